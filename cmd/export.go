@@ -0,0 +1,64 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"idm/inner/export"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// runExport idm export -kind employees|roles|assignments [-format csv|jsonl|xlsx] [-columns id,user_name]
+// [-filter 'status eq "active"'] [-o FILE] – выгрузить записи в файл или на стандартный вывод
+func runExport(service *export.Service, args []string) error {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	kind := flags.String("kind", string(export.KindEmployees), "employees, roles or assignments")
+	format := flags.String("format", "", "csv, jsonl or xlsx; by default taken from the output file extension")
+	columns := flags.String("columns", "", "comma-separated columns, all by default")
+	filter := flags.String("filter", "", "SCIM-style filter over column names")
+	output := flags.String("o", "-", "output file, - for standard output")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 0 {
+		return errors.New("unexpected arguments")
+	}
+	if *format == "" {
+		*format = string(export.FormatCSV)
+		if ext := strings.TrimPrefix(strings.ToLower(filepath.Ext(*output)), "."); ext == "jsonl" || ext == "xlsx" {
+			*format = ext
+		}
+	}
+
+	var out io.Writer = os.Stdout
+	var file *os.File
+	if *output != "-" {
+		var err error
+		if file, err = os.Create(*output); err != nil {
+			return err
+		}
+		defer func() { _ = file.Close() }()
+		out = file
+	}
+
+	count, err := service.Export(out, export.Options{
+		Kind:    export.Kind(*kind),
+		Format:  export.Format(*format),
+		Columns: export.ParseColumns(*columns),
+		Filter:  *filter,
+	})
+	if err != nil {
+		return err
+	}
+	if file != nil {
+		if err := file.Close(); err != nil {
+			return err
+		}
+	}
+	_, _ = fmt.Fprintf(os.Stderr, "exported %d %s\n", count, *kind)
+
+	return nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"idm/inner/hrsync"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// runHrReconcile idm hr-reconcile [-format csv|json] [-key user_name] [-apply] [-max-deletes 10] [-force]
+// [-map field=column ...] FILE – сверить сотрудников с выгрузкой HR и напечатать отчёт в JSON
+func runHrReconcile(service *hrsync.Service, args []string) error {
+	flags := flag.NewFlagSet("hr-reconcile", flag.ContinueOnError)
+	format := flags.String("format", "", "csv or json; by default taken from the file extension")
+	key := flags.String("key", hrsync.FieldUserName, "user_name or email")
+	apply := flags.Bool("apply", false, "correct the drift instead of only reporting it")
+	maxDeletes := flags.Int("max-deletes", hrsync.DefaultMaxDeletes, "refuse to apply if more employees would be terminated")
+	force := flags.Bool("force", false, "apply even above -max-deletes")
+	mapping := map[string]string{}
+	flags.Func("map", "field=column, may be repeated", func(value string) error {
+		field, column, ok := strings.Cut(value, "=")
+		if !ok {
+			return errors.New("expected field=column")
+		}
+		mapping[field] = column
+		return nil
+	})
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New("expected exactly one file")
+	}
+
+	path := flags.Arg(0)
+	var reader io.Reader = os.Stdin
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = file.Close() }()
+		reader = file
+	}
+	if *format == "" {
+		*format = string(hrsync.FormatCSV)
+		if strings.ToLower(filepath.Ext(path)) == ".json" {
+			*format = string(hrsync.FormatJSON)
+		}
+	}
+
+	report, err := service.Reconcile(reader, hrsync.Options{
+		Format:     hrsync.Format(*format),
+		Key:        *key,
+		Mapping:    mapping,
+		Apply:      *apply,
+		MaxDeletes: *maxDeletes,
+		Force:      *force,
+	})
+	if err != nil && !errors.Is(err, hrsync.ErrTooManyDeletes) {
+		return err
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if encodeErr := encoder.Encode(report); encodeErr != nil {
+		return encodeErr
+	}
+	if err != nil {
+		return err
+	}
+	if report.Failed > 0 {
+		return fmt.Errorf("%d of %d corrections failed", report.Failed, report.Total())
+	}
+
+	return nil
+}
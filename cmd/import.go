@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"idm/inner/bulkimport"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// runImport idm import -kind employees [-format csv|jsonl] [-key user_name] [-mode best-effort] [-dry-run]
+// [-map field=column ...] FILE – загрузить файл и напечатать отчёт в JSON. "-" вместо файла – стандартный ввод.
+func runImport(service *bulkimport.Service, args []string) error {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	kind := flags.String("kind", string(bulkimport.KindEmployees), "employees or roles")
+	format := flags.String("format", "", "csv or jsonl; by default taken from the file extension")
+	key := flags.String("key", "", "natural key to match existing records by")
+	mode := flags.String("mode", string(bulkimport.ModeAllOrNothing), "all-or-nothing or best-effort")
+	dryRun := flags.Bool("dry-run", false, "validate and count changes without saving them")
+	mapping := map[string]string{}
+	flags.Func("map", "field=column, may be repeated", func(value string) error {
+		field, column, ok := strings.Cut(value, "=")
+		if !ok {
+			return errors.New("expected field=column")
+		}
+		mapping[field] = column
+		return nil
+	})
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New("expected exactly one file")
+	}
+
+	path := flags.Arg(0)
+	var reader io.Reader = os.Stdin
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = file.Close() }()
+		reader = file
+	}
+	if *format == "" {
+		*format = string(bulkimport.FormatCSV)
+		if ext := strings.ToLower(filepath.Ext(path)); ext == ".jsonl" || ext == ".ndjson" {
+			*format = string(bulkimport.FormatJSONL)
+		}
+	}
+
+	summary, err := service.Import(reader, bulkimport.Options{
+		Kind:    bulkimport.Kind(*kind),
+		Format:  bulkimport.Format(*format),
+		Mapping: mapping,
+		Key:     *key,
+		Mode:    bulkimport.Mode(*mode),
+		DryRun:  *dryRun,
+	})
+	if err != nil && !errors.Is(err, bulkimport.ErrRejected) {
+		return err
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if encodeErr := encoder.Encode(summary); encodeErr != nil {
+		return encodeErr
+	}
+	if err != nil || summary.Failed > 0 {
+		return fmt.Errorf("%d of %d rows failed", summary.Failed, summary.Total)
+	}
+
+	return nil
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"idm/inner/common"
+	"idm/inner/employee"
+	"idm/inner/ldapsync"
+	"idm/inner/role"
+	"os"
+)
+
+// newLdapSync собрать синхронизацию с каталогом по файлу конфигурации
+func newLdapSync(cfg common.Config, db *sqlx.DB, employees *employee.Service, roles *role.Service) (*ldapsync.Service, error) {
+	file, err := os.Open(cfg.LdapSyncConfig)
+	if err != nil {
+		return nil, fmt.Errorf("error opening ldap sync config: %w", err)
+	}
+	defer func() { _ = file.Close() }()
+
+	config, err := ldapsync.LoadConfig(file)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.LdapBindPassword != "" {
+		config.BindPassword = cfg.LdapBindPassword
+	}
+
+	return ldapsync.NewService(ldapsync.NewRepository(db), ldapsync.NewDirectory(config), employees, roles, config), nil
+}
@@ -1,7 +1,327 @@
 package main
 
-import "fmt"
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"idm/inner/birthright"
+	"idm/inner/bulkimport"
+	"idm/inner/certification"
+	"idm/inner/common"
+	"idm/inner/credential"
+	"idm/inner/database"
+	"idm/inner/employee"
+	"idm/inner/export"
+	"idm/inner/graphqlapi"
+	"idm/inner/group"
+	"idm/inner/grpcapi"
+	"idm/inner/hrsync"
+	"idm/inner/httpapi"
+	"idm/inner/ldapserver"
+	"idm/inner/ldapsync"
+	"idm/inner/lifecycle"
+	"idm/inner/login"
+	"idm/inner/mfa"
+	"idm/inner/oidc"
+	"idm/inner/openapi"
+	"idm/inner/org"
+	"idm/inner/outbox"
+	"idm/inner/provisioning"
+	"idm/inner/role"
+	"idm/inner/rolecode"
+	"idm/inner/router"
+	"idm/inner/scim"
+	"idm/inner/serviceaccount"
+	"idm/inner/session"
+	"idm/inner/sod"
+	"idm/inner/webhook"
+	"idm/inner/workflow"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+)
 
 func main() {
-	fmt.Println("Hello, Go.")
+	cfg := common.GetConfig(".env")
+	db := database.ConnectDbWithCfg(cfg)
+	defer func() { _ = db.Close() }()
+
+	// ctx отменяется по SIGINT и SIGTERM: фоновые задачи завершаются, HTTP-сервер дорабатывает запросы
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	sodService := sod.NewService(sod.NewRepository(db))
+
+	roleService := role.NewService(role.NewRepository(db))
+	roleService.UseGuard(sodService)
+
+	birthrightService := birthright.NewService(birthright.NewRepository(db))
+	birthrightService.UseGuard(sodService)
+
+	groupService := group.NewService(group.NewRepository(db), roleService)
+	groupService.UseGuard(sodService)
+	// роль, отозванную правилами по умолчанию, снова выдают группы, и наоборот, если она им ещё положена.
+	// Явные выдачи и отзывы через roleService источникам не передаются: иначе роль, отозванная вручную
+	// или по итогам аттестации, тут же выдавалась бы снова.
+	birthrightService.UseHook(groupService)
+	groupService.UseHook(birthrightService)
+
+	employeeService := employee.NewService(employee.NewRepository(db))
+	employeeService.UseHook(birthrightService)
+	employeeService.UseHook(groupService)
+
+	serviceAccountService := serviceaccount.NewService(serviceaccount.NewRepository(db), roleService)
+	if len(os.Args) > 2 && os.Args[1] == "service-accounts" && os.Args[2] == "create" {
+		if err := createServiceAccount(serviceAccountService, os.Args[3:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	credentialService := credential.NewService(credential.NewRepository(db))
+	if cfg.BreachedPasswordsFile != "" {
+		file, err := os.Open(cfg.BreachedPasswordsFile)
+		if err != nil {
+			log.Fatalf("error opening breached passwords list: %v", err)
+		}
+		list, err := credential.LoadBreachedList(file)
+		_ = file.Close()
+		if err != nil {
+			log.Fatalf("error loading breached passwords list: %v", err)
+		}
+		credentialService.UseBreachedList(list)
+	}
+
+	mfaService := mfa.NewService(mfa.NewRepository(db), employeeService, "IDM")
+	base, err := url.Parse(cfg.BaseURL)
+	if err != nil {
+		log.Fatalf("error parsing base url: %v", err)
+	}
+	web, err := webauthn.New(&webauthn.Config{
+		RPID:          base.Hostname(),
+		RPDisplayName: "IDM",
+		RPOrigins:     []string{base.Scheme + "://" + base.Host},
+	})
+	if err != nil {
+		log.Fatalf("error configuring webauthn: %v", err)
+	}
+	mfaService.UseWebAuthn(web)
+
+	sessionService := session.NewService(session.NewRepository(db))
+	if cfg.SessionIdleTimeout > 0 || cfg.SessionAbsoluteTimeout > 0 {
+		idle, absolute := session.DefaultIdleTimeout, session.DefaultAbsoluteTimeout
+		if cfg.SessionIdleTimeout > 0 {
+			idle = cfg.SessionIdleTimeout
+		}
+		if cfg.SessionAbsoluteTimeout > 0 {
+			absolute = cfg.SessionAbsoluteTimeout
+		}
+		sessionService.SetTimeouts(idle, absolute)
+	}
+	if cfg.SessionCacheTTL > 0 {
+		sessionService.UseCache(cfg.SessionCacheTTL)
+	}
+	sessionService.SetSecureCookie(strings.HasPrefix(cfg.BaseURL, "https://"))
+	employeeService.UseHook(sessionService)
+	roleService.UseHook(sessionService)
+	groupService.UseHook(sessionService)
+	birthrightService.UseHook(sessionService)
+
+	importService := bulkimport.NewService(bulkimport.NewRepository(db), employeeService)
+	importService.UseHook(birthrightService)
+	importService.UseHook(groupService)
+	importService.UseHook(sessionService)
+	exportService := export.NewService(export.NewRepository(db))
+	if len(os.Args) > 1 && os.Args[1] == "export" {
+		if err := runExport(exportService, os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	lifecycleService := lifecycle.NewService(lifecycle.NewRepository(db), birthrightService)
+	lifecycleService.UseGuard(sodService)
+	// изменение пишет сотрудника и роли по умолчанию в обход сервисов: группы пересчитываются
+	// по новым атрибутам, а роли, отозванные или выданные изменением, сверяются с группами
+	lifecycleService.UseHook(groupService)
+	lifecycleService.UseSaveHook(groupService)
+	lifecycleService.Subscribe(sessionService)
+	hrService := hrsync.NewService(employeeService, lifecycleService)
+	if len(os.Args) > 1 && os.Args[1] == "hr-reconcile" {
+		if err := runHrReconcile(hrService, os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "import" {
+		if err := runImport(importService, os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	rolesService := rolecode.NewService(rolecode.NewRepository(db))
+	rolesService.UseHook(sessionService)
+	if len(os.Args) > 1 && os.Args[1] == "roles" {
+		if err := runRoles(rolesService, os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	loginService := login.NewService(employeeService, credentialService, mfaService)
+
+	workflowService := workflow.NewService(workflow.NewRepository(db), roleService)
+	go workflowService.Run(ctx, cfg.JobsInterval)
+	certificationRepository := certification.NewRepository(db)
+	certificationService := certification.NewService(certificationRepository, roleService)
+	go certificationService.Run(ctx, cfg.JobsInterval, certificationRepository)
+	go lifecycleService.Run(ctx, cfg.JobsInterval)
+
+	handlers := router.Handlers{
+		OpenAPI:         openapi.NewHandler(cfg.BaseURL),
+		Login:           login.NewHandler(loginService, sessionService),
+		Sessions:        session.NewHandler(sessionService),
+		AdminSessions:   session.NewAdminHandler(sessionService),
+		SCIM:            scim.NewHandler(employeeService, roleService, cfg.BaseURL+"/scim/v2"),
+		ServiceAccounts: serviceaccount.NewHandler(serviceAccountService),
+		Import:          bulkimport.NewHandler(importService),
+		HR:              hrsync.NewHandler(hrService),
+		Export:          export.NewHandler(exportService),
+		GraphQL:         graphqlapi.NewHandler(employeeService, roleService),
+		Org:             org.NewHandler(org.NewService(org.NewRepository(db), employeeService)),
+		Groups:          group.NewHandler(groupService),
+		AccessRequests: workflow.NewHandler(workflowService,
+			httpapi.AuthenticatorFunc[int64](currentEmployee(sessionService))),
+		AdminAccessRequests: workflow.NewAdminHandler(workflowService),
+		Certifications: certification.NewHandler(certificationService,
+			httpapi.AuthenticatorFunc[int64](currentEmployee(sessionService))),
+		AdminCertifications: certification.NewAdminHandler(certificationService),
+		Lifecycle:           lifecycle.NewHandler(lifecycleService),
+		SoD:                 sod.NewHandler(sodService),
+	}
+
+	if cfg.LdapSyncConfig != "" {
+		ldapService, err := newLdapSync(cfg, db, employeeService, roleService)
+		if err != nil {
+			log.Fatal(err)
+		}
+		handlers.LDAP = ldapsync.NewHandler(ldapService)
+		if cfg.LdapSyncInterval > 0 {
+			go func() {
+				ticker := time.NewTicker(cfg.LdapSyncInterval)
+				defer ticker.Stop()
+				for {
+					select {
+					case <-ctx.Done():
+						return
+					case <-ticker.C:
+					}
+					if _, err := ldapService.Sync(ldapsync.Options{Incremental: true}); err != nil {
+						log.Printf("error synchronizing ldap directory: %v", err)
+					}
+				}
+			}()
+		}
+	}
+
+	if cfg.LdapServerAddr != "" {
+		ldapServer, err := ldapserver.NewServer(employeeService, roleService, credentialService, cfg.LdapBaseDN)
+		if err != nil {
+			log.Fatal(err)
+		}
+		ldapServer.UseMFA(mfaService)
+		ldapServer.UseServiceAccounts(serviceAccountService)
+		if cfg.LdapTLSCert != "" {
+			certificate, err := tls.LoadX509KeyPair(cfg.LdapTLSCert, cfg.LdapTLSKey)
+			if err != nil {
+				log.Fatalf("error loading ldap tls certificate: %v", err)
+			}
+			ldapServer.UseTLS(&tls.Config{Certificates: []tls.Certificate{certificate}, MinVersion: tls.VersionTLS12})
+		}
+		go func() {
+			log.Printf("ldap listening on %s", cfg.LdapServerAddr)
+			log.Fatal(ldapServer.ListenAndServe(cfg.LdapServerAddr))
+		}()
+	}
+
+	if cfg.GrpcAddr != "" {
+		listener, err := net.Listen("tcp", cfg.GrpcAddr)
+		if err != nil {
+			log.Fatalf("error listening for grpc: %v", err)
+		}
+		grpcServer := grpcapi.NewServer(employeeService, roleService, serviceAccountService)
+		go func() {
+			log.Printf("grpc listening on %s", cfg.GrpcAddr)
+			log.Fatal(grpcServer.Serve(listener))
+		}()
+	}
+
+	webhookRepository := webhook.NewRepository(db)
+	handlers.Webhooks = webhook.NewHandler(webhook.NewService(webhookRepository))
+	publishers, err := newOutboxPublishers(cfg.OutboxPublishers, db)
+	if err != nil {
+		log.Fatal(err)
+	}
+	publishers = append(publishers, webhook.NewDispatcher(webhookRepository))
+	go outbox.NewRelay(outbox.NewRepository(db), publishers...).Run(ctx)
+	go webhook.NewDeliverer(webhookRepository, nil).Run(ctx)
+
+	if cfg.ProvisioningConfig != "" {
+		provisioningService, err := newProvisioning(cfg.ProvisioningConfig, db)
+		if err != nil {
+			log.Fatal(err)
+		}
+		handlers.Provisioning = provisioning.NewHandler(provisioningService)
+		if cfg.ProvisioningInterval > 0 {
+			go provisioningService.Run(ctx, cfg.ProvisioningInterval)
+		}
+	}
+
+	oidcService := oidc.NewService(oidc.NewRepository(db), employeeService, roleService, cfg.BaseURL+"/oidc")
+	handlers.OIDC = oidc.NewHandler(oidcService,
+		httpapi.AuthenticatorFunc[oidc.Authentication](func(r *http.Request) (oidc.Authentication, error) {
+			current, err := sessionService.FromRequest(r)
+			if err != nil {
+				if errors.Is(err, session.ErrInvalidSession) {
+					return oidc.Authentication{}, oidc.ErrLoginRequired
+				}
+				return oidc.Authentication{}, err
+			}
+			return oidc.Authentication{EmployeeId: current.EmployeeId, AuthTime: current.AuthTime}, nil
+		}))
+
+	mux := http.NewServeMux()
+	router.Routes(mux, handlers, serviceAccountService)
+
+	server := &http.Server{Addr: cfg.HttpAddr, Handler: mux}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		_ = server.Shutdown(shutdownCtx)
+	}()
+
+	log.Printf("listening on %s", cfg.HttpAddr)
+	if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		log.Fatal(err)
+	}
+}
+
+// currentEmployee сотрудник, выполнивший вход в браузере; без действующего сеанса – httpapi.ErrUnauthenticated
+func currentEmployee(sessions *session.Service) func(r *http.Request) (int64, error) {
+	return func(r *http.Request) (int64, error) {
+		current, err := sessions.FromRequest(r)
+		if errors.Is(err, session.ErrInvalidSession) {
+			return 0, httpapi.ErrUnauthenticated
+		}
+
+		return current.EmployeeId, err
+	}
 }
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"idm/inner/outbox"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// newOutboxPublishers собрать издателей из списка через запятую:
+// stdout, file:/path/events.jsonl, webhook:https://host/hook, notify:channel
+func newOutboxPublishers(targets string, db *sqlx.DB) ([]outbox.Publisher, error) {
+	var publishers []outbox.Publisher
+	for _, target := range strings.Split(targets, ",") {
+		target = strings.TrimSpace(target)
+		kind, value, _ := strings.Cut(target, ":")
+		switch {
+		case target == "":
+			continue
+		case target == "stdout":
+			publishers = append(publishers, outbox.NewWriterPublisher(os.Stdout))
+		case kind == "file" && value != "":
+			file, err := os.OpenFile(value, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+			if err != nil {
+				return nil, fmt.Errorf("error opening outbox file: %w", err)
+			}
+			publishers = append(publishers, outbox.NewWriterPublisher(file))
+		case kind == "webhook" && value != "":
+			publishers = append(publishers, outbox.NewWebhookPublisher(value, &http.Client{Timeout: 10 * time.Second}))
+		case kind == "notify" && value != "":
+			publishers = append(publishers, outbox.NewNotifyPublisher(db, value))
+		default:
+			return nil, fmt.Errorf("unknown outbox publisher %q", target)
+		}
+	}
+
+	return publishers, nil
+}
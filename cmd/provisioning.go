@@ -0,0 +1,24 @@
+package main
+
+import (
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"idm/inner/provisioning"
+	"os"
+)
+
+// newProvisioning собрать коннекторы к целевым системам по файлу конфигурации
+func newProvisioning(path string, db *sqlx.DB) (*provisioning.Service, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening provisioning config: %w", err)
+	}
+	defer func() { _ = file.Close() }()
+
+	config, err := provisioning.LoadConfig(file)
+	if err != nil {
+		return nil, err
+	}
+
+	return provisioning.NewService(provisioning.NewRepository(db), provisioning.NewRegistry(), config)
+}
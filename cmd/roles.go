@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"idm/inner/rolecode"
+	"os"
+)
+
+// runRoles idm roles plan|apply [-json] [-force] PATH... – сравнить роли с YAML-файлами
+// и каталогами с ними и при apply применить изменения
+func runRoles(service *rolecode.Service, args []string) error {
+	if len(args) == 0 || (args[0] != "plan" && args[0] != "apply") {
+		return errors.New("expected roles plan or roles apply")
+	}
+	command := args[0]
+
+	flags := flag.NewFlagSet("roles "+command, flag.ContinueOnError)
+	asJSON := flags.Bool("json", false, "print the plan as JSON")
+	force := flags.Bool("force", false, "destroy roles that still have members")
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+	if flags.NArg() == 0 {
+		return errors.New("expected at least one file or directory with role definitions")
+	}
+
+	definitions, err := rolecode.LoadFiles(flags.Args())
+	if err != nil {
+		return err
+	}
+
+	var plan rolecode.Plan
+	if command == "plan" {
+		plan, err = service.Plan(definitions)
+	} else {
+		plan, err = service.Apply(definitions, *force)
+	}
+	if plan.Hash == "" {
+		return err
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if encodeErr := encoder.Encode(plan); encodeErr != nil {
+			return encodeErr
+		}
+	} else if renderErr := plan.Render(os.Stdout); renderErr != nil {
+		return renderErr
+	}
+	if err == nil && plan.Applied {
+		_, err = os.Stdout.WriteString("\nApply complete. State hash: " + plan.Hash + "\n")
+	}
+
+	return err
+}
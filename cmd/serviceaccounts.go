@@ -0,0 +1,40 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"idm/inner/serviceaccount"
+	"strings"
+)
+
+// createServiceAccount idm service-accounts create -name NAME -scopes a,b – выпустить первый ключ,
+// когда ещё нет ключа с правом service-accounts для HTTP API
+func createServiceAccount(service *serviceaccount.Service, args []string) error {
+	flags := flag.NewFlagSet("service-accounts create", flag.ContinueOnError)
+	name := flags.String("name", "", "service account name")
+	description := flags.String("description", "", "service account description")
+	scopes := flags.String("scopes", serviceaccount.ScopeServiceAccounts, "comma-separated key scopes")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *name == "" {
+		return errors.New("-name is required")
+	}
+
+	account, err := service.Create(serviceaccount.CreateRequest{Name: *name, Description: *description})
+	if err != nil {
+		return err
+	}
+	key, err := service.IssueKey(account.Id, serviceaccount.KeyRequest{
+		Name:   "bootstrap",
+		Scopes: strings.Split(*scopes, ","),
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("service account %d %q\napi key (shown once): %s\n", account.Id, account.Name, key.Secret)
+
+	return nil
+}
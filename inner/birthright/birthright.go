@@ -0,0 +1,304 @@
+package birthright
+
+import (
+	"errors"
+	"fmt"
+	"idm/inner/employee"
+	"idm/inner/role"
+	"slices"
+)
+
+var ErrInvalidRule = errors.New("birthright rule must have a name and at least one role")
+
+type Repo interface {
+	FindAll() ([]*Rule, error)
+	FindById(id int64) (*Rule, error)
+	Create(rule *Rule) error
+	Update(rule *Rule) error
+	Remove(id int64) error
+	FindEmployee(id int64) (*employee.Employee, error)
+	FindActiveEmployees() ([]*employee.Employee, error)
+	FindAssignments(employeeId int64) ([]*Assignment, error)
+	FindAllAssignments() ([]*Assignment, error)
+	Sync(employeeId int64, grant []int64, revoke []int64) error
+}
+
+// Service назначает сотрудникам роли по умолчанию на основе их атрибутов.
+// Реализует employee.SaveHook, чтобы пересчитывать роли при создании и изменении сотрудника.
+type Service struct {
+	repo   Repo
+	source *role.Source
+}
+
+func NewService(repository Repo) *Service {
+	return &Service{repo: repository, source: role.NewSource(SourceBirthright, repository)}
+}
+
+// UseGuard добавить проверку, которая выполняется перед выдачей каждой роли
+func (s *Service) UseGuard(guard role.AssignmentGuard) {
+	s.source.UseGuard(guard)
+}
+
+// UseHook вызывать hook после выдачи и отзыва каждой роли по умолчанию
+func (s *Service) UseHook(hook role.AssignmentHook) {
+	s.source.UseHook(hook)
+}
+
+func (s *Service) FindAll() ([]Response, error) {
+	rules, err := s.repo.FindAll()
+	if err != nil {
+		return nil, fmt.Errorf("error finding all birthright rules: %w", err)
+	}
+
+	var responses []Response
+	for _, rule := range rules {
+		responses = append(responses, *rule.ToResponse())
+	}
+
+	return responses, nil
+}
+
+func (s *Service) FindById(id int64) (Response, error) {
+	rule, err := s.repo.FindById(id)
+	if err != nil {
+		return Response{}, fmt.Errorf("error finding birthright rule with id %d: %w", id, err)
+	}
+
+	return *rule.ToResponse(), nil
+}
+
+func (s *Service) Create(request RuleRequest) (Response, error) {
+	rule, err := newRule(0, request)
+	if err != nil {
+		return Response{}, err
+	}
+
+	err = s.repo.Create(rule)
+	if err != nil {
+		return Response{}, fmt.Errorf("error creating birthright rule: %w", err)
+	}
+
+	return *rule.ToResponse(), nil
+}
+
+func (s *Service) Update(id int64, request RuleRequest) (Response, error) {
+	rule, err := newRule(id, request)
+	if err != nil {
+		return Response{}, err
+	}
+
+	err = s.repo.Update(rule)
+	if err != nil {
+		return Response{}, fmt.Errorf("error updating birthright rule with id %d: %w", id, err)
+	}
+
+	return *rule.ToResponse(), nil
+}
+
+func (s *Service) Remove(id int64) error {
+	return s.repo.Remove(id)
+}
+
+// RolesFor отсортированные идентификаторы ролей, положенных сотруднику с такими атрибутами
+func (s *Service) RolesFor(attributes employee.Attributes) ([]int64, error) {
+	rules, err := s.repo.FindAll()
+	if err != nil {
+		return nil, fmt.Errorf("error finding all birthright rules: %w", err)
+	}
+
+	return rolesFor(rules, attributes), nil
+}
+
+// AfterSave пересчитать роли по умолчанию для созданного или изменённого сотрудника
+func (s *Service) AfterSave(saved employee.Response) error {
+	if saved.Status == employee.StatusDisabled {
+		return nil
+	}
+
+	_, err := s.Evaluate(saved.Id)
+
+	return err
+}
+
+// AfterAssignmentChange пересчитать роли по умолчанию после выдачи или отзыва роли другим
+// автоматическим источником. У сотрудника одна запись на роль: правило не выдаёт роль, которую он
+// уже получил иначе, и выдаёт её, когда тот источник роль отозвал. Роли отключённых сотрудников не меняются.
+func (s *Service) AfterAssignmentChange(employeeId int64, roleId int64) error {
+	empl, err := s.repo.FindEmployee(employeeId)
+	if err != nil {
+		return fmt.Errorf("error finding employee with id %d: %w", employeeId, err)
+	}
+	if empl.Status == employee.StatusDisabled {
+		return nil
+	}
+
+	_, err = s.Evaluate(employeeId)
+
+	return err
+}
+
+// Evaluate привести роли сотрудника в соответствие с правилами: выдать недостающие
+// и отозвать выданные правилами роли, которые больше не положены
+func (s *Service) Evaluate(employeeId int64) (DiffResponse, error) {
+	rules, err := s.repo.FindAll()
+	if err != nil {
+		return DiffResponse{}, fmt.Errorf("error finding all birthright rules: %w", err)
+	}
+
+	empl, err := s.repo.FindEmployee(employeeId)
+	if err != nil {
+		return DiffResponse{}, fmt.Errorf("error finding employee with id %d: %w", employeeId, err)
+	}
+
+	assignments, err := s.repo.FindAssignments(employeeId)
+	if err != nil {
+		return DiffResponse{}, fmt.Errorf("error finding roles of employee with id %d: %w", employeeId, err)
+	}
+
+	return s.apply(empl, rolesFor(rules, empl.Attributes()), assignments)
+}
+
+// EvaluateAll пересчитать роли по умолчанию для всех работающих сотрудников.
+// Возвращает только тех, у кого что-то изменилось.
+func (s *Service) EvaluateAll() ([]DiffResponse, error) {
+	rules, err := s.repo.FindAll()
+	if err != nil {
+		return nil, fmt.Errorf("error finding all birthright rules: %w", err)
+	}
+
+	employees, byEmployee, err := s.loadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	var diffs []DiffResponse
+	for _, empl := range employees {
+		diff, err := s.apply(empl, rolesFor(rules, empl.Attributes()), byEmployee[empl.Id])
+		if err != nil {
+			return diffs, err
+		}
+		if !diff.empty() {
+			diffs = append(diffs, diff)
+		}
+	}
+
+	return diffs, nil
+}
+
+// Preview показать, кто получит и кто потеряет роли, если сохранить правило.
+// ruleId = 0 с request — новое правило, ruleId с request — изменение, ruleId без request — удаление.
+// Данные не изменяются.
+func (s *Service) Preview(ruleId int64, request *RuleRequest) ([]DiffResponse, error) {
+	before, err := s.repo.FindAll()
+	if err != nil {
+		return nil, fmt.Errorf("error finding all birthright rules: %w", err)
+	}
+
+	after := slices.DeleteFunc(slices.Clone(before), func(rule *Rule) bool { return rule.Id == ruleId && ruleId != 0 })
+	if request != nil {
+		rule, err := newRule(ruleId, *request)
+		if err != nil {
+			return nil, err
+		}
+		after = append(after, rule)
+	}
+
+	employees, byEmployee, err := s.loadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	var diffs []DiffResponse
+	for _, empl := range employees {
+		held, granted := s.source.Split(byEmployee[empl.Id])
+		was := rolesFor(before, empl.Attributes())
+		will := rolesFor(after, empl.Attributes())
+
+		diff := DiffResponse{EmployeeId: empl.Id, Name: empl.Name}
+		for _, roleId := range will {
+			if !slices.Contains(was, roleId) && !slices.Contains(held, roleId) {
+				diff.Gain = append(diff.Gain, roleId)
+			}
+		}
+		for _, roleId := range was {
+			if !slices.Contains(will, roleId) && slices.Contains(granted, roleId) {
+				diff.Lose = append(diff.Lose, roleId)
+			}
+		}
+		if !diff.empty() {
+			diffs = append(diffs, diff)
+		}
+	}
+
+	return diffs, nil
+}
+
+func (s *Service) apply(empl *employee.Employee, desired []int64, assignments []*Assignment) (DiffResponse, error) {
+	diff, err := s.source.Apply(empl.Id, desired, assignments)
+
+	return DiffResponse{EmployeeId: empl.Id, Name: empl.Name, Gain: diff.Gain, Lose: diff.Lose, Blocked: diff.Blocked}, err
+}
+
+func (s *Service) loadAll() ([]*employee.Employee, map[int64][]*Assignment, error) {
+	employees, err := s.repo.FindActiveEmployees()
+	if err != nil {
+		return nil, nil, fmt.Errorf("error finding active employees: %w", err)
+	}
+
+	assignments, err := s.repo.FindAllAssignments()
+	if err != nil {
+		return nil, nil, fmt.Errorf("error finding role assignments: %w", err)
+	}
+
+	byEmployee := map[int64][]*Assignment{}
+	for _, assignment := range assignments {
+		byEmployee[assignment.EmployeeId] = append(byEmployee[assignment.EmployeeId], assignment)
+	}
+
+	return employees, byEmployee, nil
+}
+
+func newRule(id int64, request RuleRequest) (*Rule, error) {
+	roleIds := slices.Compact(slices.Sorted(slices.Values(request.RoleIds)))
+	if request.Name == "" || len(roleIds) == 0 {
+		return nil, fmt.Errorf("error saving birthright rule %q: %w", request.Name, ErrInvalidRule)
+	}
+
+	return &Rule{
+		Id:             id,
+		Name:           request.Name,
+		Department:     request.Department,
+		Title:          request.Title,
+		Location:       request.Location,
+		EmploymentType: request.EmploymentType,
+		RoleIds:        roleIds,
+	}, nil
+}
+
+func rolesFor(rules []*Rule, attributes employee.Attributes) []int64 {
+	var roleIds []int64
+	for _, rule := range rules {
+		if !rule.matches(attributes) {
+			continue
+		}
+		for _, roleId := range rule.RoleIds {
+			if !slices.Contains(roleIds, roleId) {
+				roleIds = append(roleIds, roleId)
+			}
+		}
+	}
+	slices.Sort(roleIds)
+
+	return roleIds
+}
+
+func (r *Rule) matches(attributes employee.Attributes) bool {
+	return matches(r.Department, attributes.Department) &&
+		matches(r.Title, attributes.Title) &&
+		matches(r.Location, attributes.Location) &&
+		matches(r.EmploymentType, attributes.EmploymentType)
+}
+
+func matches(condition string, value string) bool {
+	return condition == "" || condition == value
+}
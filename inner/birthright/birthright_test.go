@@ -0,0 +1,279 @@
+package birthright
+
+import (
+	"errors"
+	assertpackage "github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"idm/inner/employee"
+	"slices"
+	"testing"
+)
+
+type MockRepo struct {
+	mock.Mock
+}
+
+func (m *MockRepo) FindAll() ([]*Rule, error) {
+	args := m.Called()
+	return args.Get(0).([]*Rule), args.Error(1)
+}
+
+func (m *MockRepo) FindById(id int64) (*Rule, error) {
+	args := m.Called(id)
+	return args.Get(0).(*Rule), args.Error(1)
+}
+
+func (m *MockRepo) Create(rule *Rule) error {
+	args := m.Called(rule)
+	return args.Error(0)
+}
+
+func (m *MockRepo) Update(rule *Rule) error {
+	args := m.Called(rule)
+	return args.Error(0)
+}
+
+func (m *MockRepo) Remove(id int64) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockRepo) FindEmployee(id int64) (*employee.Employee, error) {
+	args := m.Called(id)
+	return args.Get(0).(*employee.Employee), args.Error(1)
+}
+
+func (m *MockRepo) FindActiveEmployees() ([]*employee.Employee, error) {
+	args := m.Called()
+	return args.Get(0).([]*employee.Employee), args.Error(1)
+}
+
+func (m *MockRepo) FindAssignments(employeeId int64) ([]*Assignment, error) {
+	args := m.Called(employeeId)
+	return args.Get(0).([]*Assignment), args.Error(1)
+}
+
+func (m *MockRepo) FindAllAssignments() ([]*Assignment, error) {
+	args := m.Called()
+	return args.Get(0).([]*Assignment), args.Error(1)
+}
+
+func (m *MockRepo) Sync(employeeId int64, grant []int64, revoke []int64) error {
+	args := m.Called(employeeId, grant, revoke)
+	return args.Error(0)
+}
+
+type GuardFunc func(employeeId int64, roleId int64, pending []int64) error
+
+func (f GuardFunc) CheckAssignment(employeeId int64, roleId int64, pending []int64) error {
+	return f(employeeId, roleId, pending)
+}
+
+type RecordingHook struct {
+	roleIds []int64
+}
+
+func (h *RecordingHook) AfterAssignmentChange(employeeId int64, roleId int64) error {
+	h.roleIds = append(h.roleIds, roleId)
+	return nil
+}
+
+func TestBirthrightService(t *testing.T) {
+	assert := assertpackage.New(t)
+
+	rules := []*Rule{
+		{Id: 1, Name: "everyone", RoleIds: []int64{10}},
+		{Id: 2, Name: "engineers", Department: "engineering", RoleIds: []int64{20, 21}},
+		{Id: 3, Name: "moscow office", Location: "Moscow", RoleIds: []int64{30}},
+		{Id: 4, Name: "contractors", EmploymentType: "contractor", Department: "engineering", RoleIds: []int64{40}},
+	}
+	engineer := &employee.Employee{Id: 5, Name: "John", Department: "engineering", Location: "Moscow",
+		EmploymentType: "full-time", Status: employee.StatusActive}
+
+	t.Run("RolesFor should combine matching rules", func(t *testing.T) {
+		repo := &MockRepo{}
+		service := NewService(repo)
+
+		repo.On("FindAll").Return(rules, nil)
+		got, err := service.RolesFor(engineer.Attributes())
+
+		assert.NoError(err)
+		assert.Equal([]int64{10, 20, 21, 30}, got)
+	})
+
+	t.Run("RolesFor should return wrapped error", func(t *testing.T) {
+		repo := &MockRepo{}
+		service := NewService(repo)
+
+		repo.On("FindAll").Return([]*Rule{}, errors.New("database error"))
+		_, err := service.RolesFor(engineer.Attributes())
+
+		assert.Error(err)
+	})
+
+	t.Run("Evaluate should grant missing roles and revoke stale birthright roles", func(t *testing.T) {
+		repo := &MockRepo{}
+		service := NewService(repo)
+
+		repo.On("FindAll").Return(rules, nil)
+		repo.On("FindEmployee", int64(5)).Return(engineer, nil)
+		repo.On("FindAssignments", int64(5)).Return([]*Assignment{
+			{EmployeeId: 5, RoleId: 10, Source: SourceBirthright},
+			{EmployeeId: 5, RoleId: 21, Source: "manual"},
+			{EmployeeId: 5, RoleId: 50, Source: SourceBirthright},
+			{EmployeeId: 5, RoleId: 60, Source: "manual"},
+		}, nil)
+		repo.On("Sync", int64(5), []int64{20, 30}, []int64{50}).Return(nil)
+
+		got, err := service.Evaluate(5)
+
+		assert.NoError(err)
+		assert.Equal([]int64{20, 30}, got.Gain)
+		assert.Equal([]int64{50}, got.Lose)
+	})
+
+	t.Run("AfterAssignmentChange should grant a role again after another source revoked it", func(t *testing.T) {
+		repo := &MockRepo{}
+		service := NewService(repo)
+
+		// роль 10 была у сотрудника от группы, и группа её отозвала
+		repo.On("FindAll").Return(rules, nil)
+		repo.On("FindEmployee", int64(5)).Return(engineer, nil)
+		repo.On("FindAssignments", int64(5)).Return([]*Assignment{
+			{EmployeeId: 5, RoleId: 20, Source: SourceBirthright},
+			{EmployeeId: 5, RoleId: 21, Source: SourceBirthright},
+			{EmployeeId: 5, RoleId: 30, Source: SourceBirthright},
+		}, nil)
+		repo.On("Sync", int64(5), []int64{10}, []int64(nil)).Return(nil)
+
+		assert.NoError(service.AfterAssignmentChange(5, 10))
+		repo.AssertCalled(t, "Sync", int64(5), []int64{10}, []int64(nil))
+	})
+
+	t.Run("AfterAssignmentChange should not grant roles to disabled employees", func(t *testing.T) {
+		repo := &MockRepo{}
+		service := NewService(repo)
+
+		repo.On("FindEmployee", int64(6)).Return(&employee.Employee{Id: 6, Status: employee.StatusDisabled}, nil)
+
+		assert.NoError(service.AfterAssignmentChange(6, 10))
+		repo.AssertNotCalled(t, "Sync", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Evaluate should skip roles rejected by a guard", func(t *testing.T) {
+		repo := &MockRepo{}
+		service := NewService(repo)
+		service.UseGuard(GuardFunc(func(employeeId int64, roleId int64, pending []int64) error {
+			if roleId == 30 {
+				return errors.New("violation")
+			}
+			return nil
+		}))
+
+		repo.On("FindAll").Return(rules, nil)
+		repo.On("FindEmployee", int64(5)).Return(engineer, nil)
+		repo.On("FindAssignments", int64(5)).Return([]*Assignment{}, nil)
+		repo.On("Sync", int64(5), []int64{10, 20, 21}, []int64(nil)).Return(nil)
+
+		got, err := service.Evaluate(5)
+
+		assert.NoError(err)
+		assert.Equal([]int64{30}, got.Blocked)
+	})
+
+	t.Run("Evaluate should check roles granted in one pass against each other", func(t *testing.T) {
+		repo := &MockRepo{}
+		service := NewService(repo)
+		hook := &RecordingHook{}
+		// роли 20 и 30 нельзя держать одновременно, ни одной из них у сотрудника ещё нет
+		service.UseGuard(GuardFunc(func(employeeId int64, roleId int64, pending []int64) error {
+			if roleId == 30 && slices.Contains(pending, 20) {
+				return errors.New("violation")
+			}
+			return nil
+		}))
+		service.UseHook(hook)
+
+		repo.On("FindAll").Return(rules, nil)
+		repo.On("FindEmployee", int64(5)).Return(engineer, nil)
+		repo.On("FindAssignments", int64(5)).Return([]*Assignment{}, nil)
+		repo.On("Sync", int64(5), []int64{10, 20, 21}, []int64(nil)).Return(nil)
+
+		got, err := service.Evaluate(5)
+
+		assert.NoError(err)
+		assert.Equal([]int64{10, 20, 21}, got.Gain)
+		assert.Equal([]int64{30}, got.Blocked)
+		assert.Equal([]int64{10, 20, 21}, hook.roleIds)
+	})
+
+	t.Run("AfterSave should not evaluate disabled employees", func(t *testing.T) {
+		repo := &MockRepo{}
+		service := NewService(repo)
+
+		err := service.AfterSave(employee.Response{Id: 5, Status: employee.StatusDisabled})
+
+		assert.NoError(err)
+		repo.AssertNotCalled(t, "FindAll")
+	})
+
+	t.Run("Preview should show gains and losses of a rule change without saving", func(t *testing.T) {
+		repo := &MockRepo{}
+		service := NewService(repo)
+		designer := &employee.Employee{Id: 6, Name: "Jane", Department: "design", Location: "Berlin"}
+
+		repo.On("FindAll").Return(rules, nil)
+		repo.On("FindActiveEmployees").Return([]*employee.Employee{engineer, designer}, nil)
+		repo.On("FindAllAssignments").Return([]*Assignment{
+			{EmployeeId: 5, RoleId: 10, Source: SourceBirthright},
+			{EmployeeId: 5, RoleId: 20, Source: SourceBirthright},
+			{EmployeeId: 5, RoleId: 21, Source: SourceBirthright},
+			{EmployeeId: 5, RoleId: 30, Source: SourceBirthright},
+			{EmployeeId: 6, RoleId: 10, Source: SourceBirthright},
+		}, nil)
+
+		got, err := service.Preview(2, &RuleRequest{Name: "engineers and designers", Location: "Berlin",
+			RoleIds: []int64{20, 22}})
+
+		assert.NoError(err)
+		assert.Len(got, 2)
+		assert.Equal(int64(5), got[0].EmployeeId)
+		assert.Equal([]int64{20, 21}, got[0].Lose)
+		assert.Equal(int64(6), got[1].EmployeeId)
+		assert.Equal([]int64{20, 22}, got[1].Gain)
+		repo.AssertNotCalled(t, "Sync", mock.Anything, mock.Anything, mock.Anything)
+		repo.AssertNotCalled(t, "Update", mock.Anything)
+	})
+
+	t.Run("Preview of a deletion should list losses", func(t *testing.T) {
+		repo := &MockRepo{}
+		service := NewService(repo)
+
+		repo.On("FindAll").Return(rules, nil)
+		repo.On("FindActiveEmployees").Return([]*employee.Employee{engineer}, nil)
+		repo.On("FindAllAssignments").Return([]*Assignment{
+			{EmployeeId: 5, RoleId: 30, Source: SourceBirthright},
+		}, nil)
+
+		got, err := service.Preview(3, nil)
+
+		assert.NoError(err)
+		assert.Len(got, 1)
+		assert.Equal([]int64{30}, got[0].Lose)
+		assert.Empty(got[0].Gain)
+	})
+
+	t.Run("Create should validate and deduplicate roles", func(t *testing.T) {
+		repo := &MockRepo{}
+		service := NewService(repo)
+
+		_, err := service.Create(RuleRequest{Name: "empty"})
+		assert.ErrorIs(err, ErrInvalidRule)
+
+		repo.On("Create", mock.AnythingOfType("*birthright.Rule")).Return(nil)
+		got, err := service.Create(RuleRequest{Name: "finance", Department: "finance", RoleIds: []int64{4, 3, 4}})
+
+		assert.NoError(err)
+		assert.Equal([]int64{3, 4}, got.RoleIds)
+	})
+}
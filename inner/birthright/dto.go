@@ -0,0 +1,52 @@
+package birthright
+
+import "time"
+
+type Response struct {
+	Id             int64     `json:"id"`
+	Name           string    `json:"name"`
+	Department     string    `json:"department"`
+	Title          string    `json:"title"`
+	Location       string    `json:"location"`
+	EmploymentType string    `json:"employment_type"`
+	RoleIds        []int64   `json:"role_ids"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// RuleRequest условия и роли правила
+type RuleRequest struct {
+	Name           string  `json:"name"`
+	Department     string  `json:"department"`
+	Title          string  `json:"title"`
+	Location       string  `json:"location"`
+	EmploymentType string  `json:"employment_type"`
+	RoleIds        []int64 `json:"role_ids"`
+}
+
+// DiffResponse роли, которые сотрудник получает и теряет по правилам
+type DiffResponse struct {
+	EmployeeId int64   `json:"employee_id"`
+	Name       string  `json:"name"`
+	Gain       []int64 `json:"gain,omitempty"`
+	Lose       []int64 `json:"lose,omitempty"`
+	Blocked    []int64 `json:"blocked,omitempty"`
+}
+
+func (r *Rule) ToResponse() *Response {
+	return &Response{
+		Id:             r.Id,
+		Name:           r.Name,
+		Department:     r.Department,
+		Title:          r.Title,
+		Location:       r.Location,
+		EmploymentType: r.EmploymentType,
+		RoleIds:        r.RoleIds,
+		CreatedAt:      r.CreatedAt,
+		UpdatedAt:      r.UpdatedAt,
+	}
+}
+
+func (d *DiffResponse) empty() bool {
+	return len(d.Gain) == 0 && len(d.Lose) == 0 && len(d.Blocked) == 0
+}
@@ -0,0 +1,240 @@
+package birthright
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"idm/inner/database"
+	"idm/inner/employee"
+	"idm/inner/role"
+	"time"
+)
+
+// SourceBirthright источник назначения роли, выданной правилом по умолчанию
+const SourceBirthright = "birthright"
+
+// Rule правило: сотрудникам с подходящими атрибутами положен набор ролей.
+// Пустое условие означает "любое значение".
+type Rule struct {
+	Id             int64         `db:"id"`
+	Name           string        `db:"name"`
+	Department     string        `db:"department"`
+	Title          string        `db:"title"`
+	Location       string        `db:"location"`
+	EmploymentType string        `db:"employment_type"`
+	RoleIds        pq.Int64Array `db:"role_ids"`
+	CreatedAt      time.Time     `db:"created_at"`
+	UpdatedAt      time.Time     `db:"updated_at"`
+}
+
+// Assignment роль сотрудника и источник, из которого она была выдана
+type Assignment = role.SourcedAssignment
+
+const selectRules = `SELECT r.*,
+	COALESCE(array_agg(rr.role_id ORDER BY rr.role_id) FILTER (WHERE rr.role_id IS NOT NULL), '{}') AS role_ids
+	FROM birthright_rules r LEFT JOIN birthright_rule_roles rr ON rr.rule_id = r.id`
+
+type Repository struct {
+	db *sqlx.DB
+}
+
+func NewRepository(db *sqlx.DB) *Repository {
+	return &Repository{db: db}
+}
+
+func (r *Repository) FindAll() ([]*Rule, error) {
+	var rules []*Rule
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := r.db.SelectContext(ctx, &rules, selectRules+" GROUP BY r.id ORDER BY r.id")
+
+	return rules, err
+}
+
+func (r *Repository) FindById(id int64) (*Rule, error) {
+	var rule Rule
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := r.db.GetContext(ctx, &rule, selectRules+" WHERE r.id = $1 GROUP BY r.id", id)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, database.ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &rule, err
+}
+
+func (r *Repository) Create(rule *Rule) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	err = tx.QueryRowContext(ctx,
+		`INSERT INTO birthright_rules (name, department, title, location, employment_type)
+		VALUES ($1, $2, $3, $4, $5) RETURNING id, created_at, updated_at`,
+		rule.Name, rule.Department, rule.Title, rule.Location, rule.EmploymentType,
+	).Scan(&rule.Id, &rule.CreatedAt, &rule.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	if err = insertRuleRoles(ctx, tx, rule); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *Repository) Update(rule *Rule) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	err = tx.QueryRowContext(ctx,
+		`UPDATE birthright_rules
+		SET name = $1, department = $2, title = $3, location = $4, employment_type = $5, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $6 RETURNING created_at, updated_at`,
+		rule.Name, rule.Department, rule.Title, rule.Location, rule.EmploymentType, rule.Id,
+	).Scan(&rule.CreatedAt, &rule.UpdatedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return database.ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	if _, err = tx.ExecContext(ctx, "DELETE FROM birthright_rule_roles WHERE rule_id = $1", rule.Id); err != nil {
+		return err
+	}
+	if err = insertRuleRoles(ctx, tx, rule); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *Repository) Remove(id int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := r.db.ExecContext(ctx, "DELETE FROM birthright_rules WHERE id = $1", id)
+
+	return err
+}
+
+func (r *Repository) FindEmployee(id int64) (*employee.Employee, error) {
+	var empl employee.Employee
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := r.db.GetContext(ctx, &empl, "SELECT * FROM employees WHERE id = $1", id)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, database.ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &empl, err
+}
+
+// FindActiveEmployees все работающие сотрудники
+func (r *Repository) FindActiveEmployees() ([]*employee.Employee, error) {
+	var employees []*employee.Employee
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	err := r.db.SelectContext(ctx, &employees,
+		"SELECT * FROM employees WHERE status = $1 ORDER BY id", employee.StatusActive)
+
+	return employees, err
+}
+
+func (r *Repository) FindAssignments(employeeId int64) ([]*Assignment, error) {
+	var assignments []*Assignment
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := r.db.SelectContext(ctx, &assignments,
+		"SELECT employee_id, role_id, source FROM employee_roles WHERE employee_id = $1 ORDER BY role_id",
+		employeeId)
+
+	return assignments, err
+}
+
+func (r *Repository) FindAllAssignments() ([]*Assignment, error) {
+	var assignments []*Assignment
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	err := r.db.SelectContext(ctx, &assignments,
+		"SELECT employee_id, role_id, source FROM employee_roles ORDER BY employee_id, role_id")
+
+	return assignments, err
+}
+
+// Sync выдать и отозвать роли по умолчанию в одной транзакции. Отзываются только роли,
+// которые были выданы правилами, назначенные вручную роли не затрагиваются.
+func (r *Repository) Sync(employeeId int64, grant []int64, revoke []int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO employee_roles (employee_id, role_id, source) SELECT $1, unnest($2::BIGINT[]), $3
+		ON CONFLICT DO NOTHING`,
+		employeeId, pq.Array(grant), SourceBirthright)
+	if err != nil {
+		return database.MapError(err)
+	}
+
+	_, err = tx.ExecContext(ctx,
+		"DELETE FROM employee_roles WHERE employee_id = $1 AND role_id = ANY($2) AND source = $3",
+		employeeId, pq.Array(revoke), SourceBirthright)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func insertRuleRoles(ctx context.Context, tx *sqlx.Tx, rule *Rule) error {
+	_, err := tx.ExecContext(ctx,
+		"INSERT INTO birthright_rule_roles (rule_id, role_id) SELECT $1, unnest($2::BIGINT[])",
+		rule.Id, rule.RoleIds)
+
+	return err
+}
@@ -0,0 +1,355 @@
+package bulkimport
+
+import (
+	"errors"
+	"fmt"
+	"idm/inner/employee"
+	"io"
+	"net/mail"
+	"slices"
+	"strings"
+	"time"
+)
+
+type Kind string
+
+const (
+	KindEmployees Kind = "employees"
+	KindRoles     Kind = "roles"
+)
+
+type Format string
+
+const (
+	FormatCSV   Format = "csv"
+	FormatJSONL Format = "jsonl"
+)
+
+type Mode string
+
+const (
+	// ModeAllOrNothing при любой ошибке ничего не сохраняется
+	ModeAllOrNothing Mode = "all-or-nothing"
+	// ModeBestEffort строки с ошибками пропускаются, остальные сохраняются
+	ModeBestEffort Mode = "best-effort"
+)
+
+var (
+	ErrInvalidOptions = errors.New("invalid import options")
+	ErrRejected       = errors.New("import rejected because of invalid rows")
+)
+
+// Fields поля, которые можно импортировать; manager и owner – естественный ключ другого сотрудника
+var Fields = map[Kind][]string{
+	KindEmployees: {"user_name", "name", "email", "department", "title", "location", "employment_type", "manager", "status"},
+	KindRoles:     {"name", "owner"},
+}
+
+// Keys допустимые естественные ключи; первый используется по умолчанию
+var Keys = map[Kind][]string{
+	KindEmployees: {"user_name", "email"},
+	KindRoles:     {"name"},
+}
+
+// maxErrors сколько ошибок хранить в отчёте
+const maxErrors = 1000
+
+// hookBatchSize по сколько сотрудников загружать для обработчиков после импорта
+const hookBatchSize = 500
+
+type Repo interface {
+	// Begin начать импорт в одной транзакции; строки передаются в базу потоком через COPY
+	Begin(kind Kind, key string) (Batch, error)
+}
+
+// Batch незавершённый импорт
+type Batch interface {
+	Add(row Row) error
+	// Check ошибки, которые видны только в базе: неизвестные руководители и владельцы, неоднозначные ключи
+	Check() ([]LineError, error)
+	// Exclude убрать строки из импорта
+	Exclude(lines []int) error
+	Apply() (Result, error)
+	Commit() error
+	Rollback() error
+}
+
+type Employees interface {
+	FindByIds(ids []int64) ([]employee.Response, error)
+}
+
+type Service struct {
+	repo      Repo
+	employees Employees
+	hooks     []employee.SaveHook
+	now       func() time.Time
+}
+
+func NewService(repository Repo, employees Employees) *Service {
+	return &Service{repo: repository, employees: employees, now: time.Now}
+}
+
+// UseHook вызывать обработчики сохранения сотрудника для созданных и изменённых при импорте,
+// как если бы они сохранялись по одному через employee.Service
+func (s *Service) UseHook(hook employee.SaveHook) {
+	s.hooks = append(s.hooks, hook)
+}
+
+// Import загрузить записи из reader. При ErrRejected возвращается и отчёт с ошибками.
+func (s *Service) Import(reader io.Reader, options Options) (Summary, error) {
+	started := s.now()
+	options, err := normalize(options)
+	if err != nil {
+		return Summary{}, err
+	}
+	summary := Summary{Kind: options.Kind, Key: options.Key, Mode: options.Mode, DryRun: options.DryRun, Errors: []LineError{}}
+
+	src, err := newSource(reader, options.Format)
+	if err != nil {
+		return summary, err
+	}
+	mapping, err := resolveMapping(options, src.columns())
+	if err != nil {
+		return summary, err
+	}
+
+	batch, err := s.repo.Begin(options.Kind, options.Key)
+	if err != nil {
+		return summary, fmt.Errorf("error starting %s import: %w", options.Kind, err)
+	}
+	defer func() { _ = batch.Rollback() }()
+
+	failed := map[int]bool{}
+	seen := map[string]int{}
+	for {
+		rec, err := src.next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return summary, fmt.Errorf("error reading import file: %w", err)
+		}
+
+		summary.Total++
+		if rec.err != nil {
+			summary.fail(failed, LineError{Line: rec.line, Message: rec.err.Error()})
+			continue
+		}
+
+		row := Row{Line: rec.line, Values: map[string]string{}}
+		for field, column := range mapping {
+			if value, ok := rec.values[column]; ok {
+				row.Values[field] = strings.TrimSpace(value)
+			}
+		}
+
+		problems := validate(options, row, seen)
+		if len(problems) > 0 {
+			for _, problem := range problems {
+				summary.fail(failed, problem)
+			}
+			continue
+		}
+
+		if err := batch.Add(row); err != nil {
+			return summary, fmt.Errorf("error loading line %d: %w", rec.line, err)
+		}
+	}
+
+	problems, err := batch.Check()
+	if err != nil {
+		return summary, fmt.Errorf("error checking %s import: %w", options.Kind, err)
+	}
+	var excluded []int
+	for _, problem := range problems {
+		summary.fail(failed, problem)
+		excluded = append(excluded, problem.Line)
+	}
+
+	if summary.Failed > 0 && options.Mode == ModeAllOrNothing {
+		summary.Elapsed = s.now().Sub(started).String()
+		return summary, ErrRejected
+	}
+	if len(excluded) > 0 {
+		if err := batch.Exclude(excluded); err != nil {
+			return summary, fmt.Errorf("error excluding invalid rows: %w", err)
+		}
+	}
+
+	result, err := batch.Apply()
+	if err != nil {
+		return summary, fmt.Errorf("error applying %s import: %w", options.Kind, err)
+	}
+	summary.Created = len(result.CreatedIds)
+	summary.Updated = len(result.UpdatedIds)
+	summary.Unchanged = summary.Total - summary.Failed - summary.Created - summary.Updated
+
+	if !options.DryRun {
+		if err := batch.Commit(); err != nil {
+			return summary, fmt.Errorf("error committing %s import: %w", options.Kind, err)
+		}
+		summary.Committed = true
+		if options.Kind == KindEmployees {
+			summary.Warnings = s.afterSave(slices.Concat(result.CreatedIds, result.UpdatedIds))
+		}
+	}
+
+	summary.Elapsed = s.now().Sub(started).String()
+	return summary, nil
+}
+
+// afterSave вызвать обработчики; их ошибки не отменяют уже сохранённый импорт
+func (s *Service) afterSave(ids []int64) []string {
+	if len(s.hooks) == 0 {
+		return nil
+	}
+
+	var warnings []string
+	for chunk := range slices.Chunk(ids, hookBatchSize) {
+		employees, err := s.employees.FindByIds(chunk)
+		if err != nil {
+			return append(warnings, fmt.Sprintf("error finding imported employees: %v", err))
+		}
+		for _, e := range employees {
+			for _, hook := range s.hooks {
+				if err := hook.AfterSave(e); err != nil {
+					warnings = append(warnings, fmt.Sprintf("error processing imported employee with id %d: %v", e.Id, err))
+				}
+			}
+		}
+	}
+
+	return warnings
+}
+
+func (summary *Summary) fail(failed map[int]bool, problem LineError) {
+	if !failed[problem.Line] {
+		failed[problem.Line] = true
+		summary.Failed++
+	}
+	if len(summary.Errors) < maxErrors {
+		summary.Errors = append(summary.Errors, problem)
+	} else {
+		summary.ErrorsTruncated = true
+	}
+}
+
+func normalize(options Options) (Options, error) {
+	if _, ok := Fields[options.Kind]; !ok {
+		return options, fmt.Errorf("%w: unknown kind %q", ErrInvalidOptions, options.Kind)
+	}
+	if options.Format == "" {
+		options.Format = FormatCSV
+	}
+	if options.Key == "" {
+		options.Key = Keys[options.Kind][0]
+	}
+	if !slices.Contains(Keys[options.Kind], options.Key) {
+		return options, fmt.Errorf("%w: %s cannot be matched by %q", ErrInvalidOptions, options.Kind, options.Key)
+	}
+	if options.Mode == "" {
+		options.Mode = ModeAllOrNothing
+	}
+	if options.Mode != ModeAllOrNothing && options.Mode != ModeBestEffort {
+		return options, fmt.Errorf("%w: unknown mode %q", ErrInvalidOptions, options.Mode)
+	}
+
+	return options, nil
+}
+
+// resolveMapping поле → столбец. Для CSV столбцы проверяются по заголовку сразу,
+// для JSON Lines отсутствующий в строке ключ означает, что поле не изменяется.
+func resolveMapping(options Options, columns []string) (map[string]string, error) {
+	fields := Fields[options.Kind]
+	mapping := map[string]string{}
+	for field, column := range options.Mapping {
+		if !slices.Contains(fields, field) {
+			return nil, fmt.Errorf("%w: unknown %s field %q", ErrInvalidOptions, options.Kind, field)
+		}
+		if columns != nil && !slices.Contains(columns, column) {
+			return nil, fmt.Errorf("%w: column %q mapped to %s is missing", ErrInvalidOptions, column, field)
+		}
+		mapping[field] = column
+	}
+
+	for _, field := range fields {
+		if _, ok := mapping[field]; ok {
+			continue
+		}
+		if columns == nil {
+			mapping[field] = field
+			continue
+		}
+		for _, column := range columns {
+			if strings.EqualFold(column, field) {
+				mapping[field] = column
+			}
+		}
+	}
+
+	if columns != nil {
+		for _, required := range []string{options.Key, "name"} {
+			if _, ok := mapping[required]; !ok {
+				return nil, fmt.Errorf("%w: no column for required field %s", ErrInvalidOptions, required)
+			}
+		}
+	}
+
+	return mapping, nil
+}
+
+// validate проверить строку; seen – уже встреченные значения уникальных полей и номера их строк
+func validate(options Options, row Row, seen map[string]int) []LineError {
+	var problems []LineError
+	problem := func(field string, format string, args ...any) {
+		problems = append(problems, LineError{Line: row.Line, Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+
+	key := row.Values[options.Key]
+	if key == "" {
+		problem(options.Key, "%s is required", options.Key)
+	}
+	// user_name уникально, даже если сопоставление идёт по другому ключу
+	unique := []string{options.Key}
+	if options.Kind == KindEmployees && options.Key != "user_name" {
+		unique = append(unique, "user_name")
+	}
+	for _, field := range unique {
+		value := strings.ToLower(row.Values[field])
+		if value == "" {
+			continue
+		}
+		if first, ok := seen[field+"\x00"+value]; ok {
+			problem(field, "duplicate %s %q, first seen on line %d", field, row.Values[field], first)
+			break
+		}
+		seen[field+"\x00"+value] = row.Line
+	}
+	if row.Values["name"] == "" {
+		problem("name", "name is required")
+	}
+
+	if options.Kind == KindEmployees {
+		if userName := row.Values["user_name"]; strings.ContainsFunc(userName, isSpace) {
+			problem("user_name", "user name must not contain spaces")
+		}
+		if email := row.Values["email"]; email != "" {
+			if address, err := mail.ParseAddress(email); err != nil || address.Address != email {
+				problem("email", "invalid email %q", email)
+			}
+		}
+		if status, ok := row.Values["status"]; ok && status != "" &&
+			status != employee.StatusActive && status != employee.StatusDisabled {
+			problem("status", "status must be %s or %s", employee.StatusActive, employee.StatusDisabled)
+		}
+		if manager := row.Values["manager"]; manager != "" && key != "" && strings.EqualFold(manager, key) {
+			problem("manager", "employee cannot be their own manager")
+		}
+	}
+
+	return problems
+}
+
+func isSpace(r rune) bool {
+	return r == ' ' || r == '\t' || r == '\n' || r == '\r'
+}
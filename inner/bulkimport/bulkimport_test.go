@@ -0,0 +1,313 @@
+package bulkimport
+
+import (
+	"errors"
+	assertpackage "github.com/stretchr/testify/assert"
+	"idm/inner/employee"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// StubRepo хранит загруженные строки; Check возвращает заранее заданные ошибки базы
+type StubRepo struct {
+	batch    *StubBatch
+	problems []LineError
+	result   Result
+}
+
+type StubBatch struct {
+	repo       *StubRepo
+	kind       Kind
+	key        string
+	rows       []Row
+	excluded   []int
+	applied    bool
+	committed  bool
+	rolledBack bool
+}
+
+func (r *StubRepo) Begin(kind Kind, key string) (Batch, error) {
+	r.batch = &StubBatch{repo: r, kind: kind, key: key}
+	return r.batch, nil
+}
+
+func (b *StubBatch) Add(row Row) error {
+	b.rows = append(b.rows, row)
+	return nil
+}
+
+func (b *StubBatch) Check() ([]LineError, error) {
+	return b.repo.problems, nil
+}
+
+func (b *StubBatch) Exclude(lines []int) error {
+	b.excluded = append(b.excluded, lines...)
+	return nil
+}
+
+func (b *StubBatch) Apply() (Result, error) {
+	b.applied = true
+	return b.repo.result, nil
+}
+
+func (b *StubBatch) Commit() error {
+	b.committed = true
+	return nil
+}
+
+func (b *StubBatch) Rollback() error {
+	if !b.committed {
+		b.rolledBack = true
+	}
+	return nil
+}
+
+type StubEmployees struct {
+	found []int64
+}
+
+func (s *StubEmployees) FindByIds(ids []int64) ([]employee.Response, error) {
+	s.found = append(s.found, ids...)
+	var employees []employee.Response
+	for _, id := range ids {
+		employees = append(employees, employee.Response{Id: id})
+	}
+	return employees, nil
+}
+
+type StubHook struct {
+	saved []int64
+	err   error
+}
+
+func (h *StubHook) AfterSave(e employee.Response) error {
+	h.saved = append(h.saved, e.Id)
+	return h.err
+}
+
+func TestImport(t *testing.T) {
+	var assert = assertpackage.New(t)
+
+	t.Run("should stream csv rows with a column mapping", func(t *testing.T) {
+		repo := &StubRepo{result: Result{CreatedIds: []int64{1}, UpdatedIds: []int64{2}}}
+		service := NewService(repo, &StubEmployees{})
+		file := "\ufeffLogin,Full Name,Email\n" +
+			"alice,Alice Smith,alice@example.com\n" +
+			"bob,\"Bob, Jr.\",bob@example.com\n" +
+			"carol,Carol,carol@example.com\n"
+
+		summary, err := service.Import(strings.NewReader(file), Options{
+			Kind:    KindEmployees,
+			Mapping: map[string]string{"user_name": "Login", "name": "Full Name"},
+		})
+
+		assert.Nil(err)
+		assert.Equal(ModeAllOrNothing, summary.Mode)
+		assert.Equal("user_name", summary.Key)
+		assert.True(summary.Committed)
+		assert.Equal(3, summary.Total)
+		assert.Equal(1, summary.Created)
+		assert.Equal(1, summary.Updated)
+		assert.Equal(1, summary.Unchanged)
+		assert.Equal(KindEmployees, repo.batch.kind)
+		assert.Len(repo.batch.rows, 3)
+		assert.Equal(Row{Line: 3, Values: map[string]string{
+			"user_name": "bob", "name": "Bob, Jr.", "email": "bob@example.com",
+		}}, repo.batch.rows[1])
+		assert.True(repo.batch.committed)
+	})
+
+	t.Run("should read json lines and keep absent fields", func(t *testing.T) {
+		repo := &StubRepo{}
+		service := NewService(repo, &StubEmployees{})
+		file := `{"user_name": "alice", "name": "Alice", "manager": null}` + "\n" +
+			"\n" +
+			`{"user_name": "bob", "name": "Bob", "department": 42}` + "\n" +
+			`{"user_name": "carol", "name": ["Carol"]}` + "\n" +
+			`not json` + "\n"
+
+		summary, err := service.Import(strings.NewReader(file), Options{
+			Kind: KindEmployees, Format: FormatJSONL, Mode: ModeBestEffort,
+		})
+
+		assert.Nil(err)
+		assert.Equal(4, summary.Total)
+		assert.Equal(2, summary.Failed)
+		assert.Equal([]int{4, 5}, []int{summary.Errors[0].Line, summary.Errors[1].Line})
+		assert.Equal(map[string]string{"user_name": "alice", "name": "Alice", "manager": ""}, repo.batch.rows[0].Values)
+		assert.Equal(map[string]string{"user_name": "bob", "name": "Bob", "department": "42"}, repo.batch.rows[1].Values)
+	})
+
+	t.Run("should report every invalid line and reject the whole import", func(t *testing.T) {
+		repo := &StubRepo{}
+		service := NewService(repo, &StubEmployees{})
+		file := "user_name,name,email,status,manager\n" +
+			"alice,Alice,alice@example.com,active,\n" +
+			",Nobody,,,\n" +
+			"ALICE,Alice Again,,,\n" +
+			"bo b,Bob,not-an-email,fired,\n" +
+			"carol,Carol,,,carol\n" +
+			"dave,\n"
+
+		summary, err := service.Import(strings.NewReader(file), Options{Kind: KindEmployees})
+
+		assert.ErrorIs(err, ErrRejected)
+		assert.False(summary.Committed)
+		assert.Equal(6, summary.Total)
+		assert.Equal(5, summary.Failed)
+		assert.Equal([]LineError{
+			{Line: 3, Field: "user_name", Message: "user_name is required"},
+			{Line: 4, Field: "user_name", Message: `duplicate user_name "ALICE", first seen on line 2`},
+			{Line: 5, Field: "user_name", Message: "user name must not contain spaces"},
+			{Line: 5, Field: "email", Message: `invalid email "not-an-email"`},
+			{Line: 5, Field: "status", Message: "status must be active or disabled"},
+			{Line: 6, Field: "manager", Message: "employee cannot be their own manager"},
+			{Line: 7, Message: "wrong number of fields"},
+		}, summary.Errors)
+		assert.False(repo.batch.applied)
+		assert.True(repo.batch.rolledBack)
+	})
+
+	t.Run("should detect duplicate user names when matching by email", func(t *testing.T) {
+		repo := &StubRepo{}
+		service := NewService(repo, &StubEmployees{})
+		file := "email,user_name,name\n" +
+			"alice@example.com,alice,Alice\n" +
+			"other@example.com,alice,Other Alice\n"
+
+		summary, err := service.Import(strings.NewReader(file), Options{Kind: KindEmployees, Key: "email"})
+
+		assert.ErrorIs(err, ErrRejected)
+		assert.Equal([]LineError{
+			{Line: 3, Field: "user_name", Message: `duplicate user_name "alice", first seen on line 2`},
+		}, summary.Errors)
+	})
+
+	t.Run("should skip lines rejected by the database in best-effort mode", func(t *testing.T) {
+		repo := &StubRepo{
+			problems: []LineError{{Line: 3, Field: "owner", Message: "unknown owner ghost"}},
+			result:   Result{CreatedIds: []int64{7}},
+		}
+		service := NewService(repo, &StubEmployees{})
+		file := "name,owner\nadmins,alice\nauditors,ghost\n"
+
+		summary, err := service.Import(strings.NewReader(file), Options{Kind: KindRoles, Mode: ModeBestEffort})
+
+		assert.Nil(err)
+		assert.True(summary.Committed)
+		assert.Equal(1, summary.Failed)
+		assert.Equal(1, summary.Created)
+		assert.Equal(0, summary.Unchanged)
+		assert.Equal([]int{3}, repo.batch.excluded)
+		assert.True(repo.batch.committed)
+	})
+
+	t.Run("should reject the import on database problems in all-or-nothing mode", func(t *testing.T) {
+		repo := &StubRepo{problems: []LineError{{Line: 2, Field: "manager", Message: "unknown manager ghost"}}}
+		service := NewService(repo, &StubEmployees{})
+
+		summary, err := service.Import(strings.NewReader("user_name,name,manager\nalice,Alice,ghost\n"),
+			Options{Kind: KindEmployees})
+
+		assert.ErrorIs(err, ErrRejected)
+		assert.Equal(1, summary.Failed)
+		assert.False(repo.batch.applied)
+		assert.Nil(repo.batch.excluded)
+	})
+
+	t.Run("should count changes without committing on dry run", func(t *testing.T) {
+		repo := &StubRepo{result: Result{CreatedIds: []int64{1, 2}}}
+		employees := &StubEmployees{}
+		hook := &StubHook{}
+		service := NewService(repo, employees)
+		service.UseHook(hook)
+
+		summary, err := service.Import(strings.NewReader("user_name,name\nalice,Alice\nbob,Bob\n"),
+			Options{Kind: KindEmployees, DryRun: true})
+
+		assert.Nil(err)
+		assert.True(summary.DryRun)
+		assert.False(summary.Committed)
+		assert.Equal(2, summary.Created)
+		assert.True(repo.batch.applied)
+		assert.True(repo.batch.rolledBack)
+		assert.Nil(hook.saved)
+	})
+
+	t.Run("should run save hooks for imported employees and keep their errors as warnings", func(t *testing.T) {
+		repo := &StubRepo{result: Result{CreatedIds: []int64{1}, UpdatedIds: []int64{5}}}
+		employees := &StubEmployees{}
+		hook := &StubHook{err: errors.New("sod violation")}
+		service := NewService(repo, employees)
+		service.UseHook(hook)
+
+		summary, err := service.Import(strings.NewReader("user_name,name\nalice,Alice\nbob,Bob\n"),
+			Options{Kind: KindEmployees})
+
+		assert.Nil(err)
+		assert.True(summary.Committed)
+		assert.Equal([]int64{1, 5}, hook.saved)
+		assert.Len(summary.Warnings, 2)
+		assert.Contains(summary.Warnings[0], "sod violation")
+	})
+
+	t.Run("should refuse invalid options", func(t *testing.T) {
+		service := NewService(&StubRepo{}, &StubEmployees{})
+		csv := "user_name,name\nalice,Alice\n"
+
+		for _, options := range []Options{
+			{Kind: "groups"},
+			{Kind: KindEmployees, Format: "xml"},
+			{Kind: KindEmployees, Key: "name"},
+			{Kind: KindEmployees, Mode: "sometimes"},
+			{Kind: KindEmployees, Mapping: map[string]string{"salary": "user_name"}},
+			{Kind: KindEmployees, Mapping: map[string]string{"email": "Mail"}},
+			{Kind: KindEmployees, Key: "email"},
+		} {
+			_, err := service.Import(strings.NewReader(csv), options)
+			assert.ErrorIs(err, ErrInvalidOptions, "%+v", options)
+		}
+	})
+}
+
+func TestHandler(t *testing.T) {
+	var assert = assertpackage.New(t)
+
+	t.Run("should import json lines with options from the query", func(t *testing.T) {
+		repo := &StubRepo{result: Result{CreatedIds: []int64{1}}}
+		handler := NewHandler(NewService(repo, &StubEmployees{}))
+		body := `{"login": "alice", "name": "Alice"}` + "\n"
+		request := httptest.NewRequest(http.MethodPost, "/employees?map.user_name=login&mode=best-effort", strings.NewReader(body))
+		request.Header.Set("Content-Type", "application/x-ndjson")
+		recorder := httptest.NewRecorder()
+
+		handler.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusOK, recorder.Code)
+		assert.Contains(recorder.Body.String(), `"created":1`)
+		assert.Contains(recorder.Body.String(), `"mode":"best-effort"`)
+		assert.Equal(map[string]string{"user_name": "alice", "name": "Alice"}, repo.batch.rows[0].Values)
+	})
+
+	t.Run("should answer 422 with the report when rejected", func(t *testing.T) {
+		handler := NewHandler(NewService(&StubRepo{}, &StubEmployees{}))
+		request := httptest.NewRequest(http.MethodPost, "/roles", strings.NewReader("name\n\n\"\"\n"))
+		recorder := httptest.NewRecorder()
+
+		handler.ServeHTTP(recorder, request)
+
+		assert.Equal(http.StatusUnprocessableEntity, recorder.Code)
+		assert.Contains(recorder.Body.String(), `"name is required"`)
+	})
+
+	t.Run("should answer 400 on invalid options", func(t *testing.T) {
+		handler := NewHandler(NewService(&StubRepo{}, &StubEmployees{}))
+		for _, target := range []string{"/groups", "/employees?dry_run=maybe", "/employees?format=xml"} {
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, target, strings.NewReader("user_name,name\n")))
+			assert.Equal(http.StatusBadRequest, recorder.Code, target)
+		}
+	})
+}
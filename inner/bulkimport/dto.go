@@ -0,0 +1,52 @@
+package bulkimport
+
+// Options параметры импорта
+type Options struct {
+	Kind   Kind   `json:"kind"`
+	Format Format `json:"format"`
+	// Mapping поле → столбец файла. Поля без сопоставления ищутся в столбцах с тем же именем.
+	Mapping map[string]string `json:"mapping,omitempty"`
+	// Key естественный ключ, по которому строка сопоставляется с существующей записью
+	Key    string `json:"key"`
+	Mode   Mode   `json:"mode"`
+	DryRun bool   `json:"dry_run"`
+}
+
+// Summary итог импорта
+type Summary struct {
+	Kind   Kind   `json:"kind"`
+	Key    string `json:"key"`
+	Mode   Mode   `json:"mode"`
+	DryRun bool   `json:"dry_run"`
+	// Committed изменения сохранены; false при DryRun и при отказе в режиме all-or-nothing
+	Committed bool `json:"committed"`
+	Total     int  `json:"total"`
+	Created   int  `json:"created"`
+	Updated   int  `json:"updated"`
+	Unchanged int  `json:"unchanged"`
+	Failed    int  `json:"failed"`
+	// Errors ошибки по строкам; хранится не больше maxErrors, остальные только учитываются в Failed
+	Errors          []LineError `json:"errors"`
+	ErrorsTruncated bool        `json:"errors_truncated,omitempty"`
+	Warnings        []string    `json:"warnings,omitempty"`
+	Elapsed         string      `json:"elapsed"`
+}
+
+// LineError ошибка в строке файла; Field пуст, если строку не удалось разобрать
+type LineError struct {
+	Line    int    `json:"line"`
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
+
+// Row проверенная строка. В Values нет полей, отсутствующих в файле: такие поля не изменяются.
+type Row struct {
+	Line   int
+	Values map[string]string
+}
+
+// Result изменения, внесённые в базу
+type Result struct {
+	CreatedIds []int64
+	UpdatedIds []int64
+}
@@ -0,0 +1,85 @@
+package bulkimport
+
+import (
+	"errors"
+	"idm/inner/httpapi"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// maxUploadSize ограничение размера загружаемого файла
+const maxUploadSize = 256 << 20
+
+// Handler импорт по HTTP: POST /{kind}?format=csv&key=user_name&mode=best-effort&dry_run=true&map.name=Full+Name
+// Тело запроса – сам файл; формат по умолчанию определяется по Content-Type.
+type Handler struct {
+	service *Service
+	mux     *http.ServeMux
+}
+
+func NewHandler(service *Service) *Handler {
+	h := &Handler{service: service, mux: http.NewServeMux()}
+
+	h.mux.HandleFunc("POST /{kind}", h.importFile)
+
+	return h
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+func (h *Handler) importFile(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	options := Options{
+		Kind:    Kind(r.PathValue("kind")),
+		Format:  Format(query.Get("format")),
+		Key:     query.Get("key"),
+		Mode:    Mode(query.Get("mode")),
+		Mapping: map[string]string{},
+	}
+	if options.Format == "" {
+		options.Format = formatOf(r.Header.Get("Content-Type"))
+	}
+	if value := query.Get("dry_run"); value != "" {
+		dryRun, err := strconv.ParseBool(value)
+		if err != nil {
+			httpapi.WriteError(w, http.StatusBadRequest, "invalid dry_run")
+			return
+		}
+		options.DryRun = dryRun
+	}
+	for name, values := range query {
+		if field, ok := strings.CutPrefix(name, "map."); ok && len(values) > 0 {
+			options.Mapping[field] = values[0]
+		}
+	}
+
+	summary, err := h.service.Import(http.MaxBytesReader(w, r.Body, maxUploadSize), options)
+	var tooLarge *http.MaxBytesError
+	switch {
+	case err == nil:
+		httpapi.WriteJSON(w, http.StatusOK, summary)
+	case errors.Is(err, ErrInvalidOptions):
+		httpapi.WriteError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, ErrRejected):
+		httpapi.WriteJSON(w, http.StatusUnprocessableEntity, summary)
+	case errors.As(err, &tooLarge):
+		httpapi.WriteError(w, http.StatusRequestEntityTooLarge, "file is too large")
+	default:
+		httpapi.WriteError(w, http.StatusInternalServerError, "internal error")
+	}
+}
+
+// formatOf формат по Content-Type; по умолчанию CSV
+func formatOf(contentType string) Format {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch mediaType {
+	case "application/x-ndjson", "application/jsonl", "application/x-jsonlines":
+		return FormatJSONL
+	}
+
+	return FormatCSV
+}
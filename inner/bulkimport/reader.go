@@ -0,0 +1,145 @@
+package bulkimport
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// maxLineSize ограничение длины строки JSON Lines
+const maxLineSize = 1 << 20
+
+// record строка файла: номер строки и значения по именам столбцов
+type record struct {
+	line   int
+	values map[string]string
+	err    error
+}
+
+// source последовательное чтение записей без загрузки всего файла в память.
+// Ошибка разбора строки возвращается в record.err, ошибка чтения – вторым значением.
+type source interface {
+	// columns заголовок CSV; для JSON Lines столбцы заранее неизвестны
+	columns() []string
+	next() (record, error)
+}
+
+func newSource(reader io.Reader, format Format) (source, error) {
+	switch format {
+	case FormatCSV:
+		return newCSVSource(reader)
+	case FormatJSONL:
+		scanner := bufio.NewScanner(reader)
+		scanner.Buffer(make([]byte, 64*1024), maxLineSize)
+		return &jsonlSource{scanner: scanner}, nil
+	}
+
+	return nil, fmt.Errorf("%w: unknown format %q", ErrInvalidOptions, format)
+}
+
+type csvSource struct {
+	reader *csv.Reader
+	header []string
+}
+
+func newCSVSource(reader io.Reader) (*csvSource, error) {
+	r := csv.NewReader(reader)
+	r.ReuseRecord = true
+	header, err := r.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("%w: csv file is empty", ErrInvalidOptions)
+		}
+		return nil, fmt.Errorf("error reading csv header: %w", err)
+	}
+
+	columns := make([]string, len(header))
+	for i, column := range header {
+		columns[i] = strings.TrimSpace(column)
+	}
+	columns[0] = strings.TrimPrefix(columns[0], "\ufeff")
+
+	return &csvSource{reader: r, header: columns}, nil
+}
+
+func (s *csvSource) columns() []string {
+	return s.header
+}
+
+func (s *csvSource) next() (record, error) {
+	fields, err := s.reader.Read()
+	var parseErr *csv.ParseError
+	switch {
+	case errors.As(err, &parseErr):
+		return record{line: parseErr.StartLine, err: parseErr.Err}, nil
+	case err != nil:
+		return record{}, err
+	}
+
+	line, _ := s.reader.FieldPos(0)
+	values := make(map[string]string, len(fields))
+	for i, value := range fields {
+		values[s.header[i]] = value
+	}
+
+	return record{line: line, values: values}, nil
+}
+
+type jsonlSource struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+func (s *jsonlSource) columns() []string {
+	return nil
+}
+
+func (s *jsonlSource) next() (record, error) {
+	for s.scanner.Scan() {
+		s.line++
+		data := bytes.TrimSpace(s.scanner.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+
+		var object map[string]any
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+		if err := decoder.Decode(&object); err != nil {
+			return record{line: s.line, err: fmt.Errorf("invalid json: %w", err)}, nil
+		}
+
+		values := make(map[string]string, len(object))
+		for key, value := range object {
+			switch v := value.(type) {
+			case nil:
+				values[key] = ""
+			case string:
+				values[key] = v
+			case json.Number:
+				values[key] = v.String()
+			case bool:
+				values[key] = strconv.FormatBool(v)
+			default:
+				return record{line: s.line, err: fmt.Errorf("field %q must be a scalar", key)}, nil
+			}
+		}
+
+		return record{line: s.line, values: values}, nil
+	}
+
+	if err := s.scanner.Err(); err != nil {
+		if errors.Is(err, bufio.ErrTooLong) {
+			return record{}, fmt.Errorf("line %d is longer than %d bytes", s.line+1, maxLineSize)
+		}
+		return record{}, err
+	}
+
+	return record{}, io.EOF
+}
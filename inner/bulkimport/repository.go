@@ -0,0 +1,282 @@
+package bulkimport
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"time"
+)
+
+// importTimeout общий тайм-аут импорта: в отличие от обычных запросов, он обрабатывает весь файл
+const importTimeout = 10 * time.Minute
+
+// staging временные таблицы, куда строки загружаются через COPY
+var staging = map[Kind]string{
+	KindEmployees: "import_employees",
+	KindRoles:     "import_roles",
+}
+
+type Repository struct {
+	db *sqlx.DB
+}
+
+func NewRepository(db *sqlx.DB) *Repository {
+	return &Repository{db: db}
+}
+
+type batch struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	tx     *sqlx.Tx
+	copy   *sql.Stmt
+	kind   Kind
+	key    string
+}
+
+func (r *Repository) Begin(kind Kind, key string) (Batch, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), importTimeout)
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	b := &batch{ctx: ctx, cancel: cancel, tx: tx, kind: kind, key: key}
+
+	columns := append([]string{"line"}, Fields[kind]...)
+	definitions := ""
+	for _, column := range columns[1:] {
+		definitions += ", " + column + " TEXT"
+	}
+	statements := []string{
+		// импорты выполняются по очереди, чтобы параллельные загрузки не создали дубли
+		"SELECT pg_advisory_xact_lock(hashtext('idm_import'))",
+		fmt.Sprintf("CREATE TEMP TABLE %s (line INT NOT NULL%s) ON COMMIT DROP", staging[kind], definitions),
+	}
+	for _, statement := range statements {
+		if _, err := tx.ExecContext(ctx, statement); err != nil {
+			_ = b.Rollback()
+			return nil, err
+		}
+	}
+
+	b.copy, err = tx.PrepareContext(ctx, pq.CopyIn(staging[kind], columns...))
+	if err != nil {
+		_ = b.Rollback()
+		return nil, err
+	}
+
+	return b, nil
+}
+
+func (b *batch) Add(row Row) error {
+	values := []any{row.Line}
+	for _, field := range Fields[b.kind] {
+		if value, ok := row.Values[field]; ok {
+			values = append(values, value)
+		} else {
+			values = append(values, nil)
+		}
+	}
+
+	_, err := b.copy.ExecContext(b.ctx, values...)
+
+	return err
+}
+
+// flush завершить COPY; после этого можно выполнять другие запросы в транзакции
+func (b *batch) flush() error {
+	if b.copy == nil {
+		return nil
+	}
+	if _, err := b.copy.ExecContext(b.ctx); err != nil {
+		return err
+	}
+	err := b.copy.Close()
+	b.copy = nil
+
+	return err
+}
+
+func (b *batch) Check() ([]LineError, error) {
+	if err := b.flush(); err != nil {
+		return nil, err
+	}
+
+	var query string
+	switch b.kind {
+	case KindEmployees:
+		query = fmt.Sprintf(
+			`SELECT i.line, 'manager' AS field, 'unknown manager ' || i.manager AS message
+			FROM import_employees i
+			WHERE i.manager <> ''
+				AND NOT EXISTS (SELECT 1 FROM import_employees o WHERE lower(o.%[1]s) = lower(i.manager))
+				AND NOT EXISTS (SELECT 1 FROM employees e WHERE lower(e.%[1]s) = lower(i.manager) AND e.%[1]s <> '')
+			UNION ALL
+			SELECT i.line, '%[1]s', 'several employees match ' || i.%[1]s
+			FROM import_employees i JOIN employees e ON lower(e.%[1]s) = lower(i.%[1]s)
+			GROUP BY i.line, i.%[1]s HAVING count(*) > 1
+			UNION ALL
+			SELECT i.line, 'user_name', 'user name ' || i.user_name || ' belongs to another employee'
+			FROM import_employees i JOIN employees e ON lower(e.user_name) = lower(i.user_name) AND e.user_name <> ''
+			WHERE i.user_name <> '' AND lower(e.%[1]s) IS DISTINCT FROM lower(i.%[1]s)
+			UNION ALL
+			SELECT i.line, 'manager', 'several employees match manager ' || i.manager
+			FROM import_employees i JOIN employees e ON lower(e.%[1]s) = lower(i.manager)
+			WHERE i.manager <> ''
+			GROUP BY i.line, i.manager HAVING count(*) > 1
+			ORDER BY line`, b.key)
+	case KindRoles:
+		query = `SELECT i.line, 'owner' AS field, 'unknown owner ' || i.owner AS message
+			FROM import_roles i
+			WHERE i.owner <> ''
+				AND NOT EXISTS (SELECT 1 FROM employees e WHERE lower(e.user_name) = lower(i.owner) AND e.user_name <> '')
+			UNION ALL
+			SELECT i.line, 'name', 'several roles match ' || i.name
+			FROM import_roles i JOIN roles r ON lower(r.name) = lower(i.name)
+			GROUP BY i.line, i.name HAVING count(*) > 1
+			ORDER BY line`
+	}
+
+	var problems []LineError
+	rows, err := b.tx.QueryContext(b.ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+	for rows.Next() {
+		var problem LineError
+		if err := rows.Scan(&problem.Line, &problem.Field, &problem.Message); err != nil {
+			return nil, err
+		}
+		problems = append(problems, problem)
+	}
+
+	return problems, rows.Err()
+}
+
+func (b *batch) Exclude(lines []int) error {
+	if err := b.flush(); err != nil {
+		return err
+	}
+
+	_, err := b.tx.ExecContext(b.ctx, "DELETE FROM "+staging[b.kind]+" WHERE line = ANY($1)", pq.Array(lines))
+
+	return err
+}
+
+// Apply вставить новые записи и изменить существующие. NULL во временной таблице означает,
+// что поля не было в файле, и значение в базе сохраняется. Другое название подразделения выводит
+// сотрудника из подразделения, в котором он состоял.
+func (b *batch) Apply() (Result, error) {
+	if err := b.flush(); err != nil {
+		return Result{}, err
+	}
+
+	var insert, update string
+	switch b.kind {
+	case KindEmployees:
+		insert = fmt.Sprintf(
+			`INSERT INTO employees (name, user_name, email, department, title, location, employment_type, status)
+			SELECT i.name, COALESCE(i.user_name, ''), COALESCE(i.email, ''), COALESCE(i.department, ''),
+				COALESCE(i.title, ''), COALESCE(i.location, ''), COALESCE(i.employment_type, ''),
+				COALESCE(NULLIF(i.status, ''), 'active')
+			FROM import_employees i
+			WHERE NOT EXISTS (SELECT 1 FROM employees e WHERE lower(e.%[1]s) = lower(i.%[1]s) AND e.%[1]s <> '')
+			ORDER BY i.line
+			RETURNING id`, b.key)
+		update = fmt.Sprintf(
+			`WITH source AS (
+				SELECT i.*, CASE WHEN i.manager IS NULL THEN NULL WHEN i.manager = '' THEN 0 ELSE m.id END AS manager_id
+				FROM import_employees i
+				LEFT JOIN employees m ON lower(m.%[1]s) = lower(i.manager) AND m.%[1]s <> ''
+			)
+			UPDATE employees e
+			SET name = COALESCE(i.name, e.name), user_name = COALESCE(i.user_name, e.user_name),
+				email = COALESCE(i.email, e.email), department = COALESCE(i.department, e.department),
+				department_id = CASE WHEN COALESCE(i.department, e.department) = e.department THEN e.department_id END,
+				title = COALESCE(i.title, e.title), location = COALESCE(i.location, e.location),
+				employment_type = COALESCE(i.employment_type, e.employment_type),
+				status = COALESCE(NULLIF(i.status, ''), e.status),
+				manager_id = CASE WHEN i.manager_id IS NULL THEN e.manager_id ELSE NULLIF(i.manager_id, 0) END,
+				updated_at = CURRENT_TIMESTAMP
+			FROM source i
+			WHERE lower(e.%[1]s) = lower(i.%[1]s) AND e.%[1]s <> ''
+				AND (COALESCE(i.name, e.name) IS DISTINCT FROM e.name
+					OR COALESCE(i.user_name, e.user_name) IS DISTINCT FROM e.user_name
+					OR COALESCE(i.email, e.email) IS DISTINCT FROM e.email
+					OR COALESCE(i.department, e.department) IS DISTINCT FROM e.department
+					OR COALESCE(i.title, e.title) IS DISTINCT FROM e.title
+					OR COALESCE(i.location, e.location) IS DISTINCT FROM e.location
+					OR COALESCE(i.employment_type, e.employment_type) IS DISTINCT FROM e.employment_type
+					OR COALESCE(NULLIF(i.status, ''), e.status) IS DISTINCT FROM e.status
+					OR CASE WHEN i.manager_id IS NULL THEN e.manager_id ELSE NULLIF(i.manager_id, 0) END
+						IS DISTINCT FROM e.manager_id)
+			RETURNING e.id`, b.key)
+	case KindRoles:
+		insert = `INSERT INTO roles (name, owner_id)
+			SELECT i.name, o.id
+			FROM import_roles i
+			LEFT JOIN employees o ON lower(o.user_name) = lower(i.owner) AND o.user_name <> ''
+			WHERE NOT EXISTS (SELECT 1 FROM roles r WHERE lower(r.name) = lower(i.name))
+			ORDER BY i.line
+			RETURNING id`
+		update = `WITH source AS (
+				SELECT i.*, CASE WHEN i.owner IS NULL THEN NULL WHEN i.owner = '' THEN 0 ELSE o.id END AS owner_id
+				FROM import_roles i
+				LEFT JOIN employees o ON lower(o.user_name) = lower(i.owner) AND o.user_name <> ''
+			)
+			UPDATE roles r
+			SET name = i.name,
+				owner_id = CASE WHEN i.owner_id IS NULL THEN r.owner_id ELSE NULLIF(i.owner_id, 0) END,
+				updated_at = CURRENT_TIMESTAMP
+			FROM source i
+			WHERE lower(r.name) = lower(i.name)
+				AND (i.name IS DISTINCT FROM r.name
+					OR CASE WHEN i.owner_id IS NULL THEN r.owner_id ELSE NULLIF(i.owner_id, 0) END
+						IS DISTINCT FROM r.owner_id)
+			RETURNING r.id`
+	}
+
+	var result Result
+	if err := b.tx.SelectContext(b.ctx, &result.CreatedIds, insert); err != nil {
+		return Result{}, err
+	}
+	var updated []int64
+	if err := b.tx.SelectContext(b.ctx, &updated, update); err != nil {
+		return Result{}, err
+	}
+	// только что созданным сотрудникам руководитель назначается вторым запросом – это не изменение
+	created := map[int64]bool{}
+	for _, id := range result.CreatedIds {
+		created[id] = true
+	}
+	for _, id := range updated {
+		if !created[id] {
+			result.UpdatedIds = append(result.UpdatedIds, id)
+		}
+	}
+
+	return result, nil
+}
+
+func (b *batch) Commit() error {
+	defer b.cancel()
+	if err := b.flush(); err != nil {
+		return err
+	}
+
+	return b.tx.Commit()
+}
+
+func (b *batch) Rollback() error {
+	defer b.cancel()
+	if b.copy != nil {
+		_ = b.copy.Close()
+		b.copy = nil
+	}
+
+	return b.tx.Rollback()
+}
@@ -0,0 +1,441 @@
+package certification
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"idm/inner/httpapi"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Status состояние кампании
+type Status string
+
+const (
+	StatusOpen   Status = "open"
+	StatusClosed Status = "closed"
+)
+
+// Decision решение ревьюера по элементу пересмотра
+type Decision string
+
+const (
+	DecisionPending   Decision = "pending"
+	DecisionCertified Decision = "certified"
+	DecisionRevoked   Decision = "revoked"
+)
+
+// Format формат выгрузки результатов кампании
+type Format string
+
+const (
+	FormatCSV  Format = "csv"
+	FormatJSON Format = "json"
+)
+
+var (
+	ErrEmptyScope        = errors.New("campaign must be scoped to roles or departments")
+	ErrInvalidDeadline   = errors.New("campaign deadline must be in the future")
+	ErrCampaignClosed    = errors.New("campaign is closed")
+	ErrDeadlinePassed    = errors.New("campaign deadline has passed")
+	ErrNotReviewer       = errors.New("employee is not the reviewer of the item")
+	ErrAlreadyDecided    = errors.New("item has already been decided")
+	ErrUnsupportedFormat = errors.New("unsupported export format")
+	ErrUnauthenticated   = httpapi.ErrUnauthenticated
+)
+
+type Repo interface {
+	FindById(id int64) (*Campaign, error)
+	FindAll() ([]*Campaign, error)
+	FindExpired(now time.Time) ([]*Campaign, error)
+	Create(campaign *Campaign) (int64, error)
+	Close(campaign *Campaign) error
+	FindItemById(id int64) (*Item, error)
+	FindItems(campaignId int64) ([]*Item, error)
+	FindPendingByReviewer(reviewerId int64) ([]*Item, error)
+	Decide(item *Item) error
+	FindDueReminders(now time.Time) ([]*Item, error)
+	MarkReminded(ids []int64, now time.Time) error
+}
+
+// Revoker отзывает роль у сотрудника
+type Revoker interface {
+	Revoke(employeeId int64, roleId int64) error
+}
+
+// Notifier доставляет ревьюеру напоминание о неразобранных элементах
+type Notifier interface {
+	Remind(reviewerId int64, items []ItemResponse) error
+}
+
+// CreateRequest параметры новой кампании
+type CreateRequest struct {
+	Name             string
+	OwnerId          int64
+	RoleIds          []int64
+	Departments      []string
+	Deadline         time.Time
+	ReminderInterval time.Duration
+}
+
+// Service управляет кампаниями периодического пересмотра доступов
+type Service struct {
+	repo    Repo
+	revoker Revoker
+	now     func() time.Time
+}
+
+func NewService(repository Repo, revoker Revoker) *Service {
+	return &Service{repo: repository, revoker: revoker, now: time.Now}
+}
+
+func (s *Service) FindById(id int64) (CampaignResponse, error) {
+	campaign, err := s.repo.FindById(id)
+	if err != nil {
+		return CampaignResponse{}, fmt.Errorf("error finding campaign with id %d: %w", id, err)
+	}
+
+	items, err := s.repo.FindItems(id)
+	if err != nil {
+		return CampaignResponse{}, fmt.Errorf("error finding items of campaign with id %d: %w", id, err)
+	}
+
+	return *campaign.ToResponse(items), nil
+}
+
+func (s *Service) FindAll() ([]CampaignResponse, error) {
+	campaigns, err := s.repo.FindAll()
+	if err != nil {
+		return nil, fmt.Errorf("error finding all campaigns: %w", err)
+	}
+
+	var responses []CampaignResponse
+	for _, campaign := range campaigns {
+		responses = append(responses, *campaign.ToResponse(nil))
+	}
+
+	return responses, nil
+}
+
+// Create открыть кампанию и сгенерировать элементы пересмотра
+func (s *Service) Create(request CreateRequest) (CampaignResponse, error) {
+	if len(request.RoleIds) == 0 && len(request.Departments) == 0 {
+		return CampaignResponse{}, fmt.Errorf("error creating campaign %q: %w", request.Name, ErrEmptyScope)
+	}
+	if !request.Deadline.After(s.now()) {
+		return CampaignResponse{}, fmt.Errorf("error creating campaign %q: %w", request.Name, ErrInvalidDeadline)
+	}
+	if request.ReminderInterval <= 0 {
+		request.ReminderInterval = 24 * time.Hour
+	}
+
+	campaign := &Campaign{
+		Name:                    request.Name,
+		OwnerId:                 request.OwnerId,
+		RoleIds:                 append([]int64{}, request.RoleIds...),
+		Departments:             append([]string{}, request.Departments...),
+		Status:                  StatusOpen,
+		Deadline:                request.Deadline,
+		ReminderIntervalSeconds: int64(request.ReminderInterval / time.Second),
+	}
+	_, err := s.repo.Create(campaign)
+	if err != nil {
+		return CampaignResponse{}, fmt.Errorf("error creating campaign: %w", err)
+	}
+
+	return s.FindById(campaign.Id)
+}
+
+// PendingFor элементы, ожидающие решения ревьюера
+func (s *Service) PendingFor(reviewerId int64) ([]ItemResponse, error) {
+	items, err := s.repo.FindPendingByReviewer(reviewerId)
+	if err != nil {
+		return nil, fmt.Errorf("error finding pending items of reviewer with id %d: %w", reviewerId, err)
+	}
+
+	var responses []ItemResponse
+	for _, item := range items {
+		responses = append(responses, *item.ToResponse())
+	}
+
+	return responses, nil
+}
+
+// Certify подтвердить, что доступ сотрудника по-прежнему нужен
+func (s *Service) Certify(itemId int64, reviewerId int64, comment string) (ItemResponse, error) {
+	item, err := s.loadForDecision(itemId, reviewerId)
+	if err != nil {
+		return ItemResponse{}, err
+	}
+
+	err = s.decide(item, DecisionCertified, false, comment)
+	if err != nil {
+		return ItemResponse{}, err
+	}
+
+	return *item.ToResponse(), nil
+}
+
+// Revoke отозвать роль у сотрудника по решению ревьюера
+func (s *Service) Revoke(itemId int64, reviewerId int64, comment string) (ItemResponse, error) {
+	item, err := s.loadForDecision(itemId, reviewerId)
+	if err != nil {
+		return ItemResponse{}, err
+	}
+
+	err = s.revoke(item, false, comment)
+	if err != nil {
+		return ItemResponse{}, err
+	}
+
+	return *item.ToResponse(), nil
+}
+
+// Close закрыть кампанию. Все неразобранные элементы отзываются автоматически.
+func (s *Service) Close(campaignId int64) (CampaignResponse, error) {
+	campaign, err := s.repo.FindById(campaignId)
+	if err != nil {
+		return CampaignResponse{}, fmt.Errorf("error finding campaign with id %d: %w", campaignId, err)
+	}
+	if campaign.Status == StatusClosed {
+		return CampaignResponse{}, fmt.Errorf("error closing campaign with id %d: %w", campaignId, ErrCampaignClosed)
+	}
+
+	err = s.close(campaign)
+	if err != nil {
+		return CampaignResponse{}, err
+	}
+
+	return s.FindById(campaignId)
+}
+
+// CloseExpired закрыть все кампании с истёкшим сроком. Возвращает количество закрытых кампаний.
+// Кампания, которую не удалось закрыть, не мешает закрыть остальные: её ошибка возвращается
+// вместе с ошибками других, а сама она будет закрыта при следующем запуске.
+func (s *Service) CloseExpired() (int, error) {
+	campaigns, err := s.repo.FindExpired(s.now())
+	if err != nil {
+		return 0, fmt.Errorf("error finding expired campaigns: %w", err)
+	}
+
+	closed := 0
+	var failures []error
+	for _, campaign := range campaigns {
+		if err = s.close(campaign); err != nil {
+			failures = append(failures, err)
+			continue
+		}
+		closed++
+	}
+
+	return closed, errors.Join(failures...)
+}
+
+// SendReminders напомнить ревьюерам о неразобранных элементах. Каждый ревьюер
+// получает одно напоминание со всеми своими элементами. Возвращает количество напоминаний.
+func (s *Service) SendReminders(notifier Notifier) (int, error) {
+	now := s.now()
+	items, err := s.repo.FindDueReminders(now)
+	if err != nil {
+		return 0, fmt.Errorf("error finding due reminders: %w", err)
+	}
+
+	var reviewers []int64
+	byReviewer := map[int64][]*Item{}
+	for _, item := range items {
+		if _, ok := byReviewer[item.ReviewerId]; !ok {
+			reviewers = append(reviewers, item.ReviewerId)
+		}
+		byReviewer[item.ReviewerId] = append(byReviewer[item.ReviewerId], item)
+	}
+
+	for i, reviewerId := range reviewers {
+		var responses []ItemResponse
+		var ids []int64
+		for _, item := range byReviewer[reviewerId] {
+			responses = append(responses, *item.ToResponse())
+			ids = append(ids, item.Id)
+		}
+
+		if err = notifier.Remind(reviewerId, responses); err != nil {
+			return i, fmt.Errorf("error reminding reviewer with id %d: %w", reviewerId, err)
+		}
+		if err = s.repo.MarkReminded(ids, now); err != nil {
+			return i, fmt.Errorf("error marking items of reviewer with id %d as reminded: %w", reviewerId, err)
+		}
+	}
+
+	return len(reviewers), nil
+}
+
+// Run каждые interval закрывать кампании с истёкшим сроком и напоминать ревьюерам через notifier,
+// пока не отменён ctx
+func (s *Service) Run(ctx context.Context, interval time.Duration, notifier Notifier) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := s.CloseExpired(); err != nil {
+			log.Printf("error closing expired campaigns: %v", err)
+		}
+		if _, err := s.SendReminders(notifier); err != nil {
+			log.Printf("error sending certification reminders: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Export выгрузить результаты кампании как доказательство её проведения
+func (s *Service) Export(campaignId int64, format Format, w io.Writer) error {
+	campaign, err := s.FindById(campaignId)
+	if err != nil {
+		return err
+	}
+
+	switch format {
+	case FormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+
+		return encoder.Encode(campaign)
+	case FormatCSV:
+		return writeCSV(campaign, w)
+	default:
+		return fmt.Errorf("error exporting campaign with id %d as %q: %w", campaignId, format, ErrUnsupportedFormat)
+	}
+}
+
+func (s *Service) loadForDecision(itemId int64, reviewerId int64) (*Item, error) {
+	item, err := s.repo.FindItemById(itemId)
+	if err != nil {
+		return nil, fmt.Errorf("error finding campaign item with id %d: %w", itemId, err)
+	}
+	if item.ReviewerId != reviewerId {
+		return nil, fmt.Errorf("error deciding on campaign item with id %d: %w", itemId, ErrNotReviewer)
+	}
+	if item.Decision != DecisionPending {
+		return nil, fmt.Errorf("error deciding on campaign item with id %d: %w", itemId, ErrAlreadyDecided)
+	}
+
+	campaign, err := s.repo.FindById(item.CampaignId)
+	if err != nil {
+		return nil, fmt.Errorf("error finding campaign with id %d: %w", item.CampaignId, err)
+	}
+	if campaign.Status != StatusOpen {
+		return nil, fmt.Errorf("error deciding on campaign item with id %d: %w", itemId, ErrCampaignClosed)
+	}
+	// кампания с истёкшим сроком закрывается фоновой задачей; до этого решения уже не принимаются
+	if !s.now().Before(campaign.Deadline) {
+		return nil, fmt.Errorf("error deciding on campaign item with id %d: %w", itemId, ErrDeadlinePassed)
+	}
+
+	return item, nil
+}
+
+func (s *Service) close(campaign *Campaign) error {
+	items, err := s.repo.FindItems(campaign.Id)
+	if err != nil {
+		return fmt.Errorf("error finding items of campaign with id %d: %w", campaign.Id, err)
+	}
+
+	for _, item := range items {
+		if item.Decision != DecisionPending {
+			continue
+		}
+		err = s.revoke(item, true, "not reviewed before campaign close")
+		if err != nil && !errors.Is(err, ErrAlreadyDecided) {
+			return err
+		}
+	}
+
+	closedAt := s.now()
+	campaign.Status = StatusClosed
+	campaign.ClosedAt = &closedAt
+	err = s.repo.Close(campaign)
+	if err != nil {
+		return fmt.Errorf("error closing campaign with id %d: %w", campaign.Id, err)
+	}
+
+	return nil
+}
+
+func (s *Service) revoke(item *Item, auto bool, comment string) error {
+	err := s.revoker.Revoke(item.EmployeeId, item.RoleId)
+	if err != nil {
+		return fmt.Errorf("error revoking role for campaign item with id %d: %w", item.Id, err)
+	}
+
+	return s.decide(item, DecisionRevoked, auto, comment)
+}
+
+func (s *Service) decide(item *Item, decision Decision, auto bool, comment string) error {
+	decidedAt := s.now()
+	item.Decision = decision
+	item.AutoRevoked = auto
+	item.Comment = comment
+	item.DecidedAt = &decidedAt
+
+	err := s.repo.Decide(item)
+	if err != nil {
+		return fmt.Errorf("error saving decision for campaign item with id %d: %w", item.Id, err)
+	}
+
+	return nil
+}
+
+func writeCSV(campaign CampaignResponse, w io.Writer) error {
+	writer := csv.NewWriter(w)
+	err := writer.Write([]string{
+		"campaign_id", "campaign_name", "item_id", "employee_id", "role_id", "reviewer_id",
+		"decision", "auto_revoked", "comment", "decided_at",
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, item := range campaign.Items {
+		decidedAt := ""
+		if item.DecidedAt != nil {
+			decidedAt = item.DecidedAt.Format(time.RFC3339)
+		}
+		err = writer.Write([]string{
+			strconv.FormatInt(campaign.Id, 10),
+			cell(campaign.Name),
+			strconv.FormatInt(item.Id, 10),
+			strconv.FormatInt(item.EmployeeId, 10),
+			strconv.FormatInt(item.RoleId, 10),
+			strconv.FormatInt(item.ReviewerId, 10),
+			string(item.Decision),
+			strconv.FormatBool(item.AutoRevoked),
+			cell(item.Comment),
+			decidedAt,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+
+	return writer.Error()
+}
+
+// cell значение ячейки CSV, которое табличный редактор не примет за формулу:
+// перед =, +, -, @ и управляющими символами в начале ставится апостроф
+func cell(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+
+	return value
+}
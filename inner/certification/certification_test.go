@@ -0,0 +1,376 @@
+package certification
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	assertpackage "github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"idm/inner/httpapi"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type MockRepo struct {
+	mock.Mock
+}
+
+func (m *MockRepo) FindById(id int64) (*Campaign, error) {
+	args := m.Called(id)
+	return args.Get(0).(*Campaign), args.Error(1)
+}
+
+func (m *MockRepo) FindAll() ([]*Campaign, error) {
+	args := m.Called()
+	return args.Get(0).([]*Campaign), args.Error(1)
+}
+
+func (m *MockRepo) FindExpired(now time.Time) ([]*Campaign, error) {
+	args := m.Called(now)
+	return args.Get(0).([]*Campaign), args.Error(1)
+}
+
+func (m *MockRepo) Create(campaign *Campaign) (int64, error) {
+	args := m.Called(campaign)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockRepo) Close(campaign *Campaign) error {
+	args := m.Called(campaign)
+	return args.Error(0)
+}
+
+func (m *MockRepo) FindItemById(id int64) (*Item, error) {
+	args := m.Called(id)
+	return args.Get(0).(*Item), args.Error(1)
+}
+
+func (m *MockRepo) FindItems(campaignId int64) ([]*Item, error) {
+	args := m.Called(campaignId)
+	return args.Get(0).([]*Item), args.Error(1)
+}
+
+func (m *MockRepo) FindPendingByReviewer(reviewerId int64) ([]*Item, error) {
+	args := m.Called(reviewerId)
+	return args.Get(0).([]*Item), args.Error(1)
+}
+
+func (m *MockRepo) Decide(item *Item) error {
+	args := m.Called(item)
+	return args.Error(0)
+}
+
+func (m *MockRepo) FindDueReminders(now time.Time) ([]*Item, error) {
+	args := m.Called(now)
+	return args.Get(0).([]*Item), args.Error(1)
+}
+
+func (m *MockRepo) MarkReminded(ids []int64, now time.Time) error {
+	args := m.Called(ids, now)
+	return args.Error(0)
+}
+
+type MockRevoker struct {
+	mock.Mock
+}
+
+func (m *MockRevoker) Revoke(employeeId int64, roleId int64) error {
+	args := m.Called(employeeId, roleId)
+	return args.Error(0)
+}
+
+type MockNotifier struct {
+	mock.Mock
+}
+
+func (m *MockNotifier) Remind(reviewerId int64, items []ItemResponse) error {
+	args := m.Called(reviewerId, items)
+	return args.Error(0)
+}
+
+func TestCertificationService(t *testing.T) {
+	assert := assertpackage.New(t)
+	now := time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC)
+
+	newService := func() (*Service, *MockRepo, *MockRevoker) {
+		repo := &MockRepo{}
+		revoker := &MockRevoker{}
+		service := NewService(repo, revoker)
+		service.now = func() time.Time { return now }
+
+		return service, repo, revoker
+	}
+
+	t.Run("Create should require a scope", func(t *testing.T) {
+		service, repo, _ := newService()
+
+		_, err := service.Create(CreateRequest{Name: "Q1", OwnerId: 1, Deadline: now.Add(time.Hour)})
+
+		assert.ErrorIs(err, ErrEmptyScope)
+		repo.AssertNotCalled(t, "Create", mock.Anything)
+	})
+
+	t.Run("Create should reject deadlines in the past", func(t *testing.T) {
+		service, _, _ := newService()
+
+		_, err := service.Create(CreateRequest{Name: "Q1", OwnerId: 1, RoleIds: []int64{1}, Deadline: now})
+
+		assert.ErrorIs(err, ErrInvalidDeadline)
+	})
+
+	t.Run("Create should open a campaign with generated items", func(t *testing.T) {
+		service, repo, _ := newService()
+
+		repo.On("Create", mock.AnythingOfType("*certification.Campaign")).Return(int64(2), nil).
+			Run(func(args mock.Arguments) { args.Get(0).(*Campaign).Id = 3 })
+		repo.On("FindById", int64(3)).Return(&Campaign{Id: 3, Name: "Q1", Status: StatusOpen}, nil)
+		repo.On("FindItems", int64(3)).Return([]*Item{
+			{Id: 1, CampaignId: 3, Decision: DecisionPending},
+			{Id: 2, CampaignId: 3, Decision: DecisionPending},
+		}, nil)
+
+		got, err := service.Create(CreateRequest{
+			Name:        "Q1",
+			OwnerId:     1,
+			Departments: []string{"finance"},
+			Deadline:    now.Add(14 * 24 * time.Hour),
+		})
+
+		assert.NoError(err)
+		assert.Equal(StatusOpen, got.Status)
+		assert.Equal(2, got.Progress.Pending)
+		created := repo.Calls[0].Arguments.Get(0).(*Campaign)
+		assert.Equal(int64(86400), created.ReminderIntervalSeconds)
+	})
+
+	t.Run("Certify should be allowed only for the reviewer", func(t *testing.T) {
+		service, repo, _ := newService()
+
+		repo.On("FindItemById", int64(1)).Return(&Item{Id: 1, CampaignId: 3, ReviewerId: 2,
+			Decision: DecisionPending}, nil)
+
+		_, err := service.Certify(1, 5, "")
+
+		assert.ErrorIs(err, ErrNotReviewer)
+	})
+
+	t.Run("Certify should record the decision", func(t *testing.T) {
+		service, repo, revoker := newService()
+
+		repo.On("FindItemById", int64(1)).Return(&Item{Id: 1, CampaignId: 3, ReviewerId: 2,
+			Decision: DecisionPending}, nil)
+		repo.On("FindById", int64(3)).Return(&Campaign{Id: 3, Status: StatusOpen, Deadline: now.Add(time.Hour)}, nil)
+		repo.On("Decide", mock.AnythingOfType("*certification.Item")).Return(nil)
+
+		got, err := service.Certify(1, 2, "still needed")
+
+		assert.NoError(err)
+		assert.Equal(DecisionCertified, got.Decision)
+		assert.Equal(now, *got.DecidedAt)
+		revoker.AssertNotCalled(t, "Revoke", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Revoke should revoke the role", func(t *testing.T) {
+		service, repo, revoker := newService()
+
+		repo.On("FindItemById", int64(1)).Return(&Item{Id: 1, CampaignId: 3, EmployeeId: 7, RoleId: 9,
+			ReviewerId: 2, Decision: DecisionPending}, nil)
+		repo.On("FindById", int64(3)).Return(&Campaign{Id: 3, Status: StatusOpen, Deadline: now.Add(time.Hour)}, nil)
+		repo.On("Decide", mock.AnythingOfType("*certification.Item")).Return(nil)
+		revoker.On("Revoke", int64(7), int64(9)).Return(nil)
+
+		got, err := service.Revoke(1, 2, "moved teams")
+
+		assert.NoError(err)
+		assert.Equal(DecisionRevoked, got.Decision)
+		assert.False(got.AutoRevoked)
+	})
+
+	t.Run("Certify should be rejected after the deadline even before the campaign is closed", func(t *testing.T) {
+		service, repo, _ := newService()
+
+		repo.On("FindItemById", int64(1)).Return(&Item{Id: 1, CampaignId: 3, ReviewerId: 2,
+			Decision: DecisionPending}, nil)
+		repo.On("FindById", int64(3)).Return(&Campaign{Id: 3, Status: StatusOpen, Deadline: now}, nil)
+
+		_, err := service.Certify(1, 2, "late")
+
+		assert.ErrorIs(err, ErrDeadlinePassed)
+		repo.AssertNotCalled(t, "Decide", mock.Anything)
+	})
+
+	t.Run("Close should auto-revoke unreviewed items", func(t *testing.T) {
+		service, repo, revoker := newService()
+		campaign := &Campaign{Id: 3, Status: StatusOpen}
+		items := []*Item{
+			{Id: 1, CampaignId: 3, EmployeeId: 7, RoleId: 9, Decision: DecisionCertified},
+			{Id: 2, CampaignId: 3, EmployeeId: 8, RoleId: 9, Decision: DecisionPending},
+		}
+
+		repo.On("FindById", int64(3)).Return(campaign, nil)
+		repo.On("FindItems", int64(3)).Return(items, nil)
+		repo.On("Decide", items[1]).Return(nil)
+		repo.On("Close", campaign).Return(nil)
+		revoker.On("Revoke", int64(8), int64(9)).Return(nil)
+
+		got, err := service.Close(3)
+
+		assert.NoError(err)
+		assert.Equal(StatusClosed, got.Status)
+		assert.Equal(1, got.Progress.Certified)
+		assert.Equal(1, got.Progress.AutoRevoked)
+		assert.True(revoker.AssertNumberOfCalls(t, "Revoke", 1))
+	})
+
+	t.Run("CloseExpired should close the other campaigns when one fails", func(t *testing.T) {
+		service, repo, _ := newService()
+		failing := &Campaign{Id: 3, Status: StatusOpen}
+		expired := []*Campaign{failing, {Id: 4, Status: StatusOpen}, {Id: 5, Status: StatusOpen}}
+		failure := errors.New("connection reset")
+
+		repo.On("FindExpired", now).Return(expired, nil)
+		repo.On("FindItems", int64(3)).Return([]*Item(nil), failure)
+		repo.On("FindItems", mock.Anything).Return([]*Item{}, nil)
+		repo.On("Close", mock.Anything).Return(nil)
+
+		closed, err := service.CloseExpired()
+
+		assert.Equal(2, closed)
+		assert.ErrorIs(err, failure)
+		assert.Contains(err.Error(), "campaign with id 3")
+		repo.AssertNumberOfCalls(t, "Close", 2)
+		assert.Equal(StatusOpen, failing.Status)
+	})
+
+	t.Run("SendReminders should group items by reviewer", func(t *testing.T) {
+		service, repo, _ := newService()
+		notifier := &MockNotifier{}
+		items := []*Item{
+			{Id: 1, ReviewerId: 2, Decision: DecisionPending},
+			{Id: 2, ReviewerId: 2, Decision: DecisionPending},
+			{Id: 3, ReviewerId: 4, Decision: DecisionPending},
+		}
+
+		repo.On("FindDueReminders", now).Return(items, nil)
+		repo.On("MarkReminded", mock.Anything, now).Return(nil)
+		notifier.On("Remind", mock.Anything, mock.Anything).Return(nil)
+
+		count, err := service.SendReminders(notifier)
+
+		assert.NoError(err)
+		assert.Equal(2, count)
+		repo.AssertCalled(t, "MarkReminded", []int64{1, 2}, now)
+		repo.AssertCalled(t, "MarkReminded", []int64{3}, now)
+	})
+
+	t.Run("Export should write CSV evidence", func(t *testing.T) {
+		service, repo, _ := newService()
+
+		repo.On("FindById", int64(3)).Return(&Campaign{Id: 3, Name: "Q1", Status: StatusClosed}, nil)
+		repo.On("FindItems", int64(3)).Return([]*Item{
+			{Id: 1, CampaignId: 3, EmployeeId: 7, RoleId: 9, ReviewerId: 2, Decision: DecisionCertified},
+		}, nil)
+
+		var buf bytes.Buffer
+		err := service.Export(3, FormatCSV, &buf)
+
+		assert.NoError(err)
+		records, err := csv.NewReader(&buf).ReadAll()
+		assert.NoError(err)
+		assert.Len(records, 2)
+		assert.Equal("certified", records[1][6])
+	})
+
+	t.Run("Export should neutralise spreadsheet formulas", func(t *testing.T) {
+		service, repo, _ := newService()
+
+		repo.On("FindById", int64(3)).Return(&Campaign{Id: 3, Name: "=HYPERLINK(\"http://evil\")", Status: StatusClosed}, nil)
+		repo.On("FindItems", int64(3)).Return([]*Item{
+			{Id: 1, CampaignId: 3, Decision: DecisionRevoked, Comment: "@SUM(A1)"},
+			{Id: 2, CampaignId: 3, Decision: DecisionCertified, Comment: "-1+2"},
+			{Id: 3, CampaignId: 3, Decision: DecisionCertified, Comment: "still needed"},
+		}, nil)
+
+		var buf bytes.Buffer
+		err := service.Export(3, FormatCSV, &buf)
+
+		assert.NoError(err)
+		records, err := csv.NewReader(&buf).ReadAll()
+		assert.NoError(err)
+		assert.Equal("'=HYPERLINK(\"http://evil\")", records[1][1])
+		assert.Equal("'@SUM(A1)", records[1][8])
+		assert.Equal("'-1+2", records[2][8])
+		assert.Equal("still needed", records[3][8])
+	})
+
+	t.Run("Export should reject unknown formats", func(t *testing.T) {
+		service, repo, _ := newService()
+
+		repo.On("FindById", int64(3)).Return(&Campaign{Id: 3}, nil)
+		repo.On("FindItems", int64(3)).Return([]*Item{}, nil)
+
+		err := service.Export(3, "xml", &bytes.Buffer{})
+
+		assert.ErrorIs(err, ErrUnsupportedFormat)
+	})
+}
+
+func TestCertificationHandler(t *testing.T) {
+	assert := assertpackage.New(t)
+	now := time.Now()
+
+	newHandlers := func(reviewerId int64) (*Handler, *AdminHandler, *MockRepo) {
+		repo := &MockRepo{}
+		service := NewService(repo, &MockRevoker{})
+		handler := NewHandler(service, httpapi.AuthenticatorFunc[int64](func(r *http.Request) (int64, error) {
+			if reviewerId == 0 {
+				return 0, ErrUnauthenticated
+			}
+			return reviewerId, nil
+		}))
+
+		return handler, NewAdminHandler(service), repo
+	}
+	do := func(handler http.Handler, method string, path string, body string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return recorder
+	}
+
+	t.Run("should require a signed in reviewer", func(t *testing.T) {
+		handler, _, _ := newHandlers(0)
+
+		assert.Equal(http.StatusUnauthorized, do(handler, http.MethodGet, "/items", "").Code)
+	})
+
+	t.Run("should decide on behalf of the signed in reviewer", func(t *testing.T) {
+		handler, _, repo := newHandlers(5)
+		repo.On("FindItemById", int64(1)).Return(&Item{Id: 1, CampaignId: 3, ReviewerId: 2,
+			Decision: DecisionPending}, nil)
+
+		recorder := do(handler, http.MethodPost, "/items/1/certify", `{"comment": "ok"}`)
+
+		assert.Equal(http.StatusForbidden, recorder.Code)
+	})
+
+	t.Run("should create campaigns and export evidence", func(t *testing.T) {
+		_, admin, repo := newHandlers(0)
+
+		recorder := do(admin, http.MethodPost, "/", `{"name": "Q1", "owner_id": 1, "deadline": "2000-01-01T00:00:00Z", "role_ids": [1]}`)
+		assert.Equal(http.StatusBadRequest, recorder.Code)
+
+		repo.On("FindById", int64(3)).Return(&Campaign{Id: 3, Name: "Q1", Status: StatusClosed, Deadline: now}, nil)
+		repo.On("FindItems", int64(3)).Return([]*Item{}, nil)
+
+		recorder = do(admin, http.MethodGet, "/3/export", "")
+		assert.Equal(http.StatusOK, recorder.Code)
+		assert.Equal("text/csv; charset=utf-8", recorder.Header().Get("Content-Type"))
+		assert.Contains(recorder.Body.String(), "campaign_id")
+
+		recorder = do(admin, http.MethodGet, "/3/export?format=xml", "")
+		assert.Equal(http.StatusBadRequest, recorder.Code)
+	})
+}
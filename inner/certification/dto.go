@@ -0,0 +1,112 @@
+package certification
+
+import "time"
+
+type CampaignResponse struct {
+	Id          int64          `json:"id"`
+	Name        string         `json:"name"`
+	OwnerId     int64          `json:"owner_id"`
+	RoleIds     []int64        `json:"role_ids"`
+	Departments []string       `json:"departments"`
+	Status      Status         `json:"status"`
+	Deadline    time.Time      `json:"deadline"`
+	ClosedAt    *time.Time     `json:"closed_at,omitempty"`
+	Progress    Progress       `json:"progress"`
+	Items       []ItemResponse `json:"items,omitempty"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+}
+
+// Progress сводка по решениям в кампании
+type Progress struct {
+	Total       int `json:"total"`
+	Pending     int `json:"pending"`
+	Certified   int `json:"certified"`
+	Revoked     int `json:"revoked"`
+	AutoRevoked int `json:"auto_revoked"`
+}
+
+type ItemResponse struct {
+	Id            int64      `json:"id"`
+	CampaignId    int64      `json:"campaign_id"`
+	EmployeeId    int64      `json:"employee_id"`
+	RoleId        int64      `json:"role_id"`
+	ReviewerId    int64      `json:"reviewer_id"`
+	Decision      Decision   `json:"decision"`
+	AutoRevoked   bool       `json:"auto_revoked"`
+	Comment       string     `json:"comment"`
+	DecidedAt     *time.Time `json:"decided_at,omitempty"`
+	RemindersSent int        `json:"reminders_sent"`
+}
+
+func (c *Campaign) ToResponse(items []*Item) *CampaignResponse {
+	response := &CampaignResponse{
+		Id:          c.Id,
+		Name:        c.Name,
+		OwnerId:     c.OwnerId,
+		RoleIds:     c.RoleIds,
+		Departments: c.Departments,
+		Status:      c.Status,
+		Deadline:    c.Deadline,
+		ClosedAt:    c.ClosedAt,
+		CreatedAt:   c.CreatedAt,
+		UpdatedAt:   c.UpdatedAt,
+	}
+	for _, item := range items {
+		response.Items = append(response.Items, *item.ToResponse())
+		response.Progress.Total++
+		switch {
+		case item.Decision == DecisionPending:
+			response.Progress.Pending++
+		case item.Decision == DecisionCertified:
+			response.Progress.Certified++
+		case item.AutoRevoked:
+			response.Progress.AutoRevoked++
+		default:
+			response.Progress.Revoked++
+		}
+	}
+
+	return response
+}
+
+func (i *Item) ToResponse() *ItemResponse {
+	return &ItemResponse{
+		Id:            i.Id,
+		CampaignId:    i.CampaignId,
+		EmployeeId:    i.EmployeeId,
+		RoleId:        i.RoleId,
+		ReviewerId:    i.ReviewerId,
+		Decision:      i.Decision,
+		AutoRevoked:   i.AutoRevoked,
+		Comment:       i.Comment,
+		DecidedAt:     i.DecidedAt,
+		RemindersSent: i.RemindersSent,
+	}
+}
+
+// CampaignRequest параметры новой кампании в API; без reminder_interval_seconds напоминания раз в сутки
+type CampaignRequest struct {
+	Name                    string    `json:"name"`
+	OwnerId                 int64     `json:"owner_id"`
+	RoleIds                 []int64   `json:"role_ids"`
+	Departments             []string  `json:"departments"`
+	Deadline                time.Time `json:"deadline"`
+	ReminderIntervalSeconds int64     `json:"reminder_interval_seconds"`
+}
+
+func (r CampaignRequest) toCreateRequest() CreateRequest {
+	return CreateRequest{
+		Name:             r.Name,
+		OwnerId:          r.OwnerId,
+		RoleIds:          r.RoleIds,
+		Departments:      r.Departments,
+		Deadline:         r.Deadline,
+		ReminderInterval: time.Duration(r.ReminderIntervalSeconds) * time.Second,
+	}
+}
+
+// DecisionRequest решение ревьюера
+type DecisionRequest struct {
+	Comment string `json:"comment"`
+}
@@ -0,0 +1,170 @@
+package certification
+
+import (
+	"bytes"
+	"idm/inner/httpapi"
+	"net/http"
+	"strconv"
+)
+
+// statuses коды ответа для ошибок сервиса
+var statuses = httpapi.Statuses{
+	{Err: ErrEmptyScope, Code: http.StatusBadRequest},
+	{Err: ErrInvalidDeadline, Code: http.StatusBadRequest},
+	{Err: ErrUnsupportedFormat, Code: http.StatusBadRequest},
+	{Err: ErrNotReviewer, Code: http.StatusForbidden},
+	{Err: ErrCampaignClosed, Code: http.StatusConflict},
+	{Err: ErrDeadlinePassed, Code: http.StatusConflict},
+	{Err: ErrAlreadyDecided, Code: http.StatusConflict},
+}
+
+// Handler элементы пересмотра, которые ждут решения текущего сотрудника
+type Handler struct {
+	service       *Service
+	authenticator httpapi.Authenticator[int64]
+	mux           *http.ServeMux
+}
+
+func NewHandler(service *Service, authenticator httpapi.Authenticator[int64]) *Handler {
+	h := &Handler{service: service, authenticator: authenticator, mux: http.NewServeMux()}
+
+	h.mux.HandleFunc("GET /items", h.pending)
+	h.mux.HandleFunc("POST /items/{id}/certify", h.certify)
+	h.mux.HandleFunc("POST /items/{id}/revoke", h.revoke)
+
+	return h
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+func (h *Handler) pending(w http.ResponseWriter, r *http.Request) {
+	reviewerId, err := h.authenticator.Authenticate(r)
+	if err != nil {
+		httpapi.Write(w, 0, nil, err, statuses)
+		return
+	}
+
+	items, err := h.service.PendingFor(reviewerId)
+	if items == nil {
+		items = []ItemResponse{}
+	}
+	httpapi.Write(w, http.StatusOK, items, err, statuses)
+}
+
+func (h *Handler) certify(w http.ResponseWriter, r *http.Request) {
+	h.decide(w, r, h.service.Certify)
+}
+
+func (h *Handler) revoke(w http.ResponseWriter, r *http.Request) {
+	h.decide(w, r, h.service.Revoke)
+}
+
+// decide принять решение по элементу от имени текущего сотрудника
+func (h *Handler) decide(w http.ResponseWriter, r *http.Request,
+	decision func(itemId int64, reviewerId int64, comment string) (ItemResponse, error)) {
+	reviewerId, err := h.authenticator.Authenticate(r)
+	if err != nil {
+		httpapi.Write(w, 0, nil, err, statuses)
+		return
+	}
+	id, ok := httpapi.PathId(w, r, "id")
+	if !ok {
+		return
+	}
+	var request DecisionRequest
+	if !httpapi.Decode(w, r, &request) {
+		return
+	}
+
+	item, err := decision(id, reviewerId, request.Comment)
+	httpapi.Write(w, http.StatusOK, item, err, statuses)
+}
+
+// AdminHandler кампании пересмотра: создание, ход, досрочное закрытие и выгрузка результатов
+type AdminHandler struct {
+	service *Service
+	mux     *http.ServeMux
+}
+
+func NewAdminHandler(service *Service) *AdminHandler {
+	h := &AdminHandler{service: service, mux: http.NewServeMux()}
+
+	h.mux.HandleFunc("GET /{$}", h.list)
+	h.mux.HandleFunc("POST /{$}", h.create)
+	h.mux.HandleFunc("GET /{id}", h.get)
+	h.mux.HandleFunc("POST /{id}/close", h.close)
+	h.mux.HandleFunc("GET /{id}/export", h.export)
+
+	return h
+}
+
+func (h *AdminHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+func (h *AdminHandler) list(w http.ResponseWriter, r *http.Request) {
+	campaigns, err := h.service.FindAll()
+	if campaigns == nil {
+		campaigns = []CampaignResponse{}
+	}
+	httpapi.Write(w, http.StatusOK, campaigns, err, statuses)
+}
+
+func (h *AdminHandler) create(w http.ResponseWriter, r *http.Request) {
+	var request CampaignRequest
+	if !httpapi.Decode(w, r, &request) {
+		return
+	}
+
+	campaign, err := h.service.Create(request.toCreateRequest())
+	httpapi.Write(w, http.StatusCreated, campaign, err, statuses)
+}
+
+func (h *AdminHandler) get(w http.ResponseWriter, r *http.Request) {
+	id, ok := httpapi.PathId(w, r, "id")
+	if !ok {
+		return
+	}
+
+	campaign, err := h.service.FindById(id)
+	httpapi.Write(w, http.StatusOK, campaign, err, statuses)
+}
+
+func (h *AdminHandler) close(w http.ResponseWriter, r *http.Request) {
+	id, ok := httpapi.PathId(w, r, "id")
+	if !ok {
+		return
+	}
+
+	campaign, err := h.service.Close(id)
+	httpapi.Write(w, http.StatusOK, campaign, err, statuses)
+}
+
+// export format=csv (по умолчанию) или json
+func (h *AdminHandler) export(w http.ResponseWriter, r *http.Request) {
+	id, ok := httpapi.PathId(w, r, "id")
+	if !ok {
+		return
+	}
+	format := Format(r.URL.Query().Get("format"))
+	if format == "" {
+		format = FormatCSV
+	}
+
+	var out bytes.Buffer
+	if err := h.service.Export(id, format, &out); err != nil {
+		httpapi.Write(w, 0, nil, err, statuses)
+		return
+	}
+
+	contentType := "text/csv; charset=utf-8"
+	if format == FormatJSON {
+		contentType = "application/json"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", `attachment; filename="campaign-`+strconv.FormatInt(id, 10)+"."+string(format)+`"`)
+	w.Header().Set("Cache-Control", "no-store")
+	_, _ = w.Write(out.Bytes())
+}
@@ -0,0 +1,275 @@
+package certification
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"idm/inner/database"
+	"idm/inner/outbox"
+	"time"
+)
+
+// Campaign кампания по пересмотру доступов, ограниченная ролями и/или отделами
+type Campaign struct {
+	Id                      int64          `db:"id"`
+	Name                    string         `db:"name"`
+	OwnerId                 int64          `db:"owner_id"`
+	RoleIds                 pq.Int64Array  `db:"role_ids"`
+	Departments             pq.StringArray `db:"departments"`
+	Status                  Status         `db:"status"`
+	Deadline                time.Time      `db:"deadline"`
+	ReminderIntervalSeconds int64          `db:"reminder_interval_seconds"`
+	ClosedAt                *time.Time     `db:"closed_at"`
+	CreatedAt               time.Time      `db:"created_at"`
+	UpdatedAt               time.Time      `db:"updated_at"`
+}
+
+// Item элемент пересмотра: подтвердить или отозвать роль сотрудника
+type Item struct {
+	Id             int64      `db:"id"`
+	CampaignId     int64      `db:"campaign_id"`
+	EmployeeId     int64      `db:"employee_id"`
+	RoleId         int64      `db:"role_id"`
+	ReviewerId     int64      `db:"reviewer_id"`
+	Decision       Decision   `db:"decision"`
+	AutoRevoked    bool       `db:"auto_revoked"`
+	Comment        string     `db:"comment"`
+	DecidedAt      *time.Time `db:"decided_at"`
+	RemindersSent  int        `db:"reminders_sent"`
+	LastRemindedAt *time.Time `db:"last_reminded_at"`
+}
+
+type Repository struct {
+	db *sqlx.DB
+}
+
+func NewRepository(db *sqlx.DB) *Repository {
+	return &Repository{db: db}
+}
+
+func (r *Repository) FindById(id int64) (*Campaign, error) {
+	var campaign Campaign
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := r.db.GetContext(ctx, &campaign, "SELECT * FROM certification_campaigns WHERE id = $1", id)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, database.ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &campaign, err
+}
+
+func (r *Repository) FindAll() ([]*Campaign, error) {
+	var campaigns []*Campaign
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := r.db.SelectContext(ctx, &campaigns, "SELECT * FROM certification_campaigns ORDER BY id")
+
+	return campaigns, err
+}
+
+// FindExpired открытые кампании, срок которых истёк
+func (r *Repository) FindExpired(now time.Time) ([]*Campaign, error) {
+	var campaigns []*Campaign
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := r.db.SelectContext(ctx, &campaigns,
+		"SELECT * FROM certification_campaigns WHERE status = $1 AND deadline <= $2 ORDER BY id",
+		StatusOpen, now)
+
+	return campaigns, err
+}
+
+// Create сохранить кампанию и сгенерировать элементы пересмотра для всех подходящих
+// пар сотрудник × роль. Ревьюер — руководитель сотрудника, затем владелец роли,
+// затем владелец кампании; сотрудник никогда не пересматривает собственный доступ.
+func (r *Repository) Create(campaign *Campaign) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	err = tx.QueryRowContext(ctx,
+		`INSERT INTO certification_campaigns
+		(name, owner_id, role_ids, departments, status, deadline, reminder_interval_seconds)
+		VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id, created_at, updated_at`,
+		campaign.Name, campaign.OwnerId, campaign.RoleIds, campaign.Departments, campaign.Status,
+		campaign.Deadline, campaign.ReminderIntervalSeconds,
+	).Scan(&campaign.Id, &campaign.CreatedAt, &campaign.UpdatedAt)
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := tx.ExecContext(ctx,
+		`INSERT INTO certification_items (campaign_id, employee_id, role_id, reviewer_id)
+		SELECT $1, er.employee_id, er.role_id,
+			COALESCE(NULLIF(COALESCE(e.manager_id, r.owner_id), er.employee_id), $2)
+		FROM employee_roles er
+		JOIN employees e ON e.id = er.employee_id
+		JOIN roles r ON r.id = er.role_id
+		WHERE (cardinality($3::BIGINT[]) = 0 OR er.role_id = ANY($3))
+			AND (cardinality($4::TEXT[]) = 0 OR e.department = ANY($4))`,
+		campaign.Id, campaign.OwnerId, campaign.RoleIds, campaign.Departments)
+	if err != nil {
+		return 0, err
+	}
+
+	count, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return count, tx.Commit()
+}
+
+// Close закрыть кампанию
+func (r *Repository) Close(campaign *Campaign) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return r.db.QueryRowContext(ctx,
+		`UPDATE certification_campaigns SET status = $1, closed_at = $2, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $3 RETURNING updated_at`,
+		campaign.Status, campaign.ClosedAt, campaign.Id,
+	).Scan(&campaign.UpdatedAt)
+}
+
+func (r *Repository) FindItemById(id int64) (*Item, error) {
+	var item Item
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := r.db.GetContext(ctx, &item, "SELECT * FROM certification_items WHERE id = $1", id)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, database.ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &item, err
+}
+
+func (r *Repository) FindItems(campaignId int64) ([]*Item, error) {
+	var items []*Item
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := r.db.SelectContext(ctx, &items,
+		"SELECT * FROM certification_items WHERE campaign_id = $1 ORDER BY id", campaignId)
+
+	return items, err
+}
+
+// FindPendingByReviewer элементы открытых кампаний, ожидающие решения ревьюера
+func (r *Repository) FindPendingByReviewer(reviewerId int64) ([]*Item, error) {
+	var items []*Item
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := r.db.SelectContext(ctx, &items,
+		`SELECT i.* FROM certification_items i
+		JOIN certification_campaigns c ON c.id = i.campaign_id
+		WHERE i.reviewer_id = $1 AND i.decision = $2 AND c.status = $3
+		ORDER BY i.id`,
+		reviewerId, DecisionPending, StatusOpen)
+
+	return items, err
+}
+
+// Decide сохранить решение по элементу, если оно ещё не было принято
+func (r *Repository) Decide(item *Item) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := r.db.ExecContext(ctx,
+		`UPDATE certification_items SET decision = $1, auto_revoked = $2, comment = $3, decided_at = $4
+		WHERE id = $5 AND decision = $6`,
+		item.Decision, item.AutoRevoked, item.Comment, item.DecidedAt, item.Id, DecisionPending)
+	if err != nil {
+		return err
+	}
+
+	count, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrAlreadyDecided
+	}
+
+	return nil
+}
+
+// FindDueReminders элементы открытых кампаний, по которым пора напомнить ревьюеру
+func (r *Repository) FindDueReminders(now time.Time) ([]*Item, error) {
+	var items []*Item
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := r.db.SelectContext(ctx, &items,
+		`SELECT i.* FROM certification_items i
+		JOIN certification_campaigns c ON c.id = i.campaign_id
+		WHERE i.decision = $1 AND c.status = $2 AND (
+			i.last_reminded_at IS NULL
+			OR i.last_reminded_at + make_interval(secs => c.reminder_interval_seconds) <= $3
+		)
+		ORDER BY i.reviewer_id, i.id`,
+		DecisionPending, StatusOpen, now)
+
+	return items, err
+}
+
+func (r *Repository) MarkReminded(ids []int64, now time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := r.db.ExecContext(ctx,
+		`UPDATE certification_items SET reminders_sent = reminders_sent + 1, last_reminded_at = $1
+		WHERE id = ANY($2)`,
+		now, pq.Array(ids))
+
+	return err
+}
+
+// Remind записать напоминание ревьюеру в outbox: его доставят вебхуки и остальные издатели событий.
+// Так Repository служит Notifier по умолчанию.
+func (r *Repository) Remind(reviewerId int64, items []ItemResponse) error {
+	payload, err := json.Marshal(map[string]any{"reviewer_id": reviewerId, "items": items})
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err = r.db.ExecContext(ctx,
+		"INSERT INTO outbox (event_type, aggregate_type, aggregate_id, payload) VALUES ($1, 'employee', $2, $3)",
+		outbox.CertificationReminder, reviewerId, payload)
+
+	return err
+}
@@ -0,0 +1,907 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"idm/inner/bulkimport"
+	"idm/inner/certification"
+	"idm/inner/export"
+	"idm/inner/group"
+	"idm/inner/hrsync"
+	"idm/inner/ldapsync"
+	"idm/inner/lifecycle"
+	"idm/inner/openapi"
+	"idm/inner/org"
+	"idm/inner/provisioning"
+	"idm/inner/scim"
+	"idm/inner/serviceaccount"
+	"idm/inner/session"
+	"idm/inner/sod"
+	"idm/inner/webhook"
+	"idm/inner/workflow"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Типы запросов и ответов – те же, что у обработчиков сервиса
+type (
+	ServiceAccount        = serviceaccount.Response
+	ServiceAccountRequest = serviceaccount.CreateRequest
+	Key                   = serviceaccount.KeyResponse
+	KeyRequest            = serviceaccount.KeyRequest
+	IssuedKey             = serviceaccount.IssuedKey
+	Session               = session.Response
+	ImportOptions         = bulkimport.Options
+	ImportSummary         = bulkimport.Summary
+	ExportOptions         = export.Options
+	HrOptions             = hrsync.Options
+	HrReport              = hrsync.Report
+	LdapOptions           = ldapsync.Options
+	LdapDiff              = ldapsync.Diff
+	Connector             = provisioning.Status
+	ProvisioningReport    = provisioning.Report
+	Department            = org.Response
+	DepartmentRequest     = org.Request
+	OrgMember             = org.MemberResponse
+	Headcount             = org.HeadcountResponse
+	OrgNode               = org.Node
+	OrgChart              = org.Chart
+	Group                 = group.Response
+	GroupRequest          = group.Request
+	GroupMember           = group.MemberResponse
+	GroupRoleDiff         = group.DiffResponse
+	Webhook               = webhook.Response
+	WebhookRequest        = webhook.Request
+	WebhookWithSecret     = webhook.CreatedResponse
+	DeliveryQuery         = webhook.DeliveryQuery
+	Delivery              = webhook.DeliveryResponse
+	Employee              = scim.User
+	Role                  = scim.Group
+	EmployeeList          = openapi.EmployeeList
+	RoleList              = openapi.RoleList
+	ScimPatchOperation    = scim.PatchOperation
+	ScimBulkRequest       = scim.BulkRequest
+	ScimBulkResponse      = scim.BulkResponse
+	AccessRequest         = workflow.RequestResponse
+	Campaign              = certification.CampaignResponse
+	CampaignRequest       = certification.CampaignRequest
+	CampaignFormat        = certification.Format
+	LifecycleChange       = lifecycle.ChangeResponse
+	HireChange            = lifecycle.HireChange
+	TransferChange        = lifecycle.TransferChange
+	SodRule               = sod.RuleResponse
+	SodRuleRequest        = sod.RuleRequest
+	SodException          = sod.ExceptionResponse
+	SodExceptionRequest   = sod.ExceptionRequest
+	SodViolation          = sod.ViolationResponse
+)
+
+// DefaultRotationOverlap период перекрытия ключей, который сервис применяет по умолчанию
+const DefaultRotationOverlap = serviceaccount.DefaultRotationOverlap
+
+// ChartOptions параметры схемы оргструктуры: RootId – корень поддерева, Members – вместе с сотрудниками
+type ChartOptions struct {
+	RootId  *int64
+	Members bool
+}
+
+// ScimQuery фильтр и страница списка SCIM; нулевые StartIndex и Count – с начала и наибольшая страница
+type ScimQuery struct {
+	Filter             string
+	StartIndex         int
+	Count              int
+	Attributes         []string
+	ExcludedAttributes []string
+}
+
+// GraphQLError ошибки выполнения запроса GraphQL
+type GraphQLError struct {
+	Messages []string
+}
+
+func (e *GraphQLError) Error() string {
+	return "idm: graphql: " + strings.Join(e.Messages, "; ")
+}
+
+// OpenAPI документ OpenAPI сервиса
+func (c *Client) OpenAPI(ctx context.Context) (map[string]any, error) {
+	var document map[string]any
+	err := c.do(ctx, request{method: http.MethodGet, path: "/openapi.json"}, &document)
+	return document, err
+}
+
+func (c *Client) ListServiceAccounts(ctx context.Context) ([]ServiceAccount, error) {
+	var accounts []ServiceAccount
+	err := c.do(ctx, request{method: http.MethodGet, path: "/service-accounts/"}, &accounts)
+	return accounts, err
+}
+
+func (c *Client) CreateServiceAccount(ctx context.Context, account ServiceAccountRequest) (ServiceAccount, error) {
+	var created ServiceAccount
+	err := c.do(ctx, request{method: http.MethodPost, path: "/service-accounts/", body: account}, &created)
+	return created, err
+}
+
+func (c *Client) GetServiceAccount(ctx context.Context, id int64) (ServiceAccount, error) {
+	var account ServiceAccount
+	err := c.do(ctx, request{method: http.MethodGet, path: "/service-accounts/" + format(id)}, &account)
+	return account, err
+}
+
+func (c *Client) RemoveServiceAccount(ctx context.Context, id int64) error {
+	return c.do(ctx, request{method: http.MethodDelete, path: "/service-accounts/" + format(id)}, nil)
+}
+
+func (c *Client) DisableServiceAccount(ctx context.Context, id int64) (ServiceAccount, error) {
+	var account ServiceAccount
+	err := c.do(ctx, request{method: http.MethodPost, path: "/service-accounts/" + format(id) + "/disable"}, &account)
+	return account, err
+}
+
+func (c *Client) EnableServiceAccount(ctx context.Context, id int64) (ServiceAccount, error) {
+	var account ServiceAccount
+	err := c.do(ctx, request{method: http.MethodPost, path: "/service-accounts/" + format(id) + "/enable"}, &account)
+	return account, err
+}
+
+func (c *Client) AssignServiceAccountRole(ctx context.Context, id int64, roleId int64) error {
+	return c.do(ctx, request{method: http.MethodPut, path: "/service-accounts/" + format(id) + "/roles/" + format(roleId)}, nil)
+}
+
+func (c *Client) RevokeServiceAccountRole(ctx context.Context, id int64, roleId int64) error {
+	return c.do(ctx, request{method: http.MethodDelete, path: "/service-accounts/" + format(id) + "/roles/" + format(roleId)}, nil)
+}
+
+func (c *Client) ListServiceAccountKeys(ctx context.Context, id int64) ([]Key, error) {
+	var keys []Key
+	err := c.do(ctx, request{method: http.MethodGet, path: "/service-accounts/" + format(id) + "/keys"}, &keys)
+	return keys, err
+}
+
+// IssueServiceAccountKey выпустить ключ; секрет есть только в этом ответе
+func (c *Client) IssueServiceAccountKey(ctx context.Context, id int64, key KeyRequest) (IssuedKey, error) {
+	var issued IssuedKey
+	err := c.do(ctx, request{method: http.MethodPost, path: "/service-accounts/" + format(id) + "/keys", body: key}, &issued)
+	return issued, err
+}
+
+// RotateServiceAccountKey выпустить замену ключа; прежний действует ещё overlap,
+// обычно DefaultRotationOverlap
+func (c *Client) RotateServiceAccountKey(ctx context.Context, id int64, keyId int64, overlap time.Duration) (IssuedKey, error) {
+	var issued IssuedKey
+	err := c.do(ctx, request{
+		method: http.MethodPost,
+		path:   "/service-accounts/" + format(id) + "/keys/" + format(keyId) + "/rotate",
+		query:  url.Values{"overlap": {overlap.String()}},
+	}, &issued)
+	return issued, err
+}
+
+func (c *Client) RevokeServiceAccountKey(ctx context.Context, id int64, keyId int64) error {
+	return c.do(ctx, request{method: http.MethodDelete, path: "/service-accounts/" + format(id) + "/keys/" + format(keyId)}, nil)
+}
+
+func (c *Client) ListEmployeeSessions(ctx context.Context, employeeId int64) ([]Session, error) {
+	var sessions []Session
+	err := c.do(ctx, request{method: http.MethodGet, path: "/admin/employees/" + format(employeeId) + "/sessions"}, &sessions)
+	return sessions, err
+}
+
+// RevokeEmployeeSessions завершить все сеансы сотрудника и вернуть их число
+func (c *Client) RevokeEmployeeSessions(ctx context.Context, employeeId int64) (int, error) {
+	var result struct {
+		Revoked int `json:"revoked"`
+	}
+	err := c.do(ctx, request{method: http.MethodDelete, path: "/admin/employees/" + format(employeeId) + "/sessions"}, &result)
+	return result.Revoked, err
+}
+
+func (c *Client) RevokeEmployeeSession(ctx context.Context, employeeId int64, id int64) error {
+	return c.do(ctx, request{method: http.MethodDelete,
+		path: "/admin/employees/" + format(employeeId) + "/sessions/" + format(id)}, nil)
+}
+
+// ImportEmployees загрузить сотрудников из файла; options.Kind не учитывается. При отказе
+// в режиме all-or-nothing вместе с ErrRejected возвращается итог с ошибками по строкам.
+func (c *Client) ImportEmployees(ctx context.Context, file io.Reader, options ImportOptions) (ImportSummary, error) {
+	return c.importFile(ctx, bulkimport.KindEmployees, file, options)
+}
+
+func (c *Client) ImportRoles(ctx context.Context, file io.Reader, options ImportOptions) (ImportSummary, error) {
+	return c.importFile(ctx, bulkimport.KindRoles, file, options)
+}
+
+func (c *Client) importFile(ctx context.Context, kind bulkimport.Kind, file io.Reader, options ImportOptions) (ImportSummary, error) {
+	query := url.Values{}
+	set(query, "format", string(options.Format))
+	set(query, "key", options.Key)
+	set(query, "mode", string(options.Mode))
+	if options.DryRun {
+		query.Set("dry_run", "true")
+	}
+	for field, column := range options.Mapping {
+		query.Set("map."+field, column)
+	}
+	contentType := "text/csv"
+	if options.Format == bulkimport.FormatJSONL {
+		contentType = "application/x-ndjson"
+	}
+
+	var summary ImportSummary
+	err := c.do(ctx, request{method: http.MethodPost, path: "/import/" + string(kind), query: query,
+		upload: file, contentType: contentType, detail: &summary}, &summary)
+	return summary, err
+}
+
+// ExportEmployees выгрузка сотрудников; options.Kind не учитывается. Поток закрывает вызывающий.
+func (c *Client) ExportEmployees(ctx context.Context, options ExportOptions) (io.ReadCloser, error) {
+	return c.export(ctx, export.KindEmployees, options)
+}
+
+func (c *Client) ExportRoles(ctx context.Context, options ExportOptions) (io.ReadCloser, error) {
+	return c.export(ctx, export.KindRoles, options)
+}
+
+func (c *Client) ExportAssignments(ctx context.Context, options ExportOptions) (io.ReadCloser, error) {
+	return c.export(ctx, export.KindAssignments, options)
+}
+
+func (c *Client) export(ctx context.Context, kind export.Kind, options ExportOptions) (io.ReadCloser, error) {
+	query := url.Values{}
+	set(query, "format", string(options.Format))
+	set(query, "columns", strings.Join(options.Columns, ","))
+	set(query, "filter", options.Filter)
+
+	response, err := c.send(ctx, request{method: http.MethodGet, path: "/export/" + string(kind), query: query})
+	if err != nil {
+		return nil, err
+	}
+
+	return response.Body, nil
+}
+
+// ReconcileHr сверить сотрудников с выгрузкой HR. Если удалений больше допустимого,
+// вместе с ErrConflict возвращается отчёт о расхождениях.
+func (c *Client) ReconcileHr(ctx context.Context, snapshot io.Reader, options HrOptions) (HrReport, error) {
+	query := url.Values{}
+	set(query, "format", string(options.Format))
+	set(query, "key", options.Key)
+	if options.Apply {
+		query.Set("apply", "true")
+	}
+	if options.MaxDeletes != 0 {
+		query.Set("max_deletes", strconv.Itoa(options.MaxDeletes))
+	}
+	if options.Force {
+		query.Set("force", "true")
+	}
+	for field, column := range options.Mapping {
+		query.Set("map."+field, column)
+	}
+	contentType := "text/csv"
+	if options.Format == hrsync.FormatJSON {
+		contentType = "application/json"
+	}
+
+	var report HrReport
+	err := c.do(ctx, request{method: http.MethodPost, path: "/admin/hr/reconcile", query: query, upload: snapshot,
+		contentType: contentType, detail: &struct {
+			Report *HrReport `json:"report"`
+		}{&report}}, &report)
+	return report, err
+}
+
+// SyncLdap синхронизировать каталог LDAP. При ошибке возвращаются изменения, внесённые до неё.
+func (c *Client) SyncLdap(ctx context.Context, options LdapOptions) (LdapDiff, error) {
+	query := url.Values{}
+	if options.DryRun {
+		query.Set("dry_run", "true")
+	}
+	if options.Incremental {
+		query.Set("incremental", "true")
+	}
+
+	var diff LdapDiff
+	err := c.do(ctx, request{method: http.MethodPost, path: "/admin/ldap/sync", query: query, detail: &struct {
+		Diff *LdapDiff `json:"diff"`
+	}{&diff}}, &diff)
+	return diff, err
+}
+
+func (c *Client) ListConnectors(ctx context.Context) ([]Connector, error) {
+	var connectors []Connector
+	err := c.do(ctx, request{method: http.MethodGet, path: "/admin/provisioning/"}, &connectors)
+	return connectors, err
+}
+
+// ReconcileConnector сверить целевую систему коннектора name. При ошибке возвращается
+// отчёт об изменениях, внесённых до неё.
+func (c *Client) ReconcileConnector(ctx context.Context, name string, dryRun bool) (ProvisioningReport, error) {
+	query := url.Values{}
+	if dryRun {
+		query.Set("dry_run", "true")
+	}
+
+	var report ProvisioningReport
+	err := c.do(ctx, request{method: http.MethodPost, path: "/admin/provisioning/" + url.PathEscape(name) + "/reconcile",
+		query: query, detail: &struct {
+			Report *ProvisioningReport `json:"report"`
+		}{&report}}, &report)
+	return report, err
+}
+
+func (c *Client) ListDepartments(ctx context.Context) ([]Department, error) {
+	var departments []Department
+	err := c.do(ctx, request{method: http.MethodGet, path: "/org/departments"}, &departments)
+	return departments, err
+}
+
+func (c *Client) CreateDepartment(ctx context.Context, department DepartmentRequest) (Department, error) {
+	var created Department
+	err := c.do(ctx, request{method: http.MethodPost, path: "/org/departments", body: department}, &created)
+	return created, err
+}
+
+func (c *Client) GetDepartment(ctx context.Context, id int64) (Department, error) {
+	var department Department
+	err := c.do(ctx, request{method: http.MethodGet, path: "/org/departments/" + format(id)}, &department)
+	return department, err
+}
+
+// UpdateDepartment переименовать, перенести или сменить руководителя; ErrConflict – если
+// подразделение переносится в собственное поддерево или имя занято
+func (c *Client) UpdateDepartment(ctx context.Context, id int64, department DepartmentRequest) (Department, error) {
+	var updated Department
+	err := c.do(ctx, request{method: http.MethodPut, path: "/org/departments/" + format(id), body: department}, &updated)
+	return updated, err
+}
+
+func (c *Client) RemoveDepartment(ctx context.Context, id int64) error {
+	return c.do(ctx, request{method: http.MethodDelete, path: "/org/departments/" + format(id)}, nil)
+}
+
+func (c *Client) ListDepartmentMembers(ctx context.Context, id int64, recursive bool) ([]OrgMember, error) {
+	query := url.Values{}
+	if recursive {
+		query.Set("recursive", "true")
+	}
+
+	var members []OrgMember
+	err := c.do(ctx, request{method: http.MethodGet, path: "/org/departments/" + format(id) + "/members", query: query}, &members)
+	return members, err
+}
+
+func (c *Client) AssignDepartmentMembers(ctx context.Context, id int64, employeeIds []int64) error {
+	return c.do(ctx, request{method: http.MethodPut, path: "/org/departments/" + format(id) + "/members",
+		body: org.MembersRequest{EmployeeIds: employeeIds}}, nil)
+}
+
+func (c *Client) RemoveDepartmentMember(ctx context.Context, id int64, employeeId int64) error {
+	return c.do(ctx, request{method: http.MethodDelete,
+		path: "/org/departments/" + format(id) + "/members/" + format(employeeId)}, nil)
+}
+
+func (c *Client) ListHeadcounts(ctx context.Context) ([]Headcount, error) {
+	var headcounts []Headcount
+	err := c.do(ctx, request{method: http.MethodGet, path: "/org/headcounts"}, &headcounts)
+	return headcounts, err
+}
+
+func (c *Client) ListEmployeeReports(ctx context.Context, employeeId int64, direct bool) ([]OrgMember, error) {
+	query := url.Values{}
+	if direct {
+		query.Set("direct", "true")
+	}
+
+	var reports []OrgMember
+	err := c.do(ctx, request{method: http.MethodGet, path: "/org/employees/" + format(employeeId) + "/reports", query: query}, &reports)
+	return reports, err
+}
+
+func (c *Client) GetEmployeeChain(ctx context.Context, employeeId int64) ([]OrgMember, error) {
+	var chain []OrgMember
+	err := c.do(ctx, request{method: http.MethodGet, path: "/org/employees/" + format(employeeId) + "/chain"}, &chain)
+	return chain, err
+}
+
+func (c *Client) GetOrgChart(ctx context.Context, options ChartOptions) (OrgChart, error) {
+	var chart OrgChart
+	err := c.do(ctx, request{method: http.MethodGet, path: "/org/chart", query: chartQuery(options, "json")}, &chart)
+	return chart, err
+}
+
+// GetOrgChartDOT схема оргструктуры в формате Graphviz DOT
+func (c *Client) GetOrgChartDOT(ctx context.Context, options ChartOptions) (string, error) {
+	response, err := c.send(ctx, request{method: http.MethodGet, path: "/org/chart", query: chartQuery(options, "dot")})
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = response.Body.Close() }()
+
+	graph, err := io.ReadAll(response.Body)
+	if err != nil {
+		return "", fmt.Errorf("idm: error reading org chart: %w", err)
+	}
+
+	return string(graph), nil
+}
+
+func chartQuery(options ChartOptions, chartFormat string) url.Values {
+	query := url.Values{"format": {chartFormat}}
+	if options.RootId != nil {
+		query.Set("root", format(*options.RootId))
+	}
+	if options.Members {
+		query.Set("members", "true")
+	}
+
+	return query
+}
+
+func (c *Client) ListGroups(ctx context.Context) ([]Group, error) {
+	var groups []Group
+	err := c.do(ctx, request{method: http.MethodGet, path: "/groups/"}, &groups)
+	return groups, err
+}
+
+// ListEmployeeGroups группы, в которые сотрудник входит явно, по правилу или через вложенные группы
+func (c *Client) ListEmployeeGroups(ctx context.Context, employeeId int64) ([]Group, error) {
+	var groups []Group
+	err := c.do(ctx, request{method: http.MethodGet, path: "/groups/",
+		query: url.Values{"employee_id": {format(employeeId)}}}, &groups)
+	return groups, err
+}
+
+func (c *Client) CreateGroup(ctx context.Context, group GroupRequest) (Group, error) {
+	var created Group
+	err := c.do(ctx, request{method: http.MethodPost, path: "/groups/", body: group}, &created)
+	return created, err
+}
+
+// EvaluateGroups пересчитать унаследованные от групп роли; возвращает сотрудников, у которых они изменились
+func (c *Client) EvaluateGroups(ctx context.Context) ([]GroupRoleDiff, error) {
+	var diffs []GroupRoleDiff
+	err := c.do(ctx, request{method: http.MethodPost, path: "/groups/evaluate"}, &diffs)
+	return diffs, err
+}
+
+func (c *Client) GetGroup(ctx context.Context, id int64) (Group, error) {
+	var group Group
+	err := c.do(ctx, request{method: http.MethodGet, path: "/groups/" + format(id)}, &group)
+	return group, err
+}
+
+func (c *Client) UpdateGroup(ctx context.Context, id int64, group GroupRequest) (Group, error) {
+	var updated Group
+	err := c.do(ctx, request{method: http.MethodPut, path: "/groups/" + format(id), body: group}, &updated)
+	return updated, err
+}
+
+func (c *Client) RemoveGroup(ctx context.Context, id int64) error {
+	return c.do(ctx, request{method: http.MethodDelete, path: "/groups/" + format(id)}, nil)
+}
+
+func (c *Client) ListGroupMembers(ctx context.Context, id int64) ([]GroupMember, error) {
+	var members []GroupMember
+	err := c.do(ctx, request{method: http.MethodGet, path: "/groups/" + format(id) + "/members"}, &members)
+	return members, err
+}
+
+func (c *Client) AddGroupMember(ctx context.Context, id int64, employeeId int64) error {
+	return c.do(ctx, request{method: http.MethodPut, path: "/groups/" + format(id) + "/members/" + format(employeeId)}, nil)
+}
+
+func (c *Client) RemoveGroupMember(ctx context.Context, id int64, employeeId int64) error {
+	return c.do(ctx, request{method: http.MethodDelete, path: "/groups/" + format(id) + "/members/" + format(employeeId)}, nil)
+}
+
+// AddSubgroup вложить группу subgroupId в группу id; ErrConflict – если получился бы цикл
+func (c *Client) AddSubgroup(ctx context.Context, id int64, subgroupId int64) error {
+	return c.do(ctx, request{method: http.MethodPut, path: "/groups/" + format(id) + "/subgroups/" + format(subgroupId)}, nil)
+}
+
+func (c *Client) RemoveSubgroup(ctx context.Context, id int64, subgroupId int64) error {
+	return c.do(ctx, request{method: http.MethodDelete, path: "/groups/" + format(id) + "/subgroups/" + format(subgroupId)}, nil)
+}
+
+func (c *Client) GrantGroupRole(ctx context.Context, id int64, roleId int64) error {
+	return c.do(ctx, request{method: http.MethodPut, path: "/groups/" + format(id) + "/roles/" + format(roleId)}, nil)
+}
+
+func (c *Client) RevokeGroupRole(ctx context.Context, id int64, roleId int64) error {
+	return c.do(ctx, request{method: http.MethodDelete, path: "/groups/" + format(id) + "/roles/" + format(roleId)}, nil)
+}
+
+func (c *Client) ListWebhooks(ctx context.Context) ([]Webhook, error) {
+	var subscriptions []Webhook
+	err := c.do(ctx, request{method: http.MethodGet, path: "/webhooks/"}, &subscriptions)
+	return subscriptions, err
+}
+
+// CreateWebhook подписаться на события; секрет подписи есть только в этом ответе
+func (c *Client) CreateWebhook(ctx context.Context, subscription WebhookRequest) (WebhookWithSecret, error) {
+	var created WebhookWithSecret
+	err := c.do(ctx, request{method: http.MethodPost, path: "/webhooks/", body: subscription}, &created)
+	return created, err
+}
+
+func (c *Client) GetWebhook(ctx context.Context, id int64) (Webhook, error) {
+	var subscription Webhook
+	err := c.do(ctx, request{method: http.MethodGet, path: "/webhooks/" + format(id)}, &subscription)
+	return subscription, err
+}
+
+func (c *Client) UpdateWebhook(ctx context.Context, id int64, subscription WebhookRequest) (Webhook, error) {
+	var updated Webhook
+	err := c.do(ctx, request{method: http.MethodPut, path: "/webhooks/" + format(id), body: subscription}, &updated)
+	return updated, err
+}
+
+func (c *Client) RemoveWebhook(ctx context.Context, id int64) error {
+	return c.do(ctx, request{method: http.MethodDelete, path: "/webhooks/" + format(id)}, nil)
+}
+
+func (c *Client) RotateWebhookSecret(ctx context.Context, id int64) (WebhookWithSecret, error) {
+	var rotated WebhookWithSecret
+	err := c.do(ctx, request{method: http.MethodPost, path: "/webhooks/" + format(id) + "/secret"}, &rotated)
+	return rotated, err
+}
+
+func (c *Client) ListWebhookDeliveries(ctx context.Context, id int64, filter DeliveryQuery) ([]Delivery, error) {
+	query := url.Values{}
+	set(query, "status", filter.Status)
+	if filter.BeforeId > 0 {
+		query.Set("before", format(filter.BeforeId))
+	}
+	if filter.Limit > 0 {
+		query.Set("limit", strconv.Itoa(filter.Limit))
+	}
+
+	var deliveries []Delivery
+	err := c.do(ctx, request{method: http.MethodGet, path: "/webhooks/" + format(id) + "/deliveries", query: query}, &deliveries)
+	return deliveries, err
+}
+
+func (c *Client) RedeliverWebhookDelivery(ctx context.Context, id int64, deliveryId int64) (Delivery, error) {
+	var delivery Delivery
+	err := c.do(ctx, request{method: http.MethodPost,
+		path: "/webhooks/" + format(id) + "/deliveries/" + format(deliveryId) + "/redeliver"}, &delivery)
+	return delivery, err
+}
+
+// GraphQL выполнить запрос GraphQL и раскодировать data в out. Ошибки выполнения
+// возвращаются как *GraphQLError; data, полученная вместе с ними, всё равно раскодируется.
+func (c *Client) GraphQL(ctx context.Context, query string, variables map[string]any, out any) error {
+	var response struct {
+		Data   json.RawMessage `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	err := c.do(ctx, request{method: http.MethodPost, path: "/graphql", detail: &response,
+		body: map[string]any{"query": query, "variables": variables}}, &response)
+	var failure *Error
+	if err != nil && !(errors.As(err, &failure) && len(response.Errors) > 0) {
+		return err
+	}
+
+	if out != nil && len(response.Data) > 0 && string(response.Data) != "null" {
+		if err := json.Unmarshal(response.Data, out); err != nil {
+			return fmt.Errorf("idm: error decoding graphql data: %w", err)
+		}
+	}
+	if len(response.Errors) > 0 {
+		messages := make([]string, 0, len(response.Errors))
+		for _, e := range response.Errors {
+			messages = append(messages, e.Message)
+		}
+		return &GraphQLError{Messages: messages}
+	}
+
+	return nil
+}
+
+// GraphQLSchema схема GraphQL на SDL
+func (c *Client) GraphQLSchema(ctx context.Context) (string, error) {
+	response, err := c.send(ctx, request{method: http.MethodGet, path: "/graphql"})
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = response.Body.Close() }()
+
+	schema, err := io.ReadAll(response.Body)
+	if err != nil {
+		return "", fmt.Errorf("idm: error reading graphql schema: %w", err)
+	}
+
+	return string(schema), nil
+}
+
+func (c *Client) GetScimServiceProviderConfig(ctx context.Context) (map[string]any, error) {
+	var config map[string]any
+	err := c.do(ctx, scimRequest(http.MethodGet, "/ServiceProviderConfig", nil), &config)
+	return config, err
+}
+
+func (c *Client) ListScimSchemas(ctx context.Context) (map[string]any, error) {
+	var schemas map[string]any
+	err := c.do(ctx, scimRequest(http.MethodGet, "/Schemas", nil), &schemas)
+	return schemas, err
+}
+
+func (c *Client) ListScimResourceTypes(ctx context.Context) (map[string]any, error) {
+	var resourceTypes map[string]any
+	err := c.do(ctx, scimRequest(http.MethodGet, "/ResourceTypes", nil), &resourceTypes)
+	return resourceTypes, err
+}
+
+func (c *Client) ListScimUsers(ctx context.Context, query ScimQuery) (EmployeeList, error) {
+	var page EmployeeList
+	r := scimRequest(http.MethodGet, "/Users", nil)
+	r.query = query.values()
+	err := c.do(ctx, r, &page)
+	return page, err
+}
+
+// CreateScimUser создать сотрудника; ErrConflict – если userName уже занят
+func (c *Client) CreateScimUser(ctx context.Context, user Employee) (Employee, error) {
+	var created Employee
+	err := c.do(ctx, scimRequest(http.MethodPost, "/Users", withSchemas(user, scim.SchemaUser)), &created)
+	return created, err
+}
+
+func (c *Client) GetScimUser(ctx context.Context, id int64) (Employee, error) {
+	var user Employee
+	err := c.do(ctx, scimRequest(http.MethodGet, "/Users/"+format(id), nil), &user)
+	return user, err
+}
+
+func (c *Client) ReplaceScimUser(ctx context.Context, id int64, user Employee) (Employee, error) {
+	var replaced Employee
+	err := c.do(ctx, scimRequest(http.MethodPut, "/Users/"+format(id), withSchemas(user, scim.SchemaUser)), &replaced)
+	return replaced, err
+}
+
+func (c *Client) PatchScimUser(ctx context.Context, id int64, operations ...ScimPatchOperation) (Employee, error) {
+	var patched Employee
+	err := c.do(ctx, scimRequest(http.MethodPatch, "/Users/"+format(id), patchRequest(operations)), &patched)
+	return patched, err
+}
+
+func (c *Client) DeleteScimUser(ctx context.Context, id int64) error {
+	return c.do(ctx, scimRequest(http.MethodDelete, "/Users/"+format(id), nil), nil)
+}
+
+func (c *Client) ListScimGroups(ctx context.Context, query ScimQuery) (RoleList, error) {
+	var page RoleList
+	r := scimRequest(http.MethodGet, "/Groups", nil)
+	r.query = query.values()
+	err := c.do(ctx, r, &page)
+	return page, err
+}
+
+func (c *Client) CreateScimGroup(ctx context.Context, group Role) (Role, error) {
+	var created Role
+	err := c.do(ctx, scimRequest(http.MethodPost, "/Groups", withSchemas(group, scim.SchemaGroup)), &created)
+	return created, err
+}
+
+func (c *Client) GetScimGroup(ctx context.Context, id int64) (Role, error) {
+	var group Role
+	err := c.do(ctx, scimRequest(http.MethodGet, "/Groups/"+format(id), nil), &group)
+	return group, err
+}
+
+func (c *Client) ReplaceScimGroup(ctx context.Context, id int64, group Role) (Role, error) {
+	var replaced Role
+	err := c.do(ctx, scimRequest(http.MethodPut, "/Groups/"+format(id), withSchemas(group, scim.SchemaGroup)), &replaced)
+	return replaced, err
+}
+
+func (c *Client) PatchScimGroup(ctx context.Context, id int64, operations ...ScimPatchOperation) (Role, error) {
+	var patched Role
+	err := c.do(ctx, scimRequest(http.MethodPatch, "/Groups/"+format(id), patchRequest(operations)), &patched)
+	return patched, err
+}
+
+func (c *Client) DeleteScimGroup(ctx context.Context, id int64) error {
+	return c.do(ctx, scimRequest(http.MethodDelete, "/Groups/"+format(id), nil), nil)
+}
+
+// ScimBulk выполнить несколько операций SCIM; итог каждой – в её status и response
+func (c *Client) ScimBulk(ctx context.Context, bulk ScimBulkRequest) (ScimBulkResponse, error) {
+	if len(bulk.Schemas) == 0 {
+		bulk.Schemas = []string{scim.SchemaBulkRequest}
+	}
+
+	var response ScimBulkResponse
+	err := c.do(ctx, scimRequest(http.MethodPost, "/Bulk", bulk), &response)
+	return response, err
+}
+
+// scimRequest запрос к SCIM; путь задаётся относительно /scim/v2
+func scimRequest(method string, path string, body any) request {
+	return request{method: method, path: "/scim/v2" + path, body: body, contentType: scim.ContentType}
+}
+
+func (q ScimQuery) values() url.Values {
+	query := url.Values{}
+	set(query, "filter", q.Filter)
+	if q.StartIndex > 0 {
+		query.Set("startIndex", strconv.Itoa(q.StartIndex))
+	}
+	if q.Count > 0 {
+		query.Set("count", strconv.Itoa(q.Count))
+	}
+	set(query, "attributes", strings.Join(q.Attributes, ","))
+	set(query, "excludedAttributes", strings.Join(q.ExcludedAttributes, ","))
+
+	return query
+}
+
+// withSchemas указать схему ресурса, если вызывающий её не задал
+func withSchemas[T Employee | Role](resource T, schema string) T {
+	switch r := any(&resource).(type) {
+	case *Employee:
+		if len(r.Schemas) == 0 {
+			r.Schemas = []string{schema}
+		}
+	case *Role:
+		if len(r.Schemas) == 0 {
+			r.Schemas = []string{schema}
+		}
+	}
+
+	return resource
+}
+
+func patchRequest(operations []ScimPatchOperation) scim.PatchRequest {
+	return scim.PatchRequest{Schemas: []string{scim.SchemaPatchOp}, Operations: operations}
+}
+
+// AdminGetAccessRequest любая заявка на роль вместе с историей
+func (c *Client) AdminGetAccessRequest(ctx context.Context, id int64) (AccessRequest, error) {
+	var found AccessRequest
+	err := c.do(ctx, request{method: http.MethodGet, path: "/admin/access-requests/" + format(id)}, &found)
+	return found, err
+}
+
+// RetryAccessRequest повторить выдачу роли по согласованной заявке, если она не удалась
+func (c *Client) RetryAccessRequest(ctx context.Context, id int64) (AccessRequest, error) {
+	var retried AccessRequest
+	err := c.do(ctx, request{method: http.MethodPost, path: "/admin/access-requests/" + format(id) + "/retry"}, &retried)
+	return retried, err
+}
+
+func (c *Client) ListCampaigns(ctx context.Context) ([]Campaign, error) {
+	var campaigns []Campaign
+	err := c.do(ctx, request{method: http.MethodGet, path: "/admin/certifications/"}, &campaigns)
+	return campaigns, err
+}
+
+func (c *Client) CreateCampaign(ctx context.Context, campaign CampaignRequest) (Campaign, error) {
+	var created Campaign
+	err := c.do(ctx, request{method: http.MethodPost, path: "/admin/certifications/", body: campaign}, &created)
+	return created, err
+}
+
+func (c *Client) GetCampaign(ctx context.Context, id int64) (Campaign, error) {
+	var campaign Campaign
+	err := c.do(ctx, request{method: http.MethodGet, path: "/admin/certifications/" + format(id)}, &campaign)
+	return campaign, err
+}
+
+// CloseCampaign закрыть кампанию досрочно; роли по элементам без решения отзываются
+func (c *Client) CloseCampaign(ctx context.Context, id int64) (Campaign, error) {
+	var closed Campaign
+	err := c.do(ctx, request{method: http.MethodPost, path: "/admin/certifications/" + format(id) + "/close"}, &closed)
+	return closed, err
+}
+
+// ExportCampaign решения кампании в CSV или JSON. Поток закрывает вызывающий.
+func (c *Client) ExportCampaign(ctx context.Context, id int64, exportFormat CampaignFormat) (io.ReadCloser, error) {
+	query := url.Values{}
+	set(query, "format", string(exportFormat))
+
+	response, err := c.send(ctx, request{method: http.MethodGet, path: "/admin/certifications/" + format(id) + "/export",
+		query: query})
+	if err != nil {
+		return nil, err
+	}
+
+	return response.Body, nil
+}
+
+// HireEmployee принять сотрудника сразу или с даты EffectiveAt
+func (c *Client) HireEmployee(ctx context.Context, hire HireChange) (LifecycleChange, error) {
+	var change LifecycleChange
+	err := c.do(ctx, request{method: http.MethodPost, path: "/admin/lifecycle/hire", body: hire}, &change)
+	return change, err
+}
+
+func (c *Client) ListEmployeeChanges(ctx context.Context, employeeId int64) ([]LifecycleChange, error) {
+	var changes []LifecycleChange
+	err := c.do(ctx, request{method: http.MethodGet, path: "/admin/lifecycle/employees/" + format(employeeId)}, &changes)
+	return changes, err
+}
+
+func (c *Client) TransferEmployee(ctx context.Context, employeeId int64, transfer TransferChange) (LifecycleChange, error) {
+	var change LifecycleChange
+	err := c.do(ctx, request{method: http.MethodPost, path: "/admin/lifecycle/employees/" + format(employeeId) + "/transfer",
+		body: transfer}, &change)
+	return change, err
+}
+
+// TerminateEmployee уволить сотрудника; нулевой effectiveAt – сразу
+func (c *Client) TerminateEmployee(ctx context.Context, employeeId int64, effectiveAt time.Time) (LifecycleChange, error) {
+	var change LifecycleChange
+	err := c.do(ctx, request{method: http.MethodPost, path: "/admin/lifecycle/employees/" + format(employeeId) + "/terminate",
+		body: lifecycle.TerminateChange{EffectiveAt: effectiveAt}}, &change)
+	return change, err
+}
+
+func (c *Client) GetLifecycleChange(ctx context.Context, id int64) (LifecycleChange, error) {
+	var change LifecycleChange
+	err := c.do(ctx, request{method: http.MethodGet, path: "/admin/lifecycle/" + format(id)}, &change)
+	return change, err
+}
+
+// CancelLifecycleChange отменить изменение, которое ещё не применено
+func (c *Client) CancelLifecycleChange(ctx context.Context, id int64) error {
+	return c.do(ctx, request{method: http.MethodPost, path: "/admin/lifecycle/" + format(id) + "/cancel"}, nil)
+}
+
+func (c *Client) ListSodRules(ctx context.Context) ([]SodRule, error) {
+	var rules []SodRule
+	err := c.do(ctx, request{method: http.MethodGet, path: "/admin/sod/rules"}, &rules)
+	return rules, err
+}
+
+// CreateSodRule создать правило разделения полномочий; без MaxRoles роли взаимоисключающие
+func (c *Client) CreateSodRule(ctx context.Context, rule SodRuleRequest) (SodRule, error) {
+	var created SodRule
+	err := c.do(ctx, request{method: http.MethodPost, path: "/admin/sod/rules", body: rule}, &created)
+	return created, err
+}
+
+func (c *Client) GetSodRule(ctx context.Context, id int64) (SodRule, error) {
+	var rule SodRule
+	err := c.do(ctx, request{method: http.MethodGet, path: "/admin/sod/rules/" + format(id)}, &rule)
+	return rule, err
+}
+
+func (c *Client) RemoveSodRule(ctx context.Context, id int64) error {
+	return c.do(ctx, request{method: http.MethodDelete, path: "/admin/sod/rules/" + format(id)}, nil)
+}
+
+// GrantSodException разрешить сотруднику нарушать правило до exception.ExpiresAt
+func (c *Client) GrantSodException(ctx context.Context, ruleId int64, exception SodExceptionRequest) (SodException, error) {
+	var granted SodException
+	err := c.do(ctx, request{method: http.MethodPost, path: "/admin/sod/rules/" + format(ruleId) + "/exceptions",
+		body: exception}, &granted)
+	return granted, err
+}
+
+// ListSodViolations сотрудники, нарушающие правила, включая покрытых исключениями
+func (c *Client) ListSodViolations(ctx context.Context) ([]SodViolation, error) {
+	var violations []SodViolation
+	err := c.do(ctx, request{method: http.MethodGet, path: "/admin/sod/violations"}, &violations)
+	return violations, err
+}
+
+func format(id int64) string {
+	return strconv.FormatInt(id, 10)
+}
+
+// set добавить параметр, если значение не пусто
+func set(query url.Values, name string, value string) {
+	if value != "" {
+		query.Set(name, value)
+	}
+}
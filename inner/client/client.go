@@ -0,0 +1,267 @@
+package client
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultRetries сколько раз повторяется запрос после временной ошибки
+	DefaultRetries = 3
+	// DefaultBackoff пауза перед первым повтором; каждая следующая вдвое длиннее
+	DefaultBackoff = 200 * time.Millisecond
+	// maxBackoff наибольшая пауза между повторами, в том числе по Retry-After
+	maxBackoff = 30 * time.Second
+)
+
+var (
+	ErrBadRequest   = errors.New("bad request")
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrForbidden    = errors.New("forbidden")
+	ErrNotFound     = errors.New("not found")
+	ErrConflict     = errors.New("conflict")
+	ErrRejected     = errors.New("rejected")
+	ErrServer       = errors.New("server error")
+)
+
+// Error ответ API с кодом ошибки. errors.Is сопоставляет его с ErrNotFound и другими
+// ошибками по коду ответа.
+type Error struct {
+	Method     string
+	Path       string
+	StatusCode int
+	// Message текст ошибки из ответа; пуст, если ответ не в формате API
+	Message string
+}
+
+func (e *Error) Error() string {
+	message := e.Message
+	if message == "" {
+		message = strings.ToLower(http.StatusText(e.StatusCode))
+	}
+
+	return fmt.Sprintf("idm: %s %s: %d %s", e.Method, e.Path, e.StatusCode, message)
+}
+
+func (e *Error) Unwrap() error {
+	switch {
+	case e.StatusCode == http.StatusBadRequest, e.StatusCode == http.StatusRequestEntityTooLarge:
+		return ErrBadRequest
+	case e.StatusCode == http.StatusUnauthorized:
+		return ErrUnauthorized
+	case e.StatusCode == http.StatusForbidden:
+		return ErrForbidden
+	case e.StatusCode == http.StatusNotFound:
+		return ErrNotFound
+	case e.StatusCode == http.StatusConflict:
+		return ErrConflict
+	case e.StatusCode == http.StatusUnprocessableEntity:
+		return ErrRejected
+	case e.StatusCode >= http.StatusInternalServerError:
+		return ErrServer
+	}
+
+	return nil
+}
+
+// Client клиент HTTP API IDM с ключом служебной учётной записи. Методы соответствуют
+// операциям openapi.Operations и принимают контекст, который ограничивает и повторы.
+// Операций без права ключа – входа, сеансов, заявок и пересмотра от имени сотрудника
+// и OpenID Connect – у клиента нет: их выполняет браузер сотрудника или приложение OIDC.
+type Client struct {
+	baseURL    string
+	apiKey     string
+	httpClient *http.Client
+	retries    int
+	backoff    time.Duration
+}
+
+func New(baseURL string, apiKey string) *Client {
+	return &Client{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		apiKey:     apiKey,
+		httpClient: http.DefaultClient,
+		retries:    DefaultRetries,
+		backoff:    DefaultBackoff,
+	}
+}
+
+// UseHTTPClient выполнять запросы через httpClient, например с собственным транспортом или тайм-аутом
+func (c *Client) UseHTTPClient(httpClient *http.Client) {
+	c.httpClient = httpClient
+}
+
+// SetRetries число повторов после временных ошибок и пауза перед первым из них; 0 – без повторов
+func (c *Client) SetRetries(retries int, backoff time.Duration) {
+	c.retries = retries
+	c.backoff = backoff
+}
+
+// request запрос к API. Тело – body в JSON или upload; contentType – тип тела, если это не application/json.
+type request struct {
+	method      string
+	path        string
+	query       url.Values
+	body        any
+	upload      io.Reader
+	contentType string
+	// detail куда раскодировать тело ответа с ошибкой, если оно несёт не только текст ошибки
+	detail any
+}
+
+// do выполнить запрос и раскодировать JSON ответа в out; nil – ответ без тела
+func (c *Client) do(ctx context.Context, r request, out any) error {
+	response, err := c.send(ctx, r)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = response.Body.Close() }()
+
+	if out == nil {
+		_, _ = io.Copy(io.Discard, response.Body)
+		return nil
+	}
+	if err := json.NewDecoder(response.Body).Decode(out); err != nil {
+		return fmt.Errorf("idm: error decoding response of %s %s: %w", r.method, r.path, err)
+	}
+
+	return nil
+}
+
+// send выполнить запрос и вернуть успешный ответ, тело которого закрывает вызывающий.
+// Временные ошибки повторяются: у GET, PUT и DELETE – сетевые ошибки и ответы 429, 502, 503
+// и 504, у POST, который мог уже выполниться, – только 429. Загрузка файла не повторяется.
+func (c *Client) send(ctx context.Context, r request) (*http.Response, error) {
+	var body []byte
+	if r.body != nil {
+		encoded, err := json.Marshal(r.body)
+		if err != nil {
+			return nil, fmt.Errorf("idm: error encoding request of %s %s: %w", r.method, r.path, err)
+		}
+		body = encoded
+	}
+	idempotent := r.method != http.MethodPost
+
+	for attempt := 0; ; attempt++ {
+		request, err := c.newRequest(ctx, r, body)
+		if err != nil {
+			return nil, err
+		}
+		response, err := c.httpClient.Do(request)
+		if err == nil && response.StatusCode < http.StatusBadRequest {
+			return response, nil
+		}
+
+		retry := attempt < c.retries && r.upload == nil
+		if err != nil {
+			if !retry || !idempotent || ctx.Err() != nil {
+				return nil, fmt.Errorf("idm: %s %s: %w", r.method, r.path, err)
+			}
+		} else {
+			switch response.StatusCode {
+			case http.StatusTooManyRequests:
+			case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+				retry = retry && idempotent
+			default:
+				retry = false
+			}
+			if !retry {
+				return nil, c.failure(r, response)
+			}
+		}
+
+		wait := c.wait(attempt, response)
+		if response != nil {
+			_, _ = io.Copy(io.Discard, response.Body)
+			_ = response.Body.Close()
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, fmt.Errorf("idm: %s %s: %w", r.method, r.path, ctx.Err())
+		case <-timer.C:
+		}
+	}
+}
+
+func (c *Client) newRequest(ctx context.Context, r request, body []byte) (*http.Request, error) {
+	target := c.baseURL + r.path
+	if len(r.query) > 0 {
+		target += "?" + r.query.Encode()
+	}
+
+	var reader io.Reader
+	switch {
+	case r.upload != nil:
+		reader = r.upload
+	case body != nil:
+		reader = bytes.NewReader(body)
+	}
+	request, err := http.NewRequestWithContext(ctx, r.method, target, reader)
+	if err != nil {
+		return nil, fmt.Errorf("idm: error creating request %s %s: %w", r.method, r.path, err)
+	}
+	request.Header.Set("Authorization", "Bearer "+c.apiKey)
+	switch {
+	case r.upload != nil, body != nil && r.contentType != "":
+		request.Header.Set("Content-Type", r.contentType)
+	case body != nil:
+		request.Header.Set("Content-Type", "application/json")
+	}
+
+	return request, nil
+}
+
+// failure ошибка по ответу; тело, кроме текста ошибки, раскодируется в r.detail
+func (c *Client) failure(r request, response *http.Response) error {
+	defer func() { _ = response.Body.Close() }()
+
+	failure := &Error{Method: r.method, Path: r.path, StatusCode: response.StatusCode}
+	data, err := io.ReadAll(io.LimitReader(response.Body, 1<<20))
+	if err != nil {
+		return failure
+	}
+	// ошибки SCIM передают текст в detail
+	var body struct {
+		Error  string `json:"error"`
+		Detail string `json:"detail"`
+	}
+	if json.Unmarshal(data, &body) == nil {
+		failure.Message = cmp.Or(body.Error, body.Detail)
+	}
+	if r.detail != nil {
+		_ = json.Unmarshal(data, r.detail)
+	}
+
+	return failure
+}
+
+// wait пауза перед повтором attempt: Retry-After ответа или экспоненциальная со случайным разбросом
+func (c *Client) wait(attempt int, response *http.Response) time.Duration {
+	if response != nil {
+		if seconds, err := strconv.Atoi(response.Header.Get("Retry-After")); err == nil && seconds >= 0 {
+			return min(time.Duration(seconds)*time.Second, maxBackoff)
+		}
+	}
+	wait := maxBackoff
+	if attempt < 16 {
+		wait = min(c.backoff<<attempt, maxBackoff)
+	}
+	if wait <= 0 {
+		return 0
+	}
+
+	return wait/2 + rand.N(wait/2+1)
+}
@@ -0,0 +1,255 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"idm/inner/hrsync"
+	"idm/inner/openapi"
+	"idm/inner/scim"
+	"idm/inner/webhook"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	assertpackage "github.com/stretchr/testify/assert"
+)
+
+func serve(t *testing.T, handler http.HandlerFunc) *Client {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	c := New(server.URL+"/", "idm_secret")
+	c.SetRetries(2, time.Millisecond)
+	return c
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func TestClient(t *testing.T) {
+	var assert = assertpackage.New(t)
+	ctx := context.Background()
+
+	t.Run("should have a method for every operation of the document", func(t *testing.T) {
+		client := reflect.TypeFor[*Client]()
+		for _, operation := range openapi.Operations {
+			// операции без права ключа выполняет браузер сотрудника или приложение OIDC
+			if operation.Scope == "" && operation.Path != "/openapi.json" {
+				continue
+			}
+			name := strings.ToUpper(operation.Id[:1]) + operation.Id[1:]
+			method, ok := client.MethodByName(name)
+			if assert.True(ok, name) {
+				assert.Equal(reflect.TypeFor[context.Context](), method.Type.In(1), name)
+			}
+		}
+	})
+
+	t.Run("should send SCIM requests with the SCIM content type and read the detail of its errors", func(t *testing.T) {
+		var contentType string
+		var patch scim.PatchRequest
+		c := serve(t, func(w http.ResponseWriter, r *http.Request) {
+			contentType = r.Header.Get("Content-Type")
+			_ = json.NewDecoder(r.Body).Decode(&patch)
+			writeJSON(w, http.StatusConflict, map[string]string{"detail": "userName is already taken"})
+		})
+
+		_, err := c.PatchScimUser(ctx, 7, ScimPatchOperation{Op: "replace", Path: "active"})
+
+		assert.ErrorIs(err, ErrConflict)
+		assert.Contains(err.Error(), "userName is already taken")
+		assert.Equal(scim.ContentType, contentType)
+		assert.Equal([]string{scim.SchemaPatchOp}, patch.Schemas)
+		assert.Len(patch.Operations, 1)
+	})
+
+	t.Run("should send the key, path, query and body", func(t *testing.T) {
+		var got *http.Request
+		var body webhook.Request
+		c := serve(t, func(w http.ResponseWriter, r *http.Request) {
+			got = r
+			switch {
+			case r.Method == http.MethodPut:
+				_ = json.NewDecoder(r.Body).Decode(&body)
+				writeJSON(w, http.StatusOK, Webhook{Id: 7, Url: body.Url, EventTypes: body.EventTypes})
+			case strings.HasSuffix(r.URL.Path, "/deliveries"):
+				writeJSON(w, http.StatusOK, []Delivery{})
+			default:
+				writeJSON(w, http.StatusCreated, IssuedKey{Secret: "idm_new"})
+			}
+		})
+
+		updated, err := c.UpdateWebhook(ctx, 7, WebhookRequest{Url: "https://hooks.example.com", EventTypes: []string{"employee.created"}})
+
+		assert.Nil(err)
+		assert.Equal(int64(7), updated.Id)
+		assert.Equal([]string{"employee.created"}, updated.EventTypes)
+		assert.Equal(http.MethodPut, got.Method)
+		assert.Equal("/webhooks/7", got.URL.Path)
+		assert.Equal("Bearer idm_secret", got.Header.Get("Authorization"))
+		assert.Equal("application/json", got.Header.Get("Content-Type"))
+
+		_, err = c.ListWebhookDeliveries(ctx, 7, DeliveryQuery{Status: webhook.StatusDead, BeforeId: 40, Limit: 10})
+		assert.Nil(err)
+		assert.Equal("/webhooks/7/deliveries", got.URL.Path)
+		assert.Equal("before=40&limit=10&status=dead", got.URL.RawQuery)
+
+		rotated, err := c.RotateServiceAccountKey(ctx, 1, 2, DefaultRotationOverlap)
+		assert.Nil(err)
+		assert.Equal("idm_new", rotated.Secret)
+		assert.Equal("/service-accounts/1/keys/2/rotate", got.URL.Path)
+		assert.Equal("24h0m0s", got.URL.Query().Get("overlap"))
+	})
+
+	t.Run("should return typed errors", func(t *testing.T) {
+		c := serve(t, func(w http.ResponseWriter, r *http.Request) {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "record not found"})
+		})
+
+		_, err := c.GetServiceAccount(ctx, 42)
+
+		assert.True(errors.Is(err, ErrNotFound))
+		var failure *Error
+		assert.True(errors.As(err, &failure))
+		assert.Equal(http.StatusNotFound, failure.StatusCode)
+		assert.Equal("record not found", failure.Message)
+		assert.Equal("idm: GET /service-accounts/42: 404 record not found", err.Error())
+	})
+
+	t.Run("should return the report sent along with an error", func(t *testing.T) {
+		var contentType string
+		c := serve(t, func(w http.ResponseWriter, r *http.Request) {
+			contentType = r.Header.Get("Content-Type")
+			writeJSON(w, http.StatusConflict, map[string]any{"error": "too many deletes",
+				"report": hrsync.Report{Key: "user_name", Extra: []hrsync.Drift{{Key: "ivanov"}}}})
+		})
+
+		report, err := c.ReconcileHr(ctx, strings.NewReader(`[]`), HrOptions{Format: hrsync.FormatJSON, Apply: true})
+
+		assert.True(errors.Is(err, ErrConflict))
+		assert.Equal("application/json", contentType)
+		assert.Equal("ivanov", report.Extra[0].Key)
+	})
+
+	t.Run("should retry idempotent requests after temporary errors", func(t *testing.T) {
+		var calls atomic.Int32
+		c := serve(t, func(w http.ResponseWriter, r *http.Request) {
+			if calls.Add(1) < 3 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			writeJSON(w, http.StatusOK, []Webhook{{Id: 1}})
+		})
+
+		subscriptions, err := c.ListWebhooks(ctx)
+
+		assert.Nil(err)
+		assert.Len(subscriptions, 1)
+		assert.Equal(int32(3), calls.Load())
+
+		// все ответы 503: первая попытка и два повтора
+		calls.Store(-10)
+		_, err = c.ListWebhooks(ctx)
+		assert.True(errors.Is(err, ErrServer))
+		assert.Equal(int32(-7), calls.Load())
+	})
+
+	t.Run("should retry POST only when the request was throttled", func(t *testing.T) {
+		var calls, status atomic.Int32
+		status.Store(http.StatusBadGateway)
+		c := serve(t, func(w http.ResponseWriter, r *http.Request) {
+			calls.Add(1)
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(int(status.Load()))
+		})
+
+		_, err := c.CreateWebhook(ctx, WebhookRequest{Url: "https://hooks.example.com"})
+		assert.True(errors.Is(err, ErrServer))
+		assert.Equal(int32(1), calls.Load())
+
+		calls.Store(0)
+		status.Store(http.StatusTooManyRequests)
+		_, err = c.CreateWebhook(ctx, WebhookRequest{Url: "https://hooks.example.com"})
+		assert.NotNil(err)
+		assert.Equal(int32(3), calls.Load())
+	})
+
+	t.Run("should stop retrying when the context is done", func(t *testing.T) {
+		c := serve(t, func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		})
+		c.SetRetries(5, time.Hour)
+		ctx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+		defer cancel()
+
+		_, err := c.ListConnectors(ctx)
+
+		assert.True(errors.Is(err, context.DeadlineExceeded))
+	})
+
+	t.Run("should stream exports", func(t *testing.T) {
+		var query string
+		c := serve(t, func(w http.ResponseWriter, r *http.Request) {
+			query = r.URL.RawQuery
+			_, _ = io.WriteString(w, "id,user_name\n1,ivanov\n")
+		})
+
+		stream, err := c.ExportEmployees(ctx, ExportOptions{Columns: []string{"id", "user_name"}, Filter: `status eq "active"`})
+		assert.Nil(err)
+		data, _ := io.ReadAll(stream)
+		_ = stream.Close()
+
+		assert.Equal("id,user_name\n1,ivanov\n", string(data))
+		assert.Equal("columns=id%2Cuser_name&filter=status+eq+%22active%22", query)
+	})
+
+	t.Run("should request the org chart as json or dot", func(t *testing.T) {
+		var queries []string
+		c := serve(t, func(w http.ResponseWriter, r *http.Request) {
+			queries = append(queries, r.URL.RawQuery)
+			if r.URL.Query().Get("format") == "dot" {
+				_, _ = io.WriteString(w, "digraph org {\n}\n")
+				return
+			}
+			writeJSON(w, http.StatusOK, OrgChart{Departments: []*OrgNode{{Id: 3, Name: "Engineering"}}})
+		})
+		root := int64(3)
+
+		chart, err := c.GetOrgChart(ctx, ChartOptions{RootId: &root})
+		assert.Nil(err)
+		assert.Equal("Engineering", chart.Departments[0].Name)
+
+		graph, err := c.GetOrgChartDOT(ctx, ChartOptions{Members: true})
+		assert.Nil(err)
+		assert.Equal("digraph org {\n}\n", graph)
+		assert.Equal([]string{"format=json&root=3", "format=dot&members=true"}, queries)
+	})
+
+	t.Run("should decode graphql data and errors", func(t *testing.T) {
+		c := serve(t, func(w http.ResponseWriter, r *http.Request) {
+			writeJSON(w, http.StatusOK, map[string]any{
+				"data":   map[string]any{"employee": map[string]any{"name": "Ivan"}, "roles": nil},
+				"errors": []map[string]any{{"message": "api key lacks scope roles:read"}},
+			})
+		})
+
+		var data struct {
+			Employee struct{ Name string }
+		}
+		err := c.GraphQL(ctx, `{ employee(id: "1") { name } roles { totalCount } }`, nil, &data)
+
+		var failure *GraphQLError
+		assert.True(errors.As(err, &failure))
+		assert.Equal([]string{"api key lacks scope roles:read"}, failure.Messages)
+		assert.Equal("Ivan", data.Employee.Name)
+	})
+}
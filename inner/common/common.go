@@ -4,12 +4,49 @@ import (
 	"fmt"
 	"github.com/joho/godotenv"
 	"os"
+	"time"
 )
 
 // Config общая конфигурация всего приложения
 type Config struct {
 	DbDriverName string `validate:"required"`
 	Dsn          string `validate:"required"`
+	HttpAddr     string
+	// BaseURL внешний адрес сервиса, используется в ссылках на ресурсы
+	BaseURL string
+	// BreachedPasswordsFile список SHA-1 скомпрометированных паролей в формате HIBP; необязателен
+	BreachedPasswordsFile string
+	// SessionIdleTimeout и SessionAbsoluteTimeout тайм-ауты сеансов; 0 – значения по умолчанию
+	SessionIdleTimeout     time.Duration
+	SessionAbsoluteTimeout time.Duration
+	// SessionCacheTTL время хранения сеансов в памяти процесса; 0 – без кеша
+	SessionCacheTTL time.Duration
+	// LdapSyncConfig путь к JSON-конфигурации синхронизации с LDAP; пусто – синхронизация выключена
+	LdapSyncConfig string
+	// LdapBindPassword пароль привязки к каталогу; заменяет указанный в конфигурации
+	LdapBindPassword string
+	// LdapSyncInterval период автоматической инкрементальной синхронизации; 0 – только по запросу
+	LdapSyncInterval time.Duration
+	// LdapServerAddr адрес LDAP-сервера только для чтения; пусто – сервер выключен
+	LdapServerAddr string
+	// LdapBaseDN корень каталога, который видят LDAP-клиенты
+	LdapBaseDN string
+	// LdapTLSCert и LdapTLSKey сертификат для StartTLS; с ним привязка без TLS запрещена
+	LdapTLSCert string
+	LdapTLSKey  string
+	// OutboxPublishers куда ещё, кроме подписок на вебхуки, доставлять доменные события:
+	// stdout, file:…, webhook:…, notify:… через запятую
+	OutboxPublishers string
+	// ProvisioningConfig путь к JSON-конфигурации коннекторов к целевым системам; пусто – выключено
+	ProvisioningConfig string
+	// ProvisioningInterval период сверки коннекторов; 0 – только по запросу
+	ProvisioningInterval time.Duration
+	// GrpcAddr адрес gRPC-сервера; пусто – сервер выключен
+	GrpcAddr string
+	// JobsInterval период фоновых задач: эскалации заявок на доступ, повторной выдачи ролей,
+	// напоминаний ревьюерам, закрытия кампаний пересмотра с истёкшим сроком
+	// и применения запланированных приёмов, переводов и увольнений
+	JobsInterval time.Duration
 }
 
 // GetConfig получение конфигурации из .env файла или переменных окружения
@@ -26,8 +63,63 @@ func GetConfig(envFile string) Config {
 		os.Getenv("DB_NAME"),
 	)
 
+	httpAddr := os.Getenv("HTTP_ADDR")
+	if httpAddr == "" {
+		httpAddr = ":8080"
+	}
+	baseURL := os.Getenv("BASE_URL")
+	if baseURL == "" {
+		baseURL = "http://localhost" + httpAddr
+	}
+
+	jobsInterval := duration("JOBS_INTERVAL")
+	if jobsInterval <= 0 {
+		jobsInterval = time.Minute
+	}
+
+	ldapBaseDN := os.Getenv("LDAP_BASE_DN")
+	if ldapBaseDN == "" {
+		ldapBaseDN = "dc=idm,dc=local"
+	}
+
 	return Config{
 		DbDriverName: os.Getenv("DB_CONNECTION"),
 		Dsn:          dsn,
+		HttpAddr:     httpAddr,
+		BaseURL:      baseURL,
+
+		BreachedPasswordsFile: os.Getenv("BREACHED_PASSWORDS_FILE"),
+
+		SessionIdleTimeout:     duration("SESSION_IDLE_TIMEOUT"),
+		SessionAbsoluteTimeout: duration("SESSION_ABSOLUTE_TIMEOUT"),
+		SessionCacheTTL:        duration("SESSION_CACHE_TTL"),
+
+		LdapSyncConfig:   os.Getenv("LDAP_SYNC_CONFIG"),
+		LdapBindPassword: os.Getenv("LDAP_BIND_PASSWORD"),
+		LdapSyncInterval: duration("LDAP_SYNC_INTERVAL"),
+
+		LdapServerAddr: os.Getenv("LDAP_SERVER_ADDR"),
+		LdapBaseDN:     ldapBaseDN,
+		LdapTLSCert:    os.Getenv("LDAP_TLS_CERT"),
+		LdapTLSKey:     os.Getenv("LDAP_TLS_KEY"),
+
+		OutboxPublishers: os.Getenv("OUTBOX_PUBLISHERS"),
+
+		ProvisioningConfig:   os.Getenv("PROVISIONING_CONFIG"),
+		ProvisioningInterval: duration("PROVISIONING_INTERVAL"),
+
+		GrpcAddr: os.Getenv("GRPC_ADDR"),
+
+		JobsInterval: jobsInterval,
 	}
 }
+
+// duration значение переменной окружения в формате time.ParseDuration; пустое или неверное – 0
+func duration(name string) time.Duration {
+	value, err := time.ParseDuration(os.Getenv(name))
+	if err != nil {
+		return 0
+	}
+
+	return value
+}
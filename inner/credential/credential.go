@@ -0,0 +1,235 @@
+package credential
+
+import (
+	"errors"
+	"fmt"
+	"idm/inner/database"
+	"math"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+var (
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	ErrLocked             = errors.New("account is locked")
+	ErrPasswordTooShort   = errors.New("password is too short")
+	ErrPasswordTooLong    = errors.New("password is too long")
+	ErrPasswordBreached   = errors.New("password appears in a list of breached passwords")
+	ErrPasswordReused     = errors.New("password was used recently")
+)
+
+// Policy требования к паролям и блокировке
+type Policy struct {
+	MinLength int
+	MaxLength int
+	// HistorySize сколько последних паролей нельзя использовать повторно
+	HistorySize int
+	// MaxFailedAttempts после стольких неудачных попыток подряд учётная запись блокируется; 0 – не блокировать
+	MaxFailedAttempts int
+	// LockoutDuration через сколько блокировка снимается сама; 0 – только администратором
+	LockoutDuration time.Duration
+}
+
+var DefaultPolicy = Policy{
+	MinLength:         12,
+	MaxLength:         128,
+	HistorySize:       5,
+	MaxFailedAttempts: 5,
+}
+
+type Repo interface {
+	FindByEmployeeId(employeeId int64) (*Credential, error)
+	FindHistory(employeeId int64, limit int) ([]string, error)
+	Save(credential *Credential, historySize int) error
+	UpdateHash(employeeId int64, hash string) error
+	RecordFailure(employeeId int64, maxAttempts int, now time.Time) (*Credential, error)
+	Unlock(employeeId int64) error
+}
+
+type Service struct {
+	repo      Repo
+	params    Params
+	policy    Policy
+	breached  BreachedList
+	now       func() time.Time
+	dummyOnce sync.Once
+	dummyHash string
+}
+
+func NewService(repository Repo) *Service {
+	return &Service{repo: repository, params: DefaultParams, policy: DefaultPolicy, now: time.Now}
+}
+
+// SetParams изменить параметры argon2id. Существующие хеши пересчитываются при следующем успешном входе.
+func (s *Service) SetParams(params Params) {
+	s.params = params
+}
+
+func (s *Service) SetPolicy(policy Policy) {
+	s.policy = policy
+}
+
+// UseBreachedList проверять новые пароли по списку скомпрометированных
+func (s *Service) UseBreachedList(list BreachedList) {
+	s.breached = list
+}
+
+// Status состояние учётных данных сотрудника
+func (s *Service) Status(employeeId int64) (Response, error) {
+	credential, err := s.repo.FindByEmployeeId(employeeId)
+	if err != nil {
+		return Response{}, fmt.Errorf("error finding credential of employee with id %d: %w", employeeId, err)
+	}
+
+	return Response{
+		EmployeeId:     credential.EmployeeId,
+		FailedAttempts: credential.FailedAttempts,
+		Locked:         s.locked(credential),
+		LockedAt:       credential.LockedAt,
+		ChangedAt:      credential.ChangedAt,
+	}, nil
+}
+
+// SetPassword установить пароль, проверив его на соответствие политике
+func (s *Service) SetPassword(employeeId int64, password string) error {
+	if err := s.check(employeeId, password); err != nil {
+		return err
+	}
+
+	hash, err := s.params.Hash(password)
+	if err != nil {
+		return fmt.Errorf("error hashing password of employee with id %d: %w", employeeId, err)
+	}
+
+	err = s.repo.Save(&Credential{EmployeeId: employeeId, Hash: hash}, s.policy.HistorySize)
+	if err != nil {
+		return fmt.Errorf("error saving password of employee with id %d: %w", employeeId, err)
+	}
+
+	return nil
+}
+
+// ChangePassword сменить пароль по запросу самого сотрудника
+func (s *Service) ChangePassword(employeeId int64, current string, password string) error {
+	if err := s.Verify(employeeId, current); err != nil {
+		return err
+	}
+
+	return s.SetPassword(employeeId, password)
+}
+
+// Verify проверить пароль. Неудачные попытки учитываются для блокировки, а хеш,
+// вычисленный с устаревшими параметрами, пересчитывается.
+func (s *Service) Verify(employeeId int64, password string) error {
+	credential, err := s.repo.FindByEmployeeId(employeeId)
+	if err != nil {
+		if errors.Is(err, database.ErrRecordNotFound) {
+			// время ответа не должно выдавать, что пароль не задан
+			_, _, _ = compare(s.dummy(), password)
+			return ErrInvalidCredentials
+		}
+		return fmt.Errorf("error finding credential of employee with id %d: %w", employeeId, err)
+	}
+
+	if credential.LockedAt != nil {
+		if s.locked(credential) {
+			return ErrLocked
+		}
+		if err := s.repo.Unlock(employeeId); err != nil {
+			return fmt.Errorf("error unlocking employee with id %d: %w", employeeId, err)
+		}
+		credential.FailedAttempts = 0
+	}
+
+	ok, params, err := compare(credential.Hash, password)
+	if err != nil {
+		return fmt.Errorf("error verifying password of employee with id %d: %w", employeeId, err)
+	}
+
+	if !ok {
+		maxAttempts := s.policy.MaxFailedAttempts
+		if maxAttempts <= 0 {
+			maxAttempts = math.MaxInt32
+		}
+		updated, err := s.repo.RecordFailure(employeeId, maxAttempts, s.now())
+		if err != nil {
+			return fmt.Errorf("error recording failed attempt of employee with id %d: %w", employeeId, err)
+		}
+		if s.locked(updated) {
+			return ErrLocked
+		}
+		return ErrInvalidCredentials
+	}
+
+	if credential.FailedAttempts > 0 {
+		if err := s.repo.Unlock(employeeId); err != nil {
+			return fmt.Errorf("error resetting failed attempts of employee with id %d: %w", employeeId, err)
+		}
+	}
+
+	if params != s.params {
+		hash, err := s.params.Hash(password)
+		if err != nil {
+			return fmt.Errorf("error rehashing password of employee with id %d: %w", employeeId, err)
+		}
+		if err := s.repo.UpdateHash(employeeId, hash); err != nil {
+			return fmt.Errorf("error rehashing password of employee with id %d: %w", employeeId, err)
+		}
+	}
+
+	return nil
+}
+
+// Unlock снять блокировку учётной записи (операция администратора)
+func (s *Service) Unlock(employeeId int64) error {
+	if err := s.repo.Unlock(employeeId); err != nil {
+		return fmt.Errorf("error unlocking employee with id %d: %w", employeeId, err)
+	}
+
+	return nil
+}
+
+func (s *Service) check(employeeId int64, password string) error {
+	length := utf8.RuneCountInString(password)
+	if length < s.policy.MinLength {
+		return fmt.Errorf("%w: at least %d characters required", ErrPasswordTooShort, s.policy.MinLength)
+	}
+	if s.policy.MaxLength > 0 && length > s.policy.MaxLength {
+		return fmt.Errorf("%w: at most %d characters allowed", ErrPasswordTooLong, s.policy.MaxLength)
+	}
+	if s.breached != nil && s.breached.Contains(password) {
+		return ErrPasswordBreached
+	}
+
+	if s.policy.HistorySize > 0 {
+		history, err := s.repo.FindHistory(employeeId, s.policy.HistorySize)
+		if err != nil {
+			return fmt.Errorf("error finding password history of employee with id %d: %w", employeeId, err)
+		}
+		for _, hash := range history {
+			if ok, _, err := compare(hash, password); err == nil && ok {
+				return fmt.Errorf("%w: choose a password not used in the last %d changes",
+					ErrPasswordReused, s.policy.HistorySize)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (s *Service) locked(credential *Credential) bool {
+	if credential.LockedAt == nil {
+		return false
+	}
+
+	return s.policy.LockoutDuration == 0 || s.now().Before(credential.LockedAt.Add(s.policy.LockoutDuration))
+}
+
+func (s *Service) dummy() string {
+	s.dummyOnce.Do(func() {
+		s.dummyHash, _ = s.params.Hash("")
+	})
+
+	return s.dummyHash
+}
@@ -0,0 +1,217 @@
+package credential
+
+import (
+	"errors"
+	assertpackage "github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"idm/inner/database"
+	"strings"
+	"testing"
+	"time"
+)
+
+type MockRepo struct {
+	mock.Mock
+}
+
+func (m *MockRepo) FindByEmployeeId(employeeId int64) (*Credential, error) {
+	args := m.Called(employeeId)
+	return args.Get(0).(*Credential), args.Error(1)
+}
+
+func (m *MockRepo) FindHistory(employeeId int64, limit int) ([]string, error) {
+	args := m.Called(employeeId, limit)
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *MockRepo) Save(credential *Credential, historySize int) error {
+	args := m.Called(credential, historySize)
+	return args.Error(0)
+}
+
+func (m *MockRepo) UpdateHash(employeeId int64, hash string) error {
+	args := m.Called(employeeId, hash)
+	return args.Error(0)
+}
+
+func (m *MockRepo) RecordFailure(employeeId int64, maxAttempts int, now time.Time) (*Credential, error) {
+	args := m.Called(employeeId, maxAttempts, now)
+	return args.Get(0).(*Credential), args.Error(1)
+}
+
+func (m *MockRepo) Unlock(employeeId int64) error {
+	args := m.Called(employeeId)
+	return args.Error(0)
+}
+
+// testParams дешёвые параметры, чтобы тесты работали быстро
+var testParams = Params{Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: 8, KeyLength: 16}
+
+func newTestService(repo Repo) *Service {
+	service := NewService(repo)
+	service.SetParams(testParams)
+	return service
+}
+
+func TestParams(t *testing.T) {
+	assert := assertpackage.New(t)
+
+	t.Run("should hash and verify a password", func(t *testing.T) {
+		hash, err := testParams.Hash("correct horse battery staple")
+		assert.Nil(err)
+		assert.True(strings.HasPrefix(hash, "$argon2id$v=19$m=64,t=1,p=1$"))
+
+		ok, params, err := compare(hash, "correct horse battery staple")
+		assert.Nil(err)
+		assert.True(ok)
+		assert.Equal(testParams, params)
+
+		ok, _, err = compare(hash, "wrong")
+		assert.Nil(err)
+		assert.False(ok)
+
+		_, _, err = compare("$2a$10$bcrypt", "x")
+		assert.ErrorIs(err, ErrMalformedHash)
+	})
+
+	t.Run("should load a breached password list", func(t *testing.T) {
+		// SHA-1("password") = 5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8
+		list, err := LoadBreachedList(strings.NewReader("# pwned\n5baa61e4c9b93f3f0682250b6cf8331b7ee68fd8:3861493\n"))
+		assert.Nil(err)
+		assert.True(list.Contains("password"))
+		assert.False(list.Contains("Password"))
+
+		_, err = LoadBreachedList(strings.NewReader("not-a-hash\n"))
+		assert.NotNil(err)
+	})
+}
+
+func TestCredentialService(t *testing.T) {
+	assert := assertpackage.New(t)
+
+	t.Run("should set a password that satisfies the policy", func(t *testing.T) {
+		repo := new(MockRepo)
+		service := newTestService(repo)
+		repo.On("FindHistory", int64(1), 5).Return([]string{}, nil)
+		repo.On("Save", mock.MatchedBy(func(c *Credential) bool {
+			ok, _, _ := compare(c.Hash, "a long enough password")
+			return c.EmployeeId == 1 && ok
+		}), 5).Return(nil)
+
+		err := service.SetPassword(1, "a long enough password")
+
+		assert.Nil(err)
+		repo.AssertExpectations(t)
+	})
+
+	t.Run("should reject passwords violating the policy", func(t *testing.T) {
+		repo := new(MockRepo)
+		service := newTestService(repo)
+		list, _ := LoadBreachedList(strings.NewReader("E6B6AFBD6D76BB5D2041542D7D2E3FAC5BB05593\n"))
+		service.UseBreachedList(list)
+		previous, _ := testParams.Hash("my old password!")
+		repo.On("FindHistory", int64(1), 5).Return([]string{previous}, nil)
+
+		assert.ErrorIs(service.SetPassword(1, "short"), ErrPasswordTooShort)
+		assert.ErrorIs(service.SetPassword(1, strings.Repeat("x", 129)), ErrPasswordTooLong)
+		// SHA-1("password1234") = E6B6AFBD6D76BB5D2041542D7D2E3FAC5BB05593
+		assert.ErrorIs(service.SetPassword(1, "password1234"), ErrPasswordBreached)
+		assert.ErrorIs(service.SetPassword(1, "my old password!"), ErrPasswordReused)
+		repo.AssertNotCalled(t, "Save", mock.Anything, mock.Anything)
+	})
+
+	t.Run("should verify a password and reset failed attempts", func(t *testing.T) {
+		repo := new(MockRepo)
+		service := newTestService(repo)
+		hash, _ := testParams.Hash("a long enough password")
+		repo.On("FindByEmployeeId", int64(1)).Return(&Credential{EmployeeId: 1, Hash: hash, FailedAttempts: 2}, nil)
+		repo.On("Unlock", int64(1)).Return(nil)
+
+		err := service.Verify(1, "a long enough password")
+
+		assert.Nil(err)
+		repo.AssertCalled(t, "Unlock", int64(1))
+		repo.AssertNotCalled(t, "UpdateHash", mock.Anything, mock.Anything)
+	})
+
+	t.Run("should rehash when parameters change", func(t *testing.T) {
+		repo := new(MockRepo)
+		service := newTestService(repo)
+		hash, _ := testParams.Hash("a long enough password")
+		repo.On("FindByEmployeeId", int64(1)).Return(&Credential{EmployeeId: 1, Hash: hash}, nil)
+		repo.On("UpdateHash", int64(1), mock.MatchedBy(func(hash string) bool {
+			return strings.Contains(hash, "m=128,t=2,p=1")
+		})).Return(nil)
+
+		service.SetParams(Params{Memory: 128, Iterations: 2, Parallelism: 1, SaltLength: 8, KeyLength: 16})
+		err := service.Verify(1, "a long enough password")
+
+		assert.Nil(err)
+		repo.AssertExpectations(t)
+	})
+
+	t.Run("should record failures and lock the account", func(t *testing.T) {
+		repo := new(MockRepo)
+		service := newTestService(repo)
+		now := time.Now()
+		service.now = func() time.Time { return now }
+		hash, _ := testParams.Hash("a long enough password")
+		repo.On("FindByEmployeeId", int64(1)).Return(&Credential{EmployeeId: 1, Hash: hash, FailedAttempts: 3}, nil)
+		repo.On("RecordFailure", int64(1), 5, now).
+			Return(&Credential{EmployeeId: 1, Hash: hash, FailedAttempts: 4}, nil).Once()
+		repo.On("RecordFailure", int64(1), 5, now).
+			Return(&Credential{EmployeeId: 1, Hash: hash, FailedAttempts: 5, LockedAt: &now}, nil).Once()
+
+		assert.ErrorIs(service.Verify(1, "wrong"), ErrInvalidCredentials)
+		assert.ErrorIs(service.Verify(1, "wrong"), ErrLocked)
+	})
+
+	t.Run("should refuse a locked account until an admin unlocks it", func(t *testing.T) {
+		repo := new(MockRepo)
+		service := newTestService(repo)
+		hash, _ := testParams.Hash("a long enough password")
+		lockedAt := time.Now().Add(-24 * time.Hour)
+		repo.On("FindByEmployeeId", int64(1)).
+			Return(&Credential{EmployeeId: 1, Hash: hash, FailedAttempts: 5, LockedAt: &lockedAt}, nil)
+		repo.On("Unlock", int64(1)).Return(nil)
+
+		assert.ErrorIs(service.Verify(1, "a long enough password"), ErrLocked)
+		assert.Nil(service.Unlock(1))
+		repo.AssertNumberOfCalls(t, "Unlock", 1)
+	})
+
+	t.Run("should lift an expired lockout automatically", func(t *testing.T) {
+		repo := new(MockRepo)
+		service := newTestService(repo)
+		policy := DefaultPolicy
+		policy.LockoutDuration = 15 * time.Minute
+		service.SetPolicy(policy)
+		hash, _ := testParams.Hash("a long enough password")
+		lockedAt := time.Now().Add(-time.Hour)
+		repo.On("FindByEmployeeId", int64(1)).
+			Return(&Credential{EmployeeId: 1, Hash: hash, FailedAttempts: 5, LockedAt: &lockedAt}, nil)
+		repo.On("Unlock", int64(1)).Return(nil)
+
+		assert.Nil(service.Verify(1, "a long enough password"))
+	})
+
+	t.Run("should not reveal a missing credential", func(t *testing.T) {
+		repo := new(MockRepo)
+		service := newTestService(repo)
+		repo.On("FindByEmployeeId", int64(1)).Return((*Credential)(nil), database.ErrRecordNotFound)
+
+		assert.ErrorIs(service.Verify(1, "anything"), ErrInvalidCredentials)
+	})
+
+	t.Run("should return wrapped repository errors", func(t *testing.T) {
+		repo := new(MockRepo)
+		service := newTestService(repo)
+		repoErr := errors.New("database error")
+		repo.On("Unlock", int64(1)).Return(repoErr)
+
+		err := service.Unlock(1)
+
+		assert.ErrorIs(err, repoErr)
+		assert.Equal("error unlocking employee with id 1: database error", err.Error())
+	})
+}
@@ -0,0 +1,11 @@
+package credential
+
+import "time"
+
+type Response struct {
+	EmployeeId     int64      `json:"employee_id"`
+	FailedAttempts int        `json:"failed_attempts"`
+	Locked         bool       `json:"locked"`
+	LockedAt       *time.Time `json:"locked_at,omitempty"`
+	ChangedAt      time.Time  `json:"changed_at"`
+}
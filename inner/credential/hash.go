@@ -0,0 +1,114 @@
+package credential
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/argon2"
+	"io"
+	"strings"
+)
+
+var ErrMalformedHash = errors.New("malformed password hash")
+
+// Params параметры argon2id. Memory задаётся в KiB.
+type Params struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// DefaultParams рекомендованные OWASP параметры argon2id
+var DefaultParams = Params{
+	Memory:      64 * 1024,
+	Iterations:  3,
+	Parallelism: 2,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+// Hash вычислить хеш пароля в формате PHC: $argon2id$v=19$m=65536,t=3,p=2$<salt>$<key>
+func (p Params) Hash(password string) (string, error) {
+	salt := make([]byte, p.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, p.Iterations, p.Memory, p.Parallelism, p.KeyLength)
+
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, p.Memory, p.Iterations, p.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// compare сравнить пароль с хешем и вернуть параметры, с которыми хеш был вычислен
+func compare(encoded string, password string) (bool, Params, error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return false, Params{}, ErrMalformedHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return false, Params{}, ErrMalformedHash
+	}
+
+	var params Params
+	_, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism)
+	if err != nil {
+		return false, Params{}, ErrMalformedHash
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return false, Params{}, ErrMalformedHash
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return false, Params{}, ErrMalformedHash
+	}
+	params.SaltLength = uint32(len(salt))
+	params.KeyLength = uint32(len(key))
+
+	actual := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, params.KeyLength)
+
+	return subtle.ConstantTimeCompare(key, actual) == 1, params, nil
+}
+
+// BreachedList множество SHA-1 хешей скомпрометированных паролей
+type BreachedList map[string]struct{}
+
+// LoadBreachedList прочитать список в формате Have I Been Pwned: по одному SHA-1 хешу на строку,
+// за хешем может идти ":<число утечек>"
+func LoadBreachedList(reader io.Reader) (BreachedList, error) {
+	list := make(BreachedList)
+
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		sum, _, _ := strings.Cut(line, ":")
+		if len(sum) != sha1.Size*2 {
+			return nil, fmt.Errorf("invalid SHA-1 hash %q in breached password list", sum)
+		}
+		list[strings.ToUpper(sum)] = struct{}{}
+	}
+
+	return list, scanner.Err()
+}
+
+func (l BreachedList) Contains(password string) bool {
+	sum := sha1.Sum([]byte(password))
+	_, found := l[strings.ToUpper(hex.EncodeToString(sum[:]))]
+
+	return found
+}
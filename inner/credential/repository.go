@@ -0,0 +1,166 @@
+package credential
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/jmoiron/sqlx"
+	"idm/inner/database"
+	"time"
+)
+
+// Credential пароль сотрудника и состояние блокировки
+type Credential struct {
+	EmployeeId     int64      `db:"employee_id"`
+	Hash           string     `db:"hash"`
+	FailedAttempts int        `db:"failed_attempts"`
+	LockedAt       *time.Time `db:"locked_at"`
+	ChangedAt      time.Time  `db:"changed_at"`
+	CreatedAt      time.Time  `db:"created_at"`
+	UpdatedAt      time.Time  `db:"updated_at"`
+}
+
+type Repository struct {
+	db *sqlx.DB
+}
+
+func NewRepository(db *sqlx.DB) *Repository {
+	return &Repository{db: db}
+}
+
+func (r *Repository) FindByEmployeeId(employeeId int64) (*Credential, error) {
+	var credential Credential
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := r.db.GetContext(ctx, &credential, "SELECT * FROM credentials WHERE employee_id = $1", employeeId)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, database.ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &credential, err
+}
+
+// FindHistory хеши последних limit паролей сотрудника, от новых к старым
+func (r *Repository) FindHistory(employeeId int64, limit int) ([]string, error) {
+	var hashes []string
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := r.db.SelectContext(ctx, &hashes,
+		"SELECT hash FROM password_history WHERE employee_id = $1 ORDER BY created_at DESC, id DESC LIMIT $2",
+		employeeId, limit)
+
+	return hashes, err
+}
+
+// Save установить новый пароль: сбросить блокировку, добавить хеш в историю
+// и оставить в ней не больше historySize записей
+func (r *Repository) Save(credential *Credential, historySize int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	err = tx.QueryRowContext(ctx,
+		`INSERT INTO credentials (employee_id, hash) VALUES ($1, $2)
+		ON CONFLICT (employee_id) DO UPDATE
+		SET hash = EXCLUDED.hash, failed_attempts = 0, locked_at = NULL,
+			changed_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+		RETURNING failed_attempts, locked_at, changed_at, created_at, updated_at`,
+		credential.EmployeeId, credential.Hash,
+	).Scan(&credential.FailedAttempts, &credential.LockedAt, &credential.ChangedAt, &credential.CreatedAt,
+		&credential.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx,
+		"INSERT INTO password_history (employee_id, hash) VALUES ($1, $2)", credential.EmployeeId, credential.Hash)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`DELETE FROM password_history WHERE employee_id = $1 AND id NOT IN (
+			SELECT id FROM password_history WHERE employee_id = $1 ORDER BY created_at DESC, id DESC LIMIT $2
+		)`,
+		credential.EmployeeId, historySize)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// UpdateHash заменить хеш того же пароля, вычисленный с новыми параметрами
+func (r *Repository) UpdateHash(employeeId int64, hash string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := r.db.ExecContext(ctx,
+		"UPDATE credentials SET hash = $1, updated_at = CURRENT_TIMESTAMP WHERE employee_id = $2", hash, employeeId)
+
+	return err
+}
+
+// RecordFailure увеличить счётчик неудачных попыток и заблокировать учётную запись,
+// когда он достигнет maxAttempts
+func (r *Repository) RecordFailure(employeeId int64, maxAttempts int, now time.Time) (*Credential, error) {
+	var credential Credential
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := r.db.GetContext(ctx, &credential,
+		`UPDATE credentials
+		SET failed_attempts = failed_attempts + 1,
+			locked_at = CASE WHEN failed_attempts + 1 >= $2 THEN COALESCE(locked_at, $3) ELSE locked_at END,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE employee_id = $1 RETURNING *`,
+		employeeId, maxAttempts, now)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, database.ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &credential, err
+}
+
+// Unlock сбросить счётчик неудачных попыток и снять блокировку
+func (r *Repository) Unlock(employeeId int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := r.db.ExecContext(ctx,
+		`UPDATE credentials SET failed_attempts = 0, locked_at = NULL, updated_at = CURRENT_TIMESTAMP
+		WHERE employee_id = $1`, employeeId)
+	if err != nil {
+		return err
+	}
+
+	count, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return database.ErrRecordNotFound
+	}
+
+	return nil
+}
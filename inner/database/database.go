@@ -2,8 +2,9 @@ package database
 
 import (
 	"errors"
+	"fmt"
 	"github.com/jmoiron/sqlx"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 	"idm/inner/common"
 	"time"
 )
@@ -12,8 +13,23 @@ var DB *sqlx.DB
 
 var (
 	ErrRecordNotFound = errors.New("record not found")
+	ErrSodViolation   = errors.New("separation of duties violation")
 )
 
+// sodViolationCode код ошибки триггера, который проверяет правила разделения полномочий при выдаче роли
+const sodViolationCode = "IDM01"
+
+// MapError заменить ошибку триггера правил разделения полномочий на ErrSodViolation,
+// остальные ошибки вернуть как есть
+func MapError(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == sodViolationCode {
+		return fmt.Errorf("%w: %s", ErrSodViolation, pqErr.Message)
+	}
+
+	return err
+}
+
 // ConnectDb получить конфиг и подключиться с ним к базе данных
 func ConnectDb() *sqlx.DB {
 	cfg := common.GetConfig(".env")
@@ -3,17 +3,49 @@ package employee
 import "time"
 
 type Response struct {
-	Id        int64     `json:"id"`
-	Name      string    `json:"name"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	Id             int64      `json:"id"`
+	Name           string     `json:"name"`
+	UserName       string     `json:"user_name"`
+	Email          string     `json:"email"`
+	ManagerId      *int64     `json:"manager_id,omitempty"`
+	Department     string     `json:"department"`
+	DepartmentId   *int64     `json:"department_id,omitempty"`
+	Title          string     `json:"title"`
+	Location       string     `json:"location"`
+	EmploymentType string     `json:"employment_type"`
+	Status         string     `json:"status"`
+	TerminatedAt   *time.Time `json:"terminated_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
 }
 
 func (e *Employee) ToResponse() *Response {
 	return &Response{
-		Id:        e.Id,
-		Name:      e.Name,
-		CreatedAt: e.CreatedAt,
-		UpdatedAt: e.UpdatedAt,
+		Id:             e.Id,
+		Name:           e.Name,
+		UserName:       e.UserName,
+		Email:          e.Email,
+		ManagerId:      e.ManagerId,
+		Department:     e.Department,
+		DepartmentId:   e.DepartmentId,
+		Title:          e.Title,
+		Location:       e.Location,
+		EmploymentType: e.EmploymentType,
+		Status:         e.Status,
+		TerminatedAt:   e.TerminatedAt,
+		CreatedAt:      e.CreatedAt,
+		UpdatedAt:      e.UpdatedAt,
 	}
 }
+
+// UpdateRequest новые данные сотрудника
+type UpdateRequest struct {
+	Name           string `json:"name"`
+	UserName       string `json:"user_name"`
+	Email          string `json:"email"`
+	ManagerId      *int64 `json:"manager_id,omitempty"`
+	Department     string `json:"department"`
+	Title          string `json:"title"`
+	Location       string `json:"location"`
+	EmploymentType string `json:"employment_type"`
+}
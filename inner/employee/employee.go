@@ -4,16 +4,28 @@ import "fmt"
 
 type Repo interface {
 	FindById(id int64) (*Employee, error)
+	FindByUserName(userName string) (*Employee, error)
 	FindAll() ([]*Employee, error)
+	FindPage(afterId int64, limit int) ([]*Employee, error)
+	Count() (int64, error)
 	FindByIds(ids []int64) ([]*Employee, error)
 	Create(employee *Employee) error
+	Update(employee *Employee) error
+	SetStatus(id int64, status string) error
+	SetDepartment(ids []int64, departmentId *int64) ([]*Employee, error)
 	Remove(id int64) error
 	RemoveByIds(ids []int64) error
 }
 
+// SaveHook вызывается после создания или изменения сотрудника
+type SaveHook interface {
+	AfterSave(employee Response) error
+}
+
 // Service будет инкапсулировать бизнес-логику
 type Service struct {
-	repo Repo
+	repo  Repo
+	hooks []SaveHook
 }
 
 func NewService(repository Repo) *Service {
@@ -29,6 +41,15 @@ func (s *Service) FindById(id int64) (Response, error) {
 	return *employee.ToResponse(), nil
 }
 
+func (s *Service) FindByUserName(userName string) (Response, error) {
+	employee, err := s.repo.FindByUserName(userName)
+	if err != nil {
+		return Response{}, fmt.Errorf("error finding employee with user name %q: %w", userName, err)
+	}
+
+	return *employee.ToResponse(), nil
+}
+
 func (s *Service) FindAll() ([]Response, error) {
 	employees, err := s.repo.FindAll()
 	if err != nil {
@@ -43,6 +64,30 @@ func (s *Service) FindAll() ([]Response, error) {
 	return responses, nil
 }
 
+// FindPage не больше limit сотрудников с идентификатором больше afterId по возрастанию идентификатора
+func (s *Service) FindPage(afterId int64, limit int) ([]Response, error) {
+	employees, err := s.repo.FindPage(afterId, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error finding employees after id %d: %w", afterId, err)
+	}
+
+	var responses []Response
+	for _, employee := range employees {
+		responses = append(responses, *employee.ToResponse())
+	}
+
+	return responses, nil
+}
+
+func (s *Service) Count() (int64, error) {
+	count, err := s.repo.Count()
+	if err != nil {
+		return 0, fmt.Errorf("error counting employees: %w", err)
+	}
+
+	return count, nil
+}
+
 func (s *Service) FindByIds(ids []int64) ([]Response, error) {
 	employees, err := s.repo.FindByIds(ids)
 	if err != nil {
@@ -57,6 +102,11 @@ func (s *Service) FindByIds(ids []int64) ([]Response, error) {
 	return responses, nil
 }
 
+// UseHook добавить обработчик, который вызывается после создания или изменения сотрудника
+func (s *Service) UseHook(hook SaveHook) {
+	s.hooks = append(s.hooks, hook)
+}
+
 func (s *Service) Create(name string) (Response, error) {
 	employee := &Employee{Name: name}
 	err := s.repo.Create(employee)
@@ -64,7 +114,69 @@ func (s *Service) Create(name string) (Response, error) {
 		return Response{}, fmt.Errorf("error creating employee: %w", err)
 	}
 
-	return *employee.ToResponse(), nil
+	return s.afterSave(employee)
+}
+
+func (s *Service) Update(id int64, request UpdateRequest) (Response, error) {
+	employee, err := s.repo.FindById(id)
+	if err != nil {
+		return Response{}, fmt.Errorf("error finding employee with id %d: %w", id, err)
+	}
+
+	employee.Name = request.Name
+	employee.UserName = request.UserName
+	employee.Email = request.Email
+	employee.ManagerId = request.ManagerId
+	employee.Department = request.Department
+	employee.Title = request.Title
+	employee.Location = request.Location
+	employee.EmploymentType = request.EmploymentType
+
+	err = s.repo.Update(employee)
+	if err != nil {
+		return Response{}, fmt.Errorf("error updating employee with id %d: %w", id, err)
+	}
+
+	return s.afterSave(employee)
+}
+
+// SetActive включить или отключить учётную запись сотрудника
+func (s *Service) SetActive(id int64, active bool) (Response, error) {
+	status := StatusDisabled
+	if active {
+		status = StatusActive
+	}
+
+	err := s.repo.SetStatus(id, status)
+	if err != nil {
+		return Response{}, fmt.Errorf("error setting status of employee with id %d: %w", id, err)
+	}
+
+	employee, err := s.repo.FindById(id)
+	if err != nil {
+		return Response{}, fmt.Errorf("error finding employee with id %d: %w", id, err)
+	}
+
+	return s.afterSave(employee)
+}
+
+// SetDepartment перевести сотрудников в подразделение вместе с его названием; nil – убрать из подразделений
+func (s *Service) SetDepartment(ids []int64, departmentId *int64) ([]Response, error) {
+	employees, err := s.repo.SetDepartment(ids, departmentId)
+	if err != nil {
+		return nil, fmt.Errorf("error setting department of employees with ids %v: %w", ids, err)
+	}
+
+	responses := make([]Response, 0, len(employees))
+	for _, employee := range employees {
+		response, err := s.afterSave(employee)
+		if err != nil {
+			return nil, err
+		}
+		responses = append(responses, response)
+	}
+
+	return responses, nil
 }
 
 func (s *Service) Remove(id int64) error {
@@ -74,3 +186,14 @@ func (s *Service) Remove(id int64) error {
 func (s *Service) RemoveByIds(ids []int64) error {
 	return s.repo.RemoveByIds(ids)
 }
+
+func (s *Service) afterSave(employee *Employee) (Response, error) {
+	response := *employee.ToResponse()
+	for _, hook := range s.hooks {
+		if err := hook.AfterSave(response); err != nil {
+			return response, fmt.Errorf("error processing saved employee with id %d: %w", employee.Id, err)
+		}
+	}
+
+	return response, nil
+}
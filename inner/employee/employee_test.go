@@ -48,10 +48,22 @@ func (s *StubRepo) FindById(id int64) (*Employee, error) {
 }
 
 // Другие методы репозитория могут быть также реализованы при необходимости
+func (s *StubRepo) FindByUserName(userName string) (*Employee, error) {
+	return nil, nil
+}
+
 func (s *StubRepo) FindAll() ([]*Employee, error) {
 	return nil, nil
 }
 
+func (s *StubRepo) FindPage(afterId int64, limit int) ([]*Employee, error) {
+	return nil, nil
+}
+
+func (s *StubRepo) Count() (int64, error) {
+	return int64(len(s.employees)), nil
+}
+
 func (s *StubRepo) FindByIds(ids []int64) ([]*Employee, error) {
 	return nil, nil
 }
@@ -60,6 +72,18 @@ func (s *StubRepo) Create(employee *Employee) error {
 	return nil
 }
 
+func (s *StubRepo) Update(employee *Employee) error {
+	return nil
+}
+
+func (s *StubRepo) SetStatus(id int64, status string) error {
+	return nil
+}
+
+func (s *StubRepo) SetDepartment(ids []int64, departmentId *int64) ([]*Employee, error) {
+	return nil, nil
+}
+
 func (s *StubRepo) Remove(id int64) error {
 	return nil
 }
@@ -73,11 +97,26 @@ func (m *MockRepo) FindById(id int64) (*Employee, error) {
 	return args.Get(0).(*Employee), args.Error(1)
 }
 
+func (m *MockRepo) FindByUserName(userName string) (*Employee, error) {
+	args := m.Called(userName)
+	return args.Get(0).(*Employee), args.Error(1)
+}
+
 func (m *MockRepo) FindAll() ([]*Employee, error) {
 	args := m.Called()
 	return args.Get(0).([]*Employee), args.Error(1)
 }
 
+func (m *MockRepo) FindPage(afterId int64, limit int) ([]*Employee, error) {
+	args := m.Called(afterId, limit)
+	return args.Get(0).([]*Employee), args.Error(1)
+}
+
+func (m *MockRepo) Count() (int64, error) {
+	args := m.Called()
+	return args.Get(0).(int64), args.Error(1)
+}
+
 func (m *MockRepo) FindByIds(ids []int64) ([]*Employee, error) {
 	args := m.Called(ids)
 	return args.Get(0).([]*Employee), args.Error(1)
@@ -88,6 +127,21 @@ func (m *MockRepo) Create(employee *Employee) error {
 	return args.Error(0)
 }
 
+func (m *MockRepo) Update(employee *Employee) error {
+	args := m.Called(employee)
+	return args.Error(0)
+}
+
+func (m *MockRepo) SetStatus(id int64, status string) error {
+	args := m.Called(id, status)
+	return args.Error(0)
+}
+
+func (m *MockRepo) SetDepartment(ids []int64, departmentId *int64) ([]*Employee, error) {
+	args := m.Called(ids, departmentId)
+	return args.Get(0).([]*Employee), args.Error(1)
+}
+
 func (m *MockRepo) Remove(id int64) error {
 	args := m.Called(id)
 	return args.Error(0)
@@ -98,6 +152,12 @@ func (m *MockRepo) RemoveByIds(ids []int64) error {
 	return args.Error(0)
 }
 
+type HookFunc func(employee Response) error
+
+func (f HookFunc) AfterSave(employee Response) error {
+	return f(employee)
+}
+
 func TestEmployeeService(t *testing.T) {
 	assert := assertpackage.New(t)
 
@@ -151,6 +211,18 @@ func TestEmployeeService(t *testing.T) {
 		assert.True(repo.AssertNumberOfCalls(t, "FindById", 1))
 	})
 
+	t.Run("FindByUserName should return an employee", func(t *testing.T) {
+		repo := &MockRepo{}
+		service := NewService(repo)
+		employee := Employee{Id: 1, Name: "John Doe", UserName: "jdoe"}
+
+		repo.On("FindByUserName", "JDoe").Return(&employee, nil)
+		got, err := service.FindByUserName("JDoe")
+
+		assert.Nil(err)
+		assert.Equal(*employee.ToResponse(), got)
+	})
+
 	t.Run("FindAll should return a list of employees", func(t *testing.T) {
 		repo := &MockRepo{}
 		service := NewService(repo)
@@ -182,6 +254,21 @@ func TestEmployeeService(t *testing.T) {
 		assert.True(repo.AssertNumberOfCalls(t, "FindAll", 1))
 	})
 
+	t.Run("FindPage should return a page of employees and Count their total", func(t *testing.T) {
+		repo := &MockRepo{}
+		service := NewService(repo)
+
+		repo.On("FindPage", int64(1), 2).Return([]*Employee{{Id: 2, Name: "Jane"}, {Id: 5, Name: "John"}}, nil)
+		repo.On("Count").Return(int64(7), nil)
+		got, err := service.FindPage(1, 2)
+		count, countErr := service.Count()
+
+		assert.Nil(err)
+		assert.Nil(countErr)
+		assert.Equal([]int64{2, 5}, []int64{got[0].Id, got[1].Id})
+		assert.Equal(int64(7), count)
+	})
+
 	t.Run("FindByIds should return a list of employees", func(t *testing.T) {
 		repo := &MockRepo{}
 		service := NewService(repo)
@@ -283,4 +370,107 @@ func TestEmployeeService(t *testing.T) {
 		assert.True(repo.AssertNumberOfCalls(t, "RemoveByIds", 1))
 		assert.True(repo.AssertNumberOfCalls(t, "RemoveByIds", 1))
 	})
+
+	t.Run("Update should update an employee and run hooks", func(t *testing.T) {
+		repo := &MockRepo{}
+		service := NewService(repo)
+
+		var saved []Response
+		service.UseHook(HookFunc(func(employee Response) error {
+			saved = append(saved, employee)
+			return nil
+		}))
+
+		repo.On("FindById", int64(1)).Return(&Employee{Id: 1, Name: "John"}, nil)
+		repo.On("Update", mock.AnythingOfType("*employee.Employee")).Return(nil)
+		got, err := service.Update(1, UpdateRequest{Name: "John", Department: "engineering", Location: "Moscow"})
+
+		assert.Nil(err)
+		assert.Equal("engineering", got.Department)
+		assert.Equal("Moscow", got.Location)
+		assert.Len(saved, 1)
+		assert.Equal(int64(1), saved[0].Id)
+	})
+
+	t.Run("Update should return wrapped error", func(t *testing.T) {
+		repo := &MockRepo{}
+		service := NewService(repo)
+
+		err := errors.New("database error")
+		want := fmt.Errorf("error updating employee with id 1: %w", err)
+
+		repo.On("FindById", int64(1)).Return(&Employee{Id: 1}, nil)
+		repo.On("Update", mock.AnythingOfType("*employee.Employee")).Return(err)
+		_, got := service.Update(1, UpdateRequest{Name: "John"})
+
+		assert.Equal(want, got)
+	})
+
+	t.Run("Create should return hook error", func(t *testing.T) {
+		repo := &MockRepo{}
+		service := NewService(repo)
+
+		err := errors.New("hook error")
+		service.UseHook(HookFunc(func(employee Response) error {
+			return err
+		}))
+
+		repo.On("Create", mock.AnythingOfType("*employee.Employee")).Return(nil)
+		got, hookErr := service.Create("John")
+
+		assert.ErrorIs(hookErr, err)
+		assert.Equal("John", got.Name)
+	})
+
+	t.Run("SetActive should disable an employee", func(t *testing.T) {
+		repo := &MockRepo{}
+		service := NewService(repo)
+
+		repo.On("SetStatus", int64(1), StatusDisabled).Return(nil)
+		repo.On("FindById", int64(1)).Return(&Employee{Id: 1, Status: StatusDisabled}, nil)
+		got, err := service.SetActive(1, false)
+
+		assert.Nil(err)
+		assert.Equal(StatusDisabled, got.Status)
+	})
+
+	t.Run("SetActive should run save hooks", func(t *testing.T) {
+		repo := &MockRepo{}
+		service := NewService(repo)
+		var saved Response
+		service.UseHook(HookFunc(func(employee Response) error {
+			saved = employee
+			return nil
+		}))
+
+		repo.On("SetStatus", int64(1), StatusDisabled).Return(nil)
+		repo.On("FindById", int64(1)).Return(&Employee{Id: 1, Status: StatusDisabled}, nil)
+		_, err := service.SetActive(1, false)
+
+		assert.Nil(err)
+		assert.Equal(StatusDisabled, saved.Status)
+	})
+
+	t.Run("SetDepartment should run save hooks for every moved employee", func(t *testing.T) {
+		repo := &MockRepo{}
+		service := NewService(repo)
+		var saved []Response
+		service.UseHook(HookFunc(func(employee Response) error {
+			saved = append(saved, employee)
+			return nil
+		}))
+		departmentId := int64(4)
+
+		repo.On("SetDepartment", []int64{1, 2}, &departmentId).Return([]*Employee{
+			{Id: 1, Department: "Sales", DepartmentId: &departmentId},
+			{Id: 2, Department: "Sales", DepartmentId: &departmentId},
+		}, nil)
+		got, err := service.SetDepartment([]int64{1, 2}, &departmentId)
+
+		assert.Nil(err)
+		assert.Len(got, 2)
+		assert.Equal("Sales", got[1].Department)
+		assert.Len(saved, 2)
+		assert.Equal(int64(2), saved[1].Id)
+	})
 }
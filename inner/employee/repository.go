@@ -7,14 +7,47 @@ import (
 	"github.com/jmoiron/sqlx"
 	"github.com/lib/pq"
 	"idm/inner/database"
+	"slices"
 	"time"
 )
 
+const (
+	StatusActive   = "active"
+	StatusDisabled = "disabled"
+)
+
 type Employee struct {
-	Id        int64     `db:"id"`
-	Name      string    `db:"name"`
-	CreatedAt time.Time `db:"created_at"`
-	UpdatedAt time.Time `db:"updated_at"`
+	Id             int64      `db:"id"`
+	Name           string     `db:"name"`
+	UserName       string     `db:"user_name"`
+	Email          string     `db:"email"`
+	ManagerId      *int64     `db:"manager_id"`
+	Department     string     `db:"department"`
+	DepartmentId   *int64     `db:"department_id"`
+	Title          string     `db:"title"`
+	Location       string     `db:"location"`
+	EmploymentType string     `db:"employment_type"`
+	Status         string     `db:"status"`
+	TerminatedAt   *time.Time `db:"terminated_at"`
+	CreatedAt      time.Time  `db:"created_at"`
+	UpdatedAt      time.Time  `db:"updated_at"`
+}
+
+// Attributes атрибуты сотрудника, по которым ему назначаются роли по умолчанию
+type Attributes struct {
+	Department     string `json:"department"`
+	Title          string `json:"title"`
+	Location       string `json:"location"`
+	EmploymentType string `json:"employment_type"`
+}
+
+func (e *Employee) Attributes() Attributes {
+	return Attributes{
+		Department:     e.Department,
+		Title:          e.Title,
+		Location:       e.Location,
+		EmploymentType: e.EmploymentType,
+	}
 }
 
 type Repository struct {
@@ -44,6 +77,27 @@ func (r *Repository) FindById(id int64) (*Employee, error) {
 	return &employee, err
 }
 
+// FindByUserName найти сотрудника по имени для входа без учёта регистра
+func (r *Repository) FindByUserName(userName string) (*Employee, error) {
+	var employee Employee
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := r.db.GetContext(ctx, &employee,
+		"SELECT * FROM employees WHERE lower(user_name) = lower($1) AND user_name <> ''", userName)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, database.ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &employee, err
+}
+
 func (r *Repository) FindAll() ([]*Employee, error) {
 	var employees []*Employee
 
@@ -55,6 +109,28 @@ func (r *Repository) FindAll() ([]*Employee, error) {
 	return employees, err
 }
 
+func (r *Repository) FindPage(afterId int64, limit int) ([]*Employee, error) {
+	var employees []*Employee
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := r.db.SelectContext(ctx, &employees, "SELECT * FROM employees WHERE id > $1 ORDER BY id LIMIT $2", afterId, limit)
+
+	return employees, err
+}
+
+func (r *Repository) Count() (int64, error) {
+	var count int64
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := r.db.GetContext(ctx, &count, "SELECT count(*) FROM employees")
+
+	return count, err
+}
+
 func (r *Repository) FindByIds(ids []int64) ([]*Employee, error) {
 	var employees []*Employee
 	err := r.db.Select(&employees, "SELECT * FROM employees WHERE id = ANY($1)", pq.Array(ids))
@@ -67,14 +143,92 @@ func (r *Repository) Create(employee *Employee) error {
 	defer cancel()
 
 	err := r.db.QueryRowContext(ctx,
-		"INSERT INTO employees (name) VALUES ($1) RETURNING id, created_at, updated_at",
+		"INSERT INTO employees (name) VALUES ($1) RETURNING id, status, created_at, updated_at",
 		employee.Name,
-	).Scan(&employee.Id, &employee.CreatedAt, &employee.UpdatedAt)
+	).Scan(&employee.Id, &employee.Status, &employee.CreatedAt, &employee.UpdatedAt)
+
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *Repository) Update(employee *Employee) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	// другое название подразделения выводит сотрудника из подразделения, в котором он состоял
+	err := r.db.QueryRowContext(ctx,
+		`UPDATE employees
+		SET name = $1, user_name = $2, email = $3, manager_id = $4, department = $5, title = $6, location = $7,
+			employment_type = $8, department_id = CASE WHEN department = $5 THEN department_id END,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = $9 RETURNING department_id, status, created_at, updated_at`,
+		employee.Name, employee.UserName, employee.Email, employee.ManagerId, employee.Department, employee.Title,
+		employee.Location, employee.EmploymentType, employee.Id,
+	).Scan(&employee.DepartmentId, &employee.Status, &employee.CreatedAt, &employee.UpdatedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return database.ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// SetDepartment перевести сотрудников в подразделение; nil – убрать из подразделений. Название
+// подразделения записывается в department тем же запросом. Если кого-то из сотрудников нет,
+// не переводится никто.
+func (r *Repository) SetDepartment(ids []int64, departmentId *int64) ([]*Employee, error) {
+	var employees []*Employee
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	err = tx.SelectContext(ctx, &employees,
+		`UPDATE employees
+		SET department_id = $1, department = COALESCE((SELECT name FROM departments WHERE id = $1), ''),
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = ANY($2) RETURNING *`,
+		departmentId, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	if len(employees) != len(ids) {
+		return nil, database.ErrRecordNotFound
+	}
+
+	return employees, tx.Commit()
+}
+
+func (r *Repository) SetStatus(id int64, status string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
 
+	result, err := r.db.ExecContext(ctx,
+		"UPDATE employees SET status = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2", status, id)
 	if err != nil {
 		return err
 	}
 
+	count, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return database.ErrRecordNotFound
+	}
+
 	return nil
 }
 
@@ -82,16 +236,46 @@ func (r *Repository) Remove(id int64) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	_, err := r.db.ExecContext(ctx, "DELETE FROM employees WHERE id = $1", id)
+	result, err := r.db.ExecContext(ctx, "DELETE FROM employees WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+
+	count, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return database.ErrRecordNotFound
+	}
 
-	return err
+	return nil
 }
 
+// RemoveByIds удалить всех сотрудников с ids или ни одного: если кого-то из них нет,
+// возвращается database.ErrRecordNotFound, как и в Remove
 func (r *Repository) RemoveByIds(ids []int64) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	_, err := r.db.ExecContext(ctx, "DELETE FROM employees WHERE id = ANY($1)", pq.Array(ids))
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	result, err := tx.ExecContext(ctx, "DELETE FROM employees WHERE id = ANY($1)", pq.Array(ids))
+	if err != nil {
+		return err
+	}
+
+	count, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if count != int64(len(slices.Compact(slices.Sorted(slices.Values(ids))))) {
+		return database.ErrRecordNotFound
+	}
 
-	return err
+	return tx.Commit()
 }
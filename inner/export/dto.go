@@ -0,0 +1,11 @@
+package export
+
+// Options параметры выгрузки
+type Options struct {
+	Kind   Kind   `json:"kind"`
+	Format Format `json:"format"`
+	// Columns выбранные столбцы в нужном порядке; по умолчанию все
+	Columns []string `json:"columns,omitempty"`
+	// Filter фильтр в синтаксисе SCIM по именам столбцов, например status eq "active" and department sw "Fin"
+	Filter string `json:"filter,omitempty"`
+}
@@ -0,0 +1,175 @@
+package export
+
+import (
+	"errors"
+	"fmt"
+	"idm/inner/scimfilter"
+	"io"
+	"slices"
+	"strings"
+	"time"
+)
+
+type Kind string
+
+const (
+	KindEmployees   Kind = "employees"
+	KindRoles       Kind = "roles"
+	KindAssignments Kind = "assignments"
+)
+
+type Format string
+
+const (
+	FormatCSV   Format = "csv"
+	FormatJSONL Format = "jsonl"
+	FormatXLSX  Format = "xlsx"
+)
+
+var ErrInvalidOptions = errors.New("invalid export options")
+
+// Columns столбцы выгрузки в порядке по умолчанию
+var Columns = map[Kind][]string{
+	KindEmployees: {"id", "user_name", "name", "email", "department", "title", "location", "employment_type",
+		"status", "manager_id", "manager_user_name", "terminated_at", "created_at", "updated_at"},
+	KindRoles:       {"id", "name", "owner_id", "owner_user_name", "members", "created_at", "updated_at"},
+	KindAssignments: {"employee_id", "user_name", "employee_name", "role_id", "role_name", "source", "assigned_at"},
+}
+
+// Record строка выгрузки: значения int64, string, time.Time или nil по именам столбцов
+type Record map[string]any
+
+type Repo interface {
+	// Each передать записи по одной в порядке id, не загружая их все в память
+	Each(kind Kind, each func(Record) error) error
+}
+
+type Service struct {
+	repo Repo
+}
+
+func NewService(repository Repo) *Service {
+	return &Service{repo: repository}
+}
+
+// Export записать выгрузку в w и вернуть число строк. Ошибка ErrInvalidOptions возвращается
+// до того, как в w что-либо записано.
+func (s *Service) Export(w io.Writer, options Options) (int, error) {
+	options, err := normalize(options)
+	if err != nil {
+		return 0, err
+	}
+	var filter scimfilter.Filter
+	if options.Filter != "" {
+		if filter, err = scimfilter.Parse(options.Filter); err != nil {
+			return 0, fmt.Errorf("%w: %w", ErrInvalidOptions, err)
+		}
+	}
+
+	// заголовок пишется только вместе с первой строкой или в конце,
+	// чтобы при ошибке запроса в w ничего не попало
+	var out writer
+	start := func() error {
+		if out != nil {
+			return nil
+		}
+		out = newWriter(w, options.Format, options.Columns)
+		return out.header()
+	}
+
+	count := 0
+	err = s.repo.Each(options.Kind, func(record Record) error {
+		if filter != nil && !filter.Match(filterable(record)) {
+			return nil
+		}
+		if err := start(); err != nil {
+			return err
+		}
+		values := make([]any, len(options.Columns))
+		for i, column := range options.Columns {
+			values[i] = record[column]
+		}
+		count++
+		return out.write(values)
+	})
+	if err != nil {
+		return count, fmt.Errorf("error exporting %s: %w", options.Kind, err)
+	}
+	if err := start(); err != nil {
+		return count, fmt.Errorf("error exporting %s: %w", options.Kind, err)
+	}
+	if err := out.close(); err != nil {
+		return count, fmt.Errorf("error exporting %s: %w", options.Kind, err)
+	}
+
+	return count, nil
+}
+
+// ContentType MIME-тип формата
+func ContentType(format Format) string {
+	switch format {
+	case FormatJSONL:
+		return "application/x-ndjson"
+	case FormatXLSX:
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	}
+
+	return "text/csv; charset=utf-8"
+}
+
+func normalize(options Options) (Options, error) {
+	columns, ok := Columns[options.Kind]
+	if !ok {
+		return options, fmt.Errorf("%w: unknown kind %q", ErrInvalidOptions, options.Kind)
+	}
+	if options.Format == "" {
+		options.Format = FormatCSV
+	}
+	if !slices.Contains([]Format{FormatCSV, FormatJSONL, FormatXLSX}, options.Format) {
+		return options, fmt.Errorf("%w: unknown format %q", ErrInvalidOptions, options.Format)
+	}
+	if len(options.Columns) == 0 {
+		options.Columns = columns
+	}
+	seen := map[string]bool{}
+	for _, column := range options.Columns {
+		if !slices.Contains(columns, column) {
+			return options, fmt.Errorf("%w: unknown %s column %q", ErrInvalidOptions, options.Kind, column)
+		}
+		if seen[column] {
+			return options, fmt.Errorf("%w: duplicate column %q", ErrInvalidOptions, column)
+		}
+		seen[column] = true
+	}
+
+	return options, nil
+}
+
+// ParseColumns разобрать список столбцов через запятую
+func ParseColumns(value string) []string {
+	var columns []string
+	for _, column := range strings.Split(value, ",") {
+		if column = strings.TrimSpace(column); column != "" {
+			columns = append(columns, column)
+		}
+	}
+
+	return columns
+}
+
+// filterable запись в том виде, в каком её видит фильтр SCIM: числа как float64, время как строка RFC 3339
+func filterable(record Record) map[string]any {
+	object := make(map[string]any, len(record))
+	for column, value := range record {
+		switch v := value.(type) {
+		case int64:
+			object[column] = float64(v)
+		case time.Time:
+			object[column] = v.UTC().Format(time.RFC3339)
+		default:
+			object[column] = v
+		}
+	}
+
+	return object
+}
@@ -0,0 +1,214 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	assertpackage "github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// StubRepo отдаёт записи по одной; err возвращается после failAfter записей
+type StubRepo struct {
+	records   map[Kind][]Record
+	err       error
+	failAfter int
+}
+
+func (r *StubRepo) Each(kind Kind, each func(Record) error) error {
+	for i, record := range r.records[kind] {
+		if r.err != nil && i == r.failAfter {
+			return r.err
+		}
+		if err := each(record); err != nil {
+			return err
+		}
+	}
+
+	return r.err
+}
+
+func NewStubRepo() *StubRepo {
+	created := time.Date(2024, 3, 1, 9, 30, 0, 0, time.UTC)
+	return &StubRepo{records: map[Kind][]Record{
+		KindEmployees: {
+			{"id": int64(1), "user_name": "alice", "name": "Alice", "email": "alice@example.com",
+				"department": "Finance", "status": "active", "manager_id": nil, "created_at": created},
+			{"id": int64(2), "user_name": "bob", "name": "Bob, Jr.", "email": "bob@example.com",
+				"department": "Sales", "status": "disabled", "manager_id": int64(1), "created_at": created},
+			{"id": int64(3), "user_name": "carol", "name": "Carol <CFO>", "email": "carol@example.com",
+				"department": "Finance", "status": "active", "manager_id": int64(1), "created_at": created},
+		},
+	}}
+}
+
+func TestExport(t *testing.T) {
+	var assert = assertpackage.New(t)
+
+	t.Run("should write selected columns as csv", func(t *testing.T) {
+		service := NewService(NewStubRepo())
+		var out bytes.Buffer
+
+		count, err := service.Export(&out, Options{Kind: KindEmployees, Columns: []string{"id", "name", "manager_id", "created_at"}})
+
+		assert.Nil(err)
+		assert.Equal(3, count)
+		assert.Equal("id,name,manager_id,created_at\n"+
+			"1,Alice,,2024-03-01T09:30:00Z\n"+
+			"2,\"Bob, Jr.\",1,2024-03-01T09:30:00Z\n"+
+			"3,Carol <CFO>,1,2024-03-01T09:30:00Z\n", out.String())
+	})
+
+	t.Run("should filter rows like the list apis", func(t *testing.T) {
+		service := NewService(NewStubRepo())
+		var out bytes.Buffer
+
+		count, err := service.Export(&out, Options{
+			Kind:    KindEmployees,
+			Format:  FormatJSONL,
+			Columns: []string{"user_name", "manager_id"},
+			Filter:  `department eq "finance" and (status eq "active" and id gt 1)`,
+		})
+
+		assert.Nil(err)
+		assert.Equal(1, count)
+		assert.Equal(`{"user_name":"carol","manager_id":1}`+"\n", out.String())
+	})
+
+	t.Run("should write only the header when nothing matches", func(t *testing.T) {
+		service := NewService(NewStubRepo())
+		var out bytes.Buffer
+
+		count, err := service.Export(&out, Options{Kind: KindEmployees, Columns: []string{"id"}, Filter: "id gt 10"})
+
+		assert.Nil(err)
+		assert.Equal(0, count)
+		assert.Equal("id\n", out.String())
+	})
+
+	t.Run("should write an xlsx workbook", func(t *testing.T) {
+		service := NewService(NewStubRepo())
+		var out bytes.Buffer
+
+		_, err := service.Export(&out, Options{Kind: KindEmployees, Format: FormatXLSX, Columns: []string{"id", "name", "manager_id"}})
+		assert.Nil(err)
+
+		archive, err := zip.NewReader(bytes.NewReader(out.Bytes()), int64(out.Len()))
+		assert.Nil(err)
+		var names []string
+		var sheet string
+		for _, file := range archive.File {
+			names = append(names, file.Name)
+			if file.Name == "xl/worksheets/sheet1.xml" {
+				reader, _ := file.Open()
+				data, _ := io.ReadAll(reader)
+				sheet = string(data)
+			}
+		}
+		assert.Equal([]string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml", "xl/_rels/workbook.xml.rels",
+			"xl/worksheets/sheet1.xml"}, names)
+		assert.Contains(sheet, `<row r="1"><c r="A1" t="inlineStr"><is><t xml:space="preserve">id</t></is></c>`)
+		assert.Contains(sheet, `<row r="2"><c r="A2"><v>1</v></c><c r="B2" t="inlineStr"><is><t xml:space="preserve">Alice</t></is></c></row>`)
+		assert.Contains(sheet, `Carol &lt;CFO&gt;`)
+		assert.Contains(sheet, `<c r="C4"><v>1</v></c></row></sheetData></worksheet>`)
+	})
+
+	t.Run("should refuse invalid options before writing anything", func(t *testing.T) {
+		service := NewService(NewStubRepo())
+
+		for _, options := range []Options{
+			{Kind: "groups"},
+			{Kind: KindEmployees, Format: "pdf"},
+			{Kind: KindEmployees, Columns: []string{"salary"}},
+			{Kind: KindEmployees, Columns: []string{"id", "id"}},
+			{Kind: KindEmployees, Filter: "status eq"},
+		} {
+			var out bytes.Buffer
+			_, err := service.Export(&out, options)
+			assert.ErrorIs(err, ErrInvalidOptions, "%+v", options)
+			assert.Zero(out.Len())
+		}
+	})
+
+	t.Run("should not write anything when the query fails", func(t *testing.T) {
+		repo := NewStubRepo()
+		repo.err = errors.New("connection refused")
+		service := NewService(repo)
+		var out bytes.Buffer
+
+		_, err := service.Export(&out, Options{Kind: KindEmployees})
+
+		assert.ErrorContains(err, "connection refused")
+		assert.Zero(out.Len())
+	})
+}
+
+func TestCellColumn(t *testing.T) {
+	var assert = assertpackage.New(t)
+
+	assert.Equal("A", cellColumn(0))
+	assert.Equal("Z", cellColumn(25))
+	assert.Equal("AA", cellColumn(26))
+	assert.Equal("AZ", cellColumn(51))
+	assert.Equal("BA", cellColumn(52))
+}
+
+func TestHandler(t *testing.T) {
+	var assert = assertpackage.New(t)
+
+	t.Run("should stream an attachment", func(t *testing.T) {
+		handler := NewHandler(NewService(NewStubRepo()))
+		recorder := httptest.NewRecorder()
+
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/employees?columns=id,+user_name&filter=status+eq+%22active%22", nil))
+
+		assert.Equal(http.StatusOK, recorder.Code)
+		assert.Equal("text/csv; charset=utf-8", recorder.Header().Get("Content-Type"))
+		assert.Equal(`attachment; filename="employees.csv"`, recorder.Header().Get("Content-Disposition"))
+		assert.Equal("id,user_name\n1,alice\n3,carol\n", recorder.Body.String())
+	})
+
+	t.Run("should answer 400 on invalid options", func(t *testing.T) {
+		handler := NewHandler(NewService(NewStubRepo()))
+		recorder := httptest.NewRecorder()
+
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/employees?columns=salary", nil))
+
+		assert.Equal(http.StatusBadRequest, recorder.Code)
+		assert.Equal("application/json", recorder.Header().Get("Content-Type"))
+		assert.Empty(recorder.Header().Get("Content-Disposition"))
+	})
+
+	t.Run("should answer 500 when the export fails before streaming", func(t *testing.T) {
+		repo := NewStubRepo()
+		repo.err = errors.New("connection refused")
+		handler := NewHandler(NewService(repo))
+		recorder := httptest.NewRecorder()
+
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/employees", nil))
+
+		assert.Equal(http.StatusInternalServerError, recorder.Code)
+		assert.False(strings.Contains(recorder.Body.String(), "connection refused"))
+	})
+
+	t.Run("should abort the response when the export fails while streaming", func(t *testing.T) {
+		repo := NewStubRepo()
+		for i := range 1000 {
+			repo.records[KindEmployees] = append(repo.records[KindEmployees], Record{"id": int64(10 + i), "name": "Employee"})
+		}
+		repo.err, repo.failAfter = errors.New("connection reset"), 900
+		handler := NewHandler(NewService(repo))
+		recorder := httptest.NewRecorder()
+
+		assert.PanicsWithValue(http.ErrAbortHandler, func() {
+			handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/employees?format=jsonl", nil))
+		})
+		assert.Equal(http.StatusOK, recorder.Code)
+		assert.NotZero(recorder.Body.Len())
+	})
+}
@@ -0,0 +1,74 @@
+package export
+
+import (
+	"errors"
+	"idm/inner/httpapi"
+	"log"
+	"net/http"
+)
+
+// Handler выгрузка по HTTP: GET /{kind}?format=xlsx&columns=id,user_name&filter=status+eq+"active"
+type Handler struct {
+	service *Service
+	mux     *http.ServeMux
+}
+
+func NewHandler(service *Service) *Handler {
+	h := &Handler{service: service, mux: http.NewServeMux()}
+
+	h.mux.HandleFunc("GET /{kind}", h.export)
+
+	return h
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+func (h *Handler) export(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	options := Options{
+		Kind:    Kind(r.PathValue("kind")),
+		Format:  Format(query.Get("format")),
+		Columns: ParseColumns(query.Get("columns")),
+		Filter:  query.Get("filter"),
+	}
+	if options.Format == "" {
+		options.Format = FormatCSV
+	}
+
+	w.Header().Set("Content-Type", ContentType(options.Format))
+	w.Header().Set("Content-Disposition", `attachment; filename="`+string(options.Kind)+"."+string(options.Format)+`"`)
+	w.Header().Set("Cache-Control", "no-store")
+	out := &startedWriter{ResponseWriter: w}
+
+	_, err := h.service.Export(out, options)
+	switch {
+	case err == nil:
+	case out.started:
+		// заголовки уже отправлены: обрываем ответ, чтобы клиент не принял неполный файл за целый
+		log.Printf("error streaming export: %v", err)
+		panic(http.ErrAbortHandler)
+	case errors.Is(err, ErrInvalidOptions):
+		writeError(w, http.StatusBadRequest, err.Error())
+	default:
+		log.Printf("error exporting: %v", err)
+		writeError(w, http.StatusInternalServerError, "internal error")
+	}
+}
+
+// startedWriter запоминает, начата ли уже запись ответа
+type startedWriter struct {
+	http.ResponseWriter
+	started bool
+}
+
+func (s *startedWriter) Write(data []byte) (int, error) {
+	s.started = true
+	return s.ResponseWriter.Write(data)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Del("Content-Disposition")
+	httpapi.WriteError(w, status, message)
+}
@@ -0,0 +1,143 @@
+package export
+
+import (
+	"context"
+	"github.com/jmoiron/sqlx"
+	"time"
+)
+
+// exportTimeout общий тайм-аут выгрузки: строки читаются, пока клиент их принимает
+const exportTimeout = 10 * time.Minute
+
+type employeeRow struct {
+	Id              int64      `db:"id"`
+	UserName        string     `db:"user_name"`
+	Name            string     `db:"name"`
+	Email           string     `db:"email"`
+	Department      string     `db:"department"`
+	Title           string     `db:"title"`
+	Location        string     `db:"location"`
+	EmploymentType  string     `db:"employment_type"`
+	Status          string     `db:"status"`
+	ManagerId       *int64     `db:"manager_id"`
+	ManagerUserName *string    `db:"manager_user_name"`
+	TerminatedAt    *time.Time `db:"terminated_at"`
+	CreatedAt       time.Time  `db:"created_at"`
+	UpdatedAt       time.Time  `db:"updated_at"`
+}
+
+func (e *employeeRow) record() Record {
+	return Record{
+		"id": e.Id, "user_name": e.UserName, "name": e.Name, "email": e.Email, "department": e.Department,
+		"title": e.Title, "location": e.Location, "employment_type": e.EmploymentType, "status": e.Status,
+		"manager_id": nullable(e.ManagerId), "manager_user_name": nullable(e.ManagerUserName),
+		"terminated_at": nullable(e.TerminatedAt), "created_at": e.CreatedAt, "updated_at": e.UpdatedAt,
+	}
+}
+
+type roleRow struct {
+	Id            int64     `db:"id"`
+	Name          string    `db:"name"`
+	OwnerId       *int64    `db:"owner_id"`
+	OwnerUserName *string   `db:"owner_user_name"`
+	Members       int64     `db:"members"`
+	CreatedAt     time.Time `db:"created_at"`
+	UpdatedAt     time.Time `db:"updated_at"`
+}
+
+func (r *roleRow) record() Record {
+	return Record{
+		"id": r.Id, "name": r.Name, "owner_id": nullable(r.OwnerId), "owner_user_name": nullable(r.OwnerUserName),
+		"members": r.Members, "created_at": r.CreatedAt, "updated_at": r.UpdatedAt,
+	}
+}
+
+type assignmentRow struct {
+	EmployeeId   int64     `db:"employee_id"`
+	UserName     string    `db:"user_name"`
+	EmployeeName string    `db:"employee_name"`
+	RoleId       int64     `db:"role_id"`
+	RoleName     string    `db:"role_name"`
+	Source       string    `db:"source"`
+	AssignedAt   time.Time `db:"assigned_at"`
+}
+
+func (a *assignmentRow) record() Record {
+	return Record{
+		"employee_id": a.EmployeeId, "user_name": a.UserName, "employee_name": a.EmployeeName,
+		"role_id": a.RoleId, "role_name": a.RoleName, "source": a.Source, "assigned_at": a.AssignedAt,
+	}
+}
+
+type Repository struct {
+	db *sqlx.DB
+}
+
+func NewRepository(db *sqlx.DB) *Repository {
+	return &Repository{db: db}
+}
+
+func (r *Repository) Each(kind Kind, each func(Record) error) error {
+	switch kind {
+	case KindEmployees:
+		return scan(r.db,
+			`SELECT e.id, e.user_name, e.name, e.email, e.department, e.title, e.location, e.employment_type,
+				e.status, e.manager_id, NULLIF(m.user_name, '') AS manager_user_name, e.terminated_at,
+				e.created_at, e.updated_at
+			FROM employees e LEFT JOIN employees m ON m.id = e.manager_id
+			ORDER BY e.id`,
+			func(row *employeeRow) error { return each(row.record()) })
+	case KindRoles:
+		return scan(r.db,
+			`SELECT r.id, r.name, r.owner_id, NULLIF(o.user_name, '') AS owner_user_name,
+				(SELECT count(*) FROM employee_roles er WHERE er.role_id = r.id) AS members,
+				r.created_at, r.updated_at
+			FROM roles r LEFT JOIN employees o ON o.id = r.owner_id
+			ORDER BY r.id`,
+			func(row *roleRow) error { return each(row.record()) })
+	case KindAssignments:
+		return scan(r.db,
+			`SELECT er.employee_id, e.user_name, e.name AS employee_name, er.role_id, r.name AS role_name,
+				er.source, er.created_at AS assigned_at
+			FROM employee_roles er
+			JOIN employees e ON e.id = er.employee_id
+			JOIN roles r ON r.id = er.role_id
+			ORDER BY er.employee_id, er.role_id`,
+			func(row *assignmentRow) error { return each(row.record()) })
+	}
+
+	return ErrInvalidOptions
+}
+
+// scan читать строки курсором по одной; each вызывается, пока соединение с базой открыто
+func scan[T any](db *sqlx.DB, query string, each func(*T) error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), exportTimeout)
+	defer cancel()
+
+	rows, err := db.QueryxContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = rows.Close() }()
+
+	for rows.Next() {
+		var row T
+		if err := rows.StructScan(&row); err != nil {
+			return err
+		}
+		if err := each(&row); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// nullable nil для отсутствующего значения, иначе само значение
+func nullable[T any](value *T) any {
+	if value == nil {
+		return nil
+	}
+
+	return *value
+}
@@ -0,0 +1,213 @@
+package export
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"strconv"
+	"time"
+)
+
+// writer построчная запись в одном из форматов
+type writer interface {
+	header() error
+	write(values []any) error
+	close() error
+}
+
+func newWriter(w io.Writer, format Format, columns []string) writer {
+	switch format {
+	case FormatJSONL:
+		return &jsonlWriter{out: bufio.NewWriter(w), columns: columns}
+	case FormatXLSX:
+		return &xlsxWriter{archive: zip.NewWriter(w), columns: columns}
+	}
+
+	return &csvWriter{out: csv.NewWriter(w), columns: columns}
+}
+
+// text значение ячейки в текстовых форматах; пустое значение – пустая строка
+func text(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case time.Time:
+		return v.UTC().Format(time.RFC3339)
+	}
+
+	return ""
+}
+
+type csvWriter struct {
+	out     *csv.Writer
+	columns []string
+}
+
+func (c *csvWriter) header() error {
+	return c.out.Write(c.columns)
+}
+
+func (c *csvWriter) write(values []any) error {
+	fields := make([]string, len(values))
+	for i, value := range values {
+		fields[i] = text(value)
+	}
+
+	return c.out.Write(fields)
+}
+
+func (c *csvWriter) close() error {
+	c.out.Flush()
+	return c.out.Error()
+}
+
+// jsonlWriter объект на строку; ключи идут в порядке выбранных столбцов
+type jsonlWriter struct {
+	out     *bufio.Writer
+	columns []string
+}
+
+func (j *jsonlWriter) header() error {
+	return nil
+}
+
+func (j *jsonlWriter) write(values []any) error {
+	var line bytes.Buffer
+	line.WriteByte('{')
+	for i, value := range values {
+		if i > 0 {
+			line.WriteByte(',')
+		}
+		key, _ := json.Marshal(j.columns[i])
+		line.Write(key)
+		line.WriteByte(':')
+		if t, ok := value.(time.Time); ok {
+			value = t.UTC().Format(time.RFC3339)
+		}
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		line.Write(encoded)
+	}
+	line.WriteString("}\n")
+
+	_, err := j.out.Write(line.Bytes())
+
+	return err
+}
+
+func (j *jsonlWriter) close() error {
+	return j.out.Flush()
+}
+
+// xlsxWriter минимальная книга Office Open XML с одним листом. Служебные части пишутся сразу,
+// лист – последним элементом архива, строка за строкой.
+type xlsxWriter struct {
+	archive *zip.Writer
+	sheet   *bufio.Writer
+	columns []string
+	row     int
+}
+
+var xlsxParts = []struct{ name, content string }{
+	{"[Content_Types].xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+		`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+		`<Default Extension="xml" ContentType="application/xml"/>` +
+		`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+		`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+		`</Types>`},
+	{"_rels/.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+		`</Relationships>`},
+	{"xl/workbook.xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+		`<sheets><sheet name="export" sheetId="1" r:id="rId1"/></sheets></workbook>`},
+	{"xl/_rels/workbook.xml.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+		`</Relationships>`},
+}
+
+func (x *xlsxWriter) header() error {
+	for _, part := range xlsxParts {
+		file, err := x.archive.Create(part.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(file, part.content); err != nil {
+			return err
+		}
+	}
+
+	sheet, err := x.archive.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return err
+	}
+	x.sheet = bufio.NewWriter(sheet)
+	_, _ = x.sheet.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n" +
+		`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+
+	values := make([]any, len(x.columns))
+	for i, column := range x.columns {
+		values[i] = column
+	}
+
+	return x.write(values)
+}
+
+// write числа записываются числовыми ячейками, остальное – строками, время – в RFC 3339
+func (x *xlsxWriter) write(values []any) error {
+	x.row++
+	row := strconv.Itoa(x.row)
+	_, _ = x.sheet.WriteString(`<row r="` + row + `">`)
+	for i, value := range values {
+		ref := cellColumn(i) + row
+		switch v := value.(type) {
+		case nil:
+			continue
+		case int64:
+			_, _ = x.sheet.WriteString(`<c r="` + ref + `"><v>` + strconv.FormatInt(v, 10) + `</v></c>`)
+		default:
+			_, _ = x.sheet.WriteString(`<c r="` + ref + `" t="inlineStr"><is><t xml:space="preserve">`)
+			if err := xml.EscapeText(x.sheet, []byte(text(v))); err != nil {
+				return err
+			}
+			_, _ = x.sheet.WriteString(`</t></is></c>`)
+		}
+	}
+	_, err := x.sheet.WriteString(`</row>`)
+
+	return err
+}
+
+func (x *xlsxWriter) close() error {
+	if _, err := x.sheet.WriteString(`</sheetData></worksheet>`); err != nil {
+		return err
+	}
+	if err := x.sheet.Flush(); err != nil {
+		return err
+	}
+
+	return x.archive.Close()
+}
+
+// cellColumn буквенное имя столбца: 0 → A, 25 → Z, 26 → AA
+func cellColumn(index int) string {
+	name := ""
+	for index++; index > 0; index = (index - 1) / 26 {
+		name = string(rune('A'+(index-1)%26)) + name
+	}
+
+	return name
+}
@@ -0,0 +1,338 @@
+package graphqlapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	assertpackage "github.com/stretchr/testify/assert"
+	"idm/inner/employee"
+	"idm/inner/role"
+	"idm/inner/serviceaccount"
+)
+
+// StubServices сотрудники и роли в памяти; calls считает обращения к каждому методу
+type StubServices struct {
+	mu          sync.Mutex
+	employees   []employee.Response
+	roles       []role.Response
+	assignments []role.Assignment
+	calls       map[string]int
+	removed     []int64
+}
+
+func (s *StubServices) call(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls[name]++
+}
+
+type StubEmployees struct{ *StubServices }
+
+type StubRoles struct{ *StubServices }
+
+func (s StubEmployees) FindByIds(ids []int64) ([]employee.Response, error) {
+	s.call("employees.FindByIds")
+	var found []employee.Response
+	for _, candidate := range s.employees {
+		if slices.Contains(ids, candidate.Id) {
+			found = append(found, candidate)
+		}
+	}
+	return found, nil
+}
+
+func (s StubEmployees) FindPage(afterId int64, limit int) ([]employee.Response, error) {
+	s.call("employees.FindPage")
+	var found []employee.Response
+	for _, candidate := range s.employees {
+		if candidate.Id > afterId {
+			found = append(found, candidate)
+		}
+	}
+	slices.SortFunc(found, func(a, b employee.Response) int { return int(a.Id - b.Id) })
+	return found[:min(len(found), limit)], nil
+}
+
+func (s StubEmployees) Count() (int64, error) {
+	s.call("employees.Count")
+	return int64(len(s.employees)), nil
+}
+
+func (s StubEmployees) Create(name string) (employee.Response, error) {
+	created := employee.Response{Id: 100, Name: name, Status: employee.StatusActive}
+	s.employees = append(s.employees, created)
+	return created, nil
+}
+
+func (s StubEmployees) RemoveByIds(ids []int64) error {
+	s.removed = append(s.removed, ids...)
+	return nil
+}
+
+func (s StubRoles) FindByIds(ids []int64) ([]role.Response, error) {
+	s.call("roles.FindByIds")
+	var found []role.Response
+	for _, candidate := range s.roles {
+		if slices.Contains(ids, candidate.Id) {
+			found = append(found, candidate)
+		}
+	}
+	return found, nil
+}
+
+func (s StubRoles) FindPage(afterId int64, limit int) ([]role.Response, error) {
+	s.call("roles.FindPage")
+	return nil, errors.New("connection refused")
+}
+
+func (s StubRoles) Count() (int64, error) {
+	s.call("roles.Count")
+	return int64(len(s.roles)), nil
+}
+
+func (s StubRoles) FindAssignments(employeeIds []int64, roleIds []int64) ([]role.Assignment, error) {
+	s.call("roles.FindAssignments")
+	var found []role.Assignment
+	for _, assignment := range s.assignments {
+		if slices.Contains(employeeIds, assignment.EmployeeId) || slices.Contains(roleIds, assignment.RoleId) {
+			found = append(found, assignment)
+		}
+	}
+	return found, nil
+}
+
+func (s StubRoles) Create(name string) (role.Response, error) {
+	return role.Response{Id: 200, Name: name}, nil
+}
+
+func (s StubRoles) RemoveByIds(ids []int64) error {
+	return nil
+}
+
+func stub() *StubServices {
+	managerId, ownerId := int64(1), int64(4)
+	return &StubServices{
+		employees: []employee.Response{
+			{Id: 3, Name: "Sidor", UserName: "sidorov"},
+			{Id: 1, Name: "Ivan", UserName: "ivanov"},
+			{Id: 2, Name: "Petr", UserName: "petrov", ManagerId: &managerId},
+			{Id: 4, Name: "Anna", UserName: "smirnova"},
+		},
+		roles: []role.Response{
+			{Id: 10, Name: "admins", Permissions: []string{"all"}, OwnerId: &ownerId},
+			{Id: 11, Name: "developers", Permissions: []string{"git:write"}},
+		},
+		assignments: []role.Assignment{{EmployeeId: 1, RoleId: 10}, {EmployeeId: 2, RoleId: 10},
+			{EmployeeId: 2, RoleId: 11}, {EmployeeId: 3, RoleId: 11}, {EmployeeId: 4, RoleId: 11}},
+		calls: map[string]int{},
+	}
+}
+
+type result struct {
+	Data   map[string]any `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+func execute(t *testing.T, handler *Handler, scopes []string, query string, variables map[string]any) result {
+	handler.scopes = func(*http.Request) []string { return scopes }
+	body, _ := json.Marshal(map[string]any{"query": query, "variables": variables})
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/graphql", bytes.NewReader(body)))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", recorder.Code, recorder.Body)
+	}
+
+	var got result
+	if err := json.Unmarshal(recorder.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	return got
+}
+
+// get значение по пути вида "employees.edges.0.node.name"
+func get(value any, path string) any {
+	for _, key := range strings.Split(path, ".") {
+		switch v := value.(type) {
+		case map[string]any:
+			value = v[key]
+		case []any:
+			index := 0
+			for _, c := range key {
+				index = index*10 + int(c-'0')
+			}
+			value = v[index]
+		}
+	}
+	return value
+}
+
+var readAll = []string{serviceaccount.ScopeEmployeesRead, serviceaccount.ScopeRolesRead}
+
+func TestHandler(t *testing.T) {
+	var assert = assertpackage.New(t)
+
+	t.Run("should resolve employees with roles and their other members in batches", func(t *testing.T) {
+		services := stub()
+		handler := NewHandler(StubEmployees{services}, StubRoles{services})
+
+		got := execute(t, handler, readAll, `{
+			employees(first: 3) {
+				totalCount
+				edges { node { userName manager { name } roles {
+					name permissions owner { userName }
+					members { totalCount edges { node { userName } } }
+				} } }
+			}
+		}`, nil)
+
+		assert.Empty(got.Errors)
+		assert.Equal(float64(4), get(got.Data, "employees.totalCount"))
+		assert.Equal("ivanov", get(got.Data, "employees.edges.0.node.userName"))
+		assert.Equal("Ivan", get(got.Data, "employees.edges.1.node.manager.name"))
+		assert.Equal("admins", get(got.Data, "employees.edges.1.node.roles.0.name"))
+		assert.Equal("smirnova", get(got.Data, "employees.edges.1.node.roles.0.owner.userName"))
+		assert.Equal([]any{"git:write"}, get(got.Data, "employees.edges.1.node.roles.1.permissions"))
+		assert.Equal(float64(3), get(got.Data, "employees.edges.1.node.roles.1.members.totalCount"))
+		assert.Equal("smirnova", get(got.Data, "employees.edges.2.node.roles.0.members.edges.2.node.userName"))
+		assert.Equal(map[string]int{"employees.FindPage": 1, "employees.Count": 1, "employees.FindByIds": 1,
+			"roles.FindAssignments": 2, "roles.FindByIds": 1}, services.calls)
+	})
+
+	t.Run("should batch lookups of employees that were not listed", func(t *testing.T) {
+		services := stub()
+		handler := NewHandler(StubEmployees{services}, StubRoles{services})
+
+		got := execute(t, handler, readAll, `{
+			a: role(id: "10") { members { edges { node { userName roles { name } } } } }
+			b: role(id: "11") { members { edges { node { userName } } } }
+		}`, nil)
+
+		assert.Empty(got.Errors)
+		assert.Equal("petrov", get(got.Data, "a.members.edges.1.node.userName"))
+		assert.Equal("developers", get(got.Data, "a.members.edges.1.node.roles.1.name"))
+		assert.Equal("smirnova", get(got.Data, "b.members.edges.2.node.userName"))
+		assert.LessOrEqual(services.calls["employees.FindByIds"], 2)
+		assert.LessOrEqual(services.calls["roles.FindAssignments"], 3)
+	})
+
+	t.Run("should page by cursor", func(t *testing.T) {
+		services := stub()
+		handler := NewHandler(StubEmployees{services}, StubRoles{services})
+		query := `query($after: String) {
+			employees(first: 3, after: $after) { edges { cursor node { id } } pageInfo { hasNextPage endCursor } }
+		}`
+
+		first := execute(t, handler, readAll, query, nil)
+		assert.Equal(true, get(first.Data, "employees.pageInfo.hasNextPage"))
+		assert.Equal("3", get(first.Data, "employees.edges.2.node.id"))
+		cursor := get(first.Data, "employees.pageInfo.endCursor")
+		assert.Equal(get(first.Data, "employees.edges.2.cursor"), cursor)
+
+		second := execute(t, handler, readAll, query, map[string]any{"after": cursor})
+		assert.Equal(false, get(second.Data, "employees.pageInfo.hasNextPage"))
+		assert.Len(get(second.Data, "employees.edges"), 1)
+		assert.Equal("4", get(second.Data, "employees.edges.0.node.id"))
+
+		invalid := execute(t, handler, readAll, query, map[string]any{"after": "bm90IGEgY3Vyc29y"})
+		assert.Equal(ErrInvalidCursor.Error(), invalid.Errors[0].Message)
+
+		tooLarge := execute(t, handler, readAll, `{ employees(first: 1000) { totalCount } }`, nil)
+		assert.Equal(ErrInvalidPageSize.Error(), tooLarge.Errors[0].Message)
+	})
+
+	t.Run("should reject too deep and too complex queries", func(t *testing.T) {
+		services := stub()
+		handler := NewHandler(StubEmployees{services}, StubRoles{services})
+
+		deep := execute(t, handler, readAll, `{ employee(id: "2") { manager { manager { manager { manager {
+			manager { manager { manager { manager { manager { manager { name } } } } } } } } } } } }`, nil)
+		assert.NotEmpty(deep.Errors)
+		assert.Nil(deep.Data)
+
+		complex := execute(t, handler, readAll, `{ employees(first: 100) { edges { node { roles {
+			members(first: 100) { edges { node { name } } } } } } } }`, nil)
+		assert.Contains(complex.Errors[0].Message, ErrTooComplex.Error())
+		assert.Empty(services.calls)
+	})
+
+	t.Run("should check scopes and hide internal errors", func(t *testing.T) {
+		services := stub()
+		handler := NewHandler(StubEmployees{services}, StubRoles{services})
+
+		got := execute(t, handler, []string{serviceaccount.ScopeEmployeesRead},
+			`{ employee(id: "1") { name roles { name } } }`, nil)
+		assert.Equal(ErrForbidden.Error()+" "+serviceaccount.ScopeRolesRead, got.Errors[0].Message)
+
+		got = execute(t, handler, readAll, `mutation { createRole(name: "auditors") { id } }`, nil)
+		assert.Equal(ErrForbidden.Error()+" "+serviceaccount.ScopeRolesWrite, got.Errors[0].Message)
+
+		got = execute(t, handler, readAll, `{ roles { totalCount } }`, nil)
+		assert.Equal("internal error", got.Errors[0].Message)
+
+		got = execute(t, handler, readAll, `{ employee(id: "42") { name } }`, nil)
+		assert.Empty(got.Errors)
+		assert.Nil(get(got.Data, "employee"))
+	})
+
+	t.Run("should create and remove with write scopes", func(t *testing.T) {
+		services := stub()
+		handler := NewHandler(StubEmployees{services}, StubRoles{services})
+		scopes := []string{serviceaccount.ScopeEmployeesWrite, serviceaccount.ScopeRolesWrite}
+
+		got := execute(t, handler, scopes, `mutation {
+			createEmployee(name: "Olga") { id name status }
+			removeEmployees(ids: ["3", "4"])
+		}`, nil)
+
+		assert.Empty(got.Errors)
+		assert.Equal("100", get(got.Data, "createEmployee.id"))
+		assert.Equal([]any{"3", "4"}, get(got.Data, "removeEmployees"))
+		assert.Equal([]int64{3, 4}, services.removed)
+
+		got = execute(t, handler, scopes, `mutation { createRole(name: " ") { id } }`, nil)
+		assert.Equal(ErrInvalidName.Error(), got.Errors[0].Message)
+	})
+
+	t.Run("should charge removals against the complexity limit", func(t *testing.T) {
+		services := stub()
+		handler := NewHandler(StubEmployees{services}, StubRoles{services})
+		scopes := []string{serviceaccount.ScopeEmployeesWrite}
+		ids := make([]string, MaxComplexity)
+		for i := range ids {
+			ids[i] = strconv.Itoa(i + 1)
+		}
+
+		got := execute(t, handler, scopes, `mutation($ids: [ID!]!) { removeEmployees(ids: $ids) }`,
+			map[string]any{"ids": ids})
+
+		assert.Contains(got.Errors[0].Message, ErrTooComplex.Error())
+		assert.Empty(services.removed)
+	})
+
+	t.Run("should serve the schema and reject other methods", func(t *testing.T) {
+		handler := NewHandler(StubEmployees{stub()}, StubRoles{stub()})
+
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/graphql", nil))
+		assert.Equal(http.StatusOK, recorder.Code)
+		assert.Contains(recorder.Body.String(), "type Employee {")
+
+		recorder = httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader("{")))
+		assert.Equal(http.StatusBadRequest, recorder.Code)
+
+		recorder = httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodDelete, "/graphql", nil))
+		assert.Equal(http.StatusMethodNotAllowed, recorder.Code)
+	})
+}
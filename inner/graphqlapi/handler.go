@@ -0,0 +1,88 @@
+package graphqlapi
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"github.com/graph-gophers/graphql-go"
+	"idm/inner/httpapi"
+	"idm/inner/serviceaccount"
+	"net/http"
+)
+
+//go:embed schema.graphql
+var Schema string
+
+// maxBodySize наибольший размер тела запроса
+const maxBodySize = 1 << 20
+
+// Handler GraphQL по HTTP: POST с телом {"query": …, "operationName": …, "variables": …},
+// GET отдаёт схему. Права проверяются по ключу служебной учётной записи, который пропустил RequireScope.
+type Handler struct {
+	schema    *graphql.Schema
+	employees Employees
+	roles     Roles
+	// scopes права ключа запроса
+	scopes func(r *http.Request) []string
+}
+
+func NewHandler(employees Employees, roles Roles) *Handler {
+	h := &Handler{
+		schema: graphql.MustParseSchema(Schema, &resolver{},
+			graphql.UseStringDescriptions(),
+			graphql.MaxDepth(MaxDepth),
+			graphql.MaxQueryLength(MaxQueryLength),
+		),
+		employees: employees,
+		roles:     roles,
+		scopes: func(r *http.Request) []string {
+			principal, _ := serviceaccount.FromContext(r.Context())
+			return principal.Scopes
+		},
+	}
+
+	return h
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		h.query(w, r)
+	case http.MethodGet:
+		h.schemaText(w, r)
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+type request struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName"`
+	Variables     map[string]any `json:"variables"`
+}
+
+func (h *Handler) query(w http.ResponseWriter, r *http.Request) {
+	var body request
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize)).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if body.Query == "" {
+		writeError(w, http.StatusBadRequest, "query is required")
+		return
+	}
+
+	ctx := context.WithValue(r.Context(), loaderKey{}, newLoader(h.employees, h.roles, h.scopes(r)))
+	httpapi.WriteJSON(w, http.StatusOK, h.schema.Exec(ctx, body.Query, body.OperationName, body.Variables))
+}
+
+func (h *Handler) schemaText(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	_, _ = w.Write([]byte(Schema))
+}
+
+// writeError ошибка в формате ответа GraphQL
+func writeError(w http.ResponseWriter, status int, message string) {
+	httpapi.WriteJSON(w, status, map[string]any{"errors": []map[string]string{{"message": message}}})
+}
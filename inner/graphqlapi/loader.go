@@ -0,0 +1,230 @@
+package graphqlapi
+
+import (
+	"idm/inner/employee"
+	"idm/inner/role"
+	"maps"
+	"slices"
+	"sync"
+)
+
+// loader данные одного запроса. Связи загружаются пачками: когда резолвер первым просит
+// роли сотрудника, одним запросом загружаются роли всех сотрудников, уже попавших в ответ.
+// Так глубина запроса, а не число узлов, определяет число обращений к сервисам.
+type loader struct {
+	employees Employees
+	roles     Roles
+	scopes    []string
+
+	mu sync.Mutex
+	// employeeById и roleById кеш; nil – записи нет
+	employeeById map[int64]*employee.Response
+	roleById     map[int64]*role.Response
+	rolesOf      map[int64][]int64
+	membersOf    map[int64][]int64
+	// want* идентификаторы из ответа, связи которых загрузятся со следующей пачкой
+	wantEmployees map[int64]bool
+	wantRoles     map[int64]bool
+	wantRolesOf   map[int64]bool
+	wantMembersOf map[int64]bool
+	// spent сложность, уже списанная корневыми полями запроса
+	spent int
+}
+
+func newLoader(employees Employees, roles Roles, scopes []string) *loader {
+	return &loader{
+		employees:     employees,
+		roles:         roles,
+		scopes:        scopes,
+		employeeById:  map[int64]*employee.Response{},
+		roleById:      map[int64]*role.Response{},
+		rolesOf:       map[int64][]int64{},
+		membersOf:     map[int64][]int64{},
+		wantEmployees: map[int64]bool{},
+		wantRoles:     map[int64]bool{},
+		wantRolesOf:   map[int64]bool{},
+		wantMembersOf: map[int64]bool{},
+	}
+}
+
+// employee резолвер сотрудника, который попадает в ответ
+func (l *loader) employee(response *employee.Response) *employeeResolver {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.employeeById[response.Id] = response
+	if _, ok := l.rolesOf[response.Id]; !ok {
+		l.wantRolesOf[response.Id] = true
+	}
+	if response.ManagerId != nil {
+		if _, ok := l.employeeById[*response.ManagerId]; !ok {
+			l.wantEmployees[*response.ManagerId] = true
+		}
+	}
+
+	return &employeeResolver{employee: response, loader: l}
+}
+
+// role резолвер роли, которая попадает в ответ
+func (l *loader) role(response *role.Response) *roleResolver {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.roleById[response.Id] = response
+	if _, ok := l.membersOf[response.Id]; !ok {
+		l.wantMembersOf[response.Id] = true
+	}
+	if response.OwnerId != nil {
+		if _, ok := l.employeeById[*response.OwnerId]; !ok {
+			l.wantEmployees[*response.OwnerId] = true
+		}
+	}
+
+	return &roleResolver{role: response, loader: l}
+}
+
+// findEmployees сотрудники ids в том же порядке; удалённые пропускаются
+func (l *loader) findEmployees(ids []int64) ([]*employee.Response, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, id := range ids {
+		if _, ok := l.employeeById[id]; !ok {
+			l.wantEmployees[id] = true
+		}
+	}
+	if len(l.wantEmployees) > 0 {
+		missing := slices.Sorted(maps.Keys(l.wantEmployees))
+		clear(l.wantEmployees)
+		found, err := l.employees.FindByIds(missing)
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range missing {
+			l.employeeById[id] = nil
+		}
+		for i := range found {
+			l.employeeById[found[i].Id] = &found[i]
+		}
+	}
+
+	result := make([]*employee.Response, 0, len(ids))
+	for _, id := range ids {
+		if found := l.employeeById[id]; found != nil {
+			result = append(result, found)
+		}
+	}
+
+	return result, nil
+}
+
+func (l *loader) findRoles(ids []int64) ([]*role.Response, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, id := range ids {
+		if _, ok := l.roleById[id]; !ok {
+			l.wantRoles[id] = true
+		}
+	}
+	if len(l.wantRoles) > 0 {
+		missing := slices.Sorted(maps.Keys(l.wantRoles))
+		clear(l.wantRoles)
+		found, err := l.roles.FindByIds(missing)
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range missing {
+			l.roleById[id] = nil
+		}
+		for i := range found {
+			l.roleById[found[i].Id] = &found[i]
+			if owner := found[i].OwnerId; owner != nil {
+				if _, cached := l.employeeById[*owner]; !cached {
+					l.wantEmployees[*owner] = true
+				}
+			}
+		}
+	}
+
+	result := make([]*role.Response, 0, len(ids))
+	for _, id := range ids {
+		if found := l.roleById[id]; found != nil {
+			result = append(result, found)
+		}
+	}
+
+	return result, nil
+}
+
+// roleIdsOf роли сотрудника; загружаются вместе с ролями остальных сотрудников ответа
+func (l *loader) roleIdsOf(employeeId int64) ([]int64, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if ids, ok := l.rolesOf[employeeId]; ok {
+		return ids, nil
+	}
+
+	l.wantRolesOf[employeeId] = true
+	employeeIds := slices.Sorted(maps.Keys(l.wantRolesOf))
+	clear(l.wantRolesOf)
+	assignments, err := l.roles.FindAssignments(employeeIds, nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range employeeIds {
+		l.rolesOf[id] = []int64{}
+	}
+	for _, assignment := range assignments {
+		if ids, ok := l.rolesOf[assignment.EmployeeId]; ok {
+			l.rolesOf[assignment.EmployeeId] = append(ids, assignment.RoleId)
+			// роли попадут в ответ: их самих и участников загрузим следующими пачками
+			if _, cached := l.roleById[assignment.RoleId]; !cached {
+				l.wantRoles[assignment.RoleId] = true
+			}
+			if _, loaded := l.membersOf[assignment.RoleId]; !loaded {
+				l.wantMembersOf[assignment.RoleId] = true
+			}
+		}
+	}
+
+	return l.rolesOf[employeeId], nil
+}
+
+// memberIdsOf участники роли по возрастанию идентификатора. page – страница, которую запросят
+// у каждой роли пачки: её участники загрузятся одним запросом с участниками этой роли.
+func (l *loader) memberIdsOf(roleId int64, page func(ids []int64) []int64) ([]int64, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if ids, ok := l.membersOf[roleId]; ok {
+		return ids, nil
+	}
+
+	l.wantMembersOf[roleId] = true
+	roleIds := slices.Sorted(maps.Keys(l.wantMembersOf))
+	clear(l.wantMembersOf)
+	assignments, err := l.roles.FindAssignments(nil, roleIds)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range roleIds {
+		l.membersOf[id] = []int64{}
+	}
+	for _, assignment := range assignments {
+		if ids, ok := l.membersOf[assignment.RoleId]; ok {
+			l.membersOf[assignment.RoleId] = append(ids, assignment.EmployeeId)
+		}
+	}
+	for _, id := range roleIds {
+		slices.Sort(l.membersOf[id])
+		for _, employeeId := range page(l.membersOf[id]) {
+			if _, cached := l.employeeById[employeeId]; !cached {
+				l.wantEmployees[employeeId] = true
+			}
+		}
+	}
+
+	return l.membersOf[roleId], nil
+}
@@ -0,0 +1,217 @@
+package graphqlapi
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/graph-gophers/graphql-go"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// MaxPageSize наибольшее значение аргумента first
+	MaxPageSize = 100
+	// MaxDepth наибольшая вложенность полей запроса
+	MaxDepth = 10
+	// MaxComplexity наибольшая оценка числа значений в ответе и удаляемых записей на один запрос
+	MaxComplexity = 10000
+	// MaxQueryLength наибольшая длина текста запроса
+	MaxQueryLength = 10000
+	// defaultPageSize значение first по умолчанию; совпадает с указанным в схеме
+	defaultPageSize = 50
+	// rolesPerEmployee сколько ролей у сотрудника предполагается при оценке сложности
+	rolesPerEmployee = 10
+	cursorPrefix     = "cursor:"
+)
+
+var (
+	ErrInvalidCursor   = errors.New("invalid cursor")
+	ErrInvalidPageSize = fmt.Errorf("first must be between 0 and %d", MaxPageSize)
+	ErrTooComplex      = errors.New("query is too complex")
+)
+
+// pageArgs постраничная выборка по курсору: first элементов после курсора after
+type pageArgs struct {
+	First int32
+	After *string
+}
+
+type pageInfo struct {
+	hasNextPage bool
+	endCursor   *string
+}
+
+func (p *pageInfo) HasNextPage() bool {
+	return p.hasNextPage
+}
+
+func (p *pageInfo) EndCursor() *string {
+	return p.endCursor
+}
+
+type employeeConnection struct {
+	edges    []*employeeEdge
+	pageInfo *pageInfo
+	total    int32
+}
+
+func (c *employeeConnection) Edges() []*employeeEdge {
+	return c.edges
+}
+
+func (c *employeeConnection) PageInfo() *pageInfo {
+	return c.pageInfo
+}
+
+func (c *employeeConnection) TotalCount() int32 {
+	return c.total
+}
+
+type employeeEdge struct {
+	cursor string
+	node   *employeeResolver
+}
+
+func (e *employeeEdge) Cursor() string {
+	return e.cursor
+}
+
+func (e *employeeEdge) Node() *employeeResolver {
+	return e.node
+}
+
+type roleConnection struct {
+	edges    []*roleEdge
+	pageInfo *pageInfo
+	total    int32
+}
+
+func (c *roleConnection) Edges() []*roleEdge {
+	return c.edges
+}
+
+func (c *roleConnection) PageInfo() *pageInfo {
+	return c.pageInfo
+}
+
+func (c *roleConnection) TotalCount() int32 {
+	return c.total
+}
+
+type roleEdge struct {
+	cursor string
+	node   *roleResolver
+}
+
+func (e *roleEdge) Cursor() string {
+	return e.cursor
+}
+
+func (e *roleEdge) Node() *roleResolver {
+	return e.node
+}
+
+// paginate страница идентификаторов ids, упорядоченных по возрастанию. Курсор – идентификатор
+// последнего элемента предыдущей страницы, поэтому удаление элементов не сдвигает страницы.
+func paginate(ids []int64, args pageArgs) ([]int64, bool, error) {
+	after, err := cursorAfter(args)
+	if err != nil {
+		return nil, false, err
+	}
+
+	start := sort.Search(len(ids), func(i int) bool { return ids[i] > after })
+	end := min(start+int(args.First), len(ids))
+
+	return ids[start:end], end < len(ids), nil
+}
+
+// cursorAfter проверить аргументы страницы и вернуть идентификатор, после которого она начинается;
+// 0 – с начала, идентификаторы положительны
+func cursorAfter(args pageArgs) (int64, error) {
+	if args.First < 0 || args.First > MaxPageSize {
+		return 0, ErrInvalidPageSize
+	}
+	if args.After == nil {
+		return 0, nil
+	}
+
+	return decodeCursor(*args.After)
+}
+
+// pageSize сколько элементов вернёт страница при оценке сложности
+func pageSize(args pageArgs) int {
+	return min(max(int(args.First), 0), MaxPageSize)
+}
+
+func encodeCursor(id int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(cursorPrefix + strconv.FormatInt(id, 10)))
+}
+
+func decodeCursor(cursor string) (int64, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, ErrInvalidCursor
+	}
+	value, found := strings.CutPrefix(string(decoded), cursorPrefix)
+	if !found {
+		return 0, ErrInvalidCursor
+	}
+	id, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, ErrInvalidCursor
+	}
+
+	return id, nil
+}
+
+// charge списать сложность корневого поля, которое вернёт size элементов. Каждое выбранное поле
+// стоит столько, сколько раз оно окажется в ответе: списки умножают стоимость вложенных полей
+// на first, а роли сотрудника – на rolesPerEmployee. Запрос целиком не может превысить MaxComplexity.
+func (l *loader) charge(ctx context.Context, size int) error {
+	counts := map[string]int{"": size}
+	cost := 1
+	// родительский путь всегда идёт раньше вложенных
+	for _, path := range graphql.SelectedFieldNames(ctx) {
+		parent, name := "", path
+		if i := strings.LastIndexByte(path, '.'); i >= 0 {
+			parent, name = path[:i], path[i+1:]
+		}
+		count := counts[parent]
+		cost += count
+		counts[path] = count * fieldSize(ctx, path, name)
+	}
+
+	return l.spend(cost)
+}
+
+// spend списать сложность cost. Мутации списывают её сами: удаление стоит по единице за запись.
+func (l *loader) spend(cost int) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.spent += cost
+	if l.spent > MaxComplexity {
+		return fmt.Errorf("%w: complexity %d exceeds the limit of %d", ErrTooComplex, l.spent, MaxComplexity)
+	}
+
+	return nil
+}
+
+// fieldSize сколько значений вернёт вложенное поле path для одного родителя
+func fieldSize(ctx context.Context, path string, name string) int {
+	switch name {
+	case "members":
+		var args struct{ First *int32 }
+		if ok, err := graphql.DecodeSelectedFieldArgs(ctx, path, &args); ok && err == nil && args.First != nil {
+			return pageSize(pageArgs{First: *args.First})
+		}
+		return defaultPageSize
+	case "roles":
+		return rolesPerEmployee
+	}
+
+	return 1
+}
@@ -0,0 +1,472 @@
+package graphqlapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/graph-gophers/graphql-go"
+	"idm/inner/database"
+	"idm/inner/employee"
+	"idm/inner/role"
+	"idm/inner/serviceaccount"
+	"log"
+	"strconv"
+	"strings"
+)
+
+type Employees interface {
+	FindByIds(ids []int64) ([]employee.Response, error)
+	FindPage(afterId int64, limit int) ([]employee.Response, error)
+	Count() (int64, error)
+	Create(name string) (employee.Response, error)
+	RemoveByIds(ids []int64) error
+}
+
+type Roles interface {
+	FindByIds(ids []int64) ([]role.Response, error)
+	FindPage(afterId int64, limit int) ([]role.Response, error)
+	Count() (int64, error)
+	FindAssignments(employeeIds []int64, roleIds []int64) ([]role.Assignment, error)
+	Create(name string) (role.Response, error)
+	RemoveByIds(ids []int64) error
+}
+
+var (
+	ErrInvalidId   = errors.New("invalid id")
+	ErrInvalidName = errors.New("name is required")
+	ErrForbidden   = errors.New("api key lacks scope")
+	errInternal    = errors.New("internal error")
+)
+
+type loaderKey struct{}
+
+func loaderFrom(ctx context.Context) *loader {
+	return ctx.Value(loaderKey{}).(*loader)
+}
+
+// resolver корневой резолвер запросов и мутаций
+type resolver struct{}
+
+type idArgs struct {
+	Id graphql.ID
+}
+
+type idsArgs struct {
+	Ids []graphql.ID
+}
+
+type nameArgs struct {
+	Name string
+}
+
+func (r *resolver) Employee(ctx context.Context, args idArgs) (*employeeResolver, error) {
+	l := loaderFrom(ctx)
+	if err := l.charge(ctx, 1); err != nil {
+		return nil, err
+	}
+	id, err := parseId(args.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	found, err := l.findEmployees([]int64{id})
+	if err != nil {
+		return nil, fail(err)
+	}
+	if len(found) == 0 {
+		return nil, nil
+	}
+
+	return l.employee(found[0]), nil
+}
+
+func (r *resolver) Employees(ctx context.Context, args pageArgs) (*employeeConnection, error) {
+	l := loaderFrom(ctx)
+	if err := l.charge(ctx, pageSize(args)); err != nil {
+		return nil, err
+	}
+
+	after, err := cursorAfter(args)
+	if err != nil {
+		return nil, err
+	}
+
+	// на одного больше, чтобы узнать, есть ли следующая страница
+	found, err := l.employees.FindPage(after, int(args.First)+1)
+	if err != nil {
+		return nil, fail(err)
+	}
+	total, err := l.employees.Count()
+	if err != nil {
+		return nil, fail(err)
+	}
+
+	hasNext := len(found) > int(args.First)
+	page := make([]*employee.Response, 0, len(found))
+	for i := range found[:min(len(found), int(args.First))] {
+		page = append(page, &found[i])
+	}
+
+	return l.employeeConnection(page, hasNext, total), nil
+}
+
+func (r *resolver) Role(ctx context.Context, args idArgs) (*roleResolver, error) {
+	l := loaderFrom(ctx)
+	if err := l.authorize(serviceaccount.ScopeRolesRead); err != nil {
+		return nil, err
+	}
+	if err := l.charge(ctx, 1); err != nil {
+		return nil, err
+	}
+	id, err := parseId(args.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	found, err := l.findRoles([]int64{id})
+	if err != nil {
+		return nil, fail(err)
+	}
+	if len(found) == 0 {
+		return nil, nil
+	}
+
+	return l.role(found[0]), nil
+}
+
+func (r *resolver) Roles(ctx context.Context, args pageArgs) (*roleConnection, error) {
+	l := loaderFrom(ctx)
+	if err := l.authorize(serviceaccount.ScopeRolesRead); err != nil {
+		return nil, err
+	}
+	if err := l.charge(ctx, pageSize(args)); err != nil {
+		return nil, err
+	}
+
+	after, err := cursorAfter(args)
+	if err != nil {
+		return nil, err
+	}
+
+	found, err := l.roles.FindPage(after, int(args.First)+1)
+	if err != nil {
+		return nil, fail(err)
+	}
+	total, err := l.roles.Count()
+	if err != nil {
+		return nil, fail(err)
+	}
+
+	hasNext := len(found) > int(args.First)
+	page := make([]*role.Response, 0, len(found))
+	for i := range found[:min(len(found), int(args.First))] {
+		page = append(page, &found[i])
+	}
+
+	return l.roleConnection(page, hasNext, total), nil
+}
+
+func (r *resolver) CreateEmployee(ctx context.Context, args nameArgs) (*employeeResolver, error) {
+	l := loaderFrom(ctx)
+	if err := l.authorize(serviceaccount.ScopeEmployeesWrite); err != nil {
+		return nil, err
+	}
+	if err := l.charge(ctx, 1); err != nil {
+		return nil, err
+	}
+	name := strings.TrimSpace(args.Name)
+	if name == "" {
+		return nil, ErrInvalidName
+	}
+
+	created, err := l.employees.Create(name)
+	if err != nil {
+		return nil, fail(err)
+	}
+
+	return l.employee(&created), nil
+}
+
+func (r *resolver) RemoveEmployees(ctx context.Context, args idsArgs) ([]graphql.ID, error) {
+	l := loaderFrom(ctx)
+	if err := l.authorize(serviceaccount.ScopeEmployeesWrite); err != nil {
+		return nil, err
+	}
+	ids, err := parseIds(args.Ids)
+	if err != nil {
+		return nil, err
+	}
+	if err := l.spend(1 + len(ids)); err != nil {
+		return nil, err
+	}
+	if err := l.employees.RemoveByIds(ids); err != nil {
+		return nil, fail(err)
+	}
+
+	return args.Ids, nil
+}
+
+func (r *resolver) CreateRole(ctx context.Context, args nameArgs) (*roleResolver, error) {
+	l := loaderFrom(ctx)
+	if err := l.authorize(serviceaccount.ScopeRolesWrite); err != nil {
+		return nil, err
+	}
+	if err := l.charge(ctx, 1); err != nil {
+		return nil, err
+	}
+	name := strings.TrimSpace(args.Name)
+	if name == "" {
+		return nil, ErrInvalidName
+	}
+
+	created, err := l.roles.Create(name)
+	if err != nil {
+		return nil, fail(err)
+	}
+
+	return l.role(&created), nil
+}
+
+func (r *resolver) RemoveRoles(ctx context.Context, args idsArgs) ([]graphql.ID, error) {
+	l := loaderFrom(ctx)
+	if err := l.authorize(serviceaccount.ScopeRolesWrite); err != nil {
+		return nil, err
+	}
+	ids, err := parseIds(args.Ids)
+	if err != nil {
+		return nil, err
+	}
+	if err := l.spend(1 + len(ids)); err != nil {
+		return nil, err
+	}
+	if err := l.roles.RemoveByIds(ids); err != nil {
+		return nil, fail(err)
+	}
+
+	return args.Ids, nil
+}
+
+type employeeResolver struct {
+	employee *employee.Response
+	loader   *loader
+}
+
+func (r *employeeResolver) ID() graphql.ID {
+	return formatId(r.employee.Id)
+}
+
+func (r *employeeResolver) Name() string {
+	return r.employee.Name
+}
+
+func (r *employeeResolver) UserName() string {
+	return r.employee.UserName
+}
+
+func (r *employeeResolver) Email() string {
+	return r.employee.Email
+}
+
+func (r *employeeResolver) Manager() (*employeeResolver, error) {
+	if r.employee.ManagerId == nil {
+		return nil, nil
+	}
+	found, err := r.loader.findEmployees([]int64{*r.employee.ManagerId})
+	if err != nil || len(found) == 0 {
+		return nil, fail(err)
+	}
+
+	return r.loader.employee(found[0]), nil
+}
+
+func (r *employeeResolver) Department() string {
+	return r.employee.Department
+}
+
+func (r *employeeResolver) Title() string {
+	return r.employee.Title
+}
+
+func (r *employeeResolver) Location() string {
+	return r.employee.Location
+}
+
+func (r *employeeResolver) EmploymentType() string {
+	return r.employee.EmploymentType
+}
+
+func (r *employeeResolver) Status() string {
+	return r.employee.Status
+}
+
+func (r *employeeResolver) TerminatedAt() *graphql.Time {
+	if r.employee.TerminatedAt == nil {
+		return nil
+	}
+
+	return &graphql.Time{Time: *r.employee.TerminatedAt}
+}
+
+func (r *employeeResolver) CreatedAt() graphql.Time {
+	return graphql.Time{Time: r.employee.CreatedAt}
+}
+
+func (r *employeeResolver) UpdatedAt() graphql.Time {
+	return graphql.Time{Time: r.employee.UpdatedAt}
+}
+
+func (r *employeeResolver) Roles() ([]*roleResolver, error) {
+	if err := r.loader.authorize(serviceaccount.ScopeRolesRead); err != nil {
+		return nil, err
+	}
+	ids, err := r.loader.roleIdsOf(r.employee.Id)
+	if err != nil {
+		return nil, fail(err)
+	}
+	roles, err := r.loader.findRoles(ids)
+	if err != nil {
+		return nil, fail(err)
+	}
+
+	resolvers := make([]*roleResolver, 0, len(roles))
+	for _, found := range roles {
+		resolvers = append(resolvers, r.loader.role(found))
+	}
+
+	return resolvers, nil
+}
+
+type roleResolver struct {
+	role   *role.Response
+	loader *loader
+}
+
+func (r *roleResolver) ID() graphql.ID {
+	return formatId(r.role.Id)
+}
+
+func (r *roleResolver) Name() string {
+	return r.role.Name
+}
+
+func (r *roleResolver) Description() string {
+	return r.role.Description
+}
+
+func (r *roleResolver) Owner() (*employeeResolver, error) {
+	if r.role.OwnerId == nil {
+		return nil, nil
+	}
+	found, err := r.loader.findEmployees([]int64{*r.role.OwnerId})
+	if err != nil || len(found) == 0 {
+		return nil, fail(err)
+	}
+
+	return r.loader.employee(found[0]), nil
+}
+
+func (r *roleResolver) Permissions() []string {
+	if r.role.Permissions == nil {
+		return []string{}
+	}
+
+	return r.role.Permissions
+}
+
+func (r *roleResolver) Members(args pageArgs) (*employeeConnection, error) {
+	ids, err := r.loader.memberIdsOf(r.role.Id, func(ids []int64) []int64 {
+		page, _, _ := paginate(ids, args)
+		return page
+	})
+	if err != nil {
+		return nil, fail(err)
+	}
+	page, hasNext, err := paginate(ids, args)
+	if err != nil {
+		return nil, err
+	}
+	employees, err := r.loader.findEmployees(page)
+	if err != nil {
+		return nil, fail(err)
+	}
+
+	return r.loader.employeeConnection(employees, hasNext, int64(len(ids))), nil
+}
+
+func (r *roleResolver) CreatedAt() graphql.Time {
+	return graphql.Time{Time: r.role.CreatedAt}
+}
+
+func (r *roleResolver) UpdatedAt() graphql.Time {
+	return graphql.Time{Time: r.role.UpdatedAt}
+}
+
+// authorize есть ли у ключа запроса право scope
+func (l *loader) authorize(scope string) error {
+	if !serviceaccount.Allows(l.scopes, scope) {
+		return fmt.Errorf("%w %s", ErrForbidden, scope)
+	}
+
+	return nil
+}
+
+// employeeConnection страница сотрудников, упорядоченных по возрастанию идентификатора, из total
+func (l *loader) employeeConnection(employees []*employee.Response, hasNext bool, total int64) *employeeConnection {
+	connection := &employeeConnection{edges: []*employeeEdge{}, pageInfo: &pageInfo{hasNextPage: hasNext}, total: int32(total)}
+	for _, found := range employees {
+		connection.edges = append(connection.edges, &employeeEdge{cursor: encodeCursor(found.Id), node: l.employee(found)})
+	}
+	if len(connection.edges) > 0 {
+		connection.pageInfo.endCursor = &connection.edges[len(connection.edges)-1].cursor
+	}
+
+	return connection
+}
+
+func (l *loader) roleConnection(roles []*role.Response, hasNext bool, total int64) *roleConnection {
+	connection := &roleConnection{edges: []*roleEdge{}, pageInfo: &pageInfo{hasNextPage: hasNext}, total: int32(total)}
+	for _, found := range roles {
+		connection.edges = append(connection.edges, &roleEdge{cursor: encodeCursor(found.Id), node: l.role(found)})
+	}
+	if len(connection.edges) > 0 {
+		connection.pageInfo.endCursor = &connection.edges[len(connection.edges)-1].cursor
+	}
+
+	return connection
+}
+
+func parseId(id graphql.ID) (int64, error) {
+	parsed, err := strconv.ParseInt(string(id), 10, 64)
+	if err != nil || parsed <= 0 {
+		return 0, fmt.Errorf("%w %q", ErrInvalidId, id)
+	}
+
+	return parsed, nil
+}
+
+func parseIds(ids []graphql.ID) ([]int64, error) {
+	parsed := make([]int64, 0, len(ids))
+	for _, id := range ids {
+		value, err := parseId(id)
+		if err != nil {
+			return nil, err
+		}
+		parsed = append(parsed, value)
+	}
+
+	return parsed, nil
+}
+
+func formatId(id int64) graphql.ID {
+	return graphql.ID(strconv.FormatInt(id, 10))
+}
+
+// fail ошибка сервиса для ответа; подробности внутренних ошибок клиенту не раскрываются
+func fail(err error) error {
+	if err == nil || errors.Is(err, database.ErrRecordNotFound) {
+		return err
+	}
+	log.Printf("error resolving graphql field: %v", err)
+
+	return errInternal
+}
@@ -0,0 +1,67 @@
+package group
+
+import "time"
+
+type Response struct {
+	Id          int64     `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Rule        string    `json:"rule,omitempty"`
+	MemberIds   []int64   `json:"member_ids"`
+	SubgroupIds []int64   `json:"subgroup_ids"`
+	RoleIds     []int64   `json:"role_ids"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+func (g *Group) ToResponse() Response {
+	return Response{
+		Id:          g.Id,
+		Name:        g.Name,
+		Description: g.Description,
+		Rule:        g.Rule,
+		MemberIds:   g.MemberIds,
+		SubgroupIds: g.SubgroupIds,
+		RoleIds:     g.RoleIds,
+		CreatedAt:   g.CreatedAt,
+		UpdatedAt:   g.UpdatedAt,
+	}
+}
+
+// Request данные группы. Rule – фильтр SCIM по атрибутам сотрудника, например
+// department eq "Finance" and status eq "active"; пустой – только явные участники.
+type Request struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Rule        string `json:"rule"`
+}
+
+// Способы, которыми сотрудник входит в группу
+const (
+	MembershipStatic  = "static"
+	MembershipDynamic = "dynamic"
+	MembershipNested  = "nested"
+)
+
+// MemberResponse участник группы. Membership – как он в неё входит; если способов несколько,
+// указывается первый из static, dynamic, nested.
+type MemberResponse struct {
+	Id         int64  `json:"id"`
+	Name       string `json:"name"`
+	UserName   string `json:"user_name"`
+	Status     string `json:"status"`
+	Membership string `json:"membership"`
+}
+
+// DiffResponse роли, которые сотрудник получает и теряет через группы
+type DiffResponse struct {
+	EmployeeId int64   `json:"employee_id"`
+	Name       string  `json:"name"`
+	Gain       []int64 `json:"gain,omitempty"`
+	Lose       []int64 `json:"lose,omitempty"`
+	Blocked    []int64 `json:"blocked,omitempty"`
+}
+
+func (d *DiffResponse) empty() bool {
+	return len(d.Gain) == 0 && len(d.Lose) == 0 && len(d.Blocked) == 0
+}
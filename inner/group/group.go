@@ -0,0 +1,464 @@
+package group
+
+import (
+	"errors"
+	"fmt"
+	"idm/inner/database"
+	"idm/inner/employee"
+	"idm/inner/role"
+	"idm/inner/scimfilter"
+	"slices"
+	"strings"
+)
+
+var (
+	ErrInvalidName = errors.New("group name is required")
+	ErrNameTaken   = errors.New("group name is already taken")
+	ErrInvalidRule = errors.New("invalid membership rule")
+	ErrCycle       = errors.New("group cannot contain itself or a group it belongs to")
+)
+
+type Repo interface {
+	FindAll() ([]*Group, error)
+	FindById(id int64) (*Group, error)
+	Create(group *Group) error
+	Update(group *Group) error
+	Remove(id int64) error
+	AddMember(id int64, employeeId int64) error
+	RemoveMember(id int64, employeeId int64) error
+	AddSubgroup(id int64, subgroupId int64) error
+	RemoveSubgroup(id int64, subgroupId int64) error
+	GrantRole(id int64, roleId int64) error
+	RevokeRole(id int64, roleId int64) error
+	FindEmployee(id int64) (*employee.Employee, error)
+	FindEmployees() ([]*employee.Employee, error)
+	FindActiveEmployees() ([]*employee.Employee, error)
+	FindAssignments(employeeId int64) ([]*Assignment, error)
+	FindAllAssignments() ([]*Assignment, error)
+	Sync(employeeId int64, grant []int64, revoke []int64) error
+}
+
+// Roles проверка существования выдаваемых группам ролей
+type Roles interface {
+	FindById(id int64) (role.Response, error)
+}
+
+// Service группы сотрудников: явное, динамическое по правилу и вложенное членство.
+// Сами группы прав не дают; роли, выданные группе, назначаются всем её участникам
+// с источником SourceGroup и отзываются, когда сотрудник перестаёт в неё входить.
+// Реализует employee.SaveHook, чтобы пересчитывать динамическое членство.
+type Service struct {
+	repo   Repo
+	roles  Roles
+	source *role.Source
+}
+
+func NewService(repository Repo, roles Roles) *Service {
+	return &Service{repo: repository, roles: roles, source: role.NewSource(SourceGroup, repository)}
+}
+
+// UseGuard добавить проверку, которая выполняется перед выдачей каждой роли
+func (s *Service) UseGuard(guard role.AssignmentGuard) {
+	s.source.UseGuard(guard)
+}
+
+// UseHook добавить обработчик, который вызывается после выдачи и отзыва унаследованной роли
+func (s *Service) UseHook(hook role.AssignmentHook) {
+	s.source.UseHook(hook)
+}
+
+func (s *Service) FindAll() ([]Response, error) {
+	groups, err := s.repo.FindAll()
+	if err != nil {
+		return nil, fmt.Errorf("error finding all groups: %w", err)
+	}
+
+	return toResponses(groups), nil
+}
+
+func (s *Service) FindById(id int64) (Response, error) {
+	group, err := s.repo.FindById(id)
+	if err != nil {
+		return Response{}, fmt.Errorf("error finding group with id %d: %w", id, err)
+	}
+
+	return group.ToResponse(), nil
+}
+
+// FindByEmployee группы, в которые сотрудник входит явно, по правилу или через вложенные группы
+func (s *Service) FindByEmployee(employeeId int64) ([]Response, error) {
+	empl, err := s.repo.FindEmployee(employeeId)
+	if err != nil {
+		return nil, fmt.Errorf("error finding employee with id %d: %w", employeeId, err)
+	}
+	groups, err := s.repo.FindAll()
+	if err != nil {
+		return nil, fmt.Errorf("error finding all groups: %w", err)
+	}
+
+	g := newGraph(groups)
+	var found []*Group
+	for _, id := range g.groupsOf(empl) {
+		found = append(found, g.groups[id])
+	}
+
+	return toResponses(found), nil
+}
+
+func (s *Service) Create(request Request) (Response, error) {
+	group := &Group{}
+	if err := s.validate(group, request); err != nil {
+		return Response{}, err
+	}
+
+	if err := s.repo.Create(group); err != nil {
+		return Response{}, fmt.Errorf("error creating group with name %q: %w", group.Name, err)
+	}
+
+	return group.ToResponse(), nil
+}
+
+// Update изменить группу; при смене правила роли участников пересчитываются
+func (s *Service) Update(id int64, request Request) (Response, error) {
+	group, err := s.repo.FindById(id)
+	if err != nil {
+		return Response{}, fmt.Errorf("error finding group with id %d: %w", id, err)
+	}
+	before, err := s.graph()
+	if err != nil {
+		return Response{}, err
+	}
+	rule := group.Rule
+	if err := s.validate(group, request); err != nil {
+		return Response{}, err
+	}
+
+	if err := s.repo.Update(group); err != nil {
+		return Response{}, fmt.Errorf("error updating group with id %d: %w", id, err)
+	}
+	if group.Rule != rule {
+		if err := s.evaluateMembers(id, before); err != nil {
+			return Response{}, err
+		}
+	}
+
+	return group.ToResponse(), nil
+}
+
+func (s *Service) validate(group *Group, request Request) error {
+	name := strings.TrimSpace(request.Name)
+	if name == "" {
+		return ErrInvalidName
+	}
+	rule := strings.TrimSpace(request.Rule)
+	if rule != "" {
+		if _, err := scimfilter.Parse(rule); err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalidRule, err)
+		}
+	}
+
+	groups, err := s.repo.FindAll()
+	if err != nil {
+		return fmt.Errorf("error finding all groups: %w", err)
+	}
+	if slices.ContainsFunc(groups, func(g *Group) bool { return g.Id != group.Id && strings.EqualFold(g.Name, name) }) {
+		return fmt.Errorf("%w: %s", ErrNameTaken, name)
+	}
+
+	group.Name, group.Description, group.Rule = name, request.Description, rule
+	return nil
+}
+
+// Remove удалить группу и отозвать роли, которые её участники получали только через неё
+func (s *Service) Remove(id int64) error {
+	if _, err := s.repo.FindById(id); err != nil {
+		return fmt.Errorf("error finding group with id %d: %w", id, err)
+	}
+	before, err := s.graph()
+	if err != nil {
+		return err
+	}
+
+	if err := s.repo.Remove(id); err != nil {
+		return fmt.Errorf("error removing group with id %d: %w", id, err)
+	}
+
+	return s.evaluateMembers(id, before)
+}
+
+// Members все участники группы с тем, как каждый из них в неё входит
+func (s *Service) Members(id int64) ([]MemberResponse, error) {
+	groups, err := s.repo.FindAll()
+	if err != nil {
+		return nil, fmt.Errorf("error finding all groups: %w", err)
+	}
+	g := newGraph(groups)
+	if _, ok := g.groups[id]; !ok {
+		return nil, fmt.Errorf("error finding group with id %d: %w", id, database.ErrRecordNotFound)
+	}
+
+	employees, err := s.repo.FindEmployees()
+	if err != nil {
+		return nil, fmt.Errorf("error finding employees: %w", err)
+	}
+
+	members := []MemberResponse{}
+	for _, empl := range employees {
+		if membership := g.membership(id, empl, attributesOf(empl)); membership != "" {
+			members = append(members, MemberResponse{
+				Id:         empl.Id,
+				Name:       empl.Name,
+				UserName:   empl.UserName,
+				Status:     empl.Status,
+				Membership: membership,
+			})
+		}
+	}
+
+	return members, nil
+}
+
+func (s *Service) AddMember(id int64, employeeId int64) error {
+	if err := s.findMember(id, employeeId); err != nil {
+		return err
+	}
+
+	if err := s.repo.AddMember(id, employeeId); err != nil {
+		return fmt.Errorf("error adding employee with id %d to group with id %d: %w", employeeId, id, err)
+	}
+	_, err := s.Evaluate(employeeId)
+
+	return err
+}
+
+func (s *Service) RemoveMember(id int64, employeeId int64) error {
+	if err := s.findMember(id, employeeId); err != nil {
+		return err
+	}
+
+	if err := s.repo.RemoveMember(id, employeeId); err != nil {
+		return fmt.Errorf("error removing employee with id %d from group with id %d: %w", employeeId, id, err)
+	}
+	_, err := s.Evaluate(employeeId)
+
+	return err
+}
+
+func (s *Service) findMember(id int64, employeeId int64) error {
+	if _, err := s.repo.FindById(id); err != nil {
+		return fmt.Errorf("error finding group with id %d: %w", id, err)
+	}
+	if _, err := s.repo.FindEmployee(employeeId); err != nil {
+		return fmt.Errorf("error finding employee with id %d: %w", employeeId, err)
+	}
+
+	return nil
+}
+
+// AddSubgroup вложить группу: её участники становятся участниками группы id.
+// Группа не может содержать саму себя ни напрямую, ни через другие группы.
+func (s *Service) AddSubgroup(id int64, subgroupId int64) error {
+	g, err := s.graph()
+	if err != nil {
+		return err
+	}
+	for _, groupId := range []int64{id, subgroupId} {
+		if _, ok := g.groups[groupId]; !ok {
+			return fmt.Errorf("error finding group with id %d: %w", groupId, database.ErrRecordNotFound)
+		}
+	}
+	if id == subgroupId || g.contains(subgroupId, id) {
+		return fmt.Errorf("%w: %d in %d", ErrCycle, subgroupId, id)
+	}
+
+	if err := s.repo.AddSubgroup(id, subgroupId); err != nil {
+		return fmt.Errorf("error adding group with id %d to group with id %d: %w", subgroupId, id, err)
+	}
+
+	return s.evaluateMembers(id, g)
+}
+
+func (s *Service) RemoveSubgroup(id int64, subgroupId int64) error {
+	if _, err := s.repo.FindById(id); err != nil {
+		return fmt.Errorf("error finding group with id %d: %w", id, err)
+	}
+	before, err := s.graph()
+	if err != nil {
+		return err
+	}
+
+	if err := s.repo.RemoveSubgroup(id, subgroupId); err != nil {
+		return fmt.Errorf("error removing group with id %d from group with id %d: %w", subgroupId, id, err)
+	}
+
+	return s.evaluateMembers(id, before)
+}
+
+// GrantRole выдать роль группе: её получат все участники, кроме тех, кому её не позволяют выдать проверки
+func (s *Service) GrantRole(id int64, roleId int64) error {
+	if _, err := s.repo.FindById(id); err != nil {
+		return fmt.Errorf("error finding group with id %d: %w", id, err)
+	}
+	if _, err := s.roles.FindById(roleId); err != nil {
+		return fmt.Errorf("error finding role with id %d: %w", roleId, err)
+	}
+	before, err := s.graph()
+	if err != nil {
+		return err
+	}
+
+	if err := s.repo.GrantRole(id, roleId); err != nil {
+		return fmt.Errorf("error granting role with id %d to group with id %d: %w", roleId, id, err)
+	}
+
+	return s.evaluateMembers(id, before)
+}
+
+func (s *Service) RevokeRole(id int64, roleId int64) error {
+	if _, err := s.repo.FindById(id); err != nil {
+		return fmt.Errorf("error finding group with id %d: %w", id, err)
+	}
+	before, err := s.graph()
+	if err != nil {
+		return err
+	}
+
+	if err := s.repo.RevokeRole(id, roleId); err != nil {
+		return fmt.Errorf("error revoking role with id %d from group with id %d: %w", roleId, id, err)
+	}
+
+	return s.evaluateMembers(id, before)
+}
+
+// AfterSave пересчитать группы и унаследованные роли созданного или изменённого сотрудника
+func (s *Service) AfterSave(saved employee.Response) error {
+	if saved.Status == employee.StatusDisabled {
+		return nil
+	}
+
+	_, err := s.Evaluate(saved.Id)
+
+	return err
+}
+
+// AfterAssignmentChange пересчитать унаследованные роли сотрудника после выдачи или отзыва роли
+// другим автоматическим источником. У сотрудника одна запись на роль: группа не выдаёт роль,
+// которую он уже получил иначе, и выдаёт её, когда тот источник роль отозвал.
+func (s *Service) AfterAssignmentChange(employeeId int64, roleId int64) error {
+	_, err := s.Evaluate(employeeId)
+
+	return err
+}
+
+// Evaluate привести унаследованные от групп роли сотрудника в соответствие с его членством.
+// Роли уволенных сотрудников не меняются: их отзывает увольнение.
+func (s *Service) Evaluate(employeeId int64) (DiffResponse, error) {
+	groups, err := s.repo.FindAll()
+	if err != nil {
+		return DiffResponse{}, fmt.Errorf("error finding all groups: %w", err)
+	}
+
+	empl, err := s.repo.FindEmployee(employeeId)
+	if err != nil {
+		return DiffResponse{}, fmt.Errorf("error finding employee with id %d: %w", employeeId, err)
+	}
+	if empl.Status == employee.StatusDisabled {
+		return DiffResponse{EmployeeId: empl.Id, Name: empl.Name}, nil
+	}
+
+	assignments, err := s.repo.FindAssignments(employeeId)
+	if err != nil {
+		return DiffResponse{}, fmt.Errorf("error finding roles of employee with id %d: %w", employeeId, err)
+	}
+
+	return s.apply(empl, newGraph(groups).rolesOf(empl), assignments)
+}
+
+// EvaluateAll пересчитать унаследованные роли всех работающих сотрудников.
+// Возвращает только тех, у кого что-то изменилось.
+func (s *Service) EvaluateAll() ([]DiffResponse, error) {
+	groups, err := s.repo.FindAll()
+	if err != nil {
+		return nil, fmt.Errorf("error finding all groups: %w", err)
+	}
+	employees, err := s.repo.FindActiveEmployees()
+	if err != nil {
+		return nil, fmt.Errorf("error finding active employees: %w", err)
+	}
+	assignments, err := s.repo.FindAllAssignments()
+	if err != nil {
+		return nil, fmt.Errorf("error finding role assignments: %w", err)
+	}
+
+	byEmployee := map[int64][]*Assignment{}
+	for _, assignment := range assignments {
+		byEmployee[assignment.EmployeeId] = append(byEmployee[assignment.EmployeeId], assignment)
+	}
+
+	g := newGraph(groups)
+	var diffs []DiffResponse
+	for _, empl := range employees {
+		diff, err := s.apply(empl, g.rolesOf(empl), byEmployee[empl.Id])
+		if err != nil {
+			return diffs, err
+		}
+		if !diff.empty() {
+			diffs = append(diffs, diff)
+		}
+	}
+
+	return diffs, nil
+}
+
+// evaluateMembers пересчитать унаследованные роли сотрудников, которые входили в группу id до изменения
+// или входят в неё после: роли остальных изменение группы не затрагивает
+func (s *Service) evaluateMembers(id int64, before *graph) error {
+	after, err := s.graph()
+	if err != nil {
+		return err
+	}
+	employees, err := s.repo.FindActiveEmployees()
+	if err != nil {
+		return fmt.Errorf("error finding active employees: %w", err)
+	}
+
+	for _, empl := range employees {
+		attributes := attributesOf(empl)
+		if before.membership(id, empl, attributes) == "" && after.membership(id, empl, attributes) == "" {
+			continue
+		}
+		assignments, err := s.repo.FindAssignments(empl.Id)
+		if err != nil {
+			return fmt.Errorf("error finding roles of employee with id %d: %w", empl.Id, err)
+		}
+		if _, err := s.apply(empl, after.rolesOf(empl), assignments); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *Service) graph() (*graph, error) {
+	groups, err := s.repo.FindAll()
+	if err != nil {
+		return nil, fmt.Errorf("error finding all groups: %w", err)
+	}
+
+	return newGraph(groups), nil
+}
+
+func (s *Service) apply(empl *employee.Employee, desired []int64, assignments []*Assignment) (DiffResponse, error) {
+	diff, err := s.source.Apply(empl.Id, desired, assignments)
+
+	return DiffResponse{EmployeeId: empl.Id, Name: empl.Name, Gain: diff.Gain, Lose: diff.Lose, Blocked: diff.Blocked}, err
+}
+
+func toResponses(groups []*Group) []Response {
+	responses := make([]Response, 0, len(groups))
+	for _, group := range groups {
+		responses = append(responses, group.ToResponse())
+	}
+
+	return responses
+}
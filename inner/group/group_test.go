@@ -0,0 +1,434 @@
+package group
+
+import (
+	"encoding/json"
+	"errors"
+	assertpackage "github.com/stretchr/testify/assert"
+	"idm/inner/database"
+	"idm/inner/employee"
+	"idm/inner/role"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// StubRepo группы, сотрудники и назначения ролей в памяти
+type StubRepo struct {
+	groups      []*Group
+	employees   []*employee.Employee
+	assignments []*Assignment
+}
+
+// FindAll копии групп, как их вернула бы база: изменения не видны в прочитанных раньше
+func (r *StubRepo) FindAll() ([]*Group, error) {
+	groups := make([]*Group, 0, len(r.groups))
+	for _, group := range r.groups {
+		copied := *group
+		copied.MemberIds = slices.Clone(group.MemberIds)
+		copied.SubgroupIds = slices.Clone(group.SubgroupIds)
+		copied.RoleIds = slices.Clone(group.RoleIds)
+		groups = append(groups, &copied)
+	}
+	return groups, nil
+}
+
+func (r *StubRepo) FindById(id int64) (*Group, error) {
+	for _, group := range r.groups {
+		if group.Id == id {
+			copied := *group
+			return &copied, nil
+		}
+	}
+	return nil, database.ErrRecordNotFound
+}
+
+func (r *StubRepo) Create(group *Group) error {
+	group.Id = int64(len(r.groups) + 1)
+	r.groups = append(r.groups, group)
+	return nil
+}
+
+func (r *StubRepo) Update(group *Group) error {
+	for i, existing := range r.groups {
+		if existing.Id == group.Id {
+			r.groups[i] = group
+		}
+	}
+	return nil
+}
+
+func (r *StubRepo) Remove(id int64) error {
+	r.groups = slices.DeleteFunc(r.groups, func(g *Group) bool { return g.Id == id })
+	for _, group := range r.groups {
+		group.SubgroupIds = slices.DeleteFunc(group.SubgroupIds, func(subgroupId int64) bool { return subgroupId == id })
+	}
+	return nil
+}
+
+func (r *StubRepo) group(id int64) *Group {
+	return r.groups[slices.IndexFunc(r.groups, func(g *Group) bool { return g.Id == id })]
+}
+
+func (r *StubRepo) AddMember(id int64, employeeId int64) error {
+	r.group(id).MemberIds = append(r.group(id).MemberIds, employeeId)
+	return nil
+}
+
+func (r *StubRepo) RemoveMember(id int64, employeeId int64) error {
+	r.group(id).MemberIds = slices.DeleteFunc(r.group(id).MemberIds, func(m int64) bool { return m == employeeId })
+	return nil
+}
+
+func (r *StubRepo) AddSubgroup(id int64, subgroupId int64) error {
+	r.group(id).SubgroupIds = append(r.group(id).SubgroupIds, subgroupId)
+	return nil
+}
+
+func (r *StubRepo) RemoveSubgroup(id int64, subgroupId int64) error {
+	r.group(id).SubgroupIds = slices.DeleteFunc(r.group(id).SubgroupIds, func(s int64) bool { return s == subgroupId })
+	return nil
+}
+
+func (r *StubRepo) GrantRole(id int64, roleId int64) error {
+	r.group(id).RoleIds = append(r.group(id).RoleIds, roleId)
+	return nil
+}
+
+func (r *StubRepo) RevokeRole(id int64, roleId int64) error {
+	r.group(id).RoleIds = slices.DeleteFunc(r.group(id).RoleIds, func(g int64) bool { return g == roleId })
+	return nil
+}
+
+func (r *StubRepo) FindEmployee(id int64) (*employee.Employee, error) {
+	for _, empl := range r.employees {
+		if empl.Id == id {
+			return empl, nil
+		}
+	}
+	return nil, database.ErrRecordNotFound
+}
+
+func (r *StubRepo) FindEmployees() ([]*employee.Employee, error) {
+	return r.employees, nil
+}
+
+func (r *StubRepo) FindActiveEmployees() ([]*employee.Employee, error) {
+	return slices.DeleteFunc(slices.Clone(r.employees), func(e *employee.Employee) bool {
+		return e.Status != employee.StatusActive
+	}), nil
+}
+
+func (r *StubRepo) FindAssignments(employeeId int64) ([]*Assignment, error) {
+	var assignments []*Assignment
+	for _, assignment := range r.assignments {
+		if assignment.EmployeeId == employeeId {
+			assignments = append(assignments, assignment)
+		}
+	}
+	return assignments, nil
+}
+
+func (r *StubRepo) FindAllAssignments() ([]*Assignment, error) {
+	return r.assignments, nil
+}
+
+func (r *StubRepo) Sync(employeeId int64, grant []int64, revoke []int64) error {
+	for _, roleId := range grant {
+		r.assignments = append(r.assignments, &Assignment{EmployeeId: employeeId, RoleId: roleId, Source: SourceGroup})
+	}
+	r.assignments = slices.DeleteFunc(r.assignments, func(a *Assignment) bool {
+		return a.EmployeeId == employeeId && a.Source == SourceGroup && slices.Contains(revoke, a.RoleId)
+	})
+	return nil
+}
+
+// roles роли сотрудника с их источниками
+func (r *StubRepo) roles(employeeId int64) map[int64]string {
+	roles := map[int64]string{}
+	for _, assignment := range r.assignments {
+		if assignment.EmployeeId == employeeId {
+			roles[assignment.RoleId] = assignment.Source
+		}
+	}
+	return roles
+}
+
+type StubRoles struct{}
+
+func (StubRoles) FindById(id int64) (role.Response, error) {
+	if id < 100 {
+		return role.Response{Id: id}, nil
+	}
+	return role.Response{}, database.ErrRecordNotFound
+}
+
+// StubGuard запрещает выдавать роль 13
+type StubGuard struct{}
+
+func (StubGuard) CheckAssignment(employeeId int64, roleId int64, pending []int64) error {
+	if roleId == 13 {
+		return errors.New("conflicting roles")
+	}
+	return nil
+}
+
+type GuardFunc func(employeeId int64, roleId int64, pending []int64) error
+
+func (f GuardFunc) CheckAssignment(employeeId int64, roleId int64, pending []int64) error {
+	return f(employeeId, roleId, pending)
+}
+
+type RecordingHook struct {
+	changes [][2]int64
+}
+
+func (h *RecordingHook) AfterAssignmentChange(employeeId int64, roleId int64) error {
+	h.changes = append(h.changes, [2]int64{employeeId, roleId})
+	return nil
+}
+
+// newRepo Engineering(1) ⊃ Backend(2) ⊃ On-call(3); Finance(4) – по правилу
+func newRepo() *StubRepo {
+	return &StubRepo{
+		groups: []*Group{
+			{Id: 1, Name: "Engineering", MemberIds: []int64{1}, SubgroupIds: []int64{2}},
+			{Id: 2, Name: "Backend", SubgroupIds: []int64{3}},
+			{Id: 3, Name: "On-call", MemberIds: []int64{2}},
+			{Id: 4, Name: "Finance", Rule: `department eq "Finance" and status eq "active"`},
+		},
+		employees: []*employee.Employee{
+			{Id: 1, Name: "Ivanov Ivan", Department: "Engineering", Status: employee.StatusActive},
+			{Id: 2, Name: "Petrov Petr", Department: "Engineering", Status: employee.StatusActive},
+			{Id: 3, Name: "Sidorova Anna", Department: "Finance", Status: employee.StatusActive},
+			{Id: 4, Name: "Smirnov Oleg", Department: "Finance", Status: employee.StatusDisabled},
+		},
+	}
+}
+
+func TestMembership(t *testing.T) {
+	var assert = assertpackage.New(t)
+
+	t.Run("should resolve static, dynamic and nested membership", func(t *testing.T) {
+		service := NewService(newRepo(), StubRoles{})
+
+		members, err := service.Members(1)
+		assert.Nil(err)
+		assert.Equal([]MemberResponse{
+			{Id: 1, Name: "Ivanov Ivan", Status: employee.StatusActive, Membership: MembershipStatic},
+			{Id: 2, Name: "Petrov Petr", Status: employee.StatusActive, Membership: MembershipNested},
+		}, members)
+
+		members, err = service.Members(4)
+		assert.Nil(err)
+		assert.Len(members, 1)
+		assert.Equal(MembershipDynamic, members[0].Membership)
+
+		_, err = service.Members(42)
+		assert.True(errors.Is(err, database.ErrRecordNotFound))
+	})
+
+	t.Run("should find groups of an employee", func(t *testing.T) {
+		service := NewService(newRepo(), StubRoles{})
+
+		groups, err := service.FindByEmployee(2)
+		assert.Nil(err)
+		assert.Equal([]string{"Engineering", "Backend", "On-call"}, names(groups))
+
+		groups, err = service.FindByEmployee(4)
+		assert.Nil(err)
+		assert.Empty(groups)
+	})
+
+	t.Run("should stop on cycles that are already in the data", func(t *testing.T) {
+		repo := newRepo()
+		repo.group(3).SubgroupIds = []int64{1}
+		g := newGraph(repo.groups)
+
+		assert.Equal(MembershipNested, g.membership(3, repo.employees[0], attributesOf(repo.employees[0])))
+		assert.Equal("", g.membership(3, repo.employees[2], attributesOf(repo.employees[2])))
+		assert.True(g.contains(3, 3))
+	})
+}
+
+func TestGroupService(t *testing.T) {
+	var assert = assertpackage.New(t)
+
+	t.Run("should validate groups", func(t *testing.T) {
+		service := NewService(newRepo(), StubRoles{})
+
+		_, err := service.Create(Request{Name: " "})
+		assert.True(errors.Is(err, ErrInvalidName))
+
+		_, err = service.Create(Request{Name: "backend"})
+		assert.True(errors.Is(err, ErrNameTaken))
+
+		_, err = service.Create(Request{Name: "Sales", Rule: `department eq`})
+		assert.True(errors.Is(err, ErrInvalidRule))
+
+		created, err := service.Create(Request{Name: " Sales ", Rule: ` department eq "Sales" `})
+		assert.Nil(err)
+		assert.Equal("Sales", created.Name)
+		assert.Equal(`department eq "Sales"`, created.Rule)
+
+		_, err = service.Update(2, Request{Name: "Backend", Description: "Server side"})
+		assert.Nil(err)
+	})
+
+	t.Run("should not nest a group into itself or its subgroup", func(t *testing.T) {
+		repo := newRepo()
+		service := NewService(repo, StubRoles{})
+
+		assert.True(errors.Is(service.AddSubgroup(1, 1), ErrCycle))
+		assert.True(errors.Is(service.AddSubgroup(3, 1), ErrCycle))
+		assert.True(errors.Is(service.AddSubgroup(2, 1), ErrCycle))
+		assert.True(errors.Is(service.AddSubgroup(1, 42), database.ErrRecordNotFound))
+
+		assert.Nil(service.AddSubgroup(4, 3))
+		assert.Equal([]int64{3}, []int64(repo.group(4).SubgroupIds))
+		assert.True(errors.Is(service.AddSubgroup(3, 4), ErrCycle))
+	})
+
+	t.Run("should grant group roles to every member and revoke them when membership ends", func(t *testing.T) {
+		repo := newRepo()
+		repo.assignments = []*Assignment{{EmployeeId: 2, RoleId: 11, Source: "manual"}}
+		hook := &RecordingHook{}
+		service := NewService(repo, StubRoles{})
+		service.UseGuard(StubGuard{})
+		service.UseHook(hook)
+
+		assert.True(errors.Is(service.GrantRole(1, 100), database.ErrRecordNotFound))
+		assert.Nil(service.GrantRole(1, 11))
+		assert.Nil(service.GrantRole(1, 13))
+		assert.Nil(service.GrantRole(4, 12))
+
+		assert.Equal(map[int64]string{11: SourceGroup}, repo.roles(1))
+		assert.Equal(map[int64]string{11: "manual"}, repo.roles(2))
+		assert.Equal(map[int64]string{12: SourceGroup}, repo.roles(3))
+		assert.Empty(repo.roles(4))
+		assert.Equal([][2]int64{{1, 11}, {3, 12}}, hook.changes)
+
+		// сотрудник 2 выходит из вложенной группы: ручная роль остаётся
+		assert.Nil(service.RemoveMember(3, 2))
+		assert.Equal(map[int64]string{11: "manual"}, repo.roles(2))
+
+		assert.Nil(service.RemoveSubgroup(1, 2))
+		assert.Nil(service.AddMember(2, 1))
+		assert.Equal(map[int64]string{11: SourceGroup}, repo.roles(1))
+
+		assert.Nil(service.Remove(1))
+		assert.Empty(repo.roles(1))
+	})
+
+	t.Run("should check group roles granted in one pass against each other", func(t *testing.T) {
+		repo := newRepo()
+		service := NewService(repo, StubRoles{})
+		// роли 20 и 30 несовместимы
+		service.UseGuard(GuardFunc(func(employeeId int64, roleId int64, pending []int64) error {
+			if roleId == 30 && slices.Contains(pending, 20) || roleId == 20 && slices.Contains(pending, 30) {
+				return errors.New("conflicting roles")
+			}
+			return nil
+		}))
+		assert.Nil(repo.GrantRole(1, 20))
+		assert.Nil(repo.GrantRole(1, 30))
+
+		diff, err := service.Evaluate(1)
+
+		assert.Nil(err)
+		assert.Equal([]int64{20}, diff.Gain)
+		assert.Equal([]int64{30}, diff.Blocked)
+		assert.Equal(map[int64]string{20: SourceGroup}, repo.roles(1))
+	})
+
+	t.Run("should grant a role again after another source revoked it", func(t *testing.T) {
+		repo := newRepo()
+		repo.assignments = []*Assignment{{EmployeeId: 1, RoleId: 11, Source: "birthright"}}
+		service := NewService(repo, StubRoles{})
+
+		assert.Nil(service.GrantRole(1, 11))
+		assert.Equal(map[int64]string{11: "birthright"}, repo.roles(1))
+
+		// правило по умолчанию отозвало роль, которую группа всё ещё выдаёт
+		repo.assignments = nil
+		assert.Nil(service.AfterAssignmentChange(1, 11))
+		assert.Equal(map[int64]string{11: SourceGroup}, repo.roles(1))
+	})
+
+	t.Run("should re-evaluate only members of the changed group", func(t *testing.T) {
+		repo := newRepo()
+		// устаревшая роль группы, которую сотрудник 1 уже не должен получать
+		repo.assignments = []*Assignment{{EmployeeId: 1, RoleId: 99, Source: SourceGroup}}
+		service := NewService(repo, StubRoles{})
+
+		assert.Nil(service.GrantRole(4, 12))
+		assert.Nil(service.AddSubgroup(4, 3))
+		assert.Equal(map[int64]string{12: SourceGroup}, repo.roles(2))
+		assert.Equal(map[int64]string{12: SourceGroup}, repo.roles(3))
+		assert.Equal(map[int64]string{99: SourceGroup}, repo.roles(1))
+
+		assert.Nil(service.RemoveSubgroup(4, 3))
+		assert.Empty(repo.roles(2))
+		assert.Equal(map[int64]string{99: SourceGroup}, repo.roles(1))
+
+		assert.Nil(service.GrantRole(1, 11))
+		assert.Equal(map[int64]string{11: SourceGroup}, repo.roles(1))
+	})
+
+	t.Run("should follow rule changes and saved employees", func(t *testing.T) {
+		repo := newRepo()
+		service := NewService(repo, StubRoles{})
+		assert.Nil(service.GrantRole(4, 12))
+
+		repo.employees[2].Department = "Sales"
+		assert.Nil(service.AfterSave(*repo.employees[2].ToResponse()))
+		assert.Empty(repo.roles(3))
+
+		_, err := service.Update(4, Request{Name: "Finance", Rule: `department eq "Sales"`})
+		assert.Nil(err)
+		assert.Equal(map[int64]string{12: SourceGroup}, repo.roles(3))
+
+		diffs, err := service.EvaluateAll()
+		assert.Nil(err)
+		assert.Empty(diffs)
+	})
+}
+
+func TestGroupHandler(t *testing.T) {
+	var assert = assertpackage.New(t)
+
+	serve := func(method string, target string, body string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		NewHandler(NewService(newRepo(), StubRoles{})).ServeHTTP(recorder, httptest.NewRequest(method, target, strings.NewReader(body)))
+		return recorder
+	}
+
+	t.Run("should list groups of an employee", func(t *testing.T) {
+		recorder := serve(http.MethodGet, "/?employee_id=3", "")
+
+		assert.Equal(http.StatusOK, recorder.Code)
+		var groups []Response
+		assert.Nil(json.Unmarshal(recorder.Body.Bytes(), &groups))
+		assert.Equal([]string{"Finance"}, names(groups))
+		assert.Equal(http.StatusBadRequest, serve(http.MethodGet, "/?employee_id=x", "").Code)
+	})
+
+	t.Run("should map errors to statuses", func(t *testing.T) {
+		assert.Equal(http.StatusCreated, serve(http.MethodPost, "/", `{"name":"Sales"}`).Code)
+		assert.Equal(http.StatusBadRequest, serve(http.MethodPost, "/", `{"name":"Sales","rule":"("}`).Code)
+		assert.Equal(http.StatusConflict, serve(http.MethodPut, "/3/subgroups/1", "").Code)
+		assert.Equal(http.StatusNoContent, serve(http.MethodPut, "/4/members/1", "").Code)
+		assert.Equal(http.StatusNotFound, serve(http.MethodPut, "/4/members/42", "").Code)
+		assert.Equal(http.StatusBadRequest, serve(http.MethodDelete, "/4/roles/x", "").Code)
+		assert.Equal("[]\n", serve(http.MethodPost, "/evaluate", "").Body.String())
+	})
+}
+
+func names(groups []Response) []string {
+	var names []string
+	for _, group := range groups {
+		names = append(names, group.Name)
+	}
+	return names
+}
@@ -0,0 +1,163 @@
+package group
+
+import (
+	"idm/inner/httpapi"
+	"net/http"
+	"strconv"
+)
+
+// statuses коды ответа для ошибок сервиса
+var statuses = httpapi.Statuses{
+	{Err: ErrInvalidName, Code: http.StatusBadRequest},
+	{Err: ErrInvalidRule, Code: http.StatusBadRequest},
+	{Err: ErrNameTaken, Code: http.StatusConflict},
+	{Err: ErrCycle, Code: http.StatusConflict},
+}
+
+// Handler группы, их участники, вложенные группы и роли
+type Handler struct {
+	service *Service
+	mux     *http.ServeMux
+}
+
+func NewHandler(service *Service) *Handler {
+	h := &Handler{service: service, mux: http.NewServeMux()}
+
+	h.mux.HandleFunc("GET /{$}", h.list)
+	h.mux.HandleFunc("POST /{$}", h.create)
+	h.mux.HandleFunc("POST /evaluate", h.evaluate)
+	h.mux.HandleFunc("GET /{id}", h.get)
+	h.mux.HandleFunc("PUT /{id}", h.update)
+	h.mux.HandleFunc("DELETE /{id}", h.remove)
+	h.mux.HandleFunc("GET /{id}/members", h.members)
+	h.mux.HandleFunc("PUT /{id}/members/{employeeId}", h.addMember)
+	h.mux.HandleFunc("DELETE /{id}/members/{employeeId}", h.removeMember)
+	h.mux.HandleFunc("PUT /{id}/subgroups/{subgroupId}", h.addSubgroup)
+	h.mux.HandleFunc("DELETE /{id}/subgroups/{subgroupId}", h.removeSubgroup)
+	h.mux.HandleFunc("PUT /{id}/roles/{roleId}", h.grantRole)
+	h.mux.HandleFunc("DELETE /{id}/roles/{roleId}", h.revokeRole)
+
+	return h
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+// list с employee_id – только группы, в которые входит сотрудник
+func (h *Handler) list(w http.ResponseWriter, r *http.Request) {
+	value := r.URL.Query().Get("employee_id")
+	if value == "" {
+		groups, err := h.service.FindAll()
+		httpapi.Write(w, http.StatusOK, groups, err, statuses)
+		return
+	}
+
+	employeeId, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		httpapi.WriteError(w, http.StatusBadRequest, "invalid employee_id")
+		return
+	}
+
+	groups, err := h.service.FindByEmployee(employeeId)
+	httpapi.Write(w, http.StatusOK, groups, err, statuses)
+}
+
+func (h *Handler) create(w http.ResponseWriter, r *http.Request) {
+	var request Request
+	if !httpapi.Decode(w, r, &request) {
+		return
+	}
+
+	group, err := h.service.Create(request)
+	httpapi.Write(w, http.StatusCreated, group, err, statuses)
+}
+
+func (h *Handler) evaluate(w http.ResponseWriter, r *http.Request) {
+	diffs, err := h.service.EvaluateAll()
+	if diffs == nil {
+		diffs = []DiffResponse{}
+	}
+	httpapi.Write(w, http.StatusOK, diffs, err, statuses)
+}
+
+func (h *Handler) get(w http.ResponseWriter, r *http.Request) {
+	id, ok := httpapi.PathId(w, r, "id")
+	if !ok {
+		return
+	}
+
+	group, err := h.service.FindById(id)
+	httpapi.Write(w, http.StatusOK, group, err, statuses)
+}
+
+func (h *Handler) update(w http.ResponseWriter, r *http.Request) {
+	id, ok := httpapi.PathId(w, r, "id")
+	if !ok {
+		return
+	}
+	var request Request
+	if !httpapi.Decode(w, r, &request) {
+		return
+	}
+
+	group, err := h.service.Update(id, request)
+	httpapi.Write(w, http.StatusOK, group, err, statuses)
+}
+
+func (h *Handler) remove(w http.ResponseWriter, r *http.Request) {
+	id, ok := httpapi.PathId(w, r, "id")
+	if !ok {
+		return
+	}
+
+	httpapi.Write(w, http.StatusNoContent, nil, h.service.Remove(id), statuses)
+}
+
+func (h *Handler) members(w http.ResponseWriter, r *http.Request) {
+	id, ok := httpapi.PathId(w, r, "id")
+	if !ok {
+		return
+	}
+
+	members, err := h.service.Members(id)
+	httpapi.Write(w, http.StatusOK, members, err, statuses)
+}
+
+func (h *Handler) addMember(w http.ResponseWriter, r *http.Request) {
+	h.link(w, r, "employeeId", h.service.AddMember)
+}
+
+func (h *Handler) removeMember(w http.ResponseWriter, r *http.Request) {
+	h.link(w, r, "employeeId", h.service.RemoveMember)
+}
+
+func (h *Handler) addSubgroup(w http.ResponseWriter, r *http.Request) {
+	h.link(w, r, "subgroupId", h.service.AddSubgroup)
+}
+
+func (h *Handler) removeSubgroup(w http.ResponseWriter, r *http.Request) {
+	h.link(w, r, "subgroupId", h.service.RemoveSubgroup)
+}
+
+func (h *Handler) grantRole(w http.ResponseWriter, r *http.Request) {
+	h.link(w, r, "roleId", h.service.GrantRole)
+}
+
+func (h *Handler) revokeRole(w http.ResponseWriter, r *http.Request) {
+	h.link(w, r, "roleId", h.service.RevokeRole)
+}
+
+// link связать группу с сотрудником, группой или ролью из параметра пути name
+func (h *Handler) link(w http.ResponseWriter, r *http.Request, name string, change func(id int64, otherId int64) error) {
+	id, ok := httpapi.PathId(w, r, "id")
+	if !ok {
+		return
+	}
+	otherId, ok := httpapi.PathId(w, r, name)
+	if !ok {
+		return
+	}
+
+	httpapi.Write(w, http.StatusNoContent, nil, change(id, otherId), statuses)
+}
@@ -0,0 +1,130 @@
+package group
+
+import (
+	"encoding/json"
+	"idm/inner/employee"
+	"idm/inner/scimfilter"
+	"slices"
+)
+
+// graph группы с разобранными правилами: по нему вычисляется, кто в какую группу входит
+type graph struct {
+	groups map[int64]*Group
+	rules  map[int64]scimfilter.Filter
+}
+
+func newGraph(groups []*Group) *graph {
+	g := &graph{groups: make(map[int64]*Group, len(groups)), rules: map[int64]scimfilter.Filter{}}
+	for _, group := range groups {
+		g.groups[group.Id] = group
+		if group.Rule == "" {
+			continue
+		}
+		// правило проверяется при сохранении; если оно всё же не разбирается, под него никто не подходит
+		if filter, err := scimfilter.Parse(group.Rule); err == nil {
+			g.rules[group.Id] = filter
+		}
+	}
+
+	return g
+}
+
+// membership как сотрудник входит в группу; пустая строка – не входит
+func (g *graph) membership(id int64, empl *employee.Employee, attributes map[string]any) string {
+	group, ok := g.groups[id]
+	switch {
+	case !ok:
+		return ""
+	case slices.Contains(group.MemberIds, empl.Id):
+		return MembershipStatic
+	case g.matches(id, attributes):
+		return MembershipDynamic
+	case g.nested(group, empl, attributes, map[int64]bool{id: true}):
+		return MembershipNested
+	}
+
+	return ""
+}
+
+// nested входит ли сотрудник в одну из вложенных групп на любом уровне.
+// visited обрывает обход на циклах, если они всё же оказались в данных.
+func (g *graph) nested(group *Group, empl *employee.Employee, attributes map[string]any, visited map[int64]bool) bool {
+	for _, subgroupId := range group.SubgroupIds {
+		subgroup, ok := g.groups[subgroupId]
+		if !ok || visited[subgroupId] {
+			continue
+		}
+		visited[subgroupId] = true
+		if slices.Contains(subgroup.MemberIds, empl.Id) || g.matches(subgroupId, attributes) ||
+			g.nested(subgroup, empl, attributes, visited) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (g *graph) matches(id int64, attributes map[string]any) bool {
+	filter, ok := g.rules[id]
+	return ok && filter.Match(attributes)
+}
+
+// groupsOf группы, в которые сотрудник входит любым способом, по возрастанию id
+func (g *graph) groupsOf(empl *employee.Employee) []int64 {
+	attributes := attributesOf(empl)
+
+	var ids []int64
+	for id := range g.groups {
+		if g.membership(id, empl, attributes) != "" {
+			ids = append(ids, id)
+		}
+	}
+	slices.Sort(ids)
+
+	return ids
+}
+
+// rolesOf отсортированные роли, положенные сотруднику через группы
+func (g *graph) rolesOf(empl *employee.Employee) []int64 {
+	var roleIds []int64
+	for _, id := range g.groupsOf(empl) {
+		roleIds = append(roleIds, g.groups[id].RoleIds...)
+	}
+	slices.Sort(roleIds)
+
+	return slices.Compact(roleIds)
+}
+
+// contains входит ли группа target в группу id на любом уровне вложенности
+func (g *graph) contains(id int64, target int64) bool {
+	visited := map[int64]bool{}
+	queue := []int64{id}
+	for len(queue) > 0 {
+		group, ok := g.groups[queue[0]]
+		queue = queue[1:]
+		if !ok {
+			continue
+		}
+		for _, subgroupId := range group.SubgroupIds {
+			if subgroupId == target {
+				return true
+			}
+			if !visited[subgroupId] {
+				visited[subgroupId] = true
+				queue = append(queue, subgroupId)
+			}
+		}
+	}
+
+	return false
+}
+
+// attributesOf сотрудник в том виде, в каком его видит правило: поля ответа API,
+// числа как float64, время как строка RFC 3339
+func attributesOf(empl *employee.Employee) map[string]any {
+	encoded, _ := json.Marshal(empl.ToResponse())
+	var attributes map[string]any
+	_ = json.Unmarshal(encoded, &attributes)
+
+	return attributes
+}
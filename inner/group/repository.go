@@ -0,0 +1,241 @@
+package group
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"idm/inner/database"
+	"idm/inner/employee"
+	"idm/inner/role"
+	"time"
+)
+
+// SourceGroup источник назначения роли, унаследованной от группы
+const SourceGroup = "group"
+
+// Group группа сотрудников. Участники – явно добавленные сотрудники, сотрудники,
+// подходящие под правило, и участники вложенных групп.
+type Group struct {
+	Id          int64         `db:"id"`
+	Name        string        `db:"name"`
+	Description string        `db:"description"`
+	Rule        string        `db:"rule"`
+	MemberIds   pq.Int64Array `db:"member_ids"`
+	SubgroupIds pq.Int64Array `db:"subgroup_ids"`
+	RoleIds     pq.Int64Array `db:"role_ids"`
+	CreatedAt   time.Time     `db:"created_at"`
+	UpdatedAt   time.Time     `db:"updated_at"`
+}
+
+// Assignment роль сотрудника и источник, из которого она была выдана
+type Assignment = role.SourcedAssignment
+
+const selectGroups = `SELECT g.*,
+	ARRAY(SELECT m.employee_id FROM group_members m WHERE m.group_id = g.id ORDER BY m.employee_id) AS member_ids,
+	ARRAY(SELECT s.subgroup_id FROM group_subgroups s WHERE s.group_id = g.id ORDER BY s.subgroup_id) AS subgroup_ids,
+	ARRAY(SELECT r.role_id FROM group_roles r WHERE r.group_id = g.id ORDER BY r.role_id) AS role_ids
+	FROM groups g`
+
+type Repository struct {
+	db *sqlx.DB
+}
+
+func NewRepository(db *sqlx.DB) *Repository {
+	return &Repository{db: db}
+}
+
+func (r *Repository) FindAll() ([]*Group, error) {
+	var groups []*Group
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := r.db.SelectContext(ctx, &groups, selectGroups+" ORDER BY g.id")
+
+	return groups, err
+}
+
+func (r *Repository) FindById(id int64) (*Group, error) {
+	var group Group
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := r.db.GetContext(ctx, &group, selectGroups+" WHERE g.id = $1", id)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, database.ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &group, err
+}
+
+func (r *Repository) Create(group *Group) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return r.db.QueryRowContext(ctx,
+		"INSERT INTO groups (name, description, rule) VALUES ($1, $2, $3) RETURNING id, created_at, updated_at",
+		group.Name, group.Description, group.Rule,
+	).Scan(&group.Id, &group.CreatedAt, &group.UpdatedAt)
+}
+
+func (r *Repository) Update(group *Group) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := r.db.QueryRowContext(ctx,
+		`UPDATE groups SET name = $1, description = $2, rule = $3, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $4 RETURNING created_at, updated_at`,
+		group.Name, group.Description, group.Rule, group.Id,
+	).Scan(&group.CreatedAt, &group.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.ErrRecordNotFound
+	}
+
+	return err
+}
+
+func (r *Repository) Remove(id int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := r.db.ExecContext(ctx, "DELETE FROM groups WHERE id = $1", id)
+
+	return err
+}
+
+func (r *Repository) AddMember(id int64, employeeId int64) error {
+	return r.exec("INSERT INTO group_members (group_id, employee_id) VALUES ($1, $2) ON CONFLICT DO NOTHING", id, employeeId)
+}
+
+func (r *Repository) RemoveMember(id int64, employeeId int64) error {
+	return r.exec("DELETE FROM group_members WHERE group_id = $1 AND employee_id = $2", id, employeeId)
+}
+
+func (r *Repository) AddSubgroup(id int64, subgroupId int64) error {
+	return r.exec("INSERT INTO group_subgroups (group_id, subgroup_id) VALUES ($1, $2) ON CONFLICT DO NOTHING", id, subgroupId)
+}
+
+func (r *Repository) RemoveSubgroup(id int64, subgroupId int64) error {
+	return r.exec("DELETE FROM group_subgroups WHERE group_id = $1 AND subgroup_id = $2", id, subgroupId)
+}
+
+func (r *Repository) GrantRole(id int64, roleId int64) error {
+	return r.exec("INSERT INTO group_roles (group_id, role_id) VALUES ($1, $2) ON CONFLICT DO NOTHING", id, roleId)
+}
+
+func (r *Repository) RevokeRole(id int64, roleId int64) error {
+	return r.exec("DELETE FROM group_roles WHERE group_id = $1 AND role_id = $2", id, roleId)
+}
+
+func (r *Repository) exec(query string, args ...any) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := r.db.ExecContext(ctx, query, args...)
+
+	return err
+}
+
+func (r *Repository) FindEmployee(id int64) (*employee.Employee, error) {
+	var empl employee.Employee
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := r.db.GetContext(ctx, &empl, "SELECT * FROM employees WHERE id = $1", id)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, database.ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &empl, err
+}
+
+// FindEmployees все сотрудники, включая уволенных
+func (r *Repository) FindEmployees() ([]*employee.Employee, error) {
+	var employees []*employee.Employee
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := r.db.SelectContext(ctx, &employees, "SELECT * FROM employees ORDER BY id")
+
+	return employees, err
+}
+
+func (r *Repository) FindActiveEmployees() ([]*employee.Employee, error) {
+	var employees []*employee.Employee
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := r.db.SelectContext(ctx, &employees, "SELECT * FROM employees WHERE status = $1 ORDER BY id", employee.StatusActive)
+
+	return employees, err
+}
+
+func (r *Repository) FindAssignments(employeeId int64) ([]*Assignment, error) {
+	var assignments []*Assignment
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := r.db.SelectContext(ctx, &assignments,
+		"SELECT employee_id, role_id, source FROM employee_roles WHERE employee_id = $1 ORDER BY role_id", employeeId)
+
+	return assignments, err
+}
+
+func (r *Repository) FindAllAssignments() ([]*Assignment, error) {
+	var assignments []*Assignment
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := r.db.SelectContext(ctx, &assignments,
+		"SELECT employee_id, role_id, source FROM employee_roles ORDER BY employee_id, role_id")
+
+	return assignments, err
+}
+
+// Sync выдать и отозвать унаследованные от групп роли в одной транзакции. Отзываются только
+// роли, выданные через группы, назначенные иначе роли не затрагиваются.
+func (r *Repository) Sync(employeeId int64, grant []int64, revoke []int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO employee_roles (employee_id, role_id, source) SELECT $1, unnest($2::BIGINT[]), $3
+		ON CONFLICT DO NOTHING`,
+		employeeId, pq.Array(grant), SourceGroup)
+	if err != nil {
+		return database.MapError(err)
+	}
+
+	_, err = tx.ExecContext(ctx,
+		"DELETE FROM employee_roles WHERE employee_id = $1 AND role_id = ANY($2) AND source = $3",
+		employeeId, pq.Array(revoke), SourceGroup)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
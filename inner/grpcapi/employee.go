@@ -0,0 +1,97 @@
+package grpcapi
+
+import (
+	"context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"idm/inner/employee"
+	"idm/inner/grpcapi/idmv1"
+	"strings"
+)
+
+type employeeServer struct {
+	idmv1.UnimplementedEmployeeServiceServer
+	service Employees
+}
+
+func (s *employeeServer) FindById(_ context.Context, request *idmv1.FindByIdRequest) (*idmv1.Employee, error) {
+	found, err := s.service.FindById(request.GetId())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return toEmployee(found), nil
+}
+
+func (s *employeeServer) FindByIds(_ context.Context, request *idmv1.FindByIdsRequest) (*idmv1.EmployeeList, error) {
+	found, err := s.service.FindByIds(request.GetIds())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return toEmployeeList(found), nil
+}
+
+func (s *employeeServer) FindAll(_ context.Context, _ *idmv1.FindAllRequest) (*idmv1.EmployeeList, error) {
+	found, err := s.service.FindAll()
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return toEmployeeList(found), nil
+}
+
+func (s *employeeServer) Create(_ context.Context, request *idmv1.CreateEmployeeRequest) (*idmv1.Employee, error) {
+	name := strings.TrimSpace(request.GetName())
+	if name == "" {
+		return nil, status.Error(codes.InvalidArgument, "name is required")
+	}
+
+	created, err := s.service.Create(name)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return toEmployee(created), nil
+}
+
+func (s *employeeServer) Remove(_ context.Context, request *idmv1.RemoveRequest) (*emptypb.Empty, error) {
+	return &emptypb.Empty{}, toStatus(s.service.Remove(request.GetId()))
+}
+
+func (s *employeeServer) RemoveByIds(_ context.Context, request *idmv1.RemoveByIdsRequest) (*emptypb.Empty, error) {
+	return &emptypb.Empty{}, toStatus(s.service.RemoveByIds(request.GetIds()))
+}
+
+func toEmployee(response employee.Response) *idmv1.Employee {
+	message := &idmv1.Employee{
+		Id:             response.Id,
+		Name:           response.Name,
+		UserName:       response.UserName,
+		Email:          response.Email,
+		ManagerId:      response.ManagerId,
+		Department:     response.Department,
+		Title:          response.Title,
+		Location:       response.Location,
+		EmploymentType: response.EmploymentType,
+		Status:         response.Status,
+		CreatedAt:      timestamppb.New(response.CreatedAt),
+		UpdatedAt:      timestamppb.New(response.UpdatedAt),
+	}
+	if response.TerminatedAt != nil {
+		message.TerminatedAt = timestamppb.New(*response.TerminatedAt)
+	}
+
+	return message
+}
+
+func toEmployeeList(responses []employee.Response) *idmv1.EmployeeList {
+	list := &idmv1.EmployeeList{Employees: make([]*idmv1.Employee, 0, len(responses))}
+	for _, response := range responses {
+		list.Employees = append(list.Employees, toEmployee(response))
+	}
+
+	return list
+}
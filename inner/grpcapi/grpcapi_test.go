@@ -0,0 +1,280 @@
+package grpcapi
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+	assertpackage "github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"idm/inner/database"
+	"idm/inner/employee"
+	"idm/inner/grpcapi/idmv1"
+	"idm/inner/role"
+	"idm/inner/serviceaccount"
+)
+
+type StubEmployees struct {
+	employees map[int64]employee.Response
+	removed   []int64
+}
+
+func (s *StubEmployees) FindById(id int64) (employee.Response, error) {
+	found, ok := s.employees[id]
+	if !ok {
+		return employee.Response{}, database.ErrRecordNotFound
+	}
+	return found, nil
+}
+
+func (s *StubEmployees) FindByIds(ids []int64) ([]employee.Response, error) {
+	var found []employee.Response
+	for _, id := range ids {
+		if response, ok := s.employees[id]; ok {
+			found = append(found, response)
+		}
+	}
+	return found, nil
+}
+
+func (s *StubEmployees) FindAll() ([]employee.Response, error) {
+	return s.FindByIds([]int64{1, 2})
+}
+
+func (s *StubEmployees) Create(name string) (employee.Response, error) {
+	created := employee.Response{Id: int64(len(s.employees) + 1), Name: name, Status: employee.StatusActive}
+	s.employees[created.Id] = created
+	return created, nil
+}
+
+func (s *StubEmployees) Remove(id int64) error {
+	if _, ok := s.employees[id]; !ok {
+		return database.ErrRecordNotFound
+	}
+	s.removed = append(s.removed, id)
+	return nil
+}
+
+func (s *StubEmployees) RemoveByIds(ids []int64) error {
+	for _, id := range ids {
+		if _, ok := s.employees[id]; !ok {
+			return database.ErrRecordNotFound
+		}
+	}
+	s.removed = append(s.removed, ids...)
+	return nil
+}
+
+type StubRoles struct {
+	createErr error
+}
+
+func (s *StubRoles) FindById(id int64) (role.Response, error) {
+	if id != 7 {
+		return role.Response{}, database.ErrRecordNotFound
+	}
+	return role.Response{Id: 7, Name: "admins", Permissions: []string{"all"}}, nil
+}
+
+func (s *StubRoles) FindByIds(ids []int64) ([]role.Response, error) {
+	return nil, nil
+}
+
+func (s *StubRoles) FindAll() ([]role.Response, error) {
+	return nil, context.DeadlineExceeded
+}
+
+func (s *StubRoles) Create(name string) (role.Response, error) {
+	return role.Response{}, s.createErr
+}
+
+func (s *StubRoles) Remove(id int64) error {
+	return nil
+}
+
+func (s *StubRoles) RemoveByIds(ids []int64) error {
+	return nil
+}
+
+// StubServiceAccounts ключ reader с правом чтения и writer с правами записи
+type StubServiceAccounts struct{}
+
+func (StubServiceAccounts) Authenticate(secret string) (serviceaccount.Principal, error) {
+	switch secret {
+	case "reader":
+		return serviceaccount.Principal{Name: "reader", Scopes: []string{serviceaccount.ScopeEmployeesRead}}, nil
+	case "writer":
+		return serviceaccount.Principal{Name: "writer",
+			Scopes: []string{serviceaccount.ScopeEmployeesWrite, serviceaccount.ScopeRolesWrite}}, nil
+	}
+	return serviceaccount.Principal{}, serviceaccount.ErrInvalidKey
+}
+
+func connect(t *testing.T, employees *StubEmployees, roles *StubRoles) *grpc.ClientConn {
+	listener := bufconn.Listen(1 << 20)
+	server := NewServer(employees, roles, StubServiceAccounts{})
+	go func() { _ = server.Serve(listener) }()
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	return conn
+}
+
+func withKey(key string) context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+key)
+}
+
+func TestServer(t *testing.T) {
+	var assert = assertpackage.New(t)
+	created := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	managerId := int64(2)
+	employees := &StubEmployees{employees: map[int64]employee.Response{
+		1: {Id: 1, Name: "Ivan", UserName: "ivanov", ManagerId: &managerId, Status: employee.StatusActive, CreatedAt: created},
+		2: {Id: 2, Name: "Petr", UserName: "petrov", Status: employee.StatusActive},
+	}}
+	roles := &StubRoles{}
+	conn := connect(t, employees, roles)
+	employeeClient := idmv1.NewEmployeeServiceClient(conn)
+	roleClient := idmv1.NewRoleServiceClient(conn)
+
+	t.Run("should find employees with a read key", func(t *testing.T) {
+		found, err := employeeClient.FindById(withKey("reader"), &idmv1.FindByIdRequest{Id: 1})
+
+		assert.Nil(err)
+		assert.Equal("ivanov", found.GetUserName())
+		assert.Equal(int64(2), found.GetManagerId())
+		assert.Equal(created, found.GetCreatedAt().AsTime())
+		assert.Nil(found.GetTerminatedAt())
+
+		list, err := employeeClient.FindByIds(withKey("reader"), &idmv1.FindByIdsRequest{Ids: []int64{2, 3}})
+		assert.Nil(err)
+		assert.Len(list.GetEmployees(), 1)
+		assert.Nil(list.GetEmployees()[0].ManagerId)
+
+		list, err = employeeClient.FindAll(withKey("writer"), &idmv1.FindAllRequest{})
+		assert.Nil(err)
+		assert.Len(list.GetEmployees(), 2)
+	})
+
+	t.Run("should map repository errors to status codes", func(t *testing.T) {
+		_, err := employeeClient.FindById(withKey("reader"), &idmv1.FindByIdRequest{Id: 42})
+		assert.Equal(codes.NotFound, status.Code(err))
+
+		_, err = roleClient.FindAll(withKey("writer"), &idmv1.FindAllRequest{})
+		assert.Equal(codes.DeadlineExceeded, status.Code(err))
+
+		roles.createErr = &pq.Error{Code: "23505", Message: "duplicate key value violates unique constraint"}
+		_, err = roleClient.Create(withKey("writer"), &idmv1.CreateRoleRequest{Name: "admins"})
+		assert.Equal(codes.AlreadyExists, status.Code(err))
+
+		roles.createErr = &pq.Error{Code: "42P01", Message: "relation does not exist"}
+		_, err = roleClient.Create(withKey("writer"), &idmv1.CreateRoleRequest{Name: "admins"})
+		assert.Equal(codes.Internal, status.Code(err))
+		assert.Equal("internal error", status.Convert(err).Message())
+
+		_, err = employeeClient.Create(withKey("writer"), &idmv1.CreateEmployeeRequest{Name: "  "})
+		assert.Equal(codes.InvalidArgument, status.Code(err))
+	})
+
+	t.Run("should create and remove with a write key", func(t *testing.T) {
+		created, err := employeeClient.Create(withKey("writer"), &idmv1.CreateEmployeeRequest{Name: "Anna"})
+		assert.Nil(err)
+		assert.Equal("Anna", created.GetName())
+
+		_, err = employeeClient.Remove(withKey("writer"), &idmv1.RemoveRequest{Id: 3})
+		assert.Nil(err)
+		_, err = employeeClient.RemoveByIds(withKey("writer"), &idmv1.RemoveByIdsRequest{Ids: []int64{1, 2}})
+		assert.Nil(err)
+		assert.Equal([]int64{3, 1, 2}, employees.removed)
+
+		_, err = employeeClient.Remove(withKey("writer"), &idmv1.RemoveRequest{Id: 42})
+		assert.Equal(codes.NotFound, status.Code(err))
+		_, err = employeeClient.RemoveByIds(withKey("writer"), &idmv1.RemoveByIdsRequest{Ids: []int64{1, 42}})
+		assert.Equal(codes.NotFound, status.Code(err))
+		assert.Equal([]int64{3, 1, 2}, employees.removed)
+	})
+
+	t.Run("should require a key with the scope of the method", func(t *testing.T) {
+		_, err := employeeClient.FindById(context.Background(), &idmv1.FindByIdRequest{Id: 1})
+		assert.Equal(codes.Unauthenticated, status.Code(err))
+
+		_, err = employeeClient.FindById(withKey("unknown"), &idmv1.FindByIdRequest{Id: 1})
+		assert.Equal(codes.Unauthenticated, status.Code(err))
+
+		_, err = employeeClient.Remove(withKey("reader"), &idmv1.RemoveRequest{Id: 1})
+		assert.Equal(codes.PermissionDenied, status.Code(err))
+
+		_, err = roleClient.FindById(withKey("reader"), &idmv1.FindByIdRequest{Id: 7})
+		assert.Equal(codes.PermissionDenied, status.Code(err))
+
+		found, err := roleClient.FindById(withKey("writer"), &idmv1.FindByIdRequest{Id: 7})
+		assert.Nil(err)
+		assert.Equal([]string{"all"}, found.GetPermissions())
+	})
+
+	t.Run("should report health and list services without a key", func(t *testing.T) {
+		health, err := healthpb.NewHealthClient(conn).Check(context.Background(),
+			&healthpb.HealthCheckRequest{Service: idmv1.EmployeeService_ServiceDesc.ServiceName})
+		assert.Nil(err)
+		assert.Equal(healthpb.HealthCheckResponse_SERVING, health.GetStatus())
+
+		stream, err := reflectionpb.NewServerReflectionClient(conn).ServerReflectionInfo(context.Background())
+		assert.Nil(err)
+		assert.Nil(stream.Send(&reflectionpb.ServerReflectionRequest{
+			MessageRequest: &reflectionpb.ServerReflectionRequest_ListServices{}}))
+		response, err := stream.Recv()
+		assert.Nil(err)
+		var services []string
+		for _, service := range response.GetListServicesResponse().GetService() {
+			services = append(services, service.GetName())
+		}
+		assert.Contains(services, "idm.v1.EmployeeService")
+		assert.Contains(services, "idm.v1.RoleService")
+		assert.Contains(services, "grpc.health.v1.Health")
+	})
+
+	t.Run("should check streaming methods like unary ones", func(t *testing.T) {
+		called := false
+		handler := func(any, grpc.ServerStream) error {
+			called = true
+			return nil
+		}
+		intercept := authorizeStream(StubServiceAccounts{})
+
+		err := intercept(nil, &stubStream{ctx: context.Background()},
+			&grpc.StreamServerInfo{FullMethod: "/idm.v1.EmployeeService/Watch"}, handler)
+		assert.Equal(codes.PermissionDenied, status.Code(err))
+		assert.False(called)
+
+		err = intercept(nil, &stubStream{ctx: context.Background()},
+			&grpc.StreamServerInfo{FullMethod: healthpb.Health_Watch_FullMethodName}, handler)
+		assert.Nil(err)
+		assert.True(called)
+	})
+}
+
+// stubStream поток сервера, у которого есть только контекст
+type stubStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *stubStream) Context() context.Context {
+	return s.ctx
+}
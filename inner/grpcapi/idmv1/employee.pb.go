@@ -0,0 +1,365 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.6
+// 	protoc        (unknown)
+// source: idm/v1/employee.proto
+
+package idmv1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	emptypb "google.golang.org/protobuf/types/known/emptypb"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type Employee struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	Id             int64                  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	Name           string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	UserName       string                 `protobuf:"bytes,3,opt,name=user_name,json=userName,proto3" json:"user_name,omitempty"`
+	Email          string                 `protobuf:"bytes,4,opt,name=email,proto3" json:"email,omitempty"`
+	ManagerId      *int64                 `protobuf:"varint,5,opt,name=manager_id,json=managerId,proto3,oneof" json:"manager_id,omitempty"`
+	Department     string                 `protobuf:"bytes,6,opt,name=department,proto3" json:"department,omitempty"`
+	Title          string                 `protobuf:"bytes,7,opt,name=title,proto3" json:"title,omitempty"`
+	Location       string                 `protobuf:"bytes,8,opt,name=location,proto3" json:"location,omitempty"`
+	EmploymentType string                 `protobuf:"bytes,9,opt,name=employment_type,json=employmentType,proto3" json:"employment_type,omitempty"`
+	Status         string                 `protobuf:"bytes,10,opt,name=status,proto3" json:"status,omitempty"`
+	TerminatedAt   *timestamppb.Timestamp `protobuf:"bytes,11,opt,name=terminated_at,json=terminatedAt,proto3" json:"terminated_at,omitempty"`
+	CreatedAt      *timestamppb.Timestamp `protobuf:"bytes,12,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	UpdatedAt      *timestamppb.Timestamp `protobuf:"bytes,13,opt,name=updated_at,json=updatedAt,proto3" json:"updated_at,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *Employee) Reset() {
+	*x = Employee{}
+	mi := &file_idm_v1_employee_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Employee) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Employee) ProtoMessage() {}
+
+func (x *Employee) ProtoReflect() protoreflect.Message {
+	mi := &file_idm_v1_employee_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Employee.ProtoReflect.Descriptor instead.
+func (*Employee) Descriptor() ([]byte, []int) {
+	return file_idm_v1_employee_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Employee) GetId() int64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *Employee) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Employee) GetUserName() string {
+	if x != nil {
+		return x.UserName
+	}
+	return ""
+}
+
+func (x *Employee) GetEmail() string {
+	if x != nil {
+		return x.Email
+	}
+	return ""
+}
+
+func (x *Employee) GetManagerId() int64 {
+	if x != nil && x.ManagerId != nil {
+		return *x.ManagerId
+	}
+	return 0
+}
+
+func (x *Employee) GetDepartment() string {
+	if x != nil {
+		return x.Department
+	}
+	return ""
+}
+
+func (x *Employee) GetTitle() string {
+	if x != nil {
+		return x.Title
+	}
+	return ""
+}
+
+func (x *Employee) GetLocation() string {
+	if x != nil {
+		return x.Location
+	}
+	return ""
+}
+
+func (x *Employee) GetEmploymentType() string {
+	if x != nil {
+		return x.EmploymentType
+	}
+	return ""
+}
+
+func (x *Employee) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *Employee) GetTerminatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.TerminatedAt
+	}
+	return nil
+}
+
+func (x *Employee) GetCreatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return nil
+}
+
+func (x *Employee) GetUpdatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.UpdatedAt
+	}
+	return nil
+}
+
+type EmployeeList struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Employees     []*Employee            `protobuf:"bytes,1,rep,name=employees,proto3" json:"employees,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *EmployeeList) Reset() {
+	*x = EmployeeList{}
+	mi := &file_idm_v1_employee_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *EmployeeList) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*EmployeeList) ProtoMessage() {}
+
+func (x *EmployeeList) ProtoReflect() protoreflect.Message {
+	mi := &file_idm_v1_employee_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use EmployeeList.ProtoReflect.Descriptor instead.
+func (*EmployeeList) Descriptor() ([]byte, []int) {
+	return file_idm_v1_employee_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *EmployeeList) GetEmployees() []*Employee {
+	if x != nil {
+		return x.Employees
+	}
+	return nil
+}
+
+type CreateEmployeeRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreateEmployeeRequest) Reset() {
+	*x = CreateEmployeeRequest{}
+	mi := &file_idm_v1_employee_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateEmployeeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateEmployeeRequest) ProtoMessage() {}
+
+func (x *CreateEmployeeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_idm_v1_employee_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateEmployeeRequest.ProtoReflect.Descriptor instead.
+func (*CreateEmployeeRequest) Descriptor() ([]byte, []int) {
+	return file_idm_v1_employee_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *CreateEmployeeRequest) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+var File_idm_v1_employee_proto protoreflect.FileDescriptor
+
+const file_idm_v1_employee_proto_rawDesc = "" +
+	"\n" +
+	"\x15idm/v1/employee.proto\x12\x06idm.v1\x1a\x1bgoogle/protobuf/empty.proto\x1a\x1fgoogle/protobuf/timestamp.proto\x1a\x13idm/v1/common.proto\"\xde\x03\n" +
+	"\bEmployee\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x03R\x02id\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x12\x1b\n" +
+	"\tuser_name\x18\x03 \x01(\tR\buserName\x12\x14\n" +
+	"\x05email\x18\x04 \x01(\tR\x05email\x12\"\n" +
+	"\n" +
+	"manager_id\x18\x05 \x01(\x03H\x00R\tmanagerId\x88\x01\x01\x12\x1e\n" +
+	"\n" +
+	"department\x18\x06 \x01(\tR\n" +
+	"department\x12\x14\n" +
+	"\x05title\x18\a \x01(\tR\x05title\x12\x1a\n" +
+	"\blocation\x18\b \x01(\tR\blocation\x12'\n" +
+	"\x0femployment_type\x18\t \x01(\tR\x0eemploymentType\x12\x16\n" +
+	"\x06status\x18\n" +
+	" \x01(\tR\x06status\x12?\n" +
+	"\rterminated_at\x18\v \x01(\v2\x1a.google.protobuf.TimestampR\fterminatedAt\x129\n" +
+	"\n" +
+	"created_at\x18\f \x01(\v2\x1a.google.protobuf.TimestampR\tcreatedAt\x129\n" +
+	"\n" +
+	"updated_at\x18\r \x01(\v2\x1a.google.protobuf.TimestampR\tupdatedAtB\r\n" +
+	"\v_manager_id\">\n" +
+	"\fEmployeeList\x12.\n" +
+	"\temployees\x18\x01 \x03(\v2\x10.idm.v1.EmployeeR\temployees\"+\n" +
+	"\x15CreateEmployeeRequest\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name2\xf5\x02\n" +
+	"\x0fEmployeeService\x125\n" +
+	"\bFindById\x12\x17.idm.v1.FindByIdRequest\x1a\x10.idm.v1.Employee\x12;\n" +
+	"\tFindByIds\x12\x18.idm.v1.FindByIdsRequest\x1a\x14.idm.v1.EmployeeList\x127\n" +
+	"\aFindAll\x12\x16.idm.v1.FindAllRequest\x1a\x14.idm.v1.EmployeeList\x129\n" +
+	"\x06Create\x12\x1d.idm.v1.CreateEmployeeRequest\x1a\x10.idm.v1.Employee\x127\n" +
+	"\x06Remove\x12\x15.idm.v1.RemoveRequest\x1a\x16.google.protobuf.Empty\x12A\n" +
+	"\vRemoveByIds\x12\x1a.idm.v1.RemoveByIdsRequest\x1a\x16.google.protobuf.EmptyB\x1fZ\x1didm/inner/grpcapi/idmv1;idmv1b\x06proto3"
+
+var (
+	file_idm_v1_employee_proto_rawDescOnce sync.Once
+	file_idm_v1_employee_proto_rawDescData []byte
+)
+
+func file_idm_v1_employee_proto_rawDescGZIP() []byte {
+	file_idm_v1_employee_proto_rawDescOnce.Do(func() {
+		file_idm_v1_employee_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_idm_v1_employee_proto_rawDesc), len(file_idm_v1_employee_proto_rawDesc)))
+	})
+	return file_idm_v1_employee_proto_rawDescData
+}
+
+var file_idm_v1_employee_proto_msgTypes = make([]protoimpl.MessageInfo, 3)
+var file_idm_v1_employee_proto_goTypes = []any{
+	(*Employee)(nil),              // 0: idm.v1.Employee
+	(*EmployeeList)(nil),          // 1: idm.v1.EmployeeList
+	(*CreateEmployeeRequest)(nil), // 2: idm.v1.CreateEmployeeRequest
+	(*timestamppb.Timestamp)(nil), // 3: google.protobuf.Timestamp
+	(*FindByIdRequest)(nil),       // 4: idm.v1.FindByIdRequest
+	(*FindByIdsRequest)(nil),      // 5: idm.v1.FindByIdsRequest
+	(*FindAllRequest)(nil),        // 6: idm.v1.FindAllRequest
+	(*RemoveRequest)(nil),         // 7: idm.v1.RemoveRequest
+	(*RemoveByIdsRequest)(nil),    // 8: idm.v1.RemoveByIdsRequest
+	(*emptypb.Empty)(nil),         // 9: google.protobuf.Empty
+}
+var file_idm_v1_employee_proto_depIdxs = []int32{
+	3,  // 0: idm.v1.Employee.terminated_at:type_name -> google.protobuf.Timestamp
+	3,  // 1: idm.v1.Employee.created_at:type_name -> google.protobuf.Timestamp
+	3,  // 2: idm.v1.Employee.updated_at:type_name -> google.protobuf.Timestamp
+	0,  // 3: idm.v1.EmployeeList.employees:type_name -> idm.v1.Employee
+	4,  // 4: idm.v1.EmployeeService.FindById:input_type -> idm.v1.FindByIdRequest
+	5,  // 5: idm.v1.EmployeeService.FindByIds:input_type -> idm.v1.FindByIdsRequest
+	6,  // 6: idm.v1.EmployeeService.FindAll:input_type -> idm.v1.FindAllRequest
+	2,  // 7: idm.v1.EmployeeService.Create:input_type -> idm.v1.CreateEmployeeRequest
+	7,  // 8: idm.v1.EmployeeService.Remove:input_type -> idm.v1.RemoveRequest
+	8,  // 9: idm.v1.EmployeeService.RemoveByIds:input_type -> idm.v1.RemoveByIdsRequest
+	0,  // 10: idm.v1.EmployeeService.FindById:output_type -> idm.v1.Employee
+	1,  // 11: idm.v1.EmployeeService.FindByIds:output_type -> idm.v1.EmployeeList
+	1,  // 12: idm.v1.EmployeeService.FindAll:output_type -> idm.v1.EmployeeList
+	0,  // 13: idm.v1.EmployeeService.Create:output_type -> idm.v1.Employee
+	9,  // 14: idm.v1.EmployeeService.Remove:output_type -> google.protobuf.Empty
+	9,  // 15: idm.v1.EmployeeService.RemoveByIds:output_type -> google.protobuf.Empty
+	10, // [10:16] is the sub-list for method output_type
+	4,  // [4:10] is the sub-list for method input_type
+	4,  // [4:4] is the sub-list for extension type_name
+	4,  // [4:4] is the sub-list for extension extendee
+	0,  // [0:4] is the sub-list for field type_name
+}
+
+func init() { file_idm_v1_employee_proto_init() }
+func file_idm_v1_employee_proto_init() {
+	if File_idm_v1_employee_proto != nil {
+		return
+	}
+	file_idm_v1_common_proto_init()
+	file_idm_v1_employee_proto_msgTypes[0].OneofWrappers = []any{}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_idm_v1_employee_proto_rawDesc), len(file_idm_v1_employee_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   3,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_idm_v1_employee_proto_goTypes,
+		DependencyIndexes: file_idm_v1_employee_proto_depIdxs,
+		MessageInfos:      file_idm_v1_employee_proto_msgTypes,
+	}.Build()
+	File_idm_v1_employee_proto = out.File
+	file_idm_v1_employee_proto_goTypes = nil
+	file_idm_v1_employee_proto_depIdxs = nil
+}
@@ -0,0 +1,306 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.6
+// 	protoc        (unknown)
+// source: idm/v1/role.proto
+
+package idmv1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	emptypb "google.golang.org/protobuf/types/known/emptypb"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type Role struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            int64                  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	Name          string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	OwnerId       *int64                 `protobuf:"varint,3,opt,name=owner_id,json=ownerId,proto3,oneof" json:"owner_id,omitempty"`
+	Description   string                 `protobuf:"bytes,4,opt,name=description,proto3" json:"description,omitempty"`
+	Permissions   []string               `protobuf:"bytes,5,rep,name=permissions,proto3" json:"permissions,omitempty"`
+	CreatedAt     *timestamppb.Timestamp `protobuf:"bytes,6,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	UpdatedAt     *timestamppb.Timestamp `protobuf:"bytes,7,opt,name=updated_at,json=updatedAt,proto3" json:"updated_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Role) Reset() {
+	*x = Role{}
+	mi := &file_idm_v1_role_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Role) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Role) ProtoMessage() {}
+
+func (x *Role) ProtoReflect() protoreflect.Message {
+	mi := &file_idm_v1_role_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Role.ProtoReflect.Descriptor instead.
+func (*Role) Descriptor() ([]byte, []int) {
+	return file_idm_v1_role_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Role) GetId() int64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *Role) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Role) GetOwnerId() int64 {
+	if x != nil && x.OwnerId != nil {
+		return *x.OwnerId
+	}
+	return 0
+}
+
+func (x *Role) GetDescription() string {
+	if x != nil {
+		return x.Description
+	}
+	return ""
+}
+
+func (x *Role) GetPermissions() []string {
+	if x != nil {
+		return x.Permissions
+	}
+	return nil
+}
+
+func (x *Role) GetCreatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return nil
+}
+
+func (x *Role) GetUpdatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.UpdatedAt
+	}
+	return nil
+}
+
+type RoleList struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Roles         []*Role                `protobuf:"bytes,1,rep,name=roles,proto3" json:"roles,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RoleList) Reset() {
+	*x = RoleList{}
+	mi := &file_idm_v1_role_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RoleList) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RoleList) ProtoMessage() {}
+
+func (x *RoleList) ProtoReflect() protoreflect.Message {
+	mi := &file_idm_v1_role_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RoleList.ProtoReflect.Descriptor instead.
+func (*RoleList) Descriptor() ([]byte, []int) {
+	return file_idm_v1_role_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *RoleList) GetRoles() []*Role {
+	if x != nil {
+		return x.Roles
+	}
+	return nil
+}
+
+type CreateRoleRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreateRoleRequest) Reset() {
+	*x = CreateRoleRequest{}
+	mi := &file_idm_v1_role_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateRoleRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateRoleRequest) ProtoMessage() {}
+
+func (x *CreateRoleRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_idm_v1_role_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateRoleRequest.ProtoReflect.Descriptor instead.
+func (*CreateRoleRequest) Descriptor() ([]byte, []int) {
+	return file_idm_v1_role_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *CreateRoleRequest) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+var File_idm_v1_role_proto protoreflect.FileDescriptor
+
+const file_idm_v1_role_proto_rawDesc = "" +
+	"\n" +
+	"\x11idm/v1/role.proto\x12\x06idm.v1\x1a\x1bgoogle/protobuf/empty.proto\x1a\x1fgoogle/protobuf/timestamp.proto\x1a\x13idm/v1/common.proto\"\x91\x02\n" +
+	"\x04Role\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x03R\x02id\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x12\x1e\n" +
+	"\bowner_id\x18\x03 \x01(\x03H\x00R\aownerId\x88\x01\x01\x12 \n" +
+	"\vdescription\x18\x04 \x01(\tR\vdescription\x12 \n" +
+	"\vpermissions\x18\x05 \x03(\tR\vpermissions\x129\n" +
+	"\n" +
+	"created_at\x18\x06 \x01(\v2\x1a.google.protobuf.TimestampR\tcreatedAt\x129\n" +
+	"\n" +
+	"updated_at\x18\a \x01(\v2\x1a.google.protobuf.TimestampR\tupdatedAtB\v\n" +
+	"\t_owner_id\".\n" +
+	"\bRoleList\x12\"\n" +
+	"\x05roles\x18\x01 \x03(\v2\f.idm.v1.RoleR\x05roles\"'\n" +
+	"\x11CreateRoleRequest\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name2\xdd\x02\n" +
+	"\vRoleService\x121\n" +
+	"\bFindById\x12\x17.idm.v1.FindByIdRequest\x1a\f.idm.v1.Role\x127\n" +
+	"\tFindByIds\x12\x18.idm.v1.FindByIdsRequest\x1a\x10.idm.v1.RoleList\x123\n" +
+	"\aFindAll\x12\x16.idm.v1.FindAllRequest\x1a\x10.idm.v1.RoleList\x121\n" +
+	"\x06Create\x12\x19.idm.v1.CreateRoleRequest\x1a\f.idm.v1.Role\x127\n" +
+	"\x06Remove\x12\x15.idm.v1.RemoveRequest\x1a\x16.google.protobuf.Empty\x12A\n" +
+	"\vRemoveByIds\x12\x1a.idm.v1.RemoveByIdsRequest\x1a\x16.google.protobuf.EmptyB\x1fZ\x1didm/inner/grpcapi/idmv1;idmv1b\x06proto3"
+
+var (
+	file_idm_v1_role_proto_rawDescOnce sync.Once
+	file_idm_v1_role_proto_rawDescData []byte
+)
+
+func file_idm_v1_role_proto_rawDescGZIP() []byte {
+	file_idm_v1_role_proto_rawDescOnce.Do(func() {
+		file_idm_v1_role_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_idm_v1_role_proto_rawDesc), len(file_idm_v1_role_proto_rawDesc)))
+	})
+	return file_idm_v1_role_proto_rawDescData
+}
+
+var file_idm_v1_role_proto_msgTypes = make([]protoimpl.MessageInfo, 3)
+var file_idm_v1_role_proto_goTypes = []any{
+	(*Role)(nil),                  // 0: idm.v1.Role
+	(*RoleList)(nil),              // 1: idm.v1.RoleList
+	(*CreateRoleRequest)(nil),     // 2: idm.v1.CreateRoleRequest
+	(*timestamppb.Timestamp)(nil), // 3: google.protobuf.Timestamp
+	(*FindByIdRequest)(nil),       // 4: idm.v1.FindByIdRequest
+	(*FindByIdsRequest)(nil),      // 5: idm.v1.FindByIdsRequest
+	(*FindAllRequest)(nil),        // 6: idm.v1.FindAllRequest
+	(*RemoveRequest)(nil),         // 7: idm.v1.RemoveRequest
+	(*RemoveByIdsRequest)(nil),    // 8: idm.v1.RemoveByIdsRequest
+	(*emptypb.Empty)(nil),         // 9: google.protobuf.Empty
+}
+var file_idm_v1_role_proto_depIdxs = []int32{
+	3, // 0: idm.v1.Role.created_at:type_name -> google.protobuf.Timestamp
+	3, // 1: idm.v1.Role.updated_at:type_name -> google.protobuf.Timestamp
+	0, // 2: idm.v1.RoleList.roles:type_name -> idm.v1.Role
+	4, // 3: idm.v1.RoleService.FindById:input_type -> idm.v1.FindByIdRequest
+	5, // 4: idm.v1.RoleService.FindByIds:input_type -> idm.v1.FindByIdsRequest
+	6, // 5: idm.v1.RoleService.FindAll:input_type -> idm.v1.FindAllRequest
+	2, // 6: idm.v1.RoleService.Create:input_type -> idm.v1.CreateRoleRequest
+	7, // 7: idm.v1.RoleService.Remove:input_type -> idm.v1.RemoveRequest
+	8, // 8: idm.v1.RoleService.RemoveByIds:input_type -> idm.v1.RemoveByIdsRequest
+	0, // 9: idm.v1.RoleService.FindById:output_type -> idm.v1.Role
+	1, // 10: idm.v1.RoleService.FindByIds:output_type -> idm.v1.RoleList
+	1, // 11: idm.v1.RoleService.FindAll:output_type -> idm.v1.RoleList
+	0, // 12: idm.v1.RoleService.Create:output_type -> idm.v1.Role
+	9, // 13: idm.v1.RoleService.Remove:output_type -> google.protobuf.Empty
+	9, // 14: idm.v1.RoleService.RemoveByIds:output_type -> google.protobuf.Empty
+	9, // [9:15] is the sub-list for method output_type
+	3, // [3:9] is the sub-list for method input_type
+	3, // [3:3] is the sub-list for extension type_name
+	3, // [3:3] is the sub-list for extension extendee
+	0, // [0:3] is the sub-list for field type_name
+}
+
+func init() { file_idm_v1_role_proto_init() }
+func file_idm_v1_role_proto_init() {
+	if File_idm_v1_role_proto != nil {
+		return
+	}
+	file_idm_v1_common_proto_init()
+	file_idm_v1_role_proto_msgTypes[0].OneofWrappers = []any{}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_idm_v1_role_proto_rawDesc), len(file_idm_v1_role_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   3,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_idm_v1_role_proto_goTypes,
+		DependencyIndexes: file_idm_v1_role_proto_depIdxs,
+		MessageInfos:      file_idm_v1_role_proto_msgTypes,
+	}.Build()
+	File_idm_v1_role_proto = out.File
+	file_idm_v1_role_proto_goTypes = nil
+	file_idm_v1_role_proto_depIdxs = nil
+}
@@ -0,0 +1,87 @@
+package grpcapi
+
+import (
+	"context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"idm/inner/grpcapi/idmv1"
+	"idm/inner/role"
+	"strings"
+)
+
+type roleServer struct {
+	idmv1.UnimplementedRoleServiceServer
+	service Roles
+}
+
+func (s *roleServer) FindById(_ context.Context, request *idmv1.FindByIdRequest) (*idmv1.Role, error) {
+	found, err := s.service.FindById(request.GetId())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return toRole(found), nil
+}
+
+func (s *roleServer) FindByIds(_ context.Context, request *idmv1.FindByIdsRequest) (*idmv1.RoleList, error) {
+	found, err := s.service.FindByIds(request.GetIds())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return toRoleList(found), nil
+}
+
+func (s *roleServer) FindAll(_ context.Context, _ *idmv1.FindAllRequest) (*idmv1.RoleList, error) {
+	found, err := s.service.FindAll()
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return toRoleList(found), nil
+}
+
+func (s *roleServer) Create(_ context.Context, request *idmv1.CreateRoleRequest) (*idmv1.Role, error) {
+	name := strings.TrimSpace(request.GetName())
+	if name == "" {
+		return nil, status.Error(codes.InvalidArgument, "name is required")
+	}
+
+	created, err := s.service.Create(name)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return toRole(created), nil
+}
+
+func (s *roleServer) Remove(_ context.Context, request *idmv1.RemoveRequest) (*emptypb.Empty, error) {
+	return &emptypb.Empty{}, toStatus(s.service.Remove(request.GetId()))
+}
+
+func (s *roleServer) RemoveByIds(_ context.Context, request *idmv1.RemoveByIdsRequest) (*emptypb.Empty, error) {
+	return &emptypb.Empty{}, toStatus(s.service.RemoveByIds(request.GetIds()))
+}
+
+func toRole(response role.Response) *idmv1.Role {
+	return &idmv1.Role{
+		Id:          response.Id,
+		Name:        response.Name,
+		OwnerId:     response.OwnerId,
+		Description: response.Description,
+		Permissions: response.Permissions,
+		CreatedAt:   timestamppb.New(response.CreatedAt),
+		UpdatedAt:   timestamppb.New(response.UpdatedAt),
+	}
+}
+
+func toRoleList(responses []role.Response) *idmv1.RoleList {
+	list := &idmv1.RoleList{Roles: make([]*idmv1.Role, 0, len(responses))}
+	for _, response := range responses {
+		list.Roles = append(list.Roles, toRole(response))
+	}
+
+	return list
+}
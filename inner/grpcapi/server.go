@@ -0,0 +1,141 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/reflection"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	reflectionalphapb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	"google.golang.org/grpc/status"
+	"idm/inner/employee"
+	"idm/inner/grpcapi/idmv1"
+	"idm/inner/role"
+	"idm/inner/serviceaccount"
+	"strings"
+)
+
+type Employees interface {
+	FindById(id int64) (employee.Response, error)
+	FindByIds(ids []int64) ([]employee.Response, error)
+	FindAll() ([]employee.Response, error)
+	Create(name string) (employee.Response, error)
+	Remove(id int64) error
+	RemoveByIds(ids []int64) error
+}
+
+type Roles interface {
+	FindById(id int64) (role.Response, error)
+	FindByIds(ids []int64) ([]role.Response, error)
+	FindAll() ([]role.Response, error)
+	Create(name string) (role.Response, error)
+	Remove(id int64) error
+	RemoveByIds(ids []int64) error
+}
+
+// ServiceAccounts клиенты передают API-ключ в метаданных authorization: Bearer, как в REST
+type ServiceAccounts interface {
+	Authenticate(secret string) (serviceaccount.Principal, error)
+}
+
+// scopes право, которое нужно для вызова метода. Методы, которых здесь нет, доступны
+// только если они в public.
+var scopes = map[string]string{
+	idmv1.EmployeeService_FindById_FullMethodName:    serviceaccount.ScopeEmployeesRead,
+	idmv1.EmployeeService_FindByIds_FullMethodName:   serviceaccount.ScopeEmployeesRead,
+	idmv1.EmployeeService_FindAll_FullMethodName:     serviceaccount.ScopeEmployeesRead,
+	idmv1.EmployeeService_Create_FullMethodName:      serviceaccount.ScopeEmployeesWrite,
+	idmv1.EmployeeService_Remove_FullMethodName:      serviceaccount.ScopeEmployeesWrite,
+	idmv1.EmployeeService_RemoveByIds_FullMethodName: serviceaccount.ScopeEmployeesWrite,
+	idmv1.RoleService_FindById_FullMethodName:        serviceaccount.ScopeRolesRead,
+	idmv1.RoleService_FindByIds_FullMethodName:       serviceaccount.ScopeRolesRead,
+	idmv1.RoleService_FindAll_FullMethodName:         serviceaccount.ScopeRolesRead,
+	idmv1.RoleService_Create_FullMethodName:          serviceaccount.ScopeRolesWrite,
+	idmv1.RoleService_Remove_FullMethodName:          serviceaccount.ScopeRolesWrite,
+	idmv1.RoleService_RemoveByIds_FullMethodName:     serviceaccount.ScopeRolesWrite,
+}
+
+// public методы проверки состояния и reflection, которые вызываются без ключа
+var public = map[string]bool{
+	healthpb.Health_Check_FullMethodName:                                   true,
+	healthpb.Health_List_FullMethodName:                                    true,
+	healthpb.Health_Watch_FullMethodName:                                   true,
+	reflectionpb.ServerReflection_ServerReflectionInfo_FullMethodName:      true,
+	reflectionalphapb.ServerReflection_ServerReflectionInfo_FullMethodName: true,
+}
+
+// NewServer gRPC-сервер с EmployeeService и RoleService, проверкой состояния (grpc.health.v1)
+// и reflection. Вызовы сервисов требуют ключ служебной учётной записи с нужным правом.
+func NewServer(employees Employees, roles Roles, accounts ServiceAccounts, options ...grpc.ServerOption) *grpc.Server {
+	server := grpc.NewServer(append(options,
+		grpc.UnaryInterceptor(authorize(accounts)), grpc.StreamInterceptor(authorizeStream(accounts)))...)
+	idmv1.RegisterEmployeeServiceServer(server, &employeeServer{service: employees})
+	idmv1.RegisterRoleServiceServer(server, &roleServer{service: roles})
+
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus(idmv1.EmployeeService_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+	healthServer.SetServingStatus(idmv1.RoleService_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(server, healthServer)
+	reflection.Register(server)
+
+	return server
+}
+
+func authorize(accounts ServiceAccounts) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, request any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if err := check(ctx, accounts, info.FullMethod); err != nil {
+			return nil, err
+		}
+
+		return handler(ctx, request)
+	}
+}
+
+// authorizeStream те же проверки для потоковых методов: без него reflection, Health/Watch
+// и будущие потоковые методы сервисов вызывались бы в обход ключа
+func authorizeStream(accounts ServiceAccounts) grpc.StreamServerInterceptor {
+	return func(server any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := check(stream.Context(), accounts, info.FullMethod); err != nil {
+			return err
+		}
+
+		return handler(server, stream)
+	}
+}
+
+// check проверить, что метод публичный или ключ из метаданных даёт нужное право
+func check(ctx context.Context, accounts ServiceAccounts, method string) error {
+	if public[method] {
+		return nil
+	}
+	scope, ok := scopes[method]
+	if !ok {
+		return status.Errorf(codes.PermissionDenied, "method %s is not allowed", method)
+	}
+
+	values := metadata.ValueFromIncomingContext(ctx, "authorization")
+	if len(values) == 0 {
+		return status.Error(codes.Unauthenticated, "api key is required")
+	}
+	secret, found := strings.CutPrefix(values[0], "Bearer ")
+	if !found {
+		return status.Error(codes.Unauthenticated, "api key is required")
+	}
+
+	principal, err := accounts.Authenticate(strings.TrimSpace(secret))
+	if err != nil {
+		if errors.Is(err, serviceaccount.ErrInvalidKey) {
+			return status.Error(codes.Unauthenticated, err.Error())
+		}
+		return status.Error(codes.Internal, "internal error")
+	}
+	if !serviceaccount.Allows(principal.Scopes, scope) {
+		return status.Error(codes.PermissionDenied, "api key lacks scope "+scope)
+	}
+
+	return nil
+}
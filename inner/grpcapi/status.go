@@ -0,0 +1,31 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"github.com/lib/pq"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"idm/inner/database"
+)
+
+// toStatus ошибка сервиса в статус gRPC; внутренние ошибки клиенту не раскрываются
+func toStatus(err error) error {
+	var pqErr *pq.Error
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, database.ErrRecordNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, err.Error())
+	case errors.As(err, &pqErr) && pqErr.Code == "23505":
+		return status.Error(codes.AlreadyExists, pqErr.Message)
+	case errors.As(err, &pqErr) && pqErr.Code == "23503":
+		return status.Error(codes.FailedPrecondition, pqErr.Message)
+	}
+
+	return status.Error(codes.Internal, "internal error")
+}
@@ -0,0 +1,40 @@
+package hrsync
+
+// Report расхождения IDM с выгрузкой HR и результат их исправления
+type Report struct {
+	Key     string `json:"key"`
+	Applied bool   `json:"applied"`
+	// Snapshot и Employees число записей в выгрузке и сотрудников в IDM
+	Snapshot  int `json:"snapshot"`
+	Employees int `json:"employees"`
+	// Missing работают по данным HR, но отсутствуют в IDM
+	Missing []Drift `json:"missing"`
+	// Extra активны в IDM, но отсутствуют в выгрузке
+	Extra []Drift `json:"extra"`
+	// Changed атрибуты или статус в IDM отличаются от данных HR
+	Changed []Drift `json:"changed"`
+	// TerminatedActive уволены по данным HR, но активны в IDM
+	TerminatedActive []Drift `json:"terminated_active"`
+	// Failed сколько исправлений не удалось применить; подробности – в Error расхождения
+	Failed   int      `json:"failed"`
+	Warnings []string `json:"warnings"`
+}
+
+// Drift одно расхождение. EmployeeId пуст для сотрудника, которого нет в IDM.
+type Drift struct {
+	EmployeeId int64                  `json:"employee_id,omitempty"`
+	Key        string                 `json:"key"`
+	Name       string                 `json:"name"`
+	Changes    map[string]FieldChange `json:"changes,omitempty"`
+	Error      string                 `json:"error,omitempty"`
+}
+
+type FieldChange struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// Total число расхождений всех видов
+func (r *Report) Total() int {
+	return len(r.Missing) + len(r.Extra) + len(r.Changed) + len(r.TerminatedActive)
+}
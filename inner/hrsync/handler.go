@@ -0,0 +1,81 @@
+package hrsync
+
+import (
+	"errors"
+	"idm/inner/httpapi"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// maxSnapshotSize ограничение размера выгрузки HR
+const maxSnapshotSize = 64 << 20
+
+// Handler сверка с выгрузкой HR в теле запроса:
+// POST /reconcile?format=csv&key=user_name&apply=true&max_deletes=10&force=false&map.<поле>=<столбец>
+type Handler struct {
+	service *Service
+	mux     *http.ServeMux
+}
+
+func NewHandler(service *Service) *Handler {
+	h := &Handler{service: service, mux: http.NewServeMux()}
+
+	h.mux.HandleFunc("POST /reconcile", h.reconcile)
+
+	return h
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+func (h *Handler) reconcile(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	options := Options{Format: Format(query.Get("format")), Key: query.Get("key"), Mapping: map[string]string{}}
+	if options.Format == "" {
+		options.Format = FormatCSV
+		if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "application/json" {
+			options.Format = FormatJSON
+		}
+	}
+	for name, target := range map[string]*bool{"apply": &options.Apply, "force": &options.Force} {
+		if value := query.Get(name); value != "" {
+			parsed, err := strconv.ParseBool(value)
+			if err != nil {
+				httpapi.WriteError(w, http.StatusBadRequest, "invalid "+name)
+				return
+			}
+			*target = parsed
+		}
+	}
+	if value := query.Get("max_deletes"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			httpapi.WriteError(w, http.StatusBadRequest, "invalid max_deletes")
+			return
+		}
+		options.MaxDeletes = parsed
+	}
+	for name, values := range query {
+		if field, ok := strings.CutPrefix(name, "map."); ok && len(values) > 0 {
+			options.Mapping[field] = values[0]
+		}
+	}
+
+	report, err := h.service.Reconcile(http.MaxBytesReader(w, r.Body, maxSnapshotSize), options)
+	var tooLarge *http.MaxBytesError
+	switch {
+	case err == nil:
+		httpapi.WriteJSON(w, http.StatusOK, report)
+	case errors.As(err, &tooLarge):
+		httpapi.WriteError(w, http.StatusRequestEntityTooLarge, "snapshot is too large")
+	case errors.Is(err, ErrInvalidOptions), errors.Is(err, ErrInvalidSnapshot):
+		httpapi.WriteError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, ErrTooManyDeletes):
+		httpapi.WriteJSON(w, http.StatusConflict, map[string]any{"error": err.Error(), "report": report})
+	default:
+		httpapi.WriteError(w, http.StatusInternalServerError, "internal error")
+	}
+}
@@ -0,0 +1,373 @@
+package hrsync
+
+import (
+	"errors"
+	"fmt"
+	"idm/inner/employee"
+	"idm/inner/lifecycle"
+	"io"
+	"slices"
+	"strings"
+	"time"
+)
+
+type Format string
+
+const (
+	FormatCSV  Format = "csv"
+	FormatJSON Format = "json"
+)
+
+// DefaultMaxDeletes сколько лишних сотрудников сверка увольняет за один запуск без Force
+const DefaultMaxDeletes = 10
+
+var (
+	ErrInvalidOptions  = errors.New("invalid reconciliation options")
+	ErrInvalidSnapshot = errors.New("invalid hr snapshot")
+	ErrTooManyDeletes  = errors.New("too many employees would be terminated, refusing to apply without force")
+)
+
+// Options режим сверки
+type Options struct {
+	Format Format
+	// Key поле, по которому сотрудники выгрузки сопоставляются с IDM: user_name (по умолчанию) или email
+	Key string
+	// Mapping поле → столбец выгрузки, если они называются по-разному
+	Mapping map[string]string
+	// Apply исправить расхождения; без него только отчёт
+	Apply bool
+	// MaxDeletes сколько лишних сотрудников можно уволить за запуск; 0 – DefaultMaxDeletes
+	MaxDeletes int
+	// Force снять ограничение MaxDeletes
+	Force bool
+}
+
+// Employees сотрудники читаются и изменяются через сервис, чтобы срабатывали его обработчики
+type Employees interface {
+	FindAll() ([]employee.Response, error)
+	Create(name string) (employee.Response, error)
+	Update(id int64, request employee.UpdateRequest) (employee.Response, error)
+	SetActive(id int64, active bool) (employee.Response, error)
+}
+
+// Lifecycle сотрудники увольняются, а не удаляются: роли отзываются, учётная запись отключается,
+// а данные хранятся до удаления по сроку хранения
+type Lifecycle interface {
+	Terminate(request lifecycle.TerminateRequest) (lifecycle.ChangeResponse, error)
+}
+
+// Service сверяет сотрудников IDM с выгрузкой HR, которая считается источником истины
+type Service struct {
+	employees Employees
+	lifecycle Lifecycle
+	now       func() time.Time
+}
+
+func NewService(employees Employees, lifecycle Lifecycle) *Service {
+	return &Service{employees: employees, lifecycle: lifecycle, now: time.Now}
+}
+
+// matched запись выгрузки, по которой сотрудника нужно создать (missing) или изменить;
+// index – номер расхождения в Report.Missing или Report.Changed
+type matched struct {
+	record  record
+	current *employee.Response
+	missing bool
+	index   int
+}
+
+// Reconcile сравнить выгрузку с сотрудниками IDM и при Apply исправить расхождения. Если уволить
+// пришлось бы больше MaxDeletes лишних сотрудников, ничего не меняется и возвращается ErrTooManyDeletes.
+func (s *Service) Reconcile(reader io.Reader, options Options) (Report, error) {
+	if options.Key == "" {
+		options.Key = FieldUserName
+	}
+	if options.Key != FieldUserName && options.Key != FieldEmail {
+		return Report{}, fmt.Errorf("%w: key must be user_name or email", ErrInvalidOptions)
+	}
+	if options.MaxDeletes < 0 {
+		return Report{}, fmt.Errorf("%w: max deletes must not be negative", ErrInvalidOptions)
+	}
+	if options.MaxDeletes == 0 {
+		options.MaxDeletes = DefaultMaxDeletes
+	}
+
+	records, err := readSnapshot(reader, options.Format, options.Mapping)
+	if err != nil {
+		return Report{}, err
+	}
+	employees, err := s.employees.FindAll()
+	if err != nil {
+		return Report{}, err
+	}
+
+	report := Report{Key: options.Key, Snapshot: len(records), Employees: len(employees),
+		Missing: []Drift{}, Extra: []Drift{}, Changed: []Drift{}, TerminatedActive: []Drift{}, Warnings: []string{}}
+
+	byKey := map[string]*employee.Response{}
+	keyById := map[int64]string{}
+	duplicates := map[int64]bool{}
+	for i := range employees {
+		e := &employees[i]
+		key := normalizeKey(fieldOf(*e, options.Key))
+		keyById[e.Id] = key
+		if key == "" {
+			continue
+		}
+		if other, ok := byKey[key]; ok {
+			report.Warnings = append(report.Warnings,
+				fmt.Sprintf("employees %d and %d share %s %q and are left as is", other.Id, e.Id, options.Key, key))
+			duplicates[e.Id], duplicates[other.Id] = true, true
+			continue
+		}
+		byKey[key] = e
+	}
+
+	lines := map[string]int{}
+	seen := map[int64]bool{}
+	var matches []*matched
+	for _, r := range records {
+		key := normalizeKey(r.values[options.Key])
+		if key == "" {
+			report.Warnings = append(report.Warnings, fmt.Sprintf("line %d: no %s, skipped", r.line, options.Key))
+			continue
+		}
+		if line, ok := lines[key]; ok {
+			return Report{}, fmt.Errorf("%w: line %d repeats %s %q of line %d", ErrInvalidSnapshot, r.line, options.Key, key, line)
+		}
+		lines[key] = r.line
+
+		terminated := s.terminated(r, &report)
+		current, found := byKey[key]
+		if found && duplicates[current.Id] {
+			found = false
+			current = nil
+		}
+		if found {
+			seen[current.Id] = true
+		}
+		switch {
+		case !found && terminated:
+		case !found:
+			matches = append(matches, &matched{record: r, missing: true, index: len(report.Missing)})
+			report.Missing = append(report.Missing, Drift{Key: key, Name: r.values[FieldName], Changes: s.changes(employee.Response{}, r, "")})
+		default:
+			managerKey := ""
+			if current.ManagerId != nil {
+				managerKey = keyById[*current.ManagerId]
+			}
+			changes := s.changes(*current, r, managerKey)
+			if !terminated && current.Status == employee.StatusDisabled {
+				changes[FieldStatus] = FieldChange{From: employee.StatusDisabled, To: employee.StatusActive}
+			}
+			if terminated && current.Status == employee.StatusActive {
+				report.TerminatedActive = append(report.TerminatedActive,
+					Drift{EmployeeId: current.Id, Key: key, Name: current.Name})
+			}
+			if len(changes) > 0 {
+				matches = append(matches, &matched{record: r, current: current, index: len(report.Changed)})
+				report.Changed = append(report.Changed, Drift{EmployeeId: current.Id, Key: key, Name: current.Name, Changes: changes})
+			}
+		}
+	}
+
+	// сотрудник без ключа не может найтись в выгрузке, а отключённые уже уволены и хранятся до удаления
+	for _, e := range employees {
+		switch {
+		case seen[e.Id] || duplicates[e.Id] || e.Status != employee.StatusActive:
+		case keyById[e.Id] == "":
+			report.Warnings = append(report.Warnings,
+				fmt.Sprintf("employee %d has no %s and is left as is", e.Id, options.Key))
+		default:
+			report.Extra = append(report.Extra, Drift{EmployeeId: e.Id, Key: keyById[e.Id], Name: e.Name})
+		}
+	}
+
+	s.warnUnknownManagers(records, byKey, lines, &report, options.Key)
+	if !options.Apply {
+		return report, nil
+	}
+	if len(report.Extra) > options.MaxDeletes && !options.Force {
+		return report, fmt.Errorf("%w: %d of %d, limit %d", ErrTooManyDeletes, len(report.Extra), len(employees), options.MaxDeletes)
+	}
+
+	s.apply(matches, byKey, &report)
+	report.Applied = true
+
+	return report, nil
+}
+
+// apply создать недостающих, исправить изменившихся и уволить через жизненный цикл уволенных и лишних.
+// Сначала создаются все недостающие, чтобы на них могли ссылаться руководители.
+func (s *Service) apply(matches []*matched, byKey map[string]*employee.Response, report *Report) {
+	fail := func(drift *Drift, err error) {
+		drift.Error = err.Error()
+		report.Failed++
+	}
+	drift := func(m *matched) *Drift {
+		if m.missing {
+			return &report.Missing[m.index]
+		}
+		return &report.Changed[m.index]
+	}
+
+	for _, m := range matches {
+		if !m.missing {
+			continue
+		}
+		d := drift(m)
+		name := m.record.values[FieldName]
+		if name == "" {
+			name = d.Key
+		}
+		created, err := s.employees.Create(name)
+		if err != nil {
+			fail(d, err)
+			continue
+		}
+		m.current = &created
+		d.EmployeeId = created.Id
+		byKey[d.Key] = m.current
+	}
+
+	for _, m := range matches {
+		d := drift(m)
+		if m.current == nil {
+			continue
+		}
+		_, reactivate := d.Changes[FieldStatus]
+		if m.missing || len(d.Changes) > 1 || !reactivate {
+			if _, err := s.employees.Update(m.current.Id, s.request(*m.current, m.record, byKey)); err != nil {
+				fail(d, err)
+				continue
+			}
+		}
+		if reactivate {
+			if _, err := s.employees.SetActive(m.current.Id, true); err != nil {
+				fail(d, err)
+			}
+		}
+	}
+
+	for _, drifts := range [][]Drift{report.TerminatedActive, report.Extra} {
+		for i := range drifts {
+			drift := &drifts[i]
+			if _, err := s.lifecycle.Terminate(lifecycle.TerminateRequest{EmployeeId: drift.EmployeeId}); err != nil {
+				fail(drift, err)
+			}
+		}
+	}
+}
+
+// request данные сотрудника с полями, которые есть в записи выгрузки
+func (s *Service) request(current employee.Response, r record, byKey map[string]*employee.Response) employee.UpdateRequest {
+	request := employee.UpdateRequest{
+		Name:           current.Name,
+		UserName:       current.UserName,
+		Email:          current.Email,
+		ManagerId:      current.ManagerId,
+		Department:     current.Department,
+		Title:          current.Title,
+		Location:       current.Location,
+		EmploymentType: current.EmploymentType,
+	}
+	for field, target := range map[string]*string{
+		FieldName: &request.Name, FieldUserName: &request.UserName, FieldEmail: &request.Email,
+		FieldDepartment: &request.Department, FieldTitle: &request.Title, FieldLocation: &request.Location,
+		FieldEmploymentType: &request.EmploymentType,
+	} {
+		if value, ok := r.values[field]; ok && (value != "" || field != FieldName) {
+			*target = value
+		}
+	}
+	if value, ok := r.values[FieldManager]; ok {
+		if value == "" {
+			request.ManagerId = nil
+		} else if manager, ok := byKey[normalizeKey(value)]; ok {
+			request.ManagerId = &manager.Id
+		}
+	}
+
+	return request
+}
+
+// changes поля записи выгрузки, которые отличаются от сотрудника; отсутствующие в выгрузке поля не сравниваются
+func (s *Service) changes(current employee.Response, r record, managerKey string) map[string]FieldChange {
+	changes := map[string]FieldChange{}
+	for _, field := range []string{FieldName, FieldUserName, FieldEmail, FieldDepartment, FieldTitle, FieldLocation, FieldEmploymentType} {
+		value, ok := r.values[field]
+		if !ok || (field == FieldName && value == "") {
+			continue
+		}
+		if from := fieldOf(current, field); from != value {
+			changes[field] = FieldChange{From: from, To: value}
+		}
+	}
+	if value, ok := r.values[FieldManager]; ok && normalizeKey(value) != managerKey {
+		changes[FieldManager] = FieldChange{From: managerKey, To: normalizeKey(value)}
+	}
+
+	return changes
+}
+
+// terminated уволен ли сотрудник по данным записи
+func (s *Service) terminated(r record, report *Report) bool {
+	if slices.Contains(terminatedStatuses, strings.ToLower(r.values[FieldStatus])) {
+		return true
+	}
+	value := r.values[FieldTerminatedAt]
+	if value == "" {
+		return false
+	}
+	for _, layout := range []string{time.DateOnly, time.RFC3339} {
+		if at, err := time.Parse(layout, value); err == nil {
+			return !at.After(s.now())
+		}
+	}
+	report.Warnings = append(report.Warnings, fmt.Sprintf("line %d: invalid terminated_at %q ignored", r.line, value))
+
+	return false
+}
+
+// warnUnknownManagers предупредить о руководителях, которых нет ни в IDM, ни в выгрузке
+func (s *Service) warnUnknownManagers(records []record, byKey map[string]*employee.Response, lines map[string]int,
+	report *Report, key string) {
+	for _, r := range records {
+		manager := normalizeKey(r.values[FieldManager])
+		if manager == "" {
+			continue
+		}
+		if _, ok := byKey[manager]; ok {
+			continue
+		}
+		if _, ok := lines[manager]; ok {
+			continue
+		}
+		report.Warnings = append(report.Warnings, fmt.Sprintf("line %d: manager with %s %q not found", r.line, key, manager))
+	}
+}
+
+func fieldOf(e employee.Response, field string) string {
+	switch field {
+	case FieldName:
+		return e.Name
+	case FieldUserName:
+		return e.UserName
+	case FieldEmail:
+		return e.Email
+	case FieldDepartment:
+		return e.Department
+	case FieldTitle:
+		return e.Title
+	case FieldLocation:
+		return e.Location
+	case FieldEmploymentType:
+		return e.EmploymentType
+	}
+
+	return ""
+}
+
+func normalizeKey(value string) string {
+	return strings.ToLower(strings.TrimSpace(value))
+}
@@ -0,0 +1,270 @@
+package hrsync
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	assertpackage "github.com/stretchr/testify/assert"
+	"idm/inner/database"
+	"idm/inner/employee"
+	"idm/inner/lifecycle"
+)
+
+// StubEmployees сотрудники в памяти, заодно увольняет их как жизненный цикл; calls – вызовы изменяющих
+// методов по порядку
+type StubEmployees struct {
+	employees []*employee.Response
+	calls     []string
+	failing   string
+}
+
+func (s *StubEmployees) FindAll() ([]employee.Response, error) {
+	var responses []employee.Response
+	for _, e := range s.employees {
+		responses = append(responses, *e)
+	}
+	return responses, nil
+}
+
+func (s *StubEmployees) find(id int64) *employee.Response {
+	for _, e := range s.employees {
+		if e.Id == id {
+			return e
+		}
+	}
+	return nil
+}
+
+func (s *StubEmployees) Create(name string) (employee.Response, error) {
+	s.calls = append(s.calls, "create "+name)
+	created := &employee.Response{Id: int64(len(s.employees) + 100), Name: name, Status: employee.StatusActive}
+	s.employees = append(s.employees, created)
+	return *created, nil
+}
+
+func (s *StubEmployees) Update(id int64, request employee.UpdateRequest) (employee.Response, error) {
+	s.calls = append(s.calls, "update "+request.UserName)
+	if request.UserName == s.failing {
+		return employee.Response{}, errors.New("user name is taken")
+	}
+	e := s.find(id)
+	e.Name, e.UserName, e.Email, e.ManagerId = request.Name, request.UserName, request.Email, request.ManagerId
+	e.Department, e.Title, e.Location, e.EmploymentType = request.Department, request.Title, request.Location, request.EmploymentType
+	return *e, nil
+}
+
+func (s *StubEmployees) SetActive(id int64, active bool) (employee.Response, error) {
+	e := s.find(id)
+	if e == nil {
+		return employee.Response{}, database.ErrRecordNotFound
+	}
+	s.calls = append(s.calls, "active "+e.UserName+" "+map[bool]string{true: "true", false: "false"}[active])
+	e.Status = employee.StatusDisabled
+	if active {
+		e.Status = employee.StatusActive
+	}
+	return *e, nil
+}
+
+func (s *StubEmployees) Terminate(request lifecycle.TerminateRequest) (lifecycle.ChangeResponse, error) {
+	e := s.find(request.EmployeeId)
+	s.calls = append(s.calls, "terminate "+e.UserName)
+	e.Status = employee.StatusDisabled
+	return lifecycle.ChangeResponse{Kind: lifecycle.KindTerminate, EmployeeId: &e.Id, Status: lifecycle.ChangeApplied}, nil
+}
+
+func stub() *StubEmployees {
+	manager := int64(1)
+	return &StubEmployees{employees: []*employee.Response{
+		{Id: 1, Name: "Anna Boss", UserName: "boss", Email: "boss@example.com", Department: "IT", Status: employee.StatusActive},
+		{Id: 2, Name: "Ivan Ivanov", UserName: "ivanov", Email: "ivanov@example.com", Department: "IT", ManagerId: &manager,
+			Status: employee.StatusActive},
+		{Id: 3, Name: "Petr Petrov", UserName: "petrov", Department: "Sales", Status: employee.StatusActive},
+		{Id: 4, Name: "Olga Sidorova", UserName: "sidorova", Department: "IT", Status: employee.StatusDisabled},
+		{Id: 5, Name: "Manual Entry", UserName: "manual", Status: employee.StatusActive},
+	}}
+}
+
+const snapshot = "user_name,full_name,email,department,manager,status,terminated_at\n" +
+	"BOSS,Anna Boss,boss@example.com,IT,,active,\n" +
+	"ivanov,Ivan Ivanov,ivanov@example.com,Finance,boss,active,\n" +
+	"petrov,Petr Petrov,,Sales,,active,2025-01-31\n" +
+	"sidorova,Olga Sidorova,,IT,ivanov,active,2030-12-31\n" +
+	"kuznetsov,Kirill Kuznetsov,kuznetsov@example.com,IT,smirnov,active,\n" +
+	"leaver,Left Long Ago,,IT,,terminated,\n"
+
+func newService(employees *StubEmployees) *Service {
+	return NewService(employees, employees)
+}
+
+func TestService(t *testing.T) {
+	var assert = assertpackage.New(t)
+	now := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	options := Options{Format: FormatCSV, Mapping: map[string]string{FieldName: "full_name"}}
+
+	t.Run("should classify drift without changing anything", func(t *testing.T) {
+		employees := stub()
+		service := NewService(employees, employees)
+		service.now = func() time.Time { return now }
+
+		report, err := service.Reconcile(strings.NewReader(snapshot), options)
+
+		assert.Nil(err)
+		assert.False(report.Applied)
+		assert.Equal(6, report.Snapshot)
+		assert.Equal(5, report.Employees)
+		assert.Equal([]Drift{{Key: "kuznetsov", Name: "Kirill Kuznetsov", Changes: map[string]FieldChange{
+			FieldName: {To: "Kirill Kuznetsov"}, FieldUserName: {To: "kuznetsov"}, FieldEmail: {To: "kuznetsov@example.com"},
+			FieldDepartment: {To: "IT"}, FieldManager: {To: "smirnov"},
+		}}}, report.Missing)
+		assert.Equal([]Drift{{EmployeeId: 5, Key: "manual", Name: "Manual Entry"}}, report.Extra)
+		assert.Equal([]Drift{
+			{EmployeeId: 1, Key: "boss", Name: "Anna Boss", Changes: map[string]FieldChange{FieldUserName: {From: "boss", To: "BOSS"}}},
+			{EmployeeId: 2, Key: "ivanov", Name: "Ivan Ivanov", Changes: map[string]FieldChange{FieldDepartment: {From: "IT", To: "Finance"}}},
+			{EmployeeId: 4, Key: "sidorova", Name: "Olga Sidorova", Changes: map[string]FieldChange{
+				FieldManager: {To: "ivanov"}, FieldStatus: {From: employee.StatusDisabled, To: employee.StatusActive},
+			}},
+		}, report.Changed)
+		assert.Equal([]Drift{{EmployeeId: 3, Key: "petrov", Name: "Petr Petrov"}}, report.TerminatedActive)
+		assert.Equal([]string{`line 6: manager with user_name "smirnov" not found`}, report.Warnings)
+		assert.Equal(6, report.Total())
+		assert.Empty(employees.calls)
+	})
+
+	t.Run("should apply corrections through the employee service", func(t *testing.T) {
+		employees := stub()
+		employees.failing = "BOSS"
+		service := NewService(employees, employees)
+		service.now = func() time.Time { return now }
+		apply := options
+		apply.Apply = true
+
+		report, err := service.Reconcile(strings.NewReader(snapshot), apply)
+
+		assert.Nil(err)
+		assert.True(report.Applied)
+		assert.Equal([]string{
+			"create Kirill Kuznetsov",
+			"update BOSS",
+			"update ivanov",
+			"update sidorova",
+			"active sidorova true",
+			"update kuznetsov",
+			"terminate petrov",
+			"terminate manual",
+		}, employees.calls)
+		assert.Equal(1, report.Failed)
+		assert.Equal("user name is taken", report.Changed[0].Error)
+		assert.Equal(int64(105), report.Missing[0].EmployeeId)
+
+		created := employees.find(105)
+		assert.Equal("kuznetsov@example.com", created.Email)
+		assert.Nil(created.ManagerId)
+		assert.Equal("Finance", employees.find(2).Department)
+		assert.Equal(int64(2), *employees.find(4).ManagerId)
+		assert.Equal(employee.StatusActive, employees.find(4).Status)
+		assert.Equal(employee.StatusDisabled, employees.find(3).Status)
+		assert.Equal(employee.StatusDisabled, employees.find(5).Status)
+	})
+
+	t.Run("should refuse to mass-delete unless forced", func(t *testing.T) {
+		employees := stub()
+		service := NewService(employees, employees)
+		json := `[{"user_name":"boss","name":"Anna Boss"}]`
+
+		report, err := service.Reconcile(strings.NewReader(json), Options{Format: FormatJSON, Apply: true, MaxDeletes: 2})
+
+		assert.ErrorIs(err, ErrTooManyDeletes)
+		assert.False(report.Applied)
+		assert.Len(report.Extra, 3)
+		assert.Empty(employees.calls)
+
+		report, err = service.Reconcile(strings.NewReader(json), Options{Format: FormatJSON, Apply: true, MaxDeletes: 2, Force: true})
+
+		assert.Nil(err)
+		assert.True(report.Applied)
+		assert.Equal([]string{"terminate ivanov", "terminate petrov", "terminate manual"}, employees.calls)
+		assert.Len(employees.employees, 5)
+	})
+
+	t.Run("should leave employees without a key and disabled employees out of extra", func(t *testing.T) {
+		employees := stub()
+		employees.employees = append(employees.employees, &employee.Response{Id: 6, Name: "No Login", Status: employee.StatusActive})
+		service := NewService(employees, employees)
+
+		report, err := service.Reconcile(strings.NewReader(`[{"user_name":"boss","name":"Anna Boss"}]`),
+			Options{Format: FormatJSON, Apply: true, Force: true})
+
+		assert.Nil(err)
+		assert.Equal([]int64{2, 3, 5}, []int64{report.Extra[0].EmployeeId, report.Extra[1].EmployeeId, report.Extra[2].EmployeeId})
+		assert.Equal([]string{"employee 6 has no user_name and is left as is"}, report.Warnings)
+		assert.NotContains(employees.calls, "terminate sidorova")
+		assert.Equal(employee.StatusActive, employees.find(6).Status)
+	})
+
+	t.Run("should match by email", func(t *testing.T) {
+		service := newService(stub())
+
+		report, err := service.Reconcile(strings.NewReader(`[{"email":"IVANOV@example.com","department":"IT"}]`),
+			Options{Format: FormatJSON, Key: FieldEmail})
+
+		assert.Nil(err)
+		assert.Empty(report.Missing)
+		assert.Equal([]Drift{{EmployeeId: 2, Key: "ivanov@example.com", Name: "Ivan Ivanov",
+			Changes: map[string]FieldChange{FieldEmail: {From: "ivanov@example.com", To: "IVANOV@example.com"}}}}, report.Changed)
+		assert.Equal([]Drift{{EmployeeId: 1, Key: "boss@example.com", Name: "Anna Boss"}}, report.Extra)
+		assert.Equal([]string{"employee 3 has no email and is left as is", "employee 5 has no email and is left as is"},
+			report.Warnings)
+	})
+
+	t.Run("should reject invalid snapshots and options", func(t *testing.T) {
+		service := newService(stub())
+
+		_, err := service.Reconcile(strings.NewReader("user_name\nivanov\nIvanov\n"), Options{Format: FormatCSV})
+		assert.ErrorIs(err, ErrInvalidSnapshot)
+		_, err = service.Reconcile(strings.NewReader(`{"user_name":"ivanov"}`), Options{Format: FormatJSON})
+		assert.ErrorIs(err, ErrInvalidSnapshot)
+		_, err = service.Reconcile(strings.NewReader(`[{"user_name":["ivanov"]}]`), Options{Format: FormatJSON})
+		assert.ErrorIs(err, ErrInvalidSnapshot)
+		_, err = service.Reconcile(strings.NewReader(""), Options{Format: "xml"})
+		assert.ErrorIs(err, ErrInvalidSnapshot)
+		_, err = service.Reconcile(strings.NewReader(""), Options{Format: FormatCSV, Key: "id"})
+		assert.ErrorIs(err, ErrInvalidOptions)
+	})
+}
+
+func TestHandler(t *testing.T) {
+	var assert = assertpackage.New(t)
+
+	t.Run("should report drift and map errors", func(t *testing.T) {
+		handler := NewHandler(newService(stub()))
+
+		recorder := httptest.NewRecorder()
+		request := httptest.NewRequest(http.MethodPost, "/reconcile?map.name=full_name", strings.NewReader(snapshot))
+		handler.ServeHTTP(recorder, request)
+		assert.Equal(http.StatusOK, recorder.Code)
+		assert.Contains(recorder.Body.String(), `"extra":[{"employee_id":5,"key":"manual","name":"Manual Entry"}]`)
+
+		recorder = httptest.NewRecorder()
+		request = httptest.NewRequest(http.MethodPost, "/reconcile?apply=true&max_deletes=1", strings.NewReader(`[]`))
+		request.Header.Set("Content-Type", "application/json; charset=utf-8")
+		handler.ServeHTTP(recorder, request)
+		assert.Equal(http.StatusConflict, recorder.Code)
+		assert.Contains(recorder.Body.String(), `"report":{`)
+
+		for target, status := range map[string]int{
+			"/reconcile?apply=maybe":     http.StatusBadRequest,
+			"/reconcile?max_deletes=x":   http.StatusBadRequest,
+			"/reconcile?format=json":     http.StatusBadRequest,
+			"/reconcile?key=employee_id": http.StatusBadRequest,
+		} {
+			recorder = httptest.NewRecorder()
+			handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, target, strings.NewReader("user_name\n")))
+			assert.Equal(status, recorder.Code, target)
+		}
+	})
+}
@@ -0,0 +1,149 @@
+package hrsync
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Поля выгрузки HR
+const (
+	FieldUserName       = "user_name"
+	FieldName           = "name"
+	FieldEmail          = "email"
+	FieldDepartment     = "department"
+	FieldTitle          = "title"
+	FieldLocation       = "location"
+	FieldEmploymentType = "employment_type"
+	// FieldManager ключ руководителя – то же поле, по которому сопоставляются сотрудники
+	FieldManager = "manager"
+	// FieldStatus active или одно из terminatedStatuses
+	FieldStatus = "status"
+	// FieldTerminatedAt дата увольнения; будущая дата означает, что сотрудник ещё работает
+	FieldTerminatedAt = "terminated_at"
+)
+
+// Fields поля, которые понимает сверка; остальные столбцы выгрузки игнорируются
+var Fields = []string{
+	FieldUserName, FieldName, FieldEmail, FieldDepartment, FieldTitle, FieldLocation, FieldEmploymentType,
+	FieldManager, FieldStatus, FieldTerminatedAt,
+}
+
+// terminatedStatuses значения статуса уволенного сотрудника
+var terminatedStatuses = []string{"terminated", "inactive", "disabled", "leaver"}
+
+// record строка выгрузки: номер строки или элемента и значения полей, которые в ней есть
+type record struct {
+	line   int
+	values map[string]string
+}
+
+func (r record) has(field string) bool {
+	_, ok := r.values[field]
+	return ok
+}
+
+// readSnapshot прочитать выгрузку; mapping – поле → имя столбца, если они различаются
+func readSnapshot(reader io.Reader, format Format, mapping map[string]string) ([]record, error) {
+	var rows []map[string]string
+	var err error
+	switch format {
+	case FormatCSV:
+		rows, err = readCSV(reader)
+	case FormatJSON:
+		rows, err = readJSON(reader)
+	default:
+		return nil, fmt.Errorf("%w: unknown format %q", ErrInvalidSnapshot, format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]record, 0, len(rows))
+	for i, row := range rows {
+		values := map[string]string{}
+		for _, field := range Fields {
+			column := field
+			if mapped, ok := mapping[field]; ok {
+				column = mapped
+			}
+			if value, ok := row[column]; ok {
+				values[field] = strings.TrimSpace(value)
+			}
+		}
+		line := i + 1
+		if format == FormatCSV {
+			line = i + 2
+		}
+		records = append(records, record{line: line, values: values})
+	}
+
+	return records, nil
+}
+
+func readCSV(reader io.Reader) ([]map[string]string, error) {
+	r := csv.NewReader(reader)
+	header, err := r.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("%w: csv file is empty", ErrInvalidSnapshot)
+		}
+		return nil, fmt.Errorf("%w: %w", ErrInvalidSnapshot, err)
+	}
+	for i := range header {
+		header[i] = strings.TrimSpace(header[i])
+	}
+	header[0] = strings.TrimPrefix(header[0], "\ufeff")
+
+	var rows []map[string]string
+	for {
+		fields, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidSnapshot, err)
+		}
+		row := make(map[string]string, len(fields))
+		for i, value := range fields {
+			row[header[i]] = value
+		}
+		rows = append(rows, row)
+	}
+}
+
+// readJSON массив объектов со скалярными значениями
+func readJSON(reader io.Reader) ([]map[string]string, error) {
+	var objects []map[string]any
+	decoder := json.NewDecoder(reader)
+	decoder.UseNumber()
+	if err := decoder.Decode(&objects); err != nil {
+		return nil, fmt.Errorf("%w: expected a json array of objects: %w", ErrInvalidSnapshot, err)
+	}
+
+	rows := make([]map[string]string, 0, len(objects))
+	for i, object := range objects {
+		row := make(map[string]string, len(object))
+		for key, value := range object {
+			switch v := value.(type) {
+			case nil:
+				row[key] = ""
+			case string:
+				row[key] = v
+			case json.Number:
+				row[key] = v.String()
+			case bool:
+				row[key] = strconv.FormatBool(v)
+			default:
+				return nil, fmt.Errorf("%w: element %d: field %q must be a scalar", ErrInvalidSnapshot, i+1, key)
+			}
+		}
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
@@ -0,0 +1,102 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"errors"
+	"idm/inner/database"
+	"net/http"
+	"strconv"
+)
+
+// ErrUnauthenticated запрос от имени сотрудника без действующего сеанса
+var ErrUnauthenticated = errors.New("authentication required")
+
+// Authenticator определяет, от чьего имени выполняется запрос. Если вход не выполнен,
+// возвращает ErrUnauthenticated или ошибку, которую ожидает обработчик.
+type Authenticator[T any] interface {
+	Authenticate(r *http.Request) (T, error)
+}
+
+// AuthenticatorFunc позволяет использовать функцию как Authenticator
+type AuthenticatorFunc[T any] func(r *http.Request) (T, error)
+
+func (f AuthenticatorFunc[T]) Authenticate(r *http.Request) (T, error) {
+	return f(r)
+}
+
+// Status код ответа для ошибки сервиса
+type Status struct {
+	Err  error
+	Code int
+}
+
+// Statuses коды ответа для ошибок сервиса; ошибка получает код первой совпавшей через errors.Is
+type Statuses []Status
+
+// PathId целочисленный параметр пути name; при ошибке отвечает 400
+func PathId(w http.ResponseWriter, r *http.Request, name string) (int64, bool) {
+	id, err := strconv.ParseInt(r.PathValue(name), 10, 64)
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, "invalid "+name)
+		return 0, false
+	}
+
+	return id, true
+}
+
+// Decode раскодировать тело запроса из JSON в target; при ошибке отвечает 400
+func Decode(w http.ResponseWriter, r *http.Request, target any) bool {
+	if err := json.NewDecoder(r.Body).Decode(target); err != nil {
+		WriteError(w, http.StatusBadRequest, "invalid request body")
+		return false
+	}
+
+	return true
+}
+
+// Write ответить body с кодом status, а если err не nil – ошибкой; nil body – ответ без тела
+func Write(w http.ResponseWriter, status int, body any, err error, statuses Statuses) {
+	switch {
+	case err != nil:
+		Fail(w, err, statuses)
+	case body == nil:
+		w.WriteHeader(status)
+	default:
+		WriteJSON(w, status, body)
+	}
+}
+
+// Fail ответить ошибкой с кодом из statuses. Без кода database.ErrRecordNotFound – 404,
+// ErrUnauthenticated – 401, остальные – 500 без подробностей.
+func Fail(w http.ResponseWriter, err error, statuses Statuses) {
+	for _, status := range statuses {
+		if errors.Is(err, status.Err) {
+			WriteError(w, status.Code, err.Error())
+			return
+		}
+	}
+
+	switch {
+	case errors.Is(err, database.ErrRecordNotFound):
+		WriteError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, ErrUnauthenticated):
+		WriteError(w, http.StatusUnauthorized, err.Error())
+	default:
+		WriteError(w, http.StatusInternalServerError, "internal error")
+	}
+}
+
+// WriteError ответить телом {"error": message}
+func WriteError(w http.ResponseWriter, status int, message string) {
+	WriteJSON(w, status, map[string]string{"error": message})
+}
+
+// WriteJSON ответить body в JSON. Ответы не кешируются, если обработчик не задал Cache-Control сам.
+func WriteJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	if w.Header().Get("Cache-Control") == "" {
+		w.Header().Set("Cache-Control", "no-store")
+	}
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,116 @@
+package httpapi
+
+import (
+	"errors"
+	"fmt"
+	assertpackage "github.com/stretchr/testify/assert"
+	"idm/inner/database"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+var (
+	errInvalid = errors.New("invalid")
+	errTaken   = errors.New("taken")
+)
+
+var statuses = Statuses{
+	{Err: errInvalid, Code: http.StatusBadRequest},
+	{Err: errTaken, Code: http.StatusConflict},
+}
+
+func TestHttpApi(t *testing.T) {
+	var assert = assertpackage.New(t)
+
+	t.Run("should write bodies and empty responses", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		Write(recorder, http.StatusCreated, map[string]int{"id": 1}, nil, statuses)
+
+		assert.Equal(http.StatusCreated, recorder.Code)
+		assert.Equal("application/json", recorder.Header().Get("Content-Type"))
+		assert.Equal("no-store", recorder.Header().Get("Cache-Control"))
+		assert.JSONEq(`{"id":1}`, recorder.Body.String())
+
+		recorder = httptest.NewRecorder()
+		Write(recorder, http.StatusNoContent, nil, nil, statuses)
+		assert.Equal(http.StatusNoContent, recorder.Code)
+		assert.Empty(recorder.Body.String())
+	})
+
+	t.Run("should map errors to statuses in order", func(t *testing.T) {
+		for err, status := range map[error]int{
+			fmt.Errorf("error creating: %w", errTaken):                  http.StatusConflict,
+			errors.Join(errInvalid, errTaken):                           http.StatusBadRequest,
+			fmt.Errorf("error finding: %w", database.ErrRecordNotFound): http.StatusNotFound,
+			ErrUnauthenticated:                                          http.StatusUnauthorized,
+			errors.New("connection refused"):                            http.StatusInternalServerError,
+		} {
+			recorder := httptest.NewRecorder()
+			Write(recorder, http.StatusOK, nil, err, statuses)
+			assert.Equal(status, recorder.Code, err.Error())
+		}
+
+		recorder := httptest.NewRecorder()
+		Fail(recorder, errors.New("password authentication failed"), statuses)
+		assert.JSONEq(`{"error":"internal error"}`, recorder.Body.String())
+	})
+
+	t.Run("should keep the Cache-Control set by the handler", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		recorder.Header().Set("Cache-Control", "public, max-age=300")
+		WriteJSON(recorder, http.StatusOK, []int{})
+
+		assert.Equal("public, max-age=300", recorder.Header().Get("Cache-Control"))
+	})
+
+	t.Run("should reject invalid path ids and bodies", func(t *testing.T) {
+		mux := http.NewServeMux()
+		mux.HandleFunc("POST /{id}", func(w http.ResponseWriter, r *http.Request) {
+			id, ok := PathId(w, r, "id")
+			if !ok {
+				return
+			}
+			var body struct{ Name string }
+			if !Decode(w, r, &body) {
+				return
+			}
+			WriteJSON(w, http.StatusOK, map[string]any{"id": id, "name": body.Name})
+		})
+		serve := func(target string, body string) *httptest.ResponseRecorder {
+			recorder := httptest.NewRecorder()
+			mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, target, strings.NewReader(body)))
+			return recorder
+		}
+
+		recorder := serve("/x", `{}`)
+		assert.Equal(http.StatusBadRequest, recorder.Code)
+		assert.JSONEq(`{"error":"invalid id"}`, recorder.Body.String())
+
+		recorder = serve("/7", `{`)
+		assert.Equal(http.StatusBadRequest, recorder.Code)
+		assert.JSONEq(`{"error":"invalid request body"}`, recorder.Body.String())
+
+		recorder = serve("/7", `{"Name":"ops"}`)
+		assert.JSONEq(`{"id":7,"name":"ops"}`, recorder.Body.String())
+	})
+
+	t.Run("should authenticate with a function", func(t *testing.T) {
+		var authenticator Authenticator[int64] = AuthenticatorFunc[int64](func(r *http.Request) (int64, error) {
+			if r.Header.Get("Cookie") == "" {
+				return 0, ErrUnauthenticated
+			}
+			return 42, nil
+		})
+
+		_, err := authenticator.Authenticate(httptest.NewRequest(http.MethodGet, "/", nil))
+		assert.ErrorIs(err, ErrUnauthenticated)
+
+		request := httptest.NewRequest(http.MethodGet, "/", nil)
+		request.Header.Set("Cookie", "idm_session=token")
+		employeeId, err := authenticator.Authenticate(request)
+		assert.Nil(err)
+		assert.Equal(int64(42), employeeId)
+	})
+}
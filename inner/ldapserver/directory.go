@@ -0,0 +1,233 @@
+package ldapserver
+
+import (
+	"fmt"
+	"github.com/go-ldap/ldap/v3"
+	"idm/inner/employee"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type attribute struct {
+	name   string
+	values []string
+}
+
+// entry запись каталога. Операционные атрибуты возвращаются, только если их запросили явно.
+type entry struct {
+	dn          string
+	parsed      *ldap.DN
+	attributes  []attribute
+	operational []attribute
+}
+
+// add добавить атрибут, пропуская пустые значения
+func (e *entry) add(name string, values ...string) {
+	values = slices.DeleteFunc(values, func(value string) bool { return value == "" })
+	if len(values) > 0 {
+		e.attributes = append(e.attributes, attribute{name: name, values: values})
+	}
+}
+
+// values значения атрибута; имена атрибутов не зависят от регистра
+func (e *entry) values(name string) []string {
+	for _, a := range slices.Concat(e.attributes, e.operational) {
+		if strings.EqualFold(a.name, name) {
+			return a.values
+		}
+	}
+
+	return nil
+}
+
+// selected атрибуты, которые нужно вернуть по списку из запроса (RFC 4511, раздел 4.5.1.8)
+func (e *entry) selected(requested []string) []attribute {
+	all := len(requested) == 0 || slices.Contains(requested, "*")
+	operational := slices.Contains(requested, "+")
+	contains := func(name string) bool {
+		return slices.ContainsFunc(requested, func(r string) bool { return strings.EqualFold(r, name) })
+	}
+
+	var result []attribute
+	for _, a := range e.attributes {
+		if all || contains(a.name) {
+			result = append(result, a)
+		}
+	}
+	for _, a := range e.operational {
+		if operational || contains(a.name) {
+			result = append(result, a)
+		}
+	}
+
+	return result
+}
+
+// tree снимок каталога: корневая запись, подразделения, сотрудники и группы
+type tree struct {
+	entries []*entry
+}
+
+func (t *tree) find(dn *ldap.DN) *entry {
+	for _, e := range t.entries {
+		if e.parsed.EqualFold(dn) {
+			return e
+		}
+	}
+
+	return nil
+}
+
+// snapshot собрать каталог из текущих данных сотрудников и ролей. Отдельной копии данных нет,
+// поэтому каждый поиск видит актуальное состояние.
+func (s *Server) snapshot() (*tree, error) {
+	employees, err := s.employees.FindAll()
+	if err != nil {
+		return nil, fmt.Errorf("error finding all employees: %w", err)
+	}
+	roles, err := s.roles.FindAll()
+	if err != nil {
+		return nil, fmt.Errorf("error finding all roles: %w", err)
+	}
+
+	people := map[int64]string{}
+	for _, e := range employees {
+		if e.Status == employee.StatusActive && e.UserName != "" {
+			people[e.Id] = s.personDN(e.UserName)
+		}
+	}
+
+	t := &tree{}
+	t.entries = append(t.entries, s.container(s.baseDN), s.container(peopleOU+","+s.baseDN),
+		s.container(groupsOU+","+s.baseDN))
+
+	memberOf := map[int64][]string{}
+	var groups []*entry
+	for _, r := range roles {
+		ids, err := s.roles.FindEmployeeIds(r.Id)
+		if err != nil {
+			return nil, fmt.Errorf("error finding employees of role with id %d: %w", r.Id, err)
+		}
+
+		dn := s.groupDN(r.Name)
+		group := &entry{dn: dn}
+		group.add("objectClass", "top", "groupOfNames")
+		group.add("cn", r.Name)
+		var members []string
+		for _, id := range ids {
+			if member, ok := people[id]; ok {
+				members = append(members, member)
+				memberOf[id] = append(memberOf[id], dn)
+			}
+		}
+		group.add("member", members...)
+		group.operational = timestamps(dn, r.CreatedAt, r.UpdatedAt)
+		groups = append(groups, group)
+	}
+
+	for _, e := range employees {
+		dn, ok := people[e.Id]
+		if !ok {
+			continue
+		}
+
+		person := &entry{dn: dn}
+		person.add("objectClass", "top", "person", "organizationalPerson", "inetOrgPerson")
+		person.add("uid", e.UserName)
+		name := e.Name
+		if name == "" {
+			name = e.UserName
+		}
+		person.add("cn", name)
+		person.add("displayName", name)
+		surname := name
+		if words := strings.Fields(name); len(words) > 1 {
+			person.add("givenName", strings.Join(words[:len(words)-1], " "))
+			surname = words[len(words)-1]
+		}
+		person.add("sn", surname)
+		person.add("mail", e.Email)
+		person.add("title", e.Title)
+		person.add("departmentNumber", e.Department)
+		person.add("l", e.Location)
+		person.add("employeeType", e.EmploymentType)
+		person.add("employeeNumber", strconv.FormatInt(e.Id, 10))
+		if e.ManagerId != nil {
+			person.add("manager", people[*e.ManagerId])
+		}
+		person.add("memberOf", memberOf[e.Id]...)
+		person.operational = timestamps(dn, e.CreatedAt, e.UpdatedAt)
+		t.entries = append(t.entries, person)
+	}
+	t.entries = append(t.entries, groups...)
+
+	for _, e := range t.entries {
+		parsed, err := ldap.ParseDN(e.dn)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing dn %q: %w", e.dn, err)
+		}
+		e.parsed = parsed
+	}
+
+	return t, nil
+}
+
+// rootDSE корневая запись с описанием возможностей сервера (RFC 4512, раздел 5.1)
+func (s *Server) rootDSE() *entry {
+	root := &entry{dn: "", parsed: &ldap.DN{}}
+	root.add("objectClass", "top")
+	root.add("namingContexts", s.baseDN)
+	root.add("supportedLDAPVersion", "3")
+	extensions := []string{oidWhoAmI}
+	if s.tls != nil {
+		extensions = append(extensions, oidStartTLS)
+	}
+	root.add("supportedExtension", extensions...)
+	root.add("vendorName", "IDM")
+	return root
+}
+
+// container корневая запись или подразделение; класс выбирается по атрибуту первого RDN
+func (s *Server) container(dn string) *entry {
+	e := &entry{dn: dn}
+	parsed, err := ldap.ParseDN(dn)
+	if err != nil || len(parsed.RDNs) == 0 {
+		e.add("objectClass", "top")
+		return e
+	}
+
+	first := parsed.RDNs[0].Attributes[0]
+	switch strings.ToLower(first.Type) {
+	case "dc":
+		e.add("objectClass", "top", "domain")
+	case "o":
+		e.add("objectClass", "top", "organization")
+	case "ou":
+		e.add("objectClass", "top", "organizationalUnit")
+	default:
+		e.add("objectClass", "top", "extensibleObject")
+	}
+	e.add(first.Type, first.Value)
+
+	return e
+}
+
+func (s *Server) personDN(userName string) string {
+	return "uid=" + ldap.EscapeDN(userName) + "," + peopleOU + "," + s.baseDN
+}
+
+func (s *Server) groupDN(name string) string {
+	return "cn=" + ldap.EscapeDN(name) + "," + groupsOU + "," + s.baseDN
+}
+
+func timestamps(dn string, created time.Time, updated time.Time) []attribute {
+	return []attribute{
+		{name: "entryDN", values: []string{dn}},
+		{name: "createTimestamp", values: []string{created.UTC().Format(generalizedTime)}},
+		{name: "modifyTimestamp", values: []string{updated.UTC().Format(generalizedTime)}},
+	}
+}
+
+const generalizedTime = "20060102150405Z"
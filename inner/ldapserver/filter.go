@@ -0,0 +1,141 @@
+package ldapserver
+
+import (
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap/v3"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// виды фильтров поиска (RFC 4511, раздел 4.5.1.7)
+const (
+	filterAnd            ber.Tag = 0
+	filterOr             ber.Tag = 1
+	filterNot            ber.Tag = 2
+	filterEqualityMatch  ber.Tag = 3
+	filterSubstrings     ber.Tag = 4
+	filterGreaterOrEqual ber.Tag = 5
+	filterLessOrEqual    ber.Tag = 6
+	filterPresent        ber.Tag = 7
+	filterApproxMatch    ber.Tag = 8
+)
+
+// dnAttributes атрибуты со значениями-DN, которые сравниваются по правилам distinguishedNameMatch
+var dnAttributes = []string{"member", "manager", "memberof", "entrydn"}
+
+// matches подходит ли запись под фильтр. Неподдерживаемые и повреждённые фильтры
+// ничего не находят (значение Undefined по RFC 4511).
+func (e *entry) matches(filter *ber.Packet) bool {
+	switch filter.Tag {
+	case filterAnd:
+		for _, child := range filter.Children {
+			if !e.matches(child) {
+				return false
+			}
+		}
+		return true
+	case filterOr:
+		return slices.ContainsFunc(filter.Children, e.matches)
+	case filterNot:
+		return len(filter.Children) == 1 && !e.matches(filter.Children[0])
+	case filterPresent:
+		return strings.EqualFold(str(filter), "objectClass") || len(e.values(str(filter))) > 0
+	case filterEqualityMatch, filterApproxMatch, filterGreaterOrEqual, filterLessOrEqual:
+		if len(filter.Children) != 2 {
+			return false
+		}
+		name, asserted := str(filter.Children[0]), str(filter.Children[1])
+		return slices.ContainsFunc(e.values(name), func(value string) bool {
+			switch filter.Tag {
+			case filterGreaterOrEqual:
+				return compare(value, asserted) >= 0
+			case filterLessOrEqual:
+				return compare(value, asserted) <= 0
+			default:
+				return equal(name, value, asserted)
+			}
+		})
+	case filterSubstrings:
+		if len(filter.Children) != 2 {
+			return false
+		}
+		return slices.ContainsFunc(e.values(str(filter.Children[0])), func(value string) bool {
+			return substrings(strings.ToLower(value), filter.Children[1].Children)
+		})
+	}
+
+	return false
+}
+
+// equal сравнение без учёта регистра; DN сравниваются поэлементно
+func equal(name string, value string, asserted string) bool {
+	if slices.Contains(dnAttributes, strings.ToLower(name)) {
+		a, errA := ldap.ParseDN(value)
+		b, errB := ldap.ParseDN(asserted)
+		if errA == nil && errB == nil {
+			return a.EqualFold(b)
+		}
+	}
+
+	return strings.EqualFold(value, asserted)
+}
+
+// compare упорядочение для >= и <=: числа сравниваются как числа, остальное – как строки
+func compare(value string, asserted string) int {
+	a, errA := strconv.ParseInt(value, 10, 64)
+	b, errB := strconv.ParseInt(asserted, 10, 64)
+	if errA == nil && errB == nil {
+		switch {
+		case a < b:
+			return -1
+		case a > b:
+			return 1
+		}
+		return 0
+	}
+
+	return strings.Compare(strings.ToLower(value), strings.ToLower(asserted))
+}
+
+// substrings сопоставить значение с частями initial, any и final
+func substrings(value string, parts []*ber.Packet) bool {
+	for i, part := range parts {
+		piece := strings.ToLower(str(part))
+		switch part.Tag {
+		case 0:
+			if i != 0 || !strings.HasPrefix(value, piece) {
+				return false
+			}
+			value = value[len(piece):]
+		case 1:
+			index := strings.Index(value, piece)
+			if index < 0 {
+				return false
+			}
+			value = value[index+len(piece):]
+		case 2:
+			if i != len(parts)-1 || !strings.HasSuffix(value, piece) {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+// inScope входит ли запись в область поиска относительно базовой
+func inScope(base *ldap.DN, dn *ldap.DN, scope int64) bool {
+	switch scope {
+	case ldap.ScopeBaseObject:
+		return base.EqualFold(dn)
+	case ldap.ScopeSingleLevel:
+		return len(dn.RDNs) == len(base.RDNs)+1 && base.AncestorOfFold(dn)
+	case ldap.ScopeWholeSubtree:
+		return base.EqualFold(dn) || base.AncestorOfFold(dn)
+	}
+
+	return false
+}
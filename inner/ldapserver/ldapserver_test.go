@@ -0,0 +1,383 @@
+package ldapserver
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"github.com/go-ldap/ldap/v3"
+	assertpackage "github.com/stretchr/testify/assert"
+	"idm/inner/credential"
+	"idm/inner/database"
+	"idm/inner/employee"
+	"idm/inner/mfa"
+	"idm/inner/role"
+	"idm/inner/serviceaccount"
+	"math/big"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// StubEmployees хранит сотрудников в памяти
+type StubEmployees struct {
+	employees []employee.Response
+}
+
+func (s *StubEmployees) FindAll() ([]employee.Response, error) {
+	return s.employees, nil
+}
+
+func (s *StubEmployees) FindByUserName(userName string) (employee.Response, error) {
+	for _, found := range s.employees {
+		if strings.EqualFold(found.UserName, userName) {
+			return found, nil
+		}
+	}
+	return employee.Response{}, database.ErrRecordNotFound
+}
+
+// StubRoles хранит роли и их участников в памяти
+type StubRoles struct {
+	roles   []role.Response
+	members map[int64][]int64
+}
+
+func (s *StubRoles) FindAll() ([]role.Response, error) {
+	return s.roles, nil
+}
+
+func (s *StubRoles) FindEmployeeIds(roleId int64) ([]int64, error) {
+	return s.members[roleId], nil
+}
+
+// StubCredentials пароли сотрудников в открытом виде
+type StubCredentials map[int64]string
+
+func (s StubCredentials) Verify(employeeId int64, password string) error {
+	if s[employeeId] != password {
+		return credential.ErrInvalidCredentials
+	}
+	return nil
+}
+
+// StubMFA у сотрудника с id 2 настроен TOTP с единственным верным кодом 123456
+type StubMFA struct{}
+
+func (s StubMFA) Methods(employeeId int64) ([]string, error) {
+	if employeeId == 2 {
+		return []string{mfa.MethodTOTP}, nil
+	}
+	return nil, nil
+}
+
+func (s StubMFA) Required(employeeId int64) (bool, error) {
+	return employeeId == 2, nil
+}
+
+func (s StubMFA) VerifyTOTP(employeeId int64, code string) error {
+	if code != "123456" {
+		return mfa.ErrInvalidCode
+	}
+	return nil
+}
+
+// StubServiceAccounts единственный ключ сервисной учётной записи jenkins
+type StubServiceAccounts struct {
+	scopes []string
+}
+
+func (s StubServiceAccounts) Authenticate(secret string) (serviceaccount.Principal, error) {
+	if secret != "idm_key" {
+		return serviceaccount.Principal{}, serviceaccount.ErrInvalidKey
+	}
+	return serviceaccount.Principal{ServiceAccountId: 1, Name: "jenkins", Scopes: s.scopes}, nil
+}
+
+const (
+	aliceDN = "uid=alice,ou=people,dc=example,dc=com"
+	bobDN   = "uid=bob,ou=people,dc=example,dc=com"
+)
+
+func newTestServer(t *testing.T) (*Server, string) {
+	managerId := int64(1)
+	employees := &StubEmployees{employees: []employee.Response{
+		{Id: 1, Name: "Alice Smith", UserName: "alice", Email: "alice@example.com", Title: "CTO", Status: employee.StatusActive},
+		{Id: 2, Name: "Bob", UserName: "bob", ManagerId: &managerId, Department: "R&D", Status: employee.StatusActive},
+		{Id: 3, Name: "Carol", UserName: "carol", Status: employee.StatusDisabled},
+		{Id: 4, Name: "Dave"},
+	}}
+	roles := &StubRoles{
+		roles:   []role.Response{{Id: 1, Name: "Developers"}, {Id: 2, Name: "Admins"}},
+		members: map[int64][]int64{1: {1, 2, 3}, 2: {1}},
+	}
+	credentials := StubCredentials{1: "alice-password", 2: "bob-password", 3: "carol-password"}
+
+	server, err := NewServer(employees, roles, credentials, "dc=example,dc=com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.UseMFA(StubMFA{})
+	server.UseServiceAccounts(StubServiceAccounts{scopes: []string{serviceaccount.ScopeEmployeesRead}})
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() { _ = server.Serve(listener) }()
+	t.Cleanup(func() { _ = server.Close() })
+
+	return server, "ldap://" + listener.Addr().String()
+}
+
+func dial(t *testing.T, url string) *ldap.Conn {
+	conn, err := ldap.DialURL(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return conn
+}
+
+func search(conn *ldap.Conn, base string, scope int, filter string, attributes ...string) (*ldap.SearchResult, error) {
+	return conn.Search(ldap.NewSearchRequest(base, scope, ldap.NeverDerefAliases, 0, 0, false, filter, attributes, nil))
+}
+
+func isCode(err error, code uint16) bool {
+	var ldapErr *ldap.Error
+	return errors.As(err, &ldapErr) && ldapErr.ResultCode == code
+}
+
+func TestBind(t *testing.T) {
+	assert := assertpackage.New(t)
+	_, url := newTestServer(t)
+
+	t.Run("should bind an employee with a password", func(t *testing.T) {
+		conn := dial(t, url)
+
+		assert.Nil(conn.Bind(aliceDN, "alice-password"))
+		identity, err := conn.WhoAmI(nil)
+		assert.Nil(err)
+		assert.Equal("dn:"+aliceDN, identity.AuthzID)
+	})
+
+	t.Run("should reject wrong, unknown and disabled accounts alike", func(t *testing.T) {
+		conn := dial(t, url)
+
+		for _, bind := range [][2]string{
+			{aliceDN, "wrong"},
+			{"uid=nobody,ou=people,dc=example,dc=com", "x"},
+			{"uid=carol,ou=people,dc=example,dc=com", "carol-password"},
+			{"uid=alice,ou=groups,dc=example,dc=com", "alice-password"},
+			{"not a dn", "x"},
+		} {
+			assert.True(isCode(conn.Bind(bind[0], bind[1]), ldap.LDAPResultInvalidCredentials), bind[0])
+		}
+		_, err := conn.SimpleBind(&ldap.SimpleBindRequest{Username: aliceDN, AllowEmptyPassword: true})
+		assert.True(isCode(err, ldap.LDAPResultUnwillingToPerform))
+	})
+
+	t.Run("should require a TOTP code appended to the password", func(t *testing.T) {
+		conn := dial(t, url)
+
+		assert.True(isCode(conn.Bind(bobDN, "bob-password"), ldap.LDAPResultInvalidCredentials))
+		assert.True(isCode(conn.Bind(bobDN, "bob-password000000"), ldap.LDAPResultInvalidCredentials))
+		assert.Nil(conn.Bind(bobDN, "bob-password123456"))
+	})
+
+	t.Run("should bind a service account with an api key", func(t *testing.T) {
+		conn := dial(t, url)
+
+		assert.Nil(conn.Bind("cn=jenkins,ou=services,dc=example,dc=com", "idm_key"))
+		assert.True(isCode(conn.Bind("cn=other,ou=services,dc=example,dc=com", "idm_key"), ldap.LDAPResultInvalidCredentials))
+	})
+
+	t.Run("should refuse service accounts without the read scope", func(t *testing.T) {
+		server, url := newTestServer(t)
+		server.UseServiceAccounts(StubServiceAccounts{scopes: []string{serviceaccount.ScopeSCIM}})
+		conn := dial(t, url)
+
+		assert.True(isCode(conn.Bind("cn=jenkins,ou=services,dc=example,dc=com", "idm_key"), ldap.LDAPResultInvalidCredentials))
+	})
+}
+
+func TestSearch(t *testing.T) {
+	assert := assertpackage.New(t)
+	_, url := newTestServer(t)
+
+	t.Run("should expose the root DSE without a bind", func(t *testing.T) {
+		conn := dial(t, url)
+
+		result, err := search(conn, "", ldap.ScopeBaseObject, "(objectClass=*)")
+
+		assert.Nil(err)
+		assert.Len(result.Entries, 1)
+		assert.Equal("dc=example,dc=com", result.Entries[0].GetAttributeValue("namingContexts"))
+	})
+
+	t.Run("should require a bind for the directory", func(t *testing.T) {
+		conn := dial(t, url)
+
+		_, err := search(conn, "dc=example,dc=com", ldap.ScopeWholeSubtree, "(uid=alice)")
+
+		assert.True(isCode(err, ldap.LDAPResultInsufficientAccessRights))
+	})
+
+	t.Run("should return active employees as inetOrgPerson", func(t *testing.T) {
+		conn := dial(t, url)
+		assert.Nil(conn.Bind(aliceDN, "alice-password"))
+
+		result, err := search(conn, "dc=example,dc=com", ldap.ScopeWholeSubtree, "(objectClass=inetOrgPerson)")
+
+		assert.Nil(err)
+		assert.Len(result.Entries, 2)
+		alice := result.Entries[0]
+		assert.Equal(aliceDN, alice.DN)
+		assert.Equal("Alice Smith", alice.GetAttributeValue("cn"))
+		assert.Equal("Alice", alice.GetAttributeValue("givenName"))
+		assert.Equal("Smith", alice.GetAttributeValue("sn"))
+		assert.Equal("alice@example.com", alice.GetAttributeValue("mail"))
+		assert.Equal([]string{
+			"cn=Developers,ou=groups,dc=example,dc=com", "cn=Admins,ou=groups,dc=example,dc=com",
+		}, alice.GetAttributeValues("memberOf"))
+		assert.Empty(alice.GetAttributeValue("modifyTimestamp"))
+		assert.Equal(aliceDN, result.Entries[1].GetAttributeValue("manager"))
+		assert.Equal("R&D", result.Entries[1].GetAttributeValue("departmentNumber"))
+	})
+
+	t.Run("should return roles as groupOfNames with members", func(t *testing.T) {
+		conn := dial(t, url)
+		assert.Nil(conn.Bind(aliceDN, "alice-password"))
+
+		result, err := search(conn, "ou=groups,dc=example,dc=com", ldap.ScopeSingleLevel,
+			"(&(objectClass=groupOfNames)(member=UID=Bob,OU=People,DC=example,DC=com))", "cn", "member")
+
+		assert.Nil(err)
+		assert.Len(result.Entries, 1)
+		assert.Equal("Developers", result.Entries[0].GetAttributeValue("cn"))
+		// отключённые сотрудники в каталог не попадают
+		assert.Equal([]string{aliceDN, bobDN}, result.Entries[0].GetAttributeValues("member"))
+	})
+
+	t.Run("should evaluate standard filters", func(t *testing.T) {
+		conn := dial(t, url)
+		assert.Nil(conn.Bind(aliceDN, "alice-password"))
+
+		for filter, expected := range map[string]int{
+			"(uid=ali*)":                       1,
+			"(cn=*Smi*)":                       1,
+			"(|(uid=alice)(uid=bob))":          2,
+			"(!(uid=alice))":                   6,
+			"(&(objectClass=person)(title=*))": 1,
+			"(employeeNumber>=2)":              1,
+			"(employeeNumber<=2)":              2,
+			"(mail=ALICE@EXAMPLE.COM)":         1,
+			"(description=*)":                  0,
+		} {
+			result, err := search(conn, "dc=example,dc=com", ldap.ScopeWholeSubtree, filter)
+			assert.Nil(err, filter)
+			assert.Len(result.Entries, expected, filter)
+		}
+	})
+
+	t.Run("should honour scope, attribute selection and size limit", func(t *testing.T) {
+		conn := dial(t, url)
+		assert.Nil(conn.Bind(aliceDN, "alice-password"))
+
+		result, err := search(conn, aliceDN, ldap.ScopeBaseObject, "(objectClass=*)", "uid", "+")
+		assert.Nil(err)
+		assert.Len(result.Entries, 1)
+		assert.Equal("alice", result.Entries[0].GetAttributeValue("uid"))
+		assert.Empty(result.Entries[0].GetAttributeValue("cn"))
+		assert.NotEmpty(result.Entries[0].GetAttributeValue("modifyTimestamp"))
+
+		result, err = search(conn, "dc=example,dc=com", ldap.ScopeSingleLevel, "(objectClass=*)")
+		assert.Nil(err)
+		assert.Len(result.Entries, 2)
+
+		_, err = search(conn, "ou=nowhere,dc=example,dc=com", ldap.ScopeWholeSubtree, "(objectClass=*)")
+		assert.True(isCode(err, ldap.LDAPResultNoSuchObject))
+
+		_, err = conn.Search(ldap.NewSearchRequest("dc=example,dc=com", ldap.ScopeWholeSubtree, ldap.NeverDerefAliases,
+			1, 0, false, "(objectClass=*)", nil, nil))
+		assert.True(isCode(err, ldap.LDAPResultSizeLimitExceeded))
+	})
+
+	t.Run("should compare attribute values", func(t *testing.T) {
+		conn := dial(t, url)
+		assert.Nil(conn.Bind(aliceDN, "alice-password"))
+
+		matched, err := conn.Compare("cn=Admins,ou=groups,dc=example,dc=com", "member", aliceDN)
+		assert.Nil(err)
+		assert.True(matched)
+
+		matched, err = conn.Compare("cn=Admins,ou=groups,dc=example,dc=com", "member", bobDN)
+		assert.Nil(err)
+		assert.False(matched)
+	})
+
+	t.Run("should refuse modifications", func(t *testing.T) {
+		conn := dial(t, url)
+		assert.Nil(conn.Bind(aliceDN, "alice-password"))
+
+		err := conn.Del(ldap.NewDelRequest(bobDN, nil))
+		assert.True(isCode(err, ldap.LDAPResultUnwillingToPerform))
+
+		modify := ldap.NewModifyRequest(aliceDN, nil)
+		modify.Replace("title", []string{"CEO"})
+		assert.True(isCode(conn.Modify(modify), ldap.LDAPResultUnwillingToPerform))
+	})
+}
+
+func TestStartTLS(t *testing.T) {
+	assert := assertpackage.New(t)
+	server, url := newTestServer(t)
+	server.UseTLS(&tls.Config{Certificates: []tls.Certificate{selfSigned(t)}})
+
+	t.Run("should require TLS before a password bind", func(t *testing.T) {
+		conn := dial(t, url)
+
+		assert.True(isCode(conn.Bind(aliceDN, "alice-password"), ldap.LDAPResultConfidentialityRequired))
+		assert.Nil(conn.StartTLS(&tls.Config{InsecureSkipVerify: true}))
+		assert.Nil(conn.Bind(aliceDN, "alice-password"))
+
+		result, err := search(conn, "dc=example,dc=com", ldap.ScopeWholeSubtree, "(uid=bob)")
+		assert.Nil(err)
+		assert.Len(result.Entries, 1)
+	})
+}
+
+func TestNewServer(t *testing.T) {
+	assert := assertpackage.New(t)
+
+	t.Run("should reject an invalid base dn", func(t *testing.T) {
+		_, err := NewServer(&StubEmployees{}, &StubRoles{}, StubCredentials{}, "not a dn")
+		assert.NotNil(err)
+
+		_, err = NewServer(&StubEmployees{}, &StubRoles{}, StubCredentials{}, "")
+		assert.NotNil(err)
+	})
+}
+
+func selfSigned(t *testing.T) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
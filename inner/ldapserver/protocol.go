@@ -0,0 +1,181 @@
+package ldapserver
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"io"
+)
+
+// коды операций LDAPv3 (RFC 4511, раздел 4.2 и далее)
+const (
+	opBindRequest            ber.Tag = 0
+	opBindResponse           ber.Tag = 1
+	opUnbindRequest          ber.Tag = 2
+	opSearchRequest          ber.Tag = 3
+	opSearchEntry            ber.Tag = 4
+	opSearchDone             ber.Tag = 5
+	opModifyRequest          ber.Tag = 6
+	opModifyResponse         ber.Tag = 7
+	opAddRequest             ber.Tag = 8
+	opAddResponse            ber.Tag = 9
+	opDeleteRequest          ber.Tag = 10
+	opDeleteResponse         ber.Tag = 11
+	opModifyDNRequest        ber.Tag = 12
+	opModifyDNResponse       ber.Tag = 13
+	opCompareRequest         ber.Tag = 14
+	opCompareResponse        ber.Tag = 15
+	opAbandonRequest         ber.Tag = 16
+	opExtendedRequest        ber.Tag = 23
+	opExtendedResponse       ber.Tag = 24
+	authSimple               ber.Tag = 0
+	controlsTag              ber.Tag = 0
+	extendedNameTag          ber.Tag = 0
+	extendedNameResponseTag  ber.Tag = 10
+	extendedValueResponseTag ber.Tag = 11
+)
+
+// коды результата
+const (
+	resultSuccess                      = 0
+	resultOperationsError              = 1
+	resultProtocolError                = 2
+	resultSizeLimitExceeded            = 4
+	resultCompareFalse                 = 5
+	resultCompareTrue                  = 6
+	resultAuthMethodNotSupported       = 7
+	resultUnavailableCriticalExtension = 12
+	resultConfidentialityRequired      = 13
+	resultNoSuchObject                 = 32
+	resultInvalidDNSyntax              = 34
+	resultInvalidCredentials           = 49
+	resultInsufficientAccessRights     = 50
+	resultUnwillingToPerform           = 53
+	resultOther                        = 80
+)
+
+// oidStartTLS расширенная операция StartTLS (RFC 4511, раздел 4.14)
+const oidStartTLS = "1.3.6.1.4.1.1466.20037"
+
+// maxMessageSize ограничение размера запроса: клиенту read-only каталога большие сообщения не нужны
+const maxMessageSize = 1 << 20
+
+var errMessageTooLarge = errors.New("ldap message is too large")
+
+// readMessage прочитать одно сообщение LDAP. Длина проверяется до выделения памяти,
+// чтобы заголовок с огромной длиной не заставил сервер её зарезервировать.
+func readMessage(reader *bufio.Reader) (*ber.Packet, error) {
+	header := make([]byte, 2, 6)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		return nil, err
+	}
+	if header[0] != 0x30 {
+		return nil, fmt.Errorf("unexpected ldap message tag %#x", header[0])
+	}
+
+	length := int(header[1])
+	if length&0x80 != 0 {
+		count := length & 0x7f
+		if count == 0 || count > 4 {
+			return nil, fmt.Errorf("unsupported ldap message length encoding %#x", header[1])
+		}
+		extra := make([]byte, count)
+		if _, err := io.ReadFull(reader, extra); err != nil {
+			return nil, err
+		}
+		header = append(header, extra...)
+		length = 0
+		for _, b := range extra {
+			length = length<<8 | int(b)
+		}
+	}
+	if length > maxMessageSize {
+		return nil, errMessageTooLarge
+	}
+
+	message := make([]byte, len(header)+length)
+	copy(message, header)
+	if _, err := io.ReadFull(reader, message[len(header):]); err != nil {
+		return nil, err
+	}
+
+	return ber.DecodePacketErr(message)
+}
+
+func envelope(messageId int64) *ber.Packet {
+	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+	packet.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, messageId, "Message ID"))
+	return packet
+}
+
+// result ответ LDAPResult с операцией tag
+func result(messageId int64, tag ber.Tag, code int, matchedDN string, message string) *ber.Packet {
+	packet := envelope(messageId)
+	packet.AppendChild(resultBody(tag, code, matchedDN, message))
+	return packet
+}
+
+func resultBody(tag ber.Tag, code int, matchedDN string, message string) *ber.Packet {
+	body := ber.Encode(ber.ClassApplication, ber.TypeConstructed, tag, nil, "Response")
+	body.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, code, "Result Code"))
+	body.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, matchedDN, "Matched DN"))
+	body.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, message, "Diagnostic Message"))
+	return body
+}
+
+// extendedResult ответ на расширенную операцию с её OID и значением, если они есть
+func extendedResult(messageId int64, code int, message string, name string, value *string) *ber.Packet {
+	packet := envelope(messageId)
+	body := resultBody(opExtendedResponse, code, "", message)
+	if name != "" {
+		body.AppendChild(ber.NewString(ber.ClassContext, ber.TypePrimitive, extendedNameResponseTag, name, "Response Name"))
+	}
+	if value != nil {
+		body.AppendChild(ber.NewString(ber.ClassContext, ber.TypePrimitive, extendedValueResponseTag, *value, "Response Value"))
+	}
+	packet.AppendChild(body)
+	return packet
+}
+
+// searchEntry ответ SearchResultEntry; при typesOnly передаются только имена атрибутов
+func searchEntry(messageId int64, e *entry, attributes []string, typesOnly bool) *ber.Packet {
+	packet := envelope(messageId)
+	body := ber.Encode(ber.ClassApplication, ber.TypeConstructed, opSearchEntry, nil, "Search Result Entry")
+	body.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, e.dn, "Object Name"))
+
+	list := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Attributes")
+	for _, a := range e.selected(attributes) {
+		attribute := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Attribute")
+		attribute.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, a.name, "Type"))
+		values := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "Values")
+		if !typesOnly {
+			for _, value := range a.values {
+				values.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, value, "Value"))
+			}
+		}
+		attribute.AppendChild(values)
+		list.AppendChild(attribute)
+	}
+	body.AppendChild(list)
+
+	packet.AppendChild(body)
+	return packet
+}
+
+// responseTag операция, которой отвечают на запрос; Unbind и Abandon ответа не имеют
+var responseTag = map[ber.Tag]ber.Tag{
+	opBindRequest:     opBindResponse,
+	opSearchRequest:   opSearchDone,
+	opCompareRequest:  opCompareResponse,
+	opExtendedRequest: opExtendedResponse,
+	opModifyRequest:   opModifyResponse,
+	opAddRequest:      opAddResponse,
+	opDeleteRequest:   opDeleteResponse,
+	opModifyDNRequest: opModifyDNResponse,
+}
+
+// str строковое значение примитивного элемента
+func str(packet *ber.Packet) string {
+	return packet.Data.String()
+}
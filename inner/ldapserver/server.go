@@ -0,0 +1,503 @@
+package ldapserver
+
+import (
+	"bufio"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap/v3"
+	"idm/inner/credential"
+	"idm/inner/database"
+	"idm/inner/employee"
+	"idm/inner/mfa"
+	"idm/inner/role"
+	"idm/inner/serviceaccount"
+	"log"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	peopleOU = "ou=people"
+	groupsOU = "ou=groups"
+	// oidWhoAmI расширенная операция «Who am I?» (RFC 4532)
+	oidWhoAmI = "1.3.6.1.4.1.4203.1.11.3"
+	// totpDigits длина кода TOTP, который дописывается к паролю
+	totpDigits = 6
+)
+
+type Employees interface {
+	FindAll() ([]employee.Response, error)
+	FindByUserName(userName string) (employee.Response, error)
+}
+
+type Roles interface {
+	FindAll() ([]role.Response, error)
+	FindEmployeeIds(roleId int64) ([]int64, error)
+}
+
+type Credentials interface {
+	Verify(employeeId int64, password string) error
+}
+
+// MFA второй фактор при простой привязке: код TOTP дописывается к паролю
+type MFA interface {
+	Methods(employeeId int64) ([]string, error)
+	Required(employeeId int64) (bool, error)
+	VerifyTOTP(employeeId int64, code string) error
+}
+
+// ServiceAccounts приложения привязываются как cn=<имя>,ou=services с API-ключом вместо пароля
+type ServiceAccounts interface {
+	Authenticate(secret string) (serviceaccount.Principal, error)
+}
+
+// Server LDAPv3-сервер только для чтения: сотрудники – записи inetOrgPerson в ou=people,
+// роли – groupOfNames в ou=groups. Данные берутся из сервисов при каждом запросе.
+type Server struct {
+	employees   Employees
+	roles       Roles
+	credentials Credentials
+	mfa         MFA
+	services    ServiceAccounts
+	tls         *tls.Config
+	baseDN      string
+	base        *ldap.DN
+	idleTimeout time.Duration
+
+	mu        sync.Mutex
+	listeners []net.Listener
+	conns     map[net.Conn]struct{}
+}
+
+func NewServer(employees Employees, roles Roles, credentials Credentials, baseDN string) (*Server, error) {
+	base, err := ldap.ParseDN(baseDN)
+	if err != nil || len(base.RDNs) == 0 {
+		return nil, fmt.Errorf("invalid base dn %q", baseDN)
+	}
+
+	return &Server{
+		employees:   employees,
+		roles:       roles,
+		credentials: credentials,
+		baseDN:      base.String(),
+		base:        base,
+		idleTimeout: 5 * time.Minute,
+		conns:       map[net.Conn]struct{}{},
+	}, nil
+}
+
+// UseMFA учитывать многофакторную аутентификацию при привязке сотрудников
+func (s *Server) UseMFA(verifier MFA) {
+	s.mfa = verifier
+}
+
+// UseServiceAccounts разрешить привязку сервисным учётным записям с правом employees:read
+func (s *Server) UseServiceAccounts(services ServiceAccounts) {
+	s.services = services
+}
+
+// UseTLS включить StartTLS. После этого привязка по паролю без TLS отклоняется.
+func (s *Server) UseTLS(config *tls.Config) {
+	s.tls = config
+}
+
+func (s *Server) SetIdleTimeout(timeout time.Duration) {
+	s.idleTimeout = timeout
+}
+
+// ListenAndServe принимать соединения на addr до вызова Close
+func (s *Server) ListenAndServe(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	return s.Serve(listener)
+}
+
+// Serve принимать соединения на listener; для LDAPS передаётся listener из tls.Listen
+func (s *Server) Serve(listener net.Listener) error {
+	s.mu.Lock()
+	s.listeners = append(s.listeners, listener)
+	s.mu.Unlock()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		_, secure := conn.(*tls.Conn)
+		go s.serve(conn, secure)
+	}
+}
+
+// Close остановить приём соединений и закрыть открытые
+func (s *Server) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, listener := range s.listeners {
+		_ = listener.Close()
+	}
+	for conn := range s.conns {
+		_ = conn.Close()
+	}
+
+	return nil
+}
+
+// session состояние одного соединения
+type session struct {
+	conn     net.Conn
+	reader   *bufio.Reader
+	secure   bool
+	identity string
+}
+
+func (s *Server) serve(conn net.Conn, secure bool) {
+	c := &session{conn: conn, reader: bufio.NewReader(conn), secure: secure}
+
+	s.mu.Lock()
+	s.conns[conn] = struct{}{}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, c.conn)
+		s.mu.Unlock()
+		_ = c.conn.Close()
+	}()
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("ldap: malformed request from %s: %v", conn.RemoteAddr(), r)
+		}
+	}()
+
+	for {
+		if s.idleTimeout > 0 {
+			_ = c.conn.SetReadDeadline(time.Now().Add(s.idleTimeout))
+		}
+		packet, err := readMessage(c.reader)
+		if err != nil || len(packet.Children) < 2 {
+			return
+		}
+		messageId, ok := packet.Children[0].Value.(int64)
+		operation := packet.Children[1]
+		if !ok || operation.ClassType != ber.ClassApplication {
+			return
+		}
+		if operation.Tag == opUnbindRequest {
+			return
+		}
+		if operation.Tag == opAbandonRequest {
+			continue
+		}
+
+		response, upgrade := s.handle(c, messageId, operation, packet.Children[2:])
+		if response == nil {
+			return
+		}
+		if _, err := c.conn.Write(response); err != nil {
+			return
+		}
+		if upgrade {
+			secured := tls.Server(c.conn, s.tls)
+			if err := secured.Handshake(); err != nil {
+				return
+			}
+			s.mu.Lock()
+			delete(s.conns, c.conn)
+			s.conns[secured] = struct{}{}
+			s.mu.Unlock()
+			c.conn, c.reader, c.secure = secured, bufio.NewReader(secured), true
+		}
+	}
+}
+
+// handle выполнить операцию. Возвращает байты ответа (nil – закрыть соединение)
+// и признак перехода на TLS после отправки ответа.
+func (s *Server) handle(c *session, messageId int64, operation *ber.Packet, rest []*ber.Packet) ([]byte, bool) {
+	tag, ok := responseTag[operation.Tag]
+	if !ok {
+		return nil, false
+	}
+	if critical := unsupportedCritical(rest); critical != "" {
+		return result(messageId, tag, resultUnavailableCriticalExtension, "",
+			"unsupported critical control "+critical).Bytes(), false
+	}
+
+	switch operation.Tag {
+	case opBindRequest:
+		return s.bind(c, messageId, operation).Bytes(), false
+	case opSearchRequest:
+		return s.search(c, messageId, operation), false
+	case opCompareRequest:
+		return s.compare(c, messageId, operation).Bytes(), false
+	case opExtendedRequest:
+		return s.extended(c, messageId, operation)
+	}
+
+	return result(messageId, tag, resultUnwillingToPerform, "", "directory is read-only").Bytes(), false
+}
+
+// unsupportedCritical OID первого критичного элемента управления: сервер не поддерживает ни одного
+func unsupportedCritical(rest []*ber.Packet) string {
+	for _, packet := range rest {
+		if packet.ClassType != ber.ClassContext || packet.Tag != controlsTag {
+			continue
+		}
+		for _, control := range packet.Children {
+			if len(control.Children) > 1 {
+				if critical, ok := control.Children[1].Value.(bool); ok && critical {
+					return str(control.Children[0])
+				}
+			}
+		}
+	}
+
+	return ""
+}
+
+func (s *Server) bind(c *session, messageId int64, operation *ber.Packet) *ber.Packet {
+	c.identity = ""
+	if len(operation.Children) < 3 {
+		return result(messageId, opBindResponse, resultProtocolError, "", "malformed bind request")
+	}
+	if version, _ := operation.Children[0].Value.(int64); version != 3 {
+		return result(messageId, opBindResponse, resultProtocolError, "", "only LDAPv3 is supported")
+	}
+
+	name := str(operation.Children[1])
+	authentication := operation.Children[2]
+	if authentication.ClassType != ber.ClassContext || authentication.Tag != authSimple {
+		return result(messageId, opBindResponse, resultAuthMethodNotSupported, "", "only simple bind is supported")
+	}
+	password := str(authentication)
+
+	switch {
+	case name == "" && password == "":
+		return result(messageId, opBindResponse, resultSuccess, "", "")
+	case password == "":
+		return result(messageId, opBindResponse, resultUnwillingToPerform, "", "unauthenticated bind is not allowed")
+	case s.tls != nil && !c.secure:
+		return result(messageId, opBindResponse, resultConfidentialityRequired, "", "use StartTLS before binding")
+	}
+
+	ok, err := s.authenticate(name, password)
+	if err != nil {
+		log.Printf("ldap: error binding as %s: %v", name, err)
+		return result(messageId, opBindResponse, resultOther, "", "internal error")
+	}
+	if !ok {
+		return result(messageId, opBindResponse, resultInvalidCredentials, "", "")
+	}
+
+	c.identity = name
+	return result(messageId, opBindResponse, resultSuccess, "", "")
+}
+
+// authenticate проверить пароль сотрудника или API-ключ сервисной учётной записи.
+// Любая неудача выглядит одинаково, чтобы не раскрывать, существует ли запись.
+func (s *Server) authenticate(name string, password string) (bool, error) {
+	dn, err := ldap.ParseDN(name)
+	if err != nil || len(dn.RDNs) != len(s.base.RDNs)+2 || !s.base.AncestorOfFold(dn) {
+		return false, nil
+	}
+	rdn := dn.RDNs[0].Attributes[0]
+	ou := dn.RDNs[1].Attributes[0]
+	if !strings.EqualFold(ou.Type, "ou") {
+		return false, nil
+	}
+
+	switch {
+	case strings.EqualFold(ou.Value, "people") && strings.EqualFold(rdn.Type, "uid"):
+		return s.authenticateEmployee(rdn.Value, password)
+	case strings.EqualFold(ou.Value, "services") && strings.EqualFold(rdn.Type, "cn") && s.services != nil:
+		principal, err := s.services.Authenticate(password)
+		if err != nil {
+			if errors.Is(err, serviceaccount.ErrInvalidKey) {
+				return false, nil
+			}
+			return false, err
+		}
+		return strings.EqualFold(principal.Name, rdn.Value) &&
+			serviceaccount.Allows(principal.Scopes, serviceaccount.ScopeEmployeesRead), nil
+	}
+
+	return false, nil
+}
+
+// authenticateEmployee у сотрудника с настроенным TOTP последние шесть цифр пароля – код.
+// Сотрудникам, которым нужен второй фактор, но у которых есть только WebAuthn, привязка недоступна.
+func (s *Server) authenticateEmployee(userName string, password string) (bool, error) {
+	found, err := s.employees.FindByUserName(userName)
+	if err != nil {
+		if errors.Is(err, database.ErrRecordNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	if found.Status != employee.StatusActive {
+		return false, nil
+	}
+
+	code := ""
+	if s.mfa != nil {
+		methods, err := s.mfa.Methods(found.Id)
+		if err != nil {
+			return false, err
+		}
+		required, err := s.mfa.Required(found.Id)
+		if err != nil {
+			return false, err
+		}
+		switch {
+		case slices.Contains(methods, mfa.MethodTOTP):
+			if len(password) <= totpDigits {
+				return false, nil
+			}
+			password, code = password[:len(password)-totpDigits], password[len(password)-totpDigits:]
+		case len(methods) > 0 || required:
+			return false, nil
+		}
+	}
+
+	if err := s.credentials.Verify(found.Id, password); err != nil {
+		if errors.Is(err, credential.ErrInvalidCredentials) || errors.Is(err, credential.ErrLocked) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	if code != "" {
+		if err := s.mfa.VerifyTOTP(found.Id, code); err != nil {
+			if errors.Is(err, mfa.ErrInvalidCode) || errors.Is(err, mfa.ErrNotEnrolled) {
+				return false, nil
+			}
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+func (s *Server) search(c *session, messageId int64, operation *ber.Packet) []byte {
+	done := func(code int, message string) []byte {
+		return result(messageId, opSearchDone, code, "", message).Bytes()
+	}
+	if len(operation.Children) < 8 {
+		return done(resultProtocolError, "malformed search request")
+	}
+
+	base, err := ldap.ParseDN(str(operation.Children[0]))
+	if err != nil {
+		return done(resultInvalidDNSyntax, "invalid base dn")
+	}
+	scope, _ := operation.Children[1].Value.(int64)
+	sizeLimit, _ := operation.Children[3].Value.(int64)
+	typesOnly, _ := operation.Children[5].Value.(bool)
+	filter := operation.Children[6]
+	var attributes []string
+	for _, a := range operation.Children[7].Children {
+		attributes = append(attributes, str(a))
+	}
+
+	var response []byte
+	if len(base.RDNs) == 0 && scope == ldap.ScopeBaseObject {
+		// корневая запись доступна без привязки: по ней клиенты узнают возможности сервера
+		if root := s.rootDSE(); root.matches(filter) {
+			response = append(response, searchEntry(messageId, root, attributes, typesOnly).Bytes()...)
+		}
+		return append(response, done(resultSuccess, "")...)
+	}
+
+	if c.identity == "" {
+		return done(resultInsufficientAccessRights, "bind is required")
+	}
+
+	t, err := s.snapshot()
+	if err != nil {
+		log.Printf("ldap: error building directory: %v", err)
+		return done(resultOther, "internal error")
+	}
+	if len(base.RDNs) > 0 && t.find(base) == nil {
+		return done(resultNoSuchObject, "")
+	}
+
+	count := int64(0)
+	for _, e := range t.entries {
+		if !inScope(base, e.parsed, scope) || !e.matches(filter) {
+			continue
+		}
+		if sizeLimit > 0 && count == sizeLimit {
+			return append(response, done(resultSizeLimitExceeded, "")...)
+		}
+		response = append(response, searchEntry(messageId, e, attributes, typesOnly).Bytes()...)
+		count++
+	}
+
+	return append(response, done(resultSuccess, "")...)
+}
+
+func (s *Server) compare(c *session, messageId int64, operation *ber.Packet) *ber.Packet {
+	if len(operation.Children) < 2 || len(operation.Children[1].Children) < 2 {
+		return result(messageId, opCompareResponse, resultProtocolError, "", "malformed compare request")
+	}
+	if c.identity == "" {
+		return result(messageId, opCompareResponse, resultInsufficientAccessRights, "", "bind is required")
+	}
+
+	dn, err := ldap.ParseDN(str(operation.Children[0]))
+	if err != nil {
+		return result(messageId, opCompareResponse, resultInvalidDNSyntax, "", "invalid dn")
+	}
+	t, err := s.snapshot()
+	if err != nil {
+		log.Printf("ldap: error building directory: %v", err)
+		return result(messageId, opCompareResponse, resultOther, "", "internal error")
+	}
+	e := t.find(dn)
+	if e == nil {
+		return result(messageId, opCompareResponse, resultNoSuchObject, "", "")
+	}
+
+	name, asserted := str(operation.Children[1].Children[0]), str(operation.Children[1].Children[1])
+	if slices.ContainsFunc(e.values(name), func(value string) bool { return equal(name, value, asserted) }) {
+		return result(messageId, opCompareResponse, resultCompareTrue, "", "")
+	}
+
+	return result(messageId, opCompareResponse, resultCompareFalse, "", "")
+}
+
+func (s *Server) extended(c *session, messageId int64, operation *ber.Packet) ([]byte, bool) {
+	name := ""
+	if len(operation.Children) > 0 && operation.Children[0].Tag == extendedNameTag {
+		name = str(operation.Children[0])
+	}
+
+	switch name {
+	case oidStartTLS:
+		if s.tls == nil {
+			return extendedResult(messageId, resultProtocolError, "StartTLS is not configured", name, nil).Bytes(), false
+		}
+		if c.secure {
+			return extendedResult(messageId, resultOperationsError, "TLS is already established", name, nil).Bytes(), false
+		}
+		return extendedResult(messageId, resultSuccess, "", name, nil).Bytes(), true
+	case oidWhoAmI:
+		identity := ""
+		if c.identity != "" {
+			identity = "dn:" + c.identity
+		}
+		return extendedResult(messageId, resultSuccess, "", "", &identity).Bytes(), false
+	}
+
+	return extendedResult(messageId, resultProtocolError, "unsupported extended operation "+name, "", nil).Bytes(), false
+}
@@ -0,0 +1,105 @@
+package ldapsync
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// Config подключение к каталогу и правила сопоставления его записей с сотрудниками
+type Config struct {
+	// Source имя источника; позволяет синхронизировать несколько каталогов независимо
+	Source       string `json:"source"`
+	URL          string `json:"url"`
+	StartTLS     bool   `json:"start_tls"`
+	BindDN       string `json:"bind_dn"`
+	BindPassword string `json:"bind_password"`
+	BaseDN       string `json:"base_dn"`
+	UserFilter   string `json:"user_filter"`
+	// GroupBaseDN где искать группы; по умолчанию BaseDN
+	GroupBaseDN     string `json:"group_base_dn"`
+	GroupFilter     string `json:"group_filter"`
+	MemberAttribute string `json:"member_attribute"`
+	// IdAttribute неизменяемый идентификатор записи, переживающий переименование и перенос
+	IdAttribute string  `json:"id_attribute"`
+	Attributes  Mapping `json:"attributes"`
+	// GroupRoles группа (DN или cn) → имя роли, которую получают её участники
+	GroupRoles map[string]string `json:"group_roles"`
+	PageSize   uint32            `json:"page_size"`
+}
+
+// Mapping имена атрибутов каталога для полей сотрудника. Пустое имя – поле не синхронизируется.
+type Mapping struct {
+	UserName       string `json:"user_name"`
+	Name           string `json:"name"`
+	Email          string `json:"email"`
+	Department     string `json:"department"`
+	Title          string `json:"title"`
+	Location       string `json:"location"`
+	EmploymentType string `json:"employment_type"`
+	// Manager атрибут с DN руководителя
+	Manager string `json:"manager"`
+}
+
+// DefaultMapping атрибуты схемы inetOrgPerson
+var DefaultMapping = Mapping{
+	UserName:       "uid",
+	Name:           "cn",
+	Email:          "mail",
+	Department:     "departmentNumber",
+	Title:          "title",
+	Location:       "l",
+	EmploymentType: "employeeType",
+	Manager:        "manager",
+}
+
+// LoadConfig прочитать конфигурацию в формате JSON и заполнить значения по умолчанию
+func LoadConfig(reader io.Reader) (Config, error) {
+	config := Config{Attributes: DefaultMapping}
+	if err := json.NewDecoder(reader).Decode(&config); err != nil {
+		return Config{}, fmt.Errorf("error decoding ldap sync config: %w", err)
+	}
+
+	return config.withDefaults(), nil
+}
+
+func (c Config) withDefaults() Config {
+	if c.Source == "" {
+		c.Source = "ldap"
+	}
+	if c.UserFilter == "" {
+		c.UserFilter = "(objectClass=inetOrgPerson)"
+	}
+	if c.GroupBaseDN == "" {
+		c.GroupBaseDN = c.BaseDN
+	}
+	if c.GroupFilter == "" {
+		c.GroupFilter = "(|(objectClass=groupOfNames)(objectClass=group))"
+	}
+	if c.MemberAttribute == "" {
+		c.MemberAttribute = "member"
+	}
+	if c.IdAttribute == "" {
+		c.IdAttribute = "entryUUID"
+	}
+	if c.PageSize == 0 {
+		c.PageSize = 500
+	}
+
+	return c
+}
+
+// userAttributes атрибуты, запрашиваемые у каталога для пользователей
+func (c Config) userAttributes() []string {
+	attributes := []string{c.IdAttribute, "modifyTimestamp"}
+	for _, name := range []string{
+		c.Attributes.UserName, c.Attributes.Name, c.Attributes.Email, c.Attributes.Department,
+		c.Attributes.Title, c.Attributes.Location, c.Attributes.EmploymentType, c.Attributes.Manager,
+	} {
+		if name != "" {
+			attributes = append(attributes, name)
+		}
+	}
+
+	return attributes
+}
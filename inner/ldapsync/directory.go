@@ -0,0 +1,104 @@
+package ldapsync
+
+import (
+	"encoding/hex"
+	"fmt"
+	"github.com/go-ldap/ldap/v3"
+	"strings"
+)
+
+// Entry запись каталога
+type Entry struct {
+	DN         string
+	Attributes map[string][]string
+}
+
+// Get первое значение атрибута; имена атрибутов LDAP не зависят от регистра
+func (e Entry) Get(name string) string {
+	if values := e.Values(name); len(values) > 0 {
+		return values[0]
+	}
+
+	return ""
+}
+
+func (e Entry) Values(name string) []string {
+	for key, values := range e.Attributes {
+		if strings.EqualFold(key, name) {
+			return values
+		}
+	}
+
+	return nil
+}
+
+// Directory источник записей о пользователях и группах
+type Directory interface {
+	// Users пользователи; если since не пусто – только изменённые начиная с этого modifyTimestamp
+	Users(since string) ([]Entry, error)
+	Groups() ([]Entry, error)
+}
+
+// LDAPDirectory каталог, доступный по протоколу LDAP. Соединение открывается на время каждого запроса.
+type LDAPDirectory struct {
+	config Config
+}
+
+func NewDirectory(config Config) *LDAPDirectory {
+	return &LDAPDirectory{config: config.withDefaults()}
+}
+
+func (d *LDAPDirectory) Users(since string) ([]Entry, error) {
+	filter := d.config.UserFilter
+	if since != "" {
+		filter = "(&" + filter + "(modifyTimestamp>=" + ldap.EscapeFilter(since) + "))"
+	}
+
+	return d.search(d.config.BaseDN, filter, d.config.userAttributes())
+}
+
+func (d *LDAPDirectory) Groups() ([]Entry, error) {
+	return d.search(d.config.GroupBaseDN, d.config.GroupFilter, []string{"cn", d.config.MemberAttribute})
+}
+
+func (d *LDAPDirectory) search(baseDN string, filter string, attributes []string) ([]Entry, error) {
+	conn, err := ldap.DialURL(d.config.URL)
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to %s: %w", d.config.URL, err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	if d.config.StartTLS {
+		if err := conn.StartTLS(nil); err != nil {
+			return nil, fmt.Errorf("error starting tls with %s: %w", d.config.URL, err)
+		}
+	}
+	if d.config.BindDN != "" {
+		if err := conn.Bind(d.config.BindDN, d.config.BindPassword); err != nil {
+			return nil, fmt.Errorf("error binding to %s as %s: %w", d.config.URL, d.config.BindDN, err)
+		}
+	}
+
+	request := ldap.NewSearchRequest(baseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false,
+		filter, attributes, nil)
+	result, err := conn.SearchWithPaging(request, d.config.PageSize)
+	if err != nil {
+		return nil, fmt.Errorf("error searching %s with filter %s: %w", baseDN, filter, err)
+	}
+
+	entries := make([]Entry, 0, len(result.Entries))
+	for _, found := range result.Entries {
+		entry := Entry{DN: found.DN, Attributes: map[string][]string{}}
+		for _, attribute := range found.Attributes {
+			if strings.EqualFold(attribute.Name, "objectGUID") && len(attribute.ByteValues) > 0 {
+				// objectGUID в Active Directory двоичный
+				entry.Attributes[attribute.Name] = []string{hex.EncodeToString(attribute.ByteValues[0])}
+				continue
+			}
+			entry.Attributes[attribute.Name] = attribute.Values
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
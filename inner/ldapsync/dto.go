@@ -0,0 +1,46 @@
+package ldapsync
+
+// Options режим запуска синхронизации
+type Options struct {
+	// DryRun только вычислить изменения, ничего не применяя
+	DryRun bool `json:"dry_run"`
+	// Incremental обработать только записи, изменённые после прошлого запуска. Удаление
+	// записей в этом режиме не обнаруживается – для него нужен полный запуск.
+	Incremental bool `json:"incremental"`
+}
+
+// Diff изменения, которые синхронизация внесла или внесла бы при DryRun
+type Diff struct {
+	Source        string           `json:"source"`
+	DryRun        bool             `json:"dry_run"`
+	Incremental   bool             `json:"incremental"`
+	Created       []EmployeeChange `json:"created"`
+	Linked        []EmployeeChange `json:"linked"`
+	Updated       []EmployeeChange `json:"updated"`
+	Removed       []EmployeeChange `json:"removed"`
+	Restored      []EmployeeChange `json:"restored"`
+	RolesGranted  []RoleChange     `json:"roles_granted"`
+	RolesRevoked  []RoleChange     `json:"roles_revoked"`
+	Warnings      []string         `json:"warnings"`
+	HighWaterMark string           `json:"high_water_mark,omitempty"`
+}
+
+// EmployeeChange изменение сотрудника. EmployeeId пуст для сотрудника, который ещё не создан.
+type EmployeeChange struct {
+	EmployeeId int64                  `json:"employee_id,omitempty"`
+	DN         string                 `json:"dn"`
+	UserName   string                 `json:"user_name,omitempty"`
+	Changes    map[string]FieldChange `json:"changes,omitempty"`
+}
+
+type FieldChange struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+type RoleChange struct {
+	EmployeeId int64  `json:"employee_id,omitempty"`
+	DN         string `json:"dn"`
+	RoleId     int64  `json:"role_id"`
+	Role       string `json:"role"`
+}
@@ -0,0 +1,50 @@
+package ldapsync
+
+import (
+	"errors"
+	"idm/inner/httpapi"
+	"net/http"
+	"strconv"
+)
+
+// Handler запуск синхронизации по HTTP: POST /sync?dry_run=true&incremental=true
+type Handler struct {
+	service *Service
+	mux     *http.ServeMux
+}
+
+func NewHandler(service *Service) *Handler {
+	h := &Handler{service: service, mux: http.NewServeMux()}
+
+	h.mux.HandleFunc("POST /sync", h.sync)
+
+	return h
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+func (h *Handler) sync(w http.ResponseWriter, r *http.Request) {
+	var options Options
+	for name, target := range map[string]*bool{"dry_run": &options.DryRun, "incremental": &options.Incremental} {
+		if value := r.URL.Query().Get(name); value != "" {
+			parsed, err := strconv.ParseBool(value)
+			if err != nil {
+				httpapi.WriteError(w, http.StatusBadRequest, "invalid "+name)
+				return
+			}
+			*target = parsed
+		}
+	}
+
+	diff, err := h.service.Sync(options)
+	switch {
+	case err == nil:
+		httpapi.WriteJSON(w, http.StatusOK, diff)
+	case errors.Is(err, ErrEmptyDirectory):
+		httpapi.WriteJSON(w, http.StatusConflict, map[string]any{"error": err.Error(), "diff": diff})
+	default:
+		httpapi.WriteJSON(w, http.StatusBadGateway, map[string]any{"error": err.Error(), "diff": diff})
+	}
+}
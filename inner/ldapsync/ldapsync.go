@@ -0,0 +1,543 @@
+package ldapsync
+
+import (
+	"errors"
+	"fmt"
+	"idm/inner/database"
+	"idm/inner/employee"
+	"idm/inner/role"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrEmptyDirectory = errors.New("directory returned no users, refusing to remove every synchronized employee")
+
+type Repo interface {
+	FindLinks(source string) ([]*Link, error)
+	SaveLink(link *Link) error
+	FindGrants(source string) ([]*Grant, error)
+	AddGrant(grant *Grant) error
+	RemoveGrant(grant *Grant) error
+	FindState(source string) (*State, error)
+	SaveState(state *State) error
+}
+
+// Employees изменения сотрудников выполняются через сервис, чтобы срабатывали его обработчики
+type Employees interface {
+	FindByIds(ids []int64) ([]employee.Response, error)
+	FindByUserName(userName string) (employee.Response, error)
+	Create(name string) (employee.Response, error)
+	Update(id int64, request employee.UpdateRequest) (employee.Response, error)
+	SetActive(id int64, active bool) (employee.Response, error)
+}
+
+// Roles роли выдаются через сервис, чтобы учитывались проверки разделения полномочий
+type Roles interface {
+	FindAll() ([]role.Response, error)
+	Assign(employeeId int64, roleId int64) error
+	Revoke(employeeId int64, roleId int64) error
+}
+
+type Service struct {
+	repo      Repo
+	directory Directory
+	employees Employees
+	roles     Roles
+	config    Config
+	now       func() time.Time
+	mu        sync.Mutex
+}
+
+func NewService(repository Repo, directory Directory, employees Employees, roles Roles, config Config) *Service {
+	return &Service{
+		repo:      repository,
+		directory: directory,
+		employees: employees,
+		roles:     roles,
+		config:    config.withDefaults(),
+		now:       time.Now,
+	}
+}
+
+// entry запись каталога и связанный с ней сотрудник
+type entry struct {
+	Entry
+	externalId string
+	link       *Link
+	current    *employee.Response
+	created    bool
+}
+
+// Sync привести сотрудников и их роли в соответствие с каталогом
+func (s *Service) Sync(options Options) (Diff, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	source := s.config.Source
+	diff := Diff{Source: source, DryRun: options.DryRun, Incremental: options.Incremental}
+
+	state, err := s.repo.FindState(source)
+	if err != nil {
+		return diff, fmt.Errorf("error finding sync state of %s: %w", source, err)
+	}
+	since := ""
+	if options.Incremental {
+		since = state.HighWaterMark
+	}
+
+	users, err := s.directory.Users(since)
+	if err != nil {
+		return diff, err
+	}
+	groups, err := s.directory.Groups()
+	if err != nil {
+		return diff, err
+	}
+
+	links, err := s.repo.FindLinks(source)
+	if err != nil {
+		return diff, fmt.Errorf("error finding links of %s: %w", source, err)
+	}
+	if !options.Incremental && len(users) == 0 && len(links) > 0 {
+		return diff, ErrEmptyDirectory
+	}
+
+	current, err := s.currentEmployees(links)
+	if err != nil {
+		return diff, err
+	}
+
+	entries, err := s.match(users, links, current, &diff)
+	if err != nil {
+		return diff, err
+	}
+
+	if err := s.create(entries, &diff, options.DryRun); err != nil {
+		return diff, err
+	}
+
+	byDN := map[string]int64{}
+	for _, link := range links {
+		byDN[strings.ToLower(link.DN)] = link.EmployeeId
+	}
+	for _, e := range entries {
+		if e.link != nil {
+			byDN[strings.ToLower(e.DN)] = e.link.EmployeeId
+		}
+	}
+
+	if err := s.update(entries, byDN, &diff, options.DryRun); err != nil {
+		return diff, err
+	}
+
+	removed := map[int64]bool{}
+	if !options.Incremental {
+		if err := s.remove(entries, links, removed, &diff, options.DryRun); err != nil {
+			return diff, err
+		}
+	}
+	for _, link := range links {
+		if link.RemovedAt != nil {
+			removed[link.EmployeeId] = true
+		}
+	}
+	for _, e := range entries {
+		if e.current != nil {
+			delete(removed, e.current.Id)
+		}
+	}
+
+	if err := s.syncRoles(entries, links, groups, removed, &diff, options.DryRun); err != nil {
+		return diff, err
+	}
+
+	for _, user := range users {
+		if mark := user.Get("modifyTimestamp"); mark > diff.HighWaterMark {
+			diff.HighWaterMark = mark
+		}
+	}
+	if options.DryRun {
+		return diff, nil
+	}
+
+	now := s.now()
+	state.LastRunAt = &now
+	if diff.HighWaterMark > state.HighWaterMark {
+		state.HighWaterMark = diff.HighWaterMark
+	}
+	if err := s.repo.SaveState(state); err != nil {
+		return diff, fmt.Errorf("error saving sync state of %s: %w", source, err)
+	}
+
+	return diff, nil
+}
+
+func (s *Service) currentEmployees(links []*Link) (map[int64]employee.Response, error) {
+	ids := make([]int64, 0, len(links))
+	for _, link := range links {
+		ids = append(ids, link.EmployeeId)
+	}
+
+	current := map[int64]employee.Response{}
+	if len(ids) == 0 {
+		return current, nil
+	}
+
+	found, err := s.employees.FindByIds(ids)
+	if err != nil {
+		return nil, fmt.Errorf("error finding synchronized employees: %w", err)
+	}
+	for _, e := range found {
+		current[e.Id] = e
+	}
+
+	return current, nil
+}
+
+// match найти для каждой записи связанного сотрудника. Сотрудник без связи, у которого
+// совпадает имя пользователя, связывается с записью вместо создания дубля.
+func (s *Service) match(users []Entry, links []*Link, current map[int64]employee.Response, diff *Diff) ([]*entry, error) {
+	byExternal := map[string]*Link{}
+	linked := map[int64]bool{}
+	for _, link := range links {
+		byExternal[link.ExternalId] = link
+		linked[link.EmployeeId] = true
+	}
+
+	entries := make([]*entry, 0, len(users))
+	for _, user := range users {
+		e := &entry{Entry: user, externalId: user.Get(s.config.IdAttribute)}
+		if e.externalId == "" {
+			e.externalId = strings.ToLower(user.DN)
+		}
+
+		if link, ok := byExternal[e.externalId]; ok {
+			if found, ok := current[link.EmployeeId]; ok {
+				e.link, e.current = link, &found
+			}
+		} else if userName := user.Get(s.config.Attributes.UserName); userName != "" {
+			found, err := s.employees.FindByUserName(userName)
+			switch {
+			case err == nil && linked[found.Id]:
+				diff.Warnings = append(diff.Warnings,
+					fmt.Sprintf("%s: employee %d with user name %q is linked to another entry", user.DN, found.Id, userName))
+				continue
+			case err == nil:
+				e.link = &Link{EmployeeId: found.Id, Source: s.config.Source, ExternalId: e.externalId, DN: user.DN}
+				e.current = &found
+				linked[found.Id] = true
+				diff.Linked = append(diff.Linked, EmployeeChange{EmployeeId: found.Id, DN: user.DN, UserName: userName})
+			case !errors.Is(err, database.ErrRecordNotFound):
+				return nil, err
+			}
+		}
+
+		entries = append(entries, e)
+	}
+
+	return entries, nil
+}
+
+func (s *Service) create(entries []*entry, diff *Diff, dryRun bool) error {
+	for _, e := range entries {
+		if e.current != nil {
+			continue
+		}
+
+		request := s.request(e.Entry, employee.Response{}, nil, nil)
+		change := EmployeeChange{DN: e.DN, UserName: request.UserName, Changes: changes(employee.Response{}, request, e.Get(s.config.Attributes.Manager))}
+		e.created = true
+
+		if !dryRun {
+			created, err := s.employees.Create(request.Name)
+			if err != nil {
+				return fmt.Errorf("error creating employee for %s: %w", e.DN, err)
+			}
+			e.current = &created
+			e.link = &Link{EmployeeId: created.Id, Source: s.config.Source, ExternalId: e.externalId, DN: e.DN}
+			change.EmployeeId = created.Id
+		}
+
+		diff.Created = append(diff.Created, change)
+	}
+
+	return nil
+}
+
+func (s *Service) update(entries []*entry, byDN map[string]int64, diff *Diff, dryRun bool) error {
+	for _, e := range entries {
+		if e.current == nil {
+			continue
+		}
+
+		request := s.request(e.Entry, *e.current, byDN, diff)
+		fieldChanges := changes(*e.current, request, "")
+		restored := e.link.RemovedAt != nil
+		if restored {
+			diff.Restored = append(diff.Restored, EmployeeChange{EmployeeId: e.current.Id, DN: e.DN, UserName: request.UserName})
+		}
+		if len(fieldChanges) > 0 && !e.created {
+			diff.Updated = append(diff.Updated, EmployeeChange{
+				EmployeeId: e.current.Id, DN: e.DN, UserName: request.UserName, Changes: fieldChanges,
+			})
+		}
+
+		if dryRun {
+			continue
+		}
+		if len(fieldChanges) > 0 {
+			if _, err := s.employees.Update(e.current.Id, request); err != nil {
+				return fmt.Errorf("error updating employee with id %d from %s: %w", e.current.Id, e.DN, err)
+			}
+		}
+		if restored {
+			if _, err := s.employees.SetActive(e.current.Id, true); err != nil {
+				return fmt.Errorf("error restoring employee with id %d: %w", e.current.Id, err)
+			}
+		}
+		e.link.DN, e.link.RemovedAt = e.DN, nil
+		if err := s.repo.SaveLink(e.link); err != nil {
+			return fmt.Errorf("error saving link of employee with id %d: %w", e.current.Id, err)
+		}
+	}
+
+	return nil
+}
+
+// remove отключить сотрудников, чьих записей больше нет в каталоге. Сотрудник не удаляется,
+// чтобы сохранить историю, и восстанавливается, если запись появится снова.
+func (s *Service) remove(entries []*entry, links []*Link, removed map[int64]bool, diff *Diff, dryRun bool) error {
+	seen := map[string]bool{}
+	for _, e := range entries {
+		seen[e.externalId] = true
+	}
+
+	for _, link := range links {
+		if seen[link.ExternalId] || link.RemovedAt != nil {
+			continue
+		}
+
+		diff.Removed = append(diff.Removed, EmployeeChange{EmployeeId: link.EmployeeId, DN: link.DN})
+		removed[link.EmployeeId] = true
+		if dryRun {
+			continue
+		}
+
+		if _, err := s.employees.SetActive(link.EmployeeId, false); err != nil {
+			return fmt.Errorf("error disabling employee with id %d: %w", link.EmployeeId, err)
+		}
+		now := s.now()
+		link.RemovedAt = &now
+		if err := s.repo.SaveLink(link); err != nil {
+			return fmt.Errorf("error saving link of employee with id %d: %w", link.EmployeeId, err)
+		}
+	}
+
+	return nil
+}
+
+// syncRoles выдать роли по членству в группах и отозвать выданные ранее, если членство прекратилось
+func (s *Service) syncRoles(entries []*entry, links []*Link, groups []Entry, removed map[int64]bool, diff *Diff, dryRun bool) error {
+	if len(s.config.GroupRoles) == 0 {
+		return nil
+	}
+
+	roles, err := s.roles.FindAll()
+	if err != nil {
+		return fmt.Errorf("error finding all roles: %w", err)
+	}
+	byName := map[string]role.Response{}
+	for _, r := range roles {
+		byName[strings.ToLower(r.Name)] = r
+	}
+
+	desired := map[string][]int64{}
+	for _, group := range groups {
+		roleName := s.roleFor(group)
+		if roleName == "" {
+			continue
+		}
+		r, ok := byName[strings.ToLower(roleName)]
+		if !ok {
+			diff.Warnings = append(diff.Warnings, fmt.Sprintf("%s: role %q does not exist", group.DN, roleName))
+			continue
+		}
+		for _, member := range group.Values(s.config.MemberAttribute) {
+			key := strings.ToLower(member)
+			if !slices.Contains(desired[key], r.Id) {
+				desired[key] = append(desired[key], r.Id)
+			}
+		}
+	}
+
+	grants, err := s.repo.FindGrants(s.config.Source)
+	if err != nil {
+		return fmt.Errorf("error finding role grants of %s: %w", s.config.Source, err)
+	}
+	granted := map[int64][]int64{}
+	for _, grant := range grants {
+		granted[grant.EmployeeId] = append(granted[grant.EmployeeId], grant.RoleId)
+	}
+
+	// сотрудники из этого запуска, а при инкрементальной синхронизации – и остальные связанные
+	type member struct {
+		employeeId int64
+		dn         string
+	}
+	var members []member
+	inRun := map[int64]bool{}
+	for _, e := range entries {
+		var id int64
+		if e.current != nil {
+			id = e.current.Id
+			inRun[id] = true
+		}
+		members = append(members, member{employeeId: id, dn: e.DN})
+	}
+	for _, link := range links {
+		if !inRun[link.EmployeeId] {
+			members = append(members, member{employeeId: link.EmployeeId, dn: link.DN})
+		}
+	}
+
+	names := map[int64]string{}
+	for _, r := range roles {
+		names[r.Id] = r.Name
+	}
+
+	for _, m := range members {
+		var want []int64
+		if !removed[m.employeeId] {
+			want = desired[strings.ToLower(m.dn)]
+		}
+		have := granted[m.employeeId]
+
+		for _, roleId := range want {
+			if slices.Contains(have, roleId) {
+				continue
+			}
+			change := RoleChange{EmployeeId: m.employeeId, DN: m.dn, RoleId: roleId, Role: names[roleId]}
+			if !dryRun {
+				if err := s.roles.Assign(m.employeeId, roleId); err != nil {
+					diff.Warnings = append(diff.Warnings, fmt.Sprintf("%s: %v", m.dn, err))
+					continue
+				}
+				if err := s.repo.AddGrant(&Grant{EmployeeId: m.employeeId, RoleId: roleId, Source: s.config.Source}); err != nil {
+					return fmt.Errorf("error saving role grant of employee with id %d: %w", m.employeeId, err)
+				}
+			}
+			diff.RolesGranted = append(diff.RolesGranted, change)
+		}
+
+		for _, roleId := range have {
+			if slices.Contains(want, roleId) {
+				continue
+			}
+			if !dryRun {
+				if err := s.roles.Revoke(m.employeeId, roleId); err != nil && !errors.Is(err, database.ErrRecordNotFound) {
+					return fmt.Errorf("error revoking role with id %d from employee with id %d: %w", roleId, m.employeeId, err)
+				}
+				if err := s.repo.RemoveGrant(&Grant{EmployeeId: m.employeeId, RoleId: roleId, Source: s.config.Source}); err != nil {
+					return fmt.Errorf("error removing role grant of employee with id %d: %w", m.employeeId, err)
+				}
+			}
+			diff.RolesRevoked = append(diff.RolesRevoked, RoleChange{EmployeeId: m.employeeId, DN: m.dn, RoleId: roleId, Role: names[roleId]})
+		}
+	}
+
+	return nil
+}
+
+func (s *Service) roleFor(group Entry) string {
+	for key, roleName := range s.config.GroupRoles {
+		if strings.EqualFold(key, group.DN) || strings.EqualFold(key, group.Get("cn")) {
+			return roleName
+		}
+	}
+
+	return ""
+}
+
+// request новые данные сотрудника: синхронизируемые поля берутся из записи, остальные остаются прежними.
+// Руководитель определяется по DN среди уже связанных записей.
+func (s *Service) request(e Entry, current employee.Response, byDN map[string]int64, diff *Diff) employee.UpdateRequest {
+	mapping := s.config.Attributes
+	request := employee.UpdateRequest{
+		Name:           current.Name,
+		UserName:       current.UserName,
+		Email:          current.Email,
+		ManagerId:      current.ManagerId,
+		Department:     current.Department,
+		Title:          current.Title,
+		Location:       current.Location,
+		EmploymentType: current.EmploymentType,
+	}
+
+	for _, field := range []struct {
+		attribute string
+		target    *string
+	}{
+		{mapping.Name, &request.Name},
+		{mapping.UserName, &request.UserName},
+		{mapping.Email, &request.Email},
+		{mapping.Department, &request.Department},
+		{mapping.Title, &request.Title},
+		{mapping.Location, &request.Location},
+		{mapping.EmploymentType, &request.EmploymentType},
+	} {
+		if field.attribute != "" {
+			*field.target = e.Get(field.attribute)
+		}
+	}
+
+	if mapping.Manager != "" && byDN != nil {
+		managerDN := e.Get(mapping.Manager)
+		if managerDN == "" {
+			request.ManagerId = nil
+		} else if id, ok := byDN[strings.ToLower(managerDN)]; ok {
+			request.ManagerId = &id
+		} else {
+			diff.Warnings = append(diff.Warnings, fmt.Sprintf("%s: manager %s is not synchronized", e.DN, managerDN))
+		}
+	}
+
+	return request
+}
+
+// changes различающиеся поля; manager – DN руководителя ещё не созданного сотрудника
+func changes(current employee.Response, request employee.UpdateRequest, manager string) map[string]FieldChange {
+	result := map[string]FieldChange{}
+	for _, field := range []struct {
+		name     string
+		from, to string
+	}{
+		{"name", current.Name, request.Name},
+		{"user_name", current.UserName, request.UserName},
+		{"email", current.Email, request.Email},
+		{"department", current.Department, request.Department},
+		{"title", current.Title, request.Title},
+		{"location", current.Location, request.Location},
+		{"employment_type", current.EmploymentType, request.EmploymentType},
+		{"manager", formatId(current.ManagerId), formatId(request.ManagerId)},
+	} {
+		if field.from != field.to {
+			result[field.name] = FieldChange{From: field.from, To: field.to}
+		}
+	}
+	if manager != "" {
+		result["manager"] = FieldChange{To: manager}
+	}
+
+	return result
+}
+
+func formatId(id *int64) string {
+	if id == nil {
+		return ""
+	}
+
+	return strconv.FormatInt(*id, 10)
+}
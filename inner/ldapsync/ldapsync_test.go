@@ -0,0 +1,569 @@
+package ldapsync
+
+import (
+	"errors"
+	ber "github.com/go-asn1-ber/asn1-ber"
+	assertpackage "github.com/stretchr/testify/assert"
+	"idm/inner/database"
+	"idm/inner/employee"
+	"idm/inner/role"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// StubLDAP минимальный LDAP-сервер: простая привязка и поиск по поддереву без постраничной выдачи
+type StubLDAP struct {
+	listener net.Listener
+	mu       sync.Mutex
+	entries  []Entry
+	password string
+}
+
+func NewStubLDAP(t *testing.T, password string, entries ...Entry) *StubLDAP {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stub := &StubLDAP{listener: listener, entries: entries, password: password}
+	t.Cleanup(func() { _ = listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go stub.serve(conn)
+		}
+	}()
+
+	return stub
+}
+
+func (s *StubLDAP) URL() string {
+	return "ldap://" + s.listener.Addr().String()
+}
+
+func (s *StubLDAP) SetEntries(entries ...Entry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries = entries
+}
+
+func (s *StubLDAP) serve(conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+
+	for {
+		packet, err := ber.ReadPacket(conn)
+		if err != nil || len(packet.Children) < 2 {
+			return
+		}
+		messageId := packet.Children[0].Value
+		request := packet.Children[1]
+
+		switch request.Tag {
+		case ber.Tag(0): // BindRequest
+			code := int64(0)
+			if request.Children[2].Data.String() != s.password {
+				code = 49 // invalidCredentials
+			}
+			_, _ = conn.Write(response(messageId, 1, code).Bytes())
+		case ber.Tag(2): // UnbindRequest
+			return
+		case ber.Tag(3): // SearchRequest
+			baseDN := strings.ToLower(request.Children[0].Data.String())
+			var attributes []string
+			for _, attribute := range request.Children[7].Children {
+				attributes = append(attributes, attribute.Data.String())
+			}
+
+			s.mu.Lock()
+			entries := slices.Clone(s.entries)
+			s.mu.Unlock()
+
+			for _, e := range entries {
+				if !strings.HasSuffix(strings.ToLower(e.DN), baseDN) || !matches(request.Children[6], e) {
+					continue
+				}
+				_, _ = conn.Write(searchEntry(messageId, e, attributes).Bytes())
+			}
+			_, _ = conn.Write(response(messageId, 5, 0).Bytes())
+		default:
+			return
+		}
+	}
+}
+
+func envelope(messageId any) *ber.Packet {
+	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+	packet.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, messageId, "Message ID"))
+	return packet
+}
+
+func response(messageId any, tag ber.Tag, code int64) *ber.Packet {
+	packet := envelope(messageId)
+	result := ber.Encode(ber.ClassApplication, ber.TypeConstructed, tag, nil, "Result")
+	result.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, code, "Result Code"))
+	result.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Matched DN"))
+	result.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Diagnostic Message"))
+	packet.AppendChild(result)
+	return packet
+}
+
+func searchEntry(messageId any, e Entry, attributes []string) *ber.Packet {
+	packet := envelope(messageId)
+	result := ber.Encode(ber.ClassApplication, ber.TypeConstructed, 4, nil, "Search Result Entry")
+	result.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, e.DN, "Object Name"))
+	list := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Attributes")
+	for _, name := range attributes {
+		values := e.Values(name)
+		if len(values) == 0 {
+			continue
+		}
+		attribute := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Attribute")
+		attribute.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, name, "Type"))
+		set := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "Values")
+		for _, value := range values {
+			set.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, value, "Value"))
+		}
+		attribute.AppendChild(set)
+		list.AppendChild(attribute)
+	}
+	result.AppendChild(list)
+	packet.AppendChild(result)
+	return packet
+}
+
+// matches вычислить фильтр поиска: and, or, not, равенство, наличие и greaterOrEqual
+func matches(filter *ber.Packet, e Entry) bool {
+	switch filter.Tag {
+	case 0:
+		for _, child := range filter.Children {
+			if !matches(child, e) {
+				return false
+			}
+		}
+		return true
+	case 1:
+		return slices.ContainsFunc(filter.Children, func(child *ber.Packet) bool { return matches(child, e) })
+	case 2:
+		return !matches(filter.Children[0], e)
+	case 3:
+		return slices.ContainsFunc(e.Values(filter.Children[0].Data.String()), func(value string) bool {
+			return strings.EqualFold(value, filter.Children[1].Data.String())
+		})
+	case 5:
+		return slices.ContainsFunc(e.Values(filter.Children[0].Data.String()), func(value string) bool {
+			return value >= filter.Children[1].Data.String()
+		})
+	case 7:
+		return len(e.Values(filter.Data.String())) > 0
+	}
+	return false
+}
+
+// StubRepo хранит связи, выданные роли и состояние в памяти
+type StubRepo struct {
+	links  []*Link
+	grants []*Grant
+	state  *State
+}
+
+func (s *StubRepo) FindLinks(source string) ([]*Link, error) {
+	var result []*Link
+	for _, link := range s.links {
+		copied := *link
+		result = append(result, &copied)
+	}
+	return result, nil
+}
+
+func (s *StubRepo) SaveLink(link *Link) error {
+	copied := *link
+	for i, found := range s.links {
+		if found.EmployeeId == link.EmployeeId {
+			s.links[i] = &copied
+			return nil
+		}
+	}
+	s.links = append(s.links, &copied)
+	return nil
+}
+
+func (s *StubRepo) FindGrants(source string) ([]*Grant, error) {
+	return slices.Clone(s.grants), nil
+}
+
+func (s *StubRepo) AddGrant(grant *Grant) error {
+	s.grants = append(s.grants, grant)
+	return nil
+}
+
+func (s *StubRepo) RemoveGrant(grant *Grant) error {
+	s.grants = slices.DeleteFunc(s.grants, func(g *Grant) bool { return *g == *grant })
+	return nil
+}
+
+func (s *StubRepo) FindState(source string) (*State, error) {
+	if s.state == nil {
+		return &State{Source: source}, nil
+	}
+	copied := *s.state
+	return &copied, nil
+}
+
+func (s *StubRepo) SaveState(state *State) error {
+	copied := *state
+	s.state = &copied
+	return nil
+}
+
+// StubEmployees хранит сотрудников в памяти
+type StubEmployees struct {
+	employees []employee.Response
+}
+
+func (s *StubEmployees) FindById(id int64) (employee.Response, error) {
+	for _, found := range s.employees {
+		if found.Id == id {
+			return found, nil
+		}
+	}
+	return employee.Response{}, database.ErrRecordNotFound
+}
+
+func (s *StubEmployees) FindByIds(ids []int64) ([]employee.Response, error) {
+	var result []employee.Response
+	for _, id := range ids {
+		if found, err := s.FindById(id); err == nil {
+			result = append(result, found)
+		}
+	}
+	return result, nil
+}
+
+func (s *StubEmployees) FindByUserName(userName string) (employee.Response, error) {
+	for _, found := range s.employees {
+		if strings.EqualFold(found.UserName, userName) {
+			return found, nil
+		}
+	}
+	return employee.Response{}, database.ErrRecordNotFound
+}
+
+func (s *StubEmployees) Create(name string) (employee.Response, error) {
+	created := employee.Response{Id: int64(len(s.employees) + 1), Name: name, Status: employee.StatusActive}
+	s.employees = append(s.employees, created)
+	return created, nil
+}
+
+func (s *StubEmployees) Update(id int64, request employee.UpdateRequest) (employee.Response, error) {
+	for i, found := range s.employees {
+		if found.Id == id {
+			found.Name, found.UserName, found.Email = request.Name, request.UserName, request.Email
+			found.ManagerId, found.Department, found.Title = request.ManagerId, request.Department, request.Title
+			found.Location, found.EmploymentType = request.Location, request.EmploymentType
+			s.employees[i] = found
+			return found, nil
+		}
+	}
+	return employee.Response{}, database.ErrRecordNotFound
+}
+
+func (s *StubEmployees) SetActive(id int64, active bool) (employee.Response, error) {
+	for i, found := range s.employees {
+		if found.Id == id {
+			found.Status = employee.StatusDisabled
+			if active {
+				found.Status = employee.StatusActive
+			}
+			s.employees[i] = found
+			return found, nil
+		}
+	}
+	return employee.Response{}, database.ErrRecordNotFound
+}
+
+// StubRoles хранит роли и назначения в памяти
+type StubRoles struct {
+	roles       []role.Response
+	assignments map[int64][]int64
+}
+
+func (s *StubRoles) FindAll() ([]role.Response, error) {
+	return s.roles, nil
+}
+
+func (s *StubRoles) Assign(employeeId int64, roleId int64) error {
+	s.assignments[employeeId] = append(s.assignments[employeeId], roleId)
+	return nil
+}
+
+func (s *StubRoles) Revoke(employeeId int64, roleId int64) error {
+	s.assignments[employeeId] = slices.DeleteFunc(s.assignments[employeeId], func(id int64) bool { return id == roleId })
+	return nil
+}
+
+func person(uid string, name string, title string, modified string, manager string) Entry {
+	attributes := map[string][]string{
+		"objectClass":     {"inetOrgPerson"},
+		"entryUUID":       {"uuid-" + uid},
+		"uid":             {uid},
+		"cn":              {name},
+		"mail":            {uid + "@example.com"},
+		"title":           {title},
+		"modifyTimestamp": {modified},
+	}
+	if manager != "" {
+		attributes["manager"] = []string{manager}
+	}
+	return Entry{DN: "uid=" + uid + ",ou=people,dc=example,dc=com", Attributes: attributes}
+}
+
+func group(cn string, members ...string) Entry {
+	return Entry{DN: "cn=" + cn + ",ou=groups,dc=example,dc=com", Attributes: map[string][]string{
+		"objectClass": {"groupOfNames"},
+		"cn":          {cn},
+		"member":      members,
+	}}
+}
+
+type fixture struct {
+	ldap      *StubLDAP
+	repo      *StubRepo
+	employees *StubEmployees
+	roles     *StubRoles
+	service   *Service
+}
+
+func newFixture(t *testing.T, entries ...Entry) *fixture {
+	f := &fixture{
+		ldap:      NewStubLDAP(t, "secret", entries...),
+		repo:      &StubRepo{},
+		employees: &StubEmployees{},
+		roles: &StubRoles{
+			roles:       []role.Response{{Id: 1, Name: "Developer"}, {Id: 2, Name: "Admin"}},
+			assignments: map[int64][]int64{},
+		},
+	}
+	config := Config{
+		URL:          f.ldap.URL(),
+		BindDN:       "cn=sync,dc=example,dc=com",
+		BindPassword: "secret",
+		BaseDN:       "dc=example,dc=com",
+		Attributes:   DefaultMapping,
+		GroupRoles:   map[string]string{"developers": "Developer", "cn=admins,ou=groups,dc=example,dc=com": "Admin"},
+	}
+	f.service = NewService(f.repo, NewDirectory(config), f.employees, f.roles, config)
+	return f
+}
+
+func TestLDAPDirectory(t *testing.T) {
+	assert := assertpackage.New(t)
+
+	t.Run("should search users incrementally and groups", func(t *testing.T) {
+		f := newFixture(t,
+			person("alice", "Alice", "CTO", "20250101000000Z", ""),
+			person("bob", "Bob", "Developer", "20250201000000Z", ""),
+			group("developers", "uid=bob,ou=people,dc=example,dc=com"),
+		)
+		directory := NewDirectory(Config{URL: f.ldap.URL(), BindDN: "cn=sync", BindPassword: "secret", BaseDN: "dc=example,dc=com", Attributes: DefaultMapping})
+
+		users, err := directory.Users("")
+		assert.Nil(err)
+		assert.Len(users, 2)
+		assert.Equal("uuid-alice", users[0].Get("entryuuid"))
+
+		users, err = directory.Users("20250115000000Z")
+		assert.Nil(err)
+		assert.Len(users, 1)
+		assert.Equal("bob", users[0].Get("uid"))
+
+		groups, err := directory.Groups()
+		assert.Nil(err)
+		assert.Len(groups, 1)
+		assert.Equal([]string{"uid=bob,ou=people,dc=example,dc=com"}, groups[0].Values("member"))
+	})
+
+	t.Run("should fail on invalid credentials", func(t *testing.T) {
+		f := newFixture(t)
+		directory := NewDirectory(Config{URL: f.ldap.URL(), BindDN: "cn=sync", BindPassword: "wrong", BaseDN: "dc=example,dc=com"})
+
+		_, err := directory.Users("")
+
+		assert.NotNil(err)
+	})
+}
+
+func TestLDAPSyncService(t *testing.T) {
+	assert := assertpackage.New(t)
+	aliceDN := "uid=alice,ou=people,dc=example,dc=com"
+	bobDN := "uid=bob,ou=people,dc=example,dc=com"
+
+	t.Run("should show a dry-run diff without changing anything", func(t *testing.T) {
+		f := newFixture(t,
+			person("alice", "Alice", "CTO", "20250101000000Z", ""),
+			group("developers", aliceDN),
+		)
+
+		diff, err := f.service.Sync(Options{DryRun: true})
+
+		assert.Nil(err)
+		assert.Len(diff.Created, 1)
+		assert.Equal("alice", diff.Created[0].UserName)
+		assert.Equal(FieldChange{To: "CTO"}, diff.Created[0].Changes["title"])
+		assert.Len(diff.RolesGranted, 1)
+		assert.Equal("Developer", diff.RolesGranted[0].Role)
+		assert.Empty(f.employees.employees)
+		assert.Empty(f.repo.links)
+		assert.Nil(f.repo.state)
+	})
+
+	t.Run("should create employees, resolve managers and grant roles", func(t *testing.T) {
+		f := newFixture(t,
+			person("alice", "Alice", "CTO", "20250101000000Z", ""),
+			person("bob", "Bob", "Developer", "20250201000000Z", aliceDN),
+			group("developers", bobDN),
+			group("admins", aliceDN),
+		)
+
+		diff, err := f.service.Sync(Options{})
+
+		assert.Nil(err)
+		assert.Len(diff.Created, 2)
+		assert.Empty(diff.Updated)
+		assert.Len(f.employees.employees, 2)
+		bob, _ := f.employees.FindByUserName("bob")
+		alice, _ := f.employees.FindByUserName("alice")
+		assert.Equal("Developer", bob.Title)
+		assert.Equal(&alice.Id, bob.ManagerId)
+		assert.Equal([]int64{1}, f.roles.assignments[bob.Id])
+		assert.Equal([]int64{2}, f.roles.assignments[alice.Id])
+		assert.Len(f.repo.links, 2)
+		assert.Equal("20250201000000Z", f.repo.state.HighWaterMark)
+	})
+
+	t.Run("should link an existing employee by user name", func(t *testing.T) {
+		f := newFixture(t, person("alice", "Alice", "CTO", "20250101000000Z", ""))
+		existing, _ := f.employees.Create("Alice")
+		_, _ = f.employees.Update(existing.Id, employee.UpdateRequest{Name: "Alice", UserName: "alice"})
+
+		diff, err := f.service.Sync(Options{})
+
+		assert.Nil(err)
+		assert.Empty(diff.Created)
+		assert.Len(diff.Linked, 1)
+		assert.Len(diff.Updated, 1)
+		assert.Len(f.employees.employees, 1)
+		assert.Equal(existing.Id, f.repo.links[0].EmployeeId)
+	})
+
+	t.Run("should sync only modified entries incrementally", func(t *testing.T) {
+		f := newFixture(t,
+			person("alice", "Alice", "CTO", "20250101000000Z", ""),
+			person("bob", "Bob", "Developer", "20250201000000Z", aliceDN),
+			group("developers", bobDN),
+		)
+		_, err := f.service.Sync(Options{})
+		assert.Nil(err)
+
+		f.ldap.SetEntries(
+			person("alice", "Alice", "CTO", "20250101000000Z", ""),
+			person("bob", "Bob", "Senior Developer", "20250301000000Z", aliceDN),
+			group("developers", bobDN),
+		)
+		diff, err := f.service.Sync(Options{Incremental: true})
+
+		assert.Nil(err)
+		assert.Len(diff.Updated, 1)
+		assert.Equal(FieldChange{From: "Developer", To: "Senior Developer"}, diff.Updated[0].Changes["title"])
+		assert.Empty(diff.RolesGranted)
+		assert.Empty(diff.RolesRevoked)
+		assert.Equal("20250301000000Z", f.repo.state.HighWaterMark)
+
+		diff, err = f.service.Sync(Options{Incremental: true})
+
+		assert.Nil(err)
+		assert.Empty(diff.Updated)
+	})
+
+	t.Run("should soft-remove and restore employees", func(t *testing.T) {
+		f := newFixture(t,
+			person("alice", "Alice", "CTO", "20250101000000Z", ""),
+			person("bob", "Bob", "Developer", "20250201000000Z", aliceDN),
+			group("developers", bobDN),
+		)
+		_, err := f.service.Sync(Options{})
+		assert.Nil(err)
+		bob, _ := f.employees.FindByUserName("bob")
+
+		f.ldap.SetEntries(person("alice", "Alice", "CTO", "20250101000000Z", ""), group("developers", bobDN))
+		diff, err := f.service.Sync(Options{})
+
+		assert.Nil(err)
+		assert.Len(diff.Removed, 1)
+		assert.Equal(bob.Id, diff.Removed[0].EmployeeId)
+		assert.Len(diff.RolesRevoked, 1)
+		bob, _ = f.employees.FindById(bob.Id)
+		assert.Equal(employee.StatusDisabled, bob.Status)
+		assert.Empty(f.roles.assignments[bob.Id])
+
+		f.ldap.SetEntries(
+			person("alice", "Alice", "CTO", "20250101000000Z", ""),
+			person("bob", "Bob", "Developer", "20250401000000Z", aliceDN),
+			group("developers", bobDN),
+		)
+		diff, err = f.service.Sync(Options{})
+
+		assert.Nil(err)
+		assert.Len(diff.Restored, 1)
+		assert.Len(diff.RolesGranted, 1)
+		bob, _ = f.employees.FindById(bob.Id)
+		assert.Equal(employee.StatusActive, bob.Status)
+		assert.Equal([]int64{1}, f.roles.assignments[bob.Id])
+	})
+
+	t.Run("should keep roles granted outside of the sync", func(t *testing.T) {
+		f := newFixture(t, person("alice", "Alice", "CTO", "20250101000000Z", ""))
+		_, err := f.service.Sync(Options{})
+		assert.Nil(err)
+		alice, _ := f.employees.FindByUserName("alice")
+		_ = f.roles.Assign(alice.Id, 2)
+
+		diff, err := f.service.Sync(Options{})
+
+		assert.Nil(err)
+		assert.Empty(diff.RolesRevoked)
+		assert.Equal([]int64{2}, f.roles.assignments[alice.Id])
+	})
+
+	t.Run("should refuse a full sync against an empty directory", func(t *testing.T) {
+		f := newFixture(t, person("alice", "Alice", "CTO", "20250101000000Z", ""))
+		_, err := f.service.Sync(Options{})
+		assert.Nil(err)
+
+		f.ldap.SetEntries()
+		_, err = f.service.Sync(Options{})
+
+		assert.True(errors.Is(err, ErrEmptyDirectory))
+		alice, _ := f.employees.FindByUserName("alice")
+		assert.Equal(employee.StatusActive, alice.Status)
+	})
+}
+
+func TestLoadConfig(t *testing.T) {
+	assert := assertpackage.New(t)
+
+	t.Run("should fill defaults and keep the default mapping", func(t *testing.T) {
+		config, err := LoadConfig(strings.NewReader(`{"url": "ldap://localhost", "base_dn": "dc=example,dc=com", "attributes": {"title": "jobTitle"}}`))
+
+		assert.Nil(err)
+		assert.Equal("ldap", config.Source)
+		assert.Equal("dc=example,dc=com", config.GroupBaseDN)
+		assert.Equal("jobTitle", config.Attributes.Title)
+		assert.Equal("uid", config.Attributes.UserName)
+	})
+}
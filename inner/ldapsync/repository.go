@@ -0,0 +1,129 @@
+package ldapsync
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/jmoiron/sqlx"
+	"time"
+)
+
+// Link связь сотрудника с записью каталога
+type Link struct {
+	EmployeeId int64      `db:"employee_id"`
+	Source     string     `db:"source"`
+	ExternalId string     `db:"external_id"`
+	DN         string     `db:"dn"`
+	RemovedAt  *time.Time `db:"removed_at"`
+	SyncedAt   time.Time  `db:"synced_at"`
+}
+
+// Grant роль, выданная синхронизацией за членство в группе. Отзываются только такие роли.
+type Grant struct {
+	EmployeeId int64  `db:"employee_id"`
+	RoleId     int64  `db:"role_id"`
+	Source     string `db:"source"`
+}
+
+// State отметка, с которой начинается следующая инкрементальная синхронизация
+type State struct {
+	Source        string     `db:"source"`
+	HighWaterMark string     `db:"high_water_mark"`
+	LastRunAt     *time.Time `db:"last_run_at"`
+}
+
+type Repository struct {
+	db *sqlx.DB
+}
+
+func NewRepository(db *sqlx.DB) *Repository {
+	return &Repository{db: db}
+}
+
+func (r *Repository) FindLinks(source string) ([]*Link, error) {
+	var links []*Link
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := r.db.SelectContext(ctx, &links, "SELECT * FROM ldap_links WHERE source = $1", source)
+
+	return links, err
+}
+
+func (r *Repository) SaveLink(link *Link) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO ldap_links (employee_id, source, external_id, dn, removed_at, synced_at)
+		VALUES ($1, $2, $3, $4, $5, CURRENT_TIMESTAMP)
+		ON CONFLICT (employee_id) DO UPDATE
+		SET source = EXCLUDED.source, external_id = EXCLUDED.external_id, dn = EXCLUDED.dn,
+			removed_at = EXCLUDED.removed_at, synced_at = EXCLUDED.synced_at`,
+		link.EmployeeId, link.Source, link.ExternalId, link.DN, link.RemovedAt)
+
+	return err
+}
+
+func (r *Repository) FindGrants(source string) ([]*Grant, error) {
+	var grants []*Grant
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := r.db.SelectContext(ctx, &grants, "SELECT * FROM ldap_role_grants WHERE source = $1", source)
+
+	return grants, err
+}
+
+func (r *Repository) AddGrant(grant *Grant) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO ldap_role_grants (employee_id, role_id, source) VALUES ($1, $2, $3)
+		ON CONFLICT DO NOTHING`,
+		grant.EmployeeId, grant.RoleId, grant.Source)
+
+	return err
+}
+
+func (r *Repository) RemoveGrant(grant *Grant) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := r.db.ExecContext(ctx,
+		"DELETE FROM ldap_role_grants WHERE employee_id = $1 AND role_id = $2 AND source = $3",
+		grant.EmployeeId, grant.RoleId, grant.Source)
+
+	return err
+}
+
+// FindState состояние источника; для ещё не синхронизированного – пустое
+func (r *Repository) FindState(source string) (*State, error) {
+	state := State{Source: source}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := r.db.GetContext(ctx, &state, "SELECT * FROM ldap_sync_state WHERE source = $1", source)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	return &state, nil
+}
+
+func (r *Repository) SaveState(state *State) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO ldap_sync_state (source, high_water_mark, last_run_at) VALUES ($1, $2, $3)
+		ON CONFLICT (source) DO UPDATE
+		SET high_water_mark = EXCLUDED.high_water_mark, last_run_at = EXCLUDED.last_run_at`,
+		state.Source, state.HighWaterMark, state.LastRunAt)
+
+	return err
+}
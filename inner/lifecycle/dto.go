@@ -0,0 +1,59 @@
+package lifecycle
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type ChangeResponse struct {
+	Id          int64           `json:"id"`
+	Kind        Kind            `json:"kind"`
+	EmployeeId  *int64          `json:"employee_id,omitempty"`
+	Payload     json.RawMessage `json:"payload"`
+	Status      ChangeStatus    `json:"status"`
+	Error       string          `json:"error,omitempty"`
+	EffectiveAt time.Time       `json:"effective_at"`
+	AppliedAt   *time.Time      `json:"applied_at,omitempty"`
+	Granted     []int64         `json:"granted,omitempty"`
+	Revoked     []int64         `json:"revoked,omitempty"`
+	Blocked     []int64         `json:"blocked,omitempty"`
+	CreatedAt   time.Time       `json:"created_at"`
+}
+
+func (c *Change) ToResponse(event *Event) *ChangeResponse {
+	response := &ChangeResponse{
+		Id:          c.Id,
+		Kind:        c.Kind,
+		EmployeeId:  c.EmployeeId,
+		Payload:     json.RawMessage(c.Payload),
+		Status:      c.Status,
+		Error:       c.Error,
+		EffectiveAt: c.EffectiveAt,
+		AppliedAt:   c.AppliedAt,
+		CreatedAt:   c.CreatedAt,
+	}
+	if event != nil {
+		response.Granted = event.Granted
+		response.Revoked = event.Revoked
+		response.Blocked = event.Blocked
+	}
+
+	return response
+}
+
+// HireChange приём в API; без effective_at применяется сразу
+type HireChange struct {
+	HireRequest
+	EffectiveAt time.Time `json:"effective_at"`
+}
+
+// TransferChange перевод в API; сотрудник задаётся в пути
+type TransferChange struct {
+	TransferRequest
+	EffectiveAt time.Time `json:"effective_at"`
+}
+
+// TerminateChange увольнение в API; сотрудник задаётся в пути
+type TerminateChange struct {
+	EffectiveAt time.Time `json:"effective_at"`
+}
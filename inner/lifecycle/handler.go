@@ -0,0 +1,108 @@
+package lifecycle
+
+import (
+	"idm/inner/httpapi"
+	"net/http"
+)
+
+// statuses коды ответа для ошибок сервиса
+var statuses = httpapi.Statuses{
+	{Err: ErrChangeNotPending, Code: http.StatusConflict},
+	{Err: ErrEmployeeDisabled, Code: http.StatusConflict},
+}
+
+// Handler приём, перевод и увольнение сотрудников, в том числе с датой в будущем
+type Handler struct {
+	service *Service
+	mux     *http.ServeMux
+}
+
+func NewHandler(service *Service) *Handler {
+	h := &Handler{service: service, mux: http.NewServeMux()}
+
+	h.mux.HandleFunc("POST /hire", h.hire)
+	h.mux.HandleFunc("GET /employees/{id}", h.history)
+	h.mux.HandleFunc("POST /employees/{id}/transfer", h.transfer)
+	h.mux.HandleFunc("POST /employees/{id}/terminate", h.terminate)
+	h.mux.HandleFunc("GET /{id}", h.get)
+	h.mux.HandleFunc("POST /{id}/cancel", h.cancel)
+
+	return h
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+func (h *Handler) hire(w http.ResponseWriter, r *http.Request) {
+	var request HireChange
+	if !httpapi.Decode(w, r, &request) {
+		return
+	}
+	request.HireRequest.EffectiveAt = request.EffectiveAt
+
+	change, err := h.service.Hire(request.HireRequest)
+	httpapi.Write(w, http.StatusCreated, change, err, statuses)
+}
+
+func (h *Handler) history(w http.ResponseWriter, r *http.Request) {
+	id, ok := httpapi.PathId(w, r, "id")
+	if !ok {
+		return
+	}
+
+	changes, err := h.service.FindByEmployeeId(id)
+	if changes == nil {
+		changes = []ChangeResponse{}
+	}
+	httpapi.Write(w, http.StatusOK, changes, err, statuses)
+}
+
+func (h *Handler) transfer(w http.ResponseWriter, r *http.Request) {
+	id, ok := httpapi.PathId(w, r, "id")
+	if !ok {
+		return
+	}
+	var request TransferChange
+	if !httpapi.Decode(w, r, &request) {
+		return
+	}
+	request.TransferRequest.EmployeeId = id
+	request.TransferRequest.EffectiveAt = request.EffectiveAt
+
+	change, err := h.service.Transfer(request.TransferRequest)
+	httpapi.Write(w, http.StatusCreated, change, err, statuses)
+}
+
+func (h *Handler) terminate(w http.ResponseWriter, r *http.Request) {
+	id, ok := httpapi.PathId(w, r, "id")
+	if !ok {
+		return
+	}
+	var request TerminateChange
+	if !httpapi.Decode(w, r, &request) {
+		return
+	}
+
+	change, err := h.service.Terminate(TerminateRequest{EmployeeId: id, EffectiveAt: request.EffectiveAt})
+	httpapi.Write(w, http.StatusCreated, change, err, statuses)
+}
+
+func (h *Handler) get(w http.ResponseWriter, r *http.Request) {
+	id, ok := httpapi.PathId(w, r, "id")
+	if !ok {
+		return
+	}
+
+	change, err := h.service.FindById(id)
+	httpapi.Write(w, http.StatusOK, change, err, statuses)
+}
+
+func (h *Handler) cancel(w http.ResponseWriter, r *http.Request) {
+	id, ok := httpapi.PathId(w, r, "id")
+	if !ok {
+		return
+	}
+
+	httpapi.Write(w, http.StatusNoContent, nil, h.service.Cancel(id), statuses)
+}
@@ -0,0 +1,492 @@
+package lifecycle
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"idm/inner/employee"
+	"idm/inner/role"
+	"log"
+	"slices"
+	"time"
+)
+
+// Kind тип изменения жизненного цикла
+type Kind string
+
+const (
+	KindHire      Kind = "hire"
+	KindTransfer  Kind = "transfer"
+	KindTerminate Kind = "terminate"
+	KindPurge     Kind = "purge"
+)
+
+// ChangeStatus состояние изменения
+type ChangeStatus string
+
+const (
+	ChangePending   ChangeStatus = "pending"
+	ChangeApplied   ChangeStatus = "applied"
+	ChangeFailed    ChangeStatus = "failed"
+	ChangeCancelled ChangeStatus = "cancelled"
+)
+
+// EventType тип события, которое публикуется после применения изменения
+type EventType string
+
+const (
+	EventEmployeeHired       EventType = "EmployeeHired"
+	EventEmployeeTransferred EventType = "EmployeeTransferred"
+	EventEmployeeTerminated  EventType = "EmployeeTerminated"
+	EventEmployeePurged      EventType = "EmployeePurged"
+)
+
+// DefaultRetention сколько хранятся данные уволенного сотрудника до удаления
+const DefaultRetention = 365 * 24 * time.Hour
+
+var (
+	ErrChangeNotPending  = errors.New("lifecycle change is not pending")
+	ErrEmployeeDisabled  = errors.New("employee is already terminated")
+	ErrEmployeeRemoved   = errors.New("employee was removed before the change took effect")
+	ErrUnknownChangeKind = errors.New("unknown lifecycle change kind")
+)
+
+// Event событие жизненного цикла сотрудника
+type Event struct {
+	Type       EventType
+	ChangeId   int64
+	EmployeeId int64
+	Granted    []int64
+	Revoked    []int64
+	Blocked    []int64
+	OccurredAt time.Time
+}
+
+// Listener получает события после успешного применения изменения
+type Listener interface {
+	Handle(event Event)
+}
+
+type Repo interface {
+	FindById(id int64) (*Change, error)
+	FindByEmployeeId(employeeId int64) ([]*Change, error)
+	FindDue(now time.Time) ([]*Change, error)
+	FindEmployee(id int64) (*employee.Employee, error)
+	FindRoleIds(employeeId int64) ([]int64, error)
+	FindBirthrightRoleIds(employeeId int64) ([]int64, error)
+	Schedule(change *Change) error
+	Apply(change *Change, effect *Effect) error
+	Grant(employeeId int64, roleIds []int64) error
+	MarkFailed(change *Change) error
+	Cancel(id int64) error
+}
+
+// Birthright роли, положенные сотруднику по умолчанию
+type Birthright interface {
+	RolesFor(attributes employee.Attributes) ([]int64, error)
+}
+
+// HireRequest приём сотрудника на работу
+type HireRequest struct {
+	Name           string    `json:"name"`
+	Department     string    `json:"department"`
+	Title          string    `json:"title"`
+	Location       string    `json:"location"`
+	EmploymentType string    `json:"employment_type"`
+	ManagerId      *int64    `json:"manager_id,omitempty"`
+	EffectiveAt    time.Time `json:"-"`
+}
+
+// TransferRequest перевод сотрудника в другой отдел или на другую должность
+type TransferRequest struct {
+	EmployeeId     int64     `json:"employee_id"`
+	Department     string    `json:"department"`
+	Title          string    `json:"title"`
+	Location       string    `json:"location"`
+	EmploymentType string    `json:"employment_type"`
+	ManagerId      *int64    `json:"manager_id,omitempty"`
+	EffectiveAt    time.Time `json:"-"`
+}
+
+// TerminateRequest увольнение сотрудника
+type TerminateRequest struct {
+	EmployeeId  int64     `json:"employee_id"`
+	EffectiveAt time.Time `json:"-"`
+}
+
+// Service оркестрирует приём, перевод и увольнение сотрудников.
+// Изменение с датой в будущем сохраняется и применяется через ApplyDue.
+type Service struct {
+	repo       Repo
+	birthright Birthright
+	guards     []role.AssignmentGuard
+	hooks      []role.AssignmentHook
+	saveHooks  []employee.SaveHook
+	listeners  []Listener
+	retention  time.Duration
+	now        func() time.Time
+}
+
+func NewService(repository Repo, birthright Birthright) *Service {
+	return &Service{repo: repository, birthright: birthright, retention: DefaultRetention, now: time.Now}
+}
+
+// Subscribe подписать слушателя на события жизненного цикла
+func (s *Service) Subscribe(listener Listener) {
+	s.listeners = append(s.listeners, listener)
+}
+
+// UseGuard проверять роли по умолчанию перед выдачей: запрещённые не выдаются и попадают в blocked
+func (s *Service) UseGuard(guard role.AssignmentGuard) {
+	s.guards = append(s.guards, guard)
+}
+
+// UseHook вызывать hook после выдачи и отзыва ролей при изменении
+func (s *Service) UseHook(hook role.AssignmentHook) {
+	s.hooks = append(s.hooks, hook)
+}
+
+// UseSaveHook вызывать hook для сотрудника, которого изменение приняло или перевело:
+// изменение сохраняет его в обход employee.Service
+func (s *Service) UseSaveHook(hook employee.SaveHook) {
+	s.saveHooks = append(s.saveHooks, hook)
+}
+
+// SetRetention задать срок хранения данных уволенного сотрудника
+func (s *Service) SetRetention(retention time.Duration) {
+	s.retention = retention
+}
+
+func (s *Service) FindById(id int64) (ChangeResponse, error) {
+	change, err := s.repo.FindById(id)
+	if err != nil {
+		return ChangeResponse{}, fmt.Errorf("error finding lifecycle change with id %d: %w", id, err)
+	}
+
+	return *change.ToResponse(nil), nil
+}
+
+func (s *Service) FindByEmployeeId(employeeId int64) ([]ChangeResponse, error) {
+	changes, err := s.repo.FindByEmployeeId(employeeId)
+	if err != nil {
+		return nil, fmt.Errorf("error finding lifecycle changes of employee with id %d: %w", employeeId, err)
+	}
+
+	var responses []ChangeResponse
+	for _, change := range changes {
+		responses = append(responses, *change.ToResponse(nil))
+	}
+
+	return responses, nil
+}
+
+// Hire принять сотрудника и выдать ему роли по умолчанию
+func (s *Service) Hire(request HireRequest) (ChangeResponse, error) {
+	return s.submit(KindHire, nil, request, request.EffectiveAt)
+}
+
+// Transfer перевести сотрудника: выданные правилами роли, которые не положены на новом месте,
+// отзываются, а недостающие роли нового места выдаются
+func (s *Service) Transfer(request TransferRequest) (ChangeResponse, error) {
+	return s.submit(KindTransfer, &request.EmployeeId, request, request.EffectiveAt)
+}
+
+// Terminate уволить сотрудника: отозвать все роли, отключить учётную запись
+// и запланировать удаление данных по истечении срока хранения
+func (s *Service) Terminate(request TerminateRequest) (ChangeResponse, error) {
+	return s.submit(KindTerminate, &request.EmployeeId, request, request.EffectiveAt)
+}
+
+// Cancel отменить запланированное изменение
+func (s *Service) Cancel(changeId int64) error {
+	err := s.repo.Cancel(changeId)
+	if err != nil {
+		return fmt.Errorf("error cancelling lifecycle change with id %d: %w", changeId, err)
+	}
+
+	return nil
+}
+
+// ApplyDue применить все изменения, дата которых наступила. Неудачные изменения
+// помечаются как failed и не мешают остальным, а изменения удалённых к этому времени
+// сотрудников – как cancelled. Возвращает количество применённых.
+func (s *Service) ApplyDue() (int, error) {
+	changes, err := s.repo.FindDue(s.now())
+	if err != nil {
+		return 0, fmt.Errorf("error finding due lifecycle changes: %w", err)
+	}
+
+	applied := 0
+	for _, change := range changes {
+		_, err = s.apply(change)
+		if err == nil {
+			applied++
+			continue
+		}
+
+		change.Status = ChangeFailed
+		if errors.Is(err, ErrEmployeeRemoved) {
+			change.Status = ChangeCancelled
+		}
+		change.Error = err.Error()
+		if err = s.repo.MarkFailed(change); err != nil {
+			return applied, fmt.Errorf("error marking lifecycle change with id %d as failed: %w", change.Id, err)
+		}
+	}
+
+	return applied, nil
+}
+
+// Run периодически применять наступившие изменения, пока не отменён ctx
+func (s *Service) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := s.ApplyDue(); err != nil {
+				log.Printf("error applying due lifecycle changes: %v", err)
+			}
+		}
+	}
+}
+
+func (s *Service) submit(kind Kind, employeeId *int64, request any, effectiveAt time.Time) (ChangeResponse, error) {
+	payload, err := json.Marshal(request)
+	if err != nil {
+		return ChangeResponse{}, fmt.Errorf("error encoding %s request: %w", kind, err)
+	}
+
+	now := s.now()
+	if effectiveAt.IsZero() {
+		effectiveAt = now
+	}
+
+	change := &Change{
+		Kind:        kind,
+		EmployeeId:  employeeId,
+		Payload:     payload,
+		Status:      ChangePending,
+		EffectiveAt: effectiveAt,
+	}
+
+	if effectiveAt.After(now) {
+		err = s.repo.Schedule(change)
+		if err != nil {
+			return ChangeResponse{}, fmt.Errorf("error scheduling %s: %w", kind, err)
+		}
+
+		return *change.ToResponse(nil), nil
+	}
+
+	event, err := s.apply(change)
+	if err != nil {
+		return ChangeResponse{}, err
+	}
+
+	return *change.ToResponse(event), nil
+}
+
+func (s *Service) apply(change *Change) (*Event, error) {
+	effect, event, err := s.plan(change)
+	if err != nil {
+		return nil, fmt.Errorf("error planning %s: %w", change.Kind, err)
+	}
+
+	err = s.repo.Apply(change, effect)
+	if err != nil {
+		return nil, fmt.Errorf("error applying %s: %w", change.Kind, err)
+	}
+
+	event.ChangeId = change.Id
+	event.EmployeeId = *change.EmployeeId
+	event.OccurredAt = s.now()
+
+	// роль могла быть запрещена только из-за роли, которую это же изменение отозвало
+	if len(effect.Revoke) > 0 && len(event.Blocked) > 0 {
+		grant, blocked := s.check(event.EmployeeId, event.Blocked)
+		if len(grant) > 0 {
+			if err = s.repo.Grant(event.EmployeeId, grant); err != nil {
+				return nil, fmt.Errorf("error granting roles after %s: %w", change.Kind, err)
+			}
+			event.Granted = append(event.Granted, grant...)
+			event.Blocked = blocked
+		}
+	}
+
+	for _, listener := range s.listeners {
+		listener.Handle(*event)
+	}
+
+	for _, roleId := range slices.Concat(event.Granted, event.Revoked) {
+		for _, hook := range s.hooks {
+			if err = hook.AfterAssignmentChange(event.EmployeeId, roleId); err != nil {
+				return event, fmt.Errorf("error processing role %d of employee with id %d: %w", roleId, event.EmployeeId, err)
+			}
+		}
+	}
+
+	for _, saved := range []*employee.Employee{effect.Create, effect.Update} {
+		if saved == nil {
+			continue
+		}
+		for _, hook := range s.saveHooks {
+			if err = hook.AfterSave(*saved.ToResponse()); err != nil {
+				return event, fmt.Errorf("error processing saved employee with id %d: %w", saved.Id, err)
+			}
+		}
+	}
+
+	return event, nil
+}
+
+func (s *Service) plan(change *Change) (*Effect, *Event, error) {
+	// сотрудник запланированного изменения мог быть удалён: employee_id обнуляется вместе с ним
+	if change.Kind != KindHire && change.EmployeeId == nil {
+		return nil, nil, ErrEmployeeRemoved
+	}
+
+	switch change.Kind {
+	case KindHire:
+		var request HireRequest
+		if err := json.Unmarshal(change.Payload, &request); err != nil {
+			return nil, nil, err
+		}
+
+		created := &employee.Employee{
+			Name:           request.Name,
+			Department:     request.Department,
+			Title:          request.Title,
+			Location:       request.Location,
+			EmploymentType: request.EmploymentType,
+			ManagerId:      request.ManagerId,
+		}
+		roleIds, err := s.birthright.RolesFor(created.Attributes())
+		if err != nil {
+			return nil, nil, err
+		}
+
+		grant, blocked := s.check(created.Id, roleIds)
+		effect := &Effect{Create: created, Grant: grant}
+
+		return effect, &Event{Type: EventEmployeeHired, Granted: grant, Blocked: blocked}, nil
+	case KindTransfer:
+		var request TransferRequest
+		if err := json.Unmarshal(change.Payload, &request); err != nil {
+			return nil, nil, err
+		}
+
+		return s.planTransfer(request)
+	case KindTerminate:
+		empl, err := s.activeEmployee(*change.EmployeeId)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		held, err := s.repo.FindRoleIds(empl.Id)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		purge := &Change{
+			Kind:        KindPurge,
+			EmployeeId:  &empl.Id,
+			Payload:     []byte(fmt.Sprintf(`{"employee_id":%d}`, empl.Id)),
+			Status:      ChangePending,
+			EffectiveAt: change.EffectiveAt.Add(s.retention),
+		}
+		effect := &Effect{RevokeAll: true, Disable: true, Schedule: []*Change{purge}}
+
+		return effect, &Event{Type: EventEmployeeTerminated, Revoked: held}, nil
+	case KindPurge:
+		return &Effect{RevokeAll: true, Delete: true}, &Event{Type: EventEmployeePurged}, nil
+	default:
+		return nil, nil, ErrUnknownChangeKind
+	}
+}
+
+func (s *Service) planTransfer(request TransferRequest) (*Effect, *Event, error) {
+	empl, err := s.activeEmployee(request.EmployeeId)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	held, err := s.repo.FindRoleIds(empl.Id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	granted, err := s.repo.FindBirthrightRoleIds(empl.Id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	empl.Department = request.Department
+	empl.Title = request.Title
+	empl.Location = request.Location
+	empl.EmploymentType = request.EmploymentType
+	empl.ManagerId = request.ManagerId
+
+	after, err := s.birthright.RolesFor(empl.Attributes())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var missing, revoke []int64
+	for _, roleId := range after {
+		if !slices.Contains(held, roleId) {
+			missing = append(missing, roleId)
+		}
+	}
+	for _, roleId := range granted {
+		if !slices.Contains(after, roleId) {
+			revoke = append(revoke, roleId)
+		}
+	}
+
+	grant, blocked := s.check(empl.Id, missing)
+	effect := &Effect{Update: empl, Grant: grant, Revoke: revoke}
+
+	return effect, &Event{Type: EventEmployeeTransferred, Granted: grant, Revoked: revoke, Blocked: blocked}, nil
+}
+
+// check разделить роли на разрешённые и запрещённые проверками. Роли проверяются пакетом:
+// уже разрешённые учитываются при проверке следующих
+func (s *Service) check(employeeId int64, roleIds []int64) ([]int64, []int64) {
+	var allowed, blocked []int64
+	for _, roleId := range roleIds {
+		if s.allowed(employeeId, roleId, allowed) {
+			allowed = append(allowed, roleId)
+		} else {
+			blocked = append(blocked, roleId)
+		}
+	}
+
+	return allowed, blocked
+}
+
+func (s *Service) allowed(employeeId int64, roleId int64, pending []int64) bool {
+	for _, guard := range s.guards {
+		if guard.CheckAssignment(employeeId, roleId, pending) != nil {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (s *Service) activeEmployee(id int64) (*employee.Employee, error) {
+	empl, err := s.repo.FindEmployee(id)
+	if err != nil {
+		return nil, fmt.Errorf("error finding employee with id %d: %w", id, err)
+	}
+	if empl.Status == employee.StatusDisabled {
+		return nil, fmt.Errorf("error changing employee with id %d: %w", id, ErrEmployeeDisabled)
+	}
+
+	return empl, nil
+}
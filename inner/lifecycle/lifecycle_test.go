@@ -0,0 +1,405 @@
+package lifecycle
+
+import (
+	"errors"
+	assertpackage "github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"idm/inner/employee"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+type MockRepo struct {
+	mock.Mock
+}
+
+func (m *MockRepo) FindById(id int64) (*Change, error) {
+	args := m.Called(id)
+	return args.Get(0).(*Change), args.Error(1)
+}
+
+func (m *MockRepo) FindByEmployeeId(employeeId int64) ([]*Change, error) {
+	args := m.Called(employeeId)
+	return args.Get(0).([]*Change), args.Error(1)
+}
+
+func (m *MockRepo) FindDue(now time.Time) ([]*Change, error) {
+	args := m.Called(now)
+	return args.Get(0).([]*Change), args.Error(1)
+}
+
+func (m *MockRepo) FindEmployee(id int64) (*employee.Employee, error) {
+	args := m.Called(id)
+	return args.Get(0).(*employee.Employee), args.Error(1)
+}
+
+func (m *MockRepo) FindRoleIds(employeeId int64) ([]int64, error) {
+	args := m.Called(employeeId)
+	return args.Get(0).([]int64), args.Error(1)
+}
+
+func (m *MockRepo) FindBirthrightRoleIds(employeeId int64) ([]int64, error) {
+	args := m.Called(employeeId)
+	return args.Get(0).([]int64), args.Error(1)
+}
+
+func (m *MockRepo) Schedule(change *Change) error {
+	args := m.Called(change)
+	return args.Error(0)
+}
+
+func (m *MockRepo) Apply(change *Change, effect *Effect) error {
+	args := m.Called(change, effect)
+	return args.Error(0)
+}
+
+func (m *MockRepo) Grant(employeeId int64, roleIds []int64) error {
+	args := m.Called(employeeId, roleIds)
+	return args.Error(0)
+}
+
+func (m *MockRepo) MarkFailed(change *Change) error {
+	args := m.Called(change)
+	return args.Error(0)
+}
+
+func (m *MockRepo) Cancel(id int64) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+// StubBirthright выдаёт роли по отделу
+type StubBirthright map[string][]int64
+
+func (s StubBirthright) RolesFor(attributes employee.Attributes) ([]int64, error) {
+	return s[attributes.Department], nil
+}
+
+type GuardFunc func(employeeId int64, roleId int64, pending []int64) error
+
+func (f GuardFunc) CheckAssignment(employeeId int64, roleId int64, pending []int64) error {
+	return f(employeeId, roleId, pending)
+}
+
+type RecordingHook struct {
+	roleIds []int64
+}
+
+func (h *RecordingHook) AfterAssignmentChange(employeeId int64, roleId int64) error {
+	h.roleIds = append(h.roleIds, roleId)
+	return nil
+}
+
+type RecordingSaveHook struct {
+	saved []employee.Response
+}
+
+func (h *RecordingSaveHook) AfterSave(saved employee.Response) error {
+	h.saved = append(h.saved, saved)
+	return nil
+}
+
+type RecordingListener struct {
+	events []Event
+}
+
+func (l *RecordingListener) Handle(event Event) {
+	l.events = append(l.events, event)
+}
+
+func TestLifecycleService(t *testing.T) {
+	assert := assertpackage.New(t)
+	now := time.Date(2025, 6, 2, 9, 0, 0, 0, time.UTC)
+	birthright := StubBirthright{"engineering": {1, 2}, "finance": {2, 3}}
+
+	newService := func() (*Service, *MockRepo, *RecordingListener) {
+		repo := &MockRepo{}
+		listener := &RecordingListener{}
+		service := NewService(repo, birthright)
+		service.now = func() time.Time { return now }
+		service.Subscribe(listener)
+
+		return service, repo, listener
+	}
+
+	t.Run("Hire should create the employee with birthright roles", func(t *testing.T) {
+		service, repo, listener := newService()
+
+		repo.On("Apply", mock.AnythingOfType("*lifecycle.Change"), mock.AnythingOfType("*lifecycle.Effect")).
+			Return(nil).
+			Run(func(args mock.Arguments) {
+				change := args.Get(0).(*Change)
+				id := int64(42)
+				change.Id = 7
+				change.EmployeeId = &id
+				change.Status = ChangeApplied
+			})
+
+		got, err := service.Hire(HireRequest{Name: "John Doe", Department: "engineering", Title: "developer"})
+
+		assert.NoError(err)
+		assert.Equal(ChangeApplied, got.Status)
+		assert.Equal([]int64{1, 2}, got.Granted)
+		effect := repo.Calls[0].Arguments.Get(1).(*Effect)
+		assert.Equal("John Doe", effect.Create.Name)
+		assert.Equal([]int64{1, 2}, effect.Grant)
+		assert.Len(listener.events, 1)
+		assert.Equal(EventEmployeeHired, listener.events[0].Type)
+		assert.Equal(int64(42), listener.events[0].EmployeeId)
+	})
+
+	t.Run("Hire should check birthright roles as one batch", func(t *testing.T) {
+		service, repo, listener := newService()
+		hook := &RecordingHook{}
+		// роли 1 и 2 нельзя держать одновременно
+		service.UseGuard(GuardFunc(func(employeeId int64, roleId int64, pending []int64) error {
+			if roleId == 2 && slices.Contains(pending, 1) {
+				return errors.New("conflicting roles")
+			}
+			return nil
+		}))
+		service.UseHook(hook)
+
+		repo.On("Apply", mock.AnythingOfType("*lifecycle.Change"), mock.AnythingOfType("*lifecycle.Effect")).
+			Return(nil).
+			Run(func(args mock.Arguments) {
+				id := int64(42)
+				args.Get(0).(*Change).EmployeeId = &id
+			})
+
+		got, err := service.Hire(HireRequest{Name: "John Doe", Department: "engineering"})
+
+		assert.NoError(err)
+		assert.Equal([]int64{1}, got.Granted)
+		assert.Equal([]int64{2}, got.Blocked)
+		effect := repo.Calls[0].Arguments.Get(1).(*Effect)
+		assert.Equal([]int64{1}, effect.Grant)
+		assert.Equal([]int64{2}, listener.events[0].Blocked)
+		assert.Equal([]int64{1}, hook.roleIds)
+	})
+
+	t.Run("Hire in the future should only be scheduled", func(t *testing.T) {
+		service, repo, listener := newService()
+
+		repo.On("Schedule", mock.AnythingOfType("*lifecycle.Change")).Return(nil)
+
+		got, err := service.Hire(HireRequest{Name: "John Doe", Department: "engineering",
+			EffectiveAt: now.Add(7 * 24 * time.Hour)})
+
+		assert.NoError(err)
+		assert.Equal(ChangePending, got.Status)
+		assert.Empty(listener.events)
+		repo.AssertNotCalled(t, "Apply", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Transfer should diff birthright roles", func(t *testing.T) {
+		service, repo, listener := newService()
+		empl := &employee.Employee{Id: 5, Department: "engineering", Status: employee.StatusActive}
+
+		repo.On("FindEmployee", int64(5)).Return(empl, nil)
+		repo.On("FindRoleIds", int64(5)).Return([]int64{1, 2, 9}, nil)
+		repo.On("FindBirthrightRoleIds", int64(5)).Return([]int64{1, 2}, nil)
+		repo.On("Apply", mock.AnythingOfType("*lifecycle.Change"), mock.AnythingOfType("*lifecycle.Effect")).
+			Return(nil)
+
+		got, err := service.Transfer(TransferRequest{EmployeeId: 5, Department: "finance", Title: "analyst"})
+
+		assert.NoError(err)
+		assert.Equal([]int64{3}, got.Granted)
+		assert.Equal([]int64{1}, got.Revoked)
+		effect := repo.Calls[3].Arguments.Get(1).(*Effect)
+		assert.Equal("finance", effect.Update.Department)
+		assert.Equal(EventEmployeeTransferred, listener.events[0].Type)
+	})
+
+	t.Run("Transfer should grant roles blocked only by revoked roles after applying", func(t *testing.T) {
+		service, repo, _ := newService()
+		hook := &RecordingHook{}
+		empl := &employee.Employee{Id: 5, Department: "engineering", Status: employee.StatusActive}
+		// роль 3 нельзя держать вместе с ролью 1, которую перевод отзывает
+		held := []int64{1, 2, 9}
+		service.UseGuard(GuardFunc(func(employeeId int64, roleId int64, pending []int64) error {
+			if roleId == 3 && slices.Contains(held, 1) {
+				return errors.New("conflicting roles")
+			}
+			return nil
+		}))
+		service.UseHook(hook)
+
+		repo.On("FindEmployee", int64(5)).Return(empl, nil)
+		repo.On("FindRoleIds", int64(5)).Return(held, nil)
+		repo.On("FindBirthrightRoleIds", int64(5)).Return([]int64{1, 2}, nil)
+		repo.On("Apply", mock.AnythingOfType("*lifecycle.Change"), mock.AnythingOfType("*lifecycle.Effect")).
+			Return(nil).
+			Run(func(args mock.Arguments) { held = []int64{2, 9} })
+		repo.On("Grant", int64(5), []int64{3}).Return(nil)
+
+		got, err := service.Transfer(TransferRequest{EmployeeId: 5, Department: "finance"})
+
+		assert.NoError(err)
+		effect := repo.Calls[3].Arguments.Get(1).(*Effect)
+		assert.Empty(effect.Grant)
+		assert.Equal([]int64{3}, got.Granted)
+		assert.Empty(got.Blocked)
+		assert.Equal([]int64{3, 1}, hook.roleIds)
+		repo.AssertCalled(t, "Grant", int64(5), []int64{3})
+	})
+
+	t.Run("Transfer should pass the moved employee to save hooks", func(t *testing.T) {
+		service, repo, _ := newService()
+		hook := &RecordingSaveHook{}
+		service.UseSaveHook(hook)
+		empl := &employee.Employee{Id: 5, Department: "engineering", Status: employee.StatusActive}
+
+		repo.On("FindEmployee", int64(5)).Return(empl, nil)
+		repo.On("FindRoleIds", int64(5)).Return([]int64{1, 2}, nil)
+		repo.On("FindBirthrightRoleIds", int64(5)).Return([]int64{1, 2}, nil)
+		repo.On("Apply", mock.AnythingOfType("*lifecycle.Change"), mock.AnythingOfType("*lifecycle.Effect")).
+			Return(nil)
+
+		_, err := service.Transfer(TransferRequest{EmployeeId: 5, Department: "finance"})
+
+		assert.NoError(err)
+		assert.Len(hook.saved, 1)
+		assert.Equal(int64(5), hook.saved[0].Id)
+		assert.Equal("finance", hook.saved[0].Department)
+	})
+
+	t.Run("Terminate should revoke access, disable and schedule purge", func(t *testing.T) {
+		service, repo, listener := newService()
+		empl := &employee.Employee{Id: 5, Department: "engineering", Status: employee.StatusActive}
+
+		repo.On("FindEmployee", int64(5)).Return(empl, nil)
+		repo.On("FindRoleIds", int64(5)).Return([]int64{1, 2}, nil)
+		repo.On("Apply", mock.AnythingOfType("*lifecycle.Change"), mock.AnythingOfType("*lifecycle.Effect")).
+			Return(nil)
+
+		got, err := service.Terminate(TerminateRequest{EmployeeId: 5})
+
+		assert.NoError(err)
+		assert.Equal([]int64{1, 2}, got.Revoked)
+		effect := repo.Calls[2].Arguments.Get(1).(*Effect)
+		assert.True(effect.RevokeAll)
+		assert.True(effect.Disable)
+		assert.Len(effect.Schedule, 1)
+		assert.Equal(KindPurge, effect.Schedule[0].Kind)
+		assert.Equal(now.Add(DefaultRetention), effect.Schedule[0].EffectiveAt)
+		assert.Equal(EventEmployeeTerminated, listener.events[0].Type)
+	})
+
+	t.Run("Terminate should refuse already terminated employees", func(t *testing.T) {
+		service, repo, _ := newService()
+
+		repo.On("FindEmployee", int64(5)).Return(&employee.Employee{Id: 5, Status: employee.StatusDisabled}, nil)
+
+		_, err := service.Terminate(TerminateRequest{EmployeeId: 5})
+
+		assert.ErrorIs(err, ErrEmployeeDisabled)
+	})
+
+	t.Run("Apply failure should not emit events", func(t *testing.T) {
+		service, repo, listener := newService()
+
+		repo.On("Apply", mock.AnythingOfType("*lifecycle.Change"), mock.AnythingOfType("*lifecycle.Effect")).
+			Return(errors.New("database error"))
+
+		_, err := service.Hire(HireRequest{Name: "John Doe"})
+
+		assert.Error(err)
+		assert.Empty(listener.events)
+	})
+
+	t.Run("ApplyDue should apply due changes and mark failures", func(t *testing.T) {
+		service, repo, _ := newService()
+		id := int64(5)
+		hire := &Change{Id: 1, Kind: KindHire, Payload: []byte(`{"name":"Jane"}`), Status: ChangePending}
+		terminate := &Change{Id: 2, Kind: KindTerminate, EmployeeId: &id, Payload: []byte(`{}`),
+			Status: ChangePending}
+
+		repo.On("FindDue", now).Return([]*Change{hire, terminate}, nil)
+		repo.On("Apply", hire, mock.AnythingOfType("*lifecycle.Effect")).Return(nil).
+			Run(func(args mock.Arguments) {
+				created := int64(43)
+				args.Get(0).(*Change).EmployeeId = &created
+			})
+		repo.On("FindEmployee", int64(5)).Return(&employee.Employee{}, errors.New("database error"))
+		repo.On("MarkFailed", terminate).Return(nil)
+
+		applied, err := service.ApplyDue()
+
+		assert.NoError(err)
+		assert.Equal(1, applied)
+		assert.Equal(ChangeFailed, terminate.Status)
+		assert.NotEmpty(terminate.Error)
+	})
+
+	t.Run("ApplyDue should cancel changes of employees removed before they took effect", func(t *testing.T) {
+		service, repo, listener := newService()
+		// employee_id обнулён удалением сотрудника
+		terminate := &Change{Id: 2, Kind: KindTerminate, Payload: []byte(`{"employee_id":5}`), Status: ChangePending}
+		transfer := &Change{Id: 3, Kind: KindTransfer, Payload: []byte(`{"employee_id":5}`), Status: ChangePending}
+		purge := &Change{Id: 4, Kind: KindPurge, Payload: []byte(`{"employee_id":5}`), Status: ChangePending}
+
+		repo.On("FindDue", now).Return([]*Change{terminate, transfer, purge}, nil)
+		repo.On("MarkFailed", mock.AnythingOfType("*lifecycle.Change")).Return(nil)
+
+		applied, err := service.ApplyDue()
+
+		assert.NoError(err)
+		assert.Equal(0, applied)
+		for _, change := range []*Change{terminate, transfer, purge} {
+			assert.Equal(ChangeCancelled, change.Status)
+			assert.Contains(change.Error, ErrEmployeeRemoved.Error())
+		}
+		assert.Empty(listener.events)
+		repo.AssertNotCalled(t, "Apply", mock.Anything, mock.Anything)
+	})
+}
+
+func TestLifecycleHandler(t *testing.T) {
+	assert := assertpackage.New(t)
+	now := time.Date(2025, 6, 2, 9, 0, 0, 0, time.UTC)
+
+	newHandler := func() (*Handler, *MockRepo) {
+		repo := &MockRepo{}
+		service := NewService(repo, StubBirthright{})
+		service.now = func() time.Time { return now }
+
+		return NewHandler(service), repo
+	}
+	do := func(handler http.Handler, method string, path string, body string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return recorder
+	}
+
+	t.Run("should schedule a termination for the employee in the path", func(t *testing.T) {
+		handler, repo := newHandler()
+		repo.On("Schedule", mock.AnythingOfType("*lifecycle.Change")).Return(nil)
+
+		recorder := do(handler, http.MethodPost, "/employees/5/terminate", `{"effective_at": "2025-07-01T00:00:00Z"}`)
+
+		assert.Equal(http.StatusCreated, recorder.Code)
+		change := repo.Calls[0].Arguments.Get(0).(*Change)
+		assert.Equal(KindTerminate, change.Kind)
+		assert.Equal(int64(5), *change.EmployeeId)
+		assert.Equal(time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC), change.EffectiveAt)
+	})
+
+	t.Run("should refuse to cancel a change that is no longer pending", func(t *testing.T) {
+		handler, repo := newHandler()
+		repo.On("Cancel", int64(7)).Return(ErrChangeNotPending)
+
+		assert.Equal(http.StatusConflict, do(handler, http.MethodPost, "/7/cancel", "").Code)
+	})
+
+	t.Run("should reject an invalid employee id", func(t *testing.T) {
+		handler, _ := newHandler()
+
+		assert.Equal(http.StatusBadRequest, do(handler, http.MethodPost, "/employees/x/transfer", `{}`).Code)
+	})
+}
@@ -0,0 +1,325 @@
+package lifecycle
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/jmoiron/sqlx"
+	"github.com/jmoiron/sqlx/types"
+	"github.com/lib/pq"
+	"idm/inner/birthright"
+	"idm/inner/database"
+	"idm/inner/employee"
+	"time"
+)
+
+// Change изменение жизненного цикла сотрудника: применённое или запланированное на дату
+type Change struct {
+	Id          int64          `db:"id"`
+	Kind        Kind           `db:"kind"`
+	EmployeeId  *int64         `db:"employee_id"`
+	Payload     types.JSONText `db:"payload"`
+	Status      ChangeStatus   `db:"status"`
+	Error       string         `db:"error"`
+	EffectiveAt time.Time      `db:"effective_at"`
+	AppliedAt   *time.Time     `db:"applied_at"`
+	CreatedAt   time.Time      `db:"created_at"`
+}
+
+// Effect что нужно сделать с данными сотрудника при применении изменения
+type Effect struct {
+	Create    *employee.Employee
+	Update    *employee.Employee
+	Grant     []int64
+	Revoke    []int64
+	RevokeAll bool
+	Disable   bool
+	Delete    bool
+	Schedule  []*Change
+}
+
+type Repository struct {
+	db *sqlx.DB
+}
+
+func NewRepository(db *sqlx.DB) *Repository {
+	return &Repository{db: db}
+}
+
+func (r *Repository) FindById(id int64) (*Change, error) {
+	var change Change
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := r.db.GetContext(ctx, &change, "SELECT * FROM lifecycle_changes WHERE id = $1", id)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, database.ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &change, err
+}
+
+func (r *Repository) FindByEmployeeId(employeeId int64) ([]*Change, error) {
+	var changes []*Change
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := r.db.SelectContext(ctx, &changes,
+		"SELECT * FROM lifecycle_changes WHERE employee_id = $1 ORDER BY effective_at, id", employeeId)
+
+	return changes, err
+}
+
+// FindDue запланированные изменения, дата вступления в силу которых наступила
+func (r *Repository) FindDue(now time.Time) ([]*Change, error) {
+	var changes []*Change
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := r.db.SelectContext(ctx, &changes,
+		"SELECT * FROM lifecycle_changes WHERE status = $1 AND effective_at <= $2 ORDER BY effective_at, id",
+		ChangePending, now)
+
+	return changes, err
+}
+
+func (r *Repository) FindEmployee(id int64) (*employee.Employee, error) {
+	var empl employee.Employee
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := r.db.GetContext(ctx, &empl, "SELECT * FROM employees WHERE id = $1", id)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, database.ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &empl, err
+}
+
+func (r *Repository) FindRoleIds(employeeId int64) ([]int64, error) {
+	var ids []int64
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := r.db.SelectContext(ctx, &ids,
+		"SELECT role_id FROM employee_roles WHERE employee_id = $1 ORDER BY role_id", employeeId)
+
+	return ids, err
+}
+
+// FindBirthrightRoleIds роли сотрудника, выданные правилами по умолчанию
+func (r *Repository) FindBirthrightRoleIds(employeeId int64) ([]int64, error) {
+	var ids []int64
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := r.db.SelectContext(ctx, &ids,
+		"SELECT role_id FROM employee_roles WHERE employee_id = $1 AND source = $2 ORDER BY role_id",
+		employeeId, birthright.SourceBirthright)
+
+	return ids, err
+}
+
+// Schedule сохранить изменение, которое вступит в силу позже
+func (r *Repository) Schedule(change *Change) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return insertChange(ctx, r.db, change)
+}
+
+// Apply атомарно применить изменение: все эффекты и отметка о применении
+// выполняются в одной транзакции
+func (r *Repository) Apply(change *Change, effect *Effect) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if effect.Create != nil {
+		created := effect.Create
+		err = tx.QueryRowContext(ctx,
+			`INSERT INTO employees (name, department, title, location, employment_type, manager_id, status)
+			VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id, created_at, updated_at`,
+			created.Name, created.Department, created.Title, created.Location, created.EmploymentType,
+			created.ManagerId, employee.StatusActive,
+		).Scan(&created.Id, &created.CreatedAt, &created.UpdatedAt)
+		if err != nil {
+			return err
+		}
+		created.Status = employee.StatusActive
+		change.EmployeeId = &created.Id
+	}
+
+	if effect.Update != nil {
+		// перевод в подразделение с другим названием выводит сотрудника из прежнего подразделения
+		updated := effect.Update
+		err = tx.QueryRowContext(ctx,
+			`UPDATE employees
+			SET department = $1, title = $2, location = $3, employment_type = $4, manager_id = $5,
+				department_id = CASE WHEN department = $1 THEN department_id END, updated_at = CURRENT_TIMESTAMP
+			WHERE id = $6 RETURNING department_id, updated_at`,
+			updated.Department, updated.Title, updated.Location, updated.EmploymentType, updated.ManagerId,
+			updated.Id,
+		).Scan(&updated.DepartmentId, &updated.UpdatedAt)
+		if err != nil {
+			return err
+		}
+	}
+
+	employeeId := change.EmployeeId
+	if effect.RevokeAll {
+		if _, err = tx.ExecContext(ctx, "DELETE FROM employee_roles WHERE employee_id = $1", employeeId); err != nil {
+			return err
+		}
+	}
+
+	if len(effect.Revoke) > 0 {
+		_, err = tx.ExecContext(ctx,
+			"DELETE FROM employee_roles WHERE employee_id = $1 AND role_id = ANY($2) AND source = $3",
+			employeeId, pq.Array(effect.Revoke), birthright.SourceBirthright)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(effect.Grant) > 0 {
+		_, err = tx.ExecContext(ctx,
+			`INSERT INTO employee_roles (employee_id, role_id, source) SELECT $1, unnest($2::BIGINT[]), $3
+			ON CONFLICT DO NOTHING`,
+			employeeId, pq.Array(effect.Grant), birthright.SourceBirthright)
+		if err != nil {
+			return database.MapError(err)
+		}
+	}
+
+	if effect.Disable {
+		_, err = tx.ExecContext(ctx,
+			`UPDATE employees SET status = $1, terminated_at = $2, updated_at = CURRENT_TIMESTAMP WHERE id = $3`,
+			employee.StatusDisabled, change.EffectiveAt, employeeId)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, scheduled := range effect.Schedule {
+		if err = insertChange(ctx, tx, scheduled); err != nil {
+			return err
+		}
+	}
+
+	if err = markApplied(ctx, tx, change); err != nil {
+		return err
+	}
+
+	if effect.Delete {
+		if _, err = tx.ExecContext(ctx, "DELETE FROM employees WHERE id = $1", employeeId); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Grant выдать роли по умолчанию уже применённому изменению
+func (r *Repository) Grant(employeeId int64, roleIds []int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO employee_roles (employee_id, role_id, source) SELECT $1, unnest($2::BIGINT[]), $3
+		ON CONFLICT DO NOTHING`,
+		employeeId, pq.Array(roleIds), birthright.SourceBirthright)
+
+	return database.MapError(err)
+}
+
+// MarkFailed отметить, что запланированное изменение не удалось применить или применять уже некому
+func (r *Repository) MarkFailed(change *Change) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := r.db.ExecContext(ctx,
+		"UPDATE lifecycle_changes SET status = $1, error = $2 WHERE id = $3 AND status = $4",
+		change.Status, change.Error, change.Id, ChangePending)
+
+	return err
+}
+
+// Cancel отменить запланированное изменение, если оно ещё не применено
+func (r *Repository) Cancel(id int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := r.db.ExecContext(ctx,
+		"UPDATE lifecycle_changes SET status = $1 WHERE id = $2 AND status = $3",
+		ChangeCancelled, id, ChangePending)
+	if err != nil {
+		return err
+	}
+
+	count, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrChangeNotPending
+	}
+
+	return nil
+}
+
+func insertChange(ctx context.Context, db sqlx.QueryerContext, change *Change) error {
+	return db.QueryRowxContext(ctx,
+		`INSERT INTO lifecycle_changes (kind, employee_id, payload, status, effective_at, applied_at)
+		VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, created_at`,
+		change.Kind, change.EmployeeId, change.Payload, change.Status, change.EffectiveAt, change.AppliedAt,
+	).Scan(&change.Id, &change.CreatedAt)
+}
+
+func markApplied(ctx context.Context, tx *sqlx.Tx, change *Change) error {
+	appliedAt := time.Now()
+	change.Status = ChangeApplied
+	change.AppliedAt = &appliedAt
+
+	if change.Id == 0 {
+		return insertChange(ctx, tx, change)
+	}
+
+	result, err := tx.ExecContext(ctx,
+		"UPDATE lifecycle_changes SET status = $1, employee_id = $2, applied_at = $3 WHERE id = $4 AND status = $5",
+		ChangeApplied, change.EmployeeId, change.AppliedAt, change.Id, ChangePending)
+	if err != nil {
+		return err
+	}
+
+	count, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrChangeNotPending
+	}
+
+	return nil
+}
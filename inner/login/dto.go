@@ -0,0 +1,25 @@
+package login
+
+import "time"
+
+// Result итог шага входа. Token и Methods заполнены, пока вход не завершён.
+type Result struct {
+	Status        string     `json:"status"`
+	EmployeeId    int64      `json:"employee_id"`
+	Token         string     `json:"token,omitempty"`
+	Methods       []string   `json:"methods,omitempty"`
+	ExpiresAt     *time.Time `json:"expires_at,omitempty"`
+	AuthTime      time.Time  `json:"auth_time,omitzero"`
+	RecoveryCodes []string   `json:"recovery_codes,omitempty"`
+}
+
+// CredentialsRequest первый шаг входа: имя пользователя и пароль
+type CredentialsRequest struct {
+	UserName string `json:"user_name"`
+	Password string `json:"password"`
+}
+
+// CodeRequest код TOTP или резервный код
+type CodeRequest struct {
+	Code string `json:"code"`
+}
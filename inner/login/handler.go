@@ -0,0 +1,180 @@
+package login
+
+import (
+	"errors"
+	"github.com/go-webauthn/webauthn/protocol"
+	"idm/inner/httpapi"
+	"idm/inner/mfa"
+	"net/http"
+	"time"
+)
+
+// TokenHeader заголовок, в котором передаётся токен незавершённого входа
+const TokenHeader = "X-Login-Token"
+
+// statuses коды ответа для ошибок входа; ошибки без кода – 500 без подробностей
+var statuses = httpapi.Statuses{
+	{Err: ErrInvalidCredentials, Code: http.StatusUnauthorized},
+	{Err: ErrInvalidToken, Code: http.StatusUnauthorized},
+	{Err: mfa.ErrInvalidCode, Code: http.StatusUnauthorized},
+	{Err: mfa.ErrInvalidAssertion, Code: http.StatusUnauthorized},
+	{Err: ErrLocked, Code: http.StatusLocked},
+	{Err: ErrDisabled, Code: http.StatusForbidden},
+	{Err: ErrMethodNotAllowed, Code: http.StatusBadRequest},
+	{Err: mfa.ErrCeremonyNotFound, Code: http.StatusBadRequest},
+	{Err: mfa.ErrNotEnrolled, Code: http.StatusConflict},
+	{Err: mfa.ErrAlreadyEnrolled, Code: http.StatusConflict},
+	{Err: mfa.ErrWebAuthnDisabled, Code: http.StatusNotImplemented},
+}
+
+// Sessions начинает сеанс сотрудника, завершившего вход
+type Sessions interface {
+	Start(w http.ResponseWriter, r *http.Request, employeeId int64, authTime time.Time) error
+}
+
+// Handler HTTP-эндпоинты входа. Пути задаются относительно точки монтирования,
+// поэтому при монтировании нужно использовать http.StripPrefix.
+type Handler struct {
+	service  *Service
+	sessions Sessions
+	mux      *http.ServeMux
+}
+
+func NewHandler(service *Service, sessions Sessions) *Handler {
+	h := &Handler{service: service, sessions: sessions, mux: http.NewServeMux()}
+
+	h.mux.HandleFunc("POST /{$}", h.login)
+	h.mux.HandleFunc("POST /totp", h.totp)
+	h.mux.HandleFunc("POST /recovery", h.recovery)
+	h.mux.HandleFunc("POST /webauthn/begin", h.beginWebAuthn)
+	h.mux.HandleFunc("POST /webauthn/finish", h.finishWebAuthn)
+	h.mux.HandleFunc("POST /enroll/totp", h.enrollTOTP)
+	h.mux.HandleFunc("POST /enroll/totp/confirm", h.confirmTOTP)
+	h.mux.HandleFunc("POST /enroll/webauthn", h.enrollWebAuthn)
+	h.mux.HandleFunc("POST /enroll/webauthn/confirm", h.confirmWebAuthn)
+
+	return h
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+func (h *Handler) login(w http.ResponseWriter, r *http.Request) {
+	var request CredentialsRequest
+	if !httpapi.Decode(w, r, &request) {
+		return
+	}
+
+	result, err := h.service.Login(request.UserName, request.Password)
+	h.writeResult(w, r, result, err)
+}
+
+func (h *Handler) totp(w http.ResponseWriter, r *http.Request) {
+	code, ok := decodeCode(w, r)
+	if !ok {
+		return
+	}
+
+	result, err := h.service.VerifyTOTP(r.Header.Get(TokenHeader), code)
+	h.writeResult(w, r, result, err)
+}
+
+func (h *Handler) recovery(w http.ResponseWriter, r *http.Request) {
+	code, ok := decodeCode(w, r)
+	if !ok {
+		return
+	}
+
+	result, err := h.service.UseRecoveryCode(r.Header.Get(TokenHeader), code)
+	h.writeResult(w, r, result, err)
+}
+
+func (h *Handler) beginWebAuthn(w http.ResponseWriter, r *http.Request) {
+	assertion, err := h.service.BeginWebAuthn(r.Header.Get(TokenHeader))
+	if err != nil {
+		httpapi.Fail(w, err, statuses)
+		return
+	}
+
+	httpapi.WriteJSON(w, http.StatusOK, assertion)
+}
+
+func (h *Handler) finishWebAuthn(w http.ResponseWriter, r *http.Request) {
+	response, err := protocol.ParseCredentialRequestResponseBody(r.Body)
+	if err != nil {
+		httpapi.Fail(w, errors.Join(mfa.ErrInvalidAssertion, err), statuses)
+		return
+	}
+
+	result, err := h.service.FinishWebAuthn(r.Header.Get(TokenHeader), response)
+	h.writeResult(w, r, result, err)
+}
+
+func (h *Handler) enrollTOTP(w http.ResponseWriter, r *http.Request) {
+	enrollment, err := h.service.EnrollTOTP(r.Header.Get(TokenHeader))
+	if err != nil {
+		httpapi.Fail(w, err, statuses)
+		return
+	}
+
+	httpapi.WriteJSON(w, http.StatusOK, enrollment)
+}
+
+func (h *Handler) confirmTOTP(w http.ResponseWriter, r *http.Request) {
+	code, ok := decodeCode(w, r)
+	if !ok {
+		return
+	}
+
+	result, err := h.service.ConfirmTOTP(r.Header.Get(TokenHeader), code)
+	h.writeResult(w, r, result, err)
+}
+
+func (h *Handler) enrollWebAuthn(w http.ResponseWriter, r *http.Request) {
+	creation, err := h.service.EnrollWebAuthn(r.Header.Get(TokenHeader))
+	if err != nil {
+		httpapi.Fail(w, err, statuses)
+		return
+	}
+
+	httpapi.WriteJSON(w, http.StatusOK, creation)
+}
+
+// confirmWebAuthn тело – ответ navigator.credentials.create(), название ключа передаётся в параметре name
+func (h *Handler) confirmWebAuthn(w http.ResponseWriter, r *http.Request) {
+	response, err := protocol.ParseCredentialCreationResponseBody(r.Body)
+	if err != nil {
+		httpapi.Fail(w, errors.Join(mfa.ErrInvalidAssertion, err), statuses)
+		return
+	}
+
+	result, err := h.service.ConfirmWebAuthn(r.Header.Get(TokenHeader), r.URL.Query().Get("name"), response)
+	h.writeResult(w, r, result, err)
+}
+
+func decodeCode(w http.ResponseWriter, r *http.Request) (string, bool) {
+	var request CodeRequest
+	if !httpapi.Decode(w, r, &request) {
+		return "", false
+	}
+
+	return request.Code, true
+}
+
+// writeResult ответить итогом шага входа; после завершения входа начинается сеанс
+func (h *Handler) writeResult(w http.ResponseWriter, r *http.Request, result Result, err error) {
+	if err != nil {
+		httpapi.Fail(w, err, statuses)
+		return
+	}
+
+	if result.Status == StatusAuthenticated {
+		if err := h.sessions.Start(w, r, result.EmployeeId, result.AuthTime); err != nil {
+			httpapi.Fail(w, err, statuses)
+			return
+		}
+	}
+
+	httpapi.WriteJSON(w, http.StatusOK, result)
+}
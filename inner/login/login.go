@@ -0,0 +1,312 @@
+package login
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/go-webauthn/webauthn/protocol"
+	"idm/inner/credential"
+	"idm/inner/database"
+	"idm/inner/employee"
+	"idm/inner/mfa"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	StatusAuthenticated      = "authenticated"
+	StatusMFARequired        = "mfa_required"
+	StatusEnrollmentRequired = "enrollment_required"
+
+	// DefaultPendingTTL сколько действует вход, ожидающий второго фактора
+	DefaultPendingTTL = 5 * time.Minute
+	// MaxSecondFactorAttempts после стольких неверных кодов нужно заново вводить пароль
+	MaxSecondFactorAttempts = 5
+)
+
+var (
+	ErrInvalidCredentials = credential.ErrInvalidCredentials
+	ErrLocked             = credential.ErrLocked
+	ErrDisabled           = errors.New("employee is disabled")
+	ErrInvalidToken       = errors.New("login token is invalid or expired")
+	ErrMethodNotAllowed   = errors.New("method is not available for this login")
+)
+
+// Employees поиск сотрудника по имени для входа
+type Employees interface {
+	FindByUserName(userName string) (employee.Response, error)
+}
+
+// Credentials проверка пароля
+type Credentials interface {
+	Verify(employeeId int64, password string) error
+}
+
+// MFA второй фактор и политика его обязательности
+type MFA interface {
+	Methods(employeeId int64) ([]string, error)
+	Required(employeeId int64) (bool, error)
+	VerifyTOTP(employeeId int64, code string) error
+	UseRecoveryCode(employeeId int64, code string) error
+	BeginWebAuthnLogin(employeeId int64) (*protocol.CredentialAssertion, error)
+	FinishWebAuthnLogin(employeeId int64, response *protocol.ParsedCredentialAssertionData) error
+	BeginTOTP(employeeId int64) (mfa.TOTPEnrollment, error)
+	ConfirmTOTP(employeeId int64, code string) ([]string, error)
+	BeginWebAuthnRegistration(employeeId int64) (*protocol.CredentialCreation, error)
+	FinishWebAuthnRegistration(employeeId int64, name string,
+		response *protocol.ParsedCredentialCreationData) (mfa.WebAuthnEnrollment, error)
+}
+
+// pending вход, прошедший проверку пароля и ожидающий второго фактора или его подключения
+type pending struct {
+	employeeId int64
+	status     string
+	methods    []string
+	attempts   int
+	expiresAt  time.Time
+}
+
+type Service struct {
+	employees   Employees
+	credentials Credentials
+	mfa         MFA
+	pendingTTL  time.Duration
+	now         func() time.Time
+
+	mu      sync.Mutex
+	pending map[string]*pending
+}
+
+func NewService(employees Employees, credentials Credentials, mfa MFA) *Service {
+	return &Service{
+		employees:   employees,
+		credentials: credentials,
+		mfa:         mfa,
+		pendingTTL:  DefaultPendingTTL,
+		now:         time.Now,
+		pending:     map[string]*pending{},
+	}
+}
+
+// Login проверить имя и пароль. Если сотруднику нужен второй фактор, возвращается
+// токен незавершённого входа, с которым вызываются методы подтверждения.
+func (s *Service) Login(userName string, password string) (Result, error) {
+	found, err := s.employees.FindByUserName(userName)
+	if err != nil {
+		if !errors.Is(err, database.ErrRecordNotFound) {
+			return Result{}, err
+		}
+		// проверка несуществующего пароля занимает столько же времени, сколько настоящая
+		_ = s.credentials.Verify(0, password)
+		return Result{}, ErrInvalidCredentials
+	}
+
+	if err := s.credentials.Verify(found.Id, password); err != nil {
+		return Result{}, err
+	}
+	if found.Status != employee.StatusActive {
+		return Result{}, ErrDisabled
+	}
+
+	methods, err := s.mfa.Methods(found.Id)
+	if err != nil {
+		return Result{}, err
+	}
+	if len(methods) > 0 {
+		return s.begin(found.Id, StatusMFARequired, methods)
+	}
+
+	required, err := s.mfa.Required(found.Id)
+	if err != nil {
+		return Result{}, err
+	}
+	if required {
+		return s.begin(found.Id, StatusEnrollmentRequired, []string{mfa.MethodTOTP, mfa.MethodWebAuthn})
+	}
+
+	return s.authenticated(found.Id), nil
+}
+
+// VerifyTOTP завершить вход кодом из приложения-аутентификатора
+func (s *Service) VerifyTOTP(token string, code string) (Result, error) {
+	return s.complete(token, mfa.MethodTOTP, func(employeeId int64) error {
+		return s.mfa.VerifyTOTP(employeeId, code)
+	})
+}
+
+// UseRecoveryCode завершить вход кодом восстановления
+func (s *Service) UseRecoveryCode(token string, code string) (Result, error) {
+	return s.complete(token, mfa.MethodRecovery, func(employeeId int64) error {
+		return s.mfa.UseRecoveryCode(employeeId, code)
+	})
+}
+
+func (s *Service) BeginWebAuthn(token string) (*protocol.CredentialAssertion, error) {
+	p, err := s.find(token, StatusMFARequired, mfa.MethodWebAuthn)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.mfa.BeginWebAuthnLogin(p.employeeId)
+}
+
+// FinishWebAuthn завершить вход подписью ключа безопасности
+func (s *Service) FinishWebAuthn(token string, response *protocol.ParsedCredentialAssertionData) (Result, error) {
+	return s.complete(token, mfa.MethodWebAuthn, func(employeeId int64) error {
+		return s.mfa.FinishWebAuthnLogin(employeeId, response)
+	})
+}
+
+// EnrollTOTP выдать секрет сотруднику, которому политика не позволяет войти без MFA
+func (s *Service) EnrollTOTP(token string) (mfa.TOTPEnrollment, error) {
+	p, err := s.find(token, StatusEnrollmentRequired, mfa.MethodTOTP)
+	if err != nil {
+		return mfa.TOTPEnrollment{}, err
+	}
+
+	return s.mfa.BeginTOTP(p.employeeId)
+}
+
+// ConfirmTOTP подтвердить подключённое приложение и завершить вход
+func (s *Service) ConfirmTOTP(token string, code string) (Result, error) {
+	var codes []string
+	result, err := s.completeEnrollment(token, mfa.MethodTOTP, func(employeeId int64) (err error) {
+		codes, err = s.mfa.ConfirmTOTP(employeeId, code)
+		return err
+	})
+	result.RecoveryCodes = codes
+
+	return result, err
+}
+
+func (s *Service) EnrollWebAuthn(token string) (*protocol.CredentialCreation, error) {
+	p, err := s.find(token, StatusEnrollmentRequired, mfa.MethodWebAuthn)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.mfa.BeginWebAuthnRegistration(p.employeeId)
+}
+
+// ConfirmWebAuthn сохранить зарегистрированный ключ и завершить вход
+func (s *Service) ConfirmWebAuthn(token string, name string, response *protocol.ParsedCredentialCreationData) (Result, error) {
+	var enrollment mfa.WebAuthnEnrollment
+	result, err := s.completeEnrollment(token, mfa.MethodWebAuthn, func(employeeId int64) (err error) {
+		enrollment, err = s.mfa.FinishWebAuthnRegistration(employeeId, name, response)
+		return err
+	})
+	result.RecoveryCodes = enrollment.RecoveryCodes
+
+	return result, err
+}
+
+func (s *Service) begin(employeeId int64, status string, methods []string) (Result, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return Result{}, fmt.Errorf("error generating login token: %w", err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(raw)
+	expiresAt := s.now().Add(s.pendingTTL)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.prune()
+	s.pending[hashToken(token)] = &pending{employeeId: employeeId, status: status, methods: methods, expiresAt: expiresAt}
+
+	return Result{Status: status, EmployeeId: employeeId, Token: token, Methods: methods, ExpiresAt: &expiresAt}, nil
+}
+
+func (s *Service) find(token string, status string, method string) (*pending, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, ok := s.pending[hashToken(token)]
+	if !ok || !s.now().Before(p.expiresAt) {
+		return nil, ErrInvalidToken
+	}
+	if p.status != status || !slices.Contains(p.methods, method) {
+		return nil, ErrMethodNotAllowed
+	}
+
+	return p, nil
+}
+
+// complete проверить второй фактор; после нескольких ошибок незавершённый вход аннулируется
+func (s *Service) complete(token string, method string, verify func(employeeId int64) error) (Result, error) {
+	p, err := s.find(token, StatusMFARequired, method)
+	if err != nil {
+		return Result{}, err
+	}
+
+	if err := verify(p.employeeId); err != nil {
+		if errors.Is(err, mfa.ErrInvalidCode) || errors.Is(err, mfa.ErrInvalidAssertion) {
+			s.fail(token)
+		}
+		return Result{}, err
+	}
+
+	s.forget(token)
+
+	return s.authenticated(p.employeeId), nil
+}
+
+func (s *Service) completeEnrollment(token string, method string, enroll func(employeeId int64) error) (Result, error) {
+	p, err := s.find(token, StatusEnrollmentRequired, method)
+	if err != nil {
+		return Result{}, err
+	}
+
+	if err := enroll(p.employeeId); err != nil {
+		if errors.Is(err, mfa.ErrInvalidCode) || errors.Is(err, mfa.ErrInvalidAssertion) {
+			s.fail(token)
+		}
+		return Result{}, err
+	}
+
+	s.forget(token)
+
+	return s.authenticated(p.employeeId), nil
+}
+
+func (s *Service) fail(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := hashToken(token)
+	if p, ok := s.pending[key]; ok {
+		p.attempts++
+		if p.attempts >= MaxSecondFactorAttempts {
+			delete(s.pending, key)
+		}
+	}
+}
+
+func (s *Service) forget(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.pending, hashToken(token))
+}
+
+// prune удалить просроченные входы; вызывается под блокировкой
+func (s *Service) prune() {
+	now := s.now()
+	for key, p := range s.pending {
+		if !now.Before(p.expiresAt) {
+			delete(s.pending, key)
+		}
+	}
+}
+
+func (s *Service) authenticated(employeeId int64) Result {
+	return Result{Status: StatusAuthenticated, EmployeeId: employeeId, AuthTime: s.now()}
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return string(sum[:])
+}
@@ -0,0 +1,270 @@
+package login
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/go-webauthn/webauthn/protocol"
+	assertpackage "github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"idm/inner/credential"
+	"idm/inner/database"
+	"idm/inner/employee"
+	"idm/inner/mfa"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type StubEmployees map[string]employee.Response
+
+func (s StubEmployees) FindByUserName(userName string) (employee.Response, error) {
+	found, ok := s[userName]
+	if !ok {
+		return employee.Response{}, database.ErrRecordNotFound
+	}
+	return found, nil
+}
+
+type MockCredentials struct {
+	mock.Mock
+}
+
+func (m *MockCredentials) Verify(employeeId int64, password string) error {
+	args := m.Called(employeeId, password)
+	return args.Error(0)
+}
+
+type MockMFA struct {
+	mock.Mock
+}
+
+func (m *MockMFA) Methods(employeeId int64) ([]string, error) {
+	args := m.Called(employeeId)
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *MockMFA) Required(employeeId int64) (bool, error) {
+	args := m.Called(employeeId)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockMFA) VerifyTOTP(employeeId int64, code string) error {
+	args := m.Called(employeeId, code)
+	return args.Error(0)
+}
+
+func (m *MockMFA) UseRecoveryCode(employeeId int64, code string) error {
+	args := m.Called(employeeId, code)
+	return args.Error(0)
+}
+
+func (m *MockMFA) BeginWebAuthnLogin(employeeId int64) (*protocol.CredentialAssertion, error) {
+	args := m.Called(employeeId)
+	return args.Get(0).(*protocol.CredentialAssertion), args.Error(1)
+}
+
+func (m *MockMFA) FinishWebAuthnLogin(employeeId int64, response *protocol.ParsedCredentialAssertionData) error {
+	args := m.Called(employeeId, response)
+	return args.Error(0)
+}
+
+func (m *MockMFA) BeginTOTP(employeeId int64) (mfa.TOTPEnrollment, error) {
+	args := m.Called(employeeId)
+	return args.Get(0).(mfa.TOTPEnrollment), args.Error(1)
+}
+
+func (m *MockMFA) ConfirmTOTP(employeeId int64, code string) ([]string, error) {
+	args := m.Called(employeeId, code)
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *MockMFA) BeginWebAuthnRegistration(employeeId int64) (*protocol.CredentialCreation, error) {
+	args := m.Called(employeeId)
+	return args.Get(0).(*protocol.CredentialCreation), args.Error(1)
+}
+
+func (m *MockMFA) FinishWebAuthnRegistration(employeeId int64, name string,
+	response *protocol.ParsedCredentialCreationData) (mfa.WebAuthnEnrollment, error) {
+	args := m.Called(employeeId, name, response)
+	return args.Get(0).(mfa.WebAuthnEnrollment), args.Error(1)
+}
+
+type StubSessions struct {
+	started []int64
+}
+
+func (s *StubSessions) Start(w http.ResponseWriter, r *http.Request, employeeId int64, authTime time.Time) error {
+	s.started = append(s.started, employeeId)
+	http.SetCookie(w, &http.Cookie{Name: "session", Value: "token"})
+	return nil
+}
+
+var employees = StubEmployees{
+	"jdoe":  {Id: 1, UserName: "jdoe", Status: employee.StatusActive},
+	"admin": {Id: 2, UserName: "admin", Status: employee.StatusActive},
+	"gone":  {Id: 3, UserName: "gone", Status: employee.StatusDisabled},
+}
+
+func TestLoginService(t *testing.T) {
+	assert := assertpackage.New(t)
+
+	newService := func() (*Service, *MockCredentials, *MockMFA) {
+		credentials, factors := new(MockCredentials), new(MockMFA)
+		credentials.On("Verify", mock.Anything, "secret").Return(nil)
+		credentials.On("Verify", mock.Anything, mock.Anything).Return(credential.ErrInvalidCredentials)
+		return NewService(employees, credentials, factors), credentials, factors
+	}
+
+	t.Run("should authenticate without mfa when policy does not require it", func(t *testing.T) {
+		service, _, factors := newService()
+		factors.On("Methods", int64(1)).Return([]string(nil), nil)
+		factors.On("Required", int64(1)).Return(false, nil)
+
+		result, err := service.Login("jdoe", "secret")
+
+		assert.Nil(err)
+		assert.Equal(StatusAuthenticated, result.Status)
+		assert.Equal(int64(1), result.EmployeeId)
+		assert.Empty(result.Token)
+	})
+
+	t.Run("should verify a password even for unknown users", func(t *testing.T) {
+		service, credentials, _ := newService()
+
+		_, err := service.Login("nobody", "secret")
+
+		assert.Equal(ErrInvalidCredentials, err)
+		credentials.AssertCalled(t, "Verify", int64(0), "secret")
+	})
+
+	t.Run("should reject a wrong password and a disabled employee", func(t *testing.T) {
+		service, _, _ := newService()
+
+		_, err := service.Login("jdoe", "wrong")
+		assert.Equal(ErrInvalidCredentials, err)
+
+		_, err = service.Login("gone", "secret")
+		assert.Equal(ErrDisabled, err)
+	})
+
+	t.Run("should require the second factor when it is enrolled", func(t *testing.T) {
+		service, _, factors := newService()
+		factors.On("Methods", int64(1)).Return([]string{mfa.MethodTOTP, mfa.MethodRecovery}, nil)
+		factors.On("VerifyTOTP", int64(1), "123456").Return(nil)
+
+		result, err := service.Login("jdoe", "secret")
+		assert.Nil(err)
+		assert.Equal(StatusMFARequired, result.Status)
+		assert.NotEmpty(result.Token)
+
+		_, err = service.FinishWebAuthn(result.Token, nil)
+		assert.Equal(ErrMethodNotAllowed, err)
+
+		completed, err := service.VerifyTOTP(result.Token, "123456")
+		assert.Nil(err)
+		assert.Equal(StatusAuthenticated, completed.Status)
+
+		_, err = service.VerifyTOTP(result.Token, "123456")
+		assert.Equal(ErrInvalidToken, err)
+	})
+
+	t.Run("should drop the pending login after too many wrong codes", func(t *testing.T) {
+		service, _, factors := newService()
+		factors.On("Methods", int64(1)).Return([]string{mfa.MethodTOTP}, nil)
+		factors.On("VerifyTOTP", int64(1), mock.Anything).Return(mfa.ErrInvalidCode)
+
+		result, _ := service.Login("jdoe", "secret")
+		for range MaxSecondFactorAttempts {
+			_, err := service.VerifyTOTP(result.Token, "000000")
+			assert.Equal(mfa.ErrInvalidCode, err)
+		}
+
+		_, err := service.VerifyTOTP(result.Token, "000000")
+		assert.Equal(ErrInvalidToken, err)
+	})
+
+	t.Run("should expire a pending login", func(t *testing.T) {
+		service, _, factors := newService()
+		factors.On("Methods", int64(1)).Return([]string{mfa.MethodTOTP}, nil)
+		now := time.Now()
+		service.now = func() time.Time { return now }
+
+		result, _ := service.Login("jdoe", "secret")
+		now = now.Add(DefaultPendingTTL)
+
+		_, err := service.VerifyTOTP(result.Token, "123456")
+		assert.Equal(ErrInvalidToken, err)
+	})
+
+	t.Run("should force enrollment for roles that require mfa", func(t *testing.T) {
+		service, _, factors := newService()
+		factors.On("Methods", int64(2)).Return([]string(nil), nil)
+		factors.On("Required", int64(2)).Return(true, nil)
+		factors.On("BeginTOTP", int64(2)).Return(mfa.TOTPEnrollment{Secret: "S", ProvisioningURI: "otpauth://totp/x"}, nil)
+		factors.On("ConfirmTOTP", int64(2), "123456").Return([]string{"aaaaa-bbbbb"}, nil)
+
+		result, err := service.Login("admin", "secret")
+		assert.Nil(err)
+		assert.Equal(StatusEnrollmentRequired, result.Status)
+
+		_, err = service.VerifyTOTP(result.Token, "123456")
+		assert.Equal(ErrMethodNotAllowed, err)
+
+		enrollment, err := service.EnrollTOTP(result.Token)
+		assert.Nil(err)
+		assert.Equal("S", enrollment.Secret)
+
+		completed, err := service.ConfirmTOTP(result.Token, "123456")
+		assert.Nil(err)
+		assert.Equal(StatusAuthenticated, completed.Status)
+		assert.Equal([]string{"aaaaa-bbbbb"}, completed.RecoveryCodes)
+	})
+}
+
+func TestLoginHandler(t *testing.T) {
+	assert := assertpackage.New(t)
+
+	credentials, factors := new(MockCredentials), new(MockMFA)
+	credentials.On("Verify", mock.Anything, "secret").Return(nil)
+	credentials.On("Verify", mock.Anything, mock.Anything).Return(credential.ErrInvalidCredentials)
+	factors.On("Methods", int64(1)).Return([]string{mfa.MethodTOTP}, nil)
+	factors.On("VerifyTOTP", int64(1), "123456").Return(nil)
+	sessions := &StubSessions{}
+	handler := NewHandler(NewService(employees, credentials, factors), sessions)
+
+	post := func(path string, token string, body any) (*httptest.ResponseRecorder, Result) {
+		data, _ := json.Marshal(body)
+		request := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(data))
+		if token != "" {
+			request.Header.Set(TokenHeader, token)
+		}
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+		var result Result
+		_ = json.Unmarshal(recorder.Body.Bytes(), &result)
+		return recorder, result
+	}
+
+	t.Run("should reject wrong credentials with 401", func(t *testing.T) {
+		recorder, _ := post("/", "", CredentialsRequest{UserName: "jdoe", Password: "wrong"})
+		assert.Equal(http.StatusUnauthorized, recorder.Code)
+	})
+
+	t.Run("should complete a login with totp", func(t *testing.T) {
+		recorder, result := post("/", "", CredentialsRequest{UserName: "jdoe", Password: "secret"})
+		assert.Equal(http.StatusOK, recorder.Code)
+		assert.Equal(StatusMFARequired, result.Status)
+		assert.Empty(recorder.Result().Cookies())
+		assert.Equal("no-store", recorder.Header().Get("Cache-Control"))
+
+		recorder, _ = post("/recovery", result.Token, CodeRequest{Code: "aaaaa-bbbbb"})
+		assert.Equal(http.StatusBadRequest, recorder.Code)
+
+		recorder, completed := post("/totp", result.Token, CodeRequest{Code: "123456"})
+		assert.Equal(http.StatusOK, recorder.Code)
+		assert.Equal(StatusAuthenticated, completed.Status)
+		assert.Equal([]int64{1}, sessions.started)
+		assert.Len(recorder.Result().Cookies(), 1)
+	})
+}
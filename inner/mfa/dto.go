@@ -0,0 +1,47 @@
+package mfa
+
+import "time"
+
+// TOTPEnrollment данные для настройки приложения-аутентификатора
+type TOTPEnrollment struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+}
+
+type WebAuthnCredentialResponse struct {
+	Id         string     `json:"id"`
+	Name       string     `json:"name"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+func (c *WebAuthnCredential) ToResponse() WebAuthnCredentialResponse {
+	return WebAuthnCredentialResponse{Id: c.Id, Name: c.Name, LastUsedAt: c.LastUsedAt, CreatedAt: c.CreatedAt}
+}
+
+// WebAuthnEnrollment результат регистрации ключа. Коды восстановления выдаются,
+// только если у сотрудника их ещё нет.
+type WebAuthnEnrollment struct {
+	Credential    WebAuthnCredentialResponse `json:"credential"`
+	RecoveryCodes []string                   `json:"recovery_codes,omitempty"`
+}
+
+// StatusResponse подключённые способы MFA сотрудника
+type StatusResponse struct {
+	EmployeeId    int64                        `json:"employee_id"`
+	Required      bool                         `json:"required"`
+	TOTP          bool                         `json:"totp"`
+	WebAuthn      []WebAuthnCredentialResponse `json:"webauthn"`
+	RecoveryCodes int                          `json:"recovery_codes"`
+}
+
+type EventResponse struct {
+	Id        int64     `json:"id"`
+	Kind      string    `json:"kind"`
+	Detail    string    `json:"detail,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (e *Event) ToResponse() EventResponse {
+	return EventResponse{Id: e.Id, Kind: e.Kind, Detail: e.Detail, CreatedAt: e.CreatedAt}
+}
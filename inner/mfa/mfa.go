@@ -0,0 +1,644 @@
+package mfa
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"idm/inner/database"
+	"idm/inner/employee"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	MethodTOTP     = "totp"
+	MethodWebAuthn = "webauthn"
+	MethodRecovery = "recovery"
+
+	// DefaultSkew сколько соседних 30-секундных шагов принимается из-за расхождения часов
+	DefaultSkew          = 1
+	RecoveryCodeCount    = 10
+	CeremonyTTL          = 5 * time.Minute
+	ceremonyRegistration = "registration"
+	ceremonyLogin        = "login"
+)
+
+// Виды событий аудита
+const (
+	EventTOTPStarted        = "totp_started"
+	EventTOTPConfirmed      = "totp_confirmed"
+	EventTOTPRemoved        = "totp_removed"
+	EventRecoveryGenerated  = "recovery_codes_generated"
+	EventRecoveryUsed       = "recovery_code_used"
+	EventWebAuthnRegistered = "webauthn_registered"
+	EventWebAuthnRemoved    = "webauthn_removed"
+)
+
+var (
+	ErrInvalidCode      = errors.New("invalid code")
+	ErrNotEnrolled      = errors.New("method is not enrolled")
+	ErrAlreadyEnrolled  = errors.New("method is already enrolled")
+	ErrWebAuthnDisabled = errors.New("webauthn is not configured")
+	ErrInvalidAssertion = errors.New("invalid webauthn response")
+	ErrCeremonyNotFound = errors.New("webauthn ceremony not found or expired")
+)
+
+type Repo interface {
+	FindTOTP(employeeId int64) (*TOTP, error)
+	SaveTOTP(totp *TOTP, event *Event) error
+	ConfirmTOTP(employeeId int64, step int64, recoveryHashes []string, event *Event) error
+	UseTOTPStep(employeeId int64, step int64) error
+	RemoveTOTP(employeeId int64, event *Event) error
+	ReplaceRecoveryCodes(employeeId int64, hashes []string, event *Event) error
+	UseRecoveryCode(employeeId int64, hash string, event *Event) error
+	CountRecoveryCodes(employeeId int64) (int, error)
+	FindWebAuthnCredentials(employeeId int64) ([]*WebAuthnCredential, error)
+	CreateWebAuthnCredential(credential *WebAuthnCredential, event *Event) error
+	UpdateWebAuthnCredential(credential *WebAuthnCredential) error
+	RemoveWebAuthnCredential(employeeId int64, id string, event *Event) error
+	SaveCeremony(ceremony *Ceremony) error
+	TakeCeremony(employeeId int64, kind string) (*Ceremony, error)
+	IsRequired(employeeId int64) (bool, error)
+	FindRequiredRoleIds() ([]int64, error)
+	SetRequiredRoleIds(roleIds []int64) error
+	FindEvents(employeeId int64) ([]*Event, error)
+}
+
+// Employees источник имён сотрудников для аутентификаторов
+type Employees interface {
+	FindById(id int64) (employee.Response, error)
+}
+
+type Service struct {
+	repo      Repo
+	employees Employees
+	issuer    string
+	webauthn  *webauthn.WebAuthn
+	skew      int
+	now       func() time.Time
+}
+
+// NewService создать сервис MFA. issuer – название, под которым учётная запись видна в приложении-аутентификаторе.
+func NewService(repository Repo, employees Employees, issuer string) *Service {
+	return &Service{repo: repository, employees: employees, issuer: issuer, skew: DefaultSkew, now: time.Now}
+}
+
+// UseWebAuthn включить регистрацию и проверку ключей WebAuthn
+func (s *Service) UseWebAuthn(web *webauthn.WebAuthn) {
+	s.webauthn = web
+}
+
+// SetSkew изменить допустимое расхождение часов в шагах TOTP
+func (s *Service) SetSkew(steps int) {
+	s.skew = steps
+}
+
+// Status подключённые способы MFA сотрудника
+func (s *Service) Status(employeeId int64) (StatusResponse, error) {
+	response := StatusResponse{EmployeeId: employeeId, WebAuthn: []WebAuthnCredentialResponse{}}
+
+	required, err := s.Required(employeeId)
+	if err != nil {
+		return StatusResponse{}, err
+	}
+	response.Required = required
+
+	totp, err := s.findTOTP(employeeId)
+	if err != nil {
+		return StatusResponse{}, err
+	}
+	response.TOTP = totp != nil && totp.ConfirmedAt != nil
+
+	credentials, err := s.repo.FindWebAuthnCredentials(employeeId)
+	if err != nil {
+		return StatusResponse{}, fmt.Errorf("error finding webauthn credentials of employee with id %d: %w", employeeId, err)
+	}
+	for _, credential := range credentials {
+		response.WebAuthn = append(response.WebAuthn, credential.ToResponse())
+	}
+
+	response.RecoveryCodes, err = s.repo.CountRecoveryCodes(employeeId)
+	if err != nil {
+		return StatusResponse{}, fmt.Errorf("error counting recovery codes of employee with id %d: %w", employeeId, err)
+	}
+
+	return response, nil
+}
+
+// Methods способы второго фактора, которыми сотрудник может подтвердить вход
+func (s *Service) Methods(employeeId int64) ([]string, error) {
+	status, err := s.Status(employeeId)
+	if err != nil {
+		return nil, err
+	}
+
+	var methods []string
+	if status.TOTP {
+		methods = append(methods, MethodTOTP)
+	}
+	if len(status.WebAuthn) > 0 && s.webauthn != nil {
+		methods = append(methods, MethodWebAuthn)
+	}
+	if len(methods) > 0 && status.RecoveryCodes > 0 {
+		methods = append(methods, MethodRecovery)
+	}
+
+	return methods, nil
+}
+
+// Required требует ли политика MFA от сотрудника из-за одной из его ролей
+func (s *Service) Required(employeeId int64) (bool, error) {
+	required, err := s.repo.IsRequired(employeeId)
+	if err != nil {
+		return false, fmt.Errorf("error checking mfa policy for employee with id %d: %w", employeeId, err)
+	}
+
+	return required, nil
+}
+
+// RequiredRoleIds роли, обладателям которых вход без MFA запрещён
+func (s *Service) RequiredRoleIds() ([]int64, error) {
+	ids, err := s.repo.FindRequiredRoleIds()
+	if err != nil {
+		return nil, fmt.Errorf("error finding mfa policy: %w", err)
+	}
+
+	return ids, nil
+}
+
+// SetRequiredRoleIds заменить политику: список ролей, для которых требуется MFA
+func (s *Service) SetRequiredRoleIds(roleIds []int64) error {
+	if err := s.repo.SetRequiredRoleIds(roleIds); err != nil {
+		return fmt.Errorf("error saving mfa policy: %w", err)
+	}
+
+	return nil
+}
+
+// Events история изменений способов MFA сотрудника
+func (s *Service) Events(employeeId int64) ([]EventResponse, error) {
+	events, err := s.repo.FindEvents(employeeId)
+	if err != nil {
+		return nil, fmt.Errorf("error finding mfa events of employee with id %d: %w", employeeId, err)
+	}
+
+	responses := make([]EventResponse, 0, len(events))
+	for _, event := range events {
+		responses = append(responses, event.ToResponse())
+	}
+
+	return responses, nil
+}
+
+// BeginTOTP выдать новый секрет. Он начнёт действовать после ConfirmTOTP.
+func (s *Service) BeginTOTP(employeeId int64) (TOTPEnrollment, error) {
+	existing, err := s.findTOTP(employeeId)
+	if err != nil {
+		return TOTPEnrollment{}, err
+	}
+	if existing != nil && existing.ConfirmedAt != nil {
+		return TOTPEnrollment{}, fmt.Errorf("%w: remove the current authenticator first", ErrAlreadyEnrolled)
+	}
+
+	account, err := s.account(employeeId)
+	if err != nil {
+		return TOTPEnrollment{}, err
+	}
+
+	secret, err := newSecret()
+	if err != nil {
+		return TOTPEnrollment{}, fmt.Errorf("error generating totp secret: %w", err)
+	}
+
+	err = s.repo.SaveTOTP(&TOTP{EmployeeId: employeeId, Secret: secret}, &Event{EmployeeId: employeeId, Kind: EventTOTPStarted})
+	if err != nil {
+		return TOTPEnrollment{}, fmt.Errorf("error saving totp secret of employee with id %d: %w", employeeId, err)
+	}
+
+	return TOTPEnrollment{Secret: secret, ProvisioningURI: ProvisioningURI(s.issuer, account, secret)}, nil
+}
+
+// ConfirmTOTP подтвердить настройку приложения первым кодом. Возвращает новые коды
+// восстановления – прежние перестают действовать.
+func (s *Service) ConfirmTOTP(employeeId int64, input string) ([]string, error) {
+	totp, err := s.findTOTP(employeeId)
+	if err != nil {
+		return nil, err
+	}
+	if totp == nil {
+		return nil, ErrNotEnrolled
+	}
+	if totp.ConfirmedAt != nil {
+		return nil, ErrAlreadyEnrolled
+	}
+
+	matched, ok := match(totp.Secret, normalizeCode(input), s.now(), s.skew)
+	if !ok {
+		return nil, ErrInvalidCode
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.repo.ConfirmTOTP(employeeId, matched, hashes, &Event{EmployeeId: employeeId, Kind: EventTOTPConfirmed})
+	if err != nil {
+		if errors.Is(err, database.ErrRecordNotFound) {
+			return nil, ErrAlreadyEnrolled
+		}
+		return nil, fmt.Errorf("error confirming totp of employee with id %d: %w", employeeId, err)
+	}
+
+	return codes, nil
+}
+
+// VerifyTOTP проверить код при входе. Каждый код принимается только один раз.
+func (s *Service) VerifyTOTP(employeeId int64, input string) error {
+	totp, err := s.findTOTP(employeeId)
+	if err != nil {
+		return err
+	}
+	if totp == nil || totp.ConfirmedAt == nil {
+		return ErrNotEnrolled
+	}
+
+	matched, ok := match(totp.Secret, normalizeCode(input), s.now(), s.skew)
+	if !ok || matched <= totp.LastUsedStep {
+		return ErrInvalidCode
+	}
+
+	if err := s.repo.UseTOTPStep(employeeId, matched); err != nil {
+		if errors.Is(err, database.ErrRecordNotFound) {
+			// код уже использован параллельным запросом
+			return ErrInvalidCode
+		}
+		return fmt.Errorf("error saving totp step of employee with id %d: %w", employeeId, err)
+	}
+
+	return nil
+}
+
+func (s *Service) RemoveTOTP(employeeId int64) error {
+	err := s.repo.RemoveTOTP(employeeId, &Event{EmployeeId: employeeId, Kind: EventTOTPRemoved})
+	if err != nil {
+		if errors.Is(err, database.ErrRecordNotFound) {
+			return ErrNotEnrolled
+		}
+		return fmt.Errorf("error removing totp of employee with id %d: %w", employeeId, err)
+	}
+
+	return nil
+}
+
+// GenerateRecoveryCodes выпустить новый набор кодов восстановления взамен прежнего
+func (s *Service) GenerateRecoveryCodes(employeeId int64) ([]string, error) {
+	methods, err := s.Methods(employeeId)
+	if err != nil {
+		return nil, err
+	}
+	if len(methods) == 0 {
+		return nil, fmt.Errorf("%w: enroll an authenticator before generating recovery codes", ErrNotEnrolled)
+	}
+
+	return s.replaceRecoveryCodes(employeeId)
+}
+
+// UseRecoveryCode погасить код восстановления вместо второго фактора
+func (s *Service) UseRecoveryCode(employeeId int64, input string) error {
+	err := s.repo.UseRecoveryCode(employeeId, hashRecoveryCode(input),
+		&Event{EmployeeId: employeeId, Kind: EventRecoveryUsed})
+	if err != nil {
+		if errors.Is(err, database.ErrRecordNotFound) {
+			return ErrInvalidCode
+		}
+		return fmt.Errorf("error using recovery code of employee with id %d: %w", employeeId, err)
+	}
+
+	return nil
+}
+
+// BeginWebAuthnRegistration параметры navigator.credentials.create() для нового ключа
+func (s *Service) BeginWebAuthnRegistration(employeeId int64) (*protocol.CredentialCreation, error) {
+	if s.webauthn == nil {
+		return nil, ErrWebAuthnDisabled
+	}
+
+	user, err := s.user(employeeId)
+	if err != nil {
+		return nil, err
+	}
+
+	creation, session, err := s.webauthn.BeginRegistration(user,
+		webauthn.WithExclusions(webauthn.Credentials(user.credentials).CredentialDescriptors()))
+	if err != nil {
+		return nil, fmt.Errorf("error starting webauthn registration for employee with id %d: %w", employeeId, err)
+	}
+
+	if err := s.saveCeremony(employeeId, ceremonyRegistration, session); err != nil {
+		return nil, err
+	}
+
+	return creation, nil
+}
+
+// FinishWebAuthnRegistration проверить ответ аутентификатора и сохранить ключ
+func (s *Service) FinishWebAuthnRegistration(employeeId int64, name string,
+	response *protocol.ParsedCredentialCreationData) (WebAuthnEnrollment, error) {
+	if s.webauthn == nil {
+		return WebAuthnEnrollment{}, ErrWebAuthnDisabled
+	}
+
+	session, err := s.takeCeremony(employeeId, ceremonyRegistration)
+	if err != nil {
+		return WebAuthnEnrollment{}, err
+	}
+
+	user, err := s.user(employeeId)
+	if err != nil {
+		return WebAuthnEnrollment{}, err
+	}
+
+	created, err := s.webauthn.CreateCredential(user, *session, response)
+	if err != nil {
+		return WebAuthnEnrollment{}, fmt.Errorf("%w: %v", ErrInvalidAssertion, err)
+	}
+
+	data, err := json.Marshal(created)
+	if err != nil {
+		return WebAuthnEnrollment{}, err
+	}
+	credential := &WebAuthnCredential{
+		Id:         credentialId(created.ID),
+		EmployeeId: employeeId,
+		Name:       strings.TrimSpace(name),
+		Data:       data,
+	}
+	event := &Event{EmployeeId: employeeId, Kind: EventWebAuthnRegistered, Detail: credential.Id}
+	if err := s.repo.CreateWebAuthnCredential(credential, event); err != nil {
+		return WebAuthnEnrollment{}, fmt.Errorf("error saving webauthn credential of employee with id %d: %w", employeeId, err)
+	}
+
+	enrollment := WebAuthnEnrollment{Credential: credential.ToResponse()}
+
+	count, err := s.repo.CountRecoveryCodes(employeeId)
+	if err != nil {
+		return WebAuthnEnrollment{}, fmt.Errorf("error counting recovery codes of employee with id %d: %w", employeeId, err)
+	}
+	if count == 0 {
+		enrollment.RecoveryCodes, err = s.replaceRecoveryCodes(employeeId)
+		if err != nil {
+			return WebAuthnEnrollment{}, err
+		}
+	}
+
+	return enrollment, nil
+}
+
+// BeginWebAuthnLogin параметры navigator.credentials.get() для подтверждения входа
+func (s *Service) BeginWebAuthnLogin(employeeId int64) (*protocol.CredentialAssertion, error) {
+	if s.webauthn == nil {
+		return nil, ErrWebAuthnDisabled
+	}
+
+	user, err := s.user(employeeId)
+	if err != nil {
+		return nil, err
+	}
+	if len(user.credentials) == 0 {
+		return nil, ErrNotEnrolled
+	}
+
+	assertion, session, err := s.webauthn.BeginLogin(user)
+	if err != nil {
+		return nil, fmt.Errorf("error starting webauthn login for employee with id %d: %w", employeeId, err)
+	}
+
+	if err := s.saveCeremony(employeeId, ceremonyLogin, session); err != nil {
+		return nil, err
+	}
+
+	return assertion, nil
+}
+
+// FinishWebAuthnLogin проверить подпись аутентификатора
+func (s *Service) FinishWebAuthnLogin(employeeId int64, response *protocol.ParsedCredentialAssertionData) error {
+	if s.webauthn == nil {
+		return ErrWebAuthnDisabled
+	}
+
+	session, err := s.takeCeremony(employeeId, ceremonyLogin)
+	if err != nil {
+		return err
+	}
+
+	user, err := s.user(employeeId)
+	if err != nil {
+		return err
+	}
+
+	validated, err := s.webauthn.ValidateLogin(user, *session, response)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidAssertion, err)
+	}
+	if validated.Authenticator.CloneWarning {
+		return fmt.Errorf("%w: signature counter went backwards, the authenticator may be cloned", ErrInvalidAssertion)
+	}
+
+	data, err := json.Marshal(validated)
+	if err != nil {
+		return err
+	}
+	now := s.now()
+	credential := &WebAuthnCredential{Id: credentialId(validated.ID), EmployeeId: employeeId, Data: data, LastUsedAt: &now}
+	if err := s.repo.UpdateWebAuthnCredential(credential); err != nil {
+		return fmt.Errorf("error updating webauthn credential of employee with id %d: %w", employeeId, err)
+	}
+
+	return nil
+}
+
+func (s *Service) RemoveWebAuthnCredential(employeeId int64, id string) error {
+	err := s.repo.RemoveWebAuthnCredential(employeeId, id,
+		&Event{EmployeeId: employeeId, Kind: EventWebAuthnRemoved, Detail: id})
+	if err != nil {
+		if errors.Is(err, database.ErrRecordNotFound) {
+			return ErrNotEnrolled
+		}
+		return fmt.Errorf("error removing webauthn credential %s of employee with id %d: %w", id, employeeId, err)
+	}
+
+	return nil
+}
+
+func (s *Service) findTOTP(employeeId int64) (*TOTP, error) {
+	totp, err := s.repo.FindTOTP(employeeId)
+	if err != nil {
+		if errors.Is(err, database.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error finding totp of employee with id %d: %w", employeeId, err)
+	}
+
+	return totp, nil
+}
+
+func (s *Service) replaceRecoveryCodes(employeeId int64) ([]string, error) {
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.repo.ReplaceRecoveryCodes(employeeId, hashes, &Event{EmployeeId: employeeId, Kind: EventRecoveryGenerated})
+	if err != nil {
+		return nil, fmt.Errorf("error saving recovery codes of employee with id %d: %w", employeeId, err)
+	}
+
+	return codes, nil
+}
+
+func (s *Service) account(employeeId int64) (string, error) {
+	found, err := s.employees.FindById(employeeId)
+	if err != nil {
+		return "", fmt.Errorf("error finding employee with id %d: %w", employeeId, err)
+	}
+
+	switch {
+	case found.UserName != "":
+		return found.UserName, nil
+	case found.Email != "":
+		return found.Email, nil
+	default:
+		return strconv.FormatInt(found.Id, 10), nil
+	}
+}
+
+func (s *Service) user(employeeId int64) (*user, error) {
+	found, err := s.employees.FindById(employeeId)
+	if err != nil {
+		return nil, fmt.Errorf("error finding employee with id %d: %w", employeeId, err)
+	}
+
+	stored, err := s.repo.FindWebAuthnCredentials(employeeId)
+	if err != nil {
+		return nil, fmt.Errorf("error finding webauthn credentials of employee with id %d: %w", employeeId, err)
+	}
+
+	u := &user{employee: found}
+	for _, credential := range stored {
+		var decoded webauthn.Credential
+		if err := json.Unmarshal(credential.Data, &decoded); err != nil {
+			return nil, fmt.Errorf("error decoding webauthn credential %s: %w", credential.Id, err)
+		}
+		u.credentials = append(u.credentials, decoded)
+	}
+
+	return u, nil
+}
+
+func (s *Service) saveCeremony(employeeId int64, kind string, session *webauthn.SessionData) error {
+	data, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+
+	err = s.repo.SaveCeremony(&Ceremony{EmployeeId: employeeId, Kind: kind, Data: data, ExpiresAt: s.now().Add(CeremonyTTL)})
+	if err != nil {
+		return fmt.Errorf("error saving webauthn %s ceremony of employee with id %d: %w", kind, employeeId, err)
+	}
+
+	return nil
+}
+
+func (s *Service) takeCeremony(employeeId int64, kind string) (*webauthn.SessionData, error) {
+	ceremony, err := s.repo.TakeCeremony(employeeId, kind)
+	if err != nil {
+		if errors.Is(err, database.ErrRecordNotFound) {
+			return nil, ErrCeremonyNotFound
+		}
+		return nil, fmt.Errorf("error finding webauthn %s ceremony of employee with id %d: %w", kind, employeeId, err)
+	}
+	if !s.now().Before(ceremony.ExpiresAt) {
+		return nil, ErrCeremonyNotFound
+	}
+
+	var session webauthn.SessionData
+	if err := json.Unmarshal(ceremony.Data, &session); err != nil {
+		return nil, fmt.Errorf("error decoding webauthn %s ceremony: %w", kind, err)
+	}
+
+	return &session, nil
+}
+
+// user сотрудник в представлении библиотеки WebAuthn
+type user struct {
+	employee    employee.Response
+	credentials []webauthn.Credential
+}
+
+func (u *user) WebAuthnID() []byte {
+	id := make([]byte, 8)
+	binary.BigEndian.PutUint64(id, uint64(u.employee.Id))
+	return id
+}
+
+func (u *user) WebAuthnName() string {
+	if u.employee.UserName != "" {
+		return u.employee.UserName
+	}
+	return strconv.FormatInt(u.employee.Id, 10)
+}
+
+func (u *user) WebAuthnDisplayName() string {
+	if u.employee.Name != "" {
+		return u.employee.Name
+	}
+	return u.WebAuthnName()
+}
+
+func (u *user) WebAuthnCredentials() []webauthn.Credential {
+	return u.credentials
+}
+
+func credentialId(id []byte) string {
+	return base64.RawURLEncoding.EncodeToString(id)
+}
+
+// newRecoveryCodes коды вида xxxxx-xxxxx; в базе хранится только их SHA-256
+func newRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, 0, RecoveryCodeCount)
+	hashes := make([]string, 0, RecoveryCodeCount)
+
+	encoding := base32.NewEncoding("abcdefghijkmnpqrstuvwxyz23456789").WithPadding(base32.NoPadding)
+	for range RecoveryCodeCount {
+		raw := make([]byte, 7)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, nil, fmt.Errorf("error generating recovery codes: %w", err)
+		}
+		value := encoding.EncodeToString(raw)[:10]
+		code := value[:5] + "-" + value[5:]
+		codes = append(codes, code)
+		hashes = append(hashes, hashRecoveryCode(code))
+	}
+
+	return codes, hashes, nil
+}
+
+func hashRecoveryCode(code string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(normalizeCode(code))))
+	return hex.EncodeToString(sum[:])
+}
+
+// normalizeCode убрать пробелы и дефисы, которые пользователи вводят вместе с кодом
+func normalizeCode(code string) string {
+	return strings.NewReplacer(" ", "", "-", "").Replace(strings.TrimSpace(code))
+}
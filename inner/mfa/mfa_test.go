@@ -0,0 +1,335 @@
+package mfa
+
+import (
+	"encoding/base32"
+	"errors"
+	"github.com/go-webauthn/webauthn/webauthn"
+	assertpackage "github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"idm/inner/database"
+	"idm/inner/employee"
+	"net/url"
+	"testing"
+	"time"
+)
+
+type MockRepo struct {
+	mock.Mock
+}
+
+func (m *MockRepo) FindTOTP(employeeId int64) (*TOTP, error) {
+	args := m.Called(employeeId)
+	return args.Get(0).(*TOTP), args.Error(1)
+}
+
+func (m *MockRepo) SaveTOTP(totp *TOTP, event *Event) error {
+	args := m.Called(totp, event)
+	return args.Error(0)
+}
+
+func (m *MockRepo) ConfirmTOTP(employeeId int64, step int64, recoveryHashes []string, event *Event) error {
+	args := m.Called(employeeId, step, recoveryHashes, event)
+	return args.Error(0)
+}
+
+func (m *MockRepo) UseTOTPStep(employeeId int64, step int64) error {
+	args := m.Called(employeeId, step)
+	return args.Error(0)
+}
+
+func (m *MockRepo) RemoveTOTP(employeeId int64, event *Event) error {
+	args := m.Called(employeeId, event)
+	return args.Error(0)
+}
+
+func (m *MockRepo) ReplaceRecoveryCodes(employeeId int64, hashes []string, event *Event) error {
+	args := m.Called(employeeId, hashes, event)
+	return args.Error(0)
+}
+
+func (m *MockRepo) UseRecoveryCode(employeeId int64, hash string, event *Event) error {
+	args := m.Called(employeeId, hash, event)
+	return args.Error(0)
+}
+
+func (m *MockRepo) CountRecoveryCodes(employeeId int64) (int, error) {
+	args := m.Called(employeeId)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockRepo) FindWebAuthnCredentials(employeeId int64) ([]*WebAuthnCredential, error) {
+	args := m.Called(employeeId)
+	return args.Get(0).([]*WebAuthnCredential), args.Error(1)
+}
+
+func (m *MockRepo) CreateWebAuthnCredential(credential *WebAuthnCredential, event *Event) error {
+	args := m.Called(credential, event)
+	return args.Error(0)
+}
+
+func (m *MockRepo) UpdateWebAuthnCredential(credential *WebAuthnCredential) error {
+	args := m.Called(credential)
+	return args.Error(0)
+}
+
+func (m *MockRepo) RemoveWebAuthnCredential(employeeId int64, id string, event *Event) error {
+	args := m.Called(employeeId, id, event)
+	return args.Error(0)
+}
+
+func (m *MockRepo) SaveCeremony(ceremony *Ceremony) error {
+	args := m.Called(ceremony)
+	return args.Error(0)
+}
+
+func (m *MockRepo) TakeCeremony(employeeId int64, kind string) (*Ceremony, error) {
+	args := m.Called(employeeId, kind)
+	return args.Get(0).(*Ceremony), args.Error(1)
+}
+
+func (m *MockRepo) IsRequired(employeeId int64) (bool, error) {
+	args := m.Called(employeeId)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRepo) FindRequiredRoleIds() ([]int64, error) {
+	args := m.Called()
+	return args.Get(0).([]int64), args.Error(1)
+}
+
+func (m *MockRepo) SetRequiredRoleIds(roleIds []int64) error {
+	args := m.Called(roleIds)
+	return args.Error(0)
+}
+
+func (m *MockRepo) FindEvents(employeeId int64) ([]*Event, error) {
+	args := m.Called(employeeId)
+	return args.Get(0).([]*Event), args.Error(1)
+}
+
+type StubEmployees struct{}
+
+func (StubEmployees) FindById(id int64) (employee.Response, error) {
+	return employee.Response{Id: id, Name: "John Doe", UserName: "jdoe"}, nil
+}
+
+func event(kind string) any {
+	return mock.MatchedBy(func(e *Event) bool { return e.Kind == kind })
+}
+
+// rfcSecret секрет из тестовых векторов RFC 6238
+var rfcSecret = base32NoPadding.EncodeToString([]byte("12345678901234567890"))
+
+func TestTOTP(t *testing.T) {
+	assert := assertpackage.New(t)
+
+	t.Run("should match RFC 6238 test vectors", func(t *testing.T) {
+		for unix, expected := range map[int64]string{59: "287082", 1111111109: "081804", 2000000000: "279037"} {
+			got, err := code(rfcSecret, step(time.Unix(unix, 0)))
+			assert.Nil(err)
+			assert.Equal(expected, got)
+		}
+	})
+
+	t.Run("should accept codes within the skew window only", func(t *testing.T) {
+		at := time.Unix(1111111109, 0)
+		previous, _ := code(rfcSecret, step(at)-1)
+		old, _ := code(rfcSecret, step(at)-2)
+
+		matched, ok := match(rfcSecret, previous, at, 1)
+		assert.True(ok)
+		assert.Equal(step(at)-1, matched)
+
+		_, ok = match(rfcSecret, old, at, 1)
+		assert.False(ok)
+	})
+
+	t.Run("should build a provisioning uri", func(t *testing.T) {
+		uri, err := url.Parse(ProvisioningURI("IDM", "jdoe", rfcSecret))
+		assert.Nil(err)
+		assert.Equal("otpauth", uri.Scheme)
+		assert.Equal("totp", uri.Host)
+		assert.Equal("/IDM:jdoe", uri.Path)
+		assert.Equal(rfcSecret, uri.Query().Get("secret"))
+		assert.Equal("IDM", uri.Query().Get("issuer"))
+		assert.Equal("6", uri.Query().Get("digits"))
+	})
+}
+
+func TestMFAService(t *testing.T) {
+	assert := assertpackage.New(t)
+	now := time.Unix(1111111109, 0)
+
+	newService := func(repo Repo) *Service {
+		service := NewService(repo, StubEmployees{}, "IDM")
+		service.now = func() time.Time { return now }
+		return service
+	}
+
+	t.Run("should start totp enrollment and record it", func(t *testing.T) {
+		repo := new(MockRepo)
+		service := newService(repo)
+		repo.On("FindTOTP", int64(1)).Return((*TOTP)(nil), database.ErrRecordNotFound)
+		repo.On("SaveTOTP", mock.AnythingOfType("*mfa.TOTP"), event(EventTOTPStarted)).Return(nil)
+
+		got, err := service.BeginTOTP(1)
+
+		assert.Nil(err)
+		_, decodeErr := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(got.Secret)
+		assert.Nil(decodeErr)
+		assert.Contains(got.ProvisioningURI, "otpauth://totp/IDM:jdoe?")
+		repo.AssertExpectations(t)
+	})
+
+	t.Run("should refuse to replace a confirmed authenticator", func(t *testing.T) {
+		repo := new(MockRepo)
+		service := newService(repo)
+		repo.On("FindTOTP", int64(1)).Return(&TOTP{EmployeeId: 1, Secret: rfcSecret, ConfirmedAt: &now}, nil)
+
+		_, err := service.BeginTOTP(1)
+
+		assert.True(errors.Is(err, ErrAlreadyEnrolled))
+		repo.AssertNotCalled(t, "SaveTOTP", mock.Anything, mock.Anything)
+	})
+
+	t.Run("should confirm totp and issue recovery codes", func(t *testing.T) {
+		repo := new(MockRepo)
+		service := newService(repo)
+		repo.On("FindTOTP", int64(1)).Return(&TOTP{EmployeeId: 1, Secret: rfcSecret}, nil)
+		repo.On("ConfirmTOTP", int64(1), step(now), mock.AnythingOfType("[]string"), event(EventTOTPConfirmed)).Return(nil)
+
+		codes, err := service.ConfirmTOTP(1, "081 804")
+
+		assert.Nil(err)
+		assert.Len(codes, RecoveryCodeCount)
+		hashes := repo.Calls[1].Arguments.Get(2).([]string)
+		assert.Equal(hashRecoveryCode(codes[0]), hashes[0])
+		assert.NotEqual(codes[0], hashes[0])
+	})
+
+	t.Run("should reject a wrong confirmation code", func(t *testing.T) {
+		repo := new(MockRepo)
+		service := newService(repo)
+		repo.On("FindTOTP", int64(1)).Return(&TOTP{EmployeeId: 1, Secret: rfcSecret}, nil)
+
+		_, err := service.ConfirmTOTP(1, "000000")
+
+		assert.Equal(ErrInvalidCode, err)
+		repo.AssertNotCalled(t, "ConfirmTOTP", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("should verify a code once", func(t *testing.T) {
+		repo := new(MockRepo)
+		service := newService(repo)
+		repo.On("FindTOTP", int64(1)).
+			Return(&TOTP{EmployeeId: 1, Secret: rfcSecret, ConfirmedAt: &now}, nil).Once()
+		repo.On("FindTOTP", int64(1)).
+			Return(&TOTP{EmployeeId: 1, Secret: rfcSecret, ConfirmedAt: &now, LastUsedStep: step(now)}, nil)
+		repo.On("UseTOTPStep", int64(1), step(now)).Return(nil)
+
+		assert.Nil(service.VerifyTOTP(1, "081804"))
+		assert.Equal(ErrInvalidCode, service.VerifyTOTP(1, "081804"))
+		repo.AssertNumberOfCalls(t, "UseTOTPStep", 1)
+	})
+
+	t.Run("should not verify an unconfirmed authenticator", func(t *testing.T) {
+		repo := new(MockRepo)
+		service := newService(repo)
+		repo.On("FindTOTP", int64(1)).Return(&TOTP{EmployeeId: 1, Secret: rfcSecret}, nil)
+
+		assert.Equal(ErrNotEnrolled, service.VerifyTOTP(1, "081804"))
+	})
+
+	t.Run("should use a recovery code regardless of formatting", func(t *testing.T) {
+		repo := new(MockRepo)
+		service := newService(repo)
+		repo.On("UseRecoveryCode", int64(1), hashRecoveryCode("abcde-fghij"), event(EventRecoveryUsed)).Return(nil)
+		repo.On("UseRecoveryCode", int64(1), mock.Anything, mock.Anything).Return(database.ErrRecordNotFound)
+
+		assert.Nil(service.UseRecoveryCode(1, " ABCDE FGHIJ "))
+		assert.Equal(ErrInvalidCode, service.UseRecoveryCode(1, "zzzzz-zzzzz"))
+	})
+
+	t.Run("should list enrolled methods", func(t *testing.T) {
+		repo := new(MockRepo)
+		service := newService(repo)
+		service.UseWebAuthn(&webauthn.WebAuthn{})
+		repo.On("IsRequired", int64(1)).Return(true, nil)
+		repo.On("FindTOTP", int64(1)).Return(&TOTP{EmployeeId: 1, Secret: rfcSecret, ConfirmedAt: &now}, nil)
+		repo.On("FindWebAuthnCredentials", int64(1)).Return([]*WebAuthnCredential{{Id: "key", EmployeeId: 1}}, nil)
+		repo.On("CountRecoveryCodes", int64(1)).Return(3, nil)
+
+		methods, err := service.Methods(1)
+
+		assert.Nil(err)
+		assert.Equal([]string{MethodTOTP, MethodWebAuthn, MethodRecovery}, methods)
+	})
+
+	t.Run("should not generate recovery codes without an authenticator", func(t *testing.T) {
+		repo := new(MockRepo)
+		service := newService(repo)
+		repo.On("IsRequired", int64(1)).Return(false, nil)
+		repo.On("FindTOTP", int64(1)).Return((*TOTP)(nil), database.ErrRecordNotFound)
+		repo.On("FindWebAuthnCredentials", int64(1)).Return([]*WebAuthnCredential{}, nil)
+		repo.On("CountRecoveryCodes", int64(1)).Return(0, nil)
+
+		_, err := service.GenerateRecoveryCodes(1)
+
+		assert.True(errors.Is(err, ErrNotEnrolled))
+	})
+
+	t.Run("should start a webauthn registration and keep the ceremony", func(t *testing.T) {
+		repo := new(MockRepo)
+		service := newService(repo)
+		web, err := webauthn.New(&webauthn.Config{
+			RPID:          "idm.example.com",
+			RPDisplayName: "IDM",
+			RPOrigins:     []string{"https://idm.example.com"},
+		})
+		assert.Nil(err)
+		service.UseWebAuthn(web)
+		repo.On("FindWebAuthnCredentials", int64(1)).Return([]*WebAuthnCredential{}, nil)
+		repo.On("SaveCeremony", mock.MatchedBy(func(c *Ceremony) bool {
+			return c.EmployeeId == 1 && c.Kind == ceremonyRegistration && c.ExpiresAt.Equal(now.Add(CeremonyTTL))
+		})).Return(nil)
+
+		creation, err := service.BeginWebAuthnRegistration(1)
+
+		assert.Nil(err)
+		assert.Equal("idm.example.com", creation.Response.RelyingParty.ID)
+		assert.Equal("jdoe", creation.Response.User.Name)
+		repo.AssertExpectations(t)
+	})
+
+	t.Run("should reject an expired webauthn ceremony", func(t *testing.T) {
+		repo := new(MockRepo)
+		service := newService(repo)
+		service.UseWebAuthn(&webauthn.WebAuthn{})
+		repo.On("TakeCeremony", int64(1), ceremonyLogin).
+			Return(&Ceremony{EmployeeId: 1, Kind: ceremonyLogin, Data: []byte("{}"), ExpiresAt: now}, nil)
+
+		err := service.FinishWebAuthnLogin(1, nil)
+
+		assert.Equal(ErrCeremonyNotFound, err)
+	})
+
+	t.Run("should fail webauthn calls when it is not configured", func(t *testing.T) {
+		service := newService(new(MockRepo))
+
+		_, err := service.BeginWebAuthnLogin(1)
+
+		assert.Equal(ErrWebAuthnDisabled, err)
+	})
+
+	t.Run("should record removal of a webauthn credential", func(t *testing.T) {
+		repo := new(MockRepo)
+		service := newService(repo)
+		repo.On("RemoveWebAuthnCredential", int64(1), "key", mock.MatchedBy(func(e *Event) bool {
+			return e.Kind == EventWebAuthnRemoved && e.Detail == "key"
+		})).Return(nil)
+		repo.On("RemoveWebAuthnCredential", int64(1), "missing", mock.Anything).Return(database.ErrRecordNotFound)
+
+		assert.Nil(service.RemoveWebAuthnCredential(1, "key"))
+		assert.Equal(ErrNotEnrolled, service.RemoveWebAuthnCredential(1, "missing"))
+	})
+}
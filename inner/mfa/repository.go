@@ -0,0 +1,364 @@
+package mfa
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/jmoiron/sqlx"
+	"github.com/jmoiron/sqlx/types"
+	"github.com/lib/pq"
+	"idm/inner/database"
+	"time"
+)
+
+// TOTP секрет одноразовых паролей сотрудника. До подтверждения первым кодом не используется при входе.
+type TOTP struct {
+	EmployeeId   int64      `db:"employee_id"`
+	Secret       string     `db:"secret"`
+	LastUsedStep int64      `db:"last_used_step"`
+	ConfirmedAt  *time.Time `db:"confirmed_at"`
+	CreatedAt    time.Time  `db:"created_at"`
+}
+
+// WebAuthnCredential ключ безопасности или passkey. Data – сериализованный webauthn.Credential.
+type WebAuthnCredential struct {
+	Id         string         `db:"id"`
+	EmployeeId int64          `db:"employee_id"`
+	Name       string         `db:"name"`
+	Data       types.JSONText `db:"data"`
+	LastUsedAt *time.Time     `db:"last_used_at"`
+	CreatedAt  time.Time      `db:"created_at"`
+}
+
+// Ceremony состояние незавершённой регистрации или проверки WebAuthn
+type Ceremony struct {
+	EmployeeId int64          `db:"employee_id"`
+	Kind       string         `db:"kind"`
+	Data       types.JSONText `db:"data"`
+	ExpiresAt  time.Time      `db:"expires_at"`
+}
+
+// Event запись аудита об изменении способов MFA сотрудника
+type Event struct {
+	Id         int64     `db:"id"`
+	EmployeeId int64     `db:"employee_id"`
+	Kind       string    `db:"kind"`
+	Detail     string    `db:"detail"`
+	CreatedAt  time.Time `db:"created_at"`
+}
+
+type Repository struct {
+	db *sqlx.DB
+}
+
+func NewRepository(db *sqlx.DB) *Repository {
+	return &Repository{db: db}
+}
+
+func (r *Repository) FindTOTP(employeeId int64) (*TOTP, error) {
+	var totp TOTP
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := r.db.GetContext(ctx, &totp, "SELECT * FROM mfa_totp WHERE employee_id = $1", employeeId)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, database.ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &totp, err
+}
+
+// SaveTOTP сохранить новый неподтверждённый секрет вместо прежнего
+func (r *Repository) SaveTOTP(totp *TOTP, event *Event) error {
+	return r.inTx(event, func(ctx context.Context, tx *sqlx.Tx) error {
+		return tx.QueryRowContext(ctx,
+			`INSERT INTO mfa_totp (employee_id, secret) VALUES ($1, $2)
+			ON CONFLICT (employee_id) DO UPDATE
+			SET secret = EXCLUDED.secret, last_used_step = 0, confirmed_at = NULL, created_at = CURRENT_TIMESTAMP
+			RETURNING created_at`,
+			totp.EmployeeId, totp.Secret,
+		).Scan(&totp.CreatedAt)
+	})
+}
+
+// ConfirmTOTP подтвердить секрет и сохранить новые коды восстановления
+func (r *Repository) ConfirmTOTP(employeeId int64, step int64, recoveryHashes []string, event *Event) error {
+	return r.inTx(event, func(ctx context.Context, tx *sqlx.Tx) error {
+		result, err := tx.ExecContext(ctx,
+			`UPDATE mfa_totp SET confirmed_at = CURRENT_TIMESTAMP, last_used_step = $2
+			WHERE employee_id = $1 AND confirmed_at IS NULL`,
+			employeeId, step)
+		if err != nil {
+			return err
+		}
+		if err := affected(result); err != nil {
+			return err
+		}
+
+		return replaceRecoveryCodes(ctx, tx, employeeId, recoveryHashes)
+	})
+}
+
+// UseTOTPStep отметить шаг времени использованным. Повторное использование того же
+// или более раннего шага возвращает database.ErrRecordNotFound.
+func (r *Repository) UseTOTPStep(employeeId int64, step int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := r.db.ExecContext(ctx,
+		`UPDATE mfa_totp SET last_used_step = $2
+		WHERE employee_id = $1 AND confirmed_at IS NOT NULL AND last_used_step < $2`,
+		employeeId, step)
+	if err != nil {
+		return err
+	}
+
+	return affected(result)
+}
+
+func (r *Repository) RemoveTOTP(employeeId int64, event *Event) error {
+	return r.inTx(event, func(ctx context.Context, tx *sqlx.Tx) error {
+		result, err := tx.ExecContext(ctx, "DELETE FROM mfa_totp WHERE employee_id = $1", employeeId)
+		if err != nil {
+			return err
+		}
+
+		return affected(result)
+	})
+}
+
+func (r *Repository) ReplaceRecoveryCodes(employeeId int64, hashes []string, event *Event) error {
+	return r.inTx(event, func(ctx context.Context, tx *sqlx.Tx) error {
+		return replaceRecoveryCodes(ctx, tx, employeeId, hashes)
+	})
+}
+
+// UseRecoveryCode погасить неиспользованный код восстановления
+func (r *Repository) UseRecoveryCode(employeeId int64, hash string, event *Event) error {
+	return r.inTx(event, func(ctx context.Context, tx *sqlx.Tx) error {
+		result, err := tx.ExecContext(ctx,
+			`UPDATE mfa_recovery_codes SET used_at = CURRENT_TIMESTAMP
+			WHERE id = (
+				SELECT id FROM mfa_recovery_codes
+				WHERE employee_id = $1 AND hash = $2 AND used_at IS NULL
+				LIMIT 1 FOR UPDATE
+			)`,
+			employeeId, hash)
+		if err != nil {
+			return err
+		}
+
+		return affected(result)
+	})
+}
+
+func (r *Repository) CountRecoveryCodes(employeeId int64) (int, error) {
+	var count int
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := r.db.GetContext(ctx, &count,
+		"SELECT COUNT(*) FROM mfa_recovery_codes WHERE employee_id = $1 AND used_at IS NULL", employeeId)
+
+	return count, err
+}
+
+func (r *Repository) FindWebAuthnCredentials(employeeId int64) ([]*WebAuthnCredential, error) {
+	var credentials []*WebAuthnCredential
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := r.db.SelectContext(ctx, &credentials,
+		"SELECT * FROM mfa_webauthn_credentials WHERE employee_id = $1 ORDER BY created_at", employeeId)
+
+	return credentials, err
+}
+
+func (r *Repository) CreateWebAuthnCredential(credential *WebAuthnCredential, event *Event) error {
+	return r.inTx(event, func(ctx context.Context, tx *sqlx.Tx) error {
+		return tx.QueryRowContext(ctx,
+			`INSERT INTO mfa_webauthn_credentials (id, employee_id, name, data) VALUES ($1, $2, $3, $4)
+			RETURNING created_at`,
+			credential.Id, credential.EmployeeId, credential.Name, credential.Data,
+		).Scan(&credential.CreatedAt)
+	})
+}
+
+// UpdateWebAuthnCredential сохранить счётчик подписей и время использования после проверки
+func (r *Repository) UpdateWebAuthnCredential(credential *WebAuthnCredential) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := r.db.ExecContext(ctx,
+		"UPDATE mfa_webauthn_credentials SET data = $1, last_used_at = $2 WHERE id = $3",
+		credential.Data, credential.LastUsedAt, credential.Id)
+
+	return err
+}
+
+func (r *Repository) RemoveWebAuthnCredential(employeeId int64, id string, event *Event) error {
+	return r.inTx(event, func(ctx context.Context, tx *sqlx.Tx) error {
+		result, err := tx.ExecContext(ctx,
+			"DELETE FROM mfa_webauthn_credentials WHERE employee_id = $1 AND id = $2", employeeId, id)
+		if err != nil {
+			return err
+		}
+
+		return affected(result)
+	})
+}
+
+func (r *Repository) SaveCeremony(ceremony *Ceremony) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO mfa_webauthn_sessions (employee_id, kind, data, expires_at) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (employee_id, kind) DO UPDATE SET data = EXCLUDED.data, expires_at = EXCLUDED.expires_at`,
+		ceremony.EmployeeId, ceremony.Kind, ceremony.Data, ceremony.ExpiresAt)
+
+	return err
+}
+
+// TakeCeremony забрать состояние церемонии; повторно его получить нельзя
+func (r *Repository) TakeCeremony(employeeId int64, kind string) (*Ceremony, error) {
+	var ceremony Ceremony
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := r.db.GetContext(ctx, &ceremony,
+		"DELETE FROM mfa_webauthn_sessions WHERE employee_id = $1 AND kind = $2 RETURNING *", employeeId, kind)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, database.ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &ceremony, err
+}
+
+// IsRequired есть ли у сотрудника роль, для которой политика требует MFA
+func (r *Repository) IsRequired(employeeId int64) (bool, error) {
+	var required bool
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := r.db.GetContext(ctx, &required,
+		`SELECT EXISTS (
+			SELECT 1 FROM employee_roles er JOIN mfa_role_policy p ON p.role_id = er.role_id
+			WHERE er.employee_id = $1
+		)`,
+		employeeId)
+
+	return required, err
+}
+
+func (r *Repository) FindRequiredRoleIds() ([]int64, error) {
+	var ids []int64
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := r.db.SelectContext(ctx, &ids, "SELECT role_id FROM mfa_role_policy ORDER BY role_id")
+
+	return ids, err
+}
+
+// SetRequiredRoleIds заменить список ролей, для которых требуется MFA
+func (r *Repository) SetRequiredRoleIds(roleIds []int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err = tx.ExecContext(ctx, "DELETE FROM mfa_role_policy WHERE NOT (role_id = ANY($1))",
+		pq.Array(roleIds)); err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx,
+		"INSERT INTO mfa_role_policy (role_id) SELECT unnest($1::BIGINT[]) ON CONFLICT (role_id) DO NOTHING",
+		pq.Array(roleIds))
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *Repository) FindEvents(employeeId int64) ([]*Event, error) {
+	var events []*Event
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := r.db.SelectContext(ctx, &events,
+		"SELECT * FROM mfa_events WHERE employee_id = $1 ORDER BY created_at, id", employeeId)
+
+	return events, err
+}
+
+// inTx выполнить изменение и записать событие аудита в одной транзакции
+func (r *Repository) inTx(event *Event, change func(ctx context.Context, tx *sqlx.Tx) error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err = change(ctx, tx); err != nil {
+		return err
+	}
+
+	err = tx.QueryRowContext(ctx,
+		"INSERT INTO mfa_events (employee_id, kind, detail) VALUES ($1, $2, $3) RETURNING id, created_at",
+		event.EmployeeId, event.Kind, event.Detail,
+	).Scan(&event.Id, &event.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func replaceRecoveryCodes(ctx context.Context, tx *sqlx.Tx, employeeId int64, hashes []string) error {
+	if _, err := tx.ExecContext(ctx, "DELETE FROM mfa_recovery_codes WHERE employee_id = $1", employeeId); err != nil {
+		return err
+	}
+	_, err := tx.ExecContext(ctx,
+		"INSERT INTO mfa_recovery_codes (employee_id, hash) SELECT $1, unnest($2::TEXT[])",
+		employeeId, pq.Array(hashes))
+
+	return err
+}
+
+func affected(result sql.Result) error {
+	count, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return database.ErrRecordNotFound
+	}
+
+	return nil
+}
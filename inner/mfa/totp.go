@@ -0,0 +1,88 @@
+package mfa
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// Параметры TOTP по RFC 6238 – те, что поддерживают все распространённые приложения-аутентификаторы
+const (
+	totpDigits    = 6
+	totpPeriod    = 30
+	totpSecretLen = 20
+)
+
+var base32NoPadding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+func newSecret() (string, error) {
+	secret := make([]byte, totpSecretLen)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+
+	return base32NoPadding.EncodeToString(secret), nil
+}
+
+// ProvisioningURI адрес otpauth://, который приложение-аутентификатор считывает с QR-кода
+func ProvisioningURI(issuer string, account string, secret string) string {
+	query := url.Values{}
+	query.Set("secret", secret)
+	query.Set("issuer", issuer)
+	query.Set("algorithm", "SHA1")
+	query.Set("digits", fmt.Sprint(totpDigits))
+	query.Set("period", fmt.Sprint(totpPeriod))
+
+	label := url.PathEscape(issuer) + ":" + url.PathEscape(account)
+
+	return "otpauth://totp/" + label + "?" + query.Encode()
+}
+
+func step(at time.Time) int64 {
+	return at.Unix() / totpPeriod
+}
+
+// code одноразовый пароль для шага времени (RFC 4226, динамическое усечение)
+func code(secret string, step int64) (string, error) {
+	key, err := base32NoPadding.DecodeString(secret)
+	if err != nil {
+		return "", err
+	}
+
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(step))
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	return fmt.Sprintf("%0*d", totpDigits, value%1000000), nil
+}
+
+// match найти шаг в окне ±skew, которому соответствует код
+func match(secret string, input string, at time.Time, skew int) (int64, bool) {
+	if len(input) != totpDigits {
+		return 0, false
+	}
+
+	current := step(at)
+	for delta := -skew; delta <= skew; delta++ {
+		expected, err := code(secret, current+int64(delta))
+		if err != nil {
+			return 0, false
+		}
+		if hmac.Equal([]byte(expected), []byte(input)) {
+			return current + int64(delta), true
+		}
+	}
+
+	return 0, false
+}
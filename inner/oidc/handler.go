@@ -0,0 +1,237 @@
+package oidc
+
+import (
+	"errors"
+	"html"
+	"idm/inner/httpapi"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Handler HTTP-эндпоинты провайдера. Пути обрабатываются относительно issuer,
+// поэтому при монтировании нужно использовать http.StripPrefix. authenticator определяет
+// сотрудника, выполнившего вход в браузере, а если вход не выполнен, возвращает ErrLoginRequired.
+type Handler struct {
+	service       *Service
+	authenticator httpapi.Authenticator[Authentication]
+	mux           *http.ServeMux
+}
+
+func NewHandler(service *Service, authenticator httpapi.Authenticator[Authentication]) *Handler {
+	h := &Handler{service: service, authenticator: authenticator, mux: http.NewServeMux()}
+
+	h.mux.HandleFunc("GET /.well-known/openid-configuration", h.discovery)
+	h.mux.HandleFunc("GET /jwks", h.jwks)
+	h.mux.HandleFunc("GET /authorize", h.authorize)
+	h.mux.HandleFunc("POST /authorize", h.authorize)
+	h.mux.HandleFunc("POST /token", h.token)
+	h.mux.HandleFunc("POST /revoke", h.revoke)
+	h.mux.HandleFunc("POST /introspect", h.introspect)
+	h.mux.HandleFunc("GET /userinfo", h.userinfo)
+	h.mux.HandleFunc("POST /userinfo", h.userinfo)
+
+	return h
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+func (h *Handler) discovery(w http.ResponseWriter, r *http.Request) {
+	httpapi.WriteJSON(w, http.StatusOK, h.service.Discovery())
+}
+
+func (h *Handler) jwks(w http.ResponseWriter, r *http.Request) {
+	set, err := h.service.JWKS()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	httpapi.WriteJSON(w, http.StatusOK, set)
+}
+
+func (h *Handler) authorize(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	request := AuthorizeRequest{
+		ResponseType:        r.Form.Get("response_type"),
+		ClientId:            r.Form.Get("client_id"),
+		RedirectURI:         r.Form.Get("redirect_uri"),
+		Scope:               r.Form.Get("scope"),
+		State:               r.Form.Get("state"),
+		Nonce:               r.Form.Get("nonce"),
+		CodeChallenge:       r.Form.Get("code_challenge"),
+		CodeChallengeMethod: r.Form.Get("code_challenge_method"),
+	}
+
+	// пока redirect_uri не проверен, об ошибке нельзя сообщать перенаправлением
+	if _, err := h.service.CheckClient(request.ClientId, request.RedirectURI); err != nil {
+		http.Error(w, html.EscapeString(err.Error()), http.StatusBadRequest)
+		return
+	}
+
+	authentication, err := h.authenticator.Authenticate(r)
+	if err != nil {
+		redirect(w, r, request, url.Values{"error": {errorCode(err)}, "error_description": {err.Error()}})
+		return
+	}
+	if authentication.AuthTime.IsZero() {
+		authentication.AuthTime = time.Now()
+	}
+
+	code, err := h.service.Authorize(request, authentication)
+	if err != nil {
+		redirect(w, r, request, url.Values{"error": {errorCode(err)}, "error_description": {err.Error()}})
+		return
+	}
+
+	redirect(w, r, request, url.Values{"code": {code}})
+}
+
+func (h *Handler) token(w http.ResponseWriter, r *http.Request) {
+	clientId, clientSecret, ok := clientCredentials(r)
+	if !ok {
+		writeError(w, ErrInvalidRequest)
+		return
+	}
+
+	var response TokenResponse
+	var err error
+	switch r.PostForm.Get("grant_type") {
+	case "authorization_code":
+		response, err = h.service.Exchange(clientId, clientSecret, r.PostForm.Get("code"),
+			r.PostForm.Get("redirect_uri"), r.PostForm.Get("code_verifier"))
+	case "refresh_token":
+		response, err = h.service.Refresh(clientId, clientSecret, r.PostForm.Get("refresh_token"),
+			r.PostForm.Get("scope"))
+	default:
+		err = ErrUnsupportedGrantType
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	httpapi.WriteJSON(w, http.StatusOK, response)
+}
+
+func (h *Handler) revoke(w http.ResponseWriter, r *http.Request) {
+	clientId, clientSecret, ok := clientCredentials(r)
+	if !ok {
+		writeError(w, ErrInvalidRequest)
+		return
+	}
+
+	if err := h.service.Revoke(clientId, clientSecret, r.PostForm.Get("token")); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *Handler) introspect(w http.ResponseWriter, r *http.Request) {
+	clientId, clientSecret, ok := clientCredentials(r)
+	if !ok {
+		writeError(w, ErrInvalidRequest)
+		return
+	}
+
+	response, err := h.service.Introspect(clientId, clientSecret, r.PostForm.Get("token"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	httpapi.WriteJSON(w, http.StatusOK, response)
+}
+
+func (h *Handler) userinfo(w http.ResponseWriter, r *http.Request) {
+	token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !found {
+		w.Header().Set("WWW-Authenticate", `Bearer`)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	claims, err := h.service.UserInfo(token)
+	if err != nil {
+		if errors.Is(err, ErrInvalidToken) {
+			w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		writeError(w, err)
+		return
+	}
+
+	httpapi.WriteJSON(w, http.StatusOK, claims)
+}
+
+// clientCredentials данные аутентификации приложения: client_secret_basic, client_secret_post
+// или только client_id для публичных приложений
+func clientCredentials(r *http.Request) (string, string, bool) {
+	if err := r.ParseForm(); err != nil {
+		return "", "", false
+	}
+
+	if clientId, clientSecret, ok := r.BasicAuth(); ok {
+		clientId, _ = url.QueryUnescape(clientId)
+		clientSecret, _ = url.QueryUnescape(clientSecret)
+		return clientId, clientSecret, true
+	}
+
+	clientId := r.PostForm.Get("client_id")
+
+	return clientId, r.PostForm.Get("client_secret"), clientId != ""
+}
+
+func redirect(w http.ResponseWriter, r *http.Request, request AuthorizeRequest, values url.Values) {
+	target, _ := url.Parse(request.RedirectURI)
+	query := target.Query()
+	for name, value := range values {
+		query[name] = value
+	}
+	if request.State != "" {
+		query.Set("state", request.State)
+	}
+	target.RawQuery = query.Encode()
+
+	http.Redirect(w, r, target.String(), http.StatusFound)
+}
+
+func errorCode(err error) string {
+	for _, known := range []error{
+		ErrInvalidRequest, ErrInvalidClient, ErrInvalidGrant, ErrInvalidScope, ErrInvalidToken,
+		ErrUnsupportedGrantType, ErrUnsupportedResponse, ErrAccessDenied, ErrLoginRequired,
+	} {
+		if errors.Is(err, known) {
+			return known.Error()
+		}
+	}
+
+	return "server_error"
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	code := errorCode(err)
+	status := http.StatusBadRequest
+	description := err.Error()
+	switch code {
+	case ErrInvalidClient.Error():
+		status = http.StatusUnauthorized
+		w.Header().Set("WWW-Authenticate", `Basic realm="idm"`)
+	case "server_error":
+		status = http.StatusInternalServerError
+		description = "internal error"
+	}
+
+	httpapi.WriteJSON(w, status, map[string]string{"error": code, "error_description": description})
+}
@@ -0,0 +1,831 @@
+package oidc
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"idm/inner/database"
+	"idm/inner/employee"
+	"idm/inner/role"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	ScopeOpenId        = "openid"
+	ScopeProfile       = "profile"
+	ScopeEmail         = "email"
+	ScopeRoles         = "roles"
+	ScopeOfflineAccess = "offline_access"
+
+	DefaultAccessTokenTTL  = 15 * time.Minute
+	DefaultRefreshTokenTTL = 30 * 24 * time.Hour
+	// DefaultKeyLifetime через сколько активный ключ подписи заменяется новым
+	DefaultKeyLifetime = 30 * 24 * time.Hour
+	// KeyGracePeriod сколько заменённый ключ остаётся в JWKS, чтобы выданные им токены проходили проверку
+	KeyGracePeriod = 24 * time.Hour
+	// KeyCacheTTL как долго ключи подписи берутся из памяти: за это время экземпляр замечает
+	// ротацию, выполненную другим экземпляром
+	KeyCacheTTL = time.Minute
+	CodeTTL     = time.Minute
+
+	// keyReloadInterval как часто можно перечитывать ключи из-за неизвестного kid: его может прислать кто угодно
+	keyReloadInterval = time.Second
+)
+
+var (
+	ErrInvalidRequest       = errors.New("invalid_request")
+	ErrInvalidClient        = errors.New("invalid_client")
+	ErrInvalidGrant         = errors.New("invalid_grant")
+	ErrInvalidScope         = errors.New("invalid_scope")
+	ErrInvalidToken         = errors.New("invalid_token")
+	ErrUnsupportedGrantType = errors.New("unsupported_grant_type")
+	ErrUnsupportedResponse  = errors.New("unsupported_response_type")
+	ErrAccessDenied         = errors.New("access_denied")
+	ErrLoginRequired        = errors.New("login_required")
+)
+
+var supportedScopes = []string{ScopeOpenId, ScopeProfile, ScopeEmail, ScopeRoles, ScopeOfflineAccess}
+
+type Repo interface {
+	FindClient(id string) (*Client, error)
+	CreateClient(client *Client) error
+	FindKeys() ([]*Key, error)
+	CreateKey(key *Key, since time.Time) error
+	RemoveKey(id string) error
+	CreateCode(code *Code) error
+	ConsumeCode(hash string, now time.Time) (*Code, error)
+	FindRefreshToken(hash string) (*RefreshToken, error)
+	CreateRefreshToken(token *RefreshToken) error
+	RotateRefreshToken(hash string, token *RefreshToken) error
+	RevokeRefreshToken(hash string, now time.Time) error
+	RevokeRefreshTokenFamily(family string, now time.Time) error
+	RevokeAccessToken(jti string, expiresAt time.Time) error
+	IsAccessTokenRevoked(jti string) (bool, error)
+}
+
+// Employees источник данных о сотрудниках для утверждений токенов
+type Employees interface {
+	FindById(id int64) (employee.Response, error)
+}
+
+// Roles источник ролей сотрудника для утверждения roles
+type Roles interface {
+	FindByEmployeeId(employeeId int64) ([]role.Response, error)
+}
+
+type Service struct {
+	repo            Repo
+	employees       Employees
+	roles           Roles
+	issuer          string
+	accessTokenTTL  time.Duration
+	refreshTokenTTL time.Duration
+	keyLifetime     time.Duration
+	now             func() time.Time
+
+	keysMu sync.Mutex
+	cached *keyring
+}
+
+// keyring разобранные ключи подписи: новейший подписывает токены, все проверяют подпись
+type keyring struct {
+	kid       string
+	signing   *rsa.PrivateKey
+	public    map[string]*rsa.PublicKey
+	set       JWKSet
+	createdAt time.Time
+	loadedAt  time.Time
+}
+
+// NewService создать провайдера. issuer – внешний адрес, относительно которого публикуются эндпоинты.
+func NewService(repository Repo, employees Employees, roles Roles, issuer string) *Service {
+	return &Service{
+		repo:            repository,
+		employees:       employees,
+		roles:           roles,
+		issuer:          strings.TrimSuffix(issuer, "/"),
+		accessTokenTTL:  DefaultAccessTokenTTL,
+		refreshTokenTTL: DefaultRefreshTokenTTL,
+		keyLifetime:     DefaultKeyLifetime,
+		now:             time.Now,
+	}
+}
+
+// SetTokenTTL изменить время жизни токенов доступа и обновления
+func (s *Service) SetTokenTTL(accessTokenTTL time.Duration, refreshTokenTTL time.Duration) {
+	s.accessTokenTTL = accessTokenTTL
+	s.refreshTokenTTL = refreshTokenTTL
+}
+
+// SetKeyLifetime изменить период автоматической ротации ключей подписи
+func (s *Service) SetKeyLifetime(lifetime time.Duration) {
+	s.keyLifetime = lifetime
+}
+
+func (s *Service) Discovery() Discovery {
+	return Discovery{
+		Issuer:                            s.issuer,
+		AuthorizationEndpoint:             s.issuer + "/authorize",
+		TokenEndpoint:                     s.issuer + "/token",
+		UserinfoEndpoint:                  s.issuer + "/userinfo",
+		JwksURI:                           s.issuer + "/jwks",
+		RevocationEndpoint:                s.issuer + "/revoke",
+		IntrospectionEndpoint:             s.issuer + "/introspect",
+		ResponseTypesSupported:            []string{"code"},
+		GrantTypesSupported:               []string{"authorization_code", "refresh_token"},
+		SubjectTypesSupported:             []string{"public"},
+		IdTokenSigningAlgValuesSupported:  []string{"RS256"},
+		ScopesSupported:                   supportedScopes,
+		ClaimsSupported:                   []string{"sub", "name", "preferred_username", "email", "department", "title", "roles"},
+		CodeChallengeMethodsSupported:     []string{"S256"},
+		TokenEndpointAuthMethodsSupported: []string{"client_secret_basic", "client_secret_post", "none"},
+	}
+}
+
+// RegisterClient зарегистрировать приложение. Конфиденциальному приложению выдаётся секрет,
+// публичное (SPA, мобильное) аутентифицируется только через PKCE.
+func (s *Service) RegisterClient(name string, redirectURIs []string, confidential bool) (ClientResponse, error) {
+	if len(redirectURIs) == 0 {
+		return ClientResponse{}, fmt.Errorf("%w: at least one redirect_uri is required", ErrInvalidRequest)
+	}
+	for _, redirectURI := range redirectURIs {
+		parsed, err := url.Parse(redirectURI)
+		if err != nil || !parsed.IsAbs() || parsed.Fragment != "" {
+			return ClientResponse{}, fmt.Errorf("%w: invalid redirect_uri %q", ErrInvalidRequest, redirectURI)
+		}
+	}
+
+	client := &Client{Id: randomToken(16), Name: name, RedirectURIs: redirectURIs}
+	var secret string
+	if confidential {
+		secret = randomToken(32)
+		client.SecretHash = hash(secret)
+	}
+
+	if err := s.repo.CreateClient(client); err != nil {
+		return ClientResponse{}, fmt.Errorf("error creating client %q: %w", name, err)
+	}
+
+	response := *client.ToResponse()
+	response.Secret = secret
+
+	return response, nil
+}
+
+// RotateKeys создать новый активный ключ подписи и удалить ключи, льготный период которых истёк
+func (s *Service) RotateKeys() error {
+	s.keysMu.Lock()
+	defer s.keysMu.Unlock()
+
+	keys, err := s.rotate(s.now())
+	if err != nil {
+		return err
+	}
+	s.cached, err = newKeyring(keys, s.now())
+
+	return err
+}
+
+// JWKS открытые ключи, которыми можно проверить выданные токены
+func (s *Service) JWKS() (JWKSet, error) {
+	ring, err := s.keyring(false)
+	if err != nil {
+		return JWKSet{}, err
+	}
+
+	return ring.set, nil
+}
+
+// CheckClient проверить приложение и redirect_uri. Пока они не проверены, ошибку нельзя
+// возвращать перенаправлением.
+func (s *Service) CheckClient(clientId string, redirectURI string) (*Client, error) {
+	client, err := s.repo.FindClient(clientId)
+	if err != nil {
+		if errors.Is(err, database.ErrRecordNotFound) {
+			return nil, fmt.Errorf("%w: unknown client %q", ErrInvalidClient, clientId)
+		}
+		return nil, fmt.Errorf("error finding client %q: %w", clientId, err)
+	}
+
+	if !slices.Contains(client.RedirectURIs, redirectURI) {
+		return nil, fmt.Errorf("%w: redirect_uri is not registered for client %q", ErrInvalidRequest, clientId)
+	}
+
+	return client, nil
+}
+
+// Authorize выдать код авторизации сотруднику, выполнившему вход
+func (s *Service) Authorize(request AuthorizeRequest, authentication Authentication) (string, error) {
+	if _, err := s.CheckClient(request.ClientId, request.RedirectURI); err != nil {
+		return "", err
+	}
+	if request.ResponseType != "code" {
+		return "", fmt.Errorf("%w: only response_type=code is supported", ErrUnsupportedResponse)
+	}
+
+	scopes := strings.Fields(request.Scope)
+	if !slices.Contains(scopes, ScopeOpenId) {
+		return "", fmt.Errorf("%w: scope must include openid", ErrInvalidScope)
+	}
+	for _, scope := range scopes {
+		if !slices.Contains(supportedScopes, scope) {
+			return "", fmt.Errorf("%w: unsupported scope %q", ErrInvalidScope, scope)
+		}
+	}
+
+	if request.CodeChallenge == "" {
+		return "", fmt.Errorf("%w: code_challenge is required", ErrInvalidRequest)
+	}
+	if request.CodeChallengeMethod != "S256" {
+		return "", fmt.Errorf("%w: code_challenge_method must be S256", ErrInvalidRequest)
+	}
+
+	if err := s.checkEmployee(authentication.EmployeeId); err != nil {
+		return "", err
+	}
+
+	code := randomToken(32)
+	err := s.repo.CreateCode(&Code{
+		Hash:                hash(code),
+		ClientId:            request.ClientId,
+		EmployeeId:          authentication.EmployeeId,
+		RedirectURI:         request.RedirectURI,
+		Scope:               strings.Join(scopes, " "),
+		Nonce:               request.Nonce,
+		CodeChallenge:       request.CodeChallenge,
+		CodeChallengeMethod: request.CodeChallengeMethod,
+		AuthTime:            authentication.AuthTime,
+		ExpiresAt:           s.now().Add(CodeTTL),
+	})
+	if err != nil {
+		return "", fmt.Errorf("error creating authorization code: %w", err)
+	}
+
+	return code, nil
+}
+
+// Exchange обменять код авторизации на токены (grant_type=authorization_code)
+func (s *Service) Exchange(clientId string, clientSecret string, code string, redirectURI string,
+	codeVerifier string) (TokenResponse, error) {
+	client, err := s.authenticateClient(clientId, clientSecret)
+	if err != nil {
+		return TokenResponse{}, err
+	}
+
+	grant, err := s.repo.ConsumeCode(hash(code), s.now())
+	if err != nil {
+		if errors.Is(err, database.ErrRecordNotFound) {
+			return TokenResponse{}, fmt.Errorf("%w: code is invalid or already used", ErrInvalidGrant)
+		}
+		return TokenResponse{}, fmt.Errorf("error consuming authorization code: %w", err)
+	}
+
+	switch {
+	case grant.ClientId != client.Id:
+		return TokenResponse{}, fmt.Errorf("%w: code was issued to another client", ErrInvalidGrant)
+	case grant.ExpiresAt.Before(s.now()):
+		return TokenResponse{}, fmt.Errorf("%w: code has expired", ErrInvalidGrant)
+	case grant.RedirectURI != redirectURI:
+		return TokenResponse{}, fmt.Errorf("%w: redirect_uri does not match", ErrInvalidGrant)
+	case !checkCodeVerifier(grant.CodeChallenge, codeVerifier):
+		return TokenResponse{}, fmt.Errorf("%w: code_verifier does not match code_challenge", ErrInvalidGrant)
+	}
+
+	return s.issue(client, grant.EmployeeId, grant.Scope, grant.Nonce, grant.AuthTime, nil)
+}
+
+// Refresh выдать новые токены по токену обновления (grant_type=refresh_token).
+// Использованный токен обновления отзывается и заменяется новым. Повторное предъявление
+// отозванного токена отзывает все токены, выданные по тому же входу.
+func (s *Service) Refresh(clientId string, clientSecret string, refreshToken string, scope string) (TokenResponse, error) {
+	client, err := s.authenticateClient(clientId, clientSecret)
+	if err != nil {
+		return TokenResponse{}, err
+	}
+
+	token, err := s.repo.FindRefreshToken(hash(refreshToken))
+	if err != nil {
+		if errors.Is(err, database.ErrRecordNotFound) {
+			return TokenResponse{}, fmt.Errorf("%w: unknown refresh token", ErrInvalidGrant)
+		}
+		return TokenResponse{}, fmt.Errorf("error finding refresh token: %w", err)
+	}
+
+	switch {
+	case token.ClientId != client.Id:
+		return TokenResponse{}, fmt.Errorf("%w: refresh token was issued to another client", ErrInvalidGrant)
+	case token.RevokedAt != nil:
+		return TokenResponse{}, s.revokeFamily(token)
+	case token.ExpiresAt.Before(s.now()):
+		return TokenResponse{}, fmt.Errorf("%w: refresh token has expired", ErrInvalidGrant)
+	}
+
+	granted := token.Scope
+	if scope != "" {
+		for _, requested := range strings.Fields(scope) {
+			if !slices.Contains(strings.Fields(token.Scope), requested) {
+				return TokenResponse{}, fmt.Errorf("%w: scope %q was not granted", ErrInvalidScope, requested)
+			}
+		}
+		granted = scope
+	}
+
+	return s.issue(client, token.EmployeeId, granted, "", token.AuthTime, token)
+}
+
+// Revoke отозвать токен доступа или обновления (RFC 7009). Неизвестные токены игнорируются.
+func (s *Service) Revoke(clientId string, clientSecret string, token string) error {
+	client, err := s.authenticateClient(clientId, clientSecret)
+	if err != nil {
+		return err
+	}
+
+	refreshToken, err := s.repo.FindRefreshToken(hash(token))
+	if err == nil {
+		if refreshToken.ClientId != client.Id {
+			return nil
+		}
+		if err := s.repo.RevokeRefreshToken(refreshToken.Hash, s.now()); err != nil {
+			return fmt.Errorf("error revoking refresh token: %w", err)
+		}
+		return nil
+	}
+	if !errors.Is(err, database.ErrRecordNotFound) {
+		return fmt.Errorf("error finding refresh token: %w", err)
+	}
+
+	claims, err := s.parseAccessToken(token)
+	if err != nil || claims.ClientId != client.Id {
+		return nil
+	}
+	if err := s.repo.RevokeAccessToken(claims.Id, time.Unix(claims.ExpiresAt, 0)); err != nil {
+		return fmt.Errorf("error revoking access token: %w", err)
+	}
+
+	return nil
+}
+
+// Introspect сообщить, активен ли токен, и его атрибуты (RFC 7662)
+func (s *Service) Introspect(clientId string, clientSecret string, token string) (IntrospectionResponse, error) {
+	if _, err := s.authenticateClient(clientId, clientSecret); err != nil {
+		return IntrospectionResponse{}, err
+	}
+
+	refreshToken, err := s.repo.FindRefreshToken(hash(token))
+	if err == nil {
+		if refreshToken.RevokedAt != nil || refreshToken.ExpiresAt.Before(s.now()) {
+			return IntrospectionResponse{}, nil
+		}
+		return IntrospectionResponse{
+			Active:    true,
+			Scope:     refreshToken.Scope,
+			ClientId:  refreshToken.ClientId,
+			TokenType: "refresh_token",
+			ExpiresAt: refreshToken.ExpiresAt.Unix(),
+			IssuedAt:  refreshToken.CreatedAt.Unix(),
+			Subject:   strconv.FormatInt(refreshToken.EmployeeId, 10),
+			Issuer:    s.issuer,
+		}, nil
+	}
+	if !errors.Is(err, database.ErrRecordNotFound) {
+		return IntrospectionResponse{}, fmt.Errorf("error finding refresh token: %w", err)
+	}
+
+	claims, err := s.validateAccessToken(token)
+	if err != nil {
+		return IntrospectionResponse{}, nil
+	}
+
+	return IntrospectionResponse{
+		Active:    true,
+		Scope:     claims.Scope,
+		ClientId:  claims.ClientId,
+		Username:  claims.PreferredUsername,
+		TokenType: "Bearer",
+		ExpiresAt: claims.ExpiresAt,
+		IssuedAt:  claims.IssuedAt,
+		Subject:   claims.Subject,
+		Audience:  claims.Audience,
+		Issuer:    claims.Issuer,
+		Id:        claims.Id,
+		Roles:     claims.Roles,
+	}, nil
+}
+
+// UserInfo утверждения о сотруднике по токену доступа
+func (s *Service) UserInfo(accessToken string) (Claims, error) {
+	claims, err := s.validateAccessToken(accessToken)
+	if err != nil {
+		return Claims{}, err
+	}
+
+	employeeId, err := strconv.ParseInt(claims.Subject, 10, 64)
+	if err != nil {
+		return Claims{}, fmt.Errorf("%w: malformed subject", ErrInvalidToken)
+	}
+
+	info, err := s.profile(employeeId, claims.Scope)
+	if err != nil {
+		return Claims{}, err
+	}
+
+	return Claims{
+		Subject:           info.Subject,
+		Name:              info.Name,
+		PreferredUsername: info.PreferredUsername,
+		Email:             info.Email,
+		Department:        info.Department,
+		Title:             info.Title,
+		Roles:             info.Roles,
+	}, nil
+}
+
+// ValidateAccessToken проверить подпись, срок действия и отзыв токена доступа.
+// Используется ресурсными серверами внутри процесса.
+func (s *Service) ValidateAccessToken(accessToken string) (Claims, error) {
+	return s.validateAccessToken(accessToken)
+}
+
+func (s *Service) validateAccessToken(accessToken string) (Claims, error) {
+	claims, err := s.parseAccessToken(accessToken)
+	if err != nil {
+		return Claims{}, err
+	}
+
+	revoked, err := s.repo.IsAccessTokenRevoked(claims.Id)
+	if err != nil {
+		return Claims{}, fmt.Errorf("error checking access token revocation: %w", err)
+	}
+	if revoked {
+		return Claims{}, fmt.Errorf("%w: token has been revoked", ErrInvalidToken)
+	}
+
+	return claims, nil
+}
+
+func (s *Service) parseAccessToken(accessToken string) (Claims, error) {
+	ring, err := s.keyring(false)
+	if err != nil {
+		return Claims{}, err
+	}
+
+	var claims Claims
+	err = verify(accessToken, ring.public, &claims)
+	if errors.Is(err, errUnknownKey) {
+		// ключ мог создать другой экземпляр уже после того, как ключи были загружены
+		if ring, err = s.keyring(true); err != nil {
+			return Claims{}, err
+		}
+		err = verify(accessToken, ring.public, &claims)
+	}
+	if err != nil {
+		return Claims{}, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	switch {
+	case claims.Issuer != s.issuer:
+		return Claims{}, fmt.Errorf("%w: unexpected issuer", ErrInvalidToken)
+	case claims.Id == "" || claims.ClientId == "":
+		return Claims{}, fmt.Errorf("%w: not an access token", ErrInvalidToken)
+	case time.Unix(claims.ExpiresAt, 0).Before(s.now()):
+		return Claims{}, fmt.Errorf("%w: token has expired", ErrInvalidToken)
+	}
+
+	return claims, nil
+}
+
+// issue выдать токен доступа, ID-токен и, при scope offline_access, токен обновления.
+// replaces – токен обновления, который заменяется новым; новый токен наследует его семейство.
+func (s *Service) issue(client *Client, employeeId int64, scope string, nonce string, authTime time.Time,
+	replaces *RefreshToken) (TokenResponse, error) {
+	if err := s.checkEmployee(employeeId); err != nil {
+		return TokenResponse{}, err
+	}
+
+	kid, key, err := s.signingKey()
+	if err != nil {
+		return TokenResponse{}, err
+	}
+
+	info, err := s.profile(employeeId, scope+" "+ScopeRoles)
+	if err != nil {
+		return TokenResponse{}, err
+	}
+
+	now := s.now()
+	access := Claims{
+		Issuer:            s.issuer,
+		Subject:           info.Subject,
+		Audience:          client.Id,
+		ExpiresAt:         now.Add(s.accessTokenTTL).Unix(),
+		IssuedAt:          now.Unix(),
+		Id:                randomToken(16),
+		ClientId:          client.Id,
+		Scope:             scope,
+		PreferredUsername: info.PreferredUsername,
+		Roles:             info.Roles,
+	}
+	accessToken, err := sign(kid, key, "at+jwt", access)
+	if err != nil {
+		return TokenResponse{}, fmt.Errorf("error signing access token: %w", err)
+	}
+
+	response := TokenResponse{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(s.accessTokenTTL.Seconds()),
+		Scope:       scope,
+	}
+
+	if slices.Contains(strings.Fields(scope), ScopeOpenId) {
+		identity, err := s.profile(employeeId, scope)
+		if err != nil {
+			return TokenResponse{}, err
+		}
+		identity.Issuer = s.issuer
+		identity.Audience = client.Id
+		identity.ExpiresAt = access.ExpiresAt
+		identity.IssuedAt = access.IssuedAt
+		identity.AuthTime = authTime.Unix()
+		identity.Nonce = nonce
+		identity.AccessTokenHash = leftHalfHash(accessToken)
+
+		response.IdToken, err = sign(kid, key, "JWT", identity)
+		if err != nil {
+			return TokenResponse{}, fmt.Errorf("error signing id token: %w", err)
+		}
+	}
+
+	if slices.Contains(strings.Fields(scope), ScopeOfflineAccess) {
+		refreshToken := randomToken(32)
+		token := &RefreshToken{
+			Hash:       hash(refreshToken),
+			ClientId:   client.Id,
+			EmployeeId: employeeId,
+			Scope:      scope,
+			AuthTime:   authTime,
+			ExpiresAt:  now.Add(s.refreshTokenTTL),
+			CreatedAt:  now,
+		}
+		token.Family = token.Hash
+		if replaces != nil {
+			token.Family = replaces.Family
+			err = s.repo.RotateRefreshToken(replaces.Hash, token)
+		} else {
+			err = s.repo.CreateRefreshToken(token)
+		}
+		// токен отозвали между проверкой и заменой: его предъявили дважды одновременно
+		if errors.Is(err, database.ErrRecordNotFound) {
+			return TokenResponse{}, s.revokeFamily(replaces)
+		}
+		if err != nil {
+			return TokenResponse{}, fmt.Errorf("error saving refresh token: %w", err)
+		}
+		response.RefreshToken = refreshToken
+	} else if replaces != nil {
+		if err := s.repo.RevokeRefreshToken(replaces.Hash, now); err != nil {
+			return TokenResponse{}, fmt.Errorf("error revoking refresh token: %w", err)
+		}
+	}
+
+	return response, nil
+}
+
+// revokeFamily отозвать все токены обновления, выданные по тому же входу, что и token.
+// Отозванный токен предъявляют повторно, только если его украли, и неизвестно, у кого из двоих
+// настоящий сотрудник, поэтому перестают действовать оба.
+func (s *Service) revokeFamily(token *RefreshToken) error {
+	if err := s.repo.RevokeRefreshTokenFamily(token.Family, s.now()); err != nil {
+		return fmt.Errorf("error revoking refresh token family: %w", err)
+	}
+
+	return fmt.Errorf("%w: refresh token has been revoked", ErrInvalidGrant)
+}
+
+// profile утверждения о сотруднике, разрешённые запрошенными scope
+func (s *Service) profile(employeeId int64, scope string) (Claims, error) {
+	found, err := s.employees.FindById(employeeId)
+	if err != nil {
+		return Claims{}, fmt.Errorf("error finding employee with id %d: %w", employeeId, err)
+	}
+
+	scopes := strings.Fields(scope)
+	claims := Claims{Subject: strconv.FormatInt(found.Id, 10)}
+	if slices.Contains(scopes, ScopeProfile) {
+		claims.Name = found.Name
+		claims.PreferredUsername = found.UserName
+		claims.Department = found.Department
+		claims.Title = found.Title
+	}
+	if slices.Contains(scopes, ScopeEmail) {
+		claims.Email = found.Email
+	}
+	if slices.Contains(scopes, ScopeRoles) {
+		roles, err := s.roles.FindByEmployeeId(employeeId)
+		if err != nil {
+			return Claims{}, fmt.Errorf("error finding roles of employee with id %d: %w", employeeId, err)
+		}
+		for _, found := range roles {
+			claims.Roles = append(claims.Roles, found.Name)
+		}
+	}
+
+	return claims, nil
+}
+
+// checkEmployee токены выдаются только активным сотрудникам
+func (s *Service) checkEmployee(employeeId int64) error {
+	found, err := s.employees.FindById(employeeId)
+	if err != nil {
+		if errors.Is(err, database.ErrRecordNotFound) {
+			return fmt.Errorf("%w: employee %d does not exist", ErrAccessDenied, employeeId)
+		}
+		return err
+	}
+	if found.Status != employee.StatusActive {
+		return fmt.Errorf("%w: employee %d is not active", ErrAccessDenied, employeeId)
+	}
+
+	return nil
+}
+
+func (s *Service) authenticateClient(clientId string, clientSecret string) (*Client, error) {
+	client, err := s.repo.FindClient(clientId)
+	if err != nil {
+		if errors.Is(err, database.ErrRecordNotFound) {
+			return nil, fmt.Errorf("%w: unknown client %q", ErrInvalidClient, clientId)
+		}
+		return nil, fmt.Errorf("error finding client %q: %w", clientId, err)
+	}
+
+	if client.SecretHash == "" {
+		if clientSecret != "" {
+			return nil, fmt.Errorf("%w: public client must not send a secret", ErrInvalidClient)
+		}
+		return client, nil
+	}
+	if subtle.ConstantTimeCompare([]byte(client.SecretHash), []byte(hash(clientSecret))) != 1 {
+		return nil, fmt.Errorf("%w: client authentication failed", ErrInvalidClient)
+	}
+
+	return client, nil
+}
+
+// keyring ключи подписи из памяти. Ключи перечитываются раз в KeyCacheTTL, когда активный ключ
+// устарел, и при reload – но не чаще keyReloadInterval. Загрузка и ротация выполняются под мьютексом,
+// поэтому одновременные запросы не читают и не создают ключи каждый сам.
+func (s *Service) keyring(reload bool) (*keyring, error) {
+	s.keysMu.Lock()
+	defer s.keysMu.Unlock()
+
+	now := s.now()
+	if ring := s.cached; ring != nil && !ring.createdAt.Add(s.keyLifetime).Before(now) {
+		if now.Before(ring.loadedAt.Add(keyReloadInterval)) || !reload && now.Before(ring.loadedAt.Add(KeyCacheTTL)) {
+			return ring, nil
+		}
+	}
+
+	keys, err := s.repo.FindKeys()
+	if err != nil {
+		return nil, fmt.Errorf("error finding signing keys: %w", err)
+	}
+	if len(keys) == 0 || keys[0].CreatedAt.Add(s.keyLifetime).Before(now) {
+		if keys, err = s.rotate(now.Add(-s.keyLifetime)); err != nil {
+			return nil, err
+		}
+	}
+
+	ring, err := newKeyring(keys, now)
+	if err != nil {
+		return nil, err
+	}
+	s.cached = ring
+
+	return ring, nil
+}
+
+// rotate создать новый активный ключ, если нет ключа новее since, и удалить ключи, льготный период
+// которых истёк. Экземпляры, одновременно заметившие устаревший ключ, создают один новый:
+// репозиторий проверяет since и сохраняет ключ под блокировкой.
+func (s *Service) rotate(since time.Time) ([]*Key, error) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, fmt.Errorf("error generating signing key: %w", err)
+	}
+	encoded, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding signing key: %w", err)
+	}
+
+	key := &Key{Id: randomToken(8), PrivateKey: encoded, CreatedAt: s.now()}
+	if err := s.repo.CreateKey(key, since); err != nil {
+		return nil, fmt.Errorf("error saving signing key: %w", err)
+	}
+
+	keys, err := s.repo.FindKeys()
+	if err != nil {
+		return nil, fmt.Errorf("error finding signing keys: %w", err)
+	}
+
+	result := keys[:1]
+	replacedAt := keys[0].CreatedAt
+	for _, old := range keys[1:] {
+		if replacedAt.Add(KeyGracePeriod).Before(s.now()) {
+			if err := s.repo.RemoveKey(old.Id); err != nil {
+				return nil, fmt.Errorf("error removing signing key %s: %w", old.Id, err)
+			}
+		} else {
+			result = append(result, old)
+		}
+		replacedAt = old.CreatedAt
+	}
+
+	return result, nil
+}
+
+func (s *Service) signingKey() (string, *rsa.PrivateKey, error) {
+	ring, err := s.keyring(false)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return ring.kid, ring.signing, nil
+}
+
+// newKeyring разобрать ключи, отсортированные от новейшего
+func newKeyring(keys []*Key, loadedAt time.Time) (*keyring, error) {
+	ring := &keyring{
+		kid:       keys[0].Id,
+		public:    make(map[string]*rsa.PublicKey, len(keys)),
+		set:       JWKSet{Keys: []JWK{}},
+		createdAt: keys[0].CreatedAt,
+		loadedAt:  loadedAt,
+	}
+	for _, key := range keys {
+		privateKey, err := parseKey(key)
+		if err != nil {
+			return nil, err
+		}
+		if ring.signing == nil {
+			ring.signing = privateKey
+		}
+		ring.public[key.Id] = &privateKey.PublicKey
+		ring.set.Keys = append(ring.set.Keys, newJWK(key.Id, &privateKey.PublicKey))
+	}
+
+	return ring, nil
+}
+
+func parseKey(key *Key) (*rsa.PrivateKey, error) {
+	parsed, err := x509.ParsePKCS8PrivateKey(key.PrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding signing key %s: %w", key.Id, err)
+	}
+	privateKey, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("signing key %s is not an RSA key", key.Id)
+	}
+
+	return privateKey, nil
+}
+
+func checkCodeVerifier(challenge string, verifier string) bool {
+	if len(verifier) < 43 || len(verifier) > 128 {
+		return false
+	}
+	sum := sha256.Sum256([]byte(verifier))
+
+	return subtle.ConstantTimeCompare([]byte(challenge), []byte(base64.RawURLEncoding.EncodeToString(sum[:]))) == 1
+}
+
+// leftHalfHash значение at_hash: левая половина SHA-256 токена доступа
+func leftHalfHash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+
+	return base64.RawURLEncoding.EncodeToString(sum[:len(sum)/2])
+}
+
+func randomToken(size int) string {
+	data := make([]byte, size)
+	_, _ = rand.Read(data)
+
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// hash токены и секреты случайны и длинны, поэтому для хранения достаточно SHA-256
+func hash(value string) string {
+	sum := sha256.Sum256([]byte(value))
+
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,656 @@
+package oidc
+
+import (
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	assertpackage "github.com/stretchr/testify/assert"
+	"idm/inner/database"
+	"idm/inner/employee"
+	"idm/inner/httpapi"
+	"idm/inner/role"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// StubRepo хранит данные провайдера в памяти. Ключи защищены мьютексом: их читают несколько сервисов сразу.
+type StubRepo struct {
+	clients       map[string]*Client
+	mu            sync.Mutex
+	keys          []*Key
+	keyReads      int
+	codes         map[string]*Code
+	refreshTokens map[string]*RefreshToken
+	revoked       map[string]time.Time
+}
+
+func NewStubRepo() *StubRepo {
+	return &StubRepo{
+		clients:       make(map[string]*Client),
+		codes:         make(map[string]*Code),
+		refreshTokens: make(map[string]*RefreshToken),
+		revoked:       make(map[string]time.Time),
+	}
+}
+
+func (s *StubRepo) FindClient(id string) (*Client, error) {
+	if client, ok := s.clients[id]; ok {
+		return client, nil
+	}
+	return nil, database.ErrRecordNotFound
+}
+
+func (s *StubRepo) CreateClient(client *Client) error {
+	client.CreatedAt = time.Now()
+	s.clients[client.Id] = client
+	return nil
+}
+
+func (s *StubRepo) FindKeys() ([]*Key, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keyReads++
+	return append([]*Key(nil), s.keys...), nil
+}
+
+func (s *StubRepo) CreateKey(key *Key, since time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.keys) > 0 && s.keys[0].CreatedAt.After(since) {
+		return nil
+	}
+	s.keys = append([]*Key{key}, s.keys...)
+	return nil
+}
+
+func (s *StubRepo) RemoveKey(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var kept []*Key
+	for _, key := range s.keys {
+		if key.Id != id {
+			kept = append(kept, key)
+		}
+	}
+	s.keys = kept
+	return nil
+}
+
+func (s *StubRepo) CreateCode(code *Code) error {
+	s.codes[code.Hash] = code
+	return nil
+}
+
+func (s *StubRepo) ConsumeCode(hash string, now time.Time) (*Code, error) {
+	code, ok := s.codes[hash]
+	if !ok || code.UsedAt != nil {
+		return nil, database.ErrRecordNotFound
+	}
+	code.UsedAt = &now
+	return code, nil
+}
+
+func (s *StubRepo) FindRefreshToken(hash string) (*RefreshToken, error) {
+	if token, ok := s.refreshTokens[hash]; ok {
+		return token, nil
+	}
+	return nil, database.ErrRecordNotFound
+}
+
+func (s *StubRepo) CreateRefreshToken(token *RefreshToken) error {
+	s.refreshTokens[token.Hash] = token
+	return nil
+}
+
+func (s *StubRepo) RotateRefreshToken(hash string, token *RefreshToken) error {
+	old, ok := s.refreshTokens[hash]
+	if !ok || old.RevokedAt != nil {
+		return database.ErrRecordNotFound
+	}
+	old.RevokedAt = &token.CreatedAt
+	s.refreshTokens[token.Hash] = token
+	return nil
+}
+
+func (s *StubRepo) RevokeRefreshToken(hash string, now time.Time) error {
+	if token, ok := s.refreshTokens[hash]; ok && token.RevokedAt == nil {
+		token.RevokedAt = &now
+	}
+	return nil
+}
+
+func (s *StubRepo) RevokeRefreshTokenFamily(family string, now time.Time) error {
+	for _, token := range s.refreshTokens {
+		if token.Family == family && token.RevokedAt == nil {
+			token.RevokedAt = &now
+		}
+	}
+	return nil
+}
+
+func (s *StubRepo) RevokeAccessToken(jti string, expiresAt time.Time) error {
+	s.revoked[jti] = expiresAt
+	return nil
+}
+
+func (s *StubRepo) IsAccessTokenRevoked(jti string) (bool, error) {
+	_, ok := s.revoked[jti]
+	return ok, nil
+}
+
+type StubEmployees map[int64]employee.Response
+
+func (s StubEmployees) FindById(id int64) (employee.Response, error) {
+	if found, ok := s[id]; ok {
+		return found, nil
+	}
+	return employee.Response{}, database.ErrRecordNotFound
+}
+
+type StubRoles map[int64][]role.Response
+
+func (s StubRoles) FindByEmployeeId(employeeId int64) ([]role.Response, error) {
+	return s[employeeId], nil
+}
+
+// relyingParty приложение-заглушка, которое проходит authorization code flow с PKCE
+type relyingParty struct {
+	server       *httptest.Server
+	issuer       string
+	clientId     string
+	clientSecret string
+	verifier     string
+	state        string
+	nonce        string
+}
+
+func newRelyingParty(t *testing.T, issuer string) *relyingParty {
+	rp := &relyingParty{
+		issuer:   issuer,
+		verifier: strings.Repeat("v", 43),
+		state:    "state-123",
+		nonce:    "nonce-456",
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /login", func(w http.ResponseWriter, r *http.Request) {
+		challenge := sha256.Sum256([]byte(rp.verifier))
+		query := url.Values{
+			"response_type":         {"code"},
+			"client_id":             {rp.clientId},
+			"redirect_uri":          {rp.server.URL + "/callback"},
+			"scope":                 {r.URL.Query().Get("scope")},
+			"state":                 {rp.state},
+			"nonce":                 {rp.nonce},
+			"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+			"code_challenge_method": {"S256"},
+		}
+		http.Redirect(w, r, rp.discovery(t).AuthorizationEndpoint+"?"+query.Encode(), http.StatusFound)
+	})
+	mux.HandleFunc("GET /callback", func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if query.Get("state") != rp.state {
+			http.Error(w, "state mismatch", http.StatusBadRequest)
+			return
+		}
+		if query.Has("error") {
+			w.WriteHeader(http.StatusForbidden)
+			_ = json.NewEncoder(w).Encode(map[string]string{"error": query.Get("error")})
+			return
+		}
+
+		status, body := rp.post(t, rp.discovery(t).TokenEndpoint, url.Values{
+			"grant_type":    {"authorization_code"},
+			"code":          {query.Get("code")},
+			"redirect_uri":  {rp.server.URL + "/callback"},
+			"code_verifier": {rp.verifier},
+		})
+		w.WriteHeader(status)
+		_ = json.NewEncoder(w).Encode(body)
+	})
+	rp.server = httptest.NewServer(mux)
+	t.Cleanup(rp.server.Close)
+
+	return rp
+}
+
+func (rp *relyingParty) discovery(t *testing.T) Discovery {
+	response, err := http.Get(rp.issuer + "/.well-known/openid-configuration")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+
+	var discovery Discovery
+	if err := json.NewDecoder(response.Body).Decode(&discovery); err != nil {
+		t.Fatal(err)
+	}
+	return discovery
+}
+
+func (rp *relyingParty) post(t *testing.T, endpoint string, form url.Values) (int, map[string]any) {
+	request, err := http.NewRequest(http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.SetBasicAuth(rp.clientId, rp.clientSecret)
+
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+
+	var body map[string]any
+	_ = json.NewDecoder(response.Body).Decode(&body)
+	return response.StatusCode, body
+}
+
+// signIn пройти вход через браузерные перенаправления и вернуть ответ token endpoint
+func (rp *relyingParty) signIn(t *testing.T, scope string) (int, map[string]any) {
+	response, err := http.Get(rp.server.URL + "/login?scope=" + url.QueryEscape(scope))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+
+	var body map[string]any
+	_ = json.NewDecoder(response.Body).Decode(&body)
+	return response.StatusCode, body
+}
+
+// verifyIdToken проверить ID-токен ключами из JWKS, как это делает приложение
+func (rp *relyingParty) verifyIdToken(t *testing.T, token string) Claims {
+	response, err := http.Get(rp.discovery(t).JwksURI)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+
+	var set JWKSet
+	if err := json.NewDecoder(response.Body).Decode(&set); err != nil {
+		t.Fatal(err)
+	}
+
+	var claims Claims
+	if err := verify(token, publicKeys(t, set), &claims); err != nil {
+		t.Fatal(err)
+	}
+	return claims
+}
+
+// publicKeys ключи набора по kid
+func publicKeys(t *testing.T, set JWKSet) map[string]*rsa.PublicKey {
+	keys := make(map[string]*rsa.PublicKey)
+	for _, key := range set.Keys {
+		publicKey, err := key.PublicKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys[key.Kid] = publicKey
+	}
+	return keys
+}
+
+type provider struct {
+	service  *Service
+	repo     *StubRepo
+	server   *httptest.Server
+	loggedIn int64
+	now      time.Time
+}
+
+func newProvider(t *testing.T) *provider {
+	p := &provider{repo: NewStubRepo(), loggedIn: 1, now: time.Now()}
+
+	employees := StubEmployees{
+		1: {Id: 1, Name: "John Doe", UserName: "jdoe", Email: "jdoe@example.com", Department: "IT",
+			Title: "Engineer", Status: employee.StatusActive},
+		2: {Id: 2, Name: "Gone", UserName: "gone", Status: employee.StatusDisabled},
+	}
+	roles := StubRoles{1: {{Id: 1, Name: "Admin"}, {Id: 2, Name: "Developer"}}}
+
+	mux := http.NewServeMux()
+	p.server = httptest.NewServer(mux)
+	t.Cleanup(p.server.Close)
+
+	p.service = NewService(p.repo, employees, roles, p.server.URL+"/oidc")
+	p.service.now = func() time.Time { return p.now }
+	authenticator := httpapi.AuthenticatorFunc[Authentication](func(r *http.Request) (Authentication, error) {
+		if p.loggedIn == 0 {
+			return Authentication{}, ErrLoginRequired
+		}
+		return Authentication{EmployeeId: p.loggedIn, AuthTime: p.now}, nil
+	})
+	mux.Handle("/oidc/", http.StripPrefix("/oidc", NewHandler(p.service, authenticator)))
+
+	return p
+}
+
+func (p *provider) register(t *testing.T, rp *relyingParty, confidential bool) {
+	client, err := p.service.RegisterClient("test app", []string{rp.server.URL + "/callback"}, confidential)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rp.clientId = client.Id
+	rp.clientSecret = client.Secret
+}
+
+func TestOidcProvider(t *testing.T) {
+	assert := assertpackage.New(t)
+
+	t.Run("should publish discovery document and keys", func(t *testing.T) {
+		p := newProvider(t)
+		rp := newRelyingParty(t, p.server.URL+"/oidc")
+
+		discovery := rp.discovery(t)
+		assert.Equal(p.server.URL+"/oidc", discovery.Issuer)
+		assert.Equal(p.server.URL+"/oidc/token", discovery.TokenEndpoint)
+		assert.Equal([]string{"S256"}, discovery.CodeChallengeMethodsSupported)
+
+		response, err := http.Get(discovery.JwksURI)
+		assert.Nil(err)
+		var set JWKSet
+		assert.Nil(json.NewDecoder(response.Body).Decode(&set))
+		assert.Len(set.Keys, 1)
+		assert.Equal("RS256", set.Keys[0].Alg)
+	})
+
+	t.Run("should issue tokens through authorization code flow with PKCE", func(t *testing.T) {
+		p := newProvider(t)
+		rp := newRelyingParty(t, p.server.URL+"/oidc")
+		p.register(t, rp, true)
+
+		status, body := rp.signIn(t, "openid profile email offline_access")
+		assert.Equal(http.StatusOK, status, body)
+		assert.Equal("Bearer", body["token_type"])
+		assert.NotEmpty(body["access_token"])
+		assert.NotEmpty(body["refresh_token"])
+
+		claims := rp.verifyIdToken(t, body["id_token"].(string))
+		assert.Equal(p.server.URL+"/oidc", claims.Issuer)
+		assert.Equal(rp.clientId, claims.Audience)
+		assert.Equal("1", claims.Subject)
+		assert.Equal(rp.nonce, claims.Nonce)
+		assert.Equal("John Doe", claims.Name)
+		assert.Equal("jdoe", claims.PreferredUsername)
+		assert.Equal("jdoe@example.com", claims.Email)
+		assert.Equal("IT", claims.Department)
+		assert.Empty(claims.Roles)
+		assert.Equal(leftHalfHash(body["access_token"].(string)), claims.AccessTokenHash)
+
+		status, body = rp.signIn(t, "openid roles")
+		assert.Equal(http.StatusOK, status)
+		claims = rp.verifyIdToken(t, body["id_token"].(string))
+		assert.Equal([]string{"Admin", "Developer"}, claims.Roles)
+		assert.Empty(claims.Email)
+		assert.Nil(body["refresh_token"])
+	})
+
+	t.Run("should support public clients", func(t *testing.T) {
+		p := newProvider(t)
+		rp := newRelyingParty(t, p.server.URL+"/oidc")
+		p.register(t, rp, false)
+
+		status, body := rp.signIn(t, "openid")
+		assert.Equal(http.StatusOK, status, body)
+		assert.NotEmpty(body["id_token"])
+	})
+
+	t.Run("should reject a wrong code verifier and a reused code", func(t *testing.T) {
+		p := newProvider(t)
+		rp := newRelyingParty(t, p.server.URL+"/oidc")
+		p.register(t, rp, true)
+
+		code, err := p.service.Authorize(AuthorizeRequest{
+			ResponseType:        "code",
+			ClientId:            rp.clientId,
+			RedirectURI:         rp.server.URL + "/callback",
+			Scope:               "openid",
+			CodeChallenge:       "challenge",
+			CodeChallengeMethod: "S256",
+		}, Authentication{EmployeeId: 1, AuthTime: p.now})
+		assert.Nil(err)
+
+		_, err = p.service.Exchange(rp.clientId, rp.clientSecret, code, rp.server.URL+"/callback", rp.verifier)
+		assert.ErrorIs(err, ErrInvalidGrant)
+		_, err = p.service.Exchange(rp.clientId, rp.clientSecret, code, rp.server.URL+"/callback", rp.verifier)
+		assert.ErrorIs(err, ErrInvalidGrant)
+
+		status, body := rp.post(t, p.server.URL+"/oidc/token", url.Values{
+			"grant_type": {"authorization_code"}, "code": {"unknown"},
+		})
+		assert.Equal(http.StatusBadRequest, status)
+		assert.Equal("invalid_grant", body["error"])
+	})
+
+	t.Run("should require PKCE and a registered redirect uri", func(t *testing.T) {
+		p := newProvider(t)
+		rp := newRelyingParty(t, p.server.URL+"/oidc")
+		p.register(t, rp, true)
+
+		_, err := p.service.Authorize(AuthorizeRequest{
+			ResponseType: "code", ClientId: rp.clientId, RedirectURI: rp.server.URL + "/callback", Scope: "openid",
+		}, Authentication{EmployeeId: 1})
+		assert.ErrorIs(err, ErrInvalidRequest)
+
+		client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		}}
+		response, err := client.Get(p.server.URL + "/oidc/authorize?" + url.Values{
+			"client_id": {rp.clientId}, "redirect_uri": {"https://evil.example.com/callback"},
+		}.Encode())
+		assert.Nil(err)
+		assert.Equal(http.StatusBadRequest, response.StatusCode)
+	})
+
+	t.Run("should redirect with an error when login is required or employee is inactive", func(t *testing.T) {
+		p := newProvider(t)
+		rp := newRelyingParty(t, p.server.URL+"/oidc")
+		p.register(t, rp, true)
+
+		p.loggedIn = 0
+		status, body := rp.signIn(t, "openid")
+		assert.Equal(http.StatusForbidden, status)
+		assert.Equal("login_required", body["error"])
+
+		p.loggedIn = 2
+		status, body = rp.signIn(t, "openid")
+		assert.Equal(http.StatusForbidden, status)
+		assert.Equal("access_denied", body["error"])
+	})
+
+	t.Run("should rotate refresh tokens and reject reuse", func(t *testing.T) {
+		p := newProvider(t)
+		rp := newRelyingParty(t, p.server.URL+"/oidc")
+		p.register(t, rp, true)
+
+		_, body := rp.signIn(t, "openid offline_access")
+		refreshToken := body["refresh_token"].(string)
+
+		status, refreshed := rp.post(t, p.server.URL+"/oidc/token", url.Values{
+			"grant_type": {"refresh_token"}, "refresh_token": {refreshToken},
+		})
+		assert.Equal(http.StatusOK, status, refreshed)
+		assert.NotEqual(refreshToken, refreshed["refresh_token"])
+		assert.NotEmpty(refreshed["id_token"])
+
+		status, body = rp.post(t, p.server.URL+"/oidc/token", url.Values{
+			"grant_type": {"refresh_token"}, "refresh_token": {refreshed["refresh_token"].(string)},
+			"scope": {"openid email"},
+		})
+		assert.Equal(http.StatusBadRequest, status)
+		assert.Equal("invalid_scope", body["error"])
+
+		status, body = rp.post(t, p.server.URL+"/oidc/token", url.Values{
+			"grant_type": {"refresh_token"}, "refresh_token": {refreshToken},
+		})
+		assert.Equal(http.StatusBadRequest, status)
+		assert.Equal("invalid_grant", body["error"])
+	})
+
+	t.Run("should revoke the whole token family when a replaced refresh token is reused", func(t *testing.T) {
+		p := newProvider(t)
+		rp := newRelyingParty(t, p.server.URL+"/oidc")
+		p.register(t, rp, true)
+
+		_, body := rp.signIn(t, "openid offline_access")
+		refreshToken := body["refresh_token"].(string)
+		_, refreshed := rp.post(t, p.server.URL+"/oidc/token", url.Values{
+			"grant_type": {"refresh_token"}, "refresh_token": {refreshToken},
+		})
+
+		status, body := rp.post(t, p.server.URL+"/oidc/token", url.Values{
+			"grant_type": {"refresh_token"}, "refresh_token": {refreshToken},
+		})
+		assert.Equal(http.StatusBadRequest, status)
+		assert.Equal("invalid_grant", body["error"])
+
+		status, body = rp.post(t, p.server.URL+"/oidc/token", url.Values{
+			"grant_type": {"refresh_token"}, "refresh_token": {refreshed["refresh_token"].(string)},
+		})
+		assert.Equal(http.StatusBadRequest, status)
+		assert.Equal("invalid_grant", body["error"])
+	})
+
+	t.Run("should introspect and revoke tokens", func(t *testing.T) {
+		p := newProvider(t)
+		rp := newRelyingParty(t, p.server.URL+"/oidc")
+		p.register(t, rp, true)
+
+		_, body := rp.signIn(t, "openid profile offline_access")
+		accessToken := body["access_token"].(string)
+		refreshToken := body["refresh_token"].(string)
+
+		_, introspection := rp.post(t, p.server.URL+"/oidc/introspect", url.Values{"token": {accessToken}})
+		assert.Equal(true, introspection["active"])
+		assert.Equal("1", introspection["sub"])
+		assert.Equal("jdoe", introspection["username"])
+		assert.Equal([]any{"Admin", "Developer"}, introspection["roles"])
+
+		request, _ := http.NewRequest(http.MethodGet, p.server.URL+"/oidc/userinfo", nil)
+		request.Header.Set("Authorization", "Bearer "+accessToken)
+		response, err := http.DefaultClient.Do(request)
+		assert.Nil(err)
+		assert.Equal(http.StatusOK, response.StatusCode)
+
+		status, _ := rp.post(t, p.server.URL+"/oidc/revoke", url.Values{"token": {accessToken}})
+		assert.Equal(http.StatusOK, status)
+		_, introspection = rp.post(t, p.server.URL+"/oidc/introspect", url.Values{"token": {accessToken}})
+		assert.Equal(false, introspection["active"])
+
+		response, err = http.DefaultClient.Do(request)
+		assert.Nil(err)
+		assert.Equal(http.StatusUnauthorized, response.StatusCode)
+
+		_, introspection = rp.post(t, p.server.URL+"/oidc/introspect", url.Values{"token": {refreshToken}})
+		assert.Equal(true, introspection["active"])
+		rp.post(t, p.server.URL+"/oidc/revoke", url.Values{"token": {refreshToken}})
+		_, introspection = rp.post(t, p.server.URL+"/oidc/introspect", url.Values{"token": {refreshToken}})
+		assert.Equal(false, introspection["active"])
+
+		status, _ = rp.post(t, p.server.URL+"/oidc/revoke", url.Values{"token": {"garbage"}})
+		assert.Equal(http.StatusOK, status)
+
+		rp.clientSecret = "wrong"
+		status, body = rp.post(t, p.server.URL+"/oidc/introspect", url.Values{"token": {accessToken}})
+		assert.Equal(http.StatusUnauthorized, status)
+		assert.Equal("invalid_client", body["error"])
+	})
+
+	t.Run("should keep replaced keys in JWKS for the grace period", func(t *testing.T) {
+		p := newProvider(t)
+		rp := newRelyingParty(t, p.server.URL+"/oidc")
+		p.register(t, rp, true)
+
+		_, body := rp.signIn(t, "openid")
+		idToken := body["id_token"].(string)
+
+		p.now = p.now.Add(DefaultKeyLifetime + time.Hour)
+		set, err := p.service.JWKS()
+		assert.Nil(err)
+		assert.Len(set.Keys, 2)
+		rp.verifyIdToken(t, idToken)
+
+		p.now = p.now.Add(KeyGracePeriod + time.Hour)
+		assert.Nil(p.service.RotateKeys())
+		set, err = p.service.JWKS()
+		assert.Nil(err)
+		assert.Len(set.Keys, 2)
+		var claims Claims
+		assert.NotNil(verify(idToken, publicKeys(t, set), &claims))
+	})
+
+	t.Run("should not read keys from the repository for every token", func(t *testing.T) {
+		p := newProvider(t)
+		rp := newRelyingParty(t, p.server.URL+"/oidc")
+		p.register(t, rp, true)
+
+		_, body := rp.signIn(t, "openid profile")
+		accessToken := body["access_token"].(string)
+
+		reads := p.repo.keyReads
+		for range 5 {
+			_, err := p.service.UserInfo(accessToken)
+			assert.Nil(err)
+		}
+		assert.Equal(reads, p.repo.keyReads)
+
+		p.now = p.now.Add(KeyCacheTTL + time.Second)
+		_, err := p.service.UserInfo(accessToken)
+		assert.Nil(err)
+		assert.Equal(reads+1, p.repo.keyReads)
+	})
+
+	t.Run("should accept tokens signed by a key another instance has just created", func(t *testing.T) {
+		p := newProvider(t)
+		_, err := p.service.JWKS()
+		assert.Nil(err)
+
+		other := NewService(p.repo, StubEmployees{}, StubRoles{}, p.server.URL+"/oidc")
+		other.now = p.service.now
+		assert.Nil(other.RotateKeys())
+		kid, key, err := other.signingKey()
+		assert.Nil(err)
+		token, err := sign(kid, key, "at+jwt", Claims{Issuer: p.server.URL + "/oidc", Subject: "1",
+			Id: "jti", ClientId: "app", ExpiresAt: p.now.Add(time.Hour).Unix()})
+		assert.Nil(err)
+
+		p.now = p.now.Add(keyReloadInterval)
+		_, err = p.service.parseAccessToken(token)
+		assert.Nil(err)
+	})
+
+	t.Run("should create a single key when instances rotate at the same time", func(t *testing.T) {
+		repo := NewStubRepo()
+		var wg sync.WaitGroup
+		for range 4 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := NewService(repo, StubEmployees{}, StubRoles{}, "https://idm.example.com").JWKS()
+				assert.Nil(err)
+			}()
+		}
+		wg.Wait()
+
+		assert.Len(repo.keys, 1)
+	})
+}
+
+func TestHandlerErrors(t *testing.T) {
+	assert := assertpackage.New(t)
+
+	t.Run("should map errors to OAuth error codes", func(t *testing.T) {
+		assert.Equal("invalid_grant", errorCode(errors.Join(ErrInvalidGrant)))
+		assert.Equal("server_error", errorCode(errors.New("boom")))
+	})
+}
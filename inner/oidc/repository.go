@@ -0,0 +1,288 @@
+package oidc
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"idm/inner/database"
+	"time"
+)
+
+// Client приложение (relying party), которому разрешено получать токены
+type Client struct {
+	Id           string         `db:"id"`
+	Name         string         `db:"name"`
+	SecretHash   string         `db:"secret_hash"`
+	RedirectURIs pq.StringArray `db:"redirect_uris"`
+	CreatedAt    time.Time      `db:"created_at"`
+}
+
+// Key ключ подписи токенов. Новейший ключ активен, предыдущие публикуются в JWKS до истечения льготного периода.
+type Key struct {
+	Id         string    `db:"id"`
+	PrivateKey []byte    `db:"private_key"`
+	CreatedAt  time.Time `db:"created_at"`
+}
+
+// Code одноразовый код авторизации. Хранится только хеш кода.
+type Code struct {
+	Hash                string     `db:"hash"`
+	ClientId            string     `db:"client_id"`
+	EmployeeId          int64      `db:"employee_id"`
+	RedirectURI         string     `db:"redirect_uri"`
+	Scope               string     `db:"scope"`
+	Nonce               string     `db:"nonce"`
+	CodeChallenge       string     `db:"code_challenge"`
+	CodeChallengeMethod string     `db:"code_challenge_method"`
+	AuthTime            time.Time  `db:"auth_time"`
+	ExpiresAt           time.Time  `db:"expires_at"`
+	UsedAt              *time.Time `db:"used_at"`
+}
+
+// RefreshToken токен обновления. Хранится только хеш токена. Family – хеш первого токена
+// цепочки замен, начатой одним входом.
+type RefreshToken struct {
+	Hash       string     `db:"hash"`
+	Family     string     `db:"family"`
+	ClientId   string     `db:"client_id"`
+	EmployeeId int64      `db:"employee_id"`
+	Scope      string     `db:"scope"`
+	AuthTime   time.Time  `db:"auth_time"`
+	ExpiresAt  time.Time  `db:"expires_at"`
+	RevokedAt  *time.Time `db:"revoked_at"`
+	CreatedAt  time.Time  `db:"created_at"`
+}
+
+type Repository struct {
+	db *sqlx.DB
+}
+
+func NewRepository(db *sqlx.DB) *Repository {
+	return &Repository{db: db}
+}
+
+func (r *Repository) FindClient(id string) (*Client, error) {
+	var client Client
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := r.db.GetContext(ctx, &client, "SELECT * FROM oidc_clients WHERE id = $1", id)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, database.ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &client, err
+}
+
+func (r *Repository) CreateClient(client *Client) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return r.db.QueryRowContext(ctx,
+		"INSERT INTO oidc_clients (id, name, secret_hash, redirect_uris) VALUES ($1, $2, $3, $4) RETURNING created_at",
+		client.Id, client.Name, client.SecretHash, client.RedirectURIs,
+	).Scan(&client.CreatedAt)
+}
+
+// FindKeys ключи подписи, от новых к старым
+func (r *Repository) FindKeys() ([]*Key, error) {
+	var keys []*Key
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := r.db.SelectContext(ctx, &keys, "SELECT * FROM oidc_keys ORDER BY created_at DESC, id")
+
+	return keys, err
+}
+
+// CreateKey сохранить ключ, если нет ключа, созданного после since. Экземпляры сохраняют ключи
+// по очереди под блокировкой, поэтому из одновременных ротаций ключ создаёт только первая.
+func (r *Repository) CreateKey(key *Key, since time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err = tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock(hashtext('idm_oidc_keys'))"); err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO oidc_keys (id, private_key, created_at)
+		SELECT $1, $2, $3 WHERE NOT EXISTS (SELECT 1 FROM oidc_keys WHERE created_at > $4)`,
+		key.Id, key.PrivateKey, key.CreatedAt, since)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *Repository) RemoveKey(id string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := r.db.ExecContext(ctx, "DELETE FROM oidc_keys WHERE id = $1", id)
+
+	return err
+}
+
+func (r *Repository) CreateCode(code *Code) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := r.db.NamedExecContext(ctx,
+		`INSERT INTO oidc_codes (hash, client_id, employee_id, redirect_uri, scope, nonce, code_challenge,
+			code_challenge_method, auth_time, expires_at)
+		VALUES (:hash, :client_id, :employee_id, :redirect_uri, :scope, :nonce, :code_challenge,
+			:code_challenge_method, :auth_time, :expires_at)`,
+		code)
+
+	return err
+}
+
+// ConsumeCode отметить код использованным и вернуть его. Повторное использование кода
+// возвращает database.ErrRecordNotFound.
+func (r *Repository) ConsumeCode(hash string, now time.Time) (*Code, error) {
+	var code Code
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := r.db.GetContext(ctx, &code,
+		"UPDATE oidc_codes SET used_at = $1 WHERE hash = $2 AND used_at IS NULL RETURNING *", now, hash)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, database.ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &code, err
+}
+
+func (r *Repository) FindRefreshToken(hash string) (*RefreshToken, error) {
+	var token RefreshToken
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := r.db.GetContext(ctx, &token, "SELECT * FROM oidc_refresh_tokens WHERE hash = $1", hash)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, database.ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &token, err
+}
+
+func (r *Repository) CreateRefreshToken(token *RefreshToken) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return insertRefreshToken(ctx, r.db, token)
+}
+
+// RotateRefreshToken атомарно отозвать использованный токен обновления и сохранить новый.
+// Если старый токен уже отозван, возвращается database.ErrRecordNotFound.
+func (r *Repository) RotateRefreshToken(hash string, token *RefreshToken) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	result, err := tx.ExecContext(ctx,
+		"UPDATE oidc_refresh_tokens SET revoked_at = $1 WHERE hash = $2 AND revoked_at IS NULL",
+		token.CreatedAt, hash)
+	if err != nil {
+		return err
+	}
+	count, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return database.ErrRecordNotFound
+	}
+
+	if err = insertRefreshToken(ctx, tx, token); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *Repository) RevokeRefreshToken(hash string, now time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := r.db.ExecContext(ctx,
+		"UPDATE oidc_refresh_tokens SET revoked_at = $1 WHERE hash = $2 AND revoked_at IS NULL", now, hash)
+
+	return err
+}
+
+// RevokeRefreshTokenFamily отозвать все действующие токены обновления семейства
+func (r *Repository) RevokeRefreshTokenFamily(family string, now time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := r.db.ExecContext(ctx,
+		"UPDATE oidc_refresh_tokens SET revoked_at = $1 WHERE family = $2 AND revoked_at IS NULL", now, family)
+
+	return err
+}
+
+// RevokeAccessToken запомнить отозванный токен доступа до окончания его срока действия
+func (r *Repository) RevokeAccessToken(jti string, expiresAt time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := r.db.ExecContext(ctx,
+		"INSERT INTO oidc_revoked_tokens (jti, expires_at) VALUES ($1, $2) ON CONFLICT (jti) DO NOTHING",
+		jti, expiresAt)
+
+	return err
+}
+
+func (r *Repository) IsAccessTokenRevoked(jti string) (bool, error) {
+	var revoked bool
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := r.db.GetContext(ctx, &revoked, "SELECT EXISTS (SELECT 1 FROM oidc_revoked_tokens WHERE jti = $1)", jti)
+
+	return revoked, err
+}
+
+func insertRefreshToken(ctx context.Context, db sqlx.ExtContext, token *RefreshToken) error {
+	_, err := sqlx.NamedExecContext(ctx, db,
+		`INSERT INTO oidc_refresh_tokens (hash, family, client_id, employee_id, scope, auth_time, expires_at, created_at)
+		VALUES (:hash, :family, :client_id, :employee_id, :scope, :auth_time, :expires_at, :created_at)`,
+		token)
+
+	return err
+}
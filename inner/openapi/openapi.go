@@ -0,0 +1,299 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"idm/inner/session"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Version версия спецификации OpenAPI, которой соответствует документ
+const Version = "3.1.0"
+
+// Operation одна операция HTTP API. Схемы тел строятся из типов Go, которые отдают
+// и принимают обработчики, поэтому документ не расходится с ответами сервиса.
+type Operation struct {
+	// Id operationId; клиент вызывает операцию методом с тем же именем
+	Id          string
+	Method      string
+	Path        string
+	Tag         string
+	Summary     string
+	Description string
+	// Scope право ключа; пусто – операция доступна без ключа
+	Scope string
+	// Session операция выполняется от имени сотрудника, вошедшего через /login, по cookie сеанса
+	Session bool
+	Query   []Parameter
+	Headers []Parameter
+	Body    []Content
+	// Status код успешного ответа; Result его тело, пусто – ответ без тела
+	Status int
+	Result []Content
+	// Errors коды ошибок с особым телом; nil – тело Error, пустой список – ответ без тела
+	Errors map[int][]Content
+}
+
+// Parameter параметр строки запроса или заголовок
+type Parameter struct {
+	Name        string
+	Description string
+	// Type тип JSON Schema: string, integer или boolean
+	Type string
+	Enum []string
+}
+
+// Content тело с типом MIME. Схема берётся из Schema, иначе строится по типу Value;
+// без обоих тело описывается только типом MIME.
+type Content struct {
+	Type   string
+	Value  any
+	Schema map[string]any
+}
+
+// Error тело ответа с ошибкой
+type Error struct {
+	Error string `json:"error"`
+}
+
+var pathParameter = regexp.MustCompile(`\{(\w+)\}`)
+
+// Document документ OpenAPI для сервиса, доступного по baseURL
+func Document(baseURL string) map[string]any {
+	g := &generator{components: map[string]any{}}
+	g.schemaOf(reflect.TypeFor[Error]())
+
+	paths := map[string]map[string]any{}
+	for _, operation := range Operations {
+		if paths[operation.Path] == nil {
+			paths[operation.Path] = map[string]any{}
+		}
+		paths[operation.Path][strings.ToLower(operation.Method)] = g.operation(operation)
+	}
+
+	return map[string]any{
+		"openapi": Version,
+		"info": map[string]any{
+			"title":   "IDM API",
+			"version": "1",
+			"description": "HTTP API of IDM. Operations for service accounts list the scope their API key must have. " +
+				"Sign-in (/login) starts a browser session; the employee's own sessions, access requests and " +
+				"certification reviews use its cookie. Employees and roles are provisioned through SCIM 2.0 " +
+				"(/scim/v2) as the Employee and Role resources. Relying parties use OpenID Connect (/oidc).",
+		},
+		"servers": []any{map[string]any{"url": baseURL}},
+		"paths":   paths,
+		"components": map[string]any{
+			"schemas": g.components,
+			"securitySchemes": map[string]any{
+				"apiKey": map[string]any{
+					"type":        "http",
+					"scheme":      "bearer",
+					"description": "Service account API key. Operations list the scope the key must have.",
+				},
+				"session": map[string]any{
+					"type":        "apiKey",
+					"in":          "cookie",
+					"name":        session.CookieName,
+					"description": "Session of an employee who signed in through /login.",
+				},
+			},
+		},
+	}
+}
+
+// NewHandler отдаёт документ в формате JSON: GET /openapi.json
+func NewHandler(baseURL string) http.Handler {
+	body, err := json.Marshal(Document(baseURL))
+	if err != nil {
+		panic(fmt.Sprintf("error encoding openapi document: %v", err))
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(body)
+	})
+}
+
+func (g *generator) operation(operation Operation) map[string]any {
+	parameters := []any{}
+	for _, match := range pathParameter.FindAllStringSubmatch(operation.Path, -1) {
+		schema := map[string]any{"type": "integer", "format": "int64"}
+		if !strings.HasSuffix(strings.ToLower(match[1]), "id") {
+			schema = map[string]any{"type": "string"}
+		}
+		parameters = append(parameters, map[string]any{"name": match[1], "in": "path", "required": true, "schema": schema})
+	}
+	for _, parameter := range operation.Query {
+		parameters = append(parameters, parameter.describe("query"))
+	}
+	for _, parameter := range operation.Headers {
+		parameters = append(parameters, parameter.describe("header"))
+	}
+
+	responses := map[string]any{}
+	success := map[string]any{"description": http.StatusText(operation.Status)}
+	if len(operation.Result) > 0 {
+		success["content"] = g.content(operation.Result, false)
+	}
+	responses[fmt.Sprint(operation.Status)] = success
+
+	errors := map[int][]Content{http.StatusInternalServerError: nil}
+	if operation.Scope != "" {
+		errors[http.StatusUnauthorized], errors[http.StatusForbidden] = nil, nil
+	}
+	if operation.Session {
+		errors[http.StatusUnauthorized] = nil
+	}
+	for status, content := range operation.Errors {
+		errors[status] = content
+	}
+	for status, content := range errors {
+		if content == nil {
+			content = []Content{{Type: "application/json", Value: Error{}}}
+		}
+		response := map[string]any{"description": http.StatusText(status)}
+		if len(content) > 0 {
+			response["content"] = g.content(content, false)
+		}
+		responses[fmt.Sprint(status)] = response
+	}
+
+	result := map[string]any{
+		"operationId": operation.Id,
+		"tags":        []string{operation.Tag},
+		"summary":     operation.Summary,
+		"parameters":  parameters,
+		"responses":   responses,
+	}
+	if operation.Description != "" {
+		result["description"] = operation.Description
+	}
+	if len(operation.Body) > 0 {
+		result["requestBody"] = map[string]any{"required": true, "content": g.content(operation.Body, true)}
+	}
+	switch {
+	case operation.Scope != "":
+		result["security"] = []any{map[string]any{"apiKey": []string{operation.Scope}}}
+	case operation.Session:
+		result["security"] = []any{map[string]any{"session": []string{}}}
+	default:
+		result["security"] = []any{}
+	}
+
+	return result
+}
+
+func (p Parameter) describe(in string) map[string]any {
+	schema := map[string]any{"type": p.Type}
+	if len(p.Enum) > 0 {
+		schema["enum"] = p.Enum
+	}
+
+	return map[string]any{"name": p.Name, "in": in, "description": p.Description, "schema": schema}
+}
+
+// content тела операции; в теле запроса нет обязательных полей – отсутствующие считаются пустыми
+func (g *generator) content(contents []Content, request bool) map[string]any {
+	g.request = request
+	result := map[string]any{}
+	for _, content := range contents {
+		media := map[string]any{}
+		switch {
+		case content.Schema != nil:
+			media["schema"] = content.Schema
+		case content.Value != nil:
+			media["schema"] = g.schemaOf(reflect.TypeOf(content.Value))
+		}
+		result[content.Type] = media
+	}
+
+	return result
+}
+
+// generator строит схемы JSON Schema по типам Go; именованные типы из names
+// попадают в components и подставляются ссылкой
+type generator struct {
+	components map[string]any
+	request    bool
+}
+
+func (g *generator) schemaOf(t reflect.Type) map[string]any {
+	if name, ok := names[t]; ok {
+		if _, done := g.components[name]; !done {
+			// заглушка до построения: рекурсивные типы ссылаются на себя
+			g.components[name] = nil
+			g.components[name] = g.build(t)
+		}
+		return map[string]any{"$ref": "#/components/schemas/" + name}
+	}
+
+	return g.build(t)
+}
+
+func (g *generator) build(t reflect.Type) map[string]any {
+	switch t {
+	case reflect.TypeFor[time.Time]():
+		return map[string]any{"type": "string", "format": "date-time"}
+	case reflect.TypeFor[json.RawMessage](), reflect.TypeFor[any]():
+		return map[string]any{}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return map[string]any{"anyOf": []any{g.schemaOf(t.Elem()), map[string]any{"type": "null"}}}
+	case reflect.Struct:
+		properties, required := map[string]any{}, []string{}
+		g.fields(t, properties, &required)
+		schema := map[string]any{"type": "object", "properties": properties}
+		if len(required) > 0 && !g.request {
+			schema["required"] = required
+		}
+		return schema
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": g.schemaOf(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": g.schemaOf(t.Elem())}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int64, reflect.Uint64:
+		return map[string]any{"type": "integer", "format": "int64"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	}
+
+	return map[string]any{}
+}
+
+// fields свойства структуры по тегам json; поля встроенных структур поднимаются наверх,
+// как их кодирует encoding/json. Обязательны поля без omitempty и omitzero – они есть в каждом ответе.
+func (g *generator) fields(t reflect.Type, properties map[string]any, required *[]string) {
+	for i := range t.NumField() {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" || !field.IsExported() {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			g.fields(field.Type, properties, required)
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		properties[name] = g.schemaOf(field.Type)
+		if !strings.Contains(options, "omitempty") && !strings.Contains(options, "omitzero") {
+			*required = append(*required, name)
+		}
+	}
+}
@@ -0,0 +1,231 @@
+package openapi
+
+import (
+	"encoding/json"
+	"idm/inner/bulkimport"
+	"idm/inner/certification"
+	"idm/inner/export"
+	"idm/inner/graphqlapi"
+	"idm/inner/group"
+	"idm/inner/hrsync"
+	"idm/inner/ldapsync"
+	"idm/inner/lifecycle"
+	"idm/inner/login"
+	"idm/inner/oidc"
+	"idm/inner/org"
+	"idm/inner/provisioning"
+	"idm/inner/router"
+	"idm/inner/scim"
+	"idm/inner/serviceaccount"
+	"idm/inner/session"
+	"idm/inner/sod"
+	"idm/inner/webhook"
+	"idm/inner/workflow"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+
+	assertpackage "github.com/stretchr/testify/assert"
+)
+
+// scopeHeader заголовок, в котором scopes сообщает право, проверенное перед обработчиком
+const scopeHeader = "X-Required-Scope"
+
+// scopes пропускает запросы без ключа и отмечает в ответе, какое право потребовалось бы
+type scopes struct{}
+
+func (scopes) RequireScope(scope string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(scopeHeader, scope)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// mount маршруты сервиса из router.Routes, как в cmd/main.go, но без проверки ключа.
+// Сервисы не заданы: запрос, дошедший до обработчика, завершается ответом или паникой.
+func mount() *http.ServeMux {
+	mux := http.NewServeMux()
+	router.Routes(mux, router.Handlers{
+		OpenAPI:             NewHandler("http://localhost"),
+		Login:               login.NewHandler(nil, nil),
+		Sessions:            session.NewHandler(nil),
+		AdminSessions:       session.NewAdminHandler(nil),
+		SCIM:                scim.NewHandler(nil, nil, "http://localhost/scim/v2"),
+		ServiceAccounts:     serviceaccount.NewHandler(nil),
+		Import:              bulkimport.NewHandler(nil),
+		HR:                  hrsync.NewHandler(nil),
+		Export:              export.NewHandler(nil),
+		GraphQL:             graphqlapi.NewHandler(nil, nil),
+		Org:                 org.NewHandler(nil),
+		Groups:              group.NewHandler(nil),
+		AccessRequests:      workflow.NewHandler(nil, nil),
+		AdminAccessRequests: workflow.NewAdminHandler(nil),
+		Certifications:      certification.NewHandler(nil, nil),
+		AdminCertifications: certification.NewAdminHandler(nil),
+		Lifecycle:           lifecycle.NewHandler(nil),
+		SoD:                 sod.NewHandler(nil),
+		Webhooks:            webhook.NewHandler(nil),
+		OIDC:                oidc.NewHandler(nil, nil),
+		LDAP:                ldapsync.NewHandler(nil),
+		Provisioning:        provisioning.NewHandler(nil),
+	}, scopes{})
+
+	return mux
+}
+
+// routed дошёл ли запрос до обработчика и какое право ключа для этого проверялось.
+// Промах маршрута ServeMux отвечает 404 или 405 текстом.
+func routed(handler http.Handler, method string, path string) (scope string, ok bool) {
+	recorder := httptest.NewRecorder()
+	defer func() {
+		if recover() != nil {
+			scope, ok = recorder.Header().Get(scopeHeader), true
+		}
+	}()
+
+	handler.ServeHTTP(recorder, httptest.NewRequest(method, path, strings.NewReader("{}")))
+
+	return recorder.Header().Get(scopeHeader), recorder.Code != http.StatusMethodNotAllowed &&
+		!(recorder.Code == http.StatusNotFound && recorder.Body.String() == "404 page not found\n")
+}
+
+func TestOperations(t *testing.T) {
+	var assert = assertpackage.New(t)
+
+	t.Run("should route every operation to a handler behind the documented scope", func(t *testing.T) {
+		mux := mount()
+		for _, operation := range Operations {
+			path := pathParameter.ReplaceAllString(operation.Path, "1")
+			scope, ok := routed(mux, operation.Method, path)
+			assert.True(ok, "%s %s", operation.Method, operation.Path)
+			assert.Equal(operation.Scope, scope, "%s %s", operation.Method, operation.Path)
+		}
+
+		for _, route := range [][2]string{
+			{http.MethodDelete, "/webhooks/1/secret"},
+			{http.MethodGet, "/import/employees"},
+			{http.MethodGet, "/admin/hr/unknown"},
+			{http.MethodPost, "/org/chart"},
+			{http.MethodGet, "/groups/evaluate/members/1"},
+			{http.MethodGet, "/login/"},
+			{http.MethodPost, "/scim/v2/Users/1"},
+			{http.MethodDelete, "/admin/lifecycle/1"},
+		} {
+			_, ok := routed(mux, route[0], route[1])
+			assert.False(ok, "%s %s", route[0], route[1])
+		}
+	})
+
+	t.Run("should document every route of the handlers", func(t *testing.T) {
+		documented := map[string]bool{}
+		for _, operation := range Operations {
+			documented[strings.SplitN(strings.TrimPrefix(operation.Path, "/"), "/", 2)[0]] = true
+		}
+
+		for _, prefix := range []string{"login", "sessions", "scim", "oidc", "access-requests", "certifications",
+			"admin", "service-accounts", "import", "export", "org", "groups", "webhooks", "graphql", "openapi.json"} {
+			assert.True(documented[prefix], prefix)
+		}
+	})
+
+	t.Run("should have unique operation ids and one operation per method and path", func(t *testing.T) {
+		ids, routes := map[string]bool{}, map[string]bool{}
+		for _, operation := range Operations {
+			assert.False(ids[operation.Id], operation.Id)
+			assert.False(routes[operation.Method+" "+operation.Path], operation.Path)
+			ids[operation.Id], routes[operation.Method+" "+operation.Path] = true, true
+			assert.NotZero(operation.Status, operation.Id)
+		}
+	})
+}
+
+func TestDocument(t *testing.T) {
+	var assert = assertpackage.New(t)
+
+	encoded, err := json.Marshal(Document("https://idm.example.com"))
+	assert.Nil(err)
+	var document map[string]any
+	assert.Nil(json.Unmarshal(encoded, &document))
+	schemas := document["components"].(map[string]any)["schemas"].(map[string]any)
+
+	t.Run("should describe every operation", func(t *testing.T) {
+		assert.Equal(Version, document["openapi"])
+		paths := document["paths"].(map[string]any)
+		for _, operation := range Operations {
+			described := paths[operation.Path].(map[string]any)[strings.ToLower(operation.Method)].(map[string]any)
+			assert.Equal(operation.Id, described["operationId"])
+		}
+
+		redeliver := paths["/webhooks/{id}/deliveries/{deliveryId}/redeliver"].(map[string]any)["post"].(map[string]any)
+		parameters := redeliver["parameters"].([]any)
+		assert.Equal("id", parameters[0].(map[string]any)["name"])
+		assert.Equal("deliveryId", parameters[1].(map[string]any)["name"])
+		assert.Equal([]any{map[string]any{"apiKey": []any{serviceaccount.ScopeWebhooks}}}, redeliver["security"])
+
+		sessions := paths["/sessions/"].(map[string]any)["get"].(map[string]any)
+		assert.Equal([]any{map[string]any{"session": []any{}}}, sessions["security"])
+		assert.Contains(sessions["responses"], "401")
+		totp := paths["/login/totp"].(map[string]any)["post"].(map[string]any)
+		assert.Equal("header", totp["parameters"].([]any)[0].(map[string]any)["in"])
+		userinfo := paths["/oidc/userinfo"].(map[string]any)["get"].(map[string]any)
+		assert.NotContains(userinfo["responses"].(map[string]any)["401"], "content")
+	})
+
+	t.Run("should resolve every reference", func(t *testing.T) {
+		for _, ref := range regexpAll(string(encoded), `"\$ref":"#/components/schemas/(\w+)"`) {
+			assert.Contains(schemas, ref)
+		}
+		assert.Len(schemas, len(names))
+	})
+
+	t.Run("should match json encoding of the response types", func(t *testing.T) {
+		for goType, name := range names {
+			schema := schemas[name].(map[string]any)
+			properties := schema["properties"].(map[string]any)
+
+			// нулевое значение содержит ровно поля без omitempty – обязательные поля схемы
+			zero, _ := json.Marshal(reflect.New(goType).Interface())
+			var fields map[string]any
+			assert.Nil(json.Unmarshal(zero, &fields))
+			required, _ := schema["required"].([]any)
+			for field := range fields {
+				assert.Contains(properties, field, name)
+			}
+			for _, field := range required {
+				assert.Contains(fields, field, name)
+			}
+		}
+
+		key := schemas["IssuedKey"].(map[string]any)
+		assert.Contains(key["properties"], "secret")
+		assert.Contains(key["properties"], "service_account_id")
+		assert.NotContains(key["required"], "expires_at")
+		assert.Contains(key["required"], "created_at")
+		assert.NotContains(schemas["WebhookRequest"], "required")
+		assert.NotContains(schemas["LoginResult"].(map[string]any)["required"], "auth_time")
+		assert.Contains(schemas["Employee"].(map[string]any)["required"], "userName")
+	})
+
+	t.Run("should serve the document", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		NewHandler("https://idm.example.com").ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+
+		assert.Equal(http.StatusOK, recorder.Code)
+		assert.Equal("application/json", recorder.Header().Get("Content-Type"))
+		assert.JSONEq(string(encoded), recorder.Body.String())
+	})
+}
+
+func regexpAll(text string, pattern string) []string {
+	var found []string
+	for _, match := range regexp.MustCompile(pattern).FindAllStringSubmatch(text, -1) {
+		if !slices.Contains(found, match[1]) {
+			found = append(found, match[1])
+		}
+	}
+	return found
+}
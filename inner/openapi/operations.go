@@ -0,0 +1,792 @@
+package openapi
+
+import (
+	"idm/inner/bulkimport"
+	"idm/inner/certification"
+	"idm/inner/export"
+	"idm/inner/group"
+	"idm/inner/hrsync"
+	"idm/inner/ldapsync"
+	"idm/inner/lifecycle"
+	"idm/inner/login"
+	"idm/inner/mfa"
+	"idm/inner/oidc"
+	"idm/inner/org"
+	"idm/inner/provisioning"
+	"idm/inner/scim"
+	"idm/inner/serviceaccount"
+	"idm/inner/session"
+	"idm/inner/sod"
+	"idm/inner/webhook"
+	"idm/inner/workflow"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// GraphQLRequest запрос к /graphql
+type GraphQLRequest struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName,omitempty"`
+	Variables     map[string]any `json:"variables,omitempty"`
+}
+
+// GraphQLResponse ответ /graphql; Data отсутствует, если запрос не выполнялся
+type GraphQLResponse struct {
+	Data   any `json:"data,omitempty"`
+	Errors []struct {
+		Message string `json:"message"`
+		Path    []any  `json:"path,omitempty"`
+	} `json:"errors,omitempty"`
+}
+
+// EmployeeList страница сотрудников SCIM
+type EmployeeList struct {
+	Schemas      []string    `json:"schemas"`
+	TotalResults int         `json:"totalResults"`
+	ItemsPerPage int         `json:"itemsPerPage"`
+	StartIndex   int         `json:"startIndex"`
+	Resources    []scim.User `json:"Resources"`
+}
+
+// RoleList страница ролей SCIM
+type RoleList struct {
+	Schemas      []string     `json:"schemas"`
+	TotalResults int          `json:"totalResults"`
+	ItemsPerPage int          `json:"itemsPerPage"`
+	StartIndex   int          `json:"startIndex"`
+	Resources    []scim.Group `json:"Resources"`
+}
+
+// OAuthError ошибка эндпоинтов OAuth 2.0
+type OAuthError struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// AuthorizeForm параметры /oidc/authorize, переданные формой
+type AuthorizeForm struct {
+	ResponseType        string `json:"response_type"`
+	ClientId            string `json:"client_id"`
+	RedirectURI         string `json:"redirect_uri"`
+	Scope               string `json:"scope"`
+	State               string `json:"state"`
+	Nonce               string `json:"nonce"`
+	CodeChallenge       string `json:"code_challenge"`
+	CodeChallengeMethod string `json:"code_challenge_method"`
+}
+
+// TokenForm запрос /oidc/token; приложение передаёт client_secret в форме или в заголовке Basic
+type TokenForm struct {
+	GrantType    string `json:"grant_type"`
+	Code         string `json:"code"`
+	RedirectURI  string `json:"redirect_uri"`
+	CodeVerifier string `json:"code_verifier"`
+	RefreshToken string `json:"refresh_token"`
+	Scope        string `json:"scope"`
+	ClientId     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+}
+
+// TokenActionForm запрос /oidc/revoke и /oidc/introspect
+type TokenActionForm struct {
+	Token        string `json:"token"`
+	ClientId     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+}
+
+// names имена схем в components; остальные типы описываются на месте
+var names = map[reflect.Type]string{
+	reflect.TypeFor[Error]():                          "Error",
+	reflect.TypeFor[serviceaccount.Response]():        "ServiceAccount",
+	reflect.TypeFor[serviceaccount.CreateRequest]():   "ServiceAccountRequest",
+	reflect.TypeFor[serviceaccount.KeyResponse]():     "Key",
+	reflect.TypeFor[serviceaccount.KeyRequest]():      "KeyRequest",
+	reflect.TypeFor[serviceaccount.IssuedKey]():       "IssuedKey",
+	reflect.TypeFor[session.Response]():               "Session",
+	reflect.TypeFor[bulkimport.Summary]():             "ImportSummary",
+	reflect.TypeFor[hrsync.Report]():                  "HrReport",
+	reflect.TypeFor[ldapsync.Diff]():                  "LdapDiff",
+	reflect.TypeFor[provisioning.Status]():            "Connector",
+	reflect.TypeFor[provisioning.Report]():            "ProvisioningReport",
+	reflect.TypeFor[webhook.Response]():               "Webhook",
+	reflect.TypeFor[webhook.Request]():                "WebhookRequest",
+	reflect.TypeFor[webhook.CreatedResponse]():        "WebhookWithSecret",
+	reflect.TypeFor[webhook.DeliveryResponse]():       "Delivery",
+	reflect.TypeFor[org.Response]():                   "Department",
+	reflect.TypeFor[org.Request]():                    "DepartmentRequest",
+	reflect.TypeFor[org.MembersRequest]():             "DepartmentMembersRequest",
+	reflect.TypeFor[org.MemberResponse]():             "OrgMember",
+	reflect.TypeFor[org.HeadcountResponse]():          "Headcount",
+	reflect.TypeFor[org.Node]():                       "OrgNode",
+	reflect.TypeFor[org.Chart]():                      "OrgChart",
+	reflect.TypeFor[group.Response]():                 "Group",
+	reflect.TypeFor[group.Request]():                  "GroupRequest",
+	reflect.TypeFor[group.MemberResponse]():           "GroupMember",
+	reflect.TypeFor[group.DiffResponse]():             "GroupRoleDiff",
+	reflect.TypeFor[GraphQLRequest]():                 "GraphQLRequest",
+	reflect.TypeFor[GraphQLResponse]():                "GraphQLResponse",
+	reflect.TypeFor[scim.User]():                      "Employee",
+	reflect.TypeFor[scim.Group]():                     "Role",
+	reflect.TypeFor[EmployeeList]():                   "EmployeeList",
+	reflect.TypeFor[RoleList]():                       "RoleList",
+	reflect.TypeFor[scim.PatchRequest]():              "ScimPatch",
+	reflect.TypeFor[scim.BulkRequest]():               "ScimBulkRequest",
+	reflect.TypeFor[scim.BulkResponse]():              "ScimBulkResponse",
+	reflect.TypeFor[scim.ErrorResponse]():             "ScimError",
+	reflect.TypeFor[login.CredentialsRequest]():       "LoginRequest",
+	reflect.TypeFor[login.CodeRequest]():              "LoginCode",
+	reflect.TypeFor[login.Result]():                   "LoginResult",
+	reflect.TypeFor[mfa.TOTPEnrollment]():             "TOTPEnrollment",
+	reflect.TypeFor[oidc.Discovery]():                 "OIDCDiscovery",
+	reflect.TypeFor[oidc.JWKSet]():                    "JWKSet",
+	reflect.TypeFor[oidc.TokenResponse]():             "OIDCToken",
+	reflect.TypeFor[oidc.IntrospectionResponse]():     "OIDCIntrospection",
+	reflect.TypeFor[oidc.Claims]():                    "OIDCClaims",
+	reflect.TypeFor[OAuthError]():                     "OAuthError",
+	reflect.TypeFor[workflow.RequestResponse]():       "AccessRequest",
+	reflect.TypeFor[workflow.SubmitRequest]():         "AccessRequestSubmission",
+	reflect.TypeFor[workflow.DecisionRequest]():       "AccessDecision",
+	reflect.TypeFor[certification.CampaignResponse](): "Campaign",
+	reflect.TypeFor[certification.CampaignRequest]():  "CampaignRequest",
+	reflect.TypeFor[certification.ItemResponse]():     "CertificationItem",
+	reflect.TypeFor[certification.DecisionRequest]():  "CertificationDecision",
+	reflect.TypeFor[lifecycle.ChangeResponse]():       "LifecycleChange",
+	reflect.TypeFor[lifecycle.HireChange]():           "HireChange",
+	reflect.TypeFor[lifecycle.TransferChange]():       "TransferChange",
+	reflect.TypeFor[lifecycle.TerminateChange]():      "TerminateChange",
+	reflect.TypeFor[sod.RuleResponse]():               "SodRule",
+	reflect.TypeFor[sod.RuleRequest]():                "SodRuleRequest",
+	reflect.TypeFor[sod.ExceptionResponse]():          "SodException",
+	reflect.TypeFor[sod.ExceptionRequest]():           "SodExceptionRequest",
+	reflect.TypeFor[sod.ViolationResponse]():          "SodViolation",
+}
+
+// formType тело запросов OAuth 2.0
+const formType = "application/x-www-form-urlencoded"
+
+// mappingNote параметры map.<поле> не описываются схемой: их имена задаёт клиент
+const mappingNote = "Columns named differently from the fields are mapped with map.<field>=<column> query parameters."
+
+func jsonOf(value any) []Content {
+	return []Content{{Type: "application/json", Value: value}}
+}
+
+var (
+	dryRun     = Parameter{Name: "dry_run", Type: "boolean", Description: "Only compute the changes without applying them"}
+	badRequest = map[int][]Content{http.StatusBadRequest: nil}
+	byId       = map[int][]Content{http.StatusBadRequest: nil, http.StatusNotFound: nil}
+	// conflict 409: имя занято, изменение создало бы цикл или удаляемое не пусто
+	conflict = map[int][]Content{http.StatusBadRequest: nil, http.StatusNotFound: nil, http.StatusConflict: nil}
+	// decision 403: сотрудник не согласует заявку или не пересматривает элемент; 409: решение уже принято
+	decision = map[int][]Content{http.StatusBadRequest: nil, http.StatusForbidden: nil, http.StatusNotFound: nil,
+		http.StatusConflict: nil}
+)
+
+func scimOf(value any) []Content {
+	return []Content{{Type: scim.ContentType, Value: value}}
+}
+
+// scimErrors ошибки SCIM в формате RFC 7644; отказ в доступе по ключу – в формате Error
+func scimErrors(statuses ...int) map[int][]Content {
+	errors := map[int][]Content{http.StatusInternalServerError: scimOf(scim.ErrorResponse{})}
+	for _, status := range statuses {
+		errors[status] = scimOf(scim.ErrorResponse{})
+	}
+
+	return errors
+}
+
+var (
+	scimAttributes = []Parameter{
+		{Name: "attributes", Type: "string", Description: "Comma separated attributes to return"},
+		{Name: "excludedAttributes", Type: "string", Description: "Comma separated attributes to leave out"},
+	}
+	scimList = append([]Parameter{
+		{Name: "filter", Type: "string", Description: `SCIM filter expression, e.g. userName eq "ivanov"`},
+		{Name: "startIndex", Type: "integer", Description: "1-based index of the first result"},
+		{Name: "count", Type: "integer", Description: "Page size"},
+	}, scimAttributes...)
+	ifMatch = []Parameter{{Name: "If-Match", Type: "string", Description: "ETag of the version being changed"}}
+	// scimObject ответы, которые описывают сам сервис и не имеют собственной схемы
+	scimObject = []Content{{Type: scim.ContentType, Schema: map[string]any{"type": "object"}}}
+
+	loginToken = []Parameter{{Name: login.TokenHeader, Type: "string",
+		Description: "Token of the unfinished sign-in returned by the previous step"}}
+	// loginErrors 401 – неверные данные, 423 – учётная запись временно заблокирована,
+	// 409 – способ уже подключён или не подключён, 501 – WebAuthn не настроен
+	loginErrors = map[int][]Content{http.StatusBadRequest: nil, http.StatusUnauthorized: nil, http.StatusForbidden: nil,
+		http.StatusConflict: nil, http.StatusLocked: nil, http.StatusNotImplemented: nil}
+	webAuthnOptions = []Content{{Type: "application/json", Schema: map[string]any{"type": "object",
+		"description": "Options for navigator.credentials.get() or navigator.credentials.create()"}}}
+	webAuthnCredential = []Content{{Type: "application/json", Schema: map[string]any{"type": "object",
+		"description": "Credential returned by navigator.credentials.get() or navigator.credentials.create()"}}}
+
+	oauthError = map[int][]Content{http.StatusBadRequest: jsonOf(OAuthError{}), http.StatusUnauthorized: jsonOf(OAuthError{}),
+		http.StatusInternalServerError: jsonOf(OAuthError{})}
+	authorizeQuery = []Parameter{
+		{Name: "response_type", Type: "string", Enum: []string{"code"}},
+		{Name: "client_id", Type: "string"},
+		{Name: "redirect_uri", Type: "string"},
+		{Name: "scope", Type: "string", Description: "Space separated scopes including openid"},
+		{Name: "state", Type: "string"},
+		{Name: "nonce", Type: "string"},
+		{Name: "code_challenge", Type: "string"},
+		{Name: "code_challenge_method", Type: "string", Enum: []string{"S256"}},
+	}
+	// authorizeErrors неизвестное приложение или redirect_uri; остальные ошибки передаются перенаправлением
+	authorizeErrors = map[int][]Content{http.StatusBadRequest: {{Type: "text/plain"}}}
+	// userinfoErrors токен доступа отсутствует или недействителен, причина – в WWW-Authenticate
+	userinfoErrors = map[int][]Content{http.StatusUnauthorized: {}, http.StatusInternalServerError: jsonOf(OAuthError{})}
+)
+
+// exportOperation выгрузка одного вида; строки JSON Lines содержат столбцы export.Columns
+func exportOperation(id string, kind export.Kind, scope string) Operation {
+	columns := map[string]any{}
+	for _, column := range export.Columns[kind] {
+		columns[column] = map[string]any{}
+	}
+
+	return Operation{
+		Id: id, Method: http.MethodGet, Path: "/export/" + string(kind), Tag: "export", Scope: scope,
+		Summary: "Stream all " + string(kind) + " as CSV, JSON Lines or XLSX",
+		Query: []Parameter{
+			{Name: "format", Type: "string", Enum: []string{string(export.FormatCSV), string(export.FormatJSONL),
+				string(export.FormatXLSX)}, Description: "File format, csv by default"},
+			{Name: "columns", Type: "string", Description: "Comma separated columns: " + strings.Join(export.Columns[kind], ", ")},
+			{Name: "filter", Type: "string", Description: `SCIM filter expression, e.g. status eq "active"`},
+		},
+		Status: http.StatusOK,
+		Result: []Content{
+			{Type: "text/csv"},
+			{Type: "application/x-ndjson", Schema: map[string]any{"type": "object", "properties": columns,
+				"description": "Each line is a JSON object with the selected columns"}},
+			{Type: export.ContentType(export.FormatXLSX)},
+		},
+		Errors: badRequest,
+	}
+}
+
+// importOperation загрузка файла одного вида
+func importOperation(id string, kind bulkimport.Kind, scope string) Operation {
+	return Operation{
+		Id: id, Method: http.MethodPost, Path: "/import/" + string(kind), Tag: "import", Scope: scope,
+		Summary: "Create or update " + string(kind) + " from a CSV or JSON Lines file",
+		Query: []Parameter{
+			{Name: "format", Type: "string", Enum: []string{string(bulkimport.FormatCSV), string(bulkimport.FormatJSONL)},
+				Description: "File format; taken from Content-Type when omitted"},
+			{Name: "key", Type: "string", Description: "Natural key matching rows to existing records"},
+			{Name: "mode", Type: "string", Enum: []string{string(bulkimport.ModeAllOrNothing), string(bulkimport.ModeBestEffort)}},
+			dryRun,
+		},
+		Description: mappingNote,
+		Body:        []Content{{Type: "text/csv"}, {Type: "application/x-ndjson"}},
+		Status:      http.StatusOK,
+		Result:      jsonOf(bulkimport.Summary{}),
+		Errors: map[int][]Content{
+			http.StatusBadRequest:            nil,
+			http.StatusRequestEntityTooLarge: nil,
+			http.StatusUnprocessableEntity:   jsonOf(bulkimport.Summary{}),
+		},
+	}
+}
+
+// Operations операции API в порядке документа
+var Operations = []Operation{
+	{Id: "openAPI", Method: http.MethodGet, Path: "/openapi.json", Tag: "meta", Summary: "This document",
+		Status: http.StatusOK, Result: []Content{{Type: "application/json", Schema: map[string]any{"type": "object"}}}},
+
+	{Id: "listServiceAccounts", Method: http.MethodGet, Path: "/service-accounts/", Tag: "service-accounts",
+		Scope: serviceaccount.ScopeServiceAccounts, Summary: "List service accounts",
+		Status: http.StatusOK, Result: jsonOf([]serviceaccount.Response{})},
+	{Id: "createServiceAccount", Method: http.MethodPost, Path: "/service-accounts/", Tag: "service-accounts",
+		Scope: serviceaccount.ScopeServiceAccounts, Summary: "Create a service account",
+		Body: jsonOf(serviceaccount.CreateRequest{}), Status: http.StatusCreated, Result: jsonOf(serviceaccount.Response{}),
+		Errors: map[int][]Content{http.StatusBadRequest: nil, http.StatusConflict: nil}},
+	{Id: "getServiceAccount", Method: http.MethodGet, Path: "/service-accounts/{id}", Tag: "service-accounts",
+		Scope: serviceaccount.ScopeServiceAccounts, Summary: "Get a service account",
+		Status: http.StatusOK, Result: jsonOf(serviceaccount.Response{}), Errors: byId},
+	{Id: "removeServiceAccount", Method: http.MethodDelete, Path: "/service-accounts/{id}", Tag: "service-accounts",
+		Scope: serviceaccount.ScopeServiceAccounts, Summary: "Remove a service account and its keys",
+		Status: http.StatusNoContent, Errors: byId},
+	{Id: "disableServiceAccount", Method: http.MethodPost, Path: "/service-accounts/{id}/disable", Tag: "service-accounts",
+		Scope: serviceaccount.ScopeServiceAccounts, Summary: "Disable a service account; its keys stop working",
+		Status: http.StatusOK, Result: jsonOf(serviceaccount.Response{}), Errors: byId},
+	{Id: "enableServiceAccount", Method: http.MethodPost, Path: "/service-accounts/{id}/enable", Tag: "service-accounts",
+		Scope: serviceaccount.ScopeServiceAccounts, Summary: "Enable a disabled service account",
+		Status: http.StatusOK, Result: jsonOf(serviceaccount.Response{}), Errors: byId},
+	{Id: "assignServiceAccountRole", Method: http.MethodPut, Path: "/service-accounts/{id}/roles/{roleId}", Tag: "service-accounts",
+		Scope: serviceaccount.ScopeServiceAccounts, Summary: "Assign a role to a service account",
+		Status: http.StatusNoContent, Errors: byId},
+	{Id: "revokeServiceAccountRole", Method: http.MethodDelete, Path: "/service-accounts/{id}/roles/{roleId}", Tag: "service-accounts",
+		Scope: serviceaccount.ScopeServiceAccounts, Summary: "Revoke a role from a service account",
+		Status: http.StatusNoContent, Errors: byId},
+	{Id: "listServiceAccountKeys", Method: http.MethodGet, Path: "/service-accounts/{id}/keys", Tag: "service-accounts",
+		Scope: serviceaccount.ScopeServiceAccounts, Summary: "List API keys of a service account without secrets",
+		Status: http.StatusOK, Result: jsonOf([]serviceaccount.KeyResponse{}), Errors: byId},
+	{Id: "issueServiceAccountKey", Method: http.MethodPost, Path: "/service-accounts/{id}/keys", Tag: "service-accounts",
+		Scope: serviceaccount.ScopeServiceAccounts, Summary: "Issue an API key; the secret is returned only once",
+		Body: jsonOf(serviceaccount.KeyRequest{}), Status: http.StatusCreated, Result: jsonOf(serviceaccount.IssuedKey{}),
+		Errors: byId},
+	{Id: "rotateServiceAccountKey", Method: http.MethodPost, Path: "/service-accounts/{id}/keys/{keyId}/rotate",
+		Tag: "service-accounts", Scope: serviceaccount.ScopeServiceAccounts,
+		Summary: "Issue a replacement key; the old one keeps working for the overlap period",
+		Query:   []Parameter{{Name: "overlap", Type: "string", Description: "Go duration such as 24h"}},
+		Status:  http.StatusCreated, Result: jsonOf(serviceaccount.IssuedKey{}),
+		Errors: map[int][]Content{http.StatusBadRequest: nil, http.StatusNotFound: nil, http.StatusConflict: nil}},
+	{Id: "revokeServiceAccountKey", Method: http.MethodDelete, Path: "/service-accounts/{id}/keys/{keyId}",
+		Tag: "service-accounts", Scope: serviceaccount.ScopeServiceAccounts, Summary: "Revoke an API key",
+		Status: http.StatusNoContent, Errors: byId},
+
+	{Id: "listEmployeeSessions", Method: http.MethodGet, Path: "/admin/employees/{employeeId}/sessions", Tag: "sessions",
+		Scope: serviceaccount.ScopeEmployeesWrite, Summary: "List active sessions of an employee",
+		Status: http.StatusOK, Result: jsonOf([]session.Response{}), Errors: badRequest},
+	{Id: "revokeEmployeeSessions", Method: http.MethodDelete, Path: "/admin/employees/{employeeId}/sessions", Tag: "sessions",
+		Scope: serviceaccount.ScopeEmployeesWrite, Summary: "Sign an employee out of every device",
+		Status: http.StatusOK, Result: jsonOf(struct {
+			Revoked int `json:"revoked"`
+		}{}), Errors: badRequest},
+	{Id: "revokeEmployeeSession", Method: http.MethodDelete, Path: "/admin/employees/{employeeId}/sessions/{id}",
+		Tag: "sessions", Scope: serviceaccount.ScopeEmployeesWrite, Summary: "Revoke one session of an employee",
+		Status: http.StatusNoContent, Errors: byId},
+
+	importOperation("importEmployees", bulkimport.KindEmployees, serviceaccount.ScopeEmployeesWrite),
+	importOperation("importRoles", bulkimport.KindRoles, serviceaccount.ScopeRolesWrite),
+
+	exportOperation("exportEmployees", export.KindEmployees, serviceaccount.ScopeEmployeesRead),
+	exportOperation("exportRoles", export.KindRoles, serviceaccount.ScopeRolesRead),
+	exportOperation("exportAssignments", export.KindAssignments, serviceaccount.ScopeRolesRead),
+
+	{Id: "reconcileHr", Method: http.MethodPost, Path: "/admin/hr/reconcile", Tag: "reconciliation",
+		Scope: serviceaccount.ScopeEmployeesWrite, Summary: "Compare employees with an HR snapshot and optionally fix the drift",
+		Query: []Parameter{
+			{Name: "format", Type: "string", Enum: []string{string(hrsync.FormatCSV), string(hrsync.FormatJSON)},
+				Description: "Snapshot format; taken from Content-Type when omitted"},
+			{Name: "key", Type: "string", Enum: []string{"user_name", "email"}},
+			{Name: "apply", Type: "boolean", Description: "Fix the drift instead of only reporting it"},
+			{Name: "max_deletes", Type: "integer", Description: "Extra employees a run may terminate"},
+			{Name: "force", Type: "boolean", Description: "Lift the max_deletes guard"},
+		},
+		Description: mappingNote,
+		Body:        []Content{{Type: "text/csv"}, {Type: "application/json"}},
+		Status:      http.StatusOK, Result: jsonOf(hrsync.Report{}),
+		Errors: map[int][]Content{
+			http.StatusBadRequest:            nil,
+			http.StatusRequestEntityTooLarge: nil,
+			http.StatusConflict: jsonOf(struct {
+				Error  string        `json:"error"`
+				Report hrsync.Report `json:"report"`
+			}{}),
+		}},
+
+	{Id: "syncLdap", Method: http.MethodPost, Path: "/admin/ldap/sync", Tag: "reconciliation",
+		Scope: serviceaccount.ScopeEmployeesWrite, Summary: "Synchronize employees and roles with the LDAP directory, if configured",
+		Query: []Parameter{dryRun, {Name: "incremental", Type: "boolean",
+			Description: "Only entries changed since the previous run; removals are not detected"}},
+		Status: http.StatusOK, Result: jsonOf(ldapsync.Diff{}),
+		Errors: map[int][]Content{
+			http.StatusBadRequest: nil,
+			http.StatusConflict: jsonOf(struct {
+				Error string        `json:"error"`
+				Diff  ldapsync.Diff `json:"diff"`
+			}{}),
+			http.StatusBadGateway: jsonOf(struct {
+				Error string        `json:"error"`
+				Diff  ldapsync.Diff `json:"diff"`
+			}{}),
+		}},
+
+	{Id: "listConnectors", Method: http.MethodGet, Path: "/admin/provisioning/", Tag: "reconciliation",
+		Scope: serviceaccount.ScopeProvisioning, Summary: "List provisioning connectors with their last reconciliation",
+		Status: http.StatusOK, Result: jsonOf([]provisioning.Status{})},
+	{Id: "reconcileConnector", Method: http.MethodPost, Path: "/admin/provisioning/{name}/reconcile", Tag: "reconciliation",
+		Scope: serviceaccount.ScopeProvisioning, Summary: "Reconcile a target system with the accounts IDM expects there",
+		Query:  []Parameter{dryRun},
+		Status: http.StatusOK, Result: jsonOf(provisioning.Report{}),
+		Errors: map[int][]Content{
+			http.StatusBadRequest: nil,
+			http.StatusNotFound:   nil,
+			http.StatusConflict: jsonOf(struct {
+				Error  string              `json:"error"`
+				Report provisioning.Report `json:"report"`
+			}{}),
+			http.StatusBadGateway: jsonOf(struct {
+				Error  string              `json:"error"`
+				Report provisioning.Report `json:"report"`
+			}{}),
+		}},
+
+	{Id: "listDepartments", Method: http.MethodGet, Path: "/org/departments", Tag: "org",
+		Scope: serviceaccount.ScopeEmployeesRead, Summary: "List departments",
+		Status: http.StatusOK, Result: jsonOf([]org.Response{})},
+	{Id: "createDepartment", Method: http.MethodPost, Path: "/org/departments", Tag: "org",
+		Scope: serviceaccount.ScopeEmployeesWrite, Summary: "Create a department",
+		Body: jsonOf(org.Request{}), Status: http.StatusCreated, Result: jsonOf(org.Response{}), Errors: conflict},
+	{Id: "getDepartment", Method: http.MethodGet, Path: "/org/departments/{id}", Tag: "org",
+		Scope: serviceaccount.ScopeEmployeesRead, Summary: "Get a department",
+		Status: http.StatusOK, Result: jsonOf(org.Response{}), Errors: byId},
+	{Id: "updateDepartment", Method: http.MethodPut, Path: "/org/departments/{id}", Tag: "org",
+		Scope:   serviceaccount.ScopeEmployeesWrite,
+		Summary: "Rename, move or change the head of a department; it cannot move under its own subdepartment",
+		Body:    jsonOf(org.Request{}), Status: http.StatusOK, Result: jsonOf(org.Response{}), Errors: conflict},
+	{Id: "removeDepartment", Method: http.MethodDelete, Path: "/org/departments/{id}", Tag: "org",
+		Scope: serviceaccount.ScopeEmployeesWrite, Summary: "Remove a department without subdepartments and members",
+		Status: http.StatusNoContent, Errors: conflict},
+	{Id: "listDepartmentMembers", Method: http.MethodGet, Path: "/org/departments/{id}/members", Tag: "org",
+		Scope: serviceaccount.ScopeEmployeesRead, Summary: "List employees of a department",
+		Query:  []Parameter{{Name: "recursive", Type: "boolean", Description: "Include employees of all subdepartments"}},
+		Status: http.StatusOK, Result: jsonOf([]org.MemberResponse{}), Errors: byId},
+	{Id: "assignDepartmentMembers", Method: http.MethodPut, Path: "/org/departments/{id}/members", Tag: "org",
+		Scope:   serviceaccount.ScopeEmployeesWrite,
+		Summary: "Move employees to a department; nobody is moved if any of them does not exist",
+		Body:    jsonOf(org.MembersRequest{}), Status: http.StatusNoContent, Errors: byId},
+	{Id: "removeDepartmentMember", Method: http.MethodDelete, Path: "/org/departments/{id}/members/{employeeId}",
+		Tag: "org", Scope: serviceaccount.ScopeEmployeesWrite, Summary: "Take an employee out of a department",
+		Status: http.StatusNoContent, Errors: byId},
+	{Id: "listHeadcounts", Method: http.MethodGet, Path: "/org/headcounts", Tag: "org",
+		Scope: serviceaccount.ScopeEmployeesRead, Summary: "Active employees per department, with and without subdepartments",
+		Status: http.StatusOK, Result: jsonOf([]org.HeadcountResponse{})},
+	{Id: "listEmployeeReports", Method: http.MethodGet, Path: "/org/employees/{id}/reports", Tag: "org",
+		Scope: serviceaccount.ScopeEmployeesRead, Summary: "Everyone reporting to an employee, nearest levels first",
+		Query:  []Parameter{{Name: "direct", Type: "boolean", Description: "Only direct reports"}},
+		Status: http.StatusOK, Result: jsonOf([]org.MemberResponse{}), Errors: byId},
+	{Id: "getEmployeeChain", Method: http.MethodGet, Path: "/org/employees/{id}/chain", Tag: "org",
+		Scope: serviceaccount.ScopeEmployeesRead, Summary: "Management chain of an employee from the direct manager up",
+		Status: http.StatusOK, Result: jsonOf([]org.MemberResponse{}), Errors: byId},
+	{Id: "getOrgChart", Method: http.MethodGet, Path: "/org/chart", Tag: "org",
+		Scope: serviceaccount.ScopeEmployeesRead, Summary: "Org chart as a JSON tree or a Graphviz DOT graph",
+		Query: []Parameter{
+			{Name: "format", Type: "string", Enum: []string{"json", "dot"}, Description: "json by default"},
+			{Name: "root", Type: "integer", Description: "Only the subtree of this department"},
+			{Name: "members", Type: "boolean", Description: "Include active employees and their reporting lines"},
+		},
+		Status: http.StatusOK, Result: append(jsonOf(org.Chart{}), Content{Type: org.ContentTypeDOT}),
+		Errors: byId},
+
+	{Id: "listGroups", Method: http.MethodGet, Path: "/groups/", Tag: "groups",
+		Scope: serviceaccount.ScopeRolesRead, Summary: "List groups",
+		Query: []Parameter{{Name: "employee_id", Type: "integer",
+			Description: "Only groups the employee belongs to directly, by rule or through nested groups"}},
+		Status: http.StatusOK, Result: jsonOf([]group.Response{}), Errors: byId},
+	{Id: "createGroup", Method: http.MethodPost, Path: "/groups/", Tag: "groups",
+		Scope: serviceaccount.ScopeRolesWrite, Summary: "Create a group; rule is a SCIM filter over employee attributes",
+		Body: jsonOf(group.Request{}), Status: http.StatusCreated, Result: jsonOf(group.Response{}),
+		Errors: map[int][]Content{http.StatusBadRequest: nil, http.StatusConflict: nil}},
+	{Id: "evaluateGroups", Method: http.MethodPost, Path: "/groups/evaluate", Tag: "groups",
+		Scope: serviceaccount.ScopeRolesWrite, Summary: "Bring roles inherited from groups in line with current membership",
+		Status: http.StatusOK, Result: jsonOf([]group.DiffResponse{})},
+	{Id: "getGroup", Method: http.MethodGet, Path: "/groups/{id}", Tag: "groups",
+		Scope: serviceaccount.ScopeRolesRead, Summary: "Get a group",
+		Status: http.StatusOK, Result: jsonOf(group.Response{}), Errors: byId},
+	{Id: "updateGroup", Method: http.MethodPut, Path: "/groups/{id}", Tag: "groups",
+		Scope: serviceaccount.ScopeRolesWrite, Summary: "Change a group; a new rule re-evaluates inherited roles",
+		Body: jsonOf(group.Request{}), Status: http.StatusOK, Result: jsonOf(group.Response{}), Errors: conflict},
+	{Id: "removeGroup", Method: http.MethodDelete, Path: "/groups/{id}", Tag: "groups",
+		Scope: serviceaccount.ScopeRolesWrite, Summary: "Remove a group and the roles its members inherited from it",
+		Status: http.StatusNoContent, Errors: byId},
+	{Id: "listGroupMembers", Method: http.MethodGet, Path: "/groups/{id}/members", Tag: "groups",
+		Scope: serviceaccount.ScopeRolesRead, Summary: "Effective members of a group and how each of them belongs to it",
+		Status: http.StatusOK, Result: jsonOf([]group.MemberResponse{}), Errors: byId},
+	{Id: "addGroupMember", Method: http.MethodPut, Path: "/groups/{id}/members/{employeeId}", Tag: "groups",
+		Scope: serviceaccount.ScopeRolesWrite, Summary: "Add an employee to a group",
+		Status: http.StatusNoContent, Errors: byId},
+	{Id: "removeGroupMember", Method: http.MethodDelete, Path: "/groups/{id}/members/{employeeId}", Tag: "groups",
+		Scope: serviceaccount.ScopeRolesWrite, Summary: "Remove an explicitly added employee from a group",
+		Status: http.StatusNoContent, Errors: byId},
+	{Id: "addSubgroup", Method: http.MethodPut, Path: "/groups/{id}/subgroups/{subgroupId}", Tag: "groups",
+		Scope: serviceaccount.ScopeRolesWrite, Summary: "Nest a group; it may not already contain the parent group",
+		Status: http.StatusNoContent, Errors: conflict},
+	{Id: "removeSubgroup", Method: http.MethodDelete, Path: "/groups/{id}/subgroups/{subgroupId}", Tag: "groups",
+		Scope: serviceaccount.ScopeRolesWrite, Summary: "Take a nested group out of a group",
+		Status: http.StatusNoContent, Errors: byId},
+	{Id: "grantGroupRole", Method: http.MethodPut, Path: "/groups/{id}/roles/{roleId}", Tag: "groups",
+		Scope: serviceaccount.ScopeRolesWrite, Summary: "Grant a role to every member of a group",
+		Status: http.StatusNoContent, Errors: byId},
+	{Id: "revokeGroupRole", Method: http.MethodDelete, Path: "/groups/{id}/roles/{roleId}", Tag: "groups",
+		Scope: serviceaccount.ScopeRolesWrite, Summary: "Revoke a role from a group and from members holding it through the group",
+		Status: http.StatusNoContent, Errors: byId},
+
+	{Id: "listWebhooks", Method: http.MethodGet, Path: "/webhooks/", Tag: "webhooks",
+		Scope: serviceaccount.ScopeWebhooks, Summary: "List webhook subscriptions",
+		Status: http.StatusOK, Result: jsonOf([]webhook.Response{})},
+	{Id: "createWebhook", Method: http.MethodPost, Path: "/webhooks/", Tag: "webhooks",
+		Scope: serviceaccount.ScopeWebhooks, Summary: "Subscribe a URL to events; the signing secret is returned only once",
+		Body: jsonOf(webhook.Request{}), Status: http.StatusCreated, Result: jsonOf(webhook.CreatedResponse{}),
+		Errors: badRequest},
+	{Id: "getWebhook", Method: http.MethodGet, Path: "/webhooks/{id}", Tag: "webhooks",
+		Scope: serviceaccount.ScopeWebhooks, Summary: "Get a webhook subscription",
+		Status: http.StatusOK, Result: jsonOf(webhook.Response{}), Errors: byId},
+	{Id: "updateWebhook", Method: http.MethodPut, Path: "/webhooks/{id}", Tag: "webhooks",
+		Scope: serviceaccount.ScopeWebhooks, Summary: "Change a webhook subscription",
+		Body: jsonOf(webhook.Request{}), Status: http.StatusOK, Result: jsonOf(webhook.Response{}), Errors: byId},
+	{Id: "removeWebhook", Method: http.MethodDelete, Path: "/webhooks/{id}", Tag: "webhooks",
+		Scope: serviceaccount.ScopeWebhooks, Summary: "Remove a webhook subscription",
+		Status: http.StatusNoContent, Errors: byId},
+	{Id: "rotateWebhookSecret", Method: http.MethodPost, Path: "/webhooks/{id}/secret", Tag: "webhooks",
+		Scope: serviceaccount.ScopeWebhooks, Summary: "Replace the signing secret of a subscription",
+		Status: http.StatusOK, Result: jsonOf(webhook.CreatedResponse{}), Errors: byId},
+	{Id: "listWebhookDeliveries", Method: http.MethodGet, Path: "/webhooks/{id}/deliveries", Tag: "webhooks",
+		Scope: serviceaccount.ScopeWebhooks, Summary: "Delivery log of a subscription, newest first",
+		Query: []Parameter{
+			{Name: "status", Type: "string", Enum: []string{webhook.StatusPending, webhook.StatusDelivered, webhook.StatusDead}},
+			{Name: "before", Type: "integer", Description: "Only deliveries with a smaller id"},
+			{Name: "limit", Type: "integer"},
+		},
+		Status: http.StatusOK, Result: jsonOf([]webhook.DeliveryResponse{}), Errors: byId},
+	{Id: "redeliverWebhookDelivery", Method: http.MethodPost, Path: "/webhooks/{id}/deliveries/{deliveryId}/redeliver",
+		Tag: "webhooks", Scope: serviceaccount.ScopeWebhooks, Summary: "Queue a new attempt of a delivery",
+		Status: http.StatusAccepted, Result: jsonOf(webhook.DeliveryResponse{}), Errors: byId},
+
+	{Id: "graphQL", Method: http.MethodPost, Path: "/graphql", Tag: "graphql",
+		Scope: serviceaccount.ScopeEmployeesRead, Summary: "Run a GraphQL query or mutation",
+		Body: jsonOf(GraphQLRequest{}), Status: http.StatusOK, Result: jsonOf(GraphQLResponse{}),
+		Errors: map[int][]Content{http.StatusBadRequest: jsonOf(GraphQLResponse{})}},
+	{Id: "graphQLSchema", Method: http.MethodGet, Path: "/graphql", Tag: "graphql",
+		Scope: serviceaccount.ScopeEmployeesRead, Summary: "GraphQL schema in SDL",
+		Status: http.StatusOK, Result: []Content{{Type: "text/plain"}}},
+
+	{Id: "getScimServiceProviderConfig", Method: http.MethodGet, Path: "/scim/v2/ServiceProviderConfig", Tag: "scim",
+		Scope: serviceaccount.ScopeSCIM, Summary: "SCIM features the service supports",
+		Status: http.StatusOK, Result: scimObject, Errors: scimErrors()},
+	{Id: "listScimSchemas", Method: http.MethodGet, Path: "/scim/v2/Schemas", Tag: "scim",
+		Scope: serviceaccount.ScopeSCIM, Summary: "SCIM schemas of users and groups",
+		Status: http.StatusOK, Result: scimObject, Errors: scimErrors()},
+	{Id: "listScimResourceTypes", Method: http.MethodGet, Path: "/scim/v2/ResourceTypes", Tag: "scim",
+		Scope: serviceaccount.ScopeSCIM, Summary: "SCIM resource types",
+		Status: http.StatusOK, Result: scimObject, Errors: scimErrors()},
+	{Id: "listScimUsers", Method: http.MethodGet, Path: "/scim/v2/Users", Tag: "scim",
+		Scope: serviceaccount.ScopeSCIM, Summary: "List employees as SCIM users",
+		Query: scimList, Status: http.StatusOK, Result: scimOf(EmployeeList{}), Errors: scimErrors(http.StatusBadRequest)},
+	{Id: "createScimUser", Method: http.MethodPost, Path: "/scim/v2/Users", Tag: "scim",
+		Scope: serviceaccount.ScopeSCIM, Summary: "Create an employee; userName must be unique",
+		Body: scimOf(scim.User{}), Status: http.StatusCreated, Result: scimOf(scim.User{}),
+		Errors: scimErrors(http.StatusBadRequest, http.StatusConflict)},
+	{Id: "getScimUser", Method: http.MethodGet, Path: "/scim/v2/Users/{id}", Tag: "scim",
+		Scope: serviceaccount.ScopeSCIM, Summary: "Get an employee with its roles as groups",
+		Query: scimAttributes, Status: http.StatusOK, Result: scimOf(scim.User{}), Errors: scimErrors(http.StatusNotFound)},
+	{Id: "replaceScimUser", Method: http.MethodPut, Path: "/scim/v2/Users/{id}", Tag: "scim",
+		Scope: serviceaccount.ScopeSCIM, Summary: "Replace an employee",
+		Headers: ifMatch, Body: scimOf(scim.User{}), Status: http.StatusOK, Result: scimOf(scim.User{}),
+		Errors: scimErrors(http.StatusBadRequest, http.StatusNotFound, http.StatusConflict, http.StatusPreconditionFailed)},
+	{Id: "patchScimUser", Method: http.MethodPatch, Path: "/scim/v2/Users/{id}", Tag: "scim",
+		Scope: serviceaccount.ScopeSCIM, Summary: "Change attributes of an employee with SCIM patch operations",
+		Headers: ifMatch, Body: scimOf(scim.PatchRequest{}), Status: http.StatusOK, Result: scimOf(scim.User{}),
+		Errors: scimErrors(http.StatusBadRequest, http.StatusNotFound, http.StatusConflict, http.StatusPreconditionFailed)},
+	{Id: "deleteScimUser", Method: http.MethodDelete, Path: "/scim/v2/Users/{id}", Tag: "scim",
+		Scope: serviceaccount.ScopeSCIM, Summary: "Remove an employee",
+		Headers: ifMatch, Status: http.StatusNoContent,
+		Errors: scimErrors(http.StatusNotFound, http.StatusPreconditionFailed)},
+	{Id: "listScimGroups", Method: http.MethodGet, Path: "/scim/v2/Groups", Tag: "scim",
+		Scope: serviceaccount.ScopeSCIM, Summary: "List roles as SCIM groups",
+		Query: scimList, Status: http.StatusOK, Result: scimOf(RoleList{}), Errors: scimErrors(http.StatusBadRequest)},
+	{Id: "createScimGroup", Method: http.MethodPost, Path: "/scim/v2/Groups", Tag: "scim",
+		Scope: serviceaccount.ScopeSCIM, Summary: "Create a role and assign it to the members",
+		Body: scimOf(scim.Group{}), Status: http.StatusCreated, Result: scimOf(scim.Group{}),
+		Errors: scimErrors(http.StatusBadRequest, http.StatusConflict)},
+	{Id: "getScimGroup", Method: http.MethodGet, Path: "/scim/v2/Groups/{id}", Tag: "scim",
+		Scope: serviceaccount.ScopeSCIM, Summary: "Get a role with the employees holding it",
+		Query: scimAttributes, Status: http.StatusOK, Result: scimOf(scim.Group{}), Errors: scimErrors(http.StatusNotFound)},
+	{Id: "replaceScimGroup", Method: http.MethodPut, Path: "/scim/v2/Groups/{id}", Tag: "scim",
+		Scope: serviceaccount.ScopeSCIM, Summary: "Rename a role and replace its members",
+		Headers: ifMatch, Body: scimOf(scim.Group{}), Status: http.StatusOK, Result: scimOf(scim.Group{}),
+		Errors: scimErrors(http.StatusBadRequest, http.StatusNotFound, http.StatusConflict, http.StatusPreconditionFailed)},
+	{Id: "patchScimGroup", Method: http.MethodPatch, Path: "/scim/v2/Groups/{id}", Tag: "scim",
+		Scope: serviceaccount.ScopeSCIM, Summary: "Change a role or add and remove members with SCIM patch operations",
+		Headers: ifMatch, Body: scimOf(scim.PatchRequest{}), Status: http.StatusOK, Result: scimOf(scim.Group{}),
+		Errors: scimErrors(http.StatusBadRequest, http.StatusNotFound, http.StatusConflict, http.StatusPreconditionFailed)},
+	{Id: "deleteScimGroup", Method: http.MethodDelete, Path: "/scim/v2/Groups/{id}", Tag: "scim",
+		Scope: serviceaccount.ScopeSCIM, Summary: "Remove a role",
+		Headers: ifMatch, Status: http.StatusNoContent,
+		Errors: scimErrors(http.StatusNotFound, http.StatusPreconditionFailed)},
+	{Id: "scimBulk", Method: http.MethodPost, Path: "/scim/v2/Bulk", Tag: "scim",
+		Scope: serviceaccount.ScopeSCIM, Summary: "Run several SCIM operations; later ones may refer to bulkId of earlier ones",
+		Body: scimOf(scim.BulkRequest{}), Status: http.StatusOK, Result: scimOf(scim.BulkResponse{}),
+		Errors: scimErrors(http.StatusBadRequest, http.StatusRequestEntityTooLarge)},
+
+	{Id: "adminGetAccessRequest", Method: http.MethodGet, Path: "/admin/access-requests/{id}", Tag: "access-requests",
+		Scope: serviceaccount.ScopeRolesRead, Summary: "Get any access request with its history",
+		Status: http.StatusOK, Result: jsonOf(workflow.RequestResponse{}), Errors: byId},
+	{Id: "retryAccessRequest", Method: http.MethodPost, Path: "/admin/access-requests/{id}/retry", Tag: "access-requests",
+		Scope: serviceaccount.ScopeRolesWrite, Summary: "Grant the role of an approved request again after a failed grant",
+		Description: "A grant that can never succeed, such as one that violates separation of duties, " +
+			"ends the request in the failed status instead, and it cannot be retried.",
+		Status: http.StatusOK, Result: jsonOf(workflow.RequestResponse{}), Errors: conflict},
+
+	{Id: "listCampaigns", Method: http.MethodGet, Path: "/admin/certifications/", Tag: "certifications",
+		Scope: serviceaccount.ScopeRolesRead, Summary: "List certification campaigns with their progress",
+		Status: http.StatusOK, Result: jsonOf([]certification.CampaignResponse{})},
+	{Id: "createCampaign", Method: http.MethodPost, Path: "/admin/certifications/", Tag: "certifications",
+		Scope:   serviceaccount.ScopeRolesWrite,
+		Summary: "Start a campaign reviewing assignments of the roles or departments; managers review their reports",
+		Body:    jsonOf(certification.CampaignRequest{}), Status: http.StatusCreated,
+		Result: jsonOf(certification.CampaignResponse{}), Errors: badRequest},
+	{Id: "getCampaign", Method: http.MethodGet, Path: "/admin/certifications/{id}", Tag: "certifications",
+		Scope: serviceaccount.ScopeRolesRead, Summary: "Get a campaign with all its items",
+		Status: http.StatusOK, Result: jsonOf(certification.CampaignResponse{}), Errors: byId},
+	{Id: "closeCampaign", Method: http.MethodPost, Path: "/admin/certifications/{id}/close", Tag: "certifications",
+		Scope: serviceaccount.ScopeRolesWrite, Summary: "Close a campaign early; undecided items are revoked",
+		Status: http.StatusOK, Result: jsonOf(certification.CampaignResponse{}), Errors: conflict},
+	{Id: "exportCampaign", Method: http.MethodGet, Path: "/admin/certifications/{id}/export", Tag: "certifications",
+		Scope: serviceaccount.ScopeRolesRead, Summary: "Download the decisions of a campaign for auditors",
+		Query: []Parameter{{Name: "format", Type: "string", Enum: []string{string(certification.FormatCSV),
+			string(certification.FormatJSON)}, Description: "csv by default"}},
+		Status: http.StatusOK, Result: []Content{{Type: "text/csv"}, {Type: "application/json"}}, Errors: byId},
+
+	{Id: "hireEmployee", Method: http.MethodPost, Path: "/admin/lifecycle/hire", Tag: "lifecycle",
+		Scope:   serviceaccount.ScopeEmployeesWrite,
+		Summary: "Hire an employee now or on effective_at; birthright roles are granted on that date",
+		Body:    jsonOf(lifecycle.HireChange{}), Status: http.StatusCreated, Result: jsonOf(lifecycle.ChangeResponse{}),
+		Errors: badRequest},
+	{Id: "listEmployeeChanges", Method: http.MethodGet, Path: "/admin/lifecycle/employees/{id}", Tag: "lifecycle",
+		Scope: serviceaccount.ScopeEmployeesRead, Summary: "Lifecycle changes of an employee, including scheduled ones",
+		Status: http.StatusOK, Result: jsonOf([]lifecycle.ChangeResponse{}), Errors: badRequest},
+	{Id: "transferEmployee", Method: http.MethodPost, Path: "/admin/lifecycle/employees/{id}/transfer", Tag: "lifecycle",
+		Scope:   serviceaccount.ScopeEmployeesWrite,
+		Summary: "Move an employee to another department or title; birthright roles follow the new attributes",
+		Body:    jsonOf(lifecycle.TransferChange{}), Status: http.StatusCreated, Result: jsonOf(lifecycle.ChangeResponse{}),
+		Errors: conflict},
+	{Id: "terminateEmployee", Method: http.MethodPost, Path: "/admin/lifecycle/employees/{id}/terminate", Tag: "lifecycle",
+		Scope: serviceaccount.ScopeEmployeesWrite, Summary: "Disable an employee and revoke all roles now or on effective_at",
+		Body: jsonOf(lifecycle.TerminateChange{}), Status: http.StatusCreated, Result: jsonOf(lifecycle.ChangeResponse{}),
+		Errors: conflict},
+	{Id: "getLifecycleChange", Method: http.MethodGet, Path: "/admin/lifecycle/{id}", Tag: "lifecycle",
+		Scope: serviceaccount.ScopeEmployeesRead, Summary: "Get a lifecycle change",
+		Status: http.StatusOK, Result: jsonOf(lifecycle.ChangeResponse{}), Errors: byId},
+	{Id: "cancelLifecycleChange", Method: http.MethodPost, Path: "/admin/lifecycle/{id}/cancel", Tag: "lifecycle",
+		Scope: serviceaccount.ScopeEmployeesWrite, Summary: "Cancel a change that has not been applied yet",
+		Status: http.StatusNoContent, Errors: conflict},
+
+	{Id: "listSodRules", Method: http.MethodGet, Path: "/admin/sod/rules", Tag: "sod",
+		Scope: serviceaccount.ScopeRolesRead, Summary: "List separation of duties rules",
+		Status: http.StatusOK, Result: jsonOf([]sod.RuleResponse{})},
+	{Id: "createSodRule", Method: http.MethodPost, Path: "/admin/sod/rules", Tag: "sod",
+		Scope:   serviceaccount.ScopeRolesWrite,
+		Summary: "Forbid holding more than max_roles of the roles at once; without max_roles the roles are exclusive",
+		Body:    jsonOf(sod.RuleRequest{}), Status: http.StatusCreated, Result: jsonOf(sod.RuleResponse{}),
+		Errors: badRequest},
+	{Id: "getSodRule", Method: http.MethodGet, Path: "/admin/sod/rules/{id}", Tag: "sod",
+		Scope: serviceaccount.ScopeRolesRead, Summary: "Get a separation of duties rule",
+		Status: http.StatusOK, Result: jsonOf(sod.RuleResponse{}), Errors: byId},
+	{Id: "removeSodRule", Method: http.MethodDelete, Path: "/admin/sod/rules/{id}", Tag: "sod",
+		Scope: serviceaccount.ScopeRolesWrite, Summary: "Remove a rule with its exceptions",
+		Status: http.StatusNoContent, Errors: byId},
+	{Id: "grantSodException", Method: http.MethodPost, Path: "/admin/sod/rules/{id}/exceptions", Tag: "sod",
+		Scope: serviceaccount.ScopeRolesWrite, Summary: "Allow an employee to violate the rule until expires_at",
+		Body: jsonOf(sod.ExceptionRequest{}), Status: http.StatusCreated, Result: jsonOf(sod.ExceptionResponse{}),
+		Errors: byId},
+	{Id: "listSodViolations", Method: http.MethodGet, Path: "/admin/sod/violations", Tag: "sod",
+		Scope: serviceaccount.ScopeRolesRead, Summary: "Employees holding more roles of a rule than it allows, including excepted ones",
+		Status: http.StatusOK, Result: jsonOf([]sod.ViolationResponse{})},
+
+	{Id: "login", Method: http.MethodPost, Path: "/login/", Tag: "login",
+		Summary:     "Sign in with a user name and password",
+		Description: "Returns status authenticated and sets the session cookie, or a token and the second factor methods to continue with.",
+		Body:        jsonOf(login.CredentialsRequest{}), Status: http.StatusOK, Result: jsonOf(login.Result{}), Errors: loginErrors},
+	{Id: "verifyLoginTOTP", Method: http.MethodPost, Path: "/login/totp", Tag: "login",
+		Summary: "Finish sign-in with a TOTP code", Headers: loginToken,
+		Body: jsonOf(login.CodeRequest{}), Status: http.StatusOK, Result: jsonOf(login.Result{}), Errors: loginErrors},
+	{Id: "useLoginRecoveryCode", Method: http.MethodPost, Path: "/login/recovery", Tag: "login",
+		Summary: "Finish sign-in with a one-time recovery code", Headers: loginToken,
+		Body: jsonOf(login.CodeRequest{}), Status: http.StatusOK, Result: jsonOf(login.Result{}), Errors: loginErrors},
+	{Id: "beginLoginWebAuthn", Method: http.MethodPost, Path: "/login/webauthn/begin", Tag: "login",
+		Summary: "Start a WebAuthn assertion for the second factor", Headers: loginToken,
+		Status: http.StatusOK, Result: webAuthnOptions, Errors: loginErrors},
+	{Id: "finishLoginWebAuthn", Method: http.MethodPost, Path: "/login/webauthn/finish", Tag: "login",
+		Summary: "Finish sign-in with the WebAuthn assertion", Headers: loginToken,
+		Body: webAuthnCredential, Status: http.StatusOK, Result: jsonOf(login.Result{}), Errors: loginErrors},
+	{Id: "enrollLoginTOTP", Method: http.MethodPost, Path: "/login/enroll/totp", Tag: "login",
+		Summary: "Generate a TOTP secret for an employee who must set up a second factor", Headers: loginToken,
+		Status: http.StatusOK, Result: jsonOf(mfa.TOTPEnrollment{}), Errors: loginErrors},
+	{Id: "confirmLoginTOTP", Method: http.MethodPost, Path: "/login/enroll/totp/confirm", Tag: "login",
+		Summary: "Confirm the TOTP secret with a code; recovery codes are returned only once", Headers: loginToken,
+		Body: jsonOf(login.CodeRequest{}), Status: http.StatusOK, Result: jsonOf(login.Result{}), Errors: loginErrors},
+	{Id: "enrollLoginWebAuthn", Method: http.MethodPost, Path: "/login/enroll/webauthn", Tag: "login",
+		Summary: "Start registering a security key as the second factor", Headers: loginToken,
+		Status: http.StatusOK, Result: webAuthnOptions, Errors: loginErrors},
+	{Id: "confirmLoginWebAuthn", Method: http.MethodPost, Path: "/login/enroll/webauthn/confirm", Tag: "login",
+		Summary: "Finish registering a security key", Headers: loginToken,
+		Query: []Parameter{{Name: "name", Type: "string", Description: "Name of the key shown to the employee"}},
+		Body:  webAuthnCredential, Status: http.StatusOK, Result: jsonOf(login.Result{}), Errors: loginErrors},
+
+	{Id: "listSessions", Method: http.MethodGet, Path: "/sessions/", Tag: "sessions",
+		Session: true, Summary: "Active sessions of the signed-in employee; current marks this browser",
+		Status: http.StatusOK, Result: jsonOf([]session.Response{})},
+	{Id: "revokeSessions", Method: http.MethodDelete, Path: "/sessions/", Tag: "sessions",
+		Session: true, Summary: "Sign out of every device",
+		Status: http.StatusNoContent},
+	{Id: "revokeSession", Method: http.MethodDelete, Path: "/sessions/{id}", Tag: "sessions",
+		Session: true, Summary: "Sign out of one device",
+		Status: http.StatusNoContent, Errors: byId},
+	{Id: "logout", Method: http.MethodPost, Path: "/sessions/logout", Tag: "sessions",
+		Summary: "End the session of this browser, if any, and clear the cookie",
+		Status:  http.StatusNoContent},
+
+	{Id: "listAccessRequests", Method: http.MethodGet, Path: "/access-requests/", Tag: "access-requests",
+		Session: true, Summary: "Access requests of the signed-in employee",
+		Status: http.StatusOK, Result: jsonOf([]workflow.RequestResponse{})},
+	{Id: "submitAccessRequest", Method: http.MethodPost, Path: "/access-requests/", Tag: "access-requests",
+		Session: true, Summary: "Request a role; approvers of the first step are notified",
+		Body: jsonOf(workflow.SubmitRequest{}), Status: http.StatusCreated, Result: jsonOf(workflow.RequestResponse{}),
+		Errors: conflict},
+	{Id: "getAccessRequest", Method: http.MethodGet, Path: "/access-requests/{id}", Tag: "access-requests",
+		Session: true, Summary: "Get a request the employee submitted or approves",
+		Status: http.StatusOK, Result: jsonOf(workflow.RequestResponse{}), Errors: byId},
+	{Id: "listAccessRequestApprovers", Method: http.MethodGet, Path: "/access-requests/{id}/approvers",
+		Tag: "access-requests", Session: true, Summary: "Employees who may decide the current step",
+		Status: http.StatusOK, Result: jsonOf([]int64{}), Errors: byId},
+	{Id: "approveAccessRequest", Method: http.MethodPost, Path: "/access-requests/{id}/approve", Tag: "access-requests",
+		Session: true, Summary: "Approve the current step; the role is granted after the last one",
+		Body: jsonOf(workflow.DecisionRequest{}), Status: http.StatusOK, Result: jsonOf(workflow.RequestResponse{}),
+		Errors: decision},
+	{Id: "rejectAccessRequest", Method: http.MethodPost, Path: "/access-requests/{id}/reject", Tag: "access-requests",
+		Session: true, Summary: "Reject a request",
+		Body: jsonOf(workflow.DecisionRequest{}), Status: http.StatusOK, Result: jsonOf(workflow.RequestResponse{}),
+		Errors: decision},
+	{Id: "cancelAccessRequest", Method: http.MethodPost, Path: "/access-requests/{id}/cancel", Tag: "access-requests",
+		Session: true, Summary: "Withdraw an own pending request",
+		Status: http.StatusOK, Result: jsonOf(workflow.RequestResponse{}), Errors: decision},
+
+	{Id: "listCertificationItems", Method: http.MethodGet, Path: "/certifications/items", Tag: "certifications",
+		Session: true, Summary: "Assignments waiting for a decision of the signed-in reviewer",
+		Status: http.StatusOK, Result: jsonOf([]certification.ItemResponse{})},
+	{Id: "certifyCertificationItem", Method: http.MethodPost, Path: "/certifications/items/{id}/certify",
+		Tag: "certifications", Session: true, Summary: "Keep the assignment",
+		Body: jsonOf(certification.DecisionRequest{}), Status: http.StatusOK, Result: jsonOf(certification.ItemResponse{}),
+		Errors: decision},
+	{Id: "revokeCertificationItem", Method: http.MethodPost, Path: "/certifications/items/{id}/revoke",
+		Tag: "certifications", Session: true, Summary: "Revoke the role from the employee",
+		Body: jsonOf(certification.DecisionRequest{}), Status: http.StatusOK, Result: jsonOf(certification.ItemResponse{}),
+		Errors: decision},
+
+	{Id: "oidcDiscovery", Method: http.MethodGet, Path: "/oidc/.well-known/openid-configuration", Tag: "oidc",
+		Summary: "OpenID Provider metadata", Status: http.StatusOK, Result: jsonOf(oidc.Discovery{})},
+	{Id: "oidcJWKS", Method: http.MethodGet, Path: "/oidc/jwks", Tag: "oidc",
+		Summary: "Public keys verifying ID tokens and access tokens",
+		Status:  http.StatusOK, Result: jsonOf(oidc.JWKSet{}), Errors: oauthError},
+	{Id: "oidcAuthorize", Method: http.MethodGet, Path: "/oidc/authorize", Tag: "oidc",
+		Summary:     "Authorization code flow with PKCE for the employee signed in through /login",
+		Description: "Redirects to redirect_uri with code and state, or with error and error_description.",
+		Query:       authorizeQuery, Status: http.StatusFound, Errors: authorizeErrors},
+	{Id: "oidcAuthorizeForm", Method: http.MethodPost, Path: "/oidc/authorize", Tag: "oidc",
+		Summary:     "Authorization request sent as a form",
+		Description: "Redirects to redirect_uri with code and state, or with error and error_description.",
+		Body:        []Content{{Type: formType, Value: AuthorizeForm{}}}, Status: http.StatusFound, Errors: authorizeErrors},
+	{Id: "oidcToken", Method: http.MethodPost, Path: "/oidc/token", Tag: "oidc",
+		Summary:     "Exchange an authorization code or a refresh token",
+		Description: "Clients authenticate with client_secret_basic or client_secret_post; public clients send only client_id.",
+		Body:        []Content{{Type: formType, Value: TokenForm{}}}, Status: http.StatusOK, Result: jsonOf(oidc.TokenResponse{}),
+		Errors: oauthError},
+	{Id: "oidcRevoke", Method: http.MethodPost, Path: "/oidc/revoke", Tag: "oidc",
+		Summary: "Revoke a refresh token or an access token",
+		Body:    []Content{{Type: formType, Value: TokenActionForm{}}}, Status: http.StatusOK, Errors: oauthError},
+	{Id: "oidcIntrospect", Method: http.MethodPost, Path: "/oidc/introspect", Tag: "oidc",
+		Summary: "Check whether a token is active",
+		Body:    []Content{{Type: formType, Value: TokenActionForm{}}}, Status: http.StatusOK,
+		Result: jsonOf(oidc.IntrospectionResponse{}), Errors: oauthError},
+	{Id: "oidcUserInfo", Method: http.MethodGet, Path: "/oidc/userinfo", Tag: "oidc",
+		Summary: "Claims of the employee the access token in the Authorization header was issued for",
+		Status:  http.StatusOK, Result: jsonOf(oidc.Claims{}), Errors: userinfoErrors},
+	{Id: "oidcUserInfoForm", Method: http.MethodPost, Path: "/oidc/userinfo", Tag: "oidc",
+		Summary: "Claims of the employee, requested with POST",
+		Status:  http.StatusOK, Result: jsonOf(oidc.Claims{}), Errors: userinfoErrors},
+}
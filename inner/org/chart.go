@@ -0,0 +1,150 @@
+package org
+
+import (
+	"bytes"
+	"fmt"
+	"idm/inner/database"
+	"io"
+	"strings"
+)
+
+// ContentTypeDOT MIME-тип схемы в формате Graphviz
+const ContentTypeDOT = "text/vnd.graphviz; charset=utf-8"
+
+// Chart дерево оргструктуры. С rootId – только поддерево этого подразделения,
+// с withMembers – вместе с действующими сотрудниками.
+func (s *Service) Chart(rootId *int64, withMembers bool) (Chart, error) {
+	departments, err := s.repo.FindAll()
+	if err != nil {
+		return Chart{}, fmt.Errorf("error finding all departments: %w", err)
+	}
+	counts, err := s.headcounts()
+	if err != nil {
+		return Chart{}, err
+	}
+
+	nodes := make(map[int64]*Node, len(departments))
+	for _, department := range departments {
+		count := counts[department.Id]
+		nodes[department.Id] = &Node{
+			Id:             department.Id,
+			Name:           department.Name,
+			HeadId:         department.HeadId,
+			Headcount:      count.Direct,
+			TotalHeadcount: count.Total,
+			Children:       []*Node{},
+		}
+	}
+
+	chart := Chart{Departments: []*Node{}}
+	for _, department := range departments {
+		node := nodes[department.Id]
+		if parent, ok := nodes[parentId(department)]; ok {
+			parent.Children = append(parent.Children, node)
+		} else if rootId == nil {
+			chart.Departments = append(chart.Departments, node)
+		}
+	}
+	if rootId != nil {
+		root, ok := nodes[*rootId]
+		if !ok {
+			return Chart{}, fmt.Errorf("error finding department with id %d: %w", *rootId, database.ErrRecordNotFound)
+		}
+		chart.Departments = []*Node{root}
+	}
+
+	if withMembers {
+		members, err := s.repo.FindActiveMembers()
+		if err != nil {
+			return Chart{}, fmt.Errorf("error finding active employees: %w", err)
+		}
+		for _, member := range members {
+			if member.DepartmentId == nil {
+				if rootId == nil {
+					chart.Unassigned = append(chart.Unassigned, member.ToResponse())
+				}
+			} else if node, ok := nodes[*member.DepartmentId]; ok {
+				node.Members = append(node.Members, member.ToResponse())
+			}
+		}
+	}
+
+	return chart, nil
+}
+
+func parentId(department *Department) int64 {
+	if department.ParentId == nil {
+		return 0
+	}
+	return *department.ParentId
+}
+
+// WriteDOT оргструктура в формате Graphviz: подразделения – вложенные кластеры,
+// сотрудники – узлы внутри них, рёбра – линии подчинения между сотрудниками на схеме
+func WriteDOT(w io.Writer, chart Chart) error {
+	var out bytes.Buffer
+	out.WriteString("digraph org {\n\trankdir=TB;\n\tnode [shape=box];\n")
+
+	present := map[int64]bool{}
+	var members []MemberResponse
+	var writeNode func(node *Node, indent string)
+	writeNode = func(node *Node, indent string) {
+		fmt.Fprintf(&out, "%ssubgraph cluster_%d {\n", indent, node.Id)
+		fmt.Fprintf(&out, "%s\tlabel=%s;\n", indent, quote(fmt.Sprintf("%s (%d)", node.Name, node.TotalHeadcount)))
+		fmt.Fprintf(&out, "%s\td%d [label=%s, shape=folder];\n", indent, node.Id, quote(node.Name))
+		for _, member := range node.Members {
+			writeMember(&out, indent+"\t", member, node.HeadId)
+			present[member.Id] = true
+			members = append(members, member)
+		}
+		for _, child := range node.Children {
+			writeNode(child, indent+"\t")
+		}
+		fmt.Fprintf(&out, "%s}\n", indent)
+	}
+	for _, node := range chart.Departments {
+		writeNode(node, "\t")
+	}
+	for _, member := range chart.Unassigned {
+		writeMember(&out, "\t", member, nil)
+		present[member.Id] = true
+		members = append(members, member)
+	}
+
+	var writeEdges func(node *Node)
+	writeEdges = func(node *Node) {
+		for _, child := range node.Children {
+			fmt.Fprintf(&out, "\td%d -> d%d [style=dashed, arrowhead=none];\n", node.Id, child.Id)
+			writeEdges(child)
+		}
+	}
+	for _, node := range chart.Departments {
+		writeEdges(node)
+	}
+	for _, member := range members {
+		if member.ManagerId != nil && present[*member.ManagerId] {
+			fmt.Fprintf(&out, "\te%d -> e%d;\n", *member.ManagerId, member.Id)
+		}
+	}
+
+	out.WriteString("}\n")
+	_, err := w.Write(out.Bytes())
+	return err
+}
+
+func writeMember(out *bytes.Buffer, indent string, member MemberResponse, headId *int64) {
+	label := member.Name
+	if member.Title != "" {
+		label += "\n" + member.Title
+	}
+	style := ""
+	if headId != nil && *headId == member.Id {
+		style = ", style=bold"
+	}
+	fmt.Fprintf(out, "%se%d [label=%s%s];\n", indent, member.Id, quote(label), style)
+}
+
+// quote строка DOT в кавычках; переводы строк становятся разрывами строки в подписи
+func quote(text string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\r", "", "\n", `\n`).Replace(text) + `"`
+}
@@ -0,0 +1,85 @@
+package org
+
+import "time"
+
+type Response struct {
+	Id        int64     `json:"id"`
+	Name      string    `json:"name"`
+	ParentId  *int64    `json:"parent_id,omitempty"`
+	HeadId    *int64    `json:"head_id,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (d *Department) ToResponse() Response {
+	return Response{
+		Id:        d.Id,
+		Name:      d.Name,
+		ParentId:  d.ParentId,
+		HeadId:    d.HeadId,
+		CreatedAt: d.CreatedAt,
+		UpdatedAt: d.UpdatedAt,
+	}
+}
+
+// Request данные подразделения. Без ParentId подразделение верхнего уровня, без HeadId – без руководителя.
+type Request struct {
+	Name     string `json:"name"`
+	ParentId *int64 `json:"parent_id"`
+	HeadId   *int64 `json:"head_id"`
+}
+
+// MembersRequest сотрудники, которых нужно перевести в подразделение
+type MembersRequest struct {
+	EmployeeIds []int64 `json:"employee_ids"`
+}
+
+// MemberResponse сотрудник в оргструктуре. Depth – расстояние по линии подчинения
+// от сотрудника, для которого строился запрос; в остальных ответах не заполняется.
+type MemberResponse struct {
+	Id           int64  `json:"id"`
+	Name         string `json:"name"`
+	UserName     string `json:"user_name"`
+	Title        string `json:"title"`
+	ManagerId    *int64 `json:"manager_id,omitempty"`
+	DepartmentId *int64 `json:"department_id,omitempty"`
+	Depth        int    `json:"depth,omitempty"`
+}
+
+func (m *Member) ToResponse() MemberResponse {
+	return MemberResponse{
+		Id:           m.Id,
+		Name:         m.Name,
+		UserName:     m.UserName,
+		Title:        m.Title,
+		ManagerId:    m.ManagerId,
+		DepartmentId: m.DepartmentId,
+		Depth:        m.Depth,
+	}
+}
+
+// HeadcountResponse число действующих сотрудников подразделения: Direct – в нём самом,
+// Total – вместе со всеми дочерними подразделениями
+type HeadcountResponse struct {
+	DepartmentId int64  `json:"department_id"`
+	Name         string `json:"name"`
+	Direct       int    `json:"direct"`
+	Total        int    `json:"total"`
+}
+
+// Node подразделение в дереве оргструктуры; численность считается так же, как в HeadcountResponse
+type Node struct {
+	Id             int64            `json:"id"`
+	Name           string           `json:"name"`
+	HeadId         *int64           `json:"head_id,omitempty"`
+	Headcount      int              `json:"headcount"`
+	TotalHeadcount int              `json:"total_headcount"`
+	Members        []MemberResponse `json:"members,omitempty"`
+	Children       []*Node          `json:"children"`
+}
+
+// Chart оргструктура: дерево подразделений и действующие сотрудники вне подразделений
+type Chart struct {
+	Departments []*Node          `json:"departments"`
+	Unassigned  []MemberResponse `json:"unassigned,omitempty"`
+}
@@ -0,0 +1,215 @@
+package org
+
+import (
+	"idm/inner/httpapi"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+// statuses коды ответа для ошибок сервиса
+var statuses = httpapi.Statuses{
+	{Err: ErrInvalidName, Code: http.StatusBadRequest},
+	{Err: ErrNoMembers, Code: http.StatusBadRequest},
+	{Err: ErrNameTaken, Code: http.StatusConflict},
+	{Err: ErrCycle, Code: http.StatusConflict},
+	{Err: ErrNotEmpty, Code: http.StatusConflict},
+}
+
+// Handler подразделения, линии подчинения и схема оргструктуры
+type Handler struct {
+	service *Service
+	mux     *http.ServeMux
+}
+
+func NewHandler(service *Service) *Handler {
+	h := &Handler{service: service, mux: http.NewServeMux()}
+
+	h.mux.HandleFunc("GET /departments", h.list)
+	h.mux.HandleFunc("POST /departments", h.create)
+	h.mux.HandleFunc("GET /departments/{id}", h.get)
+	h.mux.HandleFunc("PUT /departments/{id}", h.update)
+	h.mux.HandleFunc("DELETE /departments/{id}", h.remove)
+	h.mux.HandleFunc("GET /departments/{id}/members", h.members)
+	h.mux.HandleFunc("PUT /departments/{id}/members", h.assignMembers)
+	h.mux.HandleFunc("DELETE /departments/{id}/members/{employeeId}", h.removeMember)
+	h.mux.HandleFunc("GET /headcounts", h.headcounts)
+	h.mux.HandleFunc("GET /employees/{id}/reports", h.reports)
+	h.mux.HandleFunc("GET /employees/{id}/chain", h.chain)
+	h.mux.HandleFunc("GET /chart", h.chart)
+
+	return h
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+func (h *Handler) list(w http.ResponseWriter, r *http.Request) {
+	departments, err := h.service.FindAll()
+	httpapi.Write(w, http.StatusOK, departments, err, statuses)
+}
+
+func (h *Handler) create(w http.ResponseWriter, r *http.Request) {
+	var request Request
+	if !httpapi.Decode(w, r, &request) {
+		return
+	}
+
+	department, err := h.service.Create(request)
+	httpapi.Write(w, http.StatusCreated, department, err, statuses)
+}
+
+func (h *Handler) get(w http.ResponseWriter, r *http.Request) {
+	id, ok := httpapi.PathId(w, r, "id")
+	if !ok {
+		return
+	}
+
+	department, err := h.service.FindById(id)
+	httpapi.Write(w, http.StatusOK, department, err, statuses)
+}
+
+func (h *Handler) update(w http.ResponseWriter, r *http.Request) {
+	id, ok := httpapi.PathId(w, r, "id")
+	if !ok {
+		return
+	}
+	var request Request
+	if !httpapi.Decode(w, r, &request) {
+		return
+	}
+
+	department, err := h.service.Update(id, request)
+	httpapi.Write(w, http.StatusOK, department, err, statuses)
+}
+
+func (h *Handler) remove(w http.ResponseWriter, r *http.Request) {
+	id, ok := httpapi.PathId(w, r, "id")
+	if !ok {
+		return
+	}
+
+	httpapi.Write(w, http.StatusNoContent, nil, h.service.Remove(id), statuses)
+}
+
+// members при recursive=true – вместе с сотрудниками дочерних подразделений
+func (h *Handler) members(w http.ResponseWriter, r *http.Request) {
+	id, ok := httpapi.PathId(w, r, "id")
+	if !ok {
+		return
+	}
+	recursive, ok := queryBool(w, r, "recursive")
+	if !ok {
+		return
+	}
+
+	members, err := h.service.Members(id, recursive)
+	httpapi.Write(w, http.StatusOK, members, err, statuses)
+}
+
+func (h *Handler) assignMembers(w http.ResponseWriter, r *http.Request) {
+	id, ok := httpapi.PathId(w, r, "id")
+	if !ok {
+		return
+	}
+	var request MembersRequest
+	if !httpapi.Decode(w, r, &request) {
+		return
+	}
+
+	httpapi.Write(w, http.StatusNoContent, nil, h.service.AssignMembers(id, request), statuses)
+}
+
+func (h *Handler) removeMember(w http.ResponseWriter, r *http.Request) {
+	id, ok := httpapi.PathId(w, r, "id")
+	if !ok {
+		return
+	}
+	employeeId, ok := httpapi.PathId(w, r, "employeeId")
+	if !ok {
+		return
+	}
+
+	httpapi.Write(w, http.StatusNoContent, nil, h.service.RemoveMember(id, employeeId), statuses)
+}
+
+func (h *Handler) headcounts(w http.ResponseWriter, r *http.Request) {
+	headcounts, err := h.service.Headcounts()
+	httpapi.Write(w, http.StatusOK, headcounts, err, statuses)
+}
+
+// reports при direct=true – только прямые подчинённые
+func (h *Handler) reports(w http.ResponseWriter, r *http.Request) {
+	id, ok := httpapi.PathId(w, r, "id")
+	if !ok {
+		return
+	}
+	direct, ok := queryBool(w, r, "direct")
+	if !ok {
+		return
+	}
+
+	reports, err := h.service.Reports(id, direct)
+	httpapi.Write(w, http.StatusOK, reports, err, statuses)
+}
+
+func (h *Handler) chain(w http.ResponseWriter, r *http.Request) {
+	id, ok := httpapi.PathId(w, r, "id")
+	if !ok {
+		return
+	}
+
+	chain, err := h.service.Chain(id)
+	httpapi.Write(w, http.StatusOK, chain, err, statuses)
+}
+
+// chart схема оргструктуры: format=json (по умолчанию) или dot, root – корень поддерева,
+// members=true – вместе с сотрудниками
+func (h *Handler) chart(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	format := query.Get("format")
+	if format != "" && format != "json" && format != "dot" {
+		httpapi.WriteError(w, http.StatusBadRequest, "invalid format")
+		return
+	}
+	var rootId *int64
+	if value := query.Get("root"); value != "" {
+		parsed, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			httpapi.WriteError(w, http.StatusBadRequest, "invalid root")
+			return
+		}
+		rootId = &parsed
+	}
+	withMembers, ok := queryBool(w, r, "members")
+	if !ok {
+		return
+	}
+
+	chart, err := h.service.Chart(rootId, withMembers)
+	if err != nil || format != "dot" {
+		httpapi.Write(w, http.StatusOK, chart, err, statuses)
+		return
+	}
+
+	w.Header().Set("Content-Type", ContentTypeDOT)
+	w.Header().Set("Cache-Control", "no-store")
+	if err := WriteDOT(w, chart); err != nil {
+		log.Printf("error writing org chart: %v", err)
+	}
+}
+
+func queryBool(w http.ResponseWriter, r *http.Request, name string) (bool, bool) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return false, true
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		httpapi.WriteError(w, http.StatusBadRequest, "invalid "+name)
+		return false, false
+	}
+
+	return parsed, true
+}
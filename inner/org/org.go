@@ -0,0 +1,333 @@
+package org
+
+import (
+	"errors"
+	"fmt"
+	"idm/inner/database"
+	"idm/inner/employee"
+	"slices"
+	"strings"
+)
+
+var (
+	ErrInvalidName = errors.New("department name is required")
+	ErrNameTaken   = errors.New("department name is already taken within the parent department")
+	ErrCycle       = errors.New("department cannot be placed under itself or its subdepartment")
+	ErrNotEmpty    = errors.New("department has subdepartments or members")
+	ErrNoMembers   = errors.New("employee ids are required")
+)
+
+type Repo interface {
+	FindById(id int64) (*Department, error)
+	FindAll() ([]*Department, error)
+	Create(department *Department) error
+	Update(department *Department) error
+	Remove(id int64) error
+	FindMember(id int64) (*Member, error)
+	FindMembers(departmentId int64, recursive bool) ([]*Member, error)
+	FindActiveMembers() ([]*Member, error)
+	FindReports(managerId int64, maxDepth int) ([]*Member, error)
+	FindChain(employeeId int64) ([]*Member, error)
+	FindHeadcounts() ([]*Headcount, error)
+}
+
+// Employees переводит сотрудников между подразделениями и вызывает обработчики их сохранения
+type Employees interface {
+	SetDepartment(ids []int64, departmentId *int64) ([]employee.Response, error)
+}
+
+// Service подразделения, членство в них и линии подчинения
+type Service struct {
+	repo      Repo
+	employees Employees
+}
+
+func NewService(repository Repo, employees Employees) *Service {
+	return &Service{repo: repository, employees: employees}
+}
+
+func (s *Service) FindById(id int64) (Response, error) {
+	department, err := s.repo.FindById(id)
+	if err != nil {
+		return Response{}, fmt.Errorf("error finding department with id %d: %w", id, err)
+	}
+
+	return department.ToResponse(), nil
+}
+
+func (s *Service) FindAll() ([]Response, error) {
+	departments, err := s.repo.FindAll()
+	if err != nil {
+		return nil, fmt.Errorf("error finding all departments: %w", err)
+	}
+
+	responses := make([]Response, 0, len(departments))
+	for _, department := range departments {
+		responses = append(responses, department.ToResponse())
+	}
+
+	return responses, nil
+}
+
+func (s *Service) Create(request Request) (Response, error) {
+	department := &Department{}
+	if err := s.apply(department, request); err != nil {
+		return Response{}, err
+	}
+
+	if err := s.repo.Create(department); err != nil {
+		return Response{}, fmt.Errorf("error creating department with name %q: %w", department.Name, err)
+	}
+
+	return department.ToResponse(), nil
+}
+
+func (s *Service) Update(id int64, request Request) (Response, error) {
+	department, err := s.repo.FindById(id)
+	if err != nil {
+		return Response{}, fmt.Errorf("error finding department with id %d: %w", id, err)
+	}
+	name := department.Name
+	if err := s.apply(department, request); err != nil {
+		return Response{}, err
+	}
+
+	if err := s.repo.Update(department); err != nil {
+		return Response{}, fmt.Errorf("error updating department with id %d: %w", id, err)
+	}
+	// новое название уже у сотрудников; обработчики их сохранения назначают роли по нему
+	if department.Name != name {
+		members, err := s.repo.FindMembers(id, false)
+		if err != nil {
+			return Response{}, fmt.Errorf("error finding members of department with id %d: %w", id, err)
+		}
+		if len(members) > 0 {
+			if _, err := s.employees.SetDepartment(memberIds(members), &id); err != nil {
+				return Response{}, fmt.Errorf("error updating department name of members of department with id %d: %w", id, err)
+			}
+		}
+	}
+
+	return department.ToResponse(), nil
+}
+
+// apply проверить запрос и перенести его в подразделение: родитель существует и не лежит
+// в поддереве самого подразделения, имя уникально среди соседей, руководитель существует
+func (s *Service) apply(department *Department, request Request) error {
+	name := strings.TrimSpace(request.Name)
+	if name == "" {
+		return ErrInvalidName
+	}
+
+	departments, err := s.repo.FindAll()
+	if err != nil {
+		return fmt.Errorf("error finding all departments: %w", err)
+	}
+	if request.ParentId != nil {
+		index := slices.IndexFunc(departments, func(d *Department) bool { return d.Id == *request.ParentId })
+		if index < 0 {
+			return fmt.Errorf("error finding parent department with id %d: %w", *request.ParentId, database.ErrRecordNotFound)
+		}
+		if department.Id != 0 && slices.Contains(ancestors(departments, departments[index]), department.Id) {
+			return fmt.Errorf("%w: %d", ErrCycle, *request.ParentId)
+		}
+	}
+	for _, other := range departments {
+		if other.Id != department.Id && equalIds(other.ParentId, request.ParentId) && strings.EqualFold(other.Name, name) {
+			return fmt.Errorf("%w: %s", ErrNameTaken, name)
+		}
+	}
+	if request.HeadId != nil {
+		if _, err := s.repo.FindMember(*request.HeadId); err != nil {
+			return fmt.Errorf("error finding head employee with id %d: %w", *request.HeadId, err)
+		}
+	}
+
+	department.Name, department.ParentId, department.HeadId = name, request.ParentId, request.HeadId
+	return nil
+}
+
+// Remove удалить подразделение; подразделение с дочерними или сотрудниками не удаляется
+func (s *Service) Remove(id int64) error {
+	departments, err := s.repo.FindAll()
+	if err != nil {
+		return fmt.Errorf("error finding all departments: %w", err)
+	}
+	if !slices.ContainsFunc(departments, func(d *Department) bool { return d.Id == id }) {
+		return fmt.Errorf("error finding department with id %d: %w", id, database.ErrRecordNotFound)
+	}
+	if slices.ContainsFunc(departments, func(d *Department) bool { return d.ParentId != nil && *d.ParentId == id }) {
+		return fmt.Errorf("%w: %d", ErrNotEmpty, id)
+	}
+	members, err := s.repo.FindMembers(id, false)
+	if err != nil {
+		return fmt.Errorf("error finding members of department with id %d: %w", id, err)
+	}
+	if len(members) > 0 {
+		return fmt.Errorf("%w: %d", ErrNotEmpty, id)
+	}
+
+	if err := s.repo.Remove(id); err != nil {
+		return fmt.Errorf("error removing department with id %d: %w", id, err)
+	}
+
+	return nil
+}
+
+// Members сотрудники подразделения, при recursive – вместе с дочерними подразделениями
+func (s *Service) Members(id int64, recursive bool) ([]MemberResponse, error) {
+	if _, err := s.repo.FindById(id); err != nil {
+		return nil, fmt.Errorf("error finding department with id %d: %w", id, err)
+	}
+
+	members, err := s.repo.FindMembers(id, recursive)
+	if err != nil {
+		return nil, fmt.Errorf("error finding members of department with id %d: %w", id, err)
+	}
+
+	return toResponses(members), nil
+}
+
+// AssignMembers перевести сотрудников в подразделение; сотрудник состоит не более чем в одном
+func (s *Service) AssignMembers(id int64, request MembersRequest) error {
+	employeeIds := slices.Compact(slices.Sorted(slices.Values(request.EmployeeIds)))
+	if len(employeeIds) == 0 {
+		return ErrNoMembers
+	}
+	if _, err := s.repo.FindById(id); err != nil {
+		return fmt.Errorf("error finding department with id %d: %w", id, err)
+	}
+
+	if _, err := s.employees.SetDepartment(employeeIds, &id); err != nil {
+		return fmt.Errorf("error assigning employees to department with id %d: %w", id, err)
+	}
+
+	return nil
+}
+
+// RemoveMember вывести сотрудника из подразделения
+func (s *Service) RemoveMember(id int64, employeeId int64) error {
+	member, err := s.repo.FindMember(employeeId)
+	if err != nil {
+		return fmt.Errorf("error finding employee with id %d: %w", employeeId, err)
+	}
+	if member.DepartmentId == nil || *member.DepartmentId != id {
+		return fmt.Errorf("error finding employee with id %d in department with id %d: %w", employeeId, id, database.ErrRecordNotFound)
+	}
+
+	if _, err := s.employees.SetDepartment([]int64{employeeId}, nil); err != nil {
+		return fmt.Errorf("error removing employee with id %d from department with id %d: %w", employeeId, id, err)
+	}
+
+	return nil
+}
+
+// Reports подчинённые руководителя: при direct – только прямые, иначе на всех уровнях
+func (s *Service) Reports(managerId int64, direct bool) ([]MemberResponse, error) {
+	maxDepth := 0
+	if direct {
+		maxDepth = 1
+	}
+
+	members, err := s.repo.FindReports(managerId, maxDepth)
+	if err != nil {
+		return nil, fmt.Errorf("error finding reports of employee with id %d: %w", managerId, err)
+	}
+	if len(members) == 0 {
+		return nil, fmt.Errorf("error finding employee with id %d: %w", managerId, database.ErrRecordNotFound)
+	}
+
+	return toResponses(members[1:]), nil
+}
+
+// Chain руководители сотрудника от непосредственного до верхнего
+func (s *Service) Chain(employeeId int64) ([]MemberResponse, error) {
+	members, err := s.repo.FindChain(employeeId)
+	if err != nil {
+		return nil, fmt.Errorf("error finding management chain of employee with id %d: %w", employeeId, err)
+	}
+	if len(members) == 0 {
+		return nil, fmt.Errorf("error finding employee with id %d: %w", employeeId, database.ErrRecordNotFound)
+	}
+
+	return toResponses(members[1:]), nil
+}
+
+func (s *Service) Headcounts() ([]HeadcountResponse, error) {
+	departments, err := s.repo.FindAll()
+	if err != nil {
+		return nil, fmt.Errorf("error finding all departments: %w", err)
+	}
+	counts, err := s.headcounts()
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]HeadcountResponse, 0, len(departments))
+	for _, department := range departments {
+		count := counts[department.Id]
+		responses = append(responses, HeadcountResponse{
+			DepartmentId: department.Id,
+			Name:         department.Name,
+			Direct:       count.Direct,
+			Total:        count.Total,
+		})
+	}
+
+	return responses, nil
+}
+
+func (s *Service) headcounts() (map[int64]Headcount, error) {
+	headcounts, err := s.repo.FindHeadcounts()
+	if err != nil {
+		return nil, fmt.Errorf("error finding department headcounts: %w", err)
+	}
+
+	counts := make(map[int64]Headcount, len(headcounts))
+	for _, headcount := range headcounts {
+		counts[headcount.DepartmentId] = *headcount
+	}
+
+	return counts, nil
+}
+
+// ancestors идентификаторы подразделения и всех его родителей; цикл в данных обрывает цепочку
+func ancestors(departments []*Department, department *Department) []int64 {
+	byId := make(map[int64]*Department, len(departments))
+	for _, d := range departments {
+		byId[d.Id] = d
+	}
+
+	var ids []int64
+	for current := department; current != nil && !slices.Contains(ids, current.Id); {
+		ids = append(ids, current.Id)
+		if current.ParentId == nil {
+			break
+		}
+		current = byId[*current.ParentId]
+	}
+
+	return ids
+}
+
+func equalIds(a *int64, b *int64) bool {
+	return a == nil && b == nil || a != nil && b != nil && *a == *b
+}
+
+func toResponses(members []*Member) []MemberResponse {
+	responses := make([]MemberResponse, 0, len(members))
+	for _, member := range members {
+		responses = append(responses, member.ToResponse())
+	}
+
+	return responses
+}
+
+func memberIds(members []*Member) []int64 {
+	ids := make([]int64, 0, len(members))
+	for _, member := range members {
+		ids = append(ids, member.Id)
+	}
+
+	return ids
+}
@@ -0,0 +1,337 @@
+package org
+
+import (
+	"encoding/json"
+	"errors"
+	assertpackage "github.com/stretchr/testify/assert"
+	"idm/inner/database"
+	"idm/inner/employee"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// StubRepo подразделения и сотрудники в памяти; рекурсивные запросы возвращают заданные строки.
+// Переводы сотрудников между подразделениями он запоминает вместо employee.Service.
+type StubRepo struct {
+	departments []*Department
+	members     []*Member
+	headcounts  []*Headcount
+	reports     []*Member
+	moved       []int64
+	movedTo     *int64
+	removed     int64
+}
+
+func (r *StubRepo) FindById(id int64) (*Department, error) {
+	for _, department := range r.departments {
+		if department.Id == id {
+			copied := *department
+			return &copied, nil
+		}
+	}
+	return nil, database.ErrRecordNotFound
+}
+
+func (r *StubRepo) FindAll() ([]*Department, error) {
+	return r.departments, nil
+}
+
+func (r *StubRepo) Create(department *Department) error {
+	department.Id = int64(len(r.departments) + 1)
+	r.departments = append(r.departments, department)
+	return nil
+}
+
+func (r *StubRepo) Update(department *Department) error {
+	for i, existing := range r.departments {
+		if existing.Id == department.Id {
+			r.departments[i] = department
+		}
+	}
+	return nil
+}
+
+func (r *StubRepo) Remove(id int64) error {
+	r.removed = id
+	return nil
+}
+
+func (r *StubRepo) FindMember(id int64) (*Member, error) {
+	for _, member := range r.members {
+		if member.Id == id {
+			return member, nil
+		}
+	}
+	return nil, database.ErrRecordNotFound
+}
+
+func (r *StubRepo) FindMembers(departmentId int64, recursive bool) ([]*Member, error) {
+	var members []*Member
+	for _, member := range r.members {
+		if member.DepartmentId != nil && *member.DepartmentId == departmentId {
+			members = append(members, member)
+		}
+	}
+	return members, nil
+}
+
+func (r *StubRepo) FindActiveMembers() ([]*Member, error) {
+	return r.members, nil
+}
+
+func (r *StubRepo) SetDepartment(ids []int64, departmentId *int64) ([]employee.Response, error) {
+	r.moved, r.movedTo = ids, departmentId
+	return nil, nil
+}
+
+func (r *StubRepo) FindReports(managerId int64, maxDepth int) ([]*Member, error) {
+	return r.reports, nil
+}
+
+func (r *StubRepo) FindChain(employeeId int64) ([]*Member, error) {
+	return r.reports, nil
+}
+
+func (r *StubRepo) FindHeadcounts() ([]*Headcount, error) {
+	return r.headcounts, nil
+}
+
+func newService() *Service {
+	repo := newRepo()
+	return NewService(repo, repo)
+}
+
+func id(value int64) *int64 {
+	return &value
+}
+
+// newRepo Компания(1) → Разработка(2) → Бэкенд(3), Продажи(4) – верхнего уровня
+func newRepo() *StubRepo {
+	return &StubRepo{
+		departments: []*Department{
+			{Id: 1, Name: "Company", HeadId: id(10)},
+			{Id: 2, Name: "Engineering", ParentId: id(1), HeadId: id(11)},
+			{Id: 3, Name: "Backend", ParentId: id(2)},
+			{Id: 4, Name: "Sales"},
+		},
+		members: []*Member{
+			{Id: 10, Name: "Ivanov Ivan", Title: "CEO", DepartmentId: id(1)},
+			{Id: 11, Name: "Petrov Petr", Title: "CTO", ManagerId: id(10), DepartmentId: id(2)},
+			{Id: 12, Name: `Sidorov "Sid" Sidor`, Title: "Developer", ManagerId: id(11), DepartmentId: id(3)},
+			{Id: 13, Name: "Smirnova Anna", ManagerId: id(10)},
+		},
+		headcounts: []*Headcount{
+			{DepartmentId: 1, Direct: 1, Total: 3},
+			{DepartmentId: 2, Direct: 1, Total: 2},
+			{DepartmentId: 3, Direct: 1, Total: 1},
+			{DepartmentId: 4},
+		},
+	}
+}
+
+func TestOrgService(t *testing.T) {
+	var assert = assertpackage.New(t)
+
+	t.Run("should create a department under an existing parent", func(t *testing.T) {
+		repo := newRepo()
+		service := NewService(repo, repo)
+
+		created, err := service.Create(Request{Name: " Frontend ", ParentId: id(2), HeadId: id(12)})
+
+		assert.Nil(err)
+		assert.Equal(int64(5), created.Id)
+		assert.Equal("Frontend", created.Name)
+		assert.Equal(int64(2), *created.ParentId)
+	})
+
+	t.Run("should reject an invalid department", func(t *testing.T) {
+		service := newService()
+
+		_, err := service.Create(Request{Name: " "})
+		assert.True(errors.Is(err, ErrInvalidName))
+
+		_, err = service.Create(Request{Name: "backend", ParentId: id(2)})
+		assert.True(errors.Is(err, ErrNameTaken))
+
+		_, err = service.Create(Request{Name: "Backend"})
+		assert.Nil(err)
+
+		_, err = service.Create(Request{Name: "Support", ParentId: id(42)})
+		assert.True(errors.Is(err, database.ErrRecordNotFound))
+
+		_, err = service.Create(Request{Name: "Support", HeadId: id(42)})
+		assert.True(errors.Is(err, database.ErrRecordNotFound))
+	})
+
+	t.Run("should not move a department under itself or its subdepartment", func(t *testing.T) {
+		repo := newRepo()
+		service := NewService(repo, repo)
+
+		_, err := service.Update(1, Request{Name: "Company", ParentId: id(3)})
+		assert.True(errors.Is(err, ErrCycle))
+
+		_, err = service.Update(2, Request{Name: "Engineering", ParentId: id(2)})
+		assert.True(errors.Is(err, ErrCycle))
+
+		moved, err := service.Update(3, Request{Name: "Backend", ParentId: id(4)})
+		assert.Nil(err)
+		assert.Equal(int64(4), *moved.ParentId)
+
+		moved, err = service.Update(3, Request{Name: "Backend"})
+		assert.Nil(err)
+		assert.Nil(moved.ParentId)
+	})
+
+	t.Run("should pass a new department name to its members", func(t *testing.T) {
+		repo := newRepo()
+		service := NewService(repo, repo)
+
+		_, err := service.Update(3, Request{Name: "Backend", ParentId: id(2)})
+		assert.Nil(err)
+		assert.Nil(repo.moved)
+
+		_, err = service.Update(3, Request{Name: "Platform", ParentId: id(2)})
+		assert.Nil(err)
+		assert.Equal([]int64{12}, repo.moved)
+		assert.Equal(int64(3), *repo.movedTo)
+	})
+
+	t.Run("should remove only empty departments", func(t *testing.T) {
+		repo := newRepo()
+		service := NewService(repo, repo)
+
+		assert.True(errors.Is(service.Remove(2), ErrNotEmpty))
+		assert.True(errors.Is(service.Remove(3), ErrNotEmpty))
+		assert.True(errors.Is(service.Remove(42), database.ErrRecordNotFound))
+		assert.Nil(service.Remove(4))
+		assert.Equal(int64(4), repo.removed)
+	})
+
+	t.Run("should assign and remove members", func(t *testing.T) {
+		repo := newRepo()
+		service := NewService(repo, repo)
+
+		assert.True(errors.Is(service.AssignMembers(4, MembersRequest{}), ErrNoMembers))
+		assert.True(errors.Is(service.AssignMembers(42, MembersRequest{EmployeeIds: []int64{13}}), database.ErrRecordNotFound))
+
+		assert.Nil(service.AssignMembers(4, MembersRequest{EmployeeIds: []int64{13, 12, 13}}))
+		assert.Equal([]int64{12, 13}, repo.moved)
+		assert.Equal(int64(4), *repo.movedTo)
+
+		assert.True(errors.Is(service.RemoveMember(4, 12), database.ErrRecordNotFound))
+		assert.Nil(service.RemoveMember(3, 12))
+		assert.Equal([]int64{12}, repo.moved)
+		assert.Nil(repo.movedTo)
+	})
+
+	t.Run("should return reports and the chain without the employee itself", func(t *testing.T) {
+		repo := newRepo()
+		service := NewService(repo, repo)
+
+		_, err := service.Reports(42, false)
+		assert.True(errors.Is(err, database.ErrRecordNotFound))
+		_, err = service.Chain(42)
+		assert.True(errors.Is(err, database.ErrRecordNotFound))
+
+		repo.reports = []*Member{{Id: 10}, {Id: 11, Depth: 1}, {Id: 13, Depth: 1}, {Id: 12, Depth: 2}}
+		reports, err := service.Reports(10, false)
+		assert.Nil(err)
+		assert.Len(reports, 3)
+		assert.Equal(2, reports[2].Depth)
+
+		repo.reports = []*Member{{Id: 13}}
+		chain, err := service.Chain(13)
+		assert.Nil(err)
+		assert.Empty(chain)
+	})
+
+	t.Run("should name department headcounts", func(t *testing.T) {
+		headcounts, err := newService().Headcounts()
+
+		assert.Nil(err)
+		assert.Equal(HeadcountResponse{DepartmentId: 1, Name: "Company", Direct: 1, Total: 3}, headcounts[0])
+		assert.Equal(HeadcountResponse{DepartmentId: 4, Name: "Sales"}, headcounts[3])
+	})
+
+	t.Run("should build the org chart tree", func(t *testing.T) {
+		service := newService()
+
+		chart, err := service.Chart(nil, true)
+		assert.Nil(err)
+		assert.Len(chart.Departments, 2)
+		company := chart.Departments[0]
+		assert.Equal(3, company.TotalHeadcount)
+		assert.Equal("Engineering", company.Children[0].Name)
+		assert.Equal("Backend", company.Children[0].Children[0].Name)
+		assert.Equal(int64(12), company.Children[0].Children[0].Members[0].Id)
+		assert.Equal(int64(13), chart.Unassigned[0].Id)
+
+		chart, err = service.Chart(id(2), false)
+		assert.Nil(err)
+		assert.Len(chart.Departments, 1)
+		assert.Equal("Engineering", chart.Departments[0].Name)
+		assert.Empty(chart.Departments[0].Members)
+		assert.Empty(chart.Unassigned)
+
+		_, err = service.Chart(id(42), false)
+		assert.True(errors.Is(err, database.ErrRecordNotFound))
+	})
+
+	t.Run("should write the org chart as dot", func(t *testing.T) {
+		chart, _ := newService().Chart(nil, true)
+		var out strings.Builder
+
+		assert.Nil(WriteDOT(&out, chart))
+		graph := out.String()
+
+		assert.True(strings.HasPrefix(graph, "digraph org {\n"))
+		assert.True(strings.HasSuffix(graph, "}\n"))
+		assert.Contains(graph, "\tsubgraph cluster_1 {\n\t\tlabel=\"Company (3)\";\n")
+		assert.Contains(graph, "\t\tsubgraph cluster_2 {\n")
+		assert.Contains(graph, `e10 [label="Ivanov Ivan\nCEO", style=bold];`)
+		assert.Contains(graph, `e12 [label="Sidorov \"Sid\" Sidor\nDeveloper"];`)
+		assert.Contains(graph, "\td1 -> d2 [style=dashed, arrowhead=none];\n")
+		assert.Contains(graph, "\te10 -> e11;\n")
+		assert.Contains(graph, "\te10 -> e13;\n")
+		assert.Equal(strings.Count(graph, "{"), strings.Count(graph, "}"))
+	})
+}
+
+func TestOrgHandler(t *testing.T) {
+	var assert = assertpackage.New(t)
+
+	serve := func(method string, target string, body string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		NewHandler(newService()).ServeHTTP(recorder, httptest.NewRequest(method, target, strings.NewReader(body)))
+		return recorder
+	}
+
+	t.Run("should serve the chart as json and dot", func(t *testing.T) {
+		recorder := serve(http.MethodGet, "/chart?root=2", "")
+		assert.Equal(http.StatusOK, recorder.Code)
+		var chart Chart
+		assert.Nil(json.Unmarshal(recorder.Body.Bytes(), &chart))
+		assert.Equal("Backend", chart.Departments[0].Children[0].Name)
+
+		recorder = serve(http.MethodGet, "/chart?format=dot&members=true", "")
+		assert.Equal(http.StatusOK, recorder.Code)
+		assert.Equal(ContentTypeDOT, recorder.Header().Get("Content-Type"))
+		assert.Contains(recorder.Body.String(), "digraph org {")
+
+		assert.Equal(http.StatusBadRequest, serve(http.MethodGet, "/chart?format=svg", "").Code)
+		assert.Equal(http.StatusBadRequest, serve(http.MethodGet, "/chart?members=maybe", "").Code)
+		assert.Equal(http.StatusNotFound, serve(http.MethodGet, "/chart?root=42", "").Code)
+	})
+
+	t.Run("should map errors to statuses", func(t *testing.T) {
+		assert.Equal(http.StatusCreated, serve(http.MethodPost, "/departments", `{"name":"Support"}`).Code)
+		assert.Equal(http.StatusBadRequest, serve(http.MethodPost, "/departments", `{"name":""}`).Code)
+		assert.Equal(http.StatusConflict, serve(http.MethodPut, "/departments/1", `{"name":"Company","parent_id":3}`).Code)
+		assert.Equal(http.StatusConflict, serve(http.MethodDelete, "/departments/2", "").Code)
+		assert.Equal(http.StatusNoContent, serve(http.MethodPut, "/departments/4/members", `{"employee_ids":[13]}`).Code)
+		assert.Equal(http.StatusNotFound, serve(http.MethodGet, "/employees/42/chain", "").Code)
+		assert.Equal(http.StatusBadRequest, serve(http.MethodGet, "/employees/x/reports", "").Code)
+	})
+}
@@ -0,0 +1,246 @@
+package org
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/jmoiron/sqlx"
+	"idm/inner/database"
+	"idm/inner/employee"
+	"time"
+)
+
+type Department struct {
+	Id        int64     `db:"id"`
+	Name      string    `db:"name"`
+	ParentId  *int64    `db:"parent_id"`
+	HeadId    *int64    `db:"head_id"`
+	CreatedAt time.Time `db:"created_at"`
+	UpdatedAt time.Time `db:"updated_at"`
+}
+
+// Member сотрудник в оргструктуре; Depth заполняют запросы по линиям подчинения
+type Member struct {
+	Id           int64  `db:"id"`
+	Name         string `db:"name"`
+	UserName     string `db:"user_name"`
+	Title        string `db:"title"`
+	ManagerId    *int64 `db:"manager_id"`
+	DepartmentId *int64 `db:"department_id"`
+	Depth        int    `db:"depth"`
+}
+
+type Headcount struct {
+	DepartmentId int64 `db:"department_id"`
+	Direct       int   `db:"direct"`
+	Total        int   `db:"total"`
+}
+
+const selectMembers = "SELECT e.id, e.name, e.user_name, e.title, e.manager_id, e.department_id"
+
+type Repository struct {
+	db *sqlx.DB
+}
+
+func NewRepository(db *sqlx.DB) *Repository {
+	return &Repository{db: db}
+}
+
+func (r *Repository) FindById(id int64) (*Department, error) {
+	var department Department
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := r.db.GetContext(ctx, &department, "SELECT * FROM departments WHERE id = $1", id)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, database.ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &department, err
+}
+
+func (r *Repository) FindAll() ([]*Department, error) {
+	var departments []*Department
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := r.db.SelectContext(ctx, &departments, "SELECT * FROM departments ORDER BY id")
+
+	return departments, err
+}
+
+func (r *Repository) Create(department *Department) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return r.db.QueryRowContext(ctx,
+		"INSERT INTO departments (name, parent_id, head_id) VALUES ($1, $2, $3) RETURNING id, created_at, updated_at",
+		department.Name, department.ParentId, department.HeadId,
+	).Scan(&department.Id, &department.CreatedAt, &department.UpdatedAt)
+}
+
+// Update изменить подразделение; его название тут же записывается сотрудникам подразделения
+func (r *Repository) Update(department *Department) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	err = tx.QueryRowContext(ctx,
+		`UPDATE departments SET name = $1, parent_id = $2, head_id = $3, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $4 RETURNING created_at, updated_at`,
+		department.Name, department.ParentId, department.HeadId, department.Id,
+	).Scan(&department.CreatedAt, &department.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.ErrRecordNotFound
+	}
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx,
+		"UPDATE employees SET department = $1, updated_at = CURRENT_TIMESTAMP WHERE department_id = $2 AND department <> $1",
+		department.Name, department.Id)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *Repository) Remove(id int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := r.db.ExecContext(ctx, "DELETE FROM departments WHERE id = $1", id)
+
+	return err
+}
+
+// FindMember сотрудник по идентификатору
+func (r *Repository) FindMember(id int64) (*Member, error) {
+	var member Member
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := r.db.GetContext(ctx, &member, selectMembers+" FROM employees e WHERE e.id = $1", id)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, database.ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &member, err
+}
+
+// FindMembers сотрудники подразделения, при recursive – вместе с дочерними подразделениями
+func (r *Repository) FindMembers(departmentId int64, recursive bool) ([]*Member, error) {
+	var members []*Member
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := r.db.SelectContext(ctx, &members,
+		`WITH RECURSIVE subtree AS (
+			SELECT id FROM departments WHERE id = $1
+			UNION
+			SELECT d.id FROM departments d JOIN subtree s ON d.parent_id = s.id WHERE $2
+		)
+		`+selectMembers+` FROM employees e WHERE e.department_id IN (SELECT id FROM subtree) ORDER BY e.id`,
+		departmentId, recursive)
+
+	return members, err
+}
+
+// FindActiveMembers все действующие сотрудники
+func (r *Repository) FindActiveMembers() ([]*Member, error) {
+	var members []*Member
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := r.db.SelectContext(ctx, &members,
+		selectMembers+" FROM employees e WHERE e.status = $1 ORDER BY e.id", employee.StatusActive)
+
+	return members, err
+}
+
+// FindReports подчинённые руководителя на всех уровнях, при maxDepth > 0 – не глубже maxDepth.
+// Первая строка – сам руководитель с глубиной 0; циклы в линиях подчинения обрываются.
+func (r *Repository) FindReports(managerId int64, maxDepth int) ([]*Member, error) {
+	var members []*Member
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := r.db.SelectContext(ctx, &members,
+		`WITH RECURSIVE reports AS (
+			SELECT id, 0 AS depth, ARRAY[id] AS path FROM employees WHERE id = $1
+			UNION ALL
+			SELECT e.id, r.depth + 1, r.path || e.id FROM employees e JOIN reports r ON e.manager_id = r.id
+			WHERE NOT e.id = ANY(r.path) AND ($2 = 0 OR r.depth < $2)
+		)
+		`+selectMembers+`, r.depth FROM reports r JOIN employees e ON e.id = r.id ORDER BY r.depth, e.id`,
+		managerId, maxDepth)
+
+	return members, err
+}
+
+// FindChain цепочка руководителей сотрудника снизу вверх. Первая строка – сам сотрудник
+// с глубиной 0; цикл в линиях подчинения обрывает цепочку.
+func (r *Repository) FindChain(employeeId int64) ([]*Member, error) {
+	var members []*Member
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := r.db.SelectContext(ctx, &members,
+		`WITH RECURSIVE chain AS (
+			SELECT id, manager_id, 0 AS depth, ARRAY[id] AS path FROM employees WHERE id = $1
+			UNION ALL
+			SELECT m.id, m.manager_id, c.depth + 1, c.path || m.id FROM employees m JOIN chain c ON m.id = c.manager_id
+			WHERE NOT m.id = ANY(c.path)
+		)
+		`+selectMembers+`, c.depth FROM chain c JOIN employees e ON e.id = c.id ORDER BY c.depth`,
+		employeeId)
+
+	return members, err
+}
+
+// FindHeadcounts численность каждого подразделения: сотрудники в нём самом и во всех дочерних
+func (r *Repository) FindHeadcounts() ([]*Headcount, error) {
+	var headcounts []*Headcount
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	// UNION, а не UNION ALL: пары повторяются при цикле, и рекурсия на нём останавливается
+	err := r.db.SelectContext(ctx, &headcounts,
+		`WITH RECURSIVE subtree AS (
+			SELECT id AS root_id, id FROM departments
+			UNION
+			SELECT s.root_id, d.id FROM departments d JOIN subtree s ON d.parent_id = s.id
+		)
+		SELECT s.root_id AS department_id,
+			count(e.id) FILTER (WHERE e.department_id = s.root_id) AS direct,
+			count(e.id) AS total
+		FROM subtree s LEFT JOIN employees e ON e.department_id = s.id AND e.status = $1
+		GROUP BY s.root_id ORDER BY s.root_id`,
+		employee.StatusActive)
+
+	return headcounts, err
+}
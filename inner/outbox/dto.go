@@ -0,0 +1,18 @@
+package outbox
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Event доменное событие. AggregateType и AggregateId – сущность, в пределах которой
+// события доставляются строго по порядку: employee или role.
+type Event struct {
+	Id            int64           `json:"id" db:"id"`
+	Type          string          `json:"type" db:"event_type"`
+	AggregateType string          `json:"aggregate_type" db:"aggregate_type"`
+	AggregateId   int64           `json:"aggregate_id" db:"aggregate_id"`
+	Payload       json.RawMessage `json:"payload" db:"payload"`
+	OccurredAt    time.Time       `json:"occurred_at" db:"occurred_at"`
+	Attempts      int             `json:"-" db:"attempts"`
+}
@@ -0,0 +1,228 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// Типы событий; пишутся триггерами базы, см. миграцию create_outbox_table
+const (
+	EmployeeCreated   = "EmployeeCreated"
+	EmployeeUpdated   = "EmployeeUpdated"
+	EmployeeActivated = "EmployeeActivated"
+	EmployeeDisabled  = "EmployeeDisabled"
+	EmployeeRemoved   = "EmployeeRemoved"
+	RoleCreated       = "RoleCreated"
+	RoleUpdated       = "RoleUpdated"
+	RoleRemoved       = "RoleRemoved"
+	RoleAssigned      = "RoleAssigned"
+	RoleRevoked       = "RoleRevoked"
+)
+
+// CertificationReminder напоминание ревьюеру о неразобранных элементах пересмотра; пишет кампания
+// пересмотра доступов, агрегат – сотрудник-ревьюер
+const CertificationReminder = "CertificationReminder"
+
+var EventTypes = []string{
+	EmployeeCreated, EmployeeUpdated, EmployeeActivated, EmployeeDisabled, EmployeeRemoved,
+	RoleCreated, RoleUpdated, RoleRemoved, RoleAssigned, RoleRevoked, CertificationReminder,
+}
+
+const (
+	DefaultBatchSize    = 100
+	DefaultPollInterval = time.Second
+	DefaultMinBackoff   = time.Second
+	DefaultMaxBackoff   = 10 * time.Minute
+	DefaultRetention    = 7 * 24 * time.Hour
+	// publishTimeout сколько ждать одного издателя
+	publishTimeout = 10 * time.Second
+	// leaseDuration на сколько экземпляр занимает пакет событий; если экземпляр упал, после этого
+	// срока события отправит другой
+	leaseDuration = 5 * time.Minute
+	// purgeInterval как часто удалять доставленные события старше срока хранения
+	purgeInterval = time.Hour
+)
+
+// ErrBusy пакет событий сейчас занимает другой экземпляр
+var ErrBusy = errors.New("outbox is being relayed by another instance")
+
+// Publisher получатель событий. Доставка «хотя бы один раз»: после сбоя событие
+// повторяется, поэтому получатель должен отбрасывать повторы по Event.Id.
+type Publisher interface {
+	Publish(ctx context.Context, event Event) error
+}
+
+// Repo хранилище событий. Каждый метод – отдельная короткая транзакция: события отправляются
+// издателям вне транзакций, а от повторной отправки другим экземпляром их защищает аренда.
+type Repo interface {
+	// Claim занять до until не больше limit событий в порядке появления, которые пора отправлять;
+	// ErrBusy, если события сейчас занимает другой экземпляр. События сущности, у которой более раннее
+	// событие ждёт повтора или занято, не возвращаются.
+	Claim(limit int, now time.Time, until time.Time) ([]Event, error)
+	Delivered(id int64, at time.Time) error
+	Failed(id int64, attempts int, next time.Time, reason string) error
+	// Release вернуть неотправленные события, если их аренда до until ещё не перешла другому экземпляру
+	Release(ids []int64, until time.Time, at time.Time) error
+	// Purge удалить события, доставленные раньше before
+	Purge(before time.Time) (int64, error)
+}
+
+// Relay доставляет события из outbox издателям
+type Relay struct {
+	repo       Repo
+	publishers []Publisher
+	batchSize  int
+	interval   time.Duration
+	minBackoff time.Duration
+	maxBackoff time.Duration
+	retention  time.Duration
+	now        func() time.Time
+}
+
+func NewRelay(repository Repo, publishers ...Publisher) *Relay {
+	return &Relay{
+		repo:       repository,
+		publishers: publishers,
+		batchSize:  DefaultBatchSize,
+		interval:   DefaultPollInterval,
+		minBackoff: DefaultMinBackoff,
+		maxBackoff: DefaultMaxBackoff,
+		retention:  DefaultRetention,
+		now:        time.Now,
+	}
+}
+
+// SetBackoff задать паузу перед первым повтором и её предел; пауза удваивается с каждой попыткой
+func (r *Relay) SetBackoff(minimum, maximum time.Duration) {
+	r.minBackoff, r.maxBackoff = minimum, maximum
+}
+
+// SetPollInterval задать период опроса outbox, когда новых событий нет
+func (r *Relay) SetPollInterval(interval time.Duration) {
+	r.interval = interval
+}
+
+// SetRetention задать срок хранения доставленных событий
+func (r *Relay) SetRetention(retention time.Duration) {
+	r.retention = retention
+}
+
+// Run доставлять события, пока не отменён ctx
+func (r *Relay) Run(ctx context.Context) {
+	var purged time.Time
+	for {
+		if r.now().Sub(purged) >= purgeInterval {
+			if _, err := r.repo.Purge(r.now().Add(-r.retention)); err != nil {
+				log.Printf("error purging outbox: %v", err)
+			}
+			purged = r.now()
+		}
+
+		count, err := r.RunOnce(ctx)
+		if err != nil {
+			log.Printf("error relaying outbox: %v", err)
+		}
+		// полный пакет – вероятно, есть ещё события, опрашиваем сразу
+		if err == nil && count == r.batchSize {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(r.interval):
+		}
+	}
+}
+
+// RunOnce обработать один пакет и вернуть число занятых событий.
+// Если одно событие сущности не доставлено, её следующие события в этом пакете не отправляются.
+// Не отправленные события – после остановки, неудачи или на исходе аренды – возвращаются сразу.
+func (r *Relay) RunOnce(ctx context.Context) (int, error) {
+	// база хранит время с точностью до микросекунды, а Release сравнивает его с until
+	until := r.now().Add(leaseDuration).Truncate(time.Microsecond)
+	events, err := r.repo.Claim(r.batchSize, r.now(), until)
+	if errors.Is(err, ErrBusy) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("error claiming pending events: %w", err)
+	}
+
+	var unsent []int64
+	blocked := map[string]bool{}
+	for i, event := range events {
+		key := fmt.Sprintf("%s/%d", event.AggregateType, event.AggregateId)
+		if blocked[key] {
+			unsent = append(unsent, event.Id)
+			continue
+		}
+		if r.now().Add(time.Duration(len(r.publishers)) * publishTimeout).After(until) {
+			unsent = append(unsent, eventIds(events[i:])...)
+			break
+		}
+
+		err := r.publish(ctx, event)
+		// при остановке недоставленное событие не считается неудачной попыткой
+		if ctx.Err() != nil {
+			unsent = append(unsent, eventIds(events[i:])...)
+			break
+		}
+		if err != nil {
+			blocked[key] = true
+			attempts := event.Attempts + 1
+			next := r.now().Add(r.backoff(attempts))
+			if err := r.repo.Failed(event.Id, attempts, next, err.Error()); err != nil {
+				return 0, fmt.Errorf("error postponing event with id %d: %w", event.Id, err)
+			}
+			continue
+		}
+
+		if err := r.repo.Delivered(event.Id, r.now()); err != nil {
+			return 0, fmt.Errorf("error marking event with id %d as delivered: %w", event.Id, err)
+		}
+	}
+
+	if len(unsent) > 0 {
+		if err := r.repo.Release(unsent, until, r.now()); err != nil {
+			return 0, fmt.Errorf("error releasing unsent events: %w", err)
+		}
+	}
+
+	return len(events), nil
+}
+
+func eventIds(events []Event) []int64 {
+	ids := make([]int64, len(events))
+	for i, event := range events {
+		ids[i] = event.Id
+	}
+
+	return ids
+}
+
+func (r *Relay) publish(ctx context.Context, event Event) error {
+	for _, publisher := range r.publishers {
+		publishCtx, cancel := context.WithTimeout(ctx, publishTimeout)
+		err := publisher.Publish(publishCtx, event)
+		cancel()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// backoff пауза перед попыткой attempts+1: minBackoff, 2·minBackoff, 4·minBackoff … не больше maxBackoff
+func (r *Relay) backoff(attempts int) time.Duration {
+	delay := r.minBackoff
+	for i := 1; i < attempts && delay < r.maxBackoff; i++ {
+		delay *= 2
+	}
+
+	return min(delay, r.maxBackoff)
+}
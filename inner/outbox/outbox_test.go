@@ -0,0 +1,319 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	assertpackage "github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+)
+
+// StubEvent событие в памяти вместе с состоянием доставки
+type StubEvent struct {
+	Event
+	next      time.Time
+	delivered bool
+	reason    string
+}
+
+// StubRepo повторяет выборку Repository.Claim над событиями в памяти
+type StubRepo struct {
+	events []*StubEvent
+	busy   bool
+	purged time.Time
+}
+
+func (r *StubRepo) Add(events ...Event) {
+	for _, event := range events {
+		r.events = append(r.events, &StubEvent{Event: event})
+	}
+}
+
+func (r *StubRepo) Purge(before time.Time) (int64, error) {
+	r.purged = before
+	return 0, nil
+}
+
+func (r *StubRepo) find(id int64) *StubEvent {
+	for _, event := range r.events {
+		if event.Id == id {
+			return event
+		}
+	}
+	return nil
+}
+
+func (r *StubRepo) Claim(limit int, now time.Time, until time.Time) ([]Event, error) {
+	if r.busy {
+		return nil, ErrBusy
+	}
+	var claimed []*StubEvent
+	for _, event := range r.events {
+		if event.delivered || event.next.After(now) || len(claimed) == limit {
+			continue
+		}
+		blocked := slices.ContainsFunc(r.events, func(earlier *StubEvent) bool {
+			return !earlier.delivered && earlier.AggregateType == event.AggregateType &&
+				earlier.AggregateId == event.AggregateId && earlier.Id < event.Id && earlier.next.After(now)
+		})
+		if !blocked {
+			claimed = append(claimed, event)
+		}
+	}
+
+	var events []Event
+	for _, event := range claimed {
+		event.next = until
+		events = append(events, event.Event)
+	}
+	return events, nil
+}
+
+func (r *StubRepo) Delivered(id int64, _ time.Time) error {
+	r.find(id).delivered = true
+	return nil
+}
+
+func (r *StubRepo) Failed(id int64, attempts int, next time.Time, reason string) error {
+	event := r.find(id)
+	event.Attempts, event.next, event.reason = attempts, next, reason
+	return nil
+}
+
+func (r *StubRepo) Release(ids []int64, until time.Time, at time.Time) error {
+	for _, id := range ids {
+		if event := r.find(id); !event.delivered && event.next.Equal(until) {
+			event.next = at
+		}
+	}
+	return nil
+}
+
+// StubPublisher запоминает события; fail решает, отказать ли в доставке
+type StubPublisher struct {
+	published []int64
+	fail      func(Event) bool
+}
+
+func (p *StubPublisher) Publish(_ context.Context, event Event) error {
+	if p.fail != nil && p.fail(event) {
+		return errors.New("unavailable")
+	}
+	p.published = append(p.published, event.Id)
+	return nil
+}
+
+func employeeEvent(id int64, employeeId int64, eventType string) Event {
+	return Event{Id: id, Type: eventType, AggregateType: "employee", AggregateId: employeeId, Payload: json.RawMessage(`{}`)}
+}
+
+func TestRelay(t *testing.T) {
+	var assert = assertpackage.New(t)
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("should deliver events in order and mark them delivered", func(t *testing.T) {
+		repo := &StubRepo{}
+		repo.Add(employeeEvent(1, 10, EmployeeCreated), employeeEvent(2, 10, RoleAssigned), employeeEvent(3, 11, EmployeeCreated))
+		publisher := &StubPublisher{}
+		relay := NewRelay(repo, publisher)
+		relay.now = func() time.Time { return now }
+
+		count, err := relay.RunOnce(context.Background())
+
+		assert.Nil(err)
+		assert.Equal(3, count)
+		assert.Equal([]int64{1, 2, 3}, publisher.published)
+		count, _ = relay.RunOnce(context.Background())
+		assert.Equal(0, count)
+	})
+
+	t.Run("should hold back later events of an entity until the failed one is delivered", func(t *testing.T) {
+		repo := &StubRepo{}
+		repo.Add(employeeEvent(1, 10, EmployeeCreated), employeeEvent(2, 11, EmployeeCreated),
+			employeeEvent(3, 10, EmployeeUpdated), employeeEvent(4, 11, EmployeeUpdated))
+		down := true
+		publisher := &StubPublisher{fail: func(event Event) bool { return down && event.Id == 1 }}
+		relay := NewRelay(repo, publisher)
+		relay.now = func() time.Time { return now }
+
+		_, err := relay.RunOnce(context.Background())
+
+		assert.Nil(err)
+		assert.Equal([]int64{2, 4}, publisher.published)
+		assert.Equal(1, repo.find(1).Attempts)
+		assert.Equal("unavailable", repo.find(1).reason)
+		assert.Equal(now.Add(DefaultMinBackoff), repo.find(1).next)
+
+		// до срока повтора ничего не отправляется, даже более поздние события той же сущности
+		count, _ := relay.RunOnce(context.Background())
+		assert.Equal(0, count)
+
+		down = false
+		now = now.Add(time.Minute)
+		_, err = relay.RunOnce(context.Background())
+
+		assert.Nil(err)
+		assert.Equal([]int64{2, 4, 1, 3}, publisher.published)
+	})
+
+	t.Run("should double the backoff up to the limit", func(t *testing.T) {
+		relay := NewRelay(&StubRepo{})
+		relay.SetBackoff(time.Second, time.Minute)
+
+		var delays []time.Duration
+		for attempts := 1; attempts <= 8; attempts++ {
+			delays = append(delays, relay.backoff(attempts))
+		}
+
+		assert.Equal([]time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second,
+			16 * time.Second, 32 * time.Second, time.Minute, time.Minute}, delays)
+	})
+
+	t.Run("should require every publisher to accept the event", func(t *testing.T) {
+		repo := &StubRepo{}
+		repo.Add(employeeEvent(1, 10, EmployeeCreated))
+		first := &StubPublisher{}
+		second := &StubPublisher{fail: func(Event) bool { return true }}
+		relay := NewRelay(repo, first, second)
+
+		_, err := relay.RunOnce(context.Background())
+
+		assert.Nil(err)
+		assert.False(repo.find(1).delivered)
+		assert.Equal(1, repo.find(1).Attempts)
+	})
+
+	t.Run("should skip the batch while another instance relays", func(t *testing.T) {
+		repo := &StubRepo{busy: true}
+		repo.Add(employeeEvent(1, 10, EmployeeCreated))
+		publisher := &StubPublisher{}
+
+		count, err := NewRelay(repo, publisher).RunOnce(context.Background())
+
+		assert.Nil(err)
+		assert.Equal(0, count)
+		assert.Nil(publisher.published)
+	})
+
+	t.Run("should not count an attempt when stopped", func(t *testing.T) {
+		repo := &StubRepo{}
+		repo.Add(employeeEvent(1, 10, EmployeeCreated))
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		publisher := &StubPublisher{fail: func(Event) bool { return ctx.Err() != nil }}
+
+		_, err := NewRelay(repo, publisher).RunOnce(ctx)
+
+		assert.Nil(err)
+		assert.False(repo.find(1).delivered)
+		assert.Equal(0, repo.find(1).Attempts)
+		assert.False(repo.find(1).next.After(time.Now()))
+	})
+
+	t.Run("should not let another instance send an event while it is being published", func(t *testing.T) {
+		repo := &StubRepo{}
+		repo.Add(employeeEvent(1, 10, EmployeeCreated), employeeEvent(2, 10, EmployeeUpdated))
+		other := NewRelay(repo, &StubPublisher{})
+		other.now = func() time.Time { return now }
+		var concurrent []int
+		publisher := &StubPublisher{fail: func(event Event) bool {
+			count, _ := other.RunOnce(context.Background())
+			concurrent = append(concurrent, count)
+			return false
+		}}
+		relay := NewRelay(repo, publisher)
+		relay.now = func() time.Time { return now }
+
+		count, err := relay.RunOnce(context.Background())
+
+		assert.Nil(err)
+		assert.Equal(2, count)
+		assert.Equal([]int{0, 0}, concurrent)
+		assert.Equal([]int64{1, 2}, publisher.published)
+	})
+
+	t.Run("should release held back events of a failed entity", func(t *testing.T) {
+		repo := &StubRepo{}
+		repo.Add(employeeEvent(1, 10, EmployeeCreated), employeeEvent(2, 10, EmployeeUpdated))
+		relay := NewRelay(repo, &StubPublisher{fail: func(event Event) bool { return event.Id == 1 }})
+		relay.now = func() time.Time { return now }
+
+		_, err := relay.RunOnce(context.Background())
+
+		assert.Nil(err)
+		assert.Equal(now.Add(DefaultMinBackoff), repo.find(1).next)
+		assert.Equal(now, repo.find(2).next)
+		assert.Equal(0, repo.find(2).Attempts)
+	})
+
+	t.Run("should send events claimed by a stopped instance after the lease", func(t *testing.T) {
+		repo := &StubRepo{}
+		repo.Add(employeeEvent(1, 10, EmployeeCreated))
+		_, _ = repo.Claim(DefaultBatchSize, now, now.Add(leaseDuration))
+		publisher := &StubPublisher{}
+		relay := NewRelay(repo, publisher)
+		relay.now = func() time.Time { return now }
+
+		count, _ := relay.RunOnce(context.Background())
+		assert.Equal(0, count)
+
+		relay.now = func() time.Time { return now.Add(leaseDuration) }
+		count, _ = relay.RunOnce(context.Background())
+		assert.Equal(1, count)
+		assert.Equal([]int64{1}, publisher.published)
+	})
+}
+
+func TestPublishers(t *testing.T) {
+	var assert = assertpackage.New(t)
+	event := Event{Id: 42, Type: RoleAssigned, AggregateType: "employee", AggregateId: 7,
+		Payload: json.RawMessage(`{"employee_id":7,"role_id":3}`), OccurredAt: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)}
+
+	t.Run("webhook should post the event as json", func(t *testing.T) {
+		var received Event
+		var headers http.Header
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			headers = r.Header
+			_ = json.NewDecoder(r.Body).Decode(&received)
+			w.WriteHeader(http.StatusAccepted)
+		}))
+		defer server.Close()
+
+		err := NewWebhookPublisher(server.URL, nil).Publish(context.Background(), event)
+
+		assert.Nil(err)
+		assert.Equal("42", headers.Get("X-Event-Id"))
+		assert.Equal(RoleAssigned, headers.Get("X-Event-Type"))
+		assert.Equal(event.Type, received.Type)
+		assert.JSONEq(string(event.Payload), string(received.Payload))
+	})
+
+	t.Run("webhook should fail on an error status", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer server.Close()
+
+		err := NewWebhookPublisher(server.URL, nil).Publish(context.Background(), event)
+
+		assert.ErrorContains(err, "503")
+	})
+
+	t.Run("writer should write one json line per event", func(t *testing.T) {
+		var out bytes.Buffer
+		publisher := NewWriterPublisher(&out)
+
+		assert.Nil(publisher.Publish(context.Background(), event))
+		assert.Nil(publisher.Publish(context.Background(), event))
+
+		lines := bytes.Split(bytes.TrimSpace(out.Bytes()), []byte("\n"))
+		assert.Len(lines, 2)
+		assert.JSONEq(`{"id":42,"type":"RoleAssigned","aggregate_type":"employee","aggregate_id":7,
+			"payload":{"employee_id":7,"role_id":3},"occurred_at":"2024-05-01T12:00:00Z"}`, string(lines[0]))
+	})
+}
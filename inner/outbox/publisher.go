@@ -0,0 +1,110 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+)
+
+// WebhookPublisher отправляет событие POST-запросом с телом в JSON; успех – любой ответ 2xx
+type WebhookPublisher struct {
+	url    string
+	client *http.Client
+}
+
+func NewWebhookPublisher(url string, client *http.Client) *WebhookPublisher {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	return &WebhookPublisher{url: url, client: client}
+}
+
+func (p *WebhookPublisher) Publish(ctx context.Context, event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("X-Event-Id", strconv.FormatInt(event.Id, 10))
+	request.Header.Set("X-Event-Type", event.Type)
+
+	response, err := p.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = response.Body.Close() }()
+	_, _ = io.Copy(io.Discard, io.LimitReader(response.Body, 64<<10))
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return fmt.Errorf("webhook responded with status %d", response.StatusCode)
+	}
+
+	return nil
+}
+
+// WriterPublisher пишет события по одному JSON на строку, например в файл или на стандартный вывод
+type WriterPublisher struct {
+	mu  sync.Mutex
+	out io.Writer
+}
+
+func NewWriterPublisher(out io.Writer) *WriterPublisher {
+	return &WriterPublisher{out: out}
+}
+
+func (p *WriterPublisher) Publish(_ context.Context, event Event) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	_, err = p.out.Write(append(line, '\n'))
+
+	return err
+}
+
+// maxNotifyPayload предел размера сообщения NOTIFY в Postgres с запасом
+const maxNotifyPayload = 7900
+
+// NotifyPublisher отправляет событие в канал Postgres через pg_notify. Сообщение NOTIFY ограничено
+// 8000 байтами, поэтому слишком большое событие отправляется без payload – получатель может
+// запросить сущность сам.
+type NotifyPublisher struct {
+	db      *sqlx.DB
+	channel string
+}
+
+func NewNotifyPublisher(db *sqlx.DB, channel string) *NotifyPublisher {
+	return &NotifyPublisher{db: db, channel: channel}
+}
+
+func (p *NotifyPublisher) Publish(ctx context.Context, event Event) error {
+	message, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	if len(message) > maxNotifyPayload {
+		event.Payload = nil
+		if message, err = json.Marshal(event); err != nil {
+			return err
+		}
+	}
+
+	_, err = p.db.ExecContext(ctx, "SELECT pg_notify($1, $2)", p.channel, string(message))
+
+	return err
+}
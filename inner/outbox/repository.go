@@ -0,0 +1,106 @@
+package outbox
+
+import (
+	"context"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"time"
+)
+
+type Repository struct {
+	db *sqlx.DB
+}
+
+func NewRepository(db *sqlx.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// Claim события занимаются по очереди во всех экземплярах: следующий экземпляр видит аренду
+// предыдущего и не берёт ни занятые события, ни более поздние события тех же сущностей
+func (r *Repository) Claim(limit int, now time.Time, until time.Time) ([]Event, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var locked bool
+	if err := tx.GetContext(ctx, &locked, "SELECT pg_try_advisory_xact_lock(hashtext('idm_outbox'))"); err != nil {
+		return nil, err
+	}
+	if !locked {
+		return nil, ErrBusy
+	}
+
+	var events []Event
+	err = tx.SelectContext(ctx, &events,
+		`WITH claimed AS (
+			UPDATE outbox SET next_attempt_at = $3
+			WHERE id IN (
+				SELECT o.id
+				FROM outbox o
+				WHERE o.delivered_at IS NULL AND o.next_attempt_at <= $1
+					AND NOT EXISTS (
+						SELECT 1 FROM outbox p
+						WHERE p.delivered_at IS NULL AND p.aggregate_type = o.aggregate_type
+							AND p.aggregate_id = o.aggregate_id AND p.id < o.id AND p.next_attempt_at > $1
+					)
+				ORDER BY o.id
+				LIMIT $2
+			)
+			RETURNING id, event_type, aggregate_type, aggregate_id, payload, occurred_at, attempts
+		)
+		SELECT * FROM claimed ORDER BY id`, now, limit, until)
+	if err != nil {
+		return nil, err
+	}
+
+	return events, tx.Commit()
+}
+
+func (r *Repository) Delivered(id int64, at time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := r.db.ExecContext(ctx, "UPDATE outbox SET delivered_at = $1, last_error = '' WHERE id = $2", at, id)
+
+	return err
+}
+
+func (r *Repository) Failed(id int64, attempts int, next time.Time, reason string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := r.db.ExecContext(ctx,
+		"UPDATE outbox SET attempts = $1, next_attempt_at = $2, last_error = $3 WHERE id = $4",
+		attempts, next, reason, id)
+
+	return err
+}
+
+func (r *Repository) Release(ids []int64, until time.Time, at time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := r.db.ExecContext(ctx,
+		`UPDATE outbox SET next_attempt_at = $1
+		WHERE id = ANY($2) AND delivered_at IS NULL AND next_attempt_at = $3`,
+		at, pq.Array(ids), until)
+
+	return err
+}
+
+func (r *Repository) Purge(before time.Time) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := r.db.ExecContext(ctx, "DELETE FROM outbox WHERE delivered_at < $1", before)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
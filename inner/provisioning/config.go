@@ -0,0 +1,80 @@
+package provisioning
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+)
+
+// Что делать с записью, которой в целевой системе быть не должно
+const (
+	DeprovisionDisable = "disable"
+	DeprovisionDelete  = "delete"
+	// DeprovisionIgnore лишние записи не трогать, например, если в системе есть чужие учётные записи
+	DeprovisionIgnore = "ignore"
+)
+
+var ErrInvalidConfig = errors.New("invalid provisioning config")
+
+// Config коннекторы к целевым системам
+type Config struct {
+	Connectors []ConnectorConfig `json:"connectors"`
+}
+
+// ConnectorConfig настройки одного коннектора
+type ConnectorConfig struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+	// Roles роль → право в целевой системе. Запись получают сотрудники хотя бы с одной из ролей;
+	// пустое право означает, что роль даёт только саму запись.
+	Roles map[string]string `json:"roles"`
+	// Deprovision disable (по умолчанию), delete или ignore
+	Deprovision string `json:"deprovision"`
+	// Settings настройки типа коннектора, например, путь к файлу или адрес API
+	Settings json.RawMessage `json:"settings"`
+}
+
+// LoadConfig прочитать конфигурацию в формате JSON и проверить её
+func LoadConfig(reader io.Reader) (Config, error) {
+	var config Config
+	if err := json.NewDecoder(reader).Decode(&config); err != nil {
+		return Config{}, fmt.Errorf("error decoding provisioning config: %w", err)
+	}
+
+	names := map[string]bool{}
+	for i := range config.Connectors {
+		connector := &config.Connectors[i]
+		if connector.Name == "" {
+			return Config{}, fmt.Errorf("%w: connector %d has no name", ErrInvalidConfig, i+1)
+		}
+		if names[connector.Name] {
+			return Config{}, fmt.Errorf("%w: %w %q", ErrInvalidConfig, ErrDuplicateName, connector.Name)
+		}
+		names[connector.Name] = true
+		if connector.Deprovision == "" {
+			connector.Deprovision = DeprovisionDisable
+		}
+		if !slices.Contains([]string{DeprovisionDisable, DeprovisionDelete, DeprovisionIgnore}, connector.Deprovision) {
+			return Config{}, fmt.Errorf("%w: connector %q has unknown deprovision mode %q",
+				ErrInvalidConfig, connector.Name, connector.Deprovision)
+		}
+		if len(connector.Roles) == 0 {
+			return Config{}, fmt.Errorf("%w: connector %q maps no roles", ErrInvalidConfig, connector.Name)
+		}
+	}
+
+	return config, nil
+}
+
+// roleNames роли, дающие запись в целевой системе
+func (c ConnectorConfig) roleNames() []string {
+	names := make([]string, 0, len(c.Roles))
+	for name := range c.Roles {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	return names
+}
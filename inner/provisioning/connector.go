@@ -0,0 +1,112 @@
+package provisioning
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+)
+
+var (
+	ErrUnknownType      = errors.New("unknown connector type")
+	ErrAccountExists    = errors.New("account already exists")
+	ErrAccountNotFound  = errors.New("account not found")
+	ErrDuplicateName    = errors.New("duplicate connector name")
+	ErrUnknownConnector = errors.New("unknown connector")
+)
+
+// Account учётная запись сотрудника в целевой системе; UserName – ключ сопоставления
+type Account struct {
+	UserName     string   `json:"user_name"`
+	Name         string   `json:"name"`
+	Email        string   `json:"email"`
+	Active       bool     `json:"active"`
+	Entitlements []string `json:"entitlements"`
+}
+
+// Connector операции над учётными записями одной целевой системы.
+// Удаление отсутствующей записи или права, как и повторная выдача права, не считаются ошибкой.
+type Connector interface {
+	// Accounts текущее состояние целевой системы для сверки
+	Accounts(ctx context.Context) ([]Account, error)
+	// CreateAccount создать запись без прав; права выдаются отдельно
+	CreateAccount(ctx context.Context, account Account) error
+	// UpdateAccount изменить имя, почту и активность записи
+	UpdateAccount(ctx context.Context, account Account) error
+	DisableAccount(ctx context.Context, userName string) error
+	DeleteAccount(ctx context.Context, userName string) error
+	AddEntitlement(ctx context.Context, userName string, entitlement string) error
+	RemoveEntitlement(ctx context.Context, userName string, entitlement string) error
+}
+
+// Factory создать коннектор по его настройкам из конфигурации
+type Factory func(settings json.RawMessage) (Connector, error)
+
+// Registry типы коннекторов, доступные в конфигурации
+type Registry struct {
+	mu        sync.RWMutex
+	factories map[string]Factory
+}
+
+// NewRegistry реестр со встроенными типами file и rest
+func NewRegistry() *Registry {
+	registry := &Registry{factories: map[string]Factory{}}
+	registry.Register("file", NewFileConnector)
+	registry.Register("rest", NewRestConnector)
+
+	return registry
+}
+
+// Register добавить тип коннектора; повторная регистрация заменяет прежний
+func (r *Registry) Register(kind string, factory Factory) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.factories[kind] = factory
+}
+
+func (r *Registry) Types() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	types := make([]string, 0, len(r.factories))
+	for kind := range r.factories {
+		types = append(types, kind)
+	}
+	sort.Strings(types)
+
+	return types
+}
+
+// Build создать коннектор по его конфигурации
+func (r *Registry) Build(config ConnectorConfig) (Connector, error) {
+	r.mu.RLock()
+	factory, ok := r.factories[config.Type]
+	r.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w %q of connector %q", ErrUnknownType, config.Type, config.Name)
+	}
+
+	connector, err := factory(config.Settings)
+	if err != nil {
+		return nil, fmt.Errorf("error configuring connector %q: %w", config.Name, err)
+	}
+
+	return connector, nil
+}
+
+// normalize права без повторов в порядке сортировки, чтобы состояния можно было сравнивать
+func normalize(entitlements []string) []string {
+	normalized := make([]string, 0, len(entitlements))
+	for _, entitlement := range entitlements {
+		if entitlement != "" {
+			normalized = append(normalized, entitlement)
+		}
+	}
+	slices.Sort(normalized)
+
+	return slices.Compact(normalized)
+}
@@ -0,0 +1,44 @@
+package provisioning
+
+import "time"
+
+// Действия сверки
+const (
+	ActionCreate            = "create"
+	ActionUpdate            = "update"
+	ActionDisable           = "disable"
+	ActionDelete            = "delete"
+	ActionAddEntitlement    = "add_entitlement"
+	ActionRemoveEntitlement = "remove_entitlement"
+)
+
+// Report изменения, которые сверка внесла или внесла бы при DryRun
+type Report struct {
+	Connector  string    `json:"connector"`
+	DryRun     bool      `json:"dry_run"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	Changes    []Change  `json:"changes"`
+	// Failed сколько изменений не удалось применить; подробности – в Error изменения
+	Failed   int      `json:"failed"`
+	Warnings []string `json:"warnings"`
+	// Error почему сверка не выполнена целиком
+	Error string `json:"error,omitempty"`
+}
+
+// Change одно изменение в целевой системе
+type Change struct {
+	Action      string `json:"action"`
+	UserName    string `json:"user_name"`
+	Entitlement string `json:"entitlement,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+// Status коннектор и его последняя сверка
+type Status struct {
+	Name        string   `json:"name"`
+	Type        string   `json:"type"`
+	Roles       []string `json:"roles"`
+	Deprovision string   `json:"deprovision"`
+	LastReport  *Report  `json:"last_report"`
+}
@@ -0,0 +1,187 @@
+package provisioning
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// fileHeader столбцы файла; права перечисляются через точку с запятой
+var fileHeader = []string{"user_name", "name", "email", "active", "entitlements"}
+
+// FileSettings настройки коннектора file
+type FileSettings struct {
+	Path string `json:"path"`
+}
+
+// FileConnector записи в CSV-файле, который забирает система без API.
+// Файл переписывается целиком через временный файл, поэтому читатель не увидит его наполовину записанным.
+type FileConnector struct {
+	path string
+	mu   sync.Mutex
+}
+
+func NewFileConnector(settings json.RawMessage) (Connector, error) {
+	var parsed FileSettings
+	if err := json.Unmarshal(settings, &parsed); err != nil {
+		return nil, fmt.Errorf("error decoding file connector settings: %w", err)
+	}
+	if parsed.Path == "" {
+		return nil, fmt.Errorf("%w: file connector requires a path", ErrInvalidConfig)
+	}
+
+	return &FileConnector{path: parsed.Path}, nil
+}
+
+func (c *FileConnector) Accounts(_ context.Context) ([]Account, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.read()
+}
+
+func (c *FileConnector) CreateAccount(_ context.Context, account Account) error {
+	return c.modify(func(accounts []Account) ([]Account, error) {
+		if slices.ContainsFunc(accounts, func(a Account) bool { return a.UserName == account.UserName }) {
+			return nil, fmt.Errorf("%w: %s", ErrAccountExists, account.UserName)
+		}
+		account.Entitlements = nil
+		return append(accounts, account), nil
+	})
+}
+
+func (c *FileConnector) UpdateAccount(_ context.Context, account Account) error {
+	return c.change(account.UserName, true, func(existing *Account) {
+		existing.Name, existing.Email, existing.Active = account.Name, account.Email, account.Active
+	})
+}
+
+func (c *FileConnector) DisableAccount(_ context.Context, userName string) error {
+	return c.change(userName, true, func(existing *Account) { existing.Active = false })
+}
+
+func (c *FileConnector) DeleteAccount(_ context.Context, userName string) error {
+	return c.modify(func(accounts []Account) ([]Account, error) {
+		return slices.DeleteFunc(accounts, func(a Account) bool { return a.UserName == userName }), nil
+	})
+}
+
+func (c *FileConnector) AddEntitlement(_ context.Context, userName string, entitlement string) error {
+	return c.change(userName, true, func(existing *Account) {
+		existing.Entitlements = normalize(append(existing.Entitlements, entitlement))
+	})
+}
+
+func (c *FileConnector) RemoveEntitlement(_ context.Context, userName string, entitlement string) error {
+	return c.change(userName, false, func(existing *Account) {
+		existing.Entitlements = slices.DeleteFunc(existing.Entitlements, func(e string) bool { return e == entitlement })
+	})
+}
+
+// change изменить одну запись; required – отсутствие записи считается ошибкой
+func (c *FileConnector) change(userName string, required bool, apply func(*Account)) error {
+	return c.modify(func(accounts []Account) ([]Account, error) {
+		index := slices.IndexFunc(accounts, func(a Account) bool { return a.UserName == userName })
+		if index < 0 && required {
+			return nil, fmt.Errorf("%w: %s", ErrAccountNotFound, userName)
+		}
+		if index >= 0 {
+			apply(&accounts[index])
+		}
+		return accounts, nil
+	})
+}
+
+func (c *FileConnector) modify(apply func([]Account) ([]Account, error)) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	accounts, err := c.read()
+	if err != nil {
+		return err
+	}
+	accounts, err = apply(accounts)
+	if err != nil {
+		return err
+	}
+
+	return c.write(accounts)
+}
+
+func (c *FileConnector) read() ([]Account, error) {
+	file, err := os.Open(c.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return []Account{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = file.Close() }()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = len(fileHeader)
+	if _, err := reader.Read(); err != nil {
+		if errors.Is(err, io.EOF) {
+			return []Account{}, nil
+		}
+		return nil, fmt.Errorf("error reading %s: %w", c.path, err)
+	}
+
+	accounts := []Account{}
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return accounts, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading %s: %w", c.path, err)
+		}
+		active, err := strconv.ParseBool(record[3])
+		if err != nil {
+			return nil, fmt.Errorf("error reading %s: invalid active %q of %s", c.path, record[3], record[0])
+		}
+		accounts = append(accounts, Account{
+			UserName:     record[0],
+			Name:         record[1],
+			Email:        record[2],
+			Active:       active,
+			Entitlements: normalize(strings.Split(record[4], ";")),
+		})
+	}
+}
+
+func (c *FileConnector) write(accounts []Account) error {
+	slices.SortFunc(accounts, func(a, b Account) int { return strings.Compare(a.UserName, b.UserName) })
+
+	temporary, err := os.CreateTemp(filepath.Dir(c.path), "."+filepath.Base(c.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(temporary.Name()) }()
+
+	writer := csv.NewWriter(temporary)
+	_ = writer.Write(fileHeader)
+	for _, account := range accounts {
+		_ = writer.Write([]string{account.UserName, account.Name, account.Email,
+			strconv.FormatBool(account.Active), strings.Join(account.Entitlements, ";")})
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		_ = temporary.Close()
+		return err
+	}
+	if err := temporary.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(temporary.Name(), c.path)
+}
@@ -0,0 +1,58 @@
+package provisioning
+
+import (
+	"errors"
+	"idm/inner/httpapi"
+	"idm/inner/outbox"
+	"net/http"
+	"strconv"
+)
+
+// Handler коннекторы и запуск сверки: POST /{name}/reconcile?dry_run=true
+type Handler struct {
+	service *Service
+	mux     *http.ServeMux
+}
+
+func NewHandler(service *Service) *Handler {
+	h := &Handler{service: service, mux: http.NewServeMux()}
+
+	h.mux.HandleFunc("GET /{$}", h.list)
+	h.mux.HandleFunc("POST /{name}/reconcile", h.reconcile)
+
+	return h
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+func (h *Handler) list(w http.ResponseWriter, r *http.Request) {
+	httpapi.WriteJSON(w, http.StatusOK, h.service.Connectors())
+}
+
+func (h *Handler) reconcile(w http.ResponseWriter, r *http.Request) {
+	var dryRun bool
+	if value := r.URL.Query().Get("dry_run"); value != "" {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			httpapi.WriteError(w, http.StatusBadRequest, "invalid dry_run")
+			return
+		}
+		dryRun = parsed
+	}
+
+	report, err := h.service.Reconcile(r.Context(), r.PathValue("name"), dryRun)
+	switch {
+	case err == nil:
+		httpapi.WriteJSON(w, http.StatusOK, report)
+	case errors.Is(err, ErrUnknownConnector):
+		httpapi.WriteError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, outbox.ErrBusy):
+		httpapi.WriteError(w, http.StatusConflict, "reconciliation of the connector is already running")
+	case errors.Is(err, ErrNoHolders):
+		httpapi.WriteJSON(w, http.StatusConflict, map[string]any{"error": err.Error(), "report": report})
+	default:
+		httpapi.WriteJSON(w, http.StatusBadGateway, map[string]any{"error": err.Error(), "report": report})
+	}
+}
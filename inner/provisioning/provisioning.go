@@ -0,0 +1,268 @@
+package provisioning
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"idm/inner/employee"
+	"idm/inner/outbox"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrNoHolders = errors.New("no employee holds a mapped role, refusing to deprovision every account")
+
+type Repo interface {
+	// Begin начать сверку коннектора; outbox.ErrBusy, если её уже ведёт другой экземпляр
+	Begin(connector string) (Batch, error)
+}
+
+// Batch сверка одного коннектора в одной транзакции
+type Batch interface {
+	// Holders сотрудники с любой из ролей, по строке на каждую роль
+	Holders(roles []string) ([]*Holder, error)
+	Commit() error
+	Rollback() error
+}
+
+type target struct {
+	config    ConnectorConfig
+	connector Connector
+}
+
+// Service сверяет записи в целевых системах с сотрудниками и их ролями
+type Service struct {
+	repo    Repo
+	targets []*target
+	reports map[string]Report
+	now     func() time.Time
+	mu      sync.Mutex
+}
+
+// NewService создать коннекторы из конфигурации типами из реестра
+func NewService(repository Repo, registry *Registry, config Config) (*Service, error) {
+	s := &Service{repo: repository, reports: map[string]Report{}, now: time.Now}
+	for _, connectorConfig := range config.Connectors {
+		connector, err := registry.Build(connectorConfig)
+		if err != nil {
+			return nil, err
+		}
+		s.targets = append(s.targets, &target{config: connectorConfig, connector: connector})
+	}
+
+	return s, nil
+}
+
+// Connectors настроенные коннекторы и итоги их последней сверки
+func (s *Service) Connectors() []Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	statuses := []Status{}
+	for _, t := range s.targets {
+		status := Status{Name: t.config.Name, Type: t.config.Type, Roles: t.config.roleNames(), Deprovision: t.config.Deprovision}
+		if report, ok := s.reports[t.config.Name]; ok {
+			status.LastReport = &report
+		}
+		statuses = append(statuses, status)
+	}
+
+	return statuses
+}
+
+// Run сверять все коннекторы с периодом interval, пока не отменён ctx
+func (s *Service) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		for _, t := range s.targets {
+			report, err := s.Reconcile(ctx, t.config.Name, false)
+			switch {
+			case errors.Is(err, outbox.ErrBusy):
+			case err != nil:
+				log.Printf("error reconciling connector %s: %v", t.config.Name, err)
+			case report.Failed > 0:
+				log.Printf("connector %s: %d of %d changes failed", t.config.Name, report.Failed, len(report.Changes))
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Reconcile привести записи целевой системы к ролям сотрудников. Ошибка отдельного изменения
+// не прерывает сверку: она попадает в отчёт, а изменение повторится при следующей.
+func (s *Service) Reconcile(ctx context.Context, name string, dryRun bool) (Report, error) {
+	index := slices.IndexFunc(s.targets, func(t *target) bool { return t.config.Name == name })
+	if index < 0 {
+		return Report{}, fmt.Errorf("%w %q", ErrUnknownConnector, name)
+	}
+	t := s.targets[index]
+
+	report := Report{Connector: name, DryRun: dryRun, StartedAt: s.now(), Changes: []Change{}, Warnings: []string{}}
+	err := s.reconcile(ctx, t, &report)
+	report.FinishedAt = s.now()
+	if errors.Is(err, outbox.ErrBusy) {
+		return report, err
+	}
+	if err != nil {
+		report.Error = err.Error()
+	}
+	if !dryRun {
+		s.mu.Lock()
+		s.reports[name] = report
+		s.mu.Unlock()
+	}
+
+	return report, err
+}
+
+func (s *Service) reconcile(ctx context.Context, t *target, report *Report) error {
+	batch, err := s.repo.Begin(t.config.Name)
+	if err != nil {
+		if errors.Is(err, outbox.ErrBusy) {
+			return err
+		}
+		return fmt.Errorf("error starting reconciliation of connector %s: %w", t.config.Name, err)
+	}
+	defer func() { _ = batch.Rollback() }()
+
+	holders, err := batch.Holders(t.config.roleNames())
+	if err != nil {
+		return fmt.Errorf("error finding holders of roles of connector %s: %w", t.config.Name, err)
+	}
+	desired := s.desired(t.config, holders, report)
+
+	accounts, err := t.connector.Accounts(ctx)
+	if err != nil {
+		return fmt.Errorf("error reading accounts of connector %s: %w", t.config.Name, err)
+	}
+	actual := map[string]Account{}
+	for _, account := range accounts {
+		actual[account.UserName] = account
+	}
+
+	if len(desired) == 0 && len(actual) > 0 && t.config.Deprovision != DeprovisionIgnore {
+		return ErrNoHolders
+	}
+
+	apply := func(change Change, operation func() error) bool {
+		if !report.DryRun {
+			if err := operation(); err != nil {
+				change.Error = err.Error()
+				report.Failed++
+			}
+		}
+		report.Changes = append(report.Changes, change)
+		return change.Error == ""
+	}
+
+	for _, account := range sortedAccounts(desired) {
+		existing, found := actual[account.UserName]
+		switch {
+		case !found && !account.Active:
+			continue
+		case !found:
+			if !apply(Change{Action: ActionCreate, UserName: account.UserName}, func() error {
+				return t.connector.CreateAccount(ctx, Account{UserName: account.UserName, Name: account.Name,
+					Email: account.Email, Active: true})
+			}) {
+				continue
+			}
+			existing = Account{UserName: account.UserName, Name: account.Name, Email: account.Email, Active: true}
+		case existing.Name != account.Name || existing.Email != account.Email || (account.Active && !existing.Active):
+			apply(Change{Action: ActionUpdate, UserName: account.UserName}, func() error {
+				return t.connector.UpdateAccount(ctx, Account{UserName: account.UserName, Name: account.Name,
+					Email: account.Email, Active: account.Active || existing.Active})
+			})
+		}
+		if !account.Active && existing.Active {
+			apply(Change{Action: ActionDisable, UserName: account.UserName}, func() error {
+				return t.connector.DisableAccount(ctx, account.UserName)
+			})
+		}
+		s.entitlements(ctx, t.connector, existing, account.Entitlements, apply)
+	}
+
+	for _, account := range sortedAccounts(actual) {
+		if _, ok := desired[account.UserName]; ok || t.config.Deprovision == DeprovisionIgnore {
+			continue
+		}
+		if t.config.Deprovision == DeprovisionDelete {
+			apply(Change{Action: ActionDelete, UserName: account.UserName}, func() error {
+				return t.connector.DeleteAccount(ctx, account.UserName)
+			})
+			continue
+		}
+		if account.Active {
+			apply(Change{Action: ActionDisable, UserName: account.UserName}, func() error {
+				return t.connector.DisableAccount(ctx, account.UserName)
+			})
+		}
+		s.entitlements(ctx, t.connector, account, nil, apply)
+	}
+
+	return batch.Commit()
+}
+
+// entitlements выдать недостающие и отозвать лишние права записи
+func (s *Service) entitlements(ctx context.Context, connector Connector, existing Account, desired []string,
+	apply func(Change, func() error) bool) {
+	for _, entitlement := range desired {
+		if !slices.Contains(existing.Entitlements, entitlement) {
+			apply(Change{Action: ActionAddEntitlement, UserName: existing.UserName, Entitlement: entitlement}, func() error {
+				return connector.AddEntitlement(ctx, existing.UserName, entitlement)
+			})
+		}
+	}
+	for _, entitlement := range existing.Entitlements {
+		if !slices.Contains(desired, entitlement) {
+			apply(Change{Action: ActionRemoveEntitlement, UserName: existing.UserName, Entitlement: entitlement}, func() error {
+				return connector.RemoveEntitlement(ctx, existing.UserName, entitlement)
+			})
+		}
+	}
+}
+
+// desired записи, которые должны быть в целевой системе. Запись отключённого сотрудника
+// остаётся, но выключается; сотрудник без имени пользователя пропускается с предупреждением.
+func (s *Service) desired(config ConnectorConfig, holders []*Holder, report *Report) map[string]Account {
+	desired := map[string]Account{}
+	for _, holder := range holders {
+		if holder.UserName == "" {
+			warning := fmt.Sprintf("employee %d has role %q but no user name", holder.EmployeeId, holder.Role)
+			if !slices.Contains(report.Warnings, warning) {
+				report.Warnings = append(report.Warnings, warning)
+			}
+			continue
+		}
+
+		account, ok := desired[holder.UserName]
+		if !ok {
+			account = Account{UserName: holder.UserName, Name: holder.Name, Email: holder.Email,
+				Active: holder.Status == employee.StatusActive}
+		}
+		account.Entitlements = normalize(append(account.Entitlements, config.Roles[holder.Role]))
+		desired[holder.UserName] = account
+	}
+
+	return desired
+}
+
+func sortedAccounts(accounts map[string]Account) []Account {
+	sorted := make([]Account, 0, len(accounts))
+	for _, account := range accounts {
+		sorted = append(sorted, account)
+	}
+	slices.SortFunc(sorted, func(a, b Account) int { return strings.Compare(a.UserName, b.UserName) })
+
+	return sorted
+}
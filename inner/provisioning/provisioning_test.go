@@ -0,0 +1,357 @@
+package provisioning
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	assertpackage "github.com/stretchr/testify/assert"
+	"idm/inner/employee"
+	"idm/inner/outbox"
+)
+
+type StubRepo struct {
+	holders []*Holder
+	busy    bool
+}
+
+type StubBatch struct {
+	repo *StubRepo
+}
+
+func (r *StubRepo) Begin(_ string) (Batch, error) {
+	if r.busy {
+		return nil, outbox.ErrBusy
+	}
+	return &StubBatch{repo: r}, nil
+}
+
+func (b *StubBatch) Holders(roles []string) ([]*Holder, error) {
+	var holders []*Holder
+	for _, holder := range b.repo.holders {
+		if slices.Contains(roles, holder.Role) {
+			holders = append(holders, holder)
+		}
+	}
+	return holders, nil
+}
+
+func (b *StubBatch) Commit() error {
+	return nil
+}
+
+func (b *StubBatch) Rollback() error {
+	return nil
+}
+
+// Target целевая система с API по DefaultPaths; fail – имена пользователей, создание которых не удаётся
+type Target struct {
+	mu       sync.Mutex
+	server   *httptest.Server
+	accounts map[string]*Account
+	fail     map[string]bool
+	tokens   []string
+	requests []string
+}
+
+func NewTarget(t *testing.T, accounts ...Account) *Target {
+	target := &Target{accounts: map[string]*Account{}, fail: map[string]bool{}}
+	for _, account := range accounts {
+		target.accounts[account.UserName] = &account
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /accounts", func(w http.ResponseWriter, r *http.Request) {
+		var accounts []Account
+		for _, account := range target.accounts {
+			accounts = append(accounts, *account)
+		}
+		_ = json.NewEncoder(w).Encode(accounts)
+	})
+	mux.HandleFunc("POST /accounts", func(w http.ResponseWriter, r *http.Request) {
+		var account Account
+		_ = json.NewDecoder(r.Body).Decode(&account)
+		switch {
+		case target.fail[account.UserName]:
+			http.Error(w, "quota exceeded", http.StatusInternalServerError)
+		case target.accounts[account.UserName] != nil:
+			w.WriteHeader(http.StatusConflict)
+		default:
+			target.accounts[account.UserName] = &account
+			w.WriteHeader(http.StatusCreated)
+		}
+	})
+	mux.HandleFunc("PUT /accounts/{userName}", target.with(func(w http.ResponseWriter, r *http.Request, account *Account) {
+		var update Account
+		_ = json.NewDecoder(r.Body).Decode(&update)
+		account.Name, account.Email, account.Active = update.Name, update.Email, update.Active
+	}))
+	mux.HandleFunc("POST /accounts/{userName}/disable", target.with(func(w http.ResponseWriter, r *http.Request, account *Account) {
+		account.Active = false
+	}))
+	mux.HandleFunc("DELETE /accounts/{userName}", target.with(func(w http.ResponseWriter, r *http.Request, account *Account) {
+		delete(target.accounts, account.UserName)
+	}))
+	mux.HandleFunc("PUT /accounts/{userName}/entitlements/{entitlement}", target.with(func(w http.ResponseWriter, r *http.Request, account *Account) {
+		account.Entitlements = normalize(append(account.Entitlements, r.PathValue("entitlement")))
+	}))
+	mux.HandleFunc("DELETE /accounts/{userName}/entitlements/{entitlement}", target.with(func(w http.ResponseWriter, r *http.Request, account *Account) {
+		account.Entitlements = slices.DeleteFunc(account.Entitlements, func(e string) bool { return e == r.PathValue("entitlement") })
+	}))
+
+	target.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		target.mu.Lock()
+		defer target.mu.Unlock()
+		target.tokens = append(target.tokens, r.Header.Get("Authorization"))
+		if r.Method != http.MethodGet {
+			target.requests = append(target.requests, r.Method+" "+r.URL.EscapedPath())
+		}
+		mux.ServeHTTP(w, r)
+	}))
+	t.Cleanup(target.server.Close)
+
+	return target
+}
+
+func (target *Target) with(handle func(http.ResponseWriter, *http.Request, *Account)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		account, ok := target.accounts[r.PathValue("userName")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		handle(w, r, account)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func holder(id int64, userName string, role string, status string) *Holder {
+	return &Holder{EmployeeId: id, UserName: userName, Name: strings.ToUpper(userName), Email: userName + "@example.com",
+		Status: status, Role: role}
+}
+
+func connectorConfig(kind string, settings string, deprovision string) Config {
+	return Config{Connectors: []ConnectorConfig{{
+		Name:        "target",
+		Type:        kind,
+		Roles:       map[string]string{"developers": "git", "admins": "git-admin", "staff": ""},
+		Deprovision: deprovision,
+		Settings:    json.RawMessage(settings),
+	}}}
+}
+
+func TestService(t *testing.T) {
+	var assert = assertpackage.New(t)
+
+	t.Run("should create, update, disable and deprovision accounts in a file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "accounts.csv")
+		_ = os.WriteFile(path, []byte("user_name,name,email,active,entitlements\n"+
+			"ivanov,Old Name,ivanov@example.com,true,git\n"+
+			"petrov,PETROV,petrov@example.com,true,git;git-admin\n"+
+			"sidorov,SIDOROV,sidorov@example.com,true,git\n"+
+			"gone,GONE,gone@example.com,true,git\n"), 0o600)
+		repo := &StubRepo{holders: []*Holder{
+			holder(1, "ivanov", "developers", employee.StatusActive),
+			holder(1, "ivanov", "admins", employee.StatusActive),
+			holder(2, "petrov", "developers", employee.StatusActive),
+			holder(3, "sidorov", "developers", employee.StatusDisabled),
+			holder(4, "smirnov", "staff", employee.StatusActive),
+			holder(5, "", "staff", employee.StatusActive),
+			holder(6, "kuznetsov", "developers", employee.StatusDisabled),
+			holder(7, "popov", "unmapped", employee.StatusActive),
+		}}
+		service, err := NewService(repo, NewRegistry(), connectorConfig("file", `{"path":"`+path+`"}`, DeprovisionDisable))
+		assert.Nil(err)
+
+		report, err := service.Reconcile(context.Background(), "target", false)
+
+		assert.Nil(err)
+		assert.Equal(0, report.Failed)
+		assert.Equal([]string{`employee 5 has role "staff" but no user name`}, report.Warnings)
+		assert.Equal([]Change{
+			{Action: ActionUpdate, UserName: "ivanov"},
+			{Action: ActionAddEntitlement, UserName: "ivanov", Entitlement: "git-admin"},
+			{Action: ActionRemoveEntitlement, UserName: "petrov", Entitlement: "git-admin"},
+			{Action: ActionDisable, UserName: "sidorov"},
+			{Action: ActionCreate, UserName: "smirnov"},
+			{Action: ActionDisable, UserName: "gone"},
+			{Action: ActionRemoveEntitlement, UserName: "gone", Entitlement: "git"},
+		}, report.Changes)
+
+		content, _ := os.ReadFile(path)
+		assert.Equal("user_name,name,email,active,entitlements\n"+
+			"gone,GONE,gone@example.com,false,\n"+
+			"ivanov,IVANOV,ivanov@example.com,true,git;git-admin\n"+
+			"petrov,PETROV,petrov@example.com,true,git\n"+
+			"sidorov,SIDOROV,sidorov@example.com,false,git\n"+
+			"smirnov,SMIRNOV,smirnov@example.com,true,\n", string(content))
+
+		again, _ := service.Reconcile(context.Background(), "target", false)
+		assert.Empty(again.Changes)
+		assert.Equal(again, *service.Connectors()[0].LastReport)
+	})
+
+	t.Run("should only report changes on a dry run", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "accounts.csv")
+		repo := &StubRepo{holders: []*Holder{holder(1, "ivanov", "developers", employee.StatusActive)}}
+		service, _ := NewService(repo, NewRegistry(), connectorConfig("file", `{"path":"`+path+`"}`, ""))
+
+		report, err := service.Reconcile(context.Background(), "target", true)
+
+		assert.Nil(err)
+		assert.True(report.DryRun)
+		assert.Equal([]Change{
+			{Action: ActionCreate, UserName: "ivanov"},
+			{Action: ActionAddEntitlement, UserName: "ivanov", Entitlement: "git"},
+		}, report.Changes)
+		assert.NoFileExists(path)
+		assert.Nil(service.Connectors()[0].LastReport)
+	})
+
+	t.Run("should provision through a rest api and keep going after a failed change", func(t *testing.T) {
+		target := NewTarget(t,
+			Account{UserName: "gone", Name: "GONE", Active: true, Entitlements: []string{"git"}},
+			Account{UserName: "o'neil", Name: "O'NEIL", Email: "o'neil@example.com", Active: true})
+		target.fail["broken"] = true
+		repo := &StubRepo{holders: []*Holder{
+			holder(1, "broken", "developers", employee.StatusActive),
+			holder(2, "o'neil", "admins", employee.StatusActive),
+			holder(3, "petrov", "developers", employee.StatusActive),
+		}}
+		t.Setenv("TARGET_TOKEN", "secret-token")
+		service, err := NewService(repo, NewRegistry(), connectorConfig("rest",
+			`{"base_url":"`+target.server.URL+`/","token_env":"TARGET_TOKEN"}`, DeprovisionDelete))
+		assert.Nil(err)
+
+		report, err := service.Reconcile(context.Background(), "target", false)
+
+		assert.Nil(err)
+		assert.Equal(1, report.Failed)
+		assert.Equal(ActionCreate, report.Changes[0].Action)
+		assert.Equal("POST /accounts responded with status 500: quota exceeded", report.Changes[0].Error)
+		assert.Equal([]string{
+			"POST /accounts",
+			"PUT /accounts/o%27neil/entitlements/git-admin",
+			"POST /accounts",
+			"PUT /accounts/petrov/entitlements/git",
+			"DELETE /accounts/gone",
+		}, target.requests)
+		assert.Equal([]string{"git-admin"}, target.accounts["o'neil"].Entitlements)
+		assert.Equal(Account{UserName: "petrov", Name: "PETROV", Email: "petrov@example.com", Active: true,
+			Entitlements: []string{"git"}}, *target.accounts["petrov"])
+		assert.NotContains(target.accounts, "gone")
+		for _, token := range target.tokens {
+			assert.Equal("Bearer secret-token", token)
+		}
+	})
+
+	t.Run("should refuse to deprovision everything when nobody holds a mapped role", func(t *testing.T) {
+		target := NewTarget(t, Account{UserName: "ivanov", Active: true})
+		service, _ := NewService(&StubRepo{}, NewRegistry(), connectorConfig("rest", `{"base_url":"`+target.server.URL+`"}`, ""))
+
+		report, err := service.Reconcile(context.Background(), "target", false)
+
+		assert.ErrorIs(err, ErrNoHolders)
+		assert.Equal(err.Error(), report.Error)
+		assert.True(target.accounts["ivanov"].Active)
+	})
+
+	t.Run("should fail when the connector is unknown or busy", func(t *testing.T) {
+		repo := &StubRepo{busy: true}
+		service, _ := NewService(repo, NewRegistry(), connectorConfig("file", `{"path":"unused.csv"}`, ""))
+
+		_, err := service.Reconcile(context.Background(), "missing", false)
+		assert.ErrorIs(err, ErrUnknownConnector)
+		_, err = service.Reconcile(context.Background(), "target", false)
+		assert.ErrorIs(err, outbox.ErrBusy)
+		assert.Nil(service.Connectors()[0].LastReport)
+	})
+}
+
+func TestRegistry(t *testing.T) {
+	var assert = assertpackage.New(t)
+
+	t.Run("should build registered connector types", func(t *testing.T) {
+		registry := NewRegistry()
+		registry.Register("memory", func(settings json.RawMessage) (Connector, error) {
+			return &FileConnector{path: string(settings)}, nil
+		})
+
+		assert.Equal([]string{"file", "memory", "rest"}, registry.Types())
+		_, err := registry.Build(ConnectorConfig{Name: "a", Type: "memory"})
+		assert.Nil(err)
+		_, err = registry.Build(ConnectorConfig{Name: "b", Type: "ftp"})
+		assert.ErrorIs(err, ErrUnknownType)
+		_, err = registry.Build(ConnectorConfig{Name: "c", Type: "rest", Settings: json.RawMessage(`{"base_url":"ftp://x"}`)})
+		assert.ErrorIs(err, ErrInvalidConfig)
+		_, err = registry.Build(ConnectorConfig{Name: "d", Type: "rest",
+			Settings: json.RawMessage(`{"base_url":"https://x","paths":{"rename":"POST /rename"}}`)})
+		assert.ErrorIs(err, ErrInvalidConfig)
+		_, err = registry.Build(ConnectorConfig{Name: "e", Type: "file", Settings: json.RawMessage(`{}`)})
+		assert.ErrorIs(err, ErrInvalidConfig)
+	})
+}
+
+func TestLoadConfig(t *testing.T) {
+	var assert = assertpackage.New(t)
+
+	t.Run("should fill defaults and validate connectors", func(t *testing.T) {
+		config, err := LoadConfig(strings.NewReader(
+			`{"connectors":[{"name":"git","type":"rest","roles":{"developers":"git"},"settings":{"base_url":"https://git"}}]}`))
+		assert.Nil(err)
+		assert.Equal(DeprovisionDisable, config.Connectors[0].Deprovision)
+
+		for _, invalid := range []string{
+			`{"connectors":[{"type":"rest","roles":{"a":""}}]}`,
+			`{"connectors":[{"name":"a","roles":{"a":""}},{"name":"a","roles":{"a":""}}]}`,
+			`{"connectors":[{"name":"a","roles":{"a":""},"deprovision":"archive"}]}`,
+			`{"connectors":[{"name":"a"}]}`,
+		} {
+			_, err := LoadConfig(strings.NewReader(invalid))
+			assert.ErrorIs(err, ErrInvalidConfig, invalid)
+		}
+	})
+}
+
+func TestHandler(t *testing.T) {
+	var assert = assertpackage.New(t)
+
+	t.Run("should list connectors and run reconciliation", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "accounts.csv")
+		repo := &StubRepo{holders: []*Holder{holder(1, "ivanov", "staff", employee.StatusActive)}}
+		service, _ := NewService(repo, NewRegistry(), connectorConfig("file", `{"path":"`+path+`"}`, ""))
+		handler := NewHandler(service)
+
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/target/reconcile", nil))
+		assert.Equal(http.StatusOK, recorder.Code)
+		assert.Contains(recorder.Body.String(), `"action":"create","user_name":"ivanov"`)
+
+		recorder = httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+		assert.Equal(http.StatusOK, recorder.Code)
+		assert.Contains(recorder.Body.String(), `"roles":["admins","developers","staff"]`)
+		assert.Contains(recorder.Body.String(), `"last_report":{"connector":"target"`)
+
+		for target, status := range map[string]int{
+			"/target/reconcile?dry_run=maybe": http.StatusBadRequest,
+			"/missing/reconcile":              http.StatusNotFound,
+		} {
+			recorder = httptest.NewRecorder()
+			handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, target, nil))
+			assert.Equal(status, recorder.Code, target)
+		}
+
+		repo.busy = true
+		recorder = httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/target/reconcile", nil))
+		assert.Equal(http.StatusConflict, recorder.Code)
+	})
+}
@@ -0,0 +1,86 @@
+package provisioning
+
+import (
+	"context"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"idm/inner/outbox"
+	"time"
+)
+
+// reconcileTimeout тайм-аут транзакции сверки: она открыта, пока идут запросы к целевой системе
+const reconcileTimeout = 30 * time.Minute
+
+// Holder сотрудник с ролью, которая даёт запись в целевой системе
+type Holder struct {
+	EmployeeId int64  `db:"employee_id"`
+	UserName   string `db:"user_name"`
+	Name       string `db:"name"`
+	Email      string `db:"email"`
+	Status     string `db:"status"`
+	Role       string `db:"role"`
+}
+
+type Repository struct {
+	db *sqlx.DB
+}
+
+func NewRepository(db *sqlx.DB) *Repository {
+	return &Repository{db: db}
+}
+
+type batch struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	tx     *sqlx.Tx
+}
+
+// Begin сверка одного коннектора идёт в одном экземпляре, чтобы запись не создавалась дважды
+func (r *Repository) Begin(connector string) (Batch, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), reconcileTimeout)
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	b := &batch{ctx: ctx, cancel: cancel, tx: tx}
+
+	var locked bool
+	if err := tx.GetContext(ctx, &locked,
+		"SELECT pg_try_advisory_xact_lock(hashtext('idm_provisioning:' || $1))", connector); err != nil {
+		_ = b.Rollback()
+		return nil, err
+	}
+	if !locked {
+		_ = b.Rollback()
+		return nil, outbox.ErrBusy
+	}
+
+	return b, nil
+}
+
+func (b *batch) Holders(roles []string) ([]*Holder, error) {
+	var holders []*Holder
+	err := b.tx.SelectContext(b.ctx, &holders,
+		`SELECT e.id AS employee_id, e.user_name, e.name, e.email, e.status, r.name AS role
+		FROM employee_roles er
+		JOIN employees e ON e.id = er.employee_id
+		JOIN roles r ON r.id = er.role_id
+		WHERE r.name = ANY($1)
+		ORDER BY e.id, r.name`, pq.StringArray(roles))
+
+	return holders, err
+}
+
+func (b *batch) Commit() error {
+	defer b.cancel()
+
+	return b.tx.Commit()
+}
+
+func (b *batch) Rollback() error {
+	defer b.cancel()
+
+	return b.tx.Rollback()
+}
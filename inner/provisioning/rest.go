@@ -0,0 +1,219 @@
+package provisioning
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// Операции коннектора rest
+const (
+	OperationList              = "list"
+	OperationCreate            = "create"
+	OperationUpdate            = "update"
+	OperationDisable           = "disable"
+	OperationDelete            = "delete"
+	OperationAddEntitlement    = "add_entitlement"
+	OperationRemoveEntitlement = "remove_entitlement"
+)
+
+// DefaultPaths запросы операций по умолчанию: "<метод> <путь>", в пути подставляются {user_name} и {entitlement}
+var DefaultPaths = map[string]string{
+	OperationList:              "GET /accounts",
+	OperationCreate:            "POST /accounts",
+	OperationUpdate:            "PUT /accounts/{user_name}",
+	OperationDisable:           "POST /accounts/{user_name}/disable",
+	OperationDelete:            "DELETE /accounts/{user_name}",
+	OperationAddEntitlement:    "PUT /accounts/{user_name}/entitlements/{entitlement}",
+	OperationRemoveEntitlement: "DELETE /accounts/{user_name}/entitlements/{entitlement}",
+}
+
+const (
+	restTimeout = 30 * time.Second
+	// maxErrorBody сколько байт ответа с ошибкой включать в сообщение
+	maxErrorBody = 512
+)
+
+// RestSettings настройки коннектора rest
+type RestSettings struct {
+	BaseURL string `json:"base_url"`
+	// Token токен для заголовка Authorization: Bearer; TokenEnv – имя переменной окружения с ним
+	Token    string            `json:"token"`
+	TokenEnv string            `json:"token_env"`
+	Headers  map[string]string `json:"headers"`
+	// Timeout тайм-аут одного запроса в формате time.ParseDuration
+	Timeout string `json:"timeout"`
+	// Paths запросы, отличающиеся от DefaultPaths
+	Paths map[string]string `json:"paths"`
+}
+
+// RestConnector записи в системе с JSON API: тело запросов создания и изменения и элементы
+// ответа списка – Account. Коды 404 на удаление записи или права считаются успехом.
+type RestConnector struct {
+	base    *url.URL
+	token   string
+	headers map[string]string
+	paths   map[string]string
+	client  *http.Client
+}
+
+func NewRestConnector(settings json.RawMessage) (Connector, error) {
+	var parsed RestSettings
+	if err := json.Unmarshal(settings, &parsed); err != nil {
+		return nil, fmt.Errorf("error decoding rest connector settings: %w", err)
+	}
+
+	base, err := url.Parse(parsed.BaseURL)
+	if err != nil || (base.Scheme != "http" && base.Scheme != "https") || base.Host == "" {
+		return nil, fmt.Errorf("%w: rest connector requires an absolute http or https base_url", ErrInvalidConfig)
+	}
+	base.Path = strings.TrimSuffix(base.Path, "/")
+
+	timeout := restTimeout
+	if parsed.Timeout != "" {
+		if timeout, err = time.ParseDuration(parsed.Timeout); err != nil {
+			return nil, fmt.Errorf("%w: invalid rest connector timeout %q", ErrInvalidConfig, parsed.Timeout)
+		}
+	}
+
+	token := parsed.Token
+	if parsed.TokenEnv != "" {
+		token = os.Getenv(parsed.TokenEnv)
+	}
+
+	paths := map[string]string{}
+	for operation, path := range DefaultPaths {
+		paths[operation] = path
+	}
+	for operation, path := range parsed.Paths {
+		if _, ok := DefaultPaths[operation]; !ok {
+			return nil, fmt.Errorf("%w: unknown rest connector operation %q", ErrInvalidConfig, operation)
+		}
+		if method, _, ok := strings.Cut(path, " "); !ok || method == "" {
+			return nil, fmt.Errorf("%w: rest connector path %q must be \"<method> <path>\"", ErrInvalidConfig, path)
+		}
+		paths[operation] = path
+	}
+
+	return &RestConnector{
+		base:    base,
+		token:   token,
+		headers: parsed.Headers,
+		paths:   paths,
+		client:  &http.Client{Timeout: timeout},
+	}, nil
+}
+
+func (c *RestConnector) Accounts(ctx context.Context) ([]Account, error) {
+	var accounts []Account
+	if err := c.do(ctx, OperationList, "", "", nil, &accounts); err != nil {
+		return nil, err
+	}
+	for i := range accounts {
+		accounts[i].Entitlements = normalize(accounts[i].Entitlements)
+	}
+
+	return accounts, nil
+}
+
+func (c *RestConnector) CreateAccount(ctx context.Context, account Account) error {
+	account.Entitlements = []string{}
+
+	return c.do(ctx, OperationCreate, account.UserName, "", account, nil)
+}
+
+func (c *RestConnector) UpdateAccount(ctx context.Context, account Account) error {
+	account.Entitlements = nil
+
+	return c.do(ctx, OperationUpdate, account.UserName, "", account, nil)
+}
+
+func (c *RestConnector) DisableAccount(ctx context.Context, userName string) error {
+	return c.do(ctx, OperationDisable, userName, "", nil, nil)
+}
+
+func (c *RestConnector) DeleteAccount(ctx context.Context, userName string) error {
+	return c.do(ctx, OperationDelete, userName, "", nil, nil)
+}
+
+func (c *RestConnector) AddEntitlement(ctx context.Context, userName string, entitlement string) error {
+	return c.do(ctx, OperationAddEntitlement, userName, entitlement, nil, nil)
+}
+
+func (c *RestConnector) RemoveEntitlement(ctx context.Context, userName string, entitlement string) error {
+	return c.do(ctx, OperationRemoveEntitlement, userName, entitlement, nil, nil)
+}
+
+func (c *RestConnector) do(ctx context.Context, operation, userName, entitlement string, body any, target any) error {
+	method, path, _ := strings.Cut(c.paths[operation], " ")
+	path = strings.NewReplacer(
+		"{user_name}", url.PathEscape(userName),
+		"{entitlement}", url.PathEscape(entitlement),
+	).Replace(strings.TrimSpace(path))
+
+	var reader io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(encoded)
+	}
+
+	request, err := http.NewRequestWithContext(ctx, method, c.base.String()+path, reader)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Accept", "application/json")
+	request.Header.Set("User-Agent", "idm-provisioning")
+	if body != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		request.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	for name, value := range c.headers {
+		request.Header.Set(name, value)
+	}
+
+	response, err := c.client.Do(request)
+	if err != nil {
+		return fmt.Errorf("error calling %s %s: %w", method, path, err)
+	}
+	defer func() { _ = response.Body.Close() }()
+
+	switch {
+	case response.StatusCode >= 200 && response.StatusCode <= 299:
+		if target == nil {
+			_, _ = io.Copy(io.Discard, io.LimitReader(response.Body, 64<<10))
+			return nil
+		}
+		if err := json.NewDecoder(response.Body).Decode(target); err != nil {
+			return fmt.Errorf("error decoding response of %s %s: %w", method, path, err)
+		}
+		return nil
+	case response.StatusCode == http.StatusNotFound &&
+		(operation == OperationDelete || operation == OperationRemoveEntitlement):
+		return nil
+	case response.StatusCode == http.StatusNotFound && userName != "" && operation != OperationCreate:
+		return fmt.Errorf("%w: %s", ErrAccountNotFound, userName)
+	case response.StatusCode == http.StatusConflict && operation == OperationCreate:
+		return fmt.Errorf("%w: %s", ErrAccountExists, userName)
+	}
+
+	text, _ := io.ReadAll(io.LimitReader(response.Body, maxErrorBody))
+	message := fmt.Sprintf("%s %s responded with status %d", method, path, response.StatusCode)
+	if text := strings.TrimSpace(strings.ToValidUTF8(string(text), "")); text != "" {
+		message += ": " + text
+	}
+
+	return errors.New(message)
+}
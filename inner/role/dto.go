@@ -3,17 +3,28 @@ package role
 import "time"
 
 type Response struct {
-	Id        int64     `json:"id"`
-	Name      string    `json:"name"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	Id          int64     `json:"id"`
+	Name        string    `json:"name"`
+	OwnerId     *int64    `json:"owner_id,omitempty"`
+	Description string    `json:"description"`
+	Permissions []string  `json:"permissions"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
 }
 
 func (r *Role) ToResponse() *Response {
+	permissions := []string(r.Permissions)
+	if permissions == nil {
+		permissions = []string{}
+	}
+
 	return &Response{
-		Id:        r.Id,
-		Name:      r.Name,
-		CreatedAt: r.CreatedAt,
-		UpdatedAt: r.UpdatedAt,
+		Id:          r.Id,
+		Name:        r.Name,
+		OwnerId:     r.OwnerId,
+		Description: r.Description,
+		Permissions: permissions,
+		CreatedAt:   r.CreatedAt,
+		UpdatedAt:   r.UpdatedAt,
 	}
 }
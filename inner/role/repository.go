@@ -7,14 +7,24 @@ import (
 	"github.com/jmoiron/sqlx"
 	"github.com/lib/pq"
 	"idm/inner/database"
+	"slices"
 	"time"
 )
 
 type Role struct {
-	Id        int64     `db:"id"`
-	Name      string    `db:"name"`
-	CreatedAt time.Time `db:"created_at"`
-	UpdatedAt time.Time `db:"updated_at"`
+	Id          int64          `db:"id"`
+	Name        string         `db:"name"`
+	OwnerId     *int64         `db:"owner_id"`
+	Description string         `db:"description"`
+	Permissions pq.StringArray `db:"permissions"`
+	CreatedAt   time.Time      `db:"created_at"`
+	UpdatedAt   time.Time      `db:"updated_at"`
+}
+
+// Assignment выдача роли сотруднику
+type Assignment struct {
+	EmployeeId int64 `db:"employee_id"`
+	RoleId     int64 `db:"role_id"`
 }
 
 type Repository struct {
@@ -55,6 +65,28 @@ func (r *Repository) FindAll() ([]*Role, error) {
 	return roles, err
 }
 
+func (r *Repository) FindPage(afterId int64, limit int) ([]*Role, error) {
+	var roles []*Role
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := r.db.SelectContext(ctx, &roles, "SELECT * FROM roles WHERE id > $1 ORDER BY id LIMIT $2", afterId, limit)
+
+	return roles, err
+}
+
+func (r *Repository) Count() (int64, error) {
+	var count int64
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := r.db.GetContext(ctx, &count, "SELECT count(*) FROM roles")
+
+	return count, err
+}
+
 func (r *Repository) FindByIds(ids []int64) ([]*Role, error) {
 	var roles []*Role
 	err := r.db.Select(&roles, "SELECT * FROM roles WHERE id = ANY($1)", pq.Array(ids))
@@ -77,20 +109,130 @@ func (r *Repository) Create(role *Role) error {
 	return nil
 }
 
+func (r *Repository) Update(role *Role) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := r.db.QueryRowContext(ctx,
+		"UPDATE roles SET name = $1, owner_id = $2, updated_at = CURRENT_TIMESTAMP WHERE id = $3 RETURNING created_at, updated_at",
+		role.Name, role.OwnerId, role.Id).Scan(&role.CreatedAt, &role.UpdatedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return database.ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
 func (r *Repository) Remove(id int64) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	_, err := r.db.ExecContext(ctx, "DELETE FROM roles WHERE id = $1", id)
+	result, err := r.db.ExecContext(ctx, "DELETE FROM roles WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+
+	count, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return database.ErrRecordNotFound
+	}
 
-	return err
+	return nil
 }
 
+// RemoveByIds удалить все роли с ids или ни одной: если какой-то из них нет,
+// возвращается database.ErrRecordNotFound, как и в Remove
 func (r *Repository) RemoveByIds(ids []int64) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	_, err := r.db.ExecContext(ctx, "DELETE FROM roles WHERE id = ANY($1)", pq.Array(ids))
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	result, err := tx.ExecContext(ctx, "DELETE FROM roles WHERE id = ANY($1)", pq.Array(ids))
+	if err != nil {
+		return err
+	}
+
+	count, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if count != int64(len(slices.Compact(slices.Sorted(slices.Values(ids))))) {
+		return database.ErrRecordNotFound
+	}
+
+	return tx.Commit()
+}
+
+func (r *Repository) FindByEmployeeId(employeeId int64) ([]*Role, error) {
+	var roles []*Role
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := r.db.SelectContext(ctx, &roles,
+		"SELECT r.* FROM roles r JOIN employee_roles er ON er.role_id = r.id WHERE er.employee_id = $1 ORDER BY r.id",
+		employeeId)
+
+	return roles, err
+}
+
+func (r *Repository) FindEmployeeIds(roleId int64) ([]int64, error) {
+	var ids []int64
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := r.db.SelectContext(ctx, &ids,
+		"SELECT employee_id FROM employee_roles WHERE role_id = $1 ORDER BY employee_id", roleId)
+
+	return ids, err
+}
+
+func (r *Repository) FindAssignments(employeeIds []int64, roleIds []int64) ([]Assignment, error) {
+	var assignments []Assignment
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := r.db.SelectContext(ctx, &assignments,
+		"SELECT employee_id, role_id FROM employee_roles WHERE employee_id = ANY($1) OR role_id = ANY($2) "+
+			"ORDER BY employee_id, role_id",
+		pq.Array(employeeIds), pq.Array(roleIds))
+
+	return assignments, err
+}
+
+func (r *Repository) Assign(employeeId int64, roleId int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := r.db.ExecContext(ctx,
+		"INSERT INTO employee_roles (employee_id, role_id) VALUES ($1, $2) ON CONFLICT DO NOTHING",
+		employeeId, roleId)
+
+	return database.MapError(err)
+}
+
+func (r *Repository) Revoke(employeeId int64, roleId int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := r.db.ExecContext(ctx,
+		"DELETE FROM employee_roles WHERE employee_id = $1 AND role_id = $2",
+		employeeId, roleId)
 
 	return err
 }
@@ -4,16 +4,37 @@ import "fmt"
 
 type Repo interface {
 	FindAll() ([]*Role, error)
+	FindPage(afterId int64, limit int) ([]*Role, error)
+	Count() (int64, error)
 	FindById(id int64) (*Role, error)
 	FindByIds(ids []int64) ([]*Role, error)
 	Create(role *Role) error
+	Update(role *Role) error
 	Remove(id int64) error
 	RemoveByIds(ids []int64) error
+	FindByEmployeeId(employeeId int64) ([]*Role, error)
+	FindEmployeeIds(roleId int64) ([]int64, error)
+	FindAssignments(employeeIds []int64, roleIds []int64) ([]Assignment, error)
+	Assign(employeeId int64, roleId int64) error
+	Revoke(employeeId int64, roleId int64) error
+}
+
+// AssignmentGuard проверяет, можно ли выдать роль сотруднику. pending — роли, которые выдаются
+// вместе с этой и ещё не сохранены: пакет проверяется целиком, а не каждая роль отдельно
+type AssignmentGuard interface {
+	CheckAssignment(employeeId int64, roleId int64, pending []int64) error
+}
+
+// AssignmentHook вызывается после выдачи или отзыва роли
+type AssignmentHook interface {
+	AfterAssignmentChange(employeeId int64, roleId int64) error
 }
 
 // Service будет инкапсулировать бизнес-логику
 type Service struct {
-	repo Repo
+	repo   Repo
+	guards []AssignmentGuard
+	hooks  []AssignmentHook
 }
 
 func NewService(repository Repo) *Service {
@@ -43,6 +64,30 @@ func (s *Service) FindAll() ([]Response, error) {
 	return responses, nil
 }
 
+// FindPage не больше limit ролей с идентификатором больше afterId по возрастанию идентификатора
+func (s *Service) FindPage(afterId int64, limit int) ([]Response, error) {
+	roles, err := s.repo.FindPage(afterId, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error finding roles after id %d: %w", afterId, err)
+	}
+
+	var responses []Response
+	for _, role := range roles {
+		responses = append(responses, *role.ToResponse())
+	}
+
+	return responses, nil
+}
+
+func (s *Service) Count() (int64, error) {
+	count, err := s.repo.Count()
+	if err != nil {
+		return 0, fmt.Errorf("error counting roles: %w", err)
+	}
+
+	return count, nil
+}
+
 func (s *Service) FindByIds(ids []int64) ([]Response, error) {
 	roles, err := s.repo.FindByIds(ids)
 	if err != nil {
@@ -67,6 +112,22 @@ func (s *Service) Create(name string) (Response, error) {
 	return *role.ToResponse(), nil
 }
 
+// Rename переименовать роль
+func (s *Service) Rename(id int64, name string) (Response, error) {
+	role, err := s.repo.FindById(id)
+	if err != nil {
+		return Response{}, fmt.Errorf("error finding role with id %d: %w", id, err)
+	}
+
+	role.Name = name
+	err = s.repo.Update(role)
+	if err != nil {
+		return Response{}, fmt.Errorf("error updating role with id %d: %w", id, err)
+	}
+
+	return *role.ToResponse(), nil
+}
+
 func (s *Service) Remove(id int64) error {
 	return s.repo.Remove(id)
 }
@@ -74,3 +135,83 @@ func (s *Service) Remove(id int64) error {
 func (s *Service) RemoveByIds(ids []int64) error {
 	return s.repo.RemoveByIds(ids)
 }
+
+func (s *Service) FindByEmployeeId(employeeId int64) ([]Response, error) {
+	roles, err := s.repo.FindByEmployeeId(employeeId)
+	if err != nil {
+		return nil, fmt.Errorf("error finding roles of employee with id %d: %w", employeeId, err)
+	}
+
+	var responses []Response
+	for _, role := range roles {
+		responses = append(responses, *role.ToResponse())
+	}
+
+	return responses, nil
+}
+
+// FindEmployeeIds идентификаторы сотрудников, которым выдана роль
+func (s *Service) FindEmployeeIds(roleId int64) ([]int64, error) {
+	ids, err := s.repo.FindEmployeeIds(roleId)
+	if err != nil {
+		return nil, fmt.Errorf("error finding employees of role with id %d: %w", roleId, err)
+	}
+
+	return ids, nil
+}
+
+// FindAssignments выдачи ролей сотрудникам employeeIds и выдачи ролей roleIds одним запросом
+func (s *Service) FindAssignments(employeeIds []int64, roleIds []int64) ([]Assignment, error) {
+	assignments, err := s.repo.FindAssignments(employeeIds, roleIds)
+	if err != nil {
+		return nil, fmt.Errorf("error finding assignments of employees %v and roles %v: %w", employeeIds, roleIds, err)
+	}
+
+	return assignments, nil
+}
+
+// UseGuard добавить проверку, которая выполняется перед каждой выдачей роли
+func (s *Service) UseGuard(guard AssignmentGuard) {
+	s.guards = append(s.guards, guard)
+}
+
+// UseHook добавить обработчик, который вызывается после каждой выдачи и отзыва роли
+func (s *Service) UseHook(hook AssignmentHook) {
+	s.hooks = append(s.hooks, hook)
+}
+
+// Assign выдать роль сотруднику
+func (s *Service) Assign(employeeId int64, roleId int64) error {
+	for _, guard := range s.guards {
+		if err := guard.CheckAssignment(employeeId, roleId, nil); err != nil {
+			return fmt.Errorf("error assigning role %d to employee %d: %w", roleId, employeeId, err)
+		}
+	}
+
+	err := s.repo.Assign(employeeId, roleId)
+	if err != nil {
+		return fmt.Errorf("error assigning role %d to employee %d: %w", roleId, employeeId, err)
+	}
+
+	return s.afterAssignmentChange(employeeId, roleId)
+}
+
+// Revoke отозвать роль у сотрудника
+func (s *Service) Revoke(employeeId int64, roleId int64) error {
+	err := s.repo.Revoke(employeeId, roleId)
+	if err != nil {
+		return fmt.Errorf("error revoking role %d from employee %d: %w", roleId, employeeId, err)
+	}
+
+	return s.afterAssignmentChange(employeeId, roleId)
+}
+
+func (s *Service) afterAssignmentChange(employeeId int64, roleId int64) error {
+	for _, hook := range s.hooks {
+		if err := hook.AfterAssignmentChange(employeeId, roleId); err != nil {
+			return fmt.Errorf("error processing role %d change of employee %d: %w", roleId, employeeId, err)
+		}
+	}
+
+	return nil
+}
@@ -22,6 +22,16 @@ func (m *MockRepo) FindAll() ([]*Role, error) {
 	return args.Get(0).([]*Role), args.Error(1)
 }
 
+func (m *MockRepo) FindPage(afterId int64, limit int) ([]*Role, error) {
+	args := m.Called(afterId, limit)
+	return args.Get(0).([]*Role), args.Error(1)
+}
+
+func (m *MockRepo) Count() (int64, error) {
+	args := m.Called()
+	return args.Get(0).(int64), args.Error(1)
+}
+
 func (m *MockRepo) FindByIds(ids []int64) ([]*Role, error) {
 	args := m.Called(ids)
 	return args.Get(0).([]*Role), args.Error(1)
@@ -32,6 +42,11 @@ func (m *MockRepo) Create(role *Role) error {
 	return args.Error(0)
 }
 
+func (m *MockRepo) Update(role *Role) error {
+	args := m.Called(role)
+	return args.Error(0)
+}
+
 func (m *MockRepo) Remove(id int64) error {
 	args := m.Called(id)
 	return args.Error(0)
@@ -42,6 +57,43 @@ func (m *MockRepo) RemoveByIds(ids []int64) error {
 	return args.Error(0)
 }
 
+func (m *MockRepo) FindByEmployeeId(employeeId int64) ([]*Role, error) {
+	args := m.Called(employeeId)
+	return args.Get(0).([]*Role), args.Error(1)
+}
+
+func (m *MockRepo) FindEmployeeIds(roleId int64) ([]int64, error) {
+	args := m.Called(roleId)
+	return args.Get(0).([]int64), args.Error(1)
+}
+
+func (m *MockRepo) FindAssignments(employeeIds []int64, roleIds []int64) ([]Assignment, error) {
+	args := m.Called(employeeIds, roleIds)
+	return args.Get(0).([]Assignment), args.Error(1)
+}
+
+func (m *MockRepo) Assign(employeeId int64, roleId int64) error {
+	args := m.Called(employeeId, roleId)
+	return args.Error(0)
+}
+
+func (m *MockRepo) Revoke(employeeId int64, roleId int64) error {
+	args := m.Called(employeeId, roleId)
+	return args.Error(0)
+}
+
+type GuardFunc func(employeeId int64, roleId int64) error
+
+func (f GuardFunc) CheckAssignment(employeeId int64, roleId int64, pending []int64) error {
+	return f(employeeId, roleId)
+}
+
+type HookFunc func(employeeId int64, roleId int64) error
+
+func (f HookFunc) AfterAssignmentChange(employeeId int64, roleId int64) error {
+	return f(employeeId, roleId)
+}
+
 func TestRoleService(t *testing.T) {
 	assert := assertpackage.New(t)
 
@@ -87,6 +139,21 @@ func TestRoleService(t *testing.T) {
 		assert.True(repo.AssertNumberOfCalls(t, "FindAll", 1))
 	})
 
+	t.Run("FindPage should return a page of roles and Count their total", func(t *testing.T) {
+		repo := &MockRepo{}
+		service := NewService(repo)
+
+		repo.On("FindPage", int64(10), 1).Return([]*Role{{Id: 11, Name: "admin"}}, nil)
+		repo.On("Count").Return(int64(0), errors.New("database error"))
+		roles, err := service.FindPage(10, 1)
+		_, countErr := service.Count()
+
+		assert.NoError(err)
+		assert.Len(roles, 1)
+		assert.Equal(int64(11), roles[0].Id)
+		assert.EqualError(countErr, "error counting roles: database error")
+	})
+
 	t.Run("FindByIds should return a list of roles", func(t *testing.T) {
 		repo := &MockRepo{}
 		service := NewService(repo)
@@ -151,4 +218,117 @@ func TestRoleService(t *testing.T) {
 		assert.NoError(err)
 		assert.True(repo.AssertNumberOfCalls(t, "RemoveByIds", 1))
 	})
+
+	t.Run("FindByEmployeeId should return roles of an employee", func(t *testing.T) {
+		repo := &MockRepo{}
+		service := NewService(repo)
+
+		repo.On("FindByEmployeeId", int64(1)).Return([]*Role{{Name: "admin"}}, nil)
+		roles, err := service.FindByEmployeeId(1)
+
+		assert.NoError(err)
+		assert.Len(roles, 1)
+		assert.Equal("admin", roles[0].Name)
+	})
+
+	t.Run("Assign should assign a role to an employee", func(t *testing.T) {
+		repo := &MockRepo{}
+		service := NewService(repo)
+
+		repo.On("Assign", int64(1), int64(2)).Return(nil)
+		err := service.Assign(1, 2)
+
+		assert.NoError(err)
+		assert.True(repo.AssertNumberOfCalls(t, "Assign", 1))
+	})
+
+	t.Run("Assign should return wrapped error", func(t *testing.T) {
+		repo := &MockRepo{}
+		service := NewService(repo)
+
+		err := errors.New("database error")
+		want := fmt.Errorf("error assigning role %d to employee %d: %w", 2, 1, err)
+
+		repo.On("Assign", int64(1), int64(2)).Return(err)
+		got := service.Assign(1, 2)
+
+		assert.Equal(want, got)
+	})
+
+	t.Run("Assign should not assign a role rejected by a guard", func(t *testing.T) {
+		repo := &MockRepo{}
+		service := NewService(repo)
+
+		err := errors.New("violation")
+		service.UseGuard(GuardFunc(func(employeeId int64, roleId int64) error {
+			return err
+		}))
+		got := service.Assign(1, 2)
+
+		assert.ErrorIs(got, err)
+		repo.AssertNotCalled(t, "Assign", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Revoke should revoke a role from an employee", func(t *testing.T) {
+		repo := &MockRepo{}
+		service := NewService(repo)
+
+		repo.On("Revoke", int64(1), int64(2)).Return(nil)
+		err := service.Revoke(1, 2)
+
+		assert.NoError(err)
+		assert.True(repo.AssertNumberOfCalls(t, "Revoke", 1))
+	})
+
+	t.Run("Assign and Revoke should notify hooks", func(t *testing.T) {
+		repo := &MockRepo{}
+		service := NewService(repo)
+
+		var changed []int64
+		service.UseHook(HookFunc(func(employeeId int64, roleId int64) error {
+			changed = append(changed, roleId)
+			return nil
+		}))
+		repo.On("Assign", int64(1), int64(2)).Return(nil)
+		repo.On("Revoke", int64(1), int64(3)).Return(nil)
+
+		assert.NoError(service.Assign(1, 2))
+		assert.NoError(service.Revoke(1, 3))
+		assert.Equal([]int64{2, 3}, changed)
+	})
+
+	t.Run("Rename should rename a role", func(t *testing.T) {
+		repo := &MockRepo{}
+		service := NewService(repo)
+
+		repo.On("FindById", int64(1)).Return(&Role{Id: 1, Name: "admin"}, nil)
+		repo.On("Update", &Role{Id: 1, Name: "administrator"}).Return(nil)
+		got, err := service.Rename(1, "administrator")
+
+		assert.NoError(err)
+		assert.Equal("administrator", got.Name)
+	})
+
+	t.Run("FindEmployeeIds should return members of a role", func(t *testing.T) {
+		repo := &MockRepo{}
+		service := NewService(repo)
+
+		repo.On("FindEmployeeIds", int64(1)).Return([]int64{3, 4}, nil)
+		got, err := service.FindEmployeeIds(1)
+
+		assert.NoError(err)
+		assert.Equal([]int64{3, 4}, got)
+	})
+
+	t.Run("FindAssignments should return assignments of employees and roles", func(t *testing.T) {
+		repo := &MockRepo{}
+		service := NewService(repo)
+
+		assignments := []Assignment{{EmployeeId: 1, RoleId: 2}, {EmployeeId: 3, RoleId: 4}}
+		repo.On("FindAssignments", []int64{1}, []int64{4}).Return(assignments, nil)
+		got, err := service.FindAssignments([]int64{1}, []int64{4})
+
+		assert.NoError(err)
+		assert.Equal(assignments, got)
+	})
 }
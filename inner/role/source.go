@@ -0,0 +1,114 @@
+package role
+
+import (
+	"fmt"
+	"slices"
+)
+
+// SourcedAssignment роль сотрудника и источник, из которого она была выдана
+type SourcedAssignment struct {
+	EmployeeId int64  `db:"employee_id"`
+	RoleId     int64  `db:"role_id"`
+	Source     string `db:"source"`
+}
+
+// Syncer сохраняет роли, выданные и отозванные источником
+type Syncer interface {
+	Sync(employeeId int64, grant []int64, revoke []int64) error
+}
+
+// Diff роли, которые сотрудник получает и теряет от источника, и роли, которые не позволили выдать проверки
+type Diff struct {
+	Gain    []int64
+	Lose    []int64
+	Blocked []int64
+}
+
+// Source источник, который сам выдаёт и отзывает роли сотрудников: правила по умолчанию, группы.
+// У сотрудника одна запись на роль: источник не выдаёт роль, которую сотрудник уже получил иначе,
+// и отзывает только выданные им самим роли.
+type Source struct {
+	name   string
+	repo   Syncer
+	guards []AssignmentGuard
+	hooks  []AssignmentHook
+}
+
+func NewSource(name string, repo Syncer) *Source {
+	return &Source{name: name, repo: repo}
+}
+
+// UseGuard добавить проверку, которая выполняется перед выдачей каждой роли
+func (s *Source) UseGuard(guard AssignmentGuard) {
+	s.guards = append(s.guards, guard)
+}
+
+// UseHook добавить обработчик, который вызывается после выдачи и отзыва каждой роли источника
+func (s *Source) UseHook(hook AssignmentHook) {
+	s.hooks = append(s.hooks, hook)
+}
+
+// Apply привести роли сотрудника, выданные источником, в соответствие с desired
+func (s *Source) Apply(employeeId int64, desired []int64, assignments []*SourcedAssignment) (Diff, error) {
+	held, granted := s.Split(assignments)
+	var diff Diff
+
+	for _, roleId := range desired {
+		if slices.Contains(held, roleId) {
+			continue
+		}
+		// уже разрешённые в этом проходе роли учитываются при проверке следующих
+		if s.allowed(employeeId, roleId, diff.Gain) {
+			diff.Gain = append(diff.Gain, roleId)
+		} else {
+			diff.Blocked = append(diff.Blocked, roleId)
+		}
+	}
+	for _, roleId := range granted {
+		if !slices.Contains(desired, roleId) {
+			diff.Lose = append(diff.Lose, roleId)
+		}
+	}
+
+	if len(diff.Gain) == 0 && len(diff.Lose) == 0 {
+		return diff, nil
+	}
+
+	err := s.repo.Sync(employeeId, diff.Gain, diff.Lose)
+	if err != nil {
+		return Diff{}, fmt.Errorf("error syncing %s roles of employee with id %d: %w", s.name, employeeId, err)
+	}
+
+	for _, roleId := range slices.Concat(diff.Gain, diff.Lose) {
+		for _, hook := range s.hooks {
+			if err := hook.AfterAssignmentChange(employeeId, roleId); err != nil {
+				return diff, fmt.Errorf("error processing %s role %d of employee with id %d: %w", s.name, roleId, employeeId, err)
+			}
+		}
+	}
+
+	return diff, nil
+}
+
+// Split все роли сотрудника и роли, выданные источником
+func (s *Source) Split(assignments []*SourcedAssignment) ([]int64, []int64) {
+	var held, granted []int64
+	for _, assignment := range assignments {
+		held = append(held, assignment.RoleId)
+		if assignment.Source == s.name {
+			granted = append(granted, assignment.RoleId)
+		}
+	}
+
+	return held, granted
+}
+
+func (s *Source) allowed(employeeId int64, roleId int64, pending []int64) bool {
+	for _, guard := range s.guards {
+		if guard.CheckAssignment(employeeId, roleId, pending) != nil {
+			return false
+		}
+	}
+
+	return true
+}
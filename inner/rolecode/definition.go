@@ -0,0 +1,139 @@
+package rolecode
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+var ErrInvalidDefinition = errors.New("invalid role definition")
+
+// Definition описание роли в файле
+type Definition struct {
+	Name        string `yaml:"name" json:"name"`
+	Description string `yaml:"description" json:"description"`
+	// Owner имя пользователя владельца роли; пусто – без владельца
+	Owner       string   `yaml:"owner" json:"owner"`
+	Permissions []string `yaml:"permissions" json:"permissions"`
+}
+
+// file содержимое одного YAML-файла
+type file struct {
+	Roles []Definition `yaml:"roles"`
+}
+
+// Load прочитать описания ролей из YAML; name – имя файла для сообщений об ошибках
+func Load(name string, reader io.Reader) ([]Definition, error) {
+	decoder := yaml.NewDecoder(reader)
+	decoder.KnownFields(true)
+
+	var definitions []Definition
+	for {
+		var content file
+		err := decoder.Decode(&content)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %w", ErrInvalidDefinition, name, err)
+		}
+		definitions = append(definitions, content.Roles...)
+	}
+
+	for i := range definitions {
+		definitions[i] = definitions[i].normalize()
+		if definitions[i].Name == "" {
+			return nil, fmt.Errorf("%w: %s: role %d has no name", ErrInvalidDefinition, name, i+1)
+		}
+	}
+
+	return definitions, nil
+}
+
+// LoadFiles прочитать файлы и каталоги с файлами *.yaml и *.yml. Роль с одним именем
+// может быть описана только один раз во всех файлах.
+func LoadFiles(paths []string) ([]Definition, error) {
+	var files []string
+	for _, path := range paths {
+		err := filepath.WalkDir(path, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			extension := strings.ToLower(filepath.Ext(path))
+			if !entry.IsDir() && (extension == ".yaml" || extension == ".yml") {
+				files = append(files, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	slices.Sort(files)
+
+	var definitions []Definition
+	declared := map[string]string{}
+	for _, path := range files {
+		content, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		loaded, err := Load(path, content)
+		_ = content.Close()
+		if err != nil {
+			return nil, err
+		}
+		for _, definition := range loaded {
+			key := strings.ToLower(definition.Name)
+			if previous, ok := declared[key]; ok {
+				return nil, fmt.Errorf("%w: role %q is declared in %s and %s", ErrInvalidDefinition, definition.Name, previous, path)
+			}
+			declared[key] = path
+		}
+		definitions = append(definitions, loaded...)
+	}
+
+	return definitions, nil
+}
+
+func (d Definition) normalize() Definition {
+	d.Name = strings.TrimSpace(d.Name)
+	d.Owner = strings.TrimSpace(d.Owner)
+	permissions := make([]string, 0, len(d.Permissions))
+	for _, permission := range d.Permissions {
+		if permission = strings.TrimSpace(permission); permission != "" {
+			permissions = append(permissions, permission)
+		}
+	}
+	slices.Sort(permissions)
+	d.Permissions = slices.Compact(permissions)
+
+	return d
+}
+
+// Hash отпечаток набора ролей, не зависящий от порядка ролей и прав
+func Hash(definitions []Definition) string {
+	normalized := make([]Definition, 0, len(definitions))
+	for _, definition := range definitions {
+		definition = definition.normalize()
+		definition.Owner = strings.ToLower(definition.Owner)
+		normalized = append(normalized, definition)
+	}
+	slices.SortFunc(normalized, func(a, b Definition) int {
+		return strings.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name))
+	})
+
+	encoded, _ := json.Marshal(normalized)
+	sum := sha256.Sum256(encoded)
+
+	return hex.EncodeToString(sum[:])
+}
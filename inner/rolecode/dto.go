@@ -0,0 +1,74 @@
+package rolecode
+
+import (
+	"github.com/lib/pq"
+	"time"
+)
+
+// Current роль в базе вместе с именем пользователя владельца и участниками
+type Current struct {
+	Id          int64          `db:"id"`
+	Name        string         `db:"name"`
+	Description string         `db:"description"`
+	Owner       string         `db:"owner"`
+	Permissions pq.StringArray `db:"permissions"`
+	Members     pq.Int64Array  `db:"members"`
+}
+
+func (c *Current) definition() Definition {
+	return Definition{Name: c.Name, Description: c.Description, Owner: c.Owner, Permissions: c.Permissions}.normalize()
+}
+
+// State отпечаток ролей после применения
+type State struct {
+	Id        int64     `db:"id"`
+	Hash      string    `db:"hash"`
+	Roles     int       `db:"roles"`
+	AppliedAt time.Time `db:"applied_at"`
+}
+
+// Plan изменения, которые приведут роли в базе к описанным в файлах
+type Plan struct {
+	Changes []Change `json:"changes"`
+	// Hash отпечаток описанных ролей; после применения он записывается как состояние
+	Hash string `json:"hash"`
+	// CurrentHash отпечаток ролей в базе до применения
+	CurrentHash     string `json:"current_hash"`
+	LastAppliedHash string `json:"last_applied_hash,omitempty"`
+	// Drifted роли меняли в обход файлов после последнего применения
+	Drifted bool `json:"drifted"`
+	Applied bool `json:"applied"`
+}
+
+// Change изменение одной роли. RoleId пуст для роли, которая ещё не создана.
+type Change struct {
+	Action string                 `json:"action"`
+	Name   string                 `json:"name"`
+	RoleId int64                  `json:"role_id,omitempty"`
+	Fields map[string]FieldChange `json:"fields,omitempty"`
+	// Members сотрудники, у которых удаляемая роль выдана
+	Members []int64 `json:"members,omitempty"`
+
+	definition Definition
+}
+
+type FieldChange struct {
+	From any `json:"from"`
+	To   any `json:"to"`
+}
+
+// Counts число созданий, изменений и удалений
+func (p *Plan) Counts() (create int, update int, remove int) {
+	for _, change := range p.Changes {
+		switch change.Action {
+		case ActionCreate:
+			create++
+		case ActionUpdate:
+			update++
+		case ActionDelete:
+			remove++
+		}
+	}
+
+	return create, update, remove
+}
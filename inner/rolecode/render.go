@@ -0,0 +1,85 @@
+package rolecode
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// fieldOrder порядок полей в выводе плана
+var fieldOrder = []string{"name", "description", "owner", "permissions"}
+
+// Render вывести план в духе terraform plan
+func (p *Plan) Render(w io.Writer) error {
+	var b strings.Builder
+
+	if p.Drifted {
+		b.WriteString("Warning: roles were changed outside of the role definitions since they were last applied.\n\n")
+	}
+
+	create, update, remove := p.Counts()
+	if create+update+remove == 0 {
+		b.WriteString("No changes. Roles match the definitions.\n")
+		_, err := io.WriteString(w, b.String())
+		return err
+	}
+
+	b.WriteString("Roles will be changed as follows:\n  + create\n  ~ update in-place\n  - destroy\n\n")
+	for _, change := range p.Changes {
+		switch change.Action {
+		case ActionCreate:
+			fmt.Fprintf(&b, "  # role %s will be created\n  + role %s {\n", strconv.Quote(change.Name), strconv.Quote(change.Name))
+			renderFields(&b, change.Fields, "+", false)
+			b.WriteString("    }\n\n")
+		case ActionUpdate:
+			fmt.Fprintf(&b, "  # role %s will be updated in-place\n  ~ role %s {\n", strconv.Quote(change.Name), strconv.Quote(change.Name))
+			renderFields(&b, change.Fields, "~", true)
+			b.WriteString("    }\n\n")
+		case ActionDelete:
+			fmt.Fprintf(&b, "  # role %s will be destroyed", strconv.Quote(change.Name))
+			if len(change.Members) > 0 {
+				fmt.Fprintf(&b, " together with its %d assignments", len(change.Members))
+			}
+			fmt.Fprintf(&b, "\n  - role %s\n\n", strconv.Quote(change.Name))
+		}
+	}
+	fmt.Fprintf(&b, "Plan: %d to create, %d to update, %d to destroy.\n", create, update, remove)
+
+	_, err := io.WriteString(w, b.String())
+
+	return err
+}
+
+func renderFields(b *strings.Builder, fields map[string]FieldChange, sign string, withFrom bool) {
+	width := 0
+	for name := range fields {
+		width = max(width, len(name))
+	}
+	for _, name := range fieldOrder {
+		field, ok := fields[name]
+		if !ok {
+			continue
+		}
+		value := format(field.To)
+		if withFrom {
+			value = format(field.From) + " -> " + value
+		}
+		fmt.Fprintf(b, "      %s %-*s = %s\n", sign, width, name, value)
+	}
+}
+
+func format(value any) string {
+	switch v := value.(type) {
+	case string:
+		return strconv.Quote(v)
+	case []string:
+		quoted := make([]string, 0, len(v))
+		for _, item := range v {
+			quoted = append(quoted, strconv.Quote(item))
+		}
+		return "[" + strings.Join(quoted, ", ") + "]"
+	}
+
+	return "null"
+}
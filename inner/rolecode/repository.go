@@ -0,0 +1,146 @@
+package rolecode
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"idm/inner/database"
+	"time"
+)
+
+// applyTimeout тайм-аут транзакции плана и применения
+const applyTimeout = time.Minute
+
+type Repository struct {
+	db *sqlx.DB
+}
+
+func NewRepository(db *sqlx.DB) *Repository {
+	return &Repository{db: db}
+}
+
+type batch struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	tx     *sqlx.Tx
+}
+
+// Begin планы и применения выполняются по очереди, чтобы применение не разошлось с показанным планом
+func (r *Repository) Begin() (Batch, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), applyTimeout)
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	b := &batch{ctx: ctx, cancel: cancel, tx: tx}
+
+	if _, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock(hashtext('idm_roles_as_code'))"); err != nil {
+		_ = b.Rollback()
+		return nil, err
+	}
+
+	return b, nil
+}
+
+func (b *batch) Roles() ([]*Current, error) {
+	var roles []*Current
+	err := b.tx.SelectContext(b.ctx, &roles,
+		`SELECT r.id, r.name, r.description, COALESCE(o.user_name, '') AS owner, r.permissions,
+			ARRAY(SELECT er.employee_id FROM employee_roles er WHERE er.role_id = r.id ORDER BY er.employee_id) AS members
+		FROM roles r LEFT JOIN employees o ON o.id = r.owner_id
+		ORDER BY r.id
+		FOR UPDATE OF r`)
+
+	return roles, err
+}
+
+func (b *batch) Owners(userNames []string) (map[string]int64, error) {
+	var rows []struct {
+		Id       int64  `db:"id"`
+		UserName string `db:"user_name"`
+	}
+	err := b.tx.SelectContext(b.ctx, &rows,
+		"SELECT id, lower(user_name) AS user_name FROM employees WHERE lower(user_name) = ANY($1)", pq.StringArray(userNames))
+	if err != nil {
+		return nil, err
+	}
+
+	owners := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		owners[row.UserName] = row.Id
+	}
+
+	return owners, nil
+}
+
+func (b *batch) LastState() (*State, error) {
+	var state State
+	err := b.tx.GetContext(b.ctx, &state, "SELECT * FROM roles_state ORDER BY id DESC LIMIT 1")
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, database.ErrRecordNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &state, nil
+}
+
+func (b *batch) Create(definition Definition, ownerId *int64) (int64, error) {
+	var id int64
+	err := b.tx.GetContext(b.ctx, &id,
+		"INSERT INTO roles (name, description, owner_id, permissions) VALUES ($1, $2, $3, $4) RETURNING id",
+		definition.Name, definition.Description, ownerId, pq.StringArray(definition.Permissions))
+
+	return id, err
+}
+
+func (b *batch) Update(id int64, definition Definition, ownerId *int64) error {
+	_, err := b.tx.ExecContext(b.ctx,
+		`UPDATE roles SET name = $1, description = $2, owner_id = $3, permissions = $4, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $5`,
+		definition.Name, definition.Description, ownerId, pq.StringArray(definition.Permissions), id)
+
+	return err
+}
+
+func (b *batch) Remove(id int64, force bool) error {
+	result, err := b.tx.ExecContext(b.ctx,
+		`DELETE FROM roles r
+		WHERE r.id = $1 AND ($2 OR NOT EXISTS (SELECT 1 FROM employee_roles er WHERE er.role_id = r.id))`, id, force)
+	if err != nil {
+		return err
+	}
+
+	count, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrRoleHasMembers
+	}
+
+	return nil
+}
+
+func (b *batch) SaveState(hash string, roles int) error {
+	_, err := b.tx.ExecContext(b.ctx, "INSERT INTO roles_state (hash, roles) VALUES ($1, $2)", hash, roles)
+
+	return err
+}
+
+func (b *batch) Commit() error {
+	defer b.cancel()
+
+	return b.tx.Commit()
+}
+
+func (b *batch) Rollback() error {
+	defer b.cancel()
+
+	return b.tx.Rollback()
+}
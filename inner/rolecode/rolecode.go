@@ -0,0 +1,249 @@
+package rolecode
+
+import (
+	"errors"
+	"fmt"
+	"idm/inner/database"
+	"idm/inner/role"
+	"slices"
+	"strings"
+)
+
+// Действия плана
+const (
+	ActionCreate = "create"
+	ActionUpdate = "update"
+	ActionDelete = "delete"
+)
+
+var (
+	ErrUnknownOwner   = errors.New("unknown role owner")
+	ErrDuplicateRole  = errors.New("duplicate role name")
+	ErrRoleHasMembers = errors.New("role still has members")
+)
+
+// Repo роли читаются и изменяются в одной транзакции, чтобы план и его применение видели одно состояние
+type Repo interface {
+	Begin() (Batch, error)
+}
+
+type Batch interface {
+	Roles() ([]*Current, error)
+	// Owners идентификаторы сотрудников по именам пользователей в нижнем регистре
+	Owners(userNames []string) (map[string]int64, error)
+	// LastState последнее применённое состояние; database.ErrRecordNotFound, если применений не было
+	LastState() (*State, error)
+	Create(definition Definition, ownerId *int64) (int64, error)
+	Update(id int64, definition Definition, ownerId *int64) error
+	// Remove удалить роль; без force роль с участниками не удаляется и возвращается ErrRoleHasMembers
+	Remove(id int64, force bool) error
+	SaveState(hash string, roles int) error
+	Commit() error
+	Rollback() error
+}
+
+// Service план и применение ролей, описанных в файлах. Файлы описывают все роли:
+// роль, которой в них нет, удаляется.
+type Service struct {
+	repo  Repo
+	hooks []role.AssignmentHook
+}
+
+func NewService(repository Repo) *Service {
+	return &Service{repo: repository}
+}
+
+// UseHook вызывать обработчики отзыва роли для участников ролей, удалённых с force
+func (s *Service) UseHook(hook role.AssignmentHook) {
+	s.hooks = append(s.hooks, hook)
+}
+
+// Plan сравнить описания с ролями в базе, ничего не меняя
+func (s *Service) Plan(definitions []Definition) (Plan, error) {
+	batch, err := s.repo.Begin()
+	if err != nil {
+		return Plan{}, fmt.Errorf("error starting roles plan: %w", err)
+	}
+	defer func() { _ = batch.Rollback() }()
+
+	plan, _, err := s.plan(batch, definitions)
+
+	return plan, err
+}
+
+// Apply применить план в одной транзакции и записать отпечаток получившегося состояния.
+// Если удаляемая роль ещё выдана сотрудникам, без force ничего не меняется.
+func (s *Service) Apply(definitions []Definition, force bool) (Plan, error) {
+	batch, err := s.repo.Begin()
+	if err != nil {
+		return Plan{}, fmt.Errorf("error starting roles apply: %w", err)
+	}
+	defer func() { _ = batch.Rollback() }()
+
+	plan, owners, err := s.plan(batch, definitions)
+	if err != nil {
+		return plan, err
+	}
+
+	var blocked []string
+	for _, change := range plan.Changes {
+		if change.Action == ActionDelete && len(change.Members) > 0 && !force {
+			blocked = append(blocked, fmt.Sprintf("%q (%d members)", change.Name, len(change.Members)))
+		}
+	}
+	if len(blocked) > 0 {
+		return plan, fmt.Errorf("%w: %s; use force to remove them with their assignments",
+			ErrRoleHasMembers, strings.Join(blocked, ", "))
+	}
+
+	for i := range plan.Changes {
+		change := &plan.Changes[i]
+		ownerId := ownerOf(owners, change.definition.Owner)
+		switch change.Action {
+		case ActionCreate:
+			id, err := batch.Create(change.definition, ownerId)
+			if err != nil {
+				return plan, fmt.Errorf("error creating role %q: %w", change.Name, err)
+			}
+			change.RoleId = id
+		case ActionUpdate:
+			if err := batch.Update(change.RoleId, change.definition, ownerId); err != nil {
+				return plan, fmt.Errorf("error updating role %q: %w", change.Name, err)
+			}
+		case ActionDelete:
+			if err := batch.Remove(change.RoleId, force); err != nil {
+				return plan, fmt.Errorf("error removing role %q: %w", change.Name, err)
+			}
+		}
+	}
+
+	if err := batch.SaveState(plan.Hash, len(definitions)); err != nil {
+		return plan, fmt.Errorf("error saving roles state: %w", err)
+	}
+	if err := batch.Commit(); err != nil {
+		return plan, fmt.Errorf("error committing roles apply: %w", err)
+	}
+	plan.Applied = true
+	plan.LastAppliedHash = plan.Hash
+
+	for _, change := range plan.Changes {
+		if change.Action != ActionDelete {
+			continue
+		}
+		for _, employeeId := range change.Members {
+			for _, hook := range s.hooks {
+				if err := hook.AfterAssignmentChange(employeeId, change.RoleId); err != nil {
+					return plan, fmt.Errorf("error processing removal of role %q for employee %d: %w", change.Name, employeeId, err)
+				}
+			}
+		}
+	}
+
+	return plan, nil
+}
+
+func (s *Service) plan(batch Batch, definitions []Definition) (Plan, map[string]int64, error) {
+	desired := map[string]Definition{}
+	var userNames []string
+	for _, definition := range definitions {
+		definition = definition.normalize()
+		key := strings.ToLower(definition.Name)
+		if _, ok := desired[key]; ok {
+			return Plan{}, nil, fmt.Errorf("%w %q in definitions", ErrDuplicateRole, definition.Name)
+		}
+		desired[key] = definition
+		if definition.Owner != "" {
+			userNames = append(userNames, strings.ToLower(definition.Owner))
+		}
+	}
+
+	owners, err := batch.Owners(userNames)
+	if err != nil {
+		return Plan{}, nil, fmt.Errorf("error finding role owners: %w", err)
+	}
+	var unknown []string
+	for _, userName := range userNames {
+		if _, ok := owners[userName]; !ok && !slices.Contains(unknown, userName) {
+			unknown = append(unknown, userName)
+		}
+	}
+	if len(unknown) > 0 {
+		return Plan{}, nil, fmt.Errorf("%w: %s", ErrUnknownOwner, strings.Join(unknown, ", "))
+	}
+
+	roles, err := batch.Roles()
+	if err != nil {
+		return Plan{}, nil, fmt.Errorf("error finding roles: %w", err)
+	}
+	current := map[string]*Current{}
+	currentDefinitions := make([]Definition, 0, len(roles))
+	for _, r := range roles {
+		key := strings.ToLower(r.Name)
+		if other, ok := current[key]; ok {
+			return Plan{}, nil, fmt.Errorf("%w: roles %d and %d are both named %q", ErrDuplicateRole, other.Id, r.Id, r.Name)
+		}
+		current[key] = r
+		currentDefinitions = append(currentDefinitions, r.definition())
+	}
+
+	plan := Plan{Changes: []Change{}, Hash: Hash(definitions), CurrentHash: Hash(currentDefinitions)}
+	last, err := batch.LastState()
+	switch {
+	case err == nil:
+		plan.LastAppliedHash = last.Hash
+		plan.Drifted = last.Hash != plan.CurrentHash
+	case !errors.Is(err, database.ErrRecordNotFound):
+		return Plan{}, nil, fmt.Errorf("error finding roles state: %w", err)
+	}
+
+	for key, definition := range desired {
+		existing, ok := current[key]
+		if !ok {
+			plan.Changes = append(plan.Changes, Change{Action: ActionCreate, Name: definition.Name,
+				Fields: diff(Definition{}, definition), definition: definition})
+			continue
+		}
+		if fields := diff(existing.definition(), definition); len(fields) > 0 {
+			plan.Changes = append(plan.Changes, Change{Action: ActionUpdate, Name: definition.Name, RoleId: existing.Id,
+				Fields: fields, definition: definition})
+		}
+	}
+	for key, existing := range current {
+		if _, ok := desired[key]; !ok {
+			plan.Changes = append(plan.Changes, Change{Action: ActionDelete, Name: existing.Name, RoleId: existing.Id,
+				Members: existing.Members})
+		}
+	}
+	slices.SortFunc(plan.Changes, func(a, b Change) int {
+		return strings.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name))
+	})
+
+	return plan, owners, nil
+}
+
+// diff поля, которые отличаются; для новой роли – все заданные
+func diff(from Definition, to Definition) map[string]FieldChange {
+	fields := map[string]FieldChange{}
+	if from.Name != to.Name && from.Name != "" {
+		fields["name"] = FieldChange{From: from.Name, To: to.Name}
+	}
+	if from.Description != to.Description {
+		fields["description"] = FieldChange{From: from.Description, To: to.Description}
+	}
+	if !strings.EqualFold(from.Owner, to.Owner) {
+		fields["owner"] = FieldChange{From: from.Owner, To: to.Owner}
+	}
+	if !slices.Equal(from.Permissions, to.Permissions) {
+		fields["permissions"] = FieldChange{From: from.Permissions, To: to.Permissions}
+	}
+
+	return fields
+}
+
+func ownerOf(owners map[string]int64, userName string) *int64 {
+	if id, ok := owners[strings.ToLower(userName)]; ok {
+		return &id
+	}
+
+	return nil
+}
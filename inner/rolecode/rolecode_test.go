@@ -0,0 +1,311 @@
+package rolecode
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	assertpackage "github.com/stretchr/testify/assert"
+	"idm/inner/database"
+)
+
+// StubRepo роли в памяти; изменения пакета видны только после Commit
+type StubRepo struct {
+	roles  []*Current
+	owners map[string]int64
+	states []*State
+	nextId int64
+}
+
+type StubBatch struct {
+	repo   *StubRepo
+	roles  []*Current
+	states []*State
+}
+
+type StubHook struct {
+	calls [][2]int64
+}
+
+func (h *StubHook) AfterAssignmentChange(employeeId int64, roleId int64) error {
+	h.calls = append(h.calls, [2]int64{employeeId, roleId})
+	return nil
+}
+
+func (r *StubRepo) Begin() (Batch, error) {
+	var roles []*Current
+	for _, role := range r.roles {
+		copied := *role
+		roles = append(roles, &copied)
+	}
+	return &StubBatch{repo: r, roles: roles, states: slices.Clone(r.states)}, nil
+}
+
+func (b *StubBatch) Roles() ([]*Current, error) {
+	return b.roles, nil
+}
+
+func (b *StubBatch) Owners(userNames []string) (map[string]int64, error) {
+	owners := map[string]int64{}
+	for _, userName := range userNames {
+		if id, ok := b.repo.owners[userName]; ok {
+			owners[userName] = id
+		}
+	}
+	return owners, nil
+}
+
+func (b *StubBatch) LastState() (*State, error) {
+	if len(b.states) == 0 {
+		return nil, database.ErrRecordNotFound
+	}
+	return b.states[len(b.states)-1], nil
+}
+
+func (b *StubBatch) owner(ownerId *int64) string {
+	for userName, id := range b.repo.owners {
+		if ownerId != nil && id == *ownerId {
+			return userName
+		}
+	}
+	return ""
+}
+
+func (b *StubBatch) Create(definition Definition, ownerId *int64) (int64, error) {
+	b.repo.nextId++
+	b.roles = append(b.roles, &Current{Id: b.repo.nextId, Name: definition.Name, Description: definition.Description,
+		Owner: b.owner(ownerId), Permissions: definition.Permissions})
+	return b.repo.nextId, nil
+}
+
+func (b *StubBatch) Update(id int64, definition Definition, ownerId *int64) error {
+	for _, role := range b.roles {
+		if role.Id == id {
+			role.Name, role.Description, role.Owner = definition.Name, definition.Description, b.owner(ownerId)
+			role.Permissions = definition.Permissions
+		}
+	}
+	return nil
+}
+
+func (b *StubBatch) Remove(id int64, force bool) error {
+	index := slices.IndexFunc(b.roles, func(role *Current) bool { return role.Id == id })
+	if len(b.roles[index].Members) > 0 && !force {
+		return ErrRoleHasMembers
+	}
+	b.roles = slices.Delete(b.roles, index, index+1)
+	return nil
+}
+
+func (b *StubBatch) SaveState(hash string, roles int) error {
+	b.states = append(b.states, &State{Id: int64(len(b.states) + 1), Hash: hash, Roles: roles})
+	return nil
+}
+
+func (b *StubBatch) Commit() error {
+	b.repo.roles, b.repo.states = b.roles, b.states
+	return nil
+}
+
+func (b *StubBatch) Rollback() error {
+	return nil
+}
+
+func stub() *StubRepo {
+	return &StubRepo{
+		roles: []*Current{
+			{Id: 1, Name: "Developers", Description: "Engineers", Owner: "ivanov", Permissions: []string{"git:write", "git:read"},
+				Members: []int64{10}},
+			{Id: 2, Name: "legacy", Members: []int64{11, 12}},
+			{Id: 3, Name: "unused"},
+		},
+		owners: map[string]int64{"ivanov": 100, "petrov": 101},
+		nextId: 3,
+	}
+}
+
+const definitions = `
+roles:
+  - name: developers
+    description: Software engineers
+    owner: Petrov
+    permissions: [git:read, git:write, git:read]
+  - name: auditors
+    description: Read-only access for audits
+    permissions:
+      - reports:read
+`
+
+func load(t *testing.T, content string) []Definition {
+	loaded, err := Load("roles.yaml", strings.NewReader(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return loaded
+}
+
+func TestLoad(t *testing.T) {
+	var assert = assertpackage.New(t)
+
+	t.Run("should read and normalize definitions", func(t *testing.T) {
+		loaded, err := Load("roles.yaml", strings.NewReader(definitions+"---\nroles:\n  - name: ' extra '\n"))
+
+		assert.Nil(err)
+		assert.Equal([]Definition{
+			{Name: "developers", Description: "Software engineers", Owner: "Petrov", Permissions: []string{"git:read", "git:write"}},
+			{Name: "auditors", Description: "Read-only access for audits", Permissions: []string{"reports:read"}},
+			{Name: "extra", Permissions: []string{}},
+		}, loaded)
+	})
+
+	t.Run("should reject unknown fields and nameless roles", func(t *testing.T) {
+		_, err := Load("roles.yaml", strings.NewReader("roles:\n  - name: a\n    members: [ivanov]\n"))
+		assert.ErrorIs(err, ErrInvalidDefinition)
+		_, err = Load("roles.yaml", strings.NewReader("roles:\n  - description: no name\n"))
+		assert.ErrorIs(err, ErrInvalidDefinition)
+	})
+
+	t.Run("should load directories and reject a role declared twice", func(t *testing.T) {
+		directory := t.TempDir()
+		_ = os.WriteFile(filepath.Join(directory, "a.yaml"), []byte("roles:\n  - name: a\n"), 0o600)
+		_ = os.MkdirAll(filepath.Join(directory, "team"), 0o700)
+		_ = os.WriteFile(filepath.Join(directory, "team", "b.yml"), []byte("roles:\n  - name: b\n"), 0o600)
+		_ = os.WriteFile(filepath.Join(directory, "README.md"), []byte("not yaml"), 0o600)
+
+		loaded, err := LoadFiles([]string{directory})
+		assert.Nil(err)
+		assert.Len(loaded, 2)
+
+		_ = os.WriteFile(filepath.Join(directory, "c.yaml"), []byte("roles:\n  - name: A\n"), 0o600)
+		_, err = LoadFiles([]string{directory})
+		assert.ErrorIs(err, ErrInvalidDefinition)
+	})
+
+	t.Run("should hash independently of order", func(t *testing.T) {
+		a := []Definition{{Name: "a", Permissions: []string{"x", "y"}}, {Name: "b", Owner: "Ivanov"}}
+		b := []Definition{{Name: "b", Owner: "ivanov"}, {Name: "a", Permissions: []string{"y", "x"}}}
+
+		assert.Equal(Hash(a), Hash(b))
+		assert.NotEqual(Hash(a), Hash(a[:1]))
+	})
+}
+
+func TestService(t *testing.T) {
+	var assert = assertpackage.New(t)
+
+	t.Run("should plan creates, updates and deletes without changing anything", func(t *testing.T) {
+		repo := stub()
+		service := NewService(repo)
+
+		plan, err := service.Plan(load(t, definitions))
+
+		assert.Nil(err)
+		assert.False(plan.Applied)
+		assert.Equal([]Change{
+			{Action: ActionCreate, Name: "auditors", Fields: map[string]FieldChange{
+				"description": {From: "", To: "Read-only access for audits"},
+				"permissions": {From: []string(nil), To: []string{"reports:read"}},
+			}},
+			{Action: ActionUpdate, Name: "developers", RoleId: 1, Fields: map[string]FieldChange{
+				"name":        {From: "Developers", To: "developers"},
+				"description": {From: "Engineers", To: "Software engineers"},
+				"owner":       {From: "ivanov", To: "Petrov"},
+			}},
+			{Action: ActionDelete, Name: "legacy", RoleId: 2, Members: []int64{11, 12}},
+			{Action: ActionDelete, Name: "unused", RoleId: 3},
+		}, stripDefinitions(plan.Changes))
+		assert.Len(repo.roles, 3)
+		assert.Empty(repo.states)
+
+		var output strings.Builder
+		assert.Nil(plan.Render(&output))
+		assert.Equal(`Roles will be changed as follows:
+  + create
+  ~ update in-place
+  - destroy
+
+  # role "auditors" will be created
+  + role "auditors" {
+      + description = "Read-only access for audits"
+      + permissions = ["reports:read"]
+    }
+
+  # role "developers" will be updated in-place
+  ~ role "developers" {
+      ~ name        = "Developers" -> "developers"
+      ~ description = "Engineers" -> "Software engineers"
+      ~ owner       = "ivanov" -> "Petrov"
+    }
+
+  # role "legacy" will be destroyed together with its 2 assignments
+  - role "legacy"
+
+  # role "unused" will be destroyed
+  - role "unused"
+
+Plan: 1 to create, 1 to update, 2 to destroy.
+`, output.String())
+	})
+
+	t.Run("should refuse to destroy roles with members unless forced", func(t *testing.T) {
+		repo := stub()
+		hook := &StubHook{}
+		service := NewService(repo)
+		service.UseHook(hook)
+
+		_, err := service.Apply(load(t, definitions), false)
+
+		assert.ErrorIs(err, ErrRoleHasMembers)
+		assert.Contains(err.Error(), `"legacy" (2 members)`)
+		assert.Len(repo.roles, 3)
+		assert.Empty(repo.states)
+
+		plan, err := service.Apply(load(t, definitions), true)
+
+		assert.Nil(err)
+		assert.True(plan.Applied)
+		assert.Equal(int64(4), plan.Changes[0].RoleId)
+		assert.Equal([]string{"developers", "auditors"}, []string{repo.roles[0].Name, repo.roles[1].Name})
+		assert.Equal(int64(101), repo.owners[repo.roles[0].Owner])
+		assert.Equal([]*State{{Id: 1, Hash: plan.Hash, Roles: 2}}, repo.states)
+		assert.Equal([][2]int64{{11, 2}, {12, 2}}, hook.calls)
+	})
+
+	t.Run("should have nothing to do after apply and detect later drift", func(t *testing.T) {
+		repo := stub()
+		service := NewService(repo)
+		_, _ = service.Apply(load(t, definitions), true)
+
+		plan, err := service.Plan(load(t, definitions))
+		assert.Nil(err)
+		assert.Empty(plan.Changes)
+		assert.False(plan.Drifted)
+		assert.Equal(plan.Hash, plan.CurrentHash)
+
+		repo.roles[1].Description = "changed by hand"
+		plan, _ = service.Plan(load(t, definitions))
+		assert.True(plan.Drifted)
+		assert.Len(plan.Changes, 1)
+		var output strings.Builder
+		_ = plan.Render(&output)
+		assert.True(strings.HasPrefix(output.String(), "Warning: roles were changed outside"))
+	})
+
+	t.Run("should reject unknown owners and duplicate names", func(t *testing.T) {
+		service := NewService(stub())
+
+		_, err := service.Plan([]Definition{{Name: "a", Owner: "nobody"}})
+		assert.ErrorIs(err, ErrUnknownOwner)
+		_, err = service.Plan([]Definition{{Name: "a"}, {Name: "A"}})
+		assert.ErrorIs(err, ErrDuplicateRole)
+	})
+}
+
+func stripDefinitions(changes []Change) []Change {
+	for i := range changes {
+		changes[i].definition = Definition{}
+	}
+	return changes
+}
@@ -0,0 +1,94 @@
+package router
+
+import (
+	"idm/inner/serviceaccount"
+	"net/http"
+)
+
+// Keys проверяет ключ служебной учётной записи и его право перед вызовом обработчика
+type Keys interface {
+	RequireScope(scope string, next http.Handler) http.Handler
+}
+
+// Handlers обработчики HTTP API. Пути в них задаются относительно точки монтирования.
+// LDAP и Provisioning необязательны: nil – синхронизация не настроена и пути не монтируются.
+type Handlers struct {
+	OpenAPI             http.Handler
+	Login               http.Handler
+	Sessions            http.Handler
+	AdminSessions       http.Handler
+	SCIM                http.Handler
+	ServiceAccounts     http.Handler
+	Import              http.Handler
+	HR                  http.Handler
+	Export              http.Handler
+	GraphQL             http.Handler
+	Org                 http.Handler
+	Groups              http.Handler
+	AccessRequests      http.Handler
+	AdminAccessRequests http.Handler
+	Certifications      http.Handler
+	AdminCertifications http.Handler
+	Lifecycle           http.Handler
+	SoD                 http.Handler
+	Webhooks            http.Handler
+	OIDC                http.Handler
+	LDAP                http.Handler
+	Provisioning        http.Handler
+}
+
+// Routes смонтировать обработчики в mux. Операции ключей служебных учётных записей
+// проверяются keys; вход, сеансы, заявки и пересмотр сотрудника, OIDC и документ OpenAPI
+// проверяют доступ сами.
+func Routes(mux *http.ServeMux, handlers Handlers, keys Keys) {
+	mux.Handle("GET /openapi.json", handlers.OpenAPI)
+	mux.Handle("/login/", http.StripPrefix("/login", handlers.Login))
+	mux.Handle("/sessions/", http.StripPrefix("/sessions", handlers.Sessions))
+	mux.Handle("/oidc/", http.StripPrefix("/oidc", handlers.OIDC))
+	mux.Handle("/access-requests/", http.StripPrefix("/access-requests", handlers.AccessRequests))
+	mux.Handle("/certifications/", http.StripPrefix("/certifications", handlers.Certifications))
+
+	mux.Handle("/admin/employees/", http.StripPrefix("/admin/employees",
+		keys.RequireScope(serviceaccount.ScopeEmployeesWrite, handlers.AdminSessions)))
+	mux.Handle("/scim/v2/", http.StripPrefix("/scim/v2", keys.RequireScope(serviceaccount.ScopeSCIM, handlers.SCIM)))
+	mux.Handle("/service-accounts/", http.StripPrefix("/service-accounts",
+		keys.RequireScope(serviceaccount.ScopeServiceAccounts, handlers.ServiceAccounts)))
+
+	importHandler := http.StripPrefix("/import", handlers.Import)
+	mux.Handle("POST /import/employees", keys.RequireScope(serviceaccount.ScopeEmployeesWrite, importHandler))
+	mux.Handle("POST /import/roles", keys.RequireScope(serviceaccount.ScopeRolesWrite, importHandler))
+	mux.Handle("/admin/hr/", http.StripPrefix("/admin/hr", keys.RequireScope(serviceaccount.ScopeEmployeesWrite, handlers.HR)))
+	exportHandler := http.StripPrefix("/export", handlers.Export)
+	mux.Handle("GET /export/employees", keys.RequireScope(serviceaccount.ScopeEmployeesRead, exportHandler))
+	mux.Handle("GET /export/roles", keys.RequireScope(serviceaccount.ScopeRolesRead, exportHandler))
+	mux.Handle("GET /export/assignments", keys.RequireScope(serviceaccount.ScopeRolesRead, exportHandler))
+	mux.Handle("/graphql", keys.RequireScope(serviceaccount.ScopeEmployeesRead, handlers.GraphQL))
+
+	// чтение и изменение одного обработчика требуют разных прав
+	readWrite(mux, keys, "/org", handlers.Org, serviceaccount.ScopeEmployeesRead, serviceaccount.ScopeEmployeesWrite)
+	readWrite(mux, keys, "/groups", handlers.Groups, serviceaccount.ScopeRolesRead, serviceaccount.ScopeRolesWrite)
+	readWrite(mux, keys, "/admin/access-requests", handlers.AdminAccessRequests,
+		serviceaccount.ScopeRolesRead, serviceaccount.ScopeRolesWrite)
+	readWrite(mux, keys, "/admin/certifications", handlers.AdminCertifications,
+		serviceaccount.ScopeRolesRead, serviceaccount.ScopeRolesWrite)
+	readWrite(mux, keys, "/admin/lifecycle", handlers.Lifecycle,
+		serviceaccount.ScopeEmployeesRead, serviceaccount.ScopeEmployeesWrite)
+	readWrite(mux, keys, "/admin/sod", handlers.SoD, serviceaccount.ScopeRolesRead, serviceaccount.ScopeRolesWrite)
+
+	mux.Handle("/webhooks/", http.StripPrefix("/webhooks", keys.RequireScope(serviceaccount.ScopeWebhooks, handlers.Webhooks)))
+	if handlers.LDAP != nil {
+		mux.Handle("/admin/ldap/", http.StripPrefix("/admin/ldap",
+			keys.RequireScope(serviceaccount.ScopeEmployeesWrite, handlers.LDAP)))
+	}
+	if handlers.Provisioning != nil {
+		mux.Handle("/admin/provisioning/", http.StripPrefix("/admin/provisioning",
+			keys.RequireScope(serviceaccount.ScopeProvisioning, handlers.Provisioning)))
+	}
+}
+
+// readWrite смонтировать обработчик под prefix: GET – с правом read, остальные методы – с правом write
+func readWrite(mux *http.ServeMux, keys Keys, prefix string, handler http.Handler, read string, write string) {
+	stripped := http.StripPrefix(prefix, handler)
+	mux.Handle("GET "+prefix+"/", keys.RequireScope(read, stripped))
+	mux.Handle(prefix+"/", keys.RequireScope(write, stripped))
+}
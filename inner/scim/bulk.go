@@ -0,0 +1,172 @@
+package scim
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// bulkRecorder http.ResponseWriter, в который пишется ответ одной операции bulk-запроса
+type bulkRecorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (r *bulkRecorder) Header() http.Header {
+	return r.header
+}
+
+func (r *bulkRecorder) Write(data []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+
+	return r.body.Write(data)
+}
+
+func (r *bulkRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+}
+
+// bulk выполнить операции по очереди (RFC 7644, раздел 3.7). Ссылки bulkId:<id> в пути и данных
+// заменяются на идентификаторы ресурсов, созданных предыдущими операциями.
+func (h *Handler) bulk(w http.ResponseWriter, r *http.Request) {
+	data, err := io.ReadAll(io.LimitReader(r.Body, MaxBulkPayloadSize+1))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalidSyntax", err.Error())
+		return
+	}
+	if len(data) > MaxBulkPayloadSize {
+		writeError(w, http.StatusRequestEntityTooLarge, "",
+			fmt.Sprintf("bulk payload exceeds %d bytes", MaxBulkPayloadSize))
+		return
+	}
+
+	var request BulkRequest
+	if err := json.Unmarshal(data, &request); err != nil {
+		writeError(w, http.StatusBadRequest, "invalidSyntax", err.Error())
+		return
+	}
+	if len(request.Operations) > MaxBulkOperations {
+		writeError(w, http.StatusRequestEntityTooLarge, "tooMany",
+			fmt.Sprintf("bulk request exceeds %d operations", MaxBulkOperations))
+		return
+	}
+
+	response := BulkResponse{Schemas: []string{SchemaBulkResponse}, Operations: []BulkOperation{}}
+	ids := make(map[string]string)
+	failures := 0
+
+	for _, operation := range request.Operations {
+		result := h.bulkOperation(r, operation, ids)
+		response.Operations = append(response.Operations, result)
+
+		status, _ := strconv.Atoi(result.Status)
+		if status >= http.StatusBadRequest {
+			failures++
+			if request.FailOnErrors > 0 && failures >= request.FailOnErrors {
+				break
+			}
+		}
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+func (h *Handler) bulkOperation(r *http.Request, operation BulkOperation, ids map[string]string) BulkOperation {
+	method := strings.ToUpper(operation.Method)
+	result := BulkOperation{Method: method, BulkId: operation.BulkId, Version: operation.Version}
+
+	if method == http.MethodPost && operation.BulkId == "" {
+		return bulkFailure(result, http.StatusBadRequest, "invalidValue", "bulkId is required for POST")
+	}
+
+	path, data, err := resolveBulkIds(operation.Path, operation.Data, ids)
+	if err != nil {
+		return bulkFailure(result, http.StatusConflict, "invalidValue", err.Error())
+	}
+
+	request, err := http.NewRequestWithContext(r.Context(), method, path, bytes.NewReader(data))
+	if err != nil {
+		return bulkFailure(result, http.StatusBadRequest, "invalidPath", err.Error())
+	}
+	request.Header.Set("Content-Type", ContentType)
+	if operation.Version != "" {
+		request.Header.Set("If-Match", operation.Version)
+	}
+
+	recorder := &bulkRecorder{header: make(http.Header)}
+	h.mux.ServeHTTP(recorder, request)
+
+	result.Status = strconv.Itoa(recorder.status)
+	result.Location = recorder.header.Get("Location")
+	if version := recorder.header.Get("ETag"); version != "" {
+		result.Version = version
+	}
+
+	if recorder.status >= http.StatusBadRequest {
+		result.Response = json.RawMessage(bytes.TrimSpace(recorder.body.Bytes()))
+		return result
+	}
+
+	if method == http.MethodPost {
+		var created struct {
+			Id string `json:"id"`
+		}
+		if err := json.Unmarshal(recorder.body.Bytes(), &created); err == nil {
+			ids[operation.BulkId] = created.Id
+		}
+	}
+	if result.Location == "" && method != http.MethodDelete {
+		result.Location = h.baseURL + path
+	}
+
+	return result
+}
+
+func resolveBulkIds(path string, data json.RawMessage, ids map[string]string) (string, []byte, error) {
+	text := path + "\x00" + string(data)
+
+	for {
+		start := strings.Index(text, "bulkId:")
+		if start < 0 {
+			break
+		}
+		end := start + len("bulkId:")
+		for end < len(text) && text[end] != '"' && text[end] != '/' && text[end] != '\x00' {
+			end++
+		}
+
+		bulkId := text[start+len("bulkId:") : end]
+		id, ok := ids[bulkId]
+		if !ok {
+			return "", nil, fmt.Errorf("unresolved reference bulkId:%s", bulkId)
+		}
+		text = text[:start] + id + text[end:]
+	}
+
+	parts := strings.SplitN(text, "\x00", 2)
+
+	return parts[0], []byte(parts[1]), nil
+}
+
+func bulkFailure(result BulkOperation, status int, scimType string, detail string) BulkOperation {
+	body, _ := json.Marshal(ErrorResponse{
+		Schemas:  []string{SchemaError},
+		Status:   strconv.Itoa(status),
+		ScimType: scimType,
+		Detail:   detail,
+	})
+
+	result.Status = strconv.Itoa(status)
+	result.Response = body
+
+	return result
+}
@@ -0,0 +1,817 @@
+package scim
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/lib/pq"
+	"idm/inner/database"
+	"idm/inner/employee"
+	"idm/inner/role"
+	"idm/inner/scimfilter"
+	"idm/inner/sod"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrUniqueness = errors.New("userName is already taken")
+	ErrNoTarget   = errors.New("path did not match any attribute")
+)
+
+// Employees операции employee.Service, которые использует SCIM
+type Employees interface {
+	FindById(id int64) (employee.Response, error)
+	FindAll() ([]employee.Response, error)
+	Create(name string) (employee.Response, error)
+	Update(id int64, request employee.UpdateRequest) (employee.Response, error)
+	SetActive(id int64, active bool) (employee.Response, error)
+	Remove(id int64) error
+}
+
+// Roles операции role.Service, которые использует SCIM. Группы SCIM соответствуют ролям.
+type Roles interface {
+	FindById(id int64) (role.Response, error)
+	FindAll() ([]role.Response, error)
+	Create(name string) (role.Response, error)
+	Rename(id int64, name string) (role.Response, error)
+	Remove(id int64) error
+	FindByEmployeeId(employeeId int64) ([]role.Response, error)
+	FindEmployeeIds(roleId int64) ([]int64, error)
+	FindAssignments(employeeIds []int64, roleIds []int64) ([]role.Assignment, error)
+	Assign(employeeId int64, roleId int64) error
+	Revoke(employeeId int64, roleId int64) error
+}
+
+// Handler HTTP-обработчик SCIM 2.0. Пути обрабатываются относительно корня SCIM,
+// поэтому при монтировании нужно использовать http.StripPrefix.
+type Handler struct {
+	employees Employees
+	roles     Roles
+	baseURL   string
+	mux       *http.ServeMux
+}
+
+// NewHandler создать обработчик. baseURL – внешний адрес корня SCIM, например https://idm.example.com/scim/v2,
+// используется в meta.location.
+func NewHandler(employees Employees, roles Roles, baseURL string) *Handler {
+	h := &Handler{
+		employees: employees,
+		roles:     roles,
+		baseURL:   strings.TrimSuffix(baseURL, "/"),
+		mux:       http.NewServeMux(),
+	}
+
+	h.mux.HandleFunc("GET /ServiceProviderConfig", h.getServiceProviderConfig)
+	h.mux.HandleFunc("GET /Schemas", h.getSchemas)
+	h.mux.HandleFunc("GET /ResourceTypes", h.getResourceTypes)
+	h.mux.HandleFunc("GET /Users", h.listUsers)
+	h.mux.HandleFunc("POST /Users", h.createUser)
+	h.mux.HandleFunc("GET /Users/{id}", h.getUser)
+	h.mux.HandleFunc("PUT /Users/{id}", h.replaceUser)
+	h.mux.HandleFunc("PATCH /Users/{id}", h.patchUser)
+	h.mux.HandleFunc("DELETE /Users/{id}", h.deleteUser)
+	h.mux.HandleFunc("GET /Groups", h.listGroups)
+	h.mux.HandleFunc("POST /Groups", h.createGroup)
+	h.mux.HandleFunc("GET /Groups/{id}", h.getGroup)
+	h.mux.HandleFunc("PUT /Groups/{id}", h.replaceGroup)
+	h.mux.HandleFunc("PATCH /Groups/{id}", h.patchGroup)
+	h.mux.HandleFunc("DELETE /Groups/{id}", h.deleteGroup)
+	h.mux.HandleFunc("POST /Bulk", h.bulk)
+
+	return h
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+func (h *Handler) getServiceProviderConfig(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, serviceProviderConfig(h.baseURL))
+}
+
+func (h *Handler) getSchemas(w http.ResponseWriter, r *http.Request) {
+	items := schemas(h.baseURL)
+	writeJSON(w, http.StatusOK, listResponse(items, len(items), 1))
+}
+
+func (h *Handler) getResourceTypes(w http.ResponseWriter, r *http.Request) {
+	items := resourceTypes(h.baseURL)
+	writeJSON(w, http.StatusOK, listResponse(items, len(items), 1))
+}
+
+// listUsers роли всех сотрудников загружаются одним запросом, а не по запросу на сотрудника
+func (h *Handler) listUsers(w http.ResponseWriter, r *http.Request) {
+	employees, err := h.employees.FindAll()
+	if err != nil {
+		h.fail(w, err)
+		return
+	}
+	roles, err := h.roles.FindAll()
+	if err != nil {
+		h.fail(w, err)
+		return
+	}
+
+	ids := make([]int64, 0, len(employees))
+	for _, employee := range employees {
+		ids = append(ids, employee.Id)
+	}
+	assignments, err := h.roles.FindAssignments(ids, nil)
+	if err != nil {
+		h.fail(w, err)
+		return
+	}
+
+	byId := make(map[int64]role.Response, len(roles))
+	for _, role := range roles {
+		byId[role.Id] = role
+	}
+	byEmployee := make(map[int64][]role.Response)
+	for _, assignment := range assignments {
+		byEmployee[assignment.EmployeeId] = append(byEmployee[assignment.EmployeeId], byId[assignment.RoleId])
+	}
+
+	var resources []any
+	for _, employee := range employees {
+		resources = append(resources, h.toUser(employee, byEmployee[employee.Id]))
+	}
+
+	h.list(w, r, resources)
+}
+
+func (h *Handler) getUser(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.findUser(w, r)
+	if !ok {
+		return
+	}
+
+	h.respond(w, r, http.StatusOK, user, user.Meta)
+}
+
+func (h *Handler) createUser(w http.ResponseWriter, r *http.Request) {
+	var user User
+	if !decode(w, r, &user) {
+		return
+	}
+
+	if err := checkUserName(user.UserName); err != nil {
+		h.fail(w, err)
+		return
+	}
+
+	created, err := h.employees.Create(displayName(user))
+	if err != nil {
+		h.fail(w, err)
+		return
+	}
+
+	result, err := h.saveUser(created, user)
+	if err != nil {
+		// сотрудник без userName не должен остаться, если, например, имя уже занято
+		_ = h.employees.Remove(created.Id)
+		h.fail(w, err)
+		return
+	}
+
+	w.Header().Set("Location", result.Meta.Location)
+	h.respond(w, r, http.StatusCreated, result, result.Meta)
+}
+
+func (h *Handler) replaceUser(w http.ResponseWriter, r *http.Request) {
+	current, ok := h.findUser(w, r)
+	if !ok || !checkVersion(w, r, current.Meta) {
+		return
+	}
+
+	var user User
+	if !decode(w, r, &user) {
+		return
+	}
+
+	h.updateUser(w, r, current, user)
+}
+
+func (h *Handler) patchUser(w http.ResponseWriter, r *http.Request) {
+	current, ok := h.findUser(w, r)
+	if !ok || !checkVersion(w, r, current.Meta) {
+		return
+	}
+
+	var request PatchRequest
+	if !decode(w, r, &request) {
+		return
+	}
+
+	var user User
+	if err := patch(current, request.Operations, &user); err != nil {
+		h.fail(w, err)
+		return
+	}
+
+	h.updateUser(w, r, current, user)
+}
+
+func (h *Handler) updateUser(w http.ResponseWriter, r *http.Request, current *User, user User) {
+	id, _ := strconv.ParseInt(current.Id, 10, 64)
+	if err := checkUserName(user.UserName); err != nil {
+		h.fail(w, err)
+		return
+	}
+
+	employee, err := h.employees.FindById(id)
+	if err != nil {
+		h.fail(w, err)
+		return
+	}
+
+	result, err := h.saveUser(employee, user)
+	if err != nil {
+		h.fail(w, err)
+		return
+	}
+
+	h.respond(w, r, http.StatusOK, result, result.Meta)
+}
+
+func (h *Handler) deleteUser(w http.ResponseWriter, r *http.Request) {
+	current, ok := h.findUser(w, r)
+	if !ok || !checkVersion(w, r, current.Meta) {
+		return
+	}
+
+	id, _ := strconv.ParseInt(current.Id, 10, 64)
+	if err := h.employees.Remove(id); err != nil {
+		h.fail(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// listGroups участники всех ролей загружаются одним запросом, а не по запросу на роль
+func (h *Handler) listGroups(w http.ResponseWriter, r *http.Request) {
+	roles, err := h.roles.FindAll()
+	if err != nil {
+		h.fail(w, err)
+		return
+	}
+
+	ids := make([]int64, 0, len(roles))
+	for _, role := range roles {
+		ids = append(ids, role.Id)
+	}
+	assignments, err := h.roles.FindAssignments(nil, ids)
+	if err != nil {
+		h.fail(w, err)
+		return
+	}
+
+	members := make(map[int64][]int64)
+	for _, assignment := range assignments {
+		members[assignment.RoleId] = append(members[assignment.RoleId], assignment.EmployeeId)
+	}
+
+	var resources []any
+	for _, role := range roles {
+		resources = append(resources, h.toGroup(role, members[role.Id]))
+	}
+
+	h.list(w, r, resources)
+}
+
+func (h *Handler) getGroup(w http.ResponseWriter, r *http.Request) {
+	group, ok := h.findGroup(w, r)
+	if !ok {
+		return
+	}
+
+	h.respond(w, r, http.StatusOK, group, group.Meta)
+}
+
+func (h *Handler) createGroup(w http.ResponseWriter, r *http.Request) {
+	var group Group
+	if !decode(w, r, &group) {
+		return
+	}
+	if group.DisplayName == "" {
+		writeError(w, http.StatusBadRequest, "invalidValue", "displayName is required")
+		return
+	}
+
+	role, err := h.roles.Create(group.DisplayName)
+	if err != nil {
+		h.fail(w, err)
+		return
+	}
+
+	result, err := h.saveGroup(role, group)
+	if err != nil {
+		h.fail(w, err)
+		return
+	}
+
+	w.Header().Set("Location", result.Meta.Location)
+	h.respond(w, r, http.StatusCreated, result, result.Meta)
+}
+
+func (h *Handler) replaceGroup(w http.ResponseWriter, r *http.Request) {
+	current, ok := h.findGroup(w, r)
+	if !ok || !checkVersion(w, r, current.Meta) {
+		return
+	}
+
+	var group Group
+	if !decode(w, r, &group) {
+		return
+	}
+
+	h.updateGroup(w, r, current, group)
+}
+
+func (h *Handler) patchGroup(w http.ResponseWriter, r *http.Request) {
+	current, ok := h.findGroup(w, r)
+	if !ok || !checkVersion(w, r, current.Meta) {
+		return
+	}
+
+	var request PatchRequest
+	if !decode(w, r, &request) {
+		return
+	}
+
+	var group Group
+	if err := patch(current, request.Operations, &group); err != nil {
+		h.fail(w, err)
+		return
+	}
+
+	h.updateGroup(w, r, current, group)
+}
+
+func (h *Handler) updateGroup(w http.ResponseWriter, r *http.Request, current *Group, group Group) {
+	if group.DisplayName == "" {
+		writeError(w, http.StatusBadRequest, "invalidValue", "displayName is required")
+		return
+	}
+
+	id, _ := strconv.ParseInt(current.Id, 10, 64)
+	role, err := h.roles.FindById(id)
+	if err != nil {
+		h.fail(w, err)
+		return
+	}
+
+	result, err := h.saveGroup(role, group)
+	if err != nil {
+		h.fail(w, err)
+		return
+	}
+
+	h.respond(w, r, http.StatusOK, result, result.Meta)
+}
+
+func (h *Handler) deleteGroup(w http.ResponseWriter, r *http.Request) {
+	current, ok := h.findGroup(w, r)
+	if !ok || !checkVersion(w, r, current.Meta) {
+		return
+	}
+
+	id, _ := strconv.ParseInt(current.Id, 10, 64)
+	if err := h.roles.Remove(id); err != nil {
+		h.fail(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) findUser(w http.ResponseWriter, r *http.Request) (*User, bool) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "", fmt.Sprintf("user %s not found", r.PathValue("id")))
+		return nil, false
+	}
+
+	employee, err := h.employees.FindById(id)
+	if err != nil {
+		h.fail(w, err)
+		return nil, false
+	}
+
+	user, err := h.user(employee)
+	if err != nil {
+		h.fail(w, err)
+		return nil, false
+	}
+
+	return user, true
+}
+
+func (h *Handler) findGroup(w http.ResponseWriter, r *http.Request) (*Group, bool) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "", fmt.Sprintf("group %s not found", r.PathValue("id")))
+		return nil, false
+	}
+
+	role, err := h.roles.FindById(id)
+	if err != nil {
+		h.fail(w, err)
+		return nil, false
+	}
+
+	group, err := h.group(role)
+	if err != nil {
+		h.fail(w, err)
+		return nil, false
+	}
+
+	return group, true
+}
+
+// user собрать представление сотрудника вместе с его ролями
+func (h *Handler) user(found employee.Response) (*User, error) {
+	roles, err := h.roles.FindByEmployeeId(found.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	return h.toUser(found, roles), nil
+}
+
+func (h *Handler) toUser(found employee.Response, roles []role.Response) *User {
+	active := found.Status == employee.StatusActive
+	user := &User{
+		Schemas:     []string{SchemaUser},
+		Id:          strconv.FormatInt(found.Id, 10),
+		UserName:    found.UserName,
+		Name:        &Name{Formatted: found.Name},
+		DisplayName: found.Name,
+		Title:       found.Title,
+		UserType:    found.EmploymentType,
+		Active:      &active,
+	}
+	if user.UserName == "" {
+		user.UserName = found.Name
+	}
+	if found.Email != "" {
+		user.Emails = []Email{{Value: found.Email, Type: "work", Primary: true}}
+	}
+	if found.Location != "" {
+		user.Addresses = []Address{{Locality: found.Location, Type: "work"}}
+	}
+	for _, role := range roles {
+		id := strconv.FormatInt(role.Id, 10)
+		user.Groups = append(user.Groups, Ref{Value: id, Ref: h.baseURL + "/Groups/" + id, Display: role.Name})
+	}
+	if found.Department != "" || found.ManagerId != nil {
+		user.Schemas = append(user.Schemas, SchemaEnterpriseUser)
+		user.Enterprise = &EnterpriseUser{Department: found.Department}
+		if found.ManagerId != nil {
+			id := strconv.FormatInt(*found.ManagerId, 10)
+			user.Enterprise.Manager = &Ref{Value: id, Ref: h.baseURL + "/Users/" + id}
+		}
+	}
+
+	user.Meta = &Meta{
+		ResourceType: "User",
+		Created:      found.CreatedAt,
+		LastModified: found.UpdatedAt,
+		Location:     h.baseURL + "/Users/" + user.Id,
+	}
+	user.Meta.Version = version(user)
+
+	return user
+}
+
+// group собрать представление роли вместе с её участниками
+func (h *Handler) group(role role.Response) (*Group, error) {
+	employeeIds, err := h.roles.FindEmployeeIds(role.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	return h.toGroup(role, employeeIds), nil
+}
+
+func (h *Handler) toGroup(role role.Response, employeeIds []int64) *Group {
+	group := &Group{
+		Schemas:     []string{SchemaGroup},
+		Id:          strconv.FormatInt(role.Id, 10),
+		DisplayName: role.Name,
+	}
+	for _, employeeId := range employeeIds {
+		id := strconv.FormatInt(employeeId, 10)
+		group.Members = append(group.Members, Ref{Value: id, Ref: h.baseURL + "/Users/" + id, Type: "User"})
+	}
+
+	group.Meta = &Meta{
+		ResourceType: "Group",
+		Created:      role.CreatedAt,
+		LastModified: role.UpdatedAt,
+		Location:     h.baseURL + "/Groups/" + group.Id,
+	}
+	group.Meta.Version = version(group)
+
+	return group
+}
+
+// saveUser записать атрибуты пользователя SCIM в сотрудника
+func (h *Handler) saveUser(current employee.Response, user User) (*User, error) {
+	request := employee.UpdateRequest{
+		Name:           displayName(user),
+		UserName:       user.UserName,
+		Title:          user.Title,
+		EmploymentType: user.UserType,
+	}
+	if len(user.Emails) > 0 {
+		request.Email = user.Emails[0].Value
+		for _, email := range user.Emails {
+			if email.Primary {
+				request.Email = email.Value
+			}
+		}
+	}
+	if len(user.Addresses) > 0 {
+		request.Location = user.Addresses[0].Locality
+	}
+	if user.Enterprise != nil {
+		request.Department = user.Enterprise.Department
+		if user.Enterprise.Manager != nil && user.Enterprise.Manager.Value != "" {
+			managerId, err := strconv.ParseInt(user.Enterprise.Manager.Value, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("%w: manager %q is not a user id", errInvalidValue, user.Enterprise.Manager.Value)
+			}
+			request.ManagerId = &managerId
+		}
+	}
+
+	saved, err := h.employees.Update(current.Id, request)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" && pqErr.Constraint == "employees_user_name_key" {
+		return nil, ErrUniqueness
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if user.Active != nil && *user.Active != (saved.Status == employee.StatusActive) {
+		saved, err = h.employees.SetActive(current.Id, *user.Active)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return h.user(saved)
+}
+
+// saveGroup переименовать роль и привести её участников к составу группы
+func (h *Handler) saveGroup(current role.Response, group Group) (*Group, error) {
+	var err error
+	if group.DisplayName != current.Name {
+		current, err = h.roles.Rename(current.Id, group.DisplayName)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	existing, err := h.roles.FindEmployeeIds(current.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	wanted := make(map[int64]bool)
+	for _, member := range group.Members {
+		employeeId, err := strconv.ParseInt(member.Value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: member %q is not a user id", errInvalidValue, member.Value)
+		}
+		wanted[employeeId] = true
+	}
+
+	for _, employeeId := range existing {
+		if wanted[employeeId] {
+			delete(wanted, employeeId)
+			continue
+		}
+		if err := h.roles.Revoke(employeeId, current.Id); err != nil {
+			return nil, err
+		}
+	}
+	for employeeId := range wanted {
+		if err := h.roles.Assign(employeeId, current.Id); err != nil {
+			return nil, err
+		}
+	}
+
+	return h.group(current)
+}
+
+// checkUserName проверить, что userName задан. Занятость имени проверяет уникальный индекс
+// employees_user_name_key при сохранении
+func checkUserName(userName string) error {
+	if userName == "" {
+		return fmt.Errorf("%w: userName is required", errInvalidValue)
+	}
+
+	return nil
+}
+
+// list отфильтровать ресурсы, выбрать страницу и записать ListResponse
+func (h *Handler) list(w http.ResponseWriter, r *http.Request, resources []any) {
+	query := r.URL.Query()
+
+	var filter scimfilter.Filter
+	if expression := query.Get("filter"); expression != "" {
+		var err error
+		filter, err = scimfilter.Parse(expression)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalidFilter", err.Error())
+			return
+		}
+	}
+
+	var matched []any
+	for _, resource := range resources {
+		object, err := toMap(resource)
+		if err != nil {
+			h.fail(w, err)
+			return
+		}
+		if filter == nil || filter.Match(object) {
+			matched = append(matched, project(object, query.Get("attributes"), query.Get("excludedAttributes")))
+		}
+	}
+
+	startIndex := 1
+	if value, err := strconv.Atoi(query.Get("startIndex")); err == nil && value > 1 {
+		startIndex = value
+	}
+	count := MaxResults
+	if value, err := strconv.Atoi(query.Get("count")); err == nil {
+		count = min(max(value, 0), MaxResults)
+	}
+
+	page := []any{}
+	if startIndex <= len(matched) {
+		page = matched[startIndex-1 : min(startIndex-1+count, len(matched))]
+	}
+
+	writeJSON(w, http.StatusOK, listResponse(page, len(matched), startIndex))
+}
+
+// respond записать ресурс с заголовком ETag, либо 304, если версия клиента актуальна
+func (h *Handler) respond(w http.ResponseWriter, r *http.Request, status int, resource any, meta *Meta) {
+	w.Header().Set("ETag", meta.Version)
+	if status == http.StatusOK && r.Method == http.MethodGet && r.Header.Get("If-None-Match") == meta.Version {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	query := r.URL.Query()
+	if query.Has("attributes") || query.Has("excludedAttributes") {
+		object, err := toMap(resource)
+		if err != nil {
+			h.fail(w, err)
+			return
+		}
+		resource = project(object, query.Get("attributes"), query.Get("excludedAttributes"))
+	}
+
+	writeJSON(w, status, resource)
+}
+
+// fail преобразовать ошибку сервиса в ответ SCIM
+func (h *Handler) fail(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, database.ErrRecordNotFound):
+		writeError(w, http.StatusNotFound, "", err.Error())
+	case errors.Is(err, ErrUniqueness):
+		writeError(w, http.StatusConflict, "uniqueness", err.Error())
+	case errors.Is(err, scimfilter.ErrInvalidFilter):
+		writeError(w, http.StatusBadRequest, "invalidFilter", err.Error())
+	case errors.Is(err, ErrNoTarget):
+		writeError(w, http.StatusBadRequest, "noTarget", err.Error())
+	case errors.Is(err, errInvalidPath):
+		writeError(w, http.StatusBadRequest, "invalidPath", err.Error())
+	case errors.Is(err, errInvalidValue):
+		writeError(w, http.StatusBadRequest, "invalidValue", err.Error())
+	case errors.Is(err, sod.ErrViolation):
+		writeError(w, http.StatusConflict, "", err.Error())
+	default:
+		writeError(w, http.StatusInternalServerError, "", err.Error())
+	}
+}
+
+// checkVersion проверить заголовок If-Match
+func checkVersion(w http.ResponseWriter, r *http.Request, meta *Meta) bool {
+	expected := r.Header.Get("If-Match")
+	if expected == "" || expected == "*" || expected == meta.Version {
+		return true
+	}
+
+	writeError(w, http.StatusPreconditionFailed, "", "resource version does not match If-Match")
+	return false
+}
+
+// decode как httpapi.Decode, но отвечает ошибкой в формате SCIM
+func decode(w http.ResponseWriter, r *http.Request, target any) bool {
+	if err := json.NewDecoder(r.Body).Decode(target); err != nil {
+		writeError(w, http.StatusBadRequest, "invalidSyntax", err.Error())
+		return false
+	}
+
+	return true
+}
+
+func displayName(user User) string {
+	switch {
+	case user.DisplayName != "":
+		return user.DisplayName
+	case user.Name != nil && user.Name.Formatted != "":
+		return user.Name.Formatted
+	default:
+		return user.UserName
+	}
+}
+
+// version слабый ETag, вычисленный по содержимому ресурса без meta.version
+func version(resource any) string {
+	data, _ := json.Marshal(resource)
+	sum := sha1.Sum(data)
+
+	return `W/"` + hex.EncodeToString(sum[:8]) + `"`
+}
+
+func toMap(resource any) (map[string]any, error) {
+	data, err := json.Marshal(resource)
+	if err != nil {
+		return nil, err
+	}
+
+	var object map[string]any
+	err = json.Unmarshal(data, &object)
+
+	return object, err
+}
+
+// project оставить в ресурсе только запрошенные атрибуты верхнего уровня
+func project(object map[string]any, attributes string, excluded string) map[string]any {
+	if attributes != "" {
+		keep := map[string]bool{"id": true, "schemas": true, "meta": true}
+		for _, name := range strings.Split(attributes, ",") {
+			keep[strings.ToLower(strings.TrimSpace(name))] = true
+		}
+		for key := range object {
+			if !keep[strings.ToLower(key)] {
+				delete(object, key)
+			}
+		}
+	}
+	if excluded != "" {
+		for _, name := range strings.Split(excluded, ",") {
+			name = strings.TrimSpace(name)
+			for key := range object {
+				if strings.EqualFold(key, name) && key != "id" && key != "schemas" {
+					delete(object, key)
+				}
+			}
+		}
+	}
+
+	return object
+}
+
+func listResponse(resources []any, total int, startIndex int) ListResponse {
+	if resources == nil {
+		resources = []any{}
+	}
+
+	return ListResponse{
+		Schemas:      []string{SchemaListResponse},
+		TotalResults: total,
+		ItemsPerPage: len(resources),
+		StartIndex:   startIndex,
+		Resources:    resources,
+	}
+}
+
+// writeJSON ответ с типом SCIM вместо application/json
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", ContentType)
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func writeError(w http.ResponseWriter, status int, scimType string, detail string) {
+	writeJSON(w, status, ErrorResponse{
+		Schemas:  []string{SchemaError},
+		Status:   strconv.Itoa(status),
+		ScimType: scimType,
+		Detail:   detail,
+	})
+}
@@ -0,0 +1,291 @@
+package scim
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"idm/inner/scimfilter"
+	"strconv"
+	"strings"
+)
+
+var (
+	errInvalidPath  = errors.New("invalid path")
+	errInvalidValue = errors.New("invalid value")
+)
+
+// patchPath разобранный путь PATCH-операции: attribute[filter].subAttribute
+type patchPath struct {
+	extension string
+	attribute string
+	filter    scimfilter.Filter
+	sub       string
+}
+
+// patch применить операции PATCH (RFC 7644, раздел 3.5.2) к ресурсу и записать результат в target
+func patch(resource any, operations []PatchOperation, target any) error {
+	object, err := toMap(resource)
+	if err != nil {
+		return err
+	}
+
+	for _, operation := range operations {
+		var value any
+		if len(operation.Value) > 0 {
+			if err := json.Unmarshal(operation.Value, &value); err != nil {
+				return fmt.Errorf("%w: %v", errInvalidValue, err)
+			}
+		}
+
+		op := strings.ToLower(operation.Op)
+		if operation.Path == "" {
+			if op == "remove" {
+				return fmt.Errorf("%w: remove requires a path", ErrNoTarget)
+			}
+			values, ok := value.(map[string]any)
+			if !ok {
+				return fmt.Errorf("%w: operation without path requires an object value", errInvalidValue)
+			}
+			for key, item := range values {
+				path, err := parsePath(key)
+				if err != nil {
+					return err
+				}
+				if err := apply(object, op, path, item); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		path, err := parsePath(operation.Path)
+		if err != nil {
+			return err
+		}
+		if err := apply(object, op, path, value); err != nil {
+			return err
+		}
+	}
+
+	normalizeActive(object)
+
+	data, err := json.Marshal(object)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, target); err != nil {
+		return fmt.Errorf("%w: %v", errInvalidValue, err)
+	}
+
+	return nil
+}
+
+func parsePath(path string) (patchPath, error) {
+	var result patchPath
+
+	if strings.HasPrefix(strings.ToLower(path), "urn:") {
+		attributeStart := strings.LastIndex(strings.SplitN(path, "[", 2)[0], ":")
+		result.extension = path[:attributeStart]
+		path = path[attributeStart+1:]
+		if strings.EqualFold(result.extension, SchemaUser) || strings.EqualFold(result.extension, SchemaGroup) {
+			result.extension = ""
+		}
+		// путь вида urn:...:enterprise:2.0:User целиком указывает на расширение
+		if strings.EqualFold(result.extension+":"+path, SchemaEnterpriseUser) {
+			return patchPath{attribute: SchemaEnterpriseUser}, nil
+		}
+	}
+
+	if open := strings.Index(path, "["); open >= 0 {
+		closing := strings.LastIndex(path, "]")
+		if closing < open {
+			return result, fmt.Errorf("%w: unbalanced brackets in %q", errInvalidPath, path)
+		}
+		filter, err := scimfilter.Parse(path[open+1 : closing])
+		if err != nil {
+			return result, fmt.Errorf("%w: %v", errInvalidPath, err)
+		}
+		result.attribute = path[:open]
+		result.filter = filter
+		result.sub = strings.TrimPrefix(path[closing+1:], ".")
+	} else {
+		parts := strings.SplitN(path, ".", 2)
+		result.attribute = parts[0]
+		if len(parts) == 2 {
+			result.sub = parts[1]
+		}
+	}
+
+	if result.attribute == "" {
+		return result, fmt.Errorf("%w: empty attribute in %q", errInvalidPath, path)
+	}
+
+	return result, nil
+}
+
+func apply(object map[string]any, op string, path patchPath, value any) error {
+	container := object
+	if path.extension != "" {
+		key := keyOf(object, path.extension)
+		extension, ok := object[key].(map[string]any)
+		if !ok {
+			if op == "remove" {
+				return nil
+			}
+			extension = map[string]any{}
+			object[key] = extension
+		}
+		container = extension
+	}
+
+	key := keyOf(container, path.attribute)
+
+	if path.filter != nil {
+		return applyFiltered(container, key, op, path, value)
+	}
+
+	if path.sub != "" {
+		parent, ok := container[key].(map[string]any)
+		if !ok {
+			if op == "remove" {
+				return nil
+			}
+			parent = map[string]any{}
+			container[key] = parent
+		}
+		return applyValue(parent, keyOf(parent, path.sub), op, value)
+	}
+
+	return applyValue(container, key, op, value)
+}
+
+func applyValue(container map[string]any, key string, op string, value any) error {
+	switch op {
+	case "add":
+		existing, isList := container[key].([]any)
+		if items, ok := value.([]any); ok && isList {
+			container[key] = append(existing, items...)
+			return nil
+		}
+		if isList {
+			container[key] = append(existing, value)
+			return nil
+		}
+		if values, ok := value.(map[string]any); ok {
+			if current, ok := container[key].(map[string]any); ok {
+				for name, item := range values {
+					current[keyOf(current, name)] = item
+				}
+				return nil
+			}
+		}
+		container[key] = value
+	case "replace":
+		if values, ok := value.(map[string]any); ok {
+			if current, ok := container[key].(map[string]any); ok {
+				for name, item := range values {
+					current[keyOf(current, name)] = item
+				}
+				return nil
+			}
+		}
+		container[key] = value
+	case "remove":
+		// Некоторые клиенты (например Azure AD) передают удаляемых участников в value
+		if items, ok := value.([]any); ok {
+			if existing, ok := container[key].([]any); ok {
+				container[key] = without(existing, items)
+				return nil
+			}
+		}
+		delete(container, key)
+	default:
+		return fmt.Errorf("%w: unknown operation %q", errInvalidValue, op)
+	}
+
+	return nil
+}
+
+func applyFiltered(container map[string]any, key string, op string, path patchPath, value any) error {
+	items, _ := container[key].([]any)
+
+	matched := false
+	var kept []any
+	for _, item := range items {
+		element, ok := item.(map[string]any)
+		if !ok || !path.filter.Match(element) {
+			kept = append(kept, item)
+			continue
+		}
+		matched = true
+
+		switch {
+		case op == "remove" && path.sub == "":
+			continue
+		case path.sub != "":
+			if err := applyValue(element, keyOf(element, path.sub), op, value); err != nil {
+				return err
+			}
+		default:
+			values, ok := value.(map[string]any)
+			if !ok {
+				return fmt.Errorf("%w: filtered %s requires an object value", errInvalidValue, op)
+			}
+			for name, field := range values {
+				element[keyOf(element, name)] = field
+			}
+		}
+		kept = append(kept, element)
+	}
+
+	if !matched && op != "remove" {
+		return fmt.Errorf("%w: %s", ErrNoTarget, key)
+	}
+	container[key] = kept
+
+	return nil
+}
+
+// without удалить из списка элементы с теми же value, что и в removed
+func without(items []any, removed []any) []any {
+	values := make(map[string]bool)
+	for _, item := range removed {
+		if element, ok := item.(map[string]any); ok {
+			values[fmt.Sprint(scimfilter.Lookup(element, "value"))] = true
+		}
+	}
+
+	var kept []any
+	for _, item := range items {
+		if element, ok := item.(map[string]any); ok && values[fmt.Sprint(scimfilter.Lookup(element, "value"))] {
+			continue
+		}
+		kept = append(kept, item)
+	}
+
+	return kept
+}
+
+// keyOf найти существующий ключ без учёта регистра, иначе вернуть name
+func keyOf(object map[string]any, name string) string {
+	if _, ok := object[name]; ok {
+		return name
+	}
+	for key := range object {
+		if strings.EqualFold(key, name) {
+			return key
+		}
+	}
+
+	return name
+}
+
+// normalizeActive привести active к bool: некоторые клиенты передают "True"/"False" строкой
+func normalizeActive(object map[string]any) {
+	key := keyOf(object, "active")
+	if text, ok := object[key].(string); ok {
+		if active, err := strconv.ParseBool(text); err == nil {
+			object[key] = active
+		}
+	}
+}
@@ -0,0 +1,273 @@
+package scim
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	SchemaUser            = "urn:ietf:params:scim:schemas:core:2.0:User"
+	SchemaGroup           = "urn:ietf:params:scim:schemas:core:2.0:Group"
+	SchemaEnterpriseUser  = "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User"
+	SchemaListResponse    = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	SchemaPatchOp         = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	SchemaBulkRequest     = "urn:ietf:params:scim:api:messages:2.0:BulkRequest"
+	SchemaBulkResponse    = "urn:ietf:params:scim:api:messages:2.0:BulkResponse"
+	SchemaError           = "urn:ietf:params:scim:api:messages:2.0:Error"
+	SchemaServiceProvider = "urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"
+	SchemaResourceType    = "urn:ietf:params:scim:schemas:core:2.0:ResourceType"
+	SchemaSchema          = "urn:ietf:params:scim:schemas:core:2.0:Schema"
+
+	ContentType = "application/scim+json"
+
+	// MaxResults максимальный размер страницы в ответах со списками
+	MaxResults = 200
+	// MaxBulkOperations максимальное число операций в одном bulk-запросе
+	MaxBulkOperations = 1000
+	// MaxBulkPayloadSize максимальный размер тела bulk-запроса в байтах
+	MaxBulkPayloadSize = 1 << 20
+)
+
+type Meta struct {
+	ResourceType string    `json:"resourceType"`
+	Created      time.Time `json:"created"`
+	LastModified time.Time `json:"lastModified"`
+	Location     string    `json:"location"`
+	Version      string    `json:"version,omitempty"`
+}
+
+type Name struct {
+	Formatted string `json:"formatted,omitempty"`
+}
+
+type Email struct {
+	Value   string `json:"value"`
+	Type    string `json:"type,omitempty"`
+	Primary bool   `json:"primary,omitempty"`
+}
+
+type Address struct {
+	Locality string `json:"locality,omitempty"`
+	Type     string `json:"type,omitempty"`
+}
+
+// Ref ссылка на другой ресурс: участник группы, группа пользователя или руководитель
+type Ref struct {
+	Value   string `json:"value"`
+	Ref     string `json:"$ref,omitempty"`
+	Display string `json:"display,omitempty"`
+	Type    string `json:"type,omitempty"`
+}
+
+type EnterpriseUser struct {
+	Department string `json:"department,omitempty"`
+	Manager    *Ref   `json:"manager,omitempty"`
+}
+
+// User сотрудник в представлении SCIM
+type User struct {
+	Schemas     []string        `json:"schemas"`
+	Id          string          `json:"id,omitempty"`
+	UserName    string          `json:"userName"`
+	Name        *Name           `json:"name,omitempty"`
+	DisplayName string          `json:"displayName,omitempty"`
+	Title       string          `json:"title,omitempty"`
+	UserType    string          `json:"userType,omitempty"`
+	Active      *bool           `json:"active,omitempty"`
+	Emails      []Email         `json:"emails,omitempty"`
+	Addresses   []Address       `json:"addresses,omitempty"`
+	Groups      []Ref           `json:"groups,omitempty"`
+	Enterprise  *EnterpriseUser `json:"urn:ietf:params:scim:schemas:extension:enterprise:2.0:User,omitempty"`
+	Meta        *Meta           `json:"meta,omitempty"`
+}
+
+// Group роль в представлении SCIM
+type Group struct {
+	Schemas     []string `json:"schemas"`
+	Id          string   `json:"id,omitempty"`
+	DisplayName string   `json:"displayName"`
+	Members     []Ref    `json:"members,omitempty"`
+	Meta        *Meta    `json:"meta,omitempty"`
+}
+
+type ListResponse struct {
+	Schemas      []string `json:"schemas"`
+	TotalResults int      `json:"totalResults"`
+	ItemsPerPage int      `json:"itemsPerPage"`
+	StartIndex   int      `json:"startIndex"`
+	Resources    []any    `json:"Resources"`
+}
+
+type ErrorResponse struct {
+	Schemas  []string `json:"schemas"`
+	Status   string   `json:"status"`
+	ScimType string   `json:"scimType,omitempty"`
+	Detail   string   `json:"detail,omitempty"`
+}
+
+type PatchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+type PatchRequest struct {
+	Schemas    []string         `json:"schemas"`
+	Operations []PatchOperation `json:"Operations"`
+}
+
+type BulkOperation struct {
+	Method   string          `json:"method"`
+	BulkId   string          `json:"bulkId,omitempty"`
+	Version  string          `json:"version,omitempty"`
+	Path     string          `json:"path"`
+	Data     json.RawMessage `json:"data,omitempty"`
+	Location string          `json:"location,omitempty"`
+	Status   string          `json:"status,omitempty"`
+	Response json.RawMessage `json:"response,omitempty"`
+}
+
+type BulkRequest struct {
+	Schemas      []string        `json:"schemas"`
+	FailOnErrors int             `json:"failOnErrors,omitempty"`
+	Operations   []BulkOperation `json:"Operations"`
+}
+
+type BulkResponse struct {
+	Schemas    []string        `json:"schemas"`
+	Operations []BulkOperation `json:"Operations"`
+}
+
+func serviceProviderConfig(baseURL string) map[string]any {
+	return map[string]any{
+		"schemas":          []string{SchemaServiceProvider},
+		"documentationUri": "https://datatracker.ietf.org/doc/html/rfc7644",
+		"patch":            map[string]any{"supported": true},
+		"bulk": map[string]any{
+			"supported":      true,
+			"maxOperations":  MaxBulkOperations,
+			"maxPayloadSize": MaxBulkPayloadSize,
+		},
+		"filter":         map[string]any{"supported": true, "maxResults": MaxResults},
+		"changePassword": map[string]any{"supported": false},
+		"sort":           map[string]any{"supported": false},
+		"etag":           map[string]any{"supported": true},
+		"authenticationSchemes": []map[string]any{{
+			"type":        "oauthbearertoken",
+			"name":        "OAuth Bearer Token",
+			"description": "Authentication scheme using the OAuth Bearer Token Standard",
+		}},
+		"meta": map[string]any{
+			"resourceType": "ServiceProviderConfig",
+			"location":     baseURL + "/ServiceProviderConfig",
+		},
+	}
+}
+
+func resourceTypes(baseURL string) []any {
+	return []any{
+		map[string]any{
+			"schemas":     []string{SchemaResourceType},
+			"id":          "User",
+			"name":        "User",
+			"endpoint":    "/Users",
+			"description": "Employee",
+			"schema":      SchemaUser,
+			"schemaExtensions": []map[string]any{
+				{"schema": SchemaEnterpriseUser, "required": false},
+			},
+			"meta": map[string]any{"resourceType": "ResourceType", "location": baseURL + "/ResourceTypes/User"},
+		},
+		map[string]any{
+			"schemas":     []string{SchemaResourceType},
+			"id":          "Group",
+			"name":        "Group",
+			"endpoint":    "/Groups",
+			"description": "Role",
+			"schema":      SchemaGroup,
+			"meta":        map[string]any{"resourceType": "ResourceType", "location": baseURL + "/ResourceTypes/Group"},
+		},
+	}
+}
+
+func attribute(name string, kind string, required bool, mutability string, uniqueness string) map[string]any {
+	return map[string]any{
+		"name":        name,
+		"type":        kind,
+		"multiValued": false,
+		"required":    required,
+		"caseExact":   false,
+		"mutability":  mutability,
+		"returned":    "default",
+		"uniqueness":  uniqueness,
+	}
+}
+
+func multiValued(name string, mutability string, subAttributes ...map[string]any) map[string]any {
+	return map[string]any{
+		"name":          name,
+		"type":          "complex",
+		"multiValued":   true,
+		"required":      false,
+		"mutability":    mutability,
+		"returned":      "default",
+		"subAttributes": subAttributes,
+	}
+}
+
+func schemas(baseURL string) []any {
+	return []any{
+		map[string]any{
+			"schemas":     []string{SchemaSchema},
+			"id":          SchemaUser,
+			"name":        "User",
+			"description": "Employee account",
+			"attributes": []map[string]any{
+				attribute("userName", "string", true, "readWrite", "server"),
+				{
+					"name": "name", "type": "complex", "multiValued": false, "required": false,
+					"mutability": "readWrite", "returned": "default",
+					"subAttributes": []map[string]any{attribute("formatted", "string", false, "readWrite", "none")},
+				},
+				attribute("displayName", "string", false, "readWrite", "none"),
+				attribute("title", "string", false, "readWrite", "none"),
+				attribute("userType", "string", false, "readWrite", "none"),
+				attribute("active", "boolean", false, "readWrite", "none"),
+				multiValued("emails", "readWrite", attribute("value", "string", false, "readWrite", "none")),
+				multiValued("addresses", "readWrite", attribute("locality", "string", false, "readWrite", "none")),
+				multiValued("groups", "readOnly",
+					attribute("value", "string", false, "readOnly", "none"),
+					attribute("display", "string", false, "readOnly", "none")),
+			},
+			"meta": map[string]any{"resourceType": "Schema", "location": baseURL + "/Schemas/" + SchemaUser},
+		},
+		map[string]any{
+			"schemas":     []string{SchemaSchema},
+			"id":          SchemaEnterpriseUser,
+			"name":        "EnterpriseUser",
+			"description": "Enterprise User",
+			"attributes": []map[string]any{
+				attribute("department", "string", false, "readWrite", "none"),
+				{
+					"name": "manager", "type": "complex", "multiValued": false, "required": false,
+					"mutability": "readWrite", "returned": "default",
+					"subAttributes": []map[string]any{attribute("value", "string", false, "readWrite", "none")},
+				},
+			},
+			"meta": map[string]any{"resourceType": "Schema", "location": baseURL + "/Schemas/" + SchemaEnterpriseUser},
+		},
+		map[string]any{
+			"schemas":     []string{SchemaSchema},
+			"id":          SchemaGroup,
+			"name":        "Group",
+			"description": "Role",
+			"attributes": []map[string]any{
+				attribute("displayName", "string", true, "readWrite", "none"),
+				multiValued("members", "readWrite",
+					attribute("value", "string", false, "immutable", "none"),
+					attribute("display", "string", false, "readOnly", "none")),
+			},
+			"meta": map[string]any{"resourceType": "Schema", "location": baseURL + "/Schemas/" + SchemaGroup},
+		},
+	}
+}
@@ -0,0 +1,608 @@
+package scim
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/lib/pq"
+	assertpackage "github.com/stretchr/testify/assert"
+	"idm/inner/database"
+	"idm/inner/employee"
+	"idm/inner/role"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// StubEmployeeRepo хранит сотрудников в памяти
+type StubEmployeeRepo struct {
+	employees []*employee.Employee
+}
+
+func (s *StubEmployeeRepo) FindById(id int64) (*employee.Employee, error) {
+	for _, found := range s.employees {
+		if found.Id == id {
+			copied := *found
+			return &copied, nil
+		}
+	}
+	return nil, database.ErrRecordNotFound
+}
+
+func (s *StubEmployeeRepo) FindByUserName(userName string) (*employee.Employee, error) {
+	for _, found := range s.employees {
+		if strings.EqualFold(found.UserName, userName) {
+			copied := *found
+			return &copied, nil
+		}
+	}
+	return nil, database.ErrRecordNotFound
+}
+
+func (s *StubEmployeeRepo) FindAll() ([]*employee.Employee, error) {
+	var result []*employee.Employee
+	for _, found := range s.employees {
+		copied := *found
+		result = append(result, &copied)
+	}
+	return result, nil
+}
+
+func (s *StubEmployeeRepo) FindByIds(ids []int64) ([]*employee.Employee, error) {
+	var result []*employee.Employee
+	for _, id := range ids {
+		if found, err := s.FindById(id); err == nil {
+			result = append(result, found)
+		}
+	}
+	return result, nil
+}
+
+func (s *StubEmployeeRepo) Create(e *employee.Employee) error {
+	e.Id = int64(len(s.employees) + 1)
+	e.Status = employee.StatusActive
+	e.CreatedAt = time.Now()
+	e.UpdatedAt = e.CreatedAt
+	copied := *e
+	s.employees = append(s.employees, &copied)
+	return nil
+}
+
+// Update как уникальный индекс employees_user_name_key не даёт занять чужой userName
+func (s *StubEmployeeRepo) Update(e *employee.Employee) error {
+	for _, found := range s.employees {
+		if found.Id != e.Id && e.UserName != "" && strings.EqualFold(found.UserName, e.UserName) {
+			return &pq.Error{Code: "23505", Constraint: "employees_user_name_key"}
+		}
+	}
+	for i, found := range s.employees {
+		if found.Id == e.Id {
+			e.Status = found.Status
+			e.CreatedAt = found.CreatedAt
+			e.UpdatedAt = time.Now()
+			copied := *e
+			s.employees[i] = &copied
+			return nil
+		}
+	}
+	return database.ErrRecordNotFound
+}
+
+func (s *StubEmployeeRepo) SetStatus(id int64, status string) error {
+	for _, found := range s.employees {
+		if found.Id == id {
+			found.Status = status
+			return nil
+		}
+	}
+	return database.ErrRecordNotFound
+}
+
+func (s *StubEmployeeRepo) FindPage(afterId int64, limit int) ([]*employee.Employee, error) {
+	return nil, nil
+}
+
+func (s *StubEmployeeRepo) Count() (int64, error) {
+	return int64(len(s.employees)), nil
+}
+
+func (s *StubEmployeeRepo) SetDepartment(ids []int64, departmentId *int64) ([]*employee.Employee, error) {
+	return nil, nil
+}
+
+func (s *StubEmployeeRepo) Remove(id int64) error {
+	s.employees = slices.DeleteFunc(s.employees, func(e *employee.Employee) bool { return e.Id == id })
+	return nil
+}
+
+func (s *StubEmployeeRepo) RemoveByIds(ids []int64) error {
+	for _, id := range ids {
+		_ = s.Remove(id)
+	}
+	return nil
+}
+
+// StubRoleRepo хранит роли и назначения в памяти; lookups считает запросы по одному сотруднику или роли
+type StubRoleRepo struct {
+	roles       []*role.Role
+	assignments map[int64][]int64
+	lookups     int
+}
+
+func (s *StubRoleRepo) FindAll() ([]*role.Role, error) {
+	return s.roles, nil
+}
+
+func (s *StubRoleRepo) FindPage(afterId int64, limit int) ([]*role.Role, error) {
+	return nil, nil
+}
+
+func (s *StubRoleRepo) Count() (int64, error) {
+	return int64(len(s.roles)), nil
+}
+
+func (s *StubRoleRepo) FindById(id int64) (*role.Role, error) {
+	for _, found := range s.roles {
+		if found.Id == id {
+			copied := *found
+			return &copied, nil
+		}
+	}
+	return nil, database.ErrRecordNotFound
+}
+
+func (s *StubRoleRepo) FindByIds(ids []int64) ([]*role.Role, error) {
+	var result []*role.Role
+	for _, id := range ids {
+		if found, err := s.FindById(id); err == nil {
+			result = append(result, found)
+		}
+	}
+	return result, nil
+}
+
+func (s *StubRoleRepo) Create(r *role.Role) error {
+	r.Id = int64(len(s.roles) + 1)
+	r.CreatedAt = time.Now()
+	r.UpdatedAt = r.CreatedAt
+	copied := *r
+	s.roles = append(s.roles, &copied)
+	return nil
+}
+
+func (s *StubRoleRepo) Update(r *role.Role) error {
+	for i, found := range s.roles {
+		if found.Id == r.Id {
+			copied := *r
+			s.roles[i] = &copied
+			return nil
+		}
+	}
+	return database.ErrRecordNotFound
+}
+
+func (s *StubRoleRepo) Remove(id int64) error {
+	s.roles = slices.DeleteFunc(s.roles, func(r *role.Role) bool { return r.Id == id })
+	delete(s.assignments, id)
+	return nil
+}
+
+func (s *StubRoleRepo) RemoveByIds(ids []int64) error {
+	for _, id := range ids {
+		_ = s.Remove(id)
+	}
+	return nil
+}
+
+func (s *StubRoleRepo) FindByEmployeeId(employeeId int64) ([]*role.Role, error) {
+	s.lookups++
+	var result []*role.Role
+	for _, found := range s.roles {
+		if slices.Contains(s.assignments[found.Id], employeeId) {
+			result = append(result, found)
+		}
+	}
+	return result, nil
+}
+
+func (s *StubRoleRepo) FindEmployeeIds(roleId int64) ([]int64, error) {
+	s.lookups++
+	return slices.Clone(s.assignments[roleId]), nil
+}
+
+func (s *StubRoleRepo) FindAssignments(employeeIds []int64, roleIds []int64) ([]role.Assignment, error) {
+	var result []role.Assignment
+	for roleId, members := range s.assignments {
+		for _, employeeId := range members {
+			if slices.Contains(employeeIds, employeeId) || slices.Contains(roleIds, roleId) {
+				result = append(result, role.Assignment{EmployeeId: employeeId, RoleId: roleId})
+			}
+		}
+	}
+	return result, nil
+}
+
+func (s *StubRoleRepo) Assign(employeeId int64, roleId int64) error {
+	if !slices.Contains(s.assignments[roleId], employeeId) {
+		s.assignments[roleId] = append(s.assignments[roleId], employeeId)
+	}
+	return nil
+}
+
+func (s *StubRoleRepo) Revoke(employeeId int64, roleId int64) error {
+	s.assignments[roleId] = slices.DeleteFunc(s.assignments[roleId], func(id int64) bool { return id == employeeId })
+	return nil
+}
+
+type client struct {
+	t      *testing.T
+	server *httptest.Server
+	roles  *StubRoleRepo
+}
+
+func newClient(t *testing.T) *client {
+	roleRepo := &StubRoleRepo{assignments: make(map[int64][]int64)}
+	employees := employee.NewService(&StubEmployeeRepo{})
+	roles := role.NewService(roleRepo)
+
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	mux.Handle("/scim/v2/", http.StripPrefix("/scim/v2", NewHandler(employees, roles, server.URL+"/scim/v2")))
+
+	return &client{t: t, server: server, roles: roleRepo}
+}
+
+func (c *client) do(method string, path string, body any, headers map[string]string) (*http.Response, map[string]any) {
+	var reader bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&reader).Encode(body); err != nil {
+			c.t.Fatal(err)
+		}
+	}
+
+	request, err := http.NewRequest(method, c.server.URL+"/scim/v2"+path, &reader)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	request.Header.Set("Content-Type", ContentType)
+	for name, value := range headers {
+		request.Header.Set(name, value)
+	}
+
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	defer response.Body.Close()
+
+	var result map[string]any
+	_ = json.NewDecoder(response.Body).Decode(&result)
+
+	return response, result
+}
+
+func (c *client) createUser(userName string, displayName string) map[string]any {
+	response, body := c.do(http.MethodPost, "/Users", map[string]any{
+		"schemas":     []string{SchemaUser},
+		"userName":    userName,
+		"displayName": displayName,
+		"emails":      []map[string]any{{"value": userName + "@example.com", "primary": true}},
+	}, nil)
+	if response.StatusCode != http.StatusCreated {
+		c.t.Fatalf("unexpected status %d: %v", response.StatusCode, body)
+	}
+
+	return body
+}
+
+func TestScimHandler(t *testing.T) {
+	assert := assertpackage.New(t)
+
+	t.Run("should serve discovery endpoints", func(t *testing.T) {
+		c := newClient(t)
+
+		response, body := c.do(http.MethodGet, "/ServiceProviderConfig", nil, nil)
+		assert.Equal(http.StatusOK, response.StatusCode)
+		assert.Equal(ContentType, response.Header.Get("Content-Type"))
+		assert.Equal(true, body["patch"].(map[string]any)["supported"])
+		assert.Equal(true, body["bulk"].(map[string]any)["supported"])
+
+		_, body = c.do(http.MethodGet, "/Schemas", nil, nil)
+		assert.Equal(float64(3), body["totalResults"])
+
+		_, body = c.do(http.MethodGet, "/ResourceTypes", nil, nil)
+		assert.Equal(float64(2), body["totalResults"])
+	})
+
+	t.Run("should create and get a user", func(t *testing.T) {
+		c := newClient(t)
+
+		response, body := c.do(http.MethodPost, "/Users", map[string]any{
+			"schemas":            []string{SchemaUser, SchemaEnterpriseUser},
+			"userName":           "jdoe",
+			"name":               map[string]any{"formatted": "John Doe"},
+			"title":              "Engineer",
+			"emails":             []map[string]any{{"value": "jdoe@example.com", "primary": true}},
+			SchemaEnterpriseUser: map[string]any{"department": "IT"},
+		}, nil)
+		assert.Equal(http.StatusCreated, response.StatusCode)
+		assert.Equal(c.server.URL+"/scim/v2/Users/1", response.Header.Get("Location"))
+		assert.NotEmpty(response.Header.Get("ETag"))
+		assert.Equal("1", body["id"])
+		assert.Equal("John Doe", body["displayName"])
+		assert.Equal(true, body["active"])
+		assert.Equal("IT", body[SchemaEnterpriseUser].(map[string]any)["department"])
+
+		response, body = c.do(http.MethodGet, "/Users/1", nil, nil)
+		assert.Equal(http.StatusOK, response.StatusCode)
+		assert.Equal("jdoe", body["userName"])
+		assert.Equal("Engineer", body["title"])
+		assert.Equal(response.Header.Get("ETag"), body["meta"].(map[string]any)["version"])
+	})
+
+	t.Run("should reject a duplicate or missing userName", func(t *testing.T) {
+		c := newClient(t)
+		c.createUser("jdoe", "John Doe")
+
+		response, body := c.do(http.MethodPost, "/Users", map[string]any{"userName": "JDOE"}, nil)
+		assert.Equal(http.StatusConflict, response.StatusCode)
+		assert.Equal("uniqueness", body["scimType"])
+		assert.Equal([]any{SchemaError}, body["schemas"])
+
+		response, body = c.do(http.MethodPost, "/Users", map[string]any{"displayName": "Nobody"}, nil)
+		assert.Equal(http.StatusBadRequest, response.StatusCode)
+		assert.Equal("invalidValue", body["scimType"])
+
+		c.createUser("jsmith", "Jane Smith")
+		response, body = c.do(http.MethodPatch, "/Users/2", map[string]any{
+			"schemas":    []string{SchemaPatchOp},
+			"Operations": []map[string]any{{"op": "replace", "path": "userName", "value": "jdoe"}},
+		}, nil)
+		assert.Equal(http.StatusConflict, response.StatusCode)
+		assert.Equal("uniqueness", body["scimType"])
+
+		_, body = c.do(http.MethodGet, "/Users", nil, nil)
+		assert.Equal(float64(2), body["totalResults"])
+	})
+
+	t.Run("should return not found for unknown resources", func(t *testing.T) {
+		c := newClient(t)
+
+		response, body := c.do(http.MethodGet, "/Users/42", nil, nil)
+		assert.Equal(http.StatusNotFound, response.StatusCode)
+		assert.Equal("404", body["status"])
+
+		response, _ = c.do(http.MethodGet, "/Groups/abc", nil, nil)
+		assert.Equal(http.StatusNotFound, response.StatusCode)
+	})
+
+	t.Run("should filter users", func(t *testing.T) {
+		c := newClient(t)
+		c.createUser("jdoe", "John Doe")
+		c.createUser("jsmith", "Jane Smith")
+		c.createUser("bob", "Bob Brown")
+
+		cases := map[string]float64{
+			`userName eq "JDOE"`:                             1,
+			`displayName co "smith"`:                         1,
+			`userName sw "j"`:                                2,
+			`userName sw "j" and displayName co "doe"`:       1,
+			`userName eq "bob" or userName eq "jsmith"`:      2,
+			`not (userName sw "j")`:                          1,
+			`emails.value ew "@example.com"`:                 3,
+			`emails[value sw "bob"]`:                         1,
+			`meta.resourceType eq "User" and active eq true`: 3,
+			`title pr`: 0,
+		}
+		for filter, expected := range cases {
+			response, body := c.do(http.MethodGet, "/Users?filter="+url.QueryEscape(filter), nil, nil)
+			assert.Equal(http.StatusOK, response.StatusCode, filter)
+			assert.Equal(expected, body["totalResults"], filter)
+		}
+
+		response, body := c.do(http.MethodGet, "/Users?filter="+url.QueryEscape(`userName zz "x"`), nil, nil)
+		assert.Equal(http.StatusBadRequest, response.StatusCode)
+		assert.Equal("invalidFilter", body["scimType"])
+	})
+
+	t.Run("should paginate users", func(t *testing.T) {
+		c := newClient(t)
+		c.createUser("a", "A")
+		c.createUser("b", "B")
+		c.createUser("c", "C")
+
+		_, body := c.do(http.MethodGet, "/Users?startIndex=2&count=1", nil, nil)
+		assert.Equal(float64(3), body["totalResults"])
+		assert.Equal(float64(1), body["itemsPerPage"])
+		assert.Equal(float64(2), body["startIndex"])
+		assert.Equal("b", body["Resources"].([]any)[0].(map[string]any)["userName"])
+
+		_, body = c.do(http.MethodGet, "/Users?startIndex=10", nil, nil)
+		assert.Equal(float64(3), body["totalResults"])
+		assert.Empty(body["Resources"])
+
+		_, body = c.do(http.MethodGet, "/Users?count=0", nil, nil)
+		assert.Equal(float64(0), body["itemsPerPage"])
+	})
+
+	t.Run("should honour ETags", func(t *testing.T) {
+		c := newClient(t)
+		c.createUser("jdoe", "John Doe")
+
+		response, _ := c.do(http.MethodGet, "/Users/1", nil, nil)
+		etag := response.Header.Get("ETag")
+
+		response, _ = c.do(http.MethodGet, "/Users/1", nil, map[string]string{"If-None-Match": etag})
+		assert.Equal(http.StatusNotModified, response.StatusCode)
+
+		response, _ = c.do(http.MethodPut, "/Users/1", map[string]any{"userName": "jdoe", "title": "CTO"},
+			map[string]string{"If-Match": etag})
+		assert.Equal(http.StatusOK, response.StatusCode)
+		assert.NotEqual(etag, response.Header.Get("ETag"))
+
+		response, _ = c.do(http.MethodPut, "/Users/1", map[string]any{"userName": "jdoe"},
+			map[string]string{"If-Match": etag})
+		assert.Equal(http.StatusPreconditionFailed, response.StatusCode)
+
+		response, _ = c.do(http.MethodDelete, "/Users/1", nil, map[string]string{"If-Match": etag})
+		assert.Equal(http.StatusPreconditionFailed, response.StatusCode)
+	})
+
+	t.Run("should patch a user", func(t *testing.T) {
+		c := newClient(t)
+		c.createUser("jdoe", "John Doe")
+
+		response, body := c.do(http.MethodPatch, "/Users/1", map[string]any{
+			"schemas": []string{SchemaPatchOp},
+			"Operations": []map[string]any{
+				{"op": "Replace", "path": "active", "value": "False"},
+				{"op": "replace", "path": "title", "value": "Manager"},
+				{"op": "add", "path": SchemaEnterpriseUser + ":department", "value": "Sales"},
+				{"op": "replace", "path": `emails[primary eq true].value`, "value": "john@example.com"},
+				{"op": "add", "value": map[string]any{"name.formatted": "Johnny Doe", "displayName": "Johnny Doe"}},
+			},
+		}, nil)
+		assert.Equal(http.StatusOK, response.StatusCode, body)
+		assert.Equal(false, body["active"])
+		assert.Equal("Manager", body["title"])
+		assert.Equal("Johnny Doe", body["displayName"])
+		assert.Equal("Sales", body[SchemaEnterpriseUser].(map[string]any)["department"])
+		assert.Equal("john@example.com", body["emails"].([]any)[0].(map[string]any)["value"])
+
+		response, body = c.do(http.MethodPatch, "/Users/1", map[string]any{
+			"schemas":    []string{SchemaPatchOp},
+			"Operations": []map[string]any{{"op": "remove", "path": "title"}},
+		}, nil)
+		assert.Equal(http.StatusOK, response.StatusCode)
+		assert.Nil(body["title"])
+
+		response, body = c.do(http.MethodPatch, "/Users/1", map[string]any{
+			"schemas":    []string{SchemaPatchOp},
+			"Operations": []map[string]any{{"op": "remove"}},
+		}, nil)
+		assert.Equal(http.StatusBadRequest, response.StatusCode)
+		assert.Equal("noTarget", body["scimType"])
+	})
+
+	t.Run("should delete a user", func(t *testing.T) {
+		c := newClient(t)
+		c.createUser("jdoe", "John Doe")
+
+		response, _ := c.do(http.MethodDelete, "/Users/1", nil, nil)
+		assert.Equal(http.StatusNoContent, response.StatusCode)
+
+		response, _ = c.do(http.MethodGet, "/Users/1", nil, nil)
+		assert.Equal(http.StatusNotFound, response.StatusCode)
+	})
+
+	t.Run("should manage group membership", func(t *testing.T) {
+		c := newClient(t)
+		c.createUser("jdoe", "John Doe")
+		c.createUser("jsmith", "Jane Smith")
+
+		response, body := c.do(http.MethodPost, "/Groups", map[string]any{
+			"schemas":     []string{SchemaGroup},
+			"displayName": "Admins",
+			"members":     []map[string]any{{"value": "1"}},
+		}, nil)
+		assert.Equal(http.StatusCreated, response.StatusCode)
+		assert.Len(body["members"], 1)
+
+		response, body = c.do(http.MethodPatch, "/Groups/1", map[string]any{
+			"schemas": []string{SchemaPatchOp},
+			"Operations": []map[string]any{
+				{"op": "add", "path": "members", "value": []map[string]any{{"value": "2"}}},
+				{"op": "remove", "path": `members[value eq "1"]`},
+				{"op": "replace", "path": "displayName", "value": "Administrators"},
+			},
+		}, nil)
+		assert.Equal(http.StatusOK, response.StatusCode, body)
+		assert.Equal("Administrators", body["displayName"])
+		assert.Equal("2", body["members"].([]any)[0].(map[string]any)["value"])
+		assert.Len(body["members"], 1)
+
+		_, body = c.do(http.MethodGet, "/Users/2", nil, nil)
+		assert.Equal("Administrators", body["groups"].([]any)[0].(map[string]any)["display"])
+
+		_, body = c.do(http.MethodGet, "/Groups?filter="+url.QueryEscape(`displayName eq "administrators"`)+
+			"&excludedAttributes=members", nil, nil)
+		assert.Equal(float64(1), body["totalResults"])
+		assert.Nil(body["Resources"].([]any)[0].(map[string]any)["members"])
+
+		response, _ = c.do(http.MethodPut, "/Groups/1", map[string]any{"displayName": "Administrators"}, nil)
+		assert.Equal(http.StatusOK, response.StatusCode)
+		_, body = c.do(http.MethodGet, "/Users/2", nil, nil)
+		assert.Nil(body["groups"])
+
+		response, _ = c.do(http.MethodDelete, "/Groups/1", nil, nil)
+		assert.Equal(http.StatusNoContent, response.StatusCode)
+	})
+
+	t.Run("should load roles of listed resources in one query", func(t *testing.T) {
+		c := newClient(t)
+		c.createUser("jdoe", "John Doe")
+		c.createUser("jsmith", "Jane Smith")
+		c.do(http.MethodPost, "/Groups", map[string]any{"displayName": "Admins", "members": []map[string]any{{"value": "2"}}}, nil)
+		c.do(http.MethodPost, "/Groups", map[string]any{"displayName": "Auditors"}, nil)
+		lookups := c.roles.lookups
+
+		_, body := c.do(http.MethodGet, "/Users", nil, nil)
+		users := body["Resources"].([]any)
+		assert.Nil(users[0].(map[string]any)["groups"])
+		assert.Equal("Admins", users[1].(map[string]any)["groups"].([]any)[0].(map[string]any)["display"])
+
+		_, body = c.do(http.MethodGet, "/Groups", nil, nil)
+		groups := body["Resources"].([]any)
+		assert.Len(groups[0].(map[string]any)["members"], 1)
+		assert.Nil(groups[1].(map[string]any)["members"])
+
+		assert.Equal(lookups, c.roles.lookups)
+	})
+
+	t.Run("should process bulk requests", func(t *testing.T) {
+		c := newClient(t)
+
+		response, body := c.do(http.MethodPost, "/Bulk", map[string]any{
+			"schemas": []string{SchemaBulkRequest},
+			"Operations": []map[string]any{
+				{"method": "POST", "path": "/Users", "bulkId": "u1", "data": map[string]any{"userName": "jdoe"}},
+				{"method": "POST", "path": "/Groups", "bulkId": "g1", "data": map[string]any{
+					"displayName": "Admins", "members": []map[string]any{{"value": "bulkId:u1"}},
+				}},
+				{"method": "PATCH", "path": "/Users/bulkId:u1", "data": map[string]any{
+					"schemas":    []string{SchemaPatchOp},
+					"Operations": []map[string]any{{"op": "replace", "path": "title", "value": "CTO"}},
+				}},
+				{"method": "DELETE", "path": "/Groups/bulkId:missing"},
+			},
+		}, nil)
+		assert.Equal(http.StatusOK, response.StatusCode)
+		operations := body["Operations"].([]any)
+		assert.Len(operations, 4)
+		assert.Equal("201", operations[0].(map[string]any)["status"])
+		assert.Equal(c.server.URL+"/scim/v2/Users/1", operations[0].(map[string]any)["location"])
+		assert.Equal("201", operations[1].(map[string]any)["status"])
+		assert.Equal("200", operations[2].(map[string]any)["status"])
+		assert.Equal("409", operations[3].(map[string]any)["status"])
+
+		_, body = c.do(http.MethodGet, "/Users/1", nil, nil)
+		assert.Equal("CTO", body["title"])
+		assert.Equal("Admins", body["groups"].([]any)[0].(map[string]any)["display"])
+
+		_, body = c.do(http.MethodPost, "/Bulk", map[string]any{
+			"schemas":      []string{SchemaBulkRequest},
+			"failOnErrors": 1,
+			"Operations": []map[string]any{
+				{"method": "POST", "path": "/Users", "bulkId": "dup", "data": map[string]any{"userName": "jdoe"}},
+				{"method": "POST", "path": "/Users", "bulkId": "next", "data": map[string]any{"userName": "next"}},
+			},
+		}, nil)
+		operations = body["Operations"].([]any)
+		assert.Len(operations, 1)
+		assert.Equal("409", operations[0].(map[string]any)["status"])
+	})
+}
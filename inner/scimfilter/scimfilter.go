@@ -0,0 +1,437 @@
+package scimfilter
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+var ErrInvalidFilter = errors.New("invalid filter")
+
+// Filter выражение фильтра SCIM (RFC 7644, раздел 3.4.2.2), вычисляемое над ресурсом,
+// представленным как результат json.Unmarshal в map[string]any
+type Filter interface {
+	Match(resource map[string]any) bool
+}
+
+type logicalFilter struct {
+	op    string
+	left  Filter
+	right Filter
+}
+
+func (f *logicalFilter) Match(resource map[string]any) bool {
+	if f.op == "and" {
+		return f.left.Match(resource) && f.right.Match(resource)
+	}
+
+	return f.left.Match(resource) || f.right.Match(resource)
+}
+
+type notFilter struct {
+	inner Filter
+}
+
+func (f *notFilter) Match(resource map[string]any) bool {
+	return !f.inner.Match(resource)
+}
+
+type valuePathFilter struct {
+	path  string
+	inner Filter
+}
+
+func (f *valuePathFilter) Match(resource map[string]any) bool {
+	for _, value := range resolve(resource, f.path) {
+		if element, ok := value.(map[string]any); ok && f.inner.Match(element) {
+			return true
+		}
+	}
+
+	return false
+}
+
+type compareFilter struct {
+	path  string
+	op    string
+	value any
+}
+
+func (f *compareFilter) Match(resource map[string]any) bool {
+	for _, actual := range resolve(resource, f.path) {
+		if compare(actual, f.op, f.value) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Parse разобрать фильтр. Поддерживаются операторы eq, ne, co, sw, ew, pr, gt, ge, lt, le,
+// логические and, or, not и скобки.
+func Parse(input string) (Filter, error) {
+	tokens, err := tokenize(input)
+	if err != nil {
+		return nil, err
+	}
+
+	parser := &filterParser{tokens: tokens}
+	filter, err := parser.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if parser.pos != len(parser.tokens) {
+		return nil, fmt.Errorf("%w: unexpected %q", ErrInvalidFilter, parser.tokens[parser.pos].text)
+	}
+
+	return filter, nil
+}
+
+type token struct {
+	text   string
+	quoted bool
+}
+
+func tokenize(input string) ([]token, error) {
+	var tokens []token
+	runes := []rune(input)
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(' || r == ')':
+			tokens = append(tokens, token{text: string(r)})
+			i++
+		case r == '"':
+			var builder strings.Builder
+			i++
+			for ; i < len(runes) && runes[i] != '"'; i++ {
+				if runes[i] == '\\' && i+1 < len(runes) {
+					i++
+				}
+				builder.WriteRune(runes[i])
+			}
+			if i == len(runes) {
+				return nil, fmt.Errorf("%w: unterminated string", ErrInvalidFilter)
+			}
+			tokens = append(tokens, token{text: builder.String(), quoted: true})
+			i++
+		default:
+			start := i
+			for i < len(runes) && !unicode.IsSpace(runes[i]) && runes[i] != '(' && runes[i] != ')' {
+				// фильтр по значению emails[type eq "work"] целиком входит в токен пути
+				if runes[i] == '[' {
+					for i < len(runes) && runes[i] != ']' {
+						if runes[i] == '"' {
+							for i++; i < len(runes) && runes[i] != '"'; i++ {
+							}
+						}
+						i++
+					}
+					if i == len(runes) {
+						return nil, fmt.Errorf("%w: unterminated [", ErrInvalidFilter)
+					}
+				}
+				i++
+			}
+			tokens = append(tokens, token{text: string(runes[start:i])})
+		}
+	}
+
+	return tokens, nil
+}
+
+type filterParser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *filterParser) peek() (token, bool) {
+	if p.pos >= len(p.tokens) {
+		return token{}, false
+	}
+
+	return p.tokens[p.pos], true
+}
+
+func (p *filterParser) keyword(word string) bool {
+	next, ok := p.peek()
+	if ok && !next.quoted && strings.EqualFold(next.text, word) {
+		p.pos++
+		return true
+	}
+
+	return false
+}
+
+func (p *filterParser) parseOr() (Filter, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("or") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicalFilter{op: "or", left: left, right: right}
+	}
+
+	return left, nil
+}
+
+func (p *filterParser) parseAnd() (Filter, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("and") {
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicalFilter{op: "and", left: left, right: right}
+	}
+
+	return left, nil
+}
+
+func (p *filterParser) parseUnary() (Filter, error) {
+	if p.keyword("not") {
+		inner, err := p.parseGroup()
+		if err != nil {
+			return nil, err
+		}
+
+		return &notFilter{inner: inner}, nil
+	}
+
+	if next, ok := p.peek(); ok && next.text == "(" && !next.quoted {
+		return p.parseGroup()
+	}
+
+	return p.parseComparison()
+}
+
+func (p *filterParser) parseGroup() (Filter, error) {
+	if next, ok := p.peek(); !ok || next.text != "(" {
+		return nil, fmt.Errorf("%w: expected (", ErrInvalidFilter)
+	}
+	p.pos++
+
+	inner, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	if next, ok := p.peek(); !ok || next.text != ")" {
+		return nil, fmt.Errorf("%w: expected )", ErrInvalidFilter)
+	}
+	p.pos++
+
+	return inner, nil
+}
+
+func (p *filterParser) parseComparison() (Filter, error) {
+	path, ok := p.peek()
+	if !ok || path.quoted {
+		return nil, fmt.Errorf("%w: expected attribute path", ErrInvalidFilter)
+	}
+	p.pos++
+
+	if open := strings.Index(path.text, "["); open >= 0 {
+		if !strings.HasSuffix(path.text, "]") {
+			return nil, fmt.Errorf("%w: unexpected text after ] in %q", ErrInvalidFilter, path.text)
+		}
+		inner, err := Parse(path.text[open+1 : len(path.text)-1])
+		if err != nil {
+			return nil, err
+		}
+
+		return &valuePathFilter{path: path.text[:open], inner: inner}, nil
+	}
+
+	op, ok := p.peek()
+	if !ok || op.quoted {
+		return nil, fmt.Errorf("%w: expected operator after %q", ErrInvalidFilter, path.text)
+	}
+	p.pos++
+
+	operator := strings.ToLower(op.text)
+	switch operator {
+	case "pr":
+		return &compareFilter{path: path.text, op: operator}, nil
+	case "eq", "ne", "co", "sw", "ew", "gt", "ge", "lt", "le":
+	default:
+		return nil, fmt.Errorf("%w: unknown operator %q", ErrInvalidFilter, op.text)
+	}
+
+	value, ok := p.peek()
+	if !ok {
+		return nil, fmt.Errorf("%w: expected value after %q", ErrInvalidFilter, op.text)
+	}
+	p.pos++
+
+	return &compareFilter{path: path.text, op: operator, value: literal(value)}, nil
+}
+
+func literal(value token) any {
+	if value.quoted {
+		return value.text
+	}
+
+	switch strings.ToLower(value.text) {
+	case "true":
+		return true
+	case "false":
+		return false
+	case "null":
+		return nil
+	}
+
+	if number, err := strconv.ParseFloat(value.text, 64); err == nil {
+		return number
+	}
+
+	return value.text
+}
+
+// resolve значения атрибута по пути вида userName, name.formatted, emails.value
+// или urn:...:enterprise:2.0:User:department. Имена атрибутов сравниваются без учёта регистра.
+func resolve(resource map[string]any, path string) []any {
+	current := []any{resource}
+
+	if strings.HasPrefix(strings.ToLower(path), "urn:") {
+		separator := strings.LastIndex(path, ":")
+		extension := Lookup(resource, path[:separator])
+		if extension == nil {
+			return nil
+		}
+		current = []any{extension}
+		path = path[separator+1:]
+	}
+
+	for _, part := range strings.Split(path, ".") {
+		var next []any
+		for _, value := range current {
+			for _, item := range flatten(value) {
+				object, ok := item.(map[string]any)
+				if !ok {
+					continue
+				}
+				if found := Lookup(object, part); found != nil {
+					next = append(next, found)
+				}
+			}
+		}
+		current = next
+	}
+
+	var values []any
+	for _, value := range current {
+		values = append(values, flatten(value)...)
+	}
+
+	return values
+}
+
+// Lookup значение атрибута объекта; имя сравнивается без учёта регистра
+func Lookup(object map[string]any, name string) any {
+	if value, ok := object[name]; ok {
+		return value
+	}
+	for key, value := range object {
+		if strings.EqualFold(key, name) {
+			return value
+		}
+	}
+
+	return nil
+}
+
+func flatten(value any) []any {
+	if list, ok := value.([]any); ok {
+		return list
+	}
+
+	return []any{value}
+}
+
+func compare(actual any, op string, expected any) bool {
+	if op == "pr" {
+		text, isText := actual.(string)
+		return actual != nil && (!isText || text != "")
+	}
+
+	switch want := expected.(type) {
+	case string:
+		got, ok := actual.(string)
+		if !ok {
+			return false
+		}
+		got, want = strings.ToLower(got), strings.ToLower(want)
+		switch op {
+		case "eq":
+			return got == want
+		case "ne":
+			return got != want
+		case "co":
+			return strings.Contains(got, want)
+		case "sw":
+			return strings.HasPrefix(got, want)
+		case "ew":
+			return strings.HasSuffix(got, want)
+		case "gt":
+			return got > want
+		case "ge":
+			return got >= want
+		case "lt":
+			return got < want
+		case "le":
+			return got <= want
+		}
+	case float64:
+		got, ok := actual.(float64)
+		if !ok {
+			return false
+		}
+		switch op {
+		case "eq":
+			return got == want
+		case "ne":
+			return got != want
+		case "gt":
+			return got > want
+		case "ge":
+			return got >= want
+		case "lt":
+			return got < want
+		case "le":
+			return got <= want
+		}
+	case bool:
+		got, ok := actual.(bool)
+		if !ok {
+			return false
+		}
+		switch op {
+		case "eq":
+			return got == want
+		case "ne":
+			return got != want
+		}
+	case nil:
+		switch op {
+		case "eq":
+			return actual == nil
+		case "ne":
+			return actual != nil
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,24 @@
+package scimfilter
+
+import (
+	assertpackage "github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	assert := assertpackage.New(t)
+
+	t.Run("should reject malformed filters", func(t *testing.T) {
+		for _, filter := range []string{`userName eq`, `(userName eq "a"`, `userName eq "a" and`, `"a" eq userName`} {
+			_, err := Parse(filter)
+			assert.ErrorIs(err, ErrInvalidFilter, filter)
+		}
+	})
+
+	t.Run("should give and precedence over or", func(t *testing.T) {
+		filter, err := Parse(`a eq 1 or b eq 1 and c eq 1`)
+		assert.Nil(err)
+		assert.True(filter.Match(map[string]any{"a": float64(1)}))
+		assert.False(filter.Match(map[string]any{"b": float64(1)}))
+	})
+}
@@ -0,0 +1,90 @@
+package serviceaccount
+
+import "time"
+
+type Response struct {
+	Id          int64      `json:"id"`
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	OwnerId     *int64     `json:"owner_id,omitempty"`
+	Disabled    bool       `json:"disabled"`
+	DisabledAt  *time.Time `json:"disabled_at,omitempty"`
+	RoleIds     []int64    `json:"role_ids"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+func (a *ServiceAccount) ToResponse(roleIds []int64) Response {
+	if roleIds == nil {
+		roleIds = []int64{}
+	}
+
+	return Response{
+		Id:          a.Id,
+		Name:        a.Name,
+		Description: a.Description,
+		OwnerId:     a.OwnerId,
+		Disabled:    a.DisabledAt != nil,
+		DisabledAt:  a.DisabledAt,
+		RoleIds:     roleIds,
+		CreatedAt:   a.CreatedAt,
+		UpdatedAt:   a.UpdatedAt,
+	}
+}
+
+// CreateRequest данные новой служебной учётной записи
+type CreateRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	OwnerId     *int64 `json:"owner_id"`
+}
+
+// KeyRequest параметры нового ключа. Без ExpiresAt ключ действует бессрочно.
+type KeyRequest struct {
+	Name      string     `json:"name"`
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// KeyResponse сведения о ключе без секрета
+type KeyResponse struct {
+	Id               int64      `json:"id"`
+	ServiceAccountId int64      `json:"service_account_id"`
+	Prefix           string     `json:"prefix"`
+	Name             string     `json:"name"`
+	Scopes           []string   `json:"scopes"`
+	ExpiresAt        *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt       *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt        *time.Time `json:"revoked_at,omitempty"`
+	RotatedTo        *int64     `json:"rotated_to,omitempty"`
+	CreatedAt        time.Time  `json:"created_at"`
+}
+
+func (k *Key) ToResponse() KeyResponse {
+	return KeyResponse{
+		Id:               k.Id,
+		ServiceAccountId: k.ServiceAccountId,
+		Prefix:           KeyPrefix + k.Lookup,
+		Name:             k.Name,
+		Scopes:           k.Scopes,
+		ExpiresAt:        k.ExpiresAt,
+		LastUsedAt:       k.LastUsedAt,
+		RevokedAt:        k.RevokedAt,
+		RotatedTo:        k.RotatedTo,
+		CreatedAt:        k.CreatedAt,
+	}
+}
+
+// IssuedKey новый ключ. Секрет показывается только один раз и нигде не сохраняется.
+type IssuedKey struct {
+	KeyResponse
+	Secret string `json:"secret"`
+}
+
+// Principal служебная учётная запись, предъявившая ключ
+type Principal struct {
+	ServiceAccountId int64
+	Name             string
+	KeyId            int64
+	Scopes           []string
+}
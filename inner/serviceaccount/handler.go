@@ -0,0 +1,227 @@
+package serviceaccount
+
+import (
+	"context"
+	"errors"
+	"idm/inner/httpapi"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// statuses коды ответа для ошибок сервиса
+var statuses = httpapi.Statuses{
+	{Err: ErrInvalidScope, Code: http.StatusBadRequest},
+	{Err: ErrInvalidName, Code: http.StatusBadRequest},
+	{Err: ErrNameTaken, Code: http.StatusConflict},
+	{Err: ErrKeyInactive, Code: http.StatusConflict},
+}
+
+type principalKey struct{}
+
+// FromContext служебная учётная запись, аутентифицированная RequireScope
+func FromContext(ctx context.Context) (Principal, bool) {
+	principal, ok := ctx.Value(principalKey{}).(Principal)
+	return principal, ok
+}
+
+// RequireScope пропускать только запросы с действующим ключом, у которого есть право scope.
+// Ключ передаётся в заголовке Authorization: Bearer.
+func (s *Service) RequireScope(scope string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		secret, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !found {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="idm"`)
+			httpapi.WriteError(w, http.StatusUnauthorized, "api key is required")
+			return
+		}
+
+		principal, err := s.Authenticate(strings.TrimSpace(secret))
+		if err != nil {
+			if errors.Is(err, ErrInvalidKey) {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="idm", error="invalid_token"`)
+				httpapi.WriteError(w, http.StatusUnauthorized, err.Error())
+				return
+			}
+			httpapi.WriteError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+
+		if !Allows(principal.Scopes, scope) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="idm", error="insufficient_scope", scope="`+scope+`"`)
+			httpapi.WriteError(w, http.StatusForbidden, "api key lacks scope "+scope)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), principalKey{}, principal)))
+	})
+}
+
+// Handler управление служебными учётными записями и их ключами
+type Handler struct {
+	service *Service
+	mux     *http.ServeMux
+}
+
+func NewHandler(service *Service) *Handler {
+	h := &Handler{service: service, mux: http.NewServeMux()}
+
+	h.mux.HandleFunc("GET /{$}", h.list)
+	h.mux.HandleFunc("POST /{$}", h.create)
+	h.mux.HandleFunc("GET /{id}", h.get)
+	h.mux.HandleFunc("DELETE /{id}", h.remove)
+	h.mux.HandleFunc("POST /{id}/disable", h.disable)
+	h.mux.HandleFunc("POST /{id}/enable", h.enable)
+	h.mux.HandleFunc("PUT /{id}/roles/{roleId}", h.assignRole)
+	h.mux.HandleFunc("DELETE /{id}/roles/{roleId}", h.revokeRole)
+	h.mux.HandleFunc("GET /{id}/keys", h.keys)
+	h.mux.HandleFunc("POST /{id}/keys", h.issueKey)
+	h.mux.HandleFunc("POST /{id}/keys/{keyId}/rotate", h.rotateKey)
+	h.mux.HandleFunc("DELETE /{id}/keys/{keyId}", h.revokeKey)
+
+	return h
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+func (h *Handler) list(w http.ResponseWriter, r *http.Request) {
+	accounts, err := h.service.FindAll()
+	httpapi.Write(w, http.StatusOK, accounts, err, statuses)
+}
+
+func (h *Handler) create(w http.ResponseWriter, r *http.Request) {
+	var request CreateRequest
+	if !httpapi.Decode(w, r, &request) {
+		return
+	}
+
+	account, err := h.service.Create(request)
+	httpapi.Write(w, http.StatusCreated, account, err, statuses)
+}
+
+func (h *Handler) get(w http.ResponseWriter, r *http.Request) {
+	id, ok := httpapi.PathId(w, r, "id")
+	if !ok {
+		return
+	}
+
+	account, err := h.service.FindById(id)
+	httpapi.Write(w, http.StatusOK, account, err, statuses)
+}
+
+func (h *Handler) remove(w http.ResponseWriter, r *http.Request) {
+	id, ok := httpapi.PathId(w, r, "id")
+	if !ok {
+		return
+	}
+
+	httpapi.Write(w, http.StatusNoContent, nil, h.service.Remove(id), statuses)
+}
+
+func (h *Handler) disable(w http.ResponseWriter, r *http.Request) {
+	h.setDisabled(w, r, true)
+}
+
+func (h *Handler) enable(w http.ResponseWriter, r *http.Request) {
+	h.setDisabled(w, r, false)
+}
+
+func (h *Handler) setDisabled(w http.ResponseWriter, r *http.Request, disabled bool) {
+	id, ok := httpapi.PathId(w, r, "id")
+	if !ok {
+		return
+	}
+
+	account, err := h.service.SetDisabled(id, disabled)
+	httpapi.Write(w, http.StatusOK, account, err, statuses)
+}
+
+func (h *Handler) assignRole(w http.ResponseWriter, r *http.Request) {
+	id, ok := httpapi.PathId(w, r, "id")
+	if !ok {
+		return
+	}
+	roleId, ok := httpapi.PathId(w, r, "roleId")
+	if !ok {
+		return
+	}
+
+	httpapi.Write(w, http.StatusNoContent, nil, h.service.AssignRole(id, roleId), statuses)
+}
+
+func (h *Handler) revokeRole(w http.ResponseWriter, r *http.Request) {
+	id, ok := httpapi.PathId(w, r, "id")
+	if !ok {
+		return
+	}
+	roleId, ok := httpapi.PathId(w, r, "roleId")
+	if !ok {
+		return
+	}
+
+	httpapi.Write(w, http.StatusNoContent, nil, h.service.RevokeRole(id, roleId), statuses)
+}
+
+func (h *Handler) keys(w http.ResponseWriter, r *http.Request) {
+	id, ok := httpapi.PathId(w, r, "id")
+	if !ok {
+		return
+	}
+
+	keys, err := h.service.FindKeys(id)
+	httpapi.Write(w, http.StatusOK, keys, err, statuses)
+}
+
+func (h *Handler) issueKey(w http.ResponseWriter, r *http.Request) {
+	id, ok := httpapi.PathId(w, r, "id")
+	if !ok {
+		return
+	}
+	var request KeyRequest
+	if !httpapi.Decode(w, r, &request) {
+		return
+	}
+
+	key, err := h.service.IssueKey(id, request)
+	httpapi.Write(w, http.StatusCreated, key, err, statuses)
+}
+
+// rotateKey период перекрытия задаётся параметром overlap в формате time.ParseDuration
+func (h *Handler) rotateKey(w http.ResponseWriter, r *http.Request) {
+	id, ok := httpapi.PathId(w, r, "id")
+	if !ok {
+		return
+	}
+	keyId, ok := httpapi.PathId(w, r, "keyId")
+	if !ok {
+		return
+	}
+
+	overlap := DefaultRotationOverlap
+	if value := r.URL.Query().Get("overlap"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed < 0 {
+			httpapi.WriteError(w, http.StatusBadRequest, "invalid overlap")
+			return
+		}
+		overlap = parsed
+	}
+
+	key, err := h.service.RotateKey(id, keyId, overlap)
+	httpapi.Write(w, http.StatusCreated, key, err, statuses)
+}
+
+func (h *Handler) revokeKey(w http.ResponseWriter, r *http.Request) {
+	id, ok := httpapi.PathId(w, r, "id")
+	if !ok {
+		return
+	}
+	keyId, ok := httpapi.PathId(w, r, "keyId")
+	if !ok {
+		return
+	}
+
+	httpapi.Write(w, http.StatusNoContent, nil, h.service.RevokeKey(id, keyId), statuses)
+}
@@ -0,0 +1,70 @@
+package serviceaccount
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"fmt"
+	"hash/crc32"
+	"strings"
+)
+
+// KeyPrefix начало каждого ключа. По нему и контрольной сумме сканеры секретов
+// находят ключи, случайно попавшие в код или логи.
+const KeyPrefix = "idm_"
+
+const (
+	lookupLength = 10
+	secretLength = 32
+)
+
+var keyEncoding = base32.NewEncoding("abcdefghijklmnopqrstuvwxyz234567").WithPadding(base32.NoPadding)
+
+// newKey ключ вида idm_<lookup>_<secret><crc32>; возвращает сам ключ, открытую часть и хеш секрета
+func newKey() (string, string, string, error) {
+	raw := make([]byte, lookupLength+secretLength)
+	if _, err := rand.Read(raw); err != nil {
+		return "", "", "", fmt.Errorf("error generating api key: %w", err)
+	}
+
+	lookup := keyEncoding.EncodeToString(raw[:lookupLength])
+	secret := keyEncoding.EncodeToString(raw[lookupLength:])
+	body := KeyPrefix + lookup + "_" + secret
+
+	return body + checksum(body), lookup, hashSecret(secret), nil
+}
+
+// parseKey разобрать ключ; ok ложно, если формат или контрольная сумма не сходятся
+func parseKey(key string) (lookup string, secret string, ok bool) {
+	rest, found := strings.CutPrefix(key, KeyPrefix)
+	if !found || len(key) <= 6 {
+		return "", "", false
+	}
+
+	body, sum := key[:len(key)-6], key[len(key)-6:]
+	if checksum(body) != sum {
+		return "", "", false
+	}
+
+	lookup, secret, found = strings.Cut(strings.TrimSuffix(rest, sum), "_")
+	if !found || lookup == "" || secret == "" {
+		return "", "", false
+	}
+
+	return lookup, secret, true
+}
+
+func checksum(body string) string {
+	var sum [4]byte
+	value := crc32.ChecksumIEEE([]byte(body))
+	sum[0], sum[1], sum[2], sum[3] = byte(value>>24), byte(value>>16), byte(value>>8), byte(value)
+
+	return keyEncoding.EncodeToString(sum[:])[:6]
+}
+
+// hashSecret секрет случайный и длинный, поэтому медленный хеш не нужен
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
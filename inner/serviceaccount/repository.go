@@ -0,0 +1,288 @@
+package serviceaccount
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"idm/inner/database"
+	"time"
+)
+
+// ServiceAccount учётная запись программы, обращающейся к API. Не является сотрудником,
+// но может обладать ролями.
+type ServiceAccount struct {
+	Id          int64      `db:"id"`
+	Name        string     `db:"name"`
+	Description string     `db:"description"`
+	OwnerId     *int64     `db:"owner_id"`
+	DisabledAt  *time.Time `db:"disabled_at"`
+	CreatedAt   time.Time  `db:"created_at"`
+	UpdatedAt   time.Time  `db:"updated_at"`
+}
+
+// Key API-ключ. Хранится только SHA-256 секретной части, Lookup – открытая часть для поиска.
+type Key struct {
+	Id               int64          `db:"id"`
+	ServiceAccountId int64          `db:"service_account_id"`
+	Lookup           string         `db:"lookup"`
+	Hash             string         `db:"hash"`
+	Name             string         `db:"name"`
+	Scopes           pq.StringArray `db:"scopes"`
+	ExpiresAt        *time.Time     `db:"expires_at"`
+	LastUsedAt       *time.Time     `db:"last_used_at"`
+	RevokedAt        *time.Time     `db:"revoked_at"`
+	RotatedTo        *int64         `db:"rotated_to"`
+	CreatedAt        time.Time      `db:"created_at"`
+}
+
+type Repository struct {
+	db *sqlx.DB
+}
+
+func NewRepository(db *sqlx.DB) *Repository {
+	return &Repository{db: db}
+}
+
+func (r *Repository) FindById(id int64) (*ServiceAccount, error) {
+	var account ServiceAccount
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := r.db.GetContext(ctx, &account, "SELECT * FROM service_accounts WHERE id = $1", id)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, database.ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &account, err
+}
+
+func (r *Repository) FindByName(name string) (*ServiceAccount, error) {
+	var account ServiceAccount
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := r.db.GetContext(ctx, &account, "SELECT * FROM service_accounts WHERE name = $1", name)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, database.ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &account, err
+}
+
+func (r *Repository) FindAll() ([]*ServiceAccount, error) {
+	var accounts []*ServiceAccount
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := r.db.SelectContext(ctx, &accounts, "SELECT * FROM service_accounts ORDER BY id")
+
+	return accounts, err
+}
+
+func (r *Repository) Create(account *ServiceAccount) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return r.db.QueryRowContext(ctx,
+		`INSERT INTO service_accounts (name, description, owner_id) VALUES ($1, $2, $3)
+		RETURNING id, created_at, updated_at`,
+		account.Name, account.Description, account.OwnerId,
+	).Scan(&account.Id, &account.CreatedAt, &account.UpdatedAt)
+}
+
+// SetDisabled отключить или включить учётную запись; ключи отключённой записи не принимаются
+func (r *Repository) SetDisabled(id int64, disabledAt *time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := r.db.ExecContext(ctx,
+		"UPDATE service_accounts SET disabled_at = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2",
+		disabledAt, id)
+	if err != nil {
+		return err
+	}
+
+	return affected(result)
+}
+
+func (r *Repository) Remove(id int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := r.db.ExecContext(ctx, "DELETE FROM service_accounts WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+
+	return affected(result)
+}
+
+func (r *Repository) FindRoleIds(id int64) ([]int64, error) {
+	var ids []int64
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := r.db.SelectContext(ctx, &ids,
+		"SELECT role_id FROM service_account_roles WHERE service_account_id = $1 ORDER BY role_id", id)
+
+	return ids, err
+}
+
+func (r *Repository) AssignRole(id int64, roleId int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO service_account_roles (service_account_id, role_id) VALUES ($1, $2)
+		ON CONFLICT (service_account_id, role_id) DO NOTHING`,
+		id, roleId)
+
+	return err
+}
+
+func (r *Repository) RevokeRole(id int64, roleId int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := r.db.ExecContext(ctx,
+		"DELETE FROM service_account_roles WHERE service_account_id = $1 AND role_id = $2", id, roleId)
+	if err != nil {
+		return err
+	}
+
+	return affected(result)
+}
+
+func (r *Repository) FindKeys(id int64) ([]*Key, error) {
+	var keys []*Key
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := r.db.SelectContext(ctx, &keys,
+		"SELECT * FROM api_keys WHERE service_account_id = $1 ORDER BY id", id)
+
+	return keys, err
+}
+
+func (r *Repository) FindKey(id int64) (*Key, error) {
+	return r.findKey("SELECT * FROM api_keys WHERE id = $1", id)
+}
+
+func (r *Repository) FindKeyByLookup(lookup string) (*Key, error) {
+	return r.findKey("SELECT * FROM api_keys WHERE lookup = $1", lookup)
+}
+
+func (r *Repository) CreateKey(key *Key) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return insertKey(ctx, r.db, key)
+}
+
+// RotateKey выпустить ключ на замену; прежний продолжает действовать до oldExpiresAt
+func (r *Repository) RotateKey(oldId int64, oldExpiresAt time.Time, key *Key) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err = insertKey(ctx, tx, key); err != nil {
+		return err
+	}
+
+	result, err := tx.ExecContext(ctx,
+		`UPDATE api_keys SET rotated_to = $1, expires_at = LEAST(COALESCE(expires_at, $2), $2)
+		WHERE id = $3 AND revoked_at IS NULL AND rotated_to IS NULL`,
+		key.Id, oldExpiresAt, oldId)
+	if err != nil {
+		return err
+	}
+	if err = affected(result); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *Repository) RevokeKey(id int64, revokedAt time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := r.db.ExecContext(ctx,
+		"UPDATE api_keys SET revoked_at = $1 WHERE id = $2 AND revoked_at IS NULL", revokedAt, id)
+	if err != nil {
+		return err
+	}
+
+	return affected(result)
+}
+
+func (r *Repository) TouchKey(id int64, usedAt time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := r.db.ExecContext(ctx, "UPDATE api_keys SET last_used_at = $1 WHERE id = $2", usedAt, id)
+
+	return err
+}
+
+func (r *Repository) findKey(query string, arg any) (*Key, error) {
+	var key Key
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := r.db.GetContext(ctx, &key, query, arg)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, database.ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &key, err
+}
+
+func insertKey(ctx context.Context, q sqlx.QueryerContext, key *Key) error {
+	return q.QueryRowxContext(ctx,
+		`INSERT INTO api_keys (service_account_id, lookup, hash, name, scopes, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at`,
+		key.ServiceAccountId, key.Lookup, key.Hash, key.Name, key.Scopes, key.ExpiresAt,
+	).Scan(&key.Id, &key.CreatedAt)
+}
+
+func affected(result sql.Result) error {
+	count, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return database.ErrRecordNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,368 @@
+package serviceaccount
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"idm/inner/database"
+	"idm/inner/role"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Права, которые можно выдать ключу
+const (
+	ScopeSCIM            = "scim"
+	ScopeEmployeesRead   = "employees:read"
+	ScopeEmployeesWrite  = "employees:write"
+	ScopeRolesRead       = "roles:read"
+	ScopeRolesWrite      = "roles:write"
+	ScopeServiceAccounts = "service-accounts"
+	ScopeWebhooks        = "webhooks"
+	ScopeProvisioning    = "provisioning"
+)
+
+var Scopes = []string{
+	ScopeSCIM, ScopeEmployeesRead, ScopeEmployeesWrite, ScopeRolesRead, ScopeRolesWrite, ScopeServiceAccounts, ScopeWebhooks,
+	ScopeProvisioning,
+}
+
+const (
+	// DefaultRotationOverlap сколько прежний ключ действует после ротации, пока клиенты переходят на новый
+	DefaultRotationOverlap = 24 * time.Hour
+	// lastUsedPrecision время последнего использования обновляется не чаще, чтобы не писать в базу на каждый запрос
+	lastUsedPrecision = time.Minute
+)
+
+var (
+	ErrInvalidKey   = errors.New("invalid api key")
+	ErrInvalidScope = errors.New("unknown scope")
+	ErrNameTaken    = errors.New("service account name is already taken")
+	ErrInvalidName  = errors.New("service account name is required")
+	ErrKeyInactive  = errors.New("api key is revoked or already rotated")
+)
+
+type Repo interface {
+	FindById(id int64) (*ServiceAccount, error)
+	FindByName(name string) (*ServiceAccount, error)
+	FindAll() ([]*ServiceAccount, error)
+	Create(account *ServiceAccount) error
+	SetDisabled(id int64, disabledAt *time.Time) error
+	Remove(id int64) error
+	FindRoleIds(id int64) ([]int64, error)
+	AssignRole(id int64, roleId int64) error
+	RevokeRole(id int64, roleId int64) error
+	FindKeys(id int64) ([]*Key, error)
+	FindKey(id int64) (*Key, error)
+	FindKeyByLookup(lookup string) (*Key, error)
+	CreateKey(key *Key) error
+	RotateKey(oldId int64, oldExpiresAt time.Time, key *Key) error
+	RevokeKey(id int64, revokedAt time.Time) error
+	TouchKey(id int64, usedAt time.Time) error
+}
+
+// Roles проверка существования назначаемых ролей
+type Roles interface {
+	FindById(id int64) (role.Response, error)
+}
+
+type Service struct {
+	repo  Repo
+	roles Roles
+	now   func() time.Time
+}
+
+func NewService(repository Repo, roles Roles) *Service {
+	return &Service{repo: repository, roles: roles, now: time.Now}
+}
+
+func (s *Service) FindById(id int64) (Response, error) {
+	account, err := s.repo.FindById(id)
+	if err != nil {
+		return Response{}, fmt.Errorf("error finding service account with id %d: %w", id, err)
+	}
+
+	return s.toResponse(account)
+}
+
+func (s *Service) FindAll() ([]Response, error) {
+	accounts, err := s.repo.FindAll()
+	if err != nil {
+		return nil, fmt.Errorf("error finding all service accounts: %w", err)
+	}
+
+	responses := make([]Response, 0, len(accounts))
+	for _, account := range accounts {
+		response, err := s.toResponse(account)
+		if err != nil {
+			return nil, err
+		}
+		responses = append(responses, response)
+	}
+
+	return responses, nil
+}
+
+func (s *Service) Create(request CreateRequest) (Response, error) {
+	name := strings.TrimSpace(request.Name)
+	if name == "" {
+		return Response{}, ErrInvalidName
+	}
+
+	_, err := s.repo.FindByName(name)
+	switch {
+	case err == nil:
+		return Response{}, fmt.Errorf("%w: %s", ErrNameTaken, name)
+	case !errors.Is(err, database.ErrRecordNotFound):
+		return Response{}, fmt.Errorf("error finding service account with name %q: %w", name, err)
+	}
+
+	account := &ServiceAccount{Name: name, Description: request.Description, OwnerId: request.OwnerId}
+	if err := s.repo.Create(account); err != nil {
+		return Response{}, fmt.Errorf("error creating service account with name %q: %w", name, err)
+	}
+
+	return account.ToResponse(nil), nil
+}
+
+// SetDisabled отключить учётную запись: все её ключи перестают приниматься, но не отзываются
+func (s *Service) SetDisabled(id int64, disabled bool) (Response, error) {
+	var disabledAt *time.Time
+	if disabled {
+		now := s.now()
+		disabledAt = &now
+	}
+
+	if err := s.repo.SetDisabled(id, disabledAt); err != nil {
+		return Response{}, fmt.Errorf("error updating service account with id %d: %w", id, err)
+	}
+
+	return s.FindById(id)
+}
+
+func (s *Service) Remove(id int64) error {
+	if err := s.repo.Remove(id); err != nil {
+		return fmt.Errorf("error removing service account with id %d: %w", id, err)
+	}
+
+	return nil
+}
+
+func (s *Service) AssignRole(id int64, roleId int64) error {
+	if _, err := s.repo.FindById(id); err != nil {
+		return fmt.Errorf("error finding service account with id %d: %w", id, err)
+	}
+	if _, err := s.roles.FindById(roleId); err != nil {
+		return err
+	}
+
+	if err := s.repo.AssignRole(id, roleId); err != nil {
+		return fmt.Errorf("error assigning role with id %d to service account with id %d: %w", roleId, id, err)
+	}
+
+	return nil
+}
+
+func (s *Service) RevokeRole(id int64, roleId int64) error {
+	if err := s.repo.RevokeRole(id, roleId); err != nil {
+		return fmt.Errorf("error revoking role with id %d from service account with id %d: %w", roleId, id, err)
+	}
+
+	return nil
+}
+
+func (s *Service) FindKeys(id int64) ([]KeyResponse, error) {
+	keys, err := s.repo.FindKeys(id)
+	if err != nil {
+		return nil, fmt.Errorf("error finding api keys of service account with id %d: %w", id, err)
+	}
+
+	responses := make([]KeyResponse, 0, len(keys))
+	for _, key := range keys {
+		responses = append(responses, key.ToResponse())
+	}
+
+	return responses, nil
+}
+
+// FindKey ключ служебной учётной записи; чужой ключ считается ненайденным
+func (s *Service) FindKey(id int64, keyId int64) (KeyResponse, error) {
+	key, err := s.findKey(id, keyId)
+	if err != nil {
+		return KeyResponse{}, err
+	}
+
+	return key.ToResponse(), nil
+}
+
+// IssueKey выпустить ключ с указанными правами
+func (s *Service) IssueKey(id int64, request KeyRequest) (IssuedKey, error) {
+	if _, err := s.repo.FindById(id); err != nil {
+		return IssuedKey{}, fmt.Errorf("error finding service account with id %d: %w", id, err)
+	}
+
+	scopes, err := normalizeScopes(request.Scopes)
+	if err != nil {
+		return IssuedKey{}, err
+	}
+
+	return s.issue(&Key{
+		ServiceAccountId: id,
+		Name:             strings.TrimSpace(request.Name),
+		Scopes:           scopes,
+		ExpiresAt:        request.ExpiresAt,
+	}, func(key *Key) error { return s.repo.CreateKey(key) })
+}
+
+// RotateKey выпустить замену ключу с теми же правами. Прежний ключ действует ещё overlap,
+// чтобы клиенты успели перейти на новый без простоя.
+func (s *Service) RotateKey(id int64, keyId int64, overlap time.Duration) (IssuedKey, error) {
+	old, err := s.findKey(id, keyId)
+	if err != nil {
+		return IssuedKey{}, err
+	}
+	if old.RevokedAt != nil || old.RotatedTo != nil {
+		return IssuedKey{}, ErrKeyInactive
+	}
+
+	now := s.now()
+	key := &Key{ServiceAccountId: id, Name: old.Name, Scopes: old.Scopes}
+	if old.ExpiresAt != nil {
+		// новый ключ получает тот же срок жизни, что был у прежнего
+		expiresAt := now.Add(old.ExpiresAt.Sub(old.CreatedAt))
+		key.ExpiresAt = &expiresAt
+	}
+
+	return s.issue(key, func(key *Key) error {
+		err := s.repo.RotateKey(old.Id, now.Add(overlap), key)
+		if errors.Is(err, database.ErrRecordNotFound) {
+			return ErrKeyInactive
+		}
+		return err
+	})
+}
+
+func (s *Service) RevokeKey(id int64, keyId int64) error {
+	if _, err := s.findKey(id, keyId); err != nil {
+		return err
+	}
+
+	if err := s.repo.RevokeKey(keyId, s.now()); err != nil {
+		return fmt.Errorf("error revoking api key with id %d: %w", keyId, err)
+	}
+
+	return nil
+}
+
+// Authenticate проверить предъявленный ключ
+func (s *Service) Authenticate(secret string) (Principal, error) {
+	lookup, raw, ok := parseKey(secret)
+	if !ok {
+		return Principal{}, ErrInvalidKey
+	}
+
+	key, err := s.repo.FindKeyByLookup(lookup)
+	if err != nil {
+		if errors.Is(err, database.ErrRecordNotFound) {
+			return Principal{}, ErrInvalidKey
+		}
+		return Principal{}, fmt.Errorf("error finding api key: %w", err)
+	}
+
+	if subtle.ConstantTimeCompare([]byte(key.Hash), []byte(hashSecret(raw))) != 1 {
+		return Principal{}, ErrInvalidKey
+	}
+
+	now := s.now()
+	if key.RevokedAt != nil {
+		return Principal{}, fmt.Errorf("%w: revoked", ErrInvalidKey)
+	}
+	if key.ExpiresAt != nil && !now.Before(*key.ExpiresAt) {
+		return Principal{}, fmt.Errorf("%w: expired", ErrInvalidKey)
+	}
+
+	account, err := s.repo.FindById(key.ServiceAccountId)
+	if err != nil {
+		return Principal{}, fmt.Errorf("error finding service account with id %d: %w", key.ServiceAccountId, err)
+	}
+	if account.DisabledAt != nil {
+		return Principal{}, fmt.Errorf("%w: service account is disabled", ErrInvalidKey)
+	}
+
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= lastUsedPrecision {
+		if err := s.repo.TouchKey(key.Id, now); err != nil {
+			return Principal{}, fmt.Errorf("error updating api key with id %d: %w", key.Id, err)
+		}
+	}
+
+	return Principal{ServiceAccountId: account.Id, Name: account.Name, KeyId: key.Id, Scopes: key.Scopes}, nil
+}
+
+// Allows разрешает ли набор прав действие; право на запись включает чтение
+func Allows(scopes []string, scope string) bool {
+	if slices.Contains(scopes, scope) {
+		return true
+	}
+	if resource, found := strings.CutSuffix(scope, ":read"); found {
+		return slices.Contains(scopes, resource+":write")
+	}
+
+	return false
+}
+
+func (s *Service) issue(key *Key, save func(key *Key) error) (IssuedKey, error) {
+	secret, lookup, hash, err := newKey()
+	if err != nil {
+		return IssuedKey{}, err
+	}
+	key.Lookup, key.Hash = lookup, hash
+
+	if err := save(key); err != nil {
+		if errors.Is(err, ErrKeyInactive) {
+			return IssuedKey{}, err
+		}
+		return IssuedKey{}, fmt.Errorf("error saving api key of service account with id %d: %w", key.ServiceAccountId, err)
+	}
+
+	return IssuedKey{KeyResponse: key.ToResponse(), Secret: secret}, nil
+}
+
+func (s *Service) findKey(id int64, keyId int64) (*Key, error) {
+	key, err := s.repo.FindKey(keyId)
+	if err == nil && key.ServiceAccountId != id {
+		err = database.ErrRecordNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error finding api key with id %d: %w", keyId, err)
+	}
+
+	return key, nil
+}
+
+func (s *Service) toResponse(account *ServiceAccount) (Response, error) {
+	roleIds, err := s.repo.FindRoleIds(account.Id)
+	if err != nil {
+		return Response{}, fmt.Errorf("error finding roles of service account with id %d: %w", account.Id, err)
+	}
+
+	return account.ToResponse(roleIds), nil
+}
+
+func normalizeScopes(scopes []string) ([]string, error) {
+	var normalized []string
+	for _, scope := range scopes {
+		scope = strings.TrimSpace(scope)
+		if !slices.Contains(Scopes, scope) {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidScope, scope)
+		}
+		if !slices.Contains(normalized, scope) {
+			normalized = append(normalized, scope)
+		}
+	}
+	if len(normalized) == 0 {
+		return nil, fmt.Errorf("%w: at least one scope is required", ErrInvalidScope)
+	}
+
+	return normalized, nil
+}
@@ -0,0 +1,316 @@
+package serviceaccount
+
+import (
+	"errors"
+	assertpackage "github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"idm/inner/database"
+	"idm/inner/role"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type MockRepo struct {
+	mock.Mock
+}
+
+func (m *MockRepo) FindById(id int64) (*ServiceAccount, error) {
+	args := m.Called(id)
+	return args.Get(0).(*ServiceAccount), args.Error(1)
+}
+
+func (m *MockRepo) FindByName(name string) (*ServiceAccount, error) {
+	args := m.Called(name)
+	return args.Get(0).(*ServiceAccount), args.Error(1)
+}
+
+func (m *MockRepo) FindAll() ([]*ServiceAccount, error) {
+	args := m.Called()
+	return args.Get(0).([]*ServiceAccount), args.Error(1)
+}
+
+func (m *MockRepo) Create(account *ServiceAccount) error {
+	args := m.Called(account)
+	return args.Error(0)
+}
+
+func (m *MockRepo) SetDisabled(id int64, disabledAt *time.Time) error {
+	args := m.Called(id, disabledAt)
+	return args.Error(0)
+}
+
+func (m *MockRepo) Remove(id int64) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockRepo) FindRoleIds(id int64) ([]int64, error) {
+	args := m.Called(id)
+	return args.Get(0).([]int64), args.Error(1)
+}
+
+func (m *MockRepo) AssignRole(id int64, roleId int64) error {
+	args := m.Called(id, roleId)
+	return args.Error(0)
+}
+
+func (m *MockRepo) RevokeRole(id int64, roleId int64) error {
+	args := m.Called(id, roleId)
+	return args.Error(0)
+}
+
+func (m *MockRepo) FindKeys(id int64) ([]*Key, error) {
+	args := m.Called(id)
+	return args.Get(0).([]*Key), args.Error(1)
+}
+
+func (m *MockRepo) FindKey(id int64) (*Key, error) {
+	args := m.Called(id)
+	return args.Get(0).(*Key), args.Error(1)
+}
+
+func (m *MockRepo) FindKeyByLookup(lookup string) (*Key, error) {
+	args := m.Called(lookup)
+	return args.Get(0).(*Key), args.Error(1)
+}
+
+func (m *MockRepo) CreateKey(key *Key) error {
+	args := m.Called(key)
+	return args.Error(0)
+}
+
+func (m *MockRepo) RotateKey(oldId int64, oldExpiresAt time.Time, key *Key) error {
+	args := m.Called(oldId, oldExpiresAt, key)
+	return args.Error(0)
+}
+
+func (m *MockRepo) RevokeKey(id int64, revokedAt time.Time) error {
+	args := m.Called(id, revokedAt)
+	return args.Error(0)
+}
+
+func (m *MockRepo) TouchKey(id int64, usedAt time.Time) error {
+	args := m.Called(id, usedAt)
+	return args.Error(0)
+}
+
+type StubRoles struct{}
+
+func (StubRoles) FindById(id int64) (role.Response, error) {
+	if id == 1 {
+		return role.Response{Id: 1, Name: "Reader"}, nil
+	}
+	return role.Response{}, database.ErrRecordNotFound
+}
+
+func TestKey(t *testing.T) {
+	assert := assertpackage.New(t)
+
+	t.Run("should generate a prefixed key that parses back", func(t *testing.T) {
+		key, lookup, hash, err := newKey()
+		assert.Nil(err)
+		assert.True(strings.HasPrefix(key, KeyPrefix+lookup+"_"))
+		assert.NotContains(key, hash)
+
+		parsedLookup, secret, ok := parseKey(key)
+		assert.True(ok)
+		assert.Equal(lookup, parsedLookup)
+		assert.Equal(hash, hashSecret(secret))
+	})
+
+	t.Run("should reject a key with a broken checksum", func(t *testing.T) {
+		key, _, _, _ := newKey()
+		broken := key[:10] + string('a'+(key[10]-'a'+1)%26) + key[11:]
+
+		_, _, ok := parseKey(broken)
+		assert.False(ok)
+		_, _, ok = parseKey("ghp_something")
+		assert.False(ok)
+	})
+
+	t.Run("should treat write scopes as including read", func(t *testing.T) {
+		assert.True(Allows([]string{ScopeEmployeesWrite}, ScopeEmployeesRead))
+		assert.False(Allows([]string{ScopeEmployeesRead}, ScopeEmployeesWrite))
+		assert.False(Allows([]string{ScopeRolesWrite}, ScopeEmployeesRead))
+	})
+}
+
+func TestServiceAccountService(t *testing.T) {
+	assert := assertpackage.New(t)
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	newService := func(repo Repo) *Service {
+		service := NewService(repo, StubRoles{})
+		service.now = func() time.Time { return now }
+		return service
+	}
+
+	// issue выпустить ключ через сервис и вернуть его вместе с сохранённой записью
+	issue := func(service *Service, repo *MockRepo, scopes ...string) (IssuedKey, *Key) {
+		var saved *Key
+		repo.On("FindById", int64(1)).Return(&ServiceAccount{Id: 1, Name: "ci"}, nil)
+		repo.On("CreateKey", mock.AnythingOfType("*serviceaccount.Key")).
+			Run(func(args mock.Arguments) {
+				saved = args.Get(0).(*Key)
+				saved.Id, saved.CreatedAt = 10, now
+			}).Return(nil).Once()
+		issued, err := service.IssueKey(1, KeyRequest{Name: "deploy", Scopes: scopes})
+		assert.Nil(err)
+		return issued, saved
+	}
+
+	t.Run("should refuse a duplicate name", func(t *testing.T) {
+		repo := new(MockRepo)
+		service := newService(repo)
+		repo.On("FindByName", "ci").Return(&ServiceAccount{Id: 1, Name: "ci"}, nil)
+
+		_, err := service.Create(CreateRequest{Name: " ci "})
+
+		assert.True(errors.Is(err, ErrNameTaken))
+	})
+
+	t.Run("should issue a key storing only its hash", func(t *testing.T) {
+		repo := new(MockRepo)
+		service := newService(repo)
+
+		issued, saved := issue(service, repo, ScopeSCIM, ScopeSCIM)
+
+		assert.True(strings.HasPrefix(issued.Secret, KeyPrefix))
+		assert.Equal([]string{ScopeSCIM}, []string(saved.Scopes))
+		assert.NotContains(issued.Secret, saved.Hash)
+		assert.Equal(KeyPrefix+saved.Lookup, issued.Prefix)
+	})
+
+	t.Run("should reject unknown scopes", func(t *testing.T) {
+		repo := new(MockRepo)
+		service := newService(repo)
+		repo.On("FindById", int64(1)).Return(&ServiceAccount{Id: 1}, nil)
+
+		_, err := service.IssueKey(1, KeyRequest{Scopes: []string{"everything"}})
+
+		assert.True(errors.Is(err, ErrInvalidScope))
+	})
+
+	t.Run("should authenticate a valid key and track its use", func(t *testing.T) {
+		repo := new(MockRepo)
+		service := newService(repo)
+		issued, saved := issue(service, repo, ScopeSCIM)
+		repo.On("FindKeyByLookup", saved.Lookup).Return(saved, nil)
+		repo.On("TouchKey", int64(10), now).Return(nil)
+
+		principal, err := service.Authenticate(issued.Secret)
+
+		assert.Nil(err)
+		assert.Equal(Principal{ServiceAccountId: 1, Name: "ci", KeyId: 10, Scopes: []string{ScopeSCIM}}, principal)
+		repo.AssertCalled(t, "TouchKey", int64(10), now)
+	})
+
+	t.Run("should not update last use on every request", func(t *testing.T) {
+		repo := new(MockRepo)
+		service := newService(repo)
+		issued, saved := issue(service, repo, ScopeSCIM)
+		recently := now.Add(-10 * time.Second)
+		saved.LastUsedAt = &recently
+		repo.On("FindKeyByLookup", saved.Lookup).Return(saved, nil)
+
+		_, err := service.Authenticate(issued.Secret)
+
+		assert.Nil(err)
+		repo.AssertNotCalled(t, "TouchKey", mock.Anything, mock.Anything)
+	})
+
+	t.Run("should reject wrong, expired, revoked keys and disabled accounts", func(t *testing.T) {
+		repo := new(MockRepo)
+		service := newService(repo)
+		issued, saved := issue(service, repo, ScopeSCIM)
+		other, _, _, _ := newKey()
+		otherLookup, _, _ := parseKey(other)
+		repo.On("FindKeyByLookup", otherLookup).Return(&Key{}, database.ErrRecordNotFound)
+
+		_, err := service.Authenticate(other)
+		assert.True(errors.Is(err, ErrInvalidKey))
+
+		expired := *saved
+		expiresAt := now
+		expired.ExpiresAt = &expiresAt
+		repo.On("FindKeyByLookup", saved.Lookup).Return(&expired, nil).Once()
+		_, err = service.Authenticate(issued.Secret)
+		assert.True(errors.Is(err, ErrInvalidKey))
+
+		revoked := *saved
+		revoked.RevokedAt = &now
+		repo.On("FindKeyByLookup", saved.Lookup).Return(&revoked, nil).Once()
+		_, err = service.Authenticate(issued.Secret)
+		assert.True(errors.Is(err, ErrInvalidKey))
+
+		disabledRepo := new(MockRepo)
+		disabledService := newService(disabledRepo)
+		disabledRepo.On("FindKeyByLookup", saved.Lookup).Return(saved, nil)
+		disabledRepo.On("FindById", int64(1)).Return(&ServiceAccount{Id: 1, DisabledAt: &now}, nil)
+		_, err = disabledService.Authenticate(issued.Secret)
+		assert.True(errors.Is(err, ErrInvalidKey))
+	})
+
+	t.Run("should rotate a key keeping the old one during the overlap", func(t *testing.T) {
+		repo := new(MockRepo)
+		service := newService(repo)
+		created := now.Add(-10 * 24 * time.Hour)
+		expires := created.Add(90 * 24 * time.Hour)
+		repo.On("FindKey", int64(10)).Return(&Key{
+			Id: 10, ServiceAccountId: 1, Name: "deploy", Scopes: []string{ScopeSCIM}, CreatedAt: created, ExpiresAt: &expires,
+		}, nil)
+		repo.On("RotateKey", int64(10), now.Add(time.Hour), mock.MatchedBy(func(key *Key) bool {
+			return key.ExpiresAt.Equal(now.Add(90*24*time.Hour)) && key.Scopes[0] == ScopeSCIM
+		})).Return(nil)
+
+		rotated, err := service.RotateKey(1, 10, time.Hour)
+
+		assert.Nil(err)
+		assert.NotEmpty(rotated.Secret)
+		repo.AssertExpectations(t)
+	})
+
+	t.Run("should not rotate a key of another account or a rotated key", func(t *testing.T) {
+		repo := new(MockRepo)
+		service := newService(repo)
+		next := int64(11)
+		repo.On("FindKey", int64(10)).Return(&Key{Id: 10, ServiceAccountId: 1, RotatedTo: &next}, nil)
+
+		_, err := service.RotateKey(2, 10, time.Hour)
+		assert.True(errors.Is(err, database.ErrRecordNotFound))
+
+		_, err = service.RotateKey(1, 10, time.Hour)
+		assert.Equal(ErrKeyInactive, err)
+	})
+
+	t.Run("should check scopes in the middleware", func(t *testing.T) {
+		repo := new(MockRepo)
+		service := newService(repo)
+		issued, saved := issue(service, repo, ScopeRolesRead)
+		repo.On("FindKeyByLookup", saved.Lookup).Return(saved, nil)
+		repo.On("TouchKey", int64(10), now).Return(nil)
+
+		var principal Principal
+		ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal, _ = FromContext(r.Context())
+		})
+		serve := func(scope string, authorization string) int {
+			request := httptest.NewRequest(http.MethodGet, "/", nil)
+			if authorization != "" {
+				request.Header.Set("Authorization", authorization)
+			}
+			recorder := httptest.NewRecorder()
+			service.RequireScope(scope, ok).ServeHTTP(recorder, request)
+			return recorder.Code
+		}
+
+		assert.Equal(http.StatusUnauthorized, serve(ScopeRolesRead, ""))
+		assert.Equal(http.StatusUnauthorized, serve(ScopeRolesRead, "Bearer idm_nope"))
+		assert.Equal(http.StatusForbidden, serve(ScopeSCIM, "Bearer "+issued.Secret))
+		assert.Equal(http.StatusOK, serve(ScopeRolesRead, "Bearer "+issued.Secret))
+		assert.Equal(int64(1), principal.ServiceAccountId)
+	})
+}
@@ -0,0 +1,31 @@
+package session
+
+import "time"
+
+type Response struct {
+	Id         int64     `json:"id"`
+	EmployeeId int64     `json:"employee_id"`
+	Device     string    `json:"device"`
+	IP         string    `json:"ip"`
+	UserAgent  string    `json:"user_agent"`
+	AuthTime   time.Time `json:"auth_time"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	Current    bool      `json:"current"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+func (s *Session) ToResponse(current bool) Response {
+	return Response{
+		Id:         s.Id,
+		EmployeeId: s.EmployeeId,
+		Device:     s.Device,
+		IP:         s.IP,
+		UserAgent:  s.UserAgent,
+		AuthTime:   s.AuthTime,
+		LastSeenAt: s.LastSeenAt,
+		ExpiresAt:  s.ExpiresAt,
+		Current:    current,
+		CreatedAt:  s.CreatedAt,
+	}
+}
@@ -0,0 +1,204 @@
+package session
+
+import (
+	"errors"
+	"idm/inner/httpapi"
+	"net"
+	"net/http"
+	"time"
+)
+
+// CookieName cookie, в которой браузер хранит токен сеанса
+const CookieName = "idm_session"
+
+// statuses коды ответа для ошибок сервиса
+var statuses = httpapi.Statuses{
+	{Err: ErrInvalidSession, Code: http.StatusUnauthorized},
+}
+
+// SetSecureCookie отдавать cookie только по HTTPS; включается, когда сервис доступен по https
+func (s *Service) SetSecureCookie(secure bool) {
+	s.secure = secure
+}
+
+// Start начать сеанс после успешного входа и выдать браузеру cookie
+func (s *Service) Start(w http.ResponseWriter, r *http.Request, employeeId int64, authTime time.Time) error {
+	token, session, err := s.Create(employeeId, authTime, clientOf(r))
+	if err != nil {
+		return err
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     CookieName,
+		Value:    token,
+		Path:     "/",
+		Expires:  session.ExpiresAt,
+		HttpOnly: true,
+		Secure:   s.secure,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	return nil
+}
+
+// FromRequest сеанс из cookie запроса
+func (s *Service) FromRequest(r *http.Request) (Response, error) {
+	cookie, err := r.Cookie(CookieName)
+	if err != nil {
+		return Response{}, ErrInvalidSession
+	}
+
+	return s.Validate(cookie.Value, clientOf(r))
+}
+
+// Handler сеансы текущего сотрудника: список устройств, выход и отзыв
+type Handler struct {
+	service *Service
+	mux     *http.ServeMux
+}
+
+func NewHandler(service *Service) *Handler {
+	h := &Handler{service: service, mux: http.NewServeMux()}
+
+	h.mux.HandleFunc("GET /{$}", h.list)
+	h.mux.HandleFunc("DELETE /{$}", h.revokeAll)
+	h.mux.HandleFunc("DELETE /{id}", h.revoke)
+	h.mux.HandleFunc("POST /logout", h.logout)
+
+	return h
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+func (h *Handler) list(w http.ResponseWriter, r *http.Request) {
+	current, ok := h.authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	sessions, err := h.service.FindByEmployeeId(current.EmployeeId)
+	for i := range sessions {
+		sessions[i].Current = sessions[i].Id == current.Id
+	}
+	httpapi.Write(w, http.StatusOK, sessions, err, statuses)
+}
+
+func (h *Handler) revoke(w http.ResponseWriter, r *http.Request) {
+	current, ok := h.authenticate(w, r)
+	if !ok {
+		return
+	}
+	id, ok := httpapi.PathId(w, r, "id")
+	if !ok {
+		return
+	}
+
+	httpapi.Write(w, http.StatusNoContent, nil, h.service.Revoke(current.EmployeeId, id, ReasonRevoked), statuses)
+}
+
+func (h *Handler) revokeAll(w http.ResponseWriter, r *http.Request) {
+	current, ok := h.authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	_, err := h.service.RevokeAll(current.EmployeeId, ReasonRevoked)
+	if err == nil {
+		clearCookie(w)
+	}
+	httpapi.Write(w, http.StatusNoContent, nil, err, statuses)
+}
+
+func (h *Handler) logout(w http.ResponseWriter, r *http.Request) {
+	clearCookie(w)
+
+	cookie, err := r.Cookie(CookieName)
+	if err != nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	err = h.service.Logout(cookie.Value)
+	if errors.Is(err, ErrInvalidSession) {
+		err = nil
+	}
+	httpapi.Write(w, http.StatusNoContent, nil, err, statuses)
+}
+
+func (h *Handler) authenticate(w http.ResponseWriter, r *http.Request) (Response, bool) {
+	current, err := h.service.FromRequest(r)
+	if err != nil {
+		httpapi.Write(w, 0, nil, err, statuses)
+		return Response{}, false
+	}
+
+	return current, true
+}
+
+// AdminHandler просмотр и отзыв сеансов любого сотрудника
+type AdminHandler struct {
+	service *Service
+	mux     *http.ServeMux
+}
+
+func NewAdminHandler(service *Service) *AdminHandler {
+	h := &AdminHandler{service: service, mux: http.NewServeMux()}
+
+	h.mux.HandleFunc("GET /{employeeId}/sessions", h.list)
+	h.mux.HandleFunc("DELETE /{employeeId}/sessions", h.revokeAll)
+	h.mux.HandleFunc("DELETE /{employeeId}/sessions/{id}", h.revoke)
+
+	return h
+}
+
+func (h *AdminHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+func (h *AdminHandler) list(w http.ResponseWriter, r *http.Request) {
+	employeeId, ok := httpapi.PathId(w, r, "employeeId")
+	if !ok {
+		return
+	}
+
+	sessions, err := h.service.FindByEmployeeId(employeeId)
+	httpapi.Write(w, http.StatusOK, sessions, err, statuses)
+}
+
+func (h *AdminHandler) revoke(w http.ResponseWriter, r *http.Request) {
+	employeeId, ok := httpapi.PathId(w, r, "employeeId")
+	if !ok {
+		return
+	}
+	id, ok := httpapi.PathId(w, r, "id")
+	if !ok {
+		return
+	}
+
+	httpapi.Write(w, http.StatusNoContent, nil, h.service.Revoke(employeeId, id, ReasonRevoked), statuses)
+}
+
+func (h *AdminHandler) revokeAll(w http.ResponseWriter, r *http.Request) {
+	employeeId, ok := httpapi.PathId(w, r, "employeeId")
+	if !ok {
+		return
+	}
+
+	count, err := h.service.RevokeAll(employeeId, ReasonRevoked)
+	httpapi.Write(w, http.StatusOK, map[string]int{"revoked": count}, err, statuses)
+}
+
+func clientOf(r *http.Request) Client {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+
+	return Client{IP: ip, UserAgent: r.UserAgent()}
+}
+
+func clearCookie(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{Name: CookieName, Value: "", Path: "/", MaxAge: -1, HttpOnly: true})
+}
@@ -0,0 +1,146 @@
+package session
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/jmoiron/sqlx"
+	"idm/inner/database"
+	"time"
+)
+
+// Session сеанс сотрудника после входа. Хранится только SHA-256 токена из cookie.
+type Session struct {
+	Id           int64      `db:"id"`
+	EmployeeId   int64      `db:"employee_id"`
+	TokenHash    string     `db:"token_hash"`
+	Device       string     `db:"device"`
+	IP           string     `db:"ip"`
+	UserAgent    string     `db:"user_agent"`
+	AuthTime     time.Time  `db:"auth_time"`
+	LastSeenAt   time.Time  `db:"last_seen_at"`
+	ExpiresAt    time.Time  `db:"expires_at"`
+	RevokedAt    *time.Time `db:"revoked_at"`
+	RevokeReason string     `db:"revoke_reason"`
+	CreatedAt    time.Time  `db:"created_at"`
+}
+
+type Repository struct {
+	db *sqlx.DB
+}
+
+func NewRepository(db *sqlx.DB) *Repository {
+	return &Repository{db: db}
+}
+
+func (r *Repository) FindByTokenHash(tokenHash string) (*Session, error) {
+	var session Session
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := r.db.GetContext(ctx, &session, "SELECT * FROM sessions WHERE token_hash = $1", tokenHash)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, database.ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &session, err
+}
+
+// FindActive действующие на момент now сеансы сотрудника, последние – первыми
+func (r *Repository) FindActive(employeeId int64, now time.Time) ([]*Session, error) {
+	var sessions []*Session
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := r.db.SelectContext(ctx, &sessions,
+		`SELECT * FROM sessions
+		WHERE employee_id = $1 AND revoked_at IS NULL AND expires_at > $2
+		ORDER BY last_seen_at DESC`,
+		employeeId, now)
+
+	return sessions, err
+}
+
+func (r *Repository) Create(session *Session) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return r.db.QueryRowContext(ctx,
+		`INSERT INTO sessions (employee_id, token_hash, device, ip, user_agent, auth_time, last_seen_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id, created_at`,
+		session.EmployeeId, session.TokenHash, session.Device, session.IP, session.UserAgent,
+		session.AuthTime, session.LastSeenAt, session.ExpiresAt,
+	).Scan(&session.Id, &session.CreatedAt)
+}
+
+func (r *Repository) Touch(id int64, lastSeenAt time.Time, ip string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := r.db.ExecContext(ctx,
+		"UPDATE sessions SET last_seen_at = $1, ip = $2 WHERE id = $3", lastSeenAt, ip, id)
+
+	return err
+}
+
+// Revoke отозвать сеанс сотрудника; возвращает хеш его токена, чтобы сбросить кеш
+func (r *Repository) Revoke(employeeId int64, id int64, reason string, at time.Time) (string, error) {
+	var tokenHash string
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := r.db.GetContext(ctx, &tokenHash,
+		`UPDATE sessions SET revoked_at = $1, revoke_reason = $2
+		WHERE id = $3 AND employee_id = $4 AND revoked_at IS NULL
+		RETURNING token_hash`,
+		at, reason, id, employeeId)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return "", database.ErrRecordNotFound
+		default:
+			return "", err
+		}
+	}
+
+	return tokenHash, nil
+}
+
+// RevokeAll отозвать все сеансы сотрудника; возвращает хеши их токенов
+func (r *Repository) RevokeAll(employeeId int64, reason string, at time.Time) ([]string, error) {
+	var tokenHashes []string
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := r.db.SelectContext(ctx, &tokenHashes,
+		`UPDATE sessions SET revoked_at = $1, revoke_reason = $2
+		WHERE employee_id = $3 AND revoked_at IS NULL
+		RETURNING token_hash`,
+		at, reason, employeeId)
+
+	return tokenHashes, err
+}
+
+// RemoveExpired удалить сеансы, истёкшие или отозванные раньше before
+func (r *Repository) RemoveExpired(before time.Time) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := r.db.ExecContext(ctx,
+		"DELETE FROM sessions WHERE expires_at < $1 OR revoked_at < $1", before)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
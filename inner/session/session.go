@@ -0,0 +1,337 @@
+package session
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"idm/inner/database"
+	"idm/inner/employee"
+	"idm/inner/lifecycle"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultIdleTimeout сеанс завершается, если им не пользовались столько времени
+	DefaultIdleTimeout = 30 * time.Minute
+	// DefaultAbsoluteTimeout сеанс завершается через столько времени после входа независимо от активности
+	DefaultAbsoluteTimeout = 12 * time.Hour
+	// touchPrecision время последней активности обновляется не чаще, чтобы не писать в базу на каждый запрос
+	touchPrecision = time.Minute
+)
+
+// Причины отзыва сеансов
+const (
+	ReasonLogout           = "logout"
+	ReasonRevoked          = "revoked"
+	ReasonEmployeeDisabled = "employee_disabled"
+	ReasonRolesChanged     = "roles_changed"
+)
+
+var ErrInvalidSession = errors.New("session is invalid or expired")
+
+type Repo interface {
+	FindByTokenHash(tokenHash string) (*Session, error)
+	FindActive(employeeId int64, now time.Time) ([]*Session, error)
+	Create(session *Session) error
+	Touch(id int64, lastSeenAt time.Time, ip string) error
+	Revoke(employeeId int64, id int64, reason string, at time.Time) (string, error)
+	RevokeAll(employeeId int64, reason string, at time.Time) ([]string, error)
+	RemoveExpired(before time.Time) (int64, error)
+}
+
+// Client сведения об устройстве, с которого выполнен вход
+type Client struct {
+	IP        string
+	UserAgent string
+}
+
+type Service struct {
+	repo     Repo
+	idle     time.Duration
+	absolute time.Duration
+	cache    *cache
+	secure   bool
+	now      func() time.Time
+}
+
+func NewService(repository Repo) *Service {
+	return &Service{repo: repository, idle: DefaultIdleTimeout, absolute: DefaultAbsoluteTimeout, now: time.Now}
+}
+
+// SetTimeouts изменить тайм-ауты бездействия и абсолютного времени жизни сеанса
+func (s *Service) SetTimeouts(idle time.Duration, absolute time.Duration) {
+	s.idle = idle
+	s.absolute = absolute
+}
+
+// UseCache хранить проверенные сеансы в памяти процесса. Отзыв в этом процессе виден сразу,
+// в других экземплярах сервиса – не позже чем через ttl.
+func (s *Service) UseCache(ttl time.Duration) {
+	s.cache = &cache{ttl: ttl, entries: map[string]cacheEntry{}}
+}
+
+// Create начать сеанс. Токен возвращается один раз – в базе хранится только его хеш.
+func (s *Service) Create(employeeId int64, authTime time.Time, client Client) (string, Response, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", Response{}, fmt.Errorf("error generating session token: %w", err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(raw)
+
+	now := s.now()
+	session := &Session{
+		EmployeeId: employeeId,
+		TokenHash:  hashToken(token),
+		Device:     describeDevice(client.UserAgent),
+		IP:         client.IP,
+		UserAgent:  client.UserAgent,
+		AuthTime:   authTime,
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(s.absolute),
+	}
+	if err := s.repo.Create(session); err != nil {
+		return "", Response{}, fmt.Errorf("error creating session of employee with id %d: %w", employeeId, err)
+	}
+
+	return token, session.ToResponse(false), nil
+}
+
+// Validate проверить токен сеанса и отметить активность
+func (s *Service) Validate(token string, client Client) (Response, error) {
+	tokenHash := hashToken(token)
+	now := s.now()
+
+	session, cached := s.cache.get(tokenHash, now)
+	if !cached {
+		found, err := s.repo.FindByTokenHash(tokenHash)
+		if err != nil {
+			if errors.Is(err, database.ErrRecordNotFound) {
+				return Response{}, ErrInvalidSession
+			}
+			return Response{}, fmt.Errorf("error finding session: %w", err)
+		}
+		session = found
+	}
+
+	if !s.active(session, now) {
+		s.cache.remove(tokenHash)
+		return Response{}, ErrInvalidSession
+	}
+
+	if now.Sub(session.LastSeenAt) >= touchPrecision {
+		if err := s.repo.Touch(session.Id, now, client.IP); err != nil {
+			return Response{}, fmt.Errorf("error updating session with id %d: %w", session.Id, err)
+		}
+		touched := *session
+		touched.LastSeenAt, touched.IP = now, client.IP
+		session = &touched
+	}
+	s.cache.put(tokenHash, session, now)
+
+	return session.ToResponse(true), nil
+}
+
+// FindByEmployeeId действующие сеансы сотрудника
+func (s *Service) FindByEmployeeId(employeeId int64) ([]Response, error) {
+	now := s.now()
+	sessions, err := s.repo.FindActive(employeeId, now)
+	if err != nil {
+		return nil, fmt.Errorf("error finding sessions of employee with id %d: %w", employeeId, err)
+	}
+
+	responses := make([]Response, 0, len(sessions))
+	for _, session := range sessions {
+		if s.active(session, now) {
+			responses = append(responses, session.ToResponse(false))
+		}
+	}
+
+	return responses, nil
+}
+
+// Revoke отозвать один сеанс сотрудника
+func (s *Service) Revoke(employeeId int64, id int64, reason string) error {
+	tokenHash, err := s.repo.Revoke(employeeId, id, reason, s.now())
+	if err != nil {
+		return fmt.Errorf("error revoking session with id %d of employee with id %d: %w", id, employeeId, err)
+	}
+	s.cache.remove(tokenHash)
+
+	return nil
+}
+
+// RevokeAll отозвать все сеансы сотрудника; возвращает их количество
+func (s *Service) RevokeAll(employeeId int64, reason string) (int, error) {
+	tokenHashes, err := s.repo.RevokeAll(employeeId, reason, s.now())
+	if err != nil {
+		return 0, fmt.Errorf("error revoking sessions of employee with id %d: %w", employeeId, err)
+	}
+	for _, tokenHash := range tokenHashes {
+		s.cache.remove(tokenHash)
+	}
+
+	return len(tokenHashes), nil
+}
+
+// Logout завершить сеанс по его токену
+func (s *Service) Logout(token string) error {
+	session, err := s.Validate(token, Client{})
+	if err != nil {
+		return err
+	}
+
+	return s.Revoke(session.EmployeeId, session.Id, ReasonLogout)
+}
+
+// RemoveExpired удалить из базы давно завершённые сеансы
+func (s *Service) RemoveExpired(retention time.Duration) (int64, error) {
+	count, err := s.repo.RemoveExpired(s.now().Add(-retention))
+	if err != nil {
+		return 0, fmt.Errorf("error removing expired sessions: %w", err)
+	}
+
+	return count, nil
+}
+
+// AfterSave завершить сеансы отключённого сотрудника
+func (s *Service) AfterSave(saved employee.Response) error {
+	if saved.Status != employee.StatusDisabled {
+		return nil
+	}
+
+	_, err := s.RevokeAll(saved.Id, ReasonEmployeeDisabled)
+
+	return err
+}
+
+// AfterAssignmentChange завершить сеансы сотрудника, у которого изменились роли,
+// чтобы новые права применялись только после повторного входа
+func (s *Service) AfterAssignmentChange(employeeId int64, roleId int64) error {
+	_, err := s.RevokeAll(employeeId, ReasonRolesChanged)
+
+	return err
+}
+
+// Handle завершить сеансы при увольнении и при переводе со сменой ролей
+func (s *Service) Handle(event lifecycle.Event) {
+	var reason string
+	switch {
+	case event.Type == lifecycle.EventEmployeeTerminated, event.Type == lifecycle.EventEmployeePurged:
+		reason = ReasonEmployeeDisabled
+	case len(event.Granted) > 0 || len(event.Revoked) > 0:
+		reason = ReasonRolesChanged
+	default:
+		return
+	}
+
+	if _, err := s.RevokeAll(event.EmployeeId, reason); err != nil {
+		log.Printf("error revoking sessions after lifecycle change %d: %v", event.ChangeId, err)
+	}
+}
+
+func (s *Service) active(session *Session, now time.Time) bool {
+	return session.RevokedAt == nil &&
+		now.Before(session.ExpiresAt) &&
+		(s.idle <= 0 || now.Sub(session.LastSeenAt) < s.idle)
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// describeDevice краткое описание браузера и системы для списка сеансов
+func describeDevice(userAgent string) string {
+	browsers := []struct{ marker, name string }{
+		{"Edg/", "Edge"}, {"OPR/", "Opera"}, {"Firefox/", "Firefox"},
+		{"Chrome/", "Chrome"}, {"Safari/", "Safari"}, {"curl/", "curl"},
+	}
+	systems := []struct{ marker, name string }{
+		{"Windows", "Windows"}, {"iPhone", "iOS"}, {"iPad", "iPadOS"}, {"Android", "Android"},
+		{"Mac OS X", "macOS"}, {"CrOS", "ChromeOS"}, {"Linux", "Linux"},
+	}
+
+	var browser, system string
+	for _, candidate := range browsers {
+		if strings.Contains(userAgent, candidate.marker) {
+			browser = candidate.name
+			break
+		}
+	}
+	for _, candidate := range systems {
+		if strings.Contains(userAgent, candidate.marker) {
+			system = candidate.name
+			break
+		}
+	}
+
+	switch {
+	case browser != "" && system != "":
+		return browser + " on " + system
+	case browser != "":
+		return browser
+	case system != "":
+		return system
+	default:
+		return "Unknown device"
+	}
+}
+
+type cacheEntry struct {
+	session  *Session
+	cachedAt time.Time
+}
+
+// cache сеансы, недавно проверенные в этом процессе. Нулевой указатель – кеш выключен.
+type cache struct {
+	ttl     time.Duration
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+}
+
+func (c *cache) get(tokenHash string, now time.Time) (*Session, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[tokenHash]
+	if !ok || now.Sub(entry.cachedAt) >= c.ttl {
+		delete(c.entries, tokenHash)
+		return nil, false
+	}
+
+	return entry.session, true
+}
+
+func (c *cache) put(tokenHash string, session *Session, now time.Time) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// время кеширования не продлевается, иначе отзыв в другом экземпляре мог бы остаться незамеченным
+	cachedAt := now
+	if entry, ok := c.entries[tokenHash]; ok {
+		cachedAt = entry.cachedAt
+	}
+	c.entries[tokenHash] = cacheEntry{session: session, cachedAt: cachedAt}
+}
+
+func (c *cache) remove(tokenHash string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.entries, tokenHash)
+}
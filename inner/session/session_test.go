@@ -0,0 +1,215 @@
+package session
+
+import (
+	assertpackage "github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"idm/inner/database"
+	"idm/inner/employee"
+	"idm/inner/lifecycle"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type MockRepo struct {
+	mock.Mock
+}
+
+func (m *MockRepo) FindByTokenHash(tokenHash string) (*Session, error) {
+	args := m.Called(tokenHash)
+	return args.Get(0).(*Session), args.Error(1)
+}
+
+func (m *MockRepo) FindActive(employeeId int64, now time.Time) ([]*Session, error) {
+	args := m.Called(employeeId, now)
+	return args.Get(0).([]*Session), args.Error(1)
+}
+
+func (m *MockRepo) Create(session *Session) error {
+	args := m.Called(session)
+	return args.Error(0)
+}
+
+func (m *MockRepo) Touch(id int64, lastSeenAt time.Time, ip string) error {
+	args := m.Called(id, lastSeenAt, ip)
+	return args.Error(0)
+}
+
+func (m *MockRepo) Revoke(employeeId int64, id int64, reason string, at time.Time) (string, error) {
+	args := m.Called(employeeId, id, reason, at)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockRepo) RevokeAll(employeeId int64, reason string, at time.Time) ([]string, error) {
+	args := m.Called(employeeId, reason, at)
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *MockRepo) RemoveExpired(before time.Time) (int64, error) {
+	args := m.Called(before)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+const firefox = "Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:128.0) Gecko/20100101 Firefox/128.0"
+
+func TestSessionService(t *testing.T) {
+	assert := assertpackage.New(t)
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	newService := func(repo Repo) (*Service, *time.Time) {
+		clock := now
+		service := NewService(repo)
+		service.now = func() time.Time { return clock }
+		return service, &clock
+	}
+
+	t.Run("should create a session storing only the token hash", func(t *testing.T) {
+		repo := new(MockRepo)
+		service, _ := newService(repo)
+		repo.On("Create", mock.AnythingOfType("*session.Session")).Return(nil)
+
+		token, got, err := service.Create(1, now, Client{IP: "10.0.0.1", UserAgent: firefox})
+
+		assert.Nil(err)
+		saved := repo.Calls[0].Arguments.Get(0).(*Session)
+		assert.Equal(hashToken(token), saved.TokenHash)
+		assert.NotEqual(token, saved.TokenHash)
+		assert.Equal("Firefox on Windows", got.Device)
+		assert.Equal(now.Add(DefaultAbsoluteTimeout), got.ExpiresAt)
+	})
+
+	t.Run("should enforce idle and absolute timeouts", func(t *testing.T) {
+		repo := new(MockRepo)
+		service, clock := newService(repo)
+		service.SetTimeouts(10*time.Minute, time.Hour)
+		repo.On("FindByTokenHash", hashToken("token")).Return(&Session{
+			Id: 5, EmployeeId: 1, LastSeenAt: now, ExpiresAt: now.Add(time.Hour),
+		}, nil)
+
+		*clock = now.Add(9 * time.Minute)
+		repo.On("Touch", int64(5), *clock, "10.0.0.2").Return(nil)
+		got, err := service.Validate("token", Client{IP: "10.0.0.2"})
+		assert.Nil(err)
+		assert.True(got.Current)
+
+		*clock = now.Add(11 * time.Minute)
+		_, err = service.Validate("token", Client{})
+		assert.Equal(ErrInvalidSession, err)
+
+		service.SetTimeouts(0, time.Hour)
+		*clock = now.Add(time.Hour)
+		_, err = service.Validate("token", Client{})
+		assert.Equal(ErrInvalidSession, err)
+	})
+
+	t.Run("should reject unknown and revoked sessions", func(t *testing.T) {
+		repo := new(MockRepo)
+		service, _ := newService(repo)
+		repo.On("FindByTokenHash", hashToken("unknown")).Return((*Session)(nil), database.ErrRecordNotFound)
+		repo.On("FindByTokenHash", hashToken("revoked")).Return(&Session{
+			Id: 5, LastSeenAt: now, ExpiresAt: now.Add(time.Hour), RevokedAt: &now,
+		}, nil)
+
+		_, err := service.Validate("unknown", Client{})
+		assert.Equal(ErrInvalidSession, err)
+		_, err = service.Validate("revoked", Client{})
+		assert.Equal(ErrInvalidSession, err)
+	})
+
+	t.Run("should serve sessions from the cache and drop them on revocation", func(t *testing.T) {
+		repo := new(MockRepo)
+		service, _ := newService(repo)
+		service.UseCache(time.Minute)
+		repo.On("FindByTokenHash", hashToken("token")).Return(&Session{
+			Id: 5, EmployeeId: 1, LastSeenAt: now, ExpiresAt: now.Add(time.Hour),
+		}, nil).Once()
+		repo.On("RevokeAll", int64(1), ReasonRolesChanged, now).Return([]string{hashToken("token")}, nil)
+		repo.On("FindByTokenHash", hashToken("token")).Return(&Session{
+			Id: 5, EmployeeId: 1, LastSeenAt: now, ExpiresAt: now.Add(time.Hour), RevokedAt: &now,
+		}, nil).Once()
+
+		_, err := service.Validate("token", Client{})
+		assert.Nil(err)
+		_, err = service.Validate("token", Client{})
+		assert.Nil(err)
+		repo.AssertNumberOfCalls(t, "FindByTokenHash", 1)
+
+		assert.Nil(service.AfterAssignmentChange(1, 2))
+		_, err = service.Validate("token", Client{})
+		assert.Equal(ErrInvalidSession, err)
+	})
+
+	t.Run("should revoke sessions of a disabled or terminated employee", func(t *testing.T) {
+		repo := new(MockRepo)
+		service, _ := newService(repo)
+		repo.On("RevokeAll", int64(1), ReasonEmployeeDisabled, now).Return([]string{}, nil)
+		repo.On("RevokeAll", int64(2), ReasonRolesChanged, now).Return([]string{}, nil)
+
+		assert.Nil(service.AfterSave(employee.Response{Id: 1, Status: employee.StatusDisabled}))
+		assert.Nil(service.AfterSave(employee.Response{Id: 3, Status: employee.StatusActive}))
+		service.Handle(lifecycle.Event{Type: lifecycle.EventEmployeeTerminated, EmployeeId: 1})
+		service.Handle(lifecycle.Event{Type: lifecycle.EventEmployeeTransferred, EmployeeId: 2, Granted: []int64{7}})
+		service.Handle(lifecycle.Event{Type: lifecycle.EventEmployeeTransferred, EmployeeId: 4})
+
+		repo.AssertNumberOfCalls(t, "RevokeAll", 3)
+	})
+
+	t.Run("should list active sessions without idle ones", func(t *testing.T) {
+		repo := new(MockRepo)
+		service, _ := newService(repo)
+		repo.On("FindActive", int64(1), now).Return([]*Session{
+			{Id: 1, LastSeenAt: now, ExpiresAt: now.Add(time.Hour)},
+			{Id: 2, LastSeenAt: now.Add(-time.Hour), ExpiresAt: now.Add(time.Hour)},
+		}, nil)
+
+		got, err := service.FindByEmployeeId(1)
+
+		assert.Nil(err)
+		assert.Len(got, 1)
+		assert.Equal(int64(1), got[0].Id)
+	})
+}
+
+func TestSessionHandler(t *testing.T) {
+	assert := assertpackage.New(t)
+	now := time.Now()
+
+	repo := new(MockRepo)
+	service := NewService(repo)
+	var created *Session
+	repo.On("Create", mock.AnythingOfType("*session.Session")).Run(func(args mock.Arguments) {
+		created = args.Get(0).(*Session)
+		created.Id = 7
+	}).Return(nil)
+
+	recorder := httptest.NewRecorder()
+	assert.Nil(service.Start(recorder, httptest.NewRequest(http.MethodPost, "/login", nil), 1, now))
+	cookie := recorder.Result().Cookies()[0]
+	assert.Equal(CookieName, cookie.Name)
+	assert.True(cookie.HttpOnly)
+
+	repo.On("FindByTokenHash", hashToken(cookie.Value)).Return(created, nil)
+	repo.On("FindActive", int64(1), mock.Anything).Return([]*Session{created}, nil)
+	repo.On("Revoke", int64(1), int64(7), ReasonLogout, mock.Anything).Return(created.TokenHash, nil)
+	handler := NewHandler(service)
+
+	serve := func(method string, path string, withCookie bool) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(method, path, nil)
+		if withCookie {
+			request.AddCookie(cookie)
+		}
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	assert.Equal(http.StatusUnauthorized, serve(http.MethodGet, "/", false).Code)
+
+	listed := serve(http.MethodGet, "/", true)
+	assert.Equal(http.StatusOK, listed.Code)
+	assert.Contains(listed.Body.String(), `"current":true`)
+
+	assert.Equal(http.StatusNoContent, serve(http.MethodPost, "/logout", true).Code)
+	repo.AssertCalled(t, "Revoke", int64(1), int64(7), ReasonLogout, mock.Anything)
+}
@@ -0,0 +1,81 @@
+package sod
+
+import "time"
+
+type RuleResponse struct {
+	Id          int64     `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	MaxRoles    int       `json:"max_roles"`
+	RoleIds     []int64   `json:"role_ids"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// RuleRequest правило "не больше MaxRoles ролей из набора"; без MaxRoles роли взаимоисключающие
+type RuleRequest struct {
+	Name        string  `json:"name"`
+	Description string  `json:"description"`
+	MaxRoles    int     `json:"max_roles"`
+	RoleIds     []int64 `json:"role_ids"`
+}
+
+// ExceptionRequest разрешение сотруднику нарушать правило до ExpiresAt
+type ExceptionRequest struct {
+	EmployeeId int64     `json:"employee_id"`
+	Reason     string    `json:"reason"`
+	ApprovedBy *int64    `json:"approved_by"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+type ExceptionResponse struct {
+	Id         int64     `json:"id"`
+	RuleId     int64     `json:"rule_id"`
+	EmployeeId int64     `json:"employee_id"`
+	Reason     string    `json:"reason"`
+	ApprovedBy *int64    `json:"approved_by,omitempty"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type ViolationResponse struct {
+	RuleId     int64   `json:"rule_id"`
+	RuleName   string  `json:"rule_name"`
+	EmployeeId int64   `json:"employee_id"`
+	RoleIds    []int64 `json:"role_ids"`
+	Excepted   bool    `json:"excepted"`
+}
+
+func (r *Rule) ToResponse() *RuleResponse {
+	return &RuleResponse{
+		Id:          r.Id,
+		Name:        r.Name,
+		Description: r.Description,
+		MaxRoles:    r.MaxRoles,
+		RoleIds:     r.RoleIds,
+		CreatedAt:   r.CreatedAt,
+		UpdatedAt:   r.UpdatedAt,
+	}
+}
+
+func (e *Exception) ToResponse() *ExceptionResponse {
+	return &ExceptionResponse{
+		Id:         e.Id,
+		RuleId:     e.RuleId,
+		EmployeeId: e.EmployeeId,
+		Reason:     e.Reason,
+		ApprovedBy: e.ApprovedBy,
+		ExpiresAt:  e.ExpiresAt,
+		CreatedAt:  e.CreatedAt,
+	}
+}
+
+func (v *Violation) ToResponse() *ViolationResponse {
+	return &ViolationResponse{
+		RuleId:     v.RuleId,
+		RuleName:   v.RuleName,
+		EmployeeId: v.EmployeeId,
+		RoleIds:    v.RoleIds,
+		Excepted:   v.Excepted,
+	}
+}
@@ -0,0 +1,100 @@
+package sod
+
+import (
+	"idm/inner/httpapi"
+	"net/http"
+)
+
+// statuses коды ответа для ошибок сервиса
+var statuses = httpapi.Statuses{
+	{Err: ErrInvalidRule, Code: http.StatusBadRequest},
+	{Err: ErrInvalidUntil, Code: http.StatusBadRequest},
+}
+
+// Handler правила разделения полномочий, исключения из них и отчёт о нарушениях
+type Handler struct {
+	service *Service
+	mux     *http.ServeMux
+}
+
+func NewHandler(service *Service) *Handler {
+	h := &Handler{service: service, mux: http.NewServeMux()}
+
+	h.mux.HandleFunc("GET /rules", h.list)
+	h.mux.HandleFunc("POST /rules", h.create)
+	h.mux.HandleFunc("GET /rules/{id}", h.get)
+	h.mux.HandleFunc("DELETE /rules/{id}", h.remove)
+	h.mux.HandleFunc("POST /rules/{id}/exceptions", h.grantException)
+	h.mux.HandleFunc("GET /violations", h.violations)
+
+	return h
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+func (h *Handler) list(w http.ResponseWriter, r *http.Request) {
+	rules, err := h.service.FindAll()
+	if rules == nil {
+		rules = []RuleResponse{}
+	}
+	httpapi.Write(w, http.StatusOK, rules, err, statuses)
+}
+
+// create без max_roles правило делает роли набора взаимоисключающими
+func (h *Handler) create(w http.ResponseWriter, r *http.Request) {
+	var request RuleRequest
+	if !httpapi.Decode(w, r, &request) {
+		return
+	}
+	maxRoles := request.MaxRoles
+	if maxRoles == 0 {
+		maxRoles = 1
+	}
+
+	rule, err := h.service.Create(request.Name, request.Description, maxRoles, request.RoleIds)
+	httpapi.Write(w, http.StatusCreated, rule, err, statuses)
+}
+
+func (h *Handler) get(w http.ResponseWriter, r *http.Request) {
+	id, ok := httpapi.PathId(w, r, "id")
+	if !ok {
+		return
+	}
+
+	rule, err := h.service.FindById(id)
+	httpapi.Write(w, http.StatusOK, rule, err, statuses)
+}
+
+func (h *Handler) remove(w http.ResponseWriter, r *http.Request) {
+	id, ok := httpapi.PathId(w, r, "id")
+	if !ok {
+		return
+	}
+
+	httpapi.Write(w, http.StatusNoContent, nil, h.service.Remove(id), statuses)
+}
+
+func (h *Handler) grantException(w http.ResponseWriter, r *http.Request) {
+	id, ok := httpapi.PathId(w, r, "id")
+	if !ok {
+		return
+	}
+	var request ExceptionRequest
+	if !httpapi.Decode(w, r, &request) {
+		return
+	}
+
+	exception, err := h.service.GrantException(id, request.EmployeeId, request.Reason, request.ApprovedBy,
+		request.ExpiresAt)
+	httpapi.Write(w, http.StatusCreated, exception, err, statuses)
+}
+
+func (h *Handler) violations(w http.ResponseWriter, r *http.Request) {
+	violations, err := h.service.Violations()
+	if violations == nil {
+		violations = []ViolationResponse{}
+	}
+	httpapi.Write(w, http.StatusOK, violations, err, statuses)
+}
@@ -0,0 +1,209 @@
+package sod
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"idm/inner/database"
+	"time"
+)
+
+// Rule правило разделения полномочий: сотрудник не может одновременно держать
+// больше MaxRoles ролей из набора RoleIds
+type Rule struct {
+	Id          int64         `db:"id"`
+	Name        string        `db:"name"`
+	Description string        `db:"description"`
+	MaxRoles    int           `db:"max_roles"`
+	RoleIds     pq.Int64Array `db:"role_ids"`
+	CreatedAt   time.Time     `db:"created_at"`
+	UpdatedAt   time.Time     `db:"updated_at"`
+}
+
+// Exception разрешённое на время нарушение правила для конкретного сотрудника
+type Exception struct {
+	Id         int64     `db:"id"`
+	RuleId     int64     `db:"rule_id"`
+	EmployeeId int64     `db:"employee_id"`
+	Reason     string    `db:"reason"`
+	ApprovedBy *int64    `db:"approved_by"`
+	ExpiresAt  time.Time `db:"expires_at"`
+	CreatedAt  time.Time `db:"created_at"`
+}
+
+// Violation сотрудник, который держит больше ролей из набора, чем разрешает правило
+type Violation struct {
+	RuleId     int64         `db:"rule_id"`
+	RuleName   string        `db:"rule_name"`
+	EmployeeId int64         `db:"employee_id"`
+	RoleIds    pq.Int64Array `db:"role_ids"`
+	Excepted   bool          `db:"excepted"`
+}
+
+const selectRules = `SELECT r.*,
+	COALESCE(array_agg(rr.role_id ORDER BY rr.role_id) FILTER (WHERE rr.role_id IS NOT NULL), '{}') AS role_ids
+	FROM sod_rules r LEFT JOIN sod_rule_roles rr ON rr.rule_id = r.id`
+
+type Repository struct {
+	db *sqlx.DB
+}
+
+func NewRepository(db *sqlx.DB) *Repository {
+	return &Repository{db: db}
+}
+
+func (r *Repository) FindById(id int64) (*Rule, error) {
+	var rule Rule
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := r.db.GetContext(ctx, &rule, selectRules+" WHERE r.id = $1 GROUP BY r.id", id)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, database.ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &rule, err
+}
+
+func (r *Repository) FindAll() ([]*Rule, error) {
+	var rules []*Rule
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := r.db.SelectContext(ctx, &rules, selectRules+" GROUP BY r.id ORDER BY r.id")
+
+	return rules, err
+}
+
+// FindByRoleId правила, в набор которых входит роль
+func (r *Repository) FindByRoleId(roleId int64) ([]*Rule, error) {
+	var rules []*Rule
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := r.db.SelectContext(ctx, &rules,
+		selectRules+" WHERE r.id IN (SELECT rule_id FROM sod_rule_roles WHERE role_id = $1) GROUP BY r.id ORDER BY r.id",
+		roleId)
+
+	return rules, err
+}
+
+func (r *Repository) FindEmployeeRoleIds(employeeId int64) ([]int64, error) {
+	var ids []int64
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := r.db.SelectContext(ctx, &ids,
+		"SELECT role_id FROM employee_roles WHERE employee_id = $1 ORDER BY role_id", employeeId)
+
+	return ids, err
+}
+
+func (r *Repository) Create(rule *Rule) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	err = tx.QueryRowContext(ctx,
+		"INSERT INTO sod_rules (name, description, max_roles) VALUES ($1, $2, $3) RETURNING id, created_at, updated_at",
+		rule.Name, rule.Description, rule.MaxRoles,
+	).Scan(&rule.Id, &rule.CreatedAt, &rule.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx,
+		"INSERT INTO sod_rule_roles (rule_id, role_id) SELECT $1, unnest($2::BIGINT[])",
+		rule.Id, rule.RoleIds)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *Repository) Remove(id int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := r.db.ExecContext(ctx, "DELETE FROM sod_rules WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+
+	count, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return database.ErrRecordNotFound
+	}
+
+	return nil
+}
+
+func (r *Repository) CreateException(exception *Exception) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return r.db.QueryRowContext(ctx,
+		`INSERT INTO sod_exceptions (rule_id, employee_id, reason, approved_by, expires_at)
+		VALUES ($1, $2, $3, $4, $5) RETURNING id, created_at`,
+		exception.RuleId, exception.EmployeeId, exception.Reason, exception.ApprovedBy, exception.ExpiresAt,
+	).Scan(&exception.Id, &exception.CreatedAt)
+}
+
+// HasActiveException есть ли у сотрудника не истёкшее исключение из правила
+func (r *Repository) HasActiveException(ruleId int64, employeeId int64, now time.Time) (bool, error) {
+	var exists bool
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := r.db.GetContext(ctx, &exists,
+		"SELECT EXISTS (SELECT 1 FROM sod_exceptions WHERE rule_id = $1 AND employee_id = $2 AND expires_at > $3)",
+		ruleId, employeeId, now)
+
+	return exists, err
+}
+
+// FindViolations все существующие нарушения правил по всем сотрудникам
+func (r *Repository) FindViolations(now time.Time) ([]*Violation, error) {
+	var violations []*Violation
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := r.db.SelectContext(ctx, &violations,
+		`SELECT r.id AS rule_id, r.name AS rule_name, er.employee_id,
+			array_agg(er.role_id ORDER BY er.role_id) AS role_ids,
+			EXISTS (
+				SELECT 1 FROM sod_exceptions x
+				WHERE x.rule_id = r.id AND x.employee_id = er.employee_id AND x.expires_at > $1
+			) AS excepted
+		FROM sod_rules r
+		JOIN sod_rule_roles rr ON rr.rule_id = r.id
+		JOIN employee_roles er ON er.role_id = rr.role_id
+		GROUP BY r.id, r.name, r.max_roles, er.employee_id
+		HAVING count(*) > r.max_roles
+		ORDER BY r.id, er.employee_id`,
+		now)
+
+	return violations, err
+}
@@ -0,0 +1,199 @@
+package sod
+
+import (
+	"errors"
+	"fmt"
+	"idm/inner/database"
+	"slices"
+	"time"
+)
+
+var (
+	// ErrViolation возвращают и проверка сервиса, и триггер базы данных при выдаче роли
+	ErrViolation    = database.ErrSodViolation
+	ErrInvalidRule  = errors.New("invalid separation of duties rule")
+	ErrInvalidUntil = errors.New("exception expiry must be in the future")
+)
+
+// ViolationError описывает, какое правило нарушит выдача роли
+type ViolationError struct {
+	RuleId     int64
+	RuleName   string
+	EmployeeId int64
+	RoleId     int64
+}
+
+func (e *ViolationError) Error() string {
+	return fmt.Sprintf("%s: role %d conflicts with rule %q for employee %d",
+		ErrViolation, e.RoleId, e.RuleName, e.EmployeeId)
+}
+
+func (e *ViolationError) Unwrap() error {
+	return ErrViolation
+}
+
+type Repo interface {
+	FindById(id int64) (*Rule, error)
+	FindAll() ([]*Rule, error)
+	FindByRoleId(roleId int64) ([]*Rule, error)
+	FindEmployeeRoleIds(employeeId int64) ([]int64, error)
+	Create(rule *Rule) error
+	Remove(id int64) error
+	CreateException(exception *Exception) error
+	HasActiveException(ruleId int64, employeeId int64, now time.Time) (bool, error)
+	FindViolations(now time.Time) ([]*Violation, error)
+}
+
+// Service хранит правила разделения полномочий и проверяет по ним выдачу ролей.
+// Реализует role.AssignmentGuard.
+type Service struct {
+	repo Repo
+	now  func() time.Time
+}
+
+func NewService(repository Repo) *Service {
+	return &Service{repo: repository, now: time.Now}
+}
+
+func (s *Service) FindById(id int64) (RuleResponse, error) {
+	rule, err := s.repo.FindById(id)
+	if err != nil {
+		return RuleResponse{}, fmt.Errorf("error finding sod rule with id %d: %w", id, err)
+	}
+
+	return *rule.ToResponse(), nil
+}
+
+func (s *Service) FindAll() ([]RuleResponse, error) {
+	rules, err := s.repo.FindAll()
+	if err != nil {
+		return nil, fmt.Errorf("error finding all sod rules: %w", err)
+	}
+
+	var responses []RuleResponse
+	for _, rule := range rules {
+		responses = append(responses, *rule.ToResponse())
+	}
+
+	return responses, nil
+}
+
+// CreateExclusive создать правило взаимоисключающих ролей: из набора можно держать только одну
+func (s *Service) CreateExclusive(name string, description string, roleIds []int64) (RuleResponse, error) {
+	return s.Create(name, description, 1, roleIds)
+}
+
+// Create создать правило "не больше maxRoles ролей из набора"
+func (s *Service) Create(name string, description string, maxRoles int, roleIds []int64) (RuleResponse, error) {
+	roleIds = slices.Compact(slices.Sorted(slices.Values(roleIds)))
+	if name == "" || maxRoles < 1 || len(roleIds) <= maxRoles {
+		return RuleResponse{}, fmt.Errorf("error creating sod rule %q with max %d of %d roles: %w",
+			name, maxRoles, len(roleIds), ErrInvalidRule)
+	}
+
+	rule := &Rule{Name: name, Description: description, MaxRoles: maxRoles, RoleIds: roleIds}
+	err := s.repo.Create(rule)
+	if err != nil {
+		return RuleResponse{}, fmt.Errorf("error creating sod rule: %w", err)
+	}
+
+	return *rule.ToResponse(), nil
+}
+
+func (s *Service) Remove(id int64) error {
+	err := s.repo.Remove(id)
+	if err != nil {
+		return fmt.Errorf("error removing sod rule with id %d: %w", id, err)
+	}
+
+	return nil
+}
+
+// GrantException разрешить сотруднику нарушать правило до момента until
+func (s *Service) GrantException(
+	ruleId int64,
+	employeeId int64,
+	reason string,
+	approvedBy *int64,
+	until time.Time,
+) (ExceptionResponse, error) {
+	if !until.After(s.now()) {
+		return ExceptionResponse{}, fmt.Errorf("error granting sod exception: %w", ErrInvalidUntil)
+	}
+	if _, err := s.repo.FindById(ruleId); err != nil {
+		return ExceptionResponse{}, fmt.Errorf("error granting exception from sod rule with id %d: %w", ruleId, err)
+	}
+
+	exception := &Exception{
+		RuleId:     ruleId,
+		EmployeeId: employeeId,
+		Reason:     reason,
+		ApprovedBy: approvedBy,
+		ExpiresAt:  until,
+	}
+	err := s.repo.CreateException(exception)
+	if err != nil {
+		return ExceptionResponse{}, fmt.Errorf("error granting sod exception: %w", err)
+	}
+
+	return *exception.ToResponse(), nil
+}
+
+// CheckAssignment проверить, не нарушит ли выдача роли правила.
+// Нарушение допускается, только если у сотрудника есть действующее исключение.
+// Роли из pending считаются уже выданными: так пакет выдачи проверяется целиком.
+func (s *Service) CheckAssignment(employeeId int64, roleId int64, pending []int64) error {
+	rules, err := s.repo.FindByRoleId(roleId)
+	if err != nil {
+		return fmt.Errorf("error finding sod rules for role with id %d: %w", roleId, err)
+	}
+	if len(rules) == 0 {
+		return nil
+	}
+
+	held, err := s.repo.FindEmployeeRoleIds(employeeId)
+	if err != nil {
+		return fmt.Errorf("error finding roles of employee with id %d: %w", employeeId, err)
+	}
+	held = slices.Concat(held, pending)
+	if slices.Contains(held, roleId) {
+		return nil
+	}
+
+	for _, rule := range rules {
+		count := 1
+		for _, id := range held {
+			if slices.Contains(rule.RoleIds, id) {
+				count++
+			}
+		}
+		if count <= rule.MaxRoles {
+			continue
+		}
+
+		excepted, err := s.repo.HasActiveException(rule.Id, employeeId, s.now())
+		if err != nil {
+			return fmt.Errorf("error finding sod exceptions for employee with id %d: %w", employeeId, err)
+		}
+		if !excepted {
+			return &ViolationError{RuleId: rule.Id, RuleName: rule.Name, EmployeeId: employeeId, RoleId: roleId}
+		}
+	}
+
+	return nil
+}
+
+// Violations отчёт о существующих нарушениях по всем сотрудникам, включая покрытые исключениями
+func (s *Service) Violations() ([]ViolationResponse, error) {
+	violations, err := s.repo.FindViolations(s.now())
+	if err != nil {
+		return nil, fmt.Errorf("error finding sod violations: %w", err)
+	}
+
+	var responses []ViolationResponse
+	for _, violation := range violations {
+		responses = append(responses, *violation.ToResponse())
+	}
+
+	return responses, nil
+}
@@ -0,0 +1,261 @@
+package sod
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/lib/pq"
+	assertpackage "github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"idm/inner/database"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type MockRepo struct {
+	mock.Mock
+}
+
+func (m *MockRepo) FindById(id int64) (*Rule, error) {
+	args := m.Called(id)
+	return args.Get(0).(*Rule), args.Error(1)
+}
+
+func (m *MockRepo) FindAll() ([]*Rule, error) {
+	args := m.Called()
+	return args.Get(0).([]*Rule), args.Error(1)
+}
+
+func (m *MockRepo) FindByRoleId(roleId int64) ([]*Rule, error) {
+	args := m.Called(roleId)
+	return args.Get(0).([]*Rule), args.Error(1)
+}
+
+func (m *MockRepo) FindEmployeeRoleIds(employeeId int64) ([]int64, error) {
+	args := m.Called(employeeId)
+	return args.Get(0).([]int64), args.Error(1)
+}
+
+func (m *MockRepo) Create(rule *Rule) error {
+	args := m.Called(rule)
+	return args.Error(0)
+}
+
+func (m *MockRepo) Remove(id int64) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockRepo) CreateException(exception *Exception) error {
+	args := m.Called(exception)
+	return args.Error(0)
+}
+
+func (m *MockRepo) HasActiveException(ruleId int64, employeeId int64, now time.Time) (bool, error) {
+	args := m.Called(ruleId, employeeId, now)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRepo) FindViolations(now time.Time) ([]*Violation, error) {
+	args := m.Called(now)
+	return args.Get(0).([]*Violation), args.Error(1)
+}
+
+func TestSodService(t *testing.T) {
+	assert := assertpackage.New(t)
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	// payments-create = 1, payments-approve = 2
+	payments := &Rule{Id: 7, Name: "payments", MaxRoles: 1, RoleIds: []int64{1, 2}}
+
+	newService := func() (*Service, *MockRepo) {
+		repo := &MockRepo{}
+		service := NewService(repo)
+		service.now = func() time.Time { return now }
+
+		return service, repo
+	}
+
+	t.Run("CheckAssignment should allow roles without rules", func(t *testing.T) {
+		service, repo := newService()
+
+		repo.On("FindByRoleId", int64(3)).Return([]*Rule{}, nil)
+
+		assert.NoError(service.CheckAssignment(1, 3, nil))
+		repo.AssertNotCalled(t, "FindEmployeeRoleIds", mock.Anything)
+	})
+
+	t.Run("CheckAssignment should block mutually exclusive roles", func(t *testing.T) {
+		service, repo := newService()
+
+		repo.On("FindByRoleId", int64(2)).Return([]*Rule{payments}, nil)
+		repo.On("FindEmployeeRoleIds", int64(5)).Return([]int64{1, 3}, nil)
+		repo.On("HasActiveException", int64(7), int64(5), now).Return(false, nil)
+
+		err := service.CheckAssignment(5, 2, nil)
+
+		assert.ErrorIs(err, ErrViolation)
+		var violation *ViolationError
+		assert.True(errors.As(err, &violation))
+		assert.Equal("payments", violation.RuleName)
+	})
+
+	t.Run("CheckAssignment should allow violation covered by an exception", func(t *testing.T) {
+		service, repo := newService()
+
+		repo.On("FindByRoleId", int64(2)).Return([]*Rule{payments}, nil)
+		repo.On("FindEmployeeRoleIds", int64(5)).Return([]int64{1}, nil)
+		repo.On("HasActiveException", int64(7), int64(5), now).Return(true, nil)
+
+		assert.NoError(service.CheckAssignment(5, 2, nil))
+	})
+
+	t.Run("CheckAssignment should respect max-N-of-set rules", func(t *testing.T) {
+		service, repo := newService()
+		rule := &Rule{Id: 8, Name: "treasury", MaxRoles: 2, RoleIds: []int64{1, 2, 3}}
+
+		repo.On("FindByRoleId", int64(3)).Return([]*Rule{rule}, nil)
+		repo.On("FindEmployeeRoleIds", int64(5)).Return([]int64{1}, nil)
+
+		assert.NoError(service.CheckAssignment(5, 3, nil))
+		repo.AssertNotCalled(t, "HasActiveException", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("CheckAssignment should ignore roles already held", func(t *testing.T) {
+		service, repo := newService()
+
+		repo.On("FindByRoleId", int64(2)).Return([]*Rule{payments}, nil)
+		repo.On("FindEmployeeRoleIds", int64(5)).Return([]int64{1, 2}, nil)
+
+		assert.NoError(service.CheckAssignment(5, 2, nil))
+	})
+
+	t.Run("CheckAssignment should count pending roles as held", func(t *testing.T) {
+		service, repo := newService()
+
+		repo.On("FindByRoleId", int64(2)).Return([]*Rule{payments}, nil)
+		repo.On("FindEmployeeRoleIds", int64(5)).Return([]int64{}, nil)
+		repo.On("HasActiveException", int64(7), int64(5), now).Return(false, nil)
+
+		assert.ErrorIs(service.CheckAssignment(5, 2, []int64{1}), ErrViolation)
+	})
+
+	t.Run("Create should reject rules with too few roles", func(t *testing.T) {
+		service, repo := newService()
+
+		_, err := service.Create("payments", "", 1, []int64{1, 1})
+
+		assert.ErrorIs(err, ErrInvalidRule)
+		repo.AssertNotCalled(t, "Create", mock.Anything)
+	})
+
+	t.Run("CreateExclusive should create a rule with deduplicated roles", func(t *testing.T) {
+		service, repo := newService()
+
+		repo.On("Create", mock.AnythingOfType("*sod.Rule")).Return(nil)
+		got, err := service.CreateExclusive("payments", "create and approve", []int64{2, 1, 2})
+
+		assert.NoError(err)
+		assert.Equal(1, got.MaxRoles)
+		assert.Equal([]int64{1, 2}, got.RoleIds)
+	})
+
+	t.Run("GrantException should reject expiry in the past", func(t *testing.T) {
+		service, _ := newService()
+
+		_, err := service.GrantException(7, 5, "audit", nil, now.Add(-time.Hour))
+
+		assert.ErrorIs(err, ErrInvalidUntil)
+	})
+
+	t.Run("GrantException should reject unknown rules", func(t *testing.T) {
+		service, repo := newService()
+
+		repo.On("FindById", int64(42)).Return((*Rule)(nil), database.ErrRecordNotFound)
+		_, err := service.GrantException(42, 5, "audit", nil, now.Add(time.Hour))
+
+		assert.ErrorIs(err, database.ErrRecordNotFound)
+		repo.AssertNotCalled(t, "CreateException", mock.Anything)
+	})
+
+	t.Run("Remove should report a missing rule", func(t *testing.T) {
+		service, repo := newService()
+
+		repo.On("Remove", int64(42)).Return(database.ErrRecordNotFound)
+
+		assert.ErrorIs(service.Remove(42), database.ErrRecordNotFound)
+	})
+
+	t.Run("ErrViolation should match the error of the database trigger", func(t *testing.T) {
+		err := database.MapError(&pq.Error{Code: "IDM01", Message: `role 2 conflicts with rule "payments" for employee 5`})
+
+		assert.ErrorIs(err, ErrViolation)
+		assert.Contains(err.Error(), "payments")
+		assert.NotErrorIs(database.MapError(&pq.Error{Code: "23505"}), ErrViolation)
+	})
+
+	t.Run("Violations should return the report", func(t *testing.T) {
+		service, repo := newService()
+
+		repo.On("FindViolations", now).Return([]*Violation{
+			{RuleId: 7, RuleName: "payments", EmployeeId: 5, RoleIds: []int64{1, 2}},
+		}, nil)
+		got, err := service.Violations()
+
+		assert.NoError(err)
+		assert.Len(got, 1)
+		assert.Equal(int64(5), got[0].EmployeeId)
+		assert.False(got[0].Excepted)
+	})
+}
+
+func TestSodHandler(t *testing.T) {
+	var assert = assertpackage.New(t)
+
+	serve := func(repo *MockRepo, method string, target string, body string) *httptest.ResponseRecorder {
+		service := NewService(repo)
+		service.now = func() time.Time { return time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC) }
+		recorder := httptest.NewRecorder()
+		NewHandler(service).ServeHTTP(recorder, httptest.NewRequest(method, target, strings.NewReader(body)))
+		return recorder
+	}
+
+	t.Run("should create an exclusive rule when max_roles is omitted", func(t *testing.T) {
+		repo := &MockRepo{}
+		repo.On("Create", mock.MatchedBy(func(rule *Rule) bool { return rule.MaxRoles == 1 })).Return(nil)
+
+		recorder := serve(repo, http.MethodPost, "/rules", `{"name":"payments","role_ids":[1,2]}`)
+
+		assert.Equal(http.StatusCreated, recorder.Code)
+		var rule RuleResponse
+		assert.Nil(json.Unmarshal(recorder.Body.Bytes(), &rule))
+		assert.Equal([]int64{1, 2}, rule.RoleIds)
+	})
+
+	t.Run("should return empty lists as arrays", func(t *testing.T) {
+		repo := &MockRepo{}
+		repo.On("FindAll").Return([]*Rule{}, nil)
+		repo.On("FindViolations", mock.Anything).Return([]*Violation{}, nil)
+
+		assert.JSONEq(`[]`, serve(repo, http.MethodGet, "/rules", "").Body.String())
+		assert.JSONEq(`[]`, serve(repo, http.MethodGet, "/violations", "").Body.String())
+	})
+
+	t.Run("should map errors to statuses", func(t *testing.T) {
+		repo := &MockRepo{}
+		repo.On("Remove", int64(42)).Return(database.ErrRecordNotFound)
+		repo.On("Remove", int64(7)).Return(nil)
+		repo.On("FindById", int64(42)).Return((*Rule)(nil), database.ErrRecordNotFound)
+
+		assert.Equal(http.StatusBadRequest, serve(repo, http.MethodPost, "/rules", `{"name":"payments","role_ids":[1]}`).Code)
+		assert.Equal(http.StatusNotFound, serve(repo, http.MethodDelete, "/rules/42", "").Code)
+		assert.Equal(http.StatusNoContent, serve(repo, http.MethodDelete, "/rules/7", "").Code)
+		assert.Equal(http.StatusNotFound, serve(repo, http.MethodGet, "/rules/42", "").Code)
+		assert.Equal(http.StatusBadRequest, serve(repo, http.MethodPost, "/rules/7/exceptions",
+			`{"employee_id":5,"reason":"audit","expires_at":"2024-01-01T00:00:00Z"}`).Code)
+		assert.Equal(http.StatusNotFound, serve(repo, http.MethodPost, "/rules/42/exceptions",
+			`{"employee_id":5,"reason":"audit","expires_at":"2026-01-01T00:00:00Z"}`).Code)
+	})
+}
@@ -0,0 +1,231 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"idm/inner/outbox"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Заголовки запроса к подписчику
+const (
+	HeaderDelivery  = "X-Webhook-Delivery"
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderEventId   = "X-Webhook-Event-Id"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+const (
+	DefaultMaxAttempts = 8
+	DefaultMinBackoff  = 30 * time.Second
+	DefaultMaxBackoff  = time.Hour
+	// DefaultTolerance насколько метка времени запроса может отличаться от часов получателя
+	DefaultTolerance = 5 * time.Minute
+	batchSize        = 50
+	pollInterval     = 5 * time.Second
+	requestTimeout   = 10 * time.Second
+	// maxErrorBody сколько байт ответа подписчика сохранять в журнале
+	maxErrorBody = 512
+)
+
+var (
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+	ErrStaleTimestamp   = errors.New("webhook timestamp is outside the tolerance")
+)
+
+// Sign подпись HMAC-SHA256 строки "<timestamp>.<тело>" в виде sha256=<hex>. Метка времени входит
+// в подпись, поэтому перехваченный запрос нельзя повторить позже допуска.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10) + "."))
+	mac.Write(body)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify проверить подпись запроса на стороне получателя
+func Verify(secret string, header http.Header, body []byte, tolerance time.Duration, now time.Time) error {
+	timestamp, err := strconv.ParseInt(header.Get(HeaderTimestamp), 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	if age := now.Sub(time.Unix(timestamp, 0)); age > tolerance || age < -tolerance {
+		return ErrStaleTimestamp
+	}
+	if !hmac.Equal([]byte(header.Get(HeaderSignature)), []byte(Sign(secret, timestamp, body))) {
+		return ErrInvalidSignature
+	}
+
+	return nil
+}
+
+// Dispatcher издатель outbox: превращает событие в доставки подходящим подпискам.
+// Сама отправка выполняется Deliverer, поэтому медленный подписчик не задерживает outbox.
+type Dispatcher struct {
+	repo Repo
+}
+
+func NewDispatcher(repository Repo) *Dispatcher {
+	return &Dispatcher{repo: repository}
+}
+
+func (d *Dispatcher) Publish(_ context.Context, event outbox.Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	if err := d.repo.Enqueue(event, payload); err != nil {
+		return fmt.Errorf("error enqueuing webhook deliveries of event with id %d: %w", event.Id, err)
+	}
+
+	return nil
+}
+
+// Deliverer отправляет доставки подписчикам с повторами и переводит их в dead после maxAttempts неудач
+type Deliverer struct {
+	repo        Repo
+	client      *http.Client
+	maxAttempts int
+	minBackoff  time.Duration
+	maxBackoff  time.Duration
+	now         func() time.Time
+}
+
+func NewDeliverer(repository Repo, client *http.Client) *Deliverer {
+	if client == nil {
+		client = &http.Client{Timeout: requestTimeout}
+	}
+
+	return &Deliverer{
+		repo:        repository,
+		client:      client,
+		maxAttempts: DefaultMaxAttempts,
+		minBackoff:  DefaultMinBackoff,
+		maxBackoff:  DefaultMaxBackoff,
+		now:         time.Now,
+	}
+}
+
+// SetRetry задать число попыток и паузы между ними; пауза удваивается с каждой попыткой
+func (d *Deliverer) SetRetry(maxAttempts int, minimum, maximum time.Duration) {
+	d.maxAttempts, d.minBackoff, d.maxBackoff = maxAttempts, minimum, maximum
+}
+
+// Run отправлять доставки, пока не отменён ctx
+func (d *Deliverer) Run(ctx context.Context) {
+	for {
+		count, err := d.RunOnce(ctx)
+		if err != nil {
+			log.Printf("error delivering webhooks: %v", err)
+		}
+		if err == nil && count == batchSize {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+// RunOnce отправить один пакет доставок, срок которых наступил, и вернуть их число
+func (d *Deliverer) RunOnce(ctx context.Context) (int, error) {
+	batch, err := d.repo.Begin()
+	if errors.Is(err, outbox.ErrBusy) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("error starting webhook batch: %w", err)
+	}
+	defer func() { _ = batch.Rollback() }()
+
+	due, err := batch.Due(batchSize, d.now())
+	if err != nil {
+		return 0, fmt.Errorf("error finding due webhook deliveries: %w", err)
+	}
+
+	for _, delivery := range due {
+		statusCode, err := d.send(ctx, delivery)
+		if ctx.Err() != nil {
+			break
+		}
+		if err == nil {
+			if err := batch.Delivered(delivery.Id, *statusCode, d.now()); err != nil {
+				return 0, fmt.Errorf("error marking webhook delivery with id %d as delivered: %w", delivery.Id, err)
+			}
+			continue
+		}
+
+		attempts := delivery.Attempts + 1
+		dead := attempts >= d.maxAttempts
+		next := d.now().Add(d.backoff(attempts))
+		if err := batch.Failed(delivery.Id, attempts, next, statusCode, err.Error(), dead); err != nil {
+			return 0, fmt.Errorf("error postponing webhook delivery with id %d: %w", delivery.Id, err)
+		}
+	}
+
+	if err := batch.Commit(); err != nil {
+		return 0, fmt.Errorf("error committing webhook batch: %w", err)
+	}
+
+	return len(due), nil
+}
+
+// send отправить подписанный запрос; код ответа nil, если ответа не было
+func (d *Deliverer) send(ctx context.Context, delivery *Due) (*int, error) {
+	timestamp := d.now().Unix()
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.Url, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("User-Agent", "idm-webhooks")
+	request.Header.Set(HeaderDelivery, strconv.FormatInt(delivery.Id, 10))
+	request.Header.Set(HeaderEvent, delivery.EventType)
+	request.Header.Set(HeaderEventId, strconv.FormatInt(delivery.EventId, 10))
+	request.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	request.Header.Set(HeaderSignature, Sign(delivery.Secret, timestamp, delivery.Payload))
+
+	response, err := d.client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = response.Body.Close() }()
+
+	statusCode := response.StatusCode
+	if statusCode >= 200 && statusCode <= 299 {
+		_, _ = io.Copy(io.Discard, io.LimitReader(response.Body, 64<<10))
+		return &statusCode, nil
+	}
+
+	body, _ := io.ReadAll(io.LimitReader(response.Body, maxErrorBody))
+	message := fmt.Sprintf("subscriber responded with status %d", statusCode)
+	if text := strings.TrimSpace(strings.ToValidUTF8(string(body), "")); text != "" {
+		message += ": " + text
+	}
+
+	return &statusCode, errors.New(message)
+}
+
+func (d *Deliverer) backoff(attempts int) time.Duration {
+	delay := d.minBackoff
+	for i := 1; i < attempts && delay < d.maxBackoff; i++ {
+		delay *= 2
+	}
+
+	return min(delay, d.maxBackoff)
+}
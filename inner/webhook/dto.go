@@ -0,0 +1,95 @@
+package webhook
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type Response struct {
+	Id          int64     `json:"id"`
+	Url         string    `json:"url"`
+	Description string    `json:"description"`
+	EventTypes  []string  `json:"event_types"`
+	Active      bool      `json:"active"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+func (s *Subscription) ToResponse() Response {
+	eventTypes := []string(s.EventTypes)
+	if eventTypes == nil {
+		eventTypes = []string{}
+	}
+
+	return Response{
+		Id:          s.Id,
+		Url:         s.Url,
+		Description: s.Description,
+		EventTypes:  eventTypes,
+		Active:      s.Active,
+		CreatedAt:   s.CreatedAt,
+		UpdatedAt:   s.UpdatedAt,
+	}
+}
+
+// Request данные подписки. Пустой EventTypes – все события; без Active подписка создаётся активной
+// и сохраняет активность при изменении. Secret учитывается только при создании.
+type Request struct {
+	Url         string   `json:"url"`
+	Description string   `json:"description"`
+	EventTypes  []string `json:"event_types"`
+	Active      *bool    `json:"active"`
+	Secret      string   `json:"secret"`
+}
+
+// CreatedResponse подписка с секретом; секрет показывается только при создании и ротации
+type CreatedResponse struct {
+	Response
+	Secret string `json:"secret"`
+}
+
+// DeliveryQuery фильтр журнала доставок
+type DeliveryQuery struct {
+	Status   string
+	BeforeId int64
+	Limit    int
+}
+
+type DeliveryResponse struct {
+	Id             int64           `json:"id"`
+	SubscriptionId int64           `json:"subscription_id"`
+	EventId        int64           `json:"event_id"`
+	EventType      string          `json:"event_type"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  *time.Time      `json:"next_attempt_at,omitempty"`
+	LastStatusCode *int            `json:"last_status_code,omitempty"`
+	LastError      string          `json:"last_error,omitempty"`
+	RedeliveryOf   *int64          `json:"redelivery_of,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+	DeliveredAt    *time.Time      `json:"delivered_at,omitempty"`
+}
+
+func (d *Delivery) ToResponse() DeliveryResponse {
+	var next *time.Time
+	if d.Status == StatusPending {
+		next = &d.NextAttemptAt
+	}
+
+	return DeliveryResponse{
+		Id:             d.Id,
+		SubscriptionId: d.SubscriptionId,
+		EventId:        d.EventId,
+		EventType:      d.EventType,
+		Payload:        d.Payload,
+		Status:         d.Status,
+		Attempts:       d.Attempts,
+		NextAttemptAt:  next,
+		LastStatusCode: d.LastStatusCode,
+		LastError:      d.LastError,
+		RedeliveryOf:   d.RedeliveryOf,
+		CreatedAt:      d.CreatedAt,
+		DeliveredAt:    d.DeliveredAt,
+	}
+}
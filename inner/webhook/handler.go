@@ -0,0 +1,141 @@
+package webhook
+
+import (
+	"idm/inner/httpapi"
+	"net/http"
+	"strconv"
+)
+
+// statuses коды ответа для ошибок сервиса
+var statuses = httpapi.Statuses{
+	{Err: ErrInvalidURL, Code: http.StatusBadRequest},
+	{Err: ErrInvalidEventType, Code: http.StatusBadRequest},
+	{Err: ErrInvalidSecret, Code: http.StatusBadRequest},
+	{Err: ErrInvalidStatus, Code: http.StatusBadRequest},
+}
+
+// Handler управление подписками и журнал доставок
+type Handler struct {
+	service *Service
+	mux     *http.ServeMux
+}
+
+func NewHandler(service *Service) *Handler {
+	h := &Handler{service: service, mux: http.NewServeMux()}
+
+	h.mux.HandleFunc("GET /{$}", h.list)
+	h.mux.HandleFunc("POST /{$}", h.create)
+	h.mux.HandleFunc("GET /{id}", h.get)
+	h.mux.HandleFunc("PUT /{id}", h.update)
+	h.mux.HandleFunc("DELETE /{id}", h.remove)
+	h.mux.HandleFunc("POST /{id}/secret", h.rotateSecret)
+	h.mux.HandleFunc("GET /{id}/deliveries", h.deliveries)
+	h.mux.HandleFunc("POST /{id}/deliveries/{deliveryId}/redeliver", h.redeliver)
+
+	return h
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+func (h *Handler) list(w http.ResponseWriter, r *http.Request) {
+	subscriptions, err := h.service.FindAll()
+	httpapi.Write(w, http.StatusOK, subscriptions, err, statuses)
+}
+
+func (h *Handler) create(w http.ResponseWriter, r *http.Request) {
+	var request Request
+	if !httpapi.Decode(w, r, &request) {
+		return
+	}
+
+	subscription, err := h.service.Create(request)
+	httpapi.Write(w, http.StatusCreated, subscription, err, statuses)
+}
+
+func (h *Handler) get(w http.ResponseWriter, r *http.Request) {
+	id, ok := httpapi.PathId(w, r, "id")
+	if !ok {
+		return
+	}
+
+	subscription, err := h.service.FindById(id)
+	httpapi.Write(w, http.StatusOK, subscription, err, statuses)
+}
+
+func (h *Handler) update(w http.ResponseWriter, r *http.Request) {
+	id, ok := httpapi.PathId(w, r, "id")
+	if !ok {
+		return
+	}
+	var request Request
+	if !httpapi.Decode(w, r, &request) {
+		return
+	}
+
+	subscription, err := h.service.Update(id, request)
+	httpapi.Write(w, http.StatusOK, subscription, err, statuses)
+}
+
+func (h *Handler) remove(w http.ResponseWriter, r *http.Request) {
+	id, ok := httpapi.PathId(w, r, "id")
+	if !ok {
+		return
+	}
+
+	httpapi.Write(w, http.StatusNoContent, nil, h.service.Remove(id), statuses)
+}
+
+func (h *Handler) rotateSecret(w http.ResponseWriter, r *http.Request) {
+	id, ok := httpapi.PathId(w, r, "id")
+	if !ok {
+		return
+	}
+
+	subscription, err := h.service.RotateSecret(id)
+	httpapi.Write(w, http.StatusOK, subscription, err, statuses)
+}
+
+// deliveries журнал доставок: ?status=dead&before=<id>&limit=50
+func (h *Handler) deliveries(w http.ResponseWriter, r *http.Request) {
+	id, ok := httpapi.PathId(w, r, "id")
+	if !ok {
+		return
+	}
+
+	query := DeliveryQuery{Status: r.URL.Query().Get("status")}
+	if value := r.URL.Query().Get("before"); value != "" {
+		before, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || before < 0 {
+			httpapi.WriteError(w, http.StatusBadRequest, "invalid before")
+			return
+		}
+		query.BeforeId = before
+	}
+	if value := r.URL.Query().Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 0 {
+			httpapi.WriteError(w, http.StatusBadRequest, "invalid limit")
+			return
+		}
+		query.Limit = limit
+	}
+
+	deliveries, err := h.service.FindDeliveries(id, query)
+	httpapi.Write(w, http.StatusOK, deliveries, err, statuses)
+}
+
+func (h *Handler) redeliver(w http.ResponseWriter, r *http.Request) {
+	id, ok := httpapi.PathId(w, r, "id")
+	if !ok {
+		return
+	}
+	deliveryId, ok := httpapi.PathId(w, r, "deliveryId")
+	if !ok {
+		return
+	}
+
+	delivery, err := h.service.Redeliver(id, deliveryId)
+	httpapi.Write(w, http.StatusAccepted, delivery, err, statuses)
+}
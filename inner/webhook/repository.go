@@ -0,0 +1,281 @@
+package webhook
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"idm/inner/database"
+	"idm/inner/outbox"
+	"time"
+)
+
+// batchTimeout тайм-аут транзакции пакета: она открыта, пока запросы отправляются подписчикам
+const batchTimeout = 5 * time.Minute
+
+type Subscription struct {
+	Id          int64          `db:"id"`
+	Url         string         `db:"url"`
+	Description string         `db:"description"`
+	EventTypes  pq.StringArray `db:"event_types"`
+	Secret      string         `db:"secret"`
+	Active      bool           `db:"active"`
+	CreatedAt   time.Time      `db:"created_at"`
+	UpdatedAt   time.Time      `db:"updated_at"`
+}
+
+type Delivery struct {
+	Id             int64           `db:"id"`
+	SubscriptionId int64           `db:"subscription_id"`
+	EventId        int64           `db:"event_id"`
+	EventType      string          `db:"event_type"`
+	Payload        json.RawMessage `db:"payload"`
+	Status         string          `db:"status"`
+	Attempts       int             `db:"attempts"`
+	NextAttemptAt  time.Time       `db:"next_attempt_at"`
+	LastStatusCode *int            `db:"last_status_code"`
+	LastError      string          `db:"last_error"`
+	RedeliveryOf   *int64          `db:"redelivery_of"`
+	CreatedAt      time.Time       `db:"created_at"`
+	DeliveredAt    *time.Time      `db:"delivered_at"`
+}
+
+// Due доставка, которую пора отправить, вместе с адресом и секретом подписки
+type Due struct {
+	Id        int64           `db:"id"`
+	EventId   int64           `db:"event_id"`
+	EventType string          `db:"event_type"`
+	Payload   json.RawMessage `db:"payload"`
+	Attempts  int             `db:"attempts"`
+	Url       string          `db:"url"`
+	Secret    string          `db:"secret"`
+}
+
+type Repository struct {
+	db *sqlx.DB
+}
+
+func NewRepository(db *sqlx.DB) *Repository {
+	return &Repository{db: db}
+}
+
+func (r *Repository) FindAll() ([]*Subscription, error) {
+	var subscriptions []*Subscription
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := r.db.SelectContext(ctx, &subscriptions, "SELECT * FROM webhook_subscriptions ORDER BY id")
+
+	return subscriptions, err
+}
+
+func (r *Repository) FindById(id int64) (*Subscription, error) {
+	var subscription Subscription
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := r.db.GetContext(ctx, &subscription, "SELECT * FROM webhook_subscriptions WHERE id = $1", id)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, database.ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &subscription, nil
+}
+
+func (r *Repository) Create(subscription *Subscription) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return r.db.QueryRowContext(ctx,
+		`INSERT INTO webhook_subscriptions (url, description, event_types, secret, active)
+		VALUES ($1, $2, $3, $4, $5) RETURNING id, created_at, updated_at`,
+		subscription.Url, subscription.Description, subscription.EventTypes, subscription.Secret, subscription.Active,
+	).Scan(&subscription.Id, &subscription.CreatedAt, &subscription.UpdatedAt)
+}
+
+func (r *Repository) Update(subscription *Subscription) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := r.db.QueryRowContext(ctx,
+		`UPDATE webhook_subscriptions
+		SET url = $1, description = $2, event_types = $3, active = $4, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $5 RETURNING updated_at`,
+		subscription.Url, subscription.Description, subscription.EventTypes, subscription.Active, subscription.Id,
+	).Scan(&subscription.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.ErrRecordNotFound
+	}
+
+	return err
+}
+
+func (r *Repository) SetSecret(id int64, secret string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := r.db.ExecContext(ctx,
+		"UPDATE webhook_subscriptions SET secret = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2", secret, id)
+
+	return err
+}
+
+func (r *Repository) Remove(id int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := r.db.ExecContext(ctx, "DELETE FROM webhook_subscriptions WHERE id = $1", id)
+
+	return err
+}
+
+func (r *Repository) Enqueue(event outbox.Event, payload []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO webhook_deliveries (subscription_id, event_id, event_type, payload)
+		SELECT s.id, $1, $2, $3 FROM webhook_subscriptions s
+		WHERE s.active AND (cardinality(s.event_types) = 0 OR $2 = ANY(s.event_types))
+		ON CONFLICT (subscription_id, event_id) WHERE redelivery_of IS NULL DO NOTHING`,
+		event.Id, event.Type, string(payload))
+
+	return err
+}
+
+func (r *Repository) FindDeliveries(subscriptionId int64, status string, beforeId int64, limit int) ([]*Delivery, error) {
+	var deliveries []*Delivery
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := r.db.SelectContext(ctx, &deliveries,
+		`SELECT * FROM webhook_deliveries
+		WHERE subscription_id = $1 AND ($2 = '' OR status = $2) AND ($3 = 0 OR id < $3)
+		ORDER BY id DESC LIMIT $4`,
+		subscriptionId, status, beforeId, limit)
+
+	return deliveries, err
+}
+
+func (r *Repository) FindDelivery(id int64) (*Delivery, error) {
+	var delivery Delivery
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := r.db.GetContext(ctx, &delivery, "SELECT * FROM webhook_deliveries WHERE id = $1", id)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, database.ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &delivery, nil
+}
+
+func (r *Repository) Redeliver(id int64) (*Delivery, error) {
+	var delivery Delivery
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := r.db.GetContext(ctx, &delivery,
+		`INSERT INTO webhook_deliveries (subscription_id, event_id, event_type, payload, redelivery_of)
+		SELECT subscription_id, event_id, event_type, payload, id FROM webhook_deliveries WHERE id = $1
+		RETURNING *`, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, database.ErrRecordNotFound
+	}
+
+	return &delivery, err
+}
+
+type batch struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	tx     *sqlx.Tx
+}
+
+// Begin пакеты отправляются по одному во всех экземплярах, чтобы доставка не ушла дважды
+func (r *Repository) Begin() (Batch, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), batchTimeout)
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	b := &batch{ctx: ctx, cancel: cancel, tx: tx}
+
+	var locked bool
+	if err := tx.GetContext(ctx, &locked, "SELECT pg_try_advisory_xact_lock(hashtext('idm_webhooks'))"); err != nil {
+		_ = b.Rollback()
+		return nil, err
+	}
+	if !locked {
+		_ = b.Rollback()
+		return nil, outbox.ErrBusy
+	}
+
+	return b, nil
+}
+
+// Due доставки неактивных подписок ждут, пока подписку не включат снова
+func (b *batch) Due(limit int, now time.Time) ([]*Due, error) {
+	var due []*Due
+	err := b.tx.SelectContext(b.ctx, &due,
+		`SELECT d.id, d.event_id, d.event_type, d.payload, d.attempts, s.url, s.secret
+		FROM webhook_deliveries d JOIN webhook_subscriptions s ON s.id = d.subscription_id
+		WHERE d.status = 'pending' AND d.next_attempt_at <= $1 AND s.active
+		ORDER BY d.id LIMIT $2`, now, limit)
+
+	return due, err
+}
+
+func (b *batch) Delivered(id int64, statusCode int, at time.Time) error {
+	_, err := b.tx.ExecContext(b.ctx,
+		`UPDATE webhook_deliveries
+		SET status = 'delivered', attempts = attempts + 1, last_status_code = $1, last_error = '', delivered_at = $2
+		WHERE id = $3`, statusCode, at, id)
+
+	return err
+}
+
+func (b *batch) Failed(id int64, attempts int, next time.Time, statusCode *int, reason string, dead bool) error {
+	status := StatusPending
+	if dead {
+		status = StatusDead
+	}
+
+	_, err := b.tx.ExecContext(b.ctx,
+		`UPDATE webhook_deliveries
+		SET status = $1, attempts = $2, next_attempt_at = $3, last_status_code = $4, last_error = $5
+		WHERE id = $6`, status, attempts, next, statusCode, reason, id)
+
+	return err
+}
+
+func (b *batch) Commit() error {
+	defer b.cancel()
+
+	return b.tx.Commit()
+}
+
+func (b *batch) Rollback() error {
+	defer b.cancel()
+
+	return b.tx.Rollback()
+}
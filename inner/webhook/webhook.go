@@ -0,0 +1,245 @@
+package webhook
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"idm/inner/database"
+	"idm/inner/outbox"
+	"net/url"
+	"slices"
+	"time"
+)
+
+// Статусы доставки
+const (
+	StatusPending   = "pending"
+	StatusDelivered = "delivered"
+	// StatusDead попытки исчерпаны; доставку можно только повторить вручную
+	StatusDead = "dead"
+)
+
+// SecretPrefix начало секретов, созданных сервисом
+const SecretPrefix = "whsec_"
+
+const (
+	minSecretLength = 16
+	// maxDeliveriesPage сколько доставок отдаётся за один запрос журнала
+	maxDeliveriesPage = 100
+)
+
+var (
+	ErrInvalidURL       = errors.New("webhook url must be an absolute http or https url")
+	ErrInvalidEventType = errors.New("unknown event type")
+	ErrInvalidSecret    = errors.New("webhook secret must be at least 16 characters")
+	ErrInvalidStatus    = errors.New("unknown delivery status")
+)
+
+type Repo interface {
+	FindAll() ([]*Subscription, error)
+	FindById(id int64) (*Subscription, error)
+	Create(subscription *Subscription) error
+	Update(subscription *Subscription) error
+	SetSecret(id int64, secret string) error
+	Remove(id int64) error
+	// Enqueue создать доставки события для подходящих активных подписок; повтор события ничего не создаёт
+	Enqueue(event outbox.Event, payload []byte) error
+	// FindDeliveries журнал подписки от новых к старым; beforeId 0 – с самой новой
+	FindDeliveries(subscriptionId int64, status string, beforeId int64, limit int) ([]*Delivery, error)
+	FindDelivery(id int64) (*Delivery, error)
+	// Redeliver новая доставка с тем же событием
+	Redeliver(id int64) (*Delivery, error)
+	// Begin начать отправку пакета; outbox.ErrBusy, если её уже ведёт другой экземпляр
+	Begin() (Batch, error)
+}
+
+// Batch пакет доставок в одной транзакции
+type Batch interface {
+	Due(limit int, now time.Time) ([]*Due, error)
+	Delivered(id int64, statusCode int, at time.Time) error
+	Failed(id int64, attempts int, next time.Time, statusCode *int, reason string, dead bool) error
+	Commit() error
+	Rollback() error
+}
+
+type Service struct {
+	repo Repo
+}
+
+func NewService(repository Repo) *Service {
+	return &Service{repo: repository}
+}
+
+func (s *Service) FindAll() ([]Response, error) {
+	subscriptions, err := s.repo.FindAll()
+	if err != nil {
+		return nil, fmt.Errorf("error finding all webhook subscriptions: %w", err)
+	}
+
+	responses := []Response{}
+	for _, subscription := range subscriptions {
+		responses = append(responses, subscription.ToResponse())
+	}
+
+	return responses, nil
+}
+
+func (s *Service) FindById(id int64) (Response, error) {
+	subscription, err := s.repo.FindById(id)
+	if err != nil {
+		return Response{}, fmt.Errorf("error finding webhook subscription with id %d: %w", id, err)
+	}
+
+	return subscription.ToResponse(), nil
+}
+
+// Create создать подписку. Без секрета он создаётся сервисом; секрет возвращается только здесь и при ротации.
+func (s *Service) Create(request Request) (CreatedResponse, error) {
+	subscription := &Subscription{Url: request.Url, Description: request.Description, EventTypes: request.EventTypes, Active: true}
+	if request.Active != nil {
+		subscription.Active = *request.Active
+	}
+	if err := validate(subscription); err != nil {
+		return CreatedResponse{}, err
+	}
+
+	secret := request.Secret
+	if secret == "" {
+		generated, err := newSecret()
+		if err != nil {
+			return CreatedResponse{}, err
+		}
+		secret = generated
+	}
+	if len(secret) < minSecretLength {
+		return CreatedResponse{}, ErrInvalidSecret
+	}
+	subscription.Secret = secret
+
+	if err := s.repo.Create(subscription); err != nil {
+		return CreatedResponse{}, fmt.Errorf("error creating webhook subscription: %w", err)
+	}
+
+	return CreatedResponse{Response: subscription.ToResponse(), Secret: secret}, nil
+}
+
+// Update изменить адрес, типы событий, описание и активность подписки; секрет не меняется
+func (s *Service) Update(id int64, request Request) (Response, error) {
+	subscription, err := s.repo.FindById(id)
+	if err != nil {
+		return Response{}, fmt.Errorf("error finding webhook subscription with id %d: %w", id, err)
+	}
+
+	subscription.Url = request.Url
+	subscription.Description = request.Description
+	subscription.EventTypes = request.EventTypes
+	if request.Active != nil {
+		subscription.Active = *request.Active
+	}
+	if err := validate(subscription); err != nil {
+		return Response{}, err
+	}
+
+	if err := s.repo.Update(subscription); err != nil {
+		return Response{}, fmt.Errorf("error updating webhook subscription with id %d: %w", id, err)
+	}
+
+	return subscription.ToResponse(), nil
+}
+
+// RotateSecret заменить секрет подписки новым; старый перестаёт действовать сразу
+func (s *Service) RotateSecret(id int64) (CreatedResponse, error) {
+	subscription, err := s.repo.FindById(id)
+	if err != nil {
+		return CreatedResponse{}, fmt.Errorf("error finding webhook subscription with id %d: %w", id, err)
+	}
+
+	secret, err := newSecret()
+	if err != nil {
+		return CreatedResponse{}, err
+	}
+	if err := s.repo.SetSecret(id, secret); err != nil {
+		return CreatedResponse{}, fmt.Errorf("error rotating secret of webhook subscription with id %d: %w", id, err)
+	}
+
+	return CreatedResponse{Response: subscription.ToResponse(), Secret: secret}, nil
+}
+
+func (s *Service) Remove(id int64) error {
+	if _, err := s.repo.FindById(id); err != nil {
+		return fmt.Errorf("error finding webhook subscription with id %d: %w", id, err)
+	}
+
+	return s.repo.Remove(id)
+}
+
+// FindDeliveries страница журнала доставок подписки, от новых к старым
+func (s *Service) FindDeliveries(subscriptionId int64, query DeliveryQuery) ([]DeliveryResponse, error) {
+	if query.Status != "" && !slices.Contains([]string{StatusPending, StatusDelivered, StatusDead}, query.Status) {
+		return nil, ErrInvalidStatus
+	}
+	if _, err := s.repo.FindById(subscriptionId); err != nil {
+		return nil, fmt.Errorf("error finding webhook subscription with id %d: %w", subscriptionId, err)
+	}
+
+	limit := query.Limit
+	if limit <= 0 || limit > maxDeliveriesPage {
+		limit = maxDeliveriesPage
+	}
+	deliveries, err := s.repo.FindDeliveries(subscriptionId, query.Status, query.BeforeId, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error finding deliveries of webhook subscription with id %d: %w", subscriptionId, err)
+	}
+
+	responses := []DeliveryResponse{}
+	for _, delivery := range deliveries {
+		responses = append(responses, delivery.ToResponse())
+	}
+
+	return responses, nil
+}
+
+// Redeliver отправить событие доставки ещё раз отдельной доставкой, не меняя журнал прежней
+func (s *Service) Redeliver(subscriptionId int64, deliveryId int64) (DeliveryResponse, error) {
+	delivery, err := s.repo.FindDelivery(deliveryId)
+	if err == nil && delivery.SubscriptionId != subscriptionId {
+		err = database.ErrRecordNotFound
+	}
+	if err != nil {
+		return DeliveryResponse{}, fmt.Errorf("error finding webhook delivery with id %d: %w", deliveryId, err)
+	}
+
+	redelivery, err := s.repo.Redeliver(deliveryId)
+	if err != nil {
+		return DeliveryResponse{}, fmt.Errorf("error redelivering webhook delivery with id %d: %w", deliveryId, err)
+	}
+
+	return redelivery.ToResponse(), nil
+}
+
+func validate(subscription *Subscription) error {
+	parsed, err := url.Parse(subscription.Url)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return ErrInvalidURL
+	}
+	if subscription.EventTypes == nil {
+		subscription.EventTypes = []string{}
+	}
+	for _, eventType := range subscription.EventTypes {
+		if !slices.Contains(outbox.EventTypes, eventType) {
+			return fmt.Errorf("%w %q", ErrInvalidEventType, eventType)
+		}
+	}
+
+	return nil
+}
+
+func newSecret() (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", fmt.Errorf("error generating webhook secret: %w", err)
+	}
+
+	return SecretPrefix + base64.RawURLEncoding.EncodeToString(raw), nil
+}
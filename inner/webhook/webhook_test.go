@@ -0,0 +1,420 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	assertpackage "github.com/stretchr/testify/assert"
+	"idm/inner/database"
+	"idm/inner/outbox"
+)
+
+// StubRepo подписки и доставки в памяти
+type StubRepo struct {
+	subscriptions []*Subscription
+	deliveries    []*Delivery
+}
+
+type StubBatch struct {
+	repo *StubRepo
+}
+
+func (r *StubRepo) FindAll() ([]*Subscription, error) {
+	return r.subscriptions, nil
+}
+
+func (r *StubRepo) FindById(id int64) (*Subscription, error) {
+	for _, subscription := range r.subscriptions {
+		if subscription.Id == id {
+			copied := *subscription
+			return &copied, nil
+		}
+	}
+	return nil, database.ErrRecordNotFound
+}
+
+func (r *StubRepo) Create(subscription *Subscription) error {
+	subscription.Id = int64(len(r.subscriptions) + 1)
+	copied := *subscription
+	r.subscriptions = append(r.subscriptions, &copied)
+	return nil
+}
+
+func (r *StubRepo) Update(subscription *Subscription) error {
+	for i, existing := range r.subscriptions {
+		if existing.Id == subscription.Id {
+			copied := *subscription
+			r.subscriptions[i] = &copied
+		}
+	}
+	return nil
+}
+
+func (r *StubRepo) SetSecret(id int64, secret string) error {
+	for _, subscription := range r.subscriptions {
+		if subscription.Id == id {
+			subscription.Secret = secret
+		}
+	}
+	return nil
+}
+
+func (r *StubRepo) Remove(id int64) error {
+	r.subscriptions = slices.DeleteFunc(r.subscriptions, func(s *Subscription) bool { return s.Id == id })
+	return nil
+}
+
+func (r *StubRepo) Enqueue(event outbox.Event, payload []byte) error {
+	for _, subscription := range r.subscriptions {
+		if !subscription.Active || (len(subscription.EventTypes) > 0 && !slices.Contains(subscription.EventTypes, event.Type)) {
+			continue
+		}
+		if slices.ContainsFunc(r.deliveries, func(d *Delivery) bool {
+			return d.SubscriptionId == subscription.Id && d.EventId == event.Id && d.RedeliveryOf == nil
+		}) {
+			continue
+		}
+		r.add(&Delivery{SubscriptionId: subscription.Id, EventId: event.Id, EventType: event.Type, Payload: payload})
+	}
+	return nil
+}
+
+func (r *StubRepo) add(delivery *Delivery) *Delivery {
+	delivery.Id = int64(len(r.deliveries) + 1)
+	delivery.Status = StatusPending
+	r.deliveries = append(r.deliveries, delivery)
+	return delivery
+}
+
+func (r *StubRepo) FindDeliveries(subscriptionId int64, status string, beforeId int64, limit int) ([]*Delivery, error) {
+	var deliveries []*Delivery
+	for _, delivery := range slices.Backward(r.deliveries) {
+		if delivery.SubscriptionId == subscriptionId && (status == "" || delivery.Status == status) &&
+			(beforeId == 0 || delivery.Id < beforeId) && len(deliveries) < limit {
+			deliveries = append(deliveries, delivery)
+		}
+	}
+	return deliveries, nil
+}
+
+func (r *StubRepo) FindDelivery(id int64) (*Delivery, error) {
+	for _, delivery := range r.deliveries {
+		if delivery.Id == id {
+			return delivery, nil
+		}
+	}
+	return nil, database.ErrRecordNotFound
+}
+
+func (r *StubRepo) Redeliver(id int64) (*Delivery, error) {
+	original, err := r.FindDelivery(id)
+	if err != nil {
+		return nil, err
+	}
+	return r.add(&Delivery{SubscriptionId: original.SubscriptionId, EventId: original.EventId,
+		EventType: original.EventType, Payload: original.Payload, RedeliveryOf: &original.Id}), nil
+}
+
+func (r *StubRepo) Begin() (Batch, error) {
+	return &StubBatch{repo: r}, nil
+}
+
+func (b *StubBatch) Due(limit int, now time.Time) ([]*Due, error) {
+	var due []*Due
+	for _, delivery := range b.repo.deliveries {
+		subscription, _ := b.repo.FindById(delivery.SubscriptionId)
+		if delivery.Status == StatusPending && !delivery.NextAttemptAt.After(now) && subscription.Active && len(due) < limit {
+			due = append(due, &Due{Id: delivery.Id, EventId: delivery.EventId, EventType: delivery.EventType,
+				Payload: delivery.Payload, Attempts: delivery.Attempts, Url: subscription.Url, Secret: subscription.Secret})
+		}
+	}
+	return due, nil
+}
+
+func (b *StubBatch) Delivered(id int64, statusCode int, at time.Time) error {
+	delivery, _ := b.repo.FindDelivery(id)
+	delivery.Status, delivery.LastStatusCode, delivery.DeliveredAt = StatusDelivered, &statusCode, &at
+	delivery.Attempts++
+	return nil
+}
+
+func (b *StubBatch) Failed(id int64, attempts int, next time.Time, statusCode *int, reason string, dead bool) error {
+	delivery, _ := b.repo.FindDelivery(id)
+	delivery.Attempts, delivery.NextAttemptAt, delivery.LastStatusCode, delivery.LastError = attempts, next, statusCode, reason
+	if dead {
+		delivery.Status = StatusDead
+	}
+	return nil
+}
+
+func (b *StubBatch) Commit() error {
+	return nil
+}
+
+func (b *StubBatch) Rollback() error {
+	return nil
+}
+
+// Receiver подписчик, проверяющий подпись; status задаёт код ответа
+type Receiver struct {
+	mu       sync.Mutex
+	server   *httptest.Server
+	secret   string
+	status   int
+	received []*http.Request
+	bodies   []string
+	errors   []error
+}
+
+func NewReceiver(t *testing.T, secret string) *Receiver {
+	receiver := &Receiver{secret: secret, status: http.StatusOK}
+	receiver.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		receiver.mu.Lock()
+		defer receiver.mu.Unlock()
+		receiver.received = append(receiver.received, r)
+		receiver.bodies = append(receiver.bodies, string(body))
+		receiver.errors = append(receiver.errors, Verify(receiver.secret, r.Header, body, DefaultTolerance, time.Now()))
+		w.WriteHeader(receiver.status)
+		_, _ = io.WriteString(w, "try later")
+	}))
+	t.Cleanup(receiver.server.Close)
+	return receiver
+}
+
+func TestService(t *testing.T) {
+	var assert = assertpackage.New(t)
+
+	t.Run("should create a subscription with a generated secret", func(t *testing.T) {
+		repo := &StubRepo{}
+		service := NewService(repo)
+
+		created, err := service.Create(Request{Url: "https://tickets.example.com/hook", EventTypes: []string{outbox.EmployeeCreated}})
+
+		assert.Nil(err)
+		assert.True(created.Active)
+		assert.True(strings.HasPrefix(created.Secret, SecretPrefix))
+		assert.Equal(created.Secret, repo.subscriptions[0].Secret)
+		found, _ := service.FindById(created.Id)
+		encoded, _ := json.Marshal(found)
+		assert.NotContains(string(encoded), created.Secret)
+	})
+
+	t.Run("should validate url, event types and secret", func(t *testing.T) {
+		service := NewService(&StubRepo{})
+
+		_, err := service.Create(Request{Url: "ftp://example.com"})
+		assert.ErrorIs(err, ErrInvalidURL)
+		_, err = service.Create(Request{Url: "/relative"})
+		assert.ErrorIs(err, ErrInvalidURL)
+		_, err = service.Create(Request{Url: "https://example.com", EventTypes: []string{"EmployeeHired"}})
+		assert.ErrorIs(err, ErrInvalidEventType)
+		_, err = service.Create(Request{Url: "https://example.com", Secret: "short"})
+		assert.ErrorIs(err, ErrInvalidSecret)
+	})
+
+	t.Run("should update without touching the secret and rotate it on demand", func(t *testing.T) {
+		repo := &StubRepo{}
+		service := NewService(repo)
+		created, _ := service.Create(Request{Url: "https://example.com/a", Secret: "0123456789abcdef"})
+		inactive := false
+
+		updated, err := service.Update(created.Id, Request{Url: "https://example.com/b", Active: &inactive, Secret: "ignored-ignored-ignored"})
+
+		assert.Nil(err)
+		assert.Equal("https://example.com/b", updated.Url)
+		assert.False(updated.Active)
+		assert.Equal([]string{}, updated.EventTypes)
+		assert.Equal("0123456789abcdef", repo.subscriptions[0].Secret)
+
+		rotated, err := service.RotateSecret(created.Id)
+		assert.Nil(err)
+		assert.NotEqual("0123456789abcdef", rotated.Secret)
+		assert.Equal(rotated.Secret, repo.subscriptions[0].Secret)
+	})
+
+	t.Run("should page the delivery log and redeliver within the subscription", func(t *testing.T) {
+		repo := &StubRepo{}
+		service := NewService(repo)
+		first, _ := service.Create(Request{Url: "https://example.com/a"})
+		second, _ := service.Create(Request{Url: "https://example.com/b"})
+		dispatcher := NewDispatcher(repo)
+		for id := int64(1); id <= 3; id++ {
+			assert.Nil(dispatcher.Publish(context.Background(), outbox.Event{Id: id, Type: outbox.RoleCreated}))
+		}
+
+		page, err := service.FindDeliveries(first.Id, DeliveryQuery{Limit: 2})
+		assert.Nil(err)
+		assert.Equal([]int64{3, 2}, []int64{page[0].EventId, page[1].EventId})
+		page, _ = service.FindDeliveries(first.Id, DeliveryQuery{BeforeId: page[1].Id})
+		assert.Len(page, 1)
+		_, err = service.FindDeliveries(first.Id, DeliveryQuery{Status: "lost"})
+		assert.ErrorIs(err, ErrInvalidStatus)
+
+		redelivery, err := service.Redeliver(first.Id, page[0].Id)
+		assert.Nil(err)
+		assert.Equal(StatusPending, redelivery.Status)
+		assert.Equal(page[0].Id, *redelivery.RedeliveryOf)
+		_, err = service.Redeliver(second.Id, page[0].Id)
+		assert.ErrorIs(err, database.ErrRecordNotFound)
+	})
+}
+
+func TestDispatcher(t *testing.T) {
+	var assert = assertpackage.New(t)
+
+	t.Run("should enqueue an event once for each matching active subscription", func(t *testing.T) {
+		repo := &StubRepo{}
+		service := NewService(repo)
+		_, _ = service.Create(Request{Url: "https://example.com/all"})
+		_, _ = service.Create(Request{Url: "https://example.com/roles", EventTypes: []string{outbox.RoleAssigned}})
+		inactive := false
+		_, _ = service.Create(Request{Url: "https://example.com/off", Active: &inactive})
+		dispatcher := NewDispatcher(repo)
+		event := outbox.Event{Id: 7, Type: outbox.EmployeeCreated, AggregateType: "employee", AggregateId: 3,
+			Payload: json.RawMessage(`{"employee":{"id":3}}`)}
+
+		assert.Nil(dispatcher.Publish(context.Background(), event))
+		assert.Nil(dispatcher.Publish(context.Background(), event))
+
+		assert.Len(repo.deliveries, 1)
+		assert.Equal(int64(1), repo.deliveries[0].SubscriptionId)
+		assert.JSONEq(`{"id":7,"type":"EmployeeCreated","aggregate_type":"employee","aggregate_id":3,
+			"payload":{"employee":{"id":3}},"occurred_at":"0001-01-01T00:00:00Z"}`, string(repo.deliveries[0].Payload))
+	})
+}
+
+func TestDeliverer(t *testing.T) {
+	var assert = assertpackage.New(t)
+
+	setup := func(t *testing.T) (*StubRepo, *Receiver, *Deliverer) {
+		repo := &StubRepo{}
+		receiver := NewReceiver(t, "0123456789abcdef")
+		_, _ = NewService(repo).Create(Request{Url: receiver.server.URL, Secret: receiver.secret})
+		_ = NewDispatcher(repo).Publish(context.Background(), outbox.Event{Id: 42, Type: outbox.RoleAssigned})
+		return repo, receiver, NewDeliverer(repo, receiver.server.Client())
+	}
+
+	t.Run("should send a signed request and mark it delivered", func(t *testing.T) {
+		repo, receiver, deliverer := setup(t)
+
+		count, err := deliverer.RunOnce(context.Background())
+
+		assert.Nil(err)
+		assert.Equal(1, count)
+		assert.Equal([]error{nil}, receiver.errors)
+		request := receiver.received[0]
+		assert.Equal(outbox.RoleAssigned, request.Header.Get(HeaderEvent))
+		assert.Equal("42", request.Header.Get(HeaderEventId))
+		assert.Equal("1", request.Header.Get(HeaderDelivery))
+		assert.Equal(string(repo.deliveries[0].Payload), receiver.bodies[0])
+		assert.Equal(StatusDelivered, repo.deliveries[0].Status)
+		assert.Equal(http.StatusOK, *repo.deliveries[0].LastStatusCode)
+	})
+
+	t.Run("should retry with backoff and give up after the last attempt", func(t *testing.T) {
+		repo, receiver, deliverer := setup(t)
+		receiver.status = http.StatusServiceUnavailable
+		now := time.Now()
+		deliverer.now = func() time.Time { return now }
+		deliverer.SetRetry(3, time.Minute, time.Hour)
+
+		_, _ = deliverer.RunOnce(context.Background())
+		delivery := repo.deliveries[0]
+		assert.Equal(StatusPending, delivery.Status)
+		assert.Equal(1, delivery.Attempts)
+		assert.Equal(now.Add(time.Minute), delivery.NextAttemptAt)
+		assert.Equal("subscriber responded with status 503: try later", delivery.LastError)
+
+		count, _ := deliverer.RunOnce(context.Background())
+		assert.Equal(0, count)
+
+		now = now.Add(time.Minute)
+		_, _ = deliverer.RunOnce(context.Background())
+		assert.Equal(now.Add(2*time.Minute), delivery.NextAttemptAt)
+		now = now.Add(2 * time.Minute)
+		_, _ = deliverer.RunOnce(context.Background())
+
+		assert.Equal(StatusDead, delivery.Status)
+		assert.Equal(3, delivery.Attempts)
+		assert.Len(receiver.received, 3)
+		now = now.Add(time.Hour)
+		count, _ = deliverer.RunOnce(context.Background())
+		assert.Equal(0, count)
+	})
+
+	t.Run("should hold deliveries of an inactive subscription", func(t *testing.T) {
+		repo, receiver, deliverer := setup(t)
+		repo.subscriptions[0].Active = false
+
+		count, _ := deliverer.RunOnce(context.Background())
+
+		assert.Equal(0, count)
+		assert.Empty(receiver.received)
+	})
+}
+
+func TestVerify(t *testing.T) {
+	var assert = assertpackage.New(t)
+	now := time.Unix(1_700_000_000, 0)
+	body := []byte(`{"id":1}`)
+	header := http.Header{}
+	header.Set(HeaderTimestamp, "1700000000")
+	header.Set(HeaderSignature, Sign("secret-secret-secret", now.Unix(), body))
+
+	assert.Nil(Verify("secret-secret-secret", header, body, DefaultTolerance, now.Add(time.Minute)))
+	assert.ErrorIs(Verify("another-secret-secret", header, body, DefaultTolerance, now), ErrInvalidSignature)
+	assert.ErrorIs(Verify("secret-secret-secret", header, []byte(`{"id":2}`), DefaultTolerance, now), ErrInvalidSignature)
+	assert.ErrorIs(Verify("secret-secret-secret", header, body, DefaultTolerance, now.Add(10*time.Minute)), ErrStaleTimestamp)
+
+	// подменённая метка времени не сходится с подписью
+	header.Set(HeaderTimestamp, "1700000600")
+	assert.ErrorIs(Verify("secret-secret-secret", header, body, DefaultTolerance, now.Add(10*time.Minute)), ErrInvalidSignature)
+}
+
+func TestHandler(t *testing.T) {
+	var assert = assertpackage.New(t)
+
+	t.Run("should create a subscription and show the secret once", func(t *testing.T) {
+		handler := NewHandler(NewService(&StubRepo{}))
+		recorder := httptest.NewRecorder()
+
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/",
+			strings.NewReader(`{"url":"https://example.com/hook","event_types":["EmployeeRemoved"]}`)))
+
+		assert.Equal(http.StatusCreated, recorder.Code)
+		assert.Contains(recorder.Body.String(), `"secret":"whsec_`)
+
+		recorder = httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/1", nil))
+		assert.Equal(http.StatusOK, recorder.Code)
+		assert.NotContains(recorder.Body.String(), "secret")
+	})
+
+	t.Run("should map errors to statuses", func(t *testing.T) {
+		handler := NewHandler(NewService(&StubRepo{}))
+
+		for target, status := range map[string]int{
+			"POST /":                         http.StatusBadRequest,
+			"GET /5":                         http.StatusNotFound,
+			"GET /x":                         http.StatusBadRequest,
+			"GET /5/deliveries?limit=-1":     http.StatusBadRequest,
+			"POST /5/deliveries/1/redeliver": http.StatusNotFound,
+			"DELETE /5":                      http.StatusNotFound,
+		} {
+			method, path, _ := strings.Cut(target, " ")
+			recorder := httptest.NewRecorder()
+			body := strings.NewReader(`{"url":"mailto:someone@example.com"}`)
+			handler.ServeHTTP(recorder, httptest.NewRequest(method, path, body))
+			assert.Equal(status, recorder.Code, target)
+		}
+	})
+}
@@ -0,0 +1,66 @@
+package workflow
+
+import "time"
+
+type RequestResponse struct {
+	Id            int64             `json:"id"`
+	EmployeeId    int64             `json:"employee_id"`
+	RoleId        int64             `json:"role_id"`
+	Justification string            `json:"justification"`
+	Status        Status            `json:"status"`
+	CurrentStep   int               `json:"current_step"`
+	StepDeadline  *time.Time        `json:"step_deadline,omitempty"`
+	History       []HistoryResponse `json:"history,omitempty"`
+	CreatedAt     time.Time         `json:"created_at"`
+	UpdatedAt     time.Time         `json:"updated_at"`
+}
+
+type HistoryResponse struct {
+	FromStatus Status    `json:"from_status"`
+	ToStatus   Status    `json:"to_status"`
+	Step       int       `json:"step"`
+	ActorId    *int64    `json:"actor_id,omitempty"`
+	Comment    string    `json:"comment"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+func (r *Request) ToResponse(history []*HistoryEntry) *RequestResponse {
+	response := &RequestResponse{
+		Id:            r.Id,
+		EmployeeId:    r.EmployeeId,
+		RoleId:        r.RoleId,
+		Justification: r.Justification,
+		Status:        r.Status,
+		CurrentStep:   r.CurrentStep,
+		StepDeadline:  r.StepDeadline,
+		CreatedAt:     r.CreatedAt,
+		UpdatedAt:     r.UpdatedAt,
+	}
+	for _, entry := range history {
+		response.History = append(response.History, *entry.ToResponse())
+	}
+
+	return response
+}
+
+func (e *HistoryEntry) ToResponse() *HistoryResponse {
+	return &HistoryResponse{
+		FromStatus: e.FromStatus,
+		ToStatus:   e.ToStatus,
+		Step:       e.Step,
+		ActorId:    e.ActorId,
+		Comment:    e.Comment,
+		CreatedAt:  e.CreatedAt,
+	}
+}
+
+// SubmitRequest заявка на роль от имени текущего сотрудника
+type SubmitRequest struct {
+	RoleId        int64  `json:"role_id"`
+	Justification string `json:"justification"`
+}
+
+// DecisionRequest решение согласующего
+type DecisionRequest struct {
+	Comment string `json:"comment"`
+}
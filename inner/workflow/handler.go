@@ -0,0 +1,191 @@
+package workflow
+
+import (
+	"idm/inner/httpapi"
+	"idm/inner/sod"
+	"net/http"
+)
+
+// statuses коды ответа для ошибок сервиса
+var statuses = httpapi.Statuses{
+	{Err: ErrNoApprovalSteps, Code: http.StatusBadRequest},
+	{Err: ErrNotApprover, Code: http.StatusForbidden},
+	{Err: ErrNotRequester, Code: http.StatusForbidden},
+	{Err: ErrDuplicateRequest, Code: http.StatusConflict},
+	{Err: ErrInvalidTransition, Code: http.StatusConflict},
+	{Err: ErrConcurrentUpdate, Code: http.StatusConflict},
+	{Err: sod.ErrViolation, Code: http.StatusConflict},
+}
+
+// Handler заявки текущего сотрудника и решения по заявкам, которые он согласует
+type Handler struct {
+	service       *Service
+	authenticator httpapi.Authenticator[int64]
+	mux           *http.ServeMux
+}
+
+func NewHandler(service *Service, authenticator httpapi.Authenticator[int64]) *Handler {
+	h := &Handler{service: service, authenticator: authenticator, mux: http.NewServeMux()}
+
+	h.mux.HandleFunc("GET /{$}", h.list)
+	h.mux.HandleFunc("POST /{$}", h.submit)
+	h.mux.HandleFunc("GET /{id}", h.get)
+	h.mux.HandleFunc("GET /{id}/approvers", h.approvers)
+	h.mux.HandleFunc("POST /{id}/approve", h.approve)
+	h.mux.HandleFunc("POST /{id}/reject", h.reject)
+	h.mux.HandleFunc("POST /{id}/cancel", h.cancel)
+
+	return h
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+func (h *Handler) list(w http.ResponseWriter, r *http.Request) {
+	employeeId, ok := h.authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	requests, err := h.service.FindByEmployeeId(employeeId)
+	if requests == nil {
+		requests = []RequestResponse{}
+	}
+	httpapi.Write(w, http.StatusOK, requests, err, statuses)
+}
+
+func (h *Handler) submit(w http.ResponseWriter, r *http.Request) {
+	employeeId, ok := h.authenticate(w, r)
+	if !ok {
+		return
+	}
+	var request SubmitRequest
+	if !httpapi.Decode(w, r, &request) {
+		return
+	}
+
+	created, err := h.service.Submit(employeeId, request.RoleId, request.Justification)
+	httpapi.Write(w, http.StatusCreated, created, err, statuses)
+}
+
+func (h *Handler) get(w http.ResponseWriter, r *http.Request) {
+	employeeId, id, ok := h.target(w, r)
+	if !ok {
+		return
+	}
+
+	request, err := h.service.FindVisible(id, employeeId)
+	httpapi.Write(w, http.StatusOK, request, err, statuses)
+}
+
+func (h *Handler) approvers(w http.ResponseWriter, r *http.Request) {
+	employeeId, id, ok := h.target(w, r)
+	if !ok {
+		return
+	}
+	if _, err := h.service.FindVisible(id, employeeId); err != nil {
+		httpapi.Write(w, 0, nil, err, statuses)
+		return
+	}
+
+	approvers, err := h.service.Approvers(id)
+	if approvers == nil {
+		approvers = []int64{}
+	}
+	httpapi.Write(w, http.StatusOK, approvers, err, statuses)
+}
+
+func (h *Handler) approve(w http.ResponseWriter, r *http.Request) {
+	h.decide(w, r, h.service.Approve)
+}
+
+func (h *Handler) reject(w http.ResponseWriter, r *http.Request) {
+	h.decide(w, r, h.service.Reject)
+}
+
+func (h *Handler) cancel(w http.ResponseWriter, r *http.Request) {
+	employeeId, id, ok := h.target(w, r)
+	if !ok {
+		return
+	}
+
+	request, err := h.service.Cancel(id, employeeId)
+	httpapi.Write(w, http.StatusOK, request, err, statuses)
+}
+
+// decide принять решение по заявке от имени текущего сотрудника
+func (h *Handler) decide(w http.ResponseWriter, r *http.Request,
+	decision func(requestId int64, approverId int64, comment string) (RequestResponse, error)) {
+	employeeId, id, ok := h.target(w, r)
+	if !ok {
+		return
+	}
+	var request DecisionRequest
+	if !httpapi.Decode(w, r, &request) {
+		return
+	}
+
+	decided, err := decision(id, employeeId, request.Comment)
+	httpapi.Write(w, http.StatusOK, decided, err, statuses)
+}
+
+func (h *Handler) authenticate(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	employeeId, err := h.authenticator.Authenticate(r)
+	if err != nil {
+		httpapi.Write(w, 0, nil, err, statuses)
+		return 0, false
+	}
+
+	return employeeId, true
+}
+
+// target текущий сотрудник и заявка из пути
+func (h *Handler) target(w http.ResponseWriter, r *http.Request) (int64, int64, bool) {
+	employeeId, ok := h.authenticate(w, r)
+	if !ok {
+		return 0, 0, false
+	}
+	id, ok := httpapi.PathId(w, r, "id")
+
+	return employeeId, id, ok
+}
+
+// AdminHandler просмотр любой заявки и повторная выдача роли по согласованной
+type AdminHandler struct {
+	service *Service
+	mux     *http.ServeMux
+}
+
+func NewAdminHandler(service *Service) *AdminHandler {
+	h := &AdminHandler{service: service, mux: http.NewServeMux()}
+
+	h.mux.HandleFunc("GET /{id}", h.get)
+	h.mux.HandleFunc("POST /{id}/retry", h.retry)
+
+	return h
+}
+
+func (h *AdminHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+func (h *AdminHandler) get(w http.ResponseWriter, r *http.Request) {
+	id, ok := httpapi.PathId(w, r, "id")
+	if !ok {
+		return
+	}
+
+	request, err := h.service.FindById(id)
+	httpapi.Write(w, http.StatusOK, request, err, statuses)
+}
+
+func (h *AdminHandler) retry(w http.ResponseWriter, r *http.Request) {
+	id, ok := httpapi.PathId(w, r, "id")
+	if !ok {
+		return
+	}
+
+	request, err := h.service.RetryGrant(id)
+	httpapi.Write(w, http.StatusOK, request, err, statuses)
+}
@@ -0,0 +1,254 @@
+package workflow
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"idm/inner/database"
+	"time"
+)
+
+// Request заявка сотрудника на получение роли
+type Request struct {
+	Id            int64      `db:"id"`
+	EmployeeId    int64      `db:"employee_id"`
+	RoleId        int64      `db:"role_id"`
+	Justification string     `db:"justification"`
+	Status        Status     `db:"status"`
+	CurrentStep   int        `db:"current_step"`
+	StepDeadline  *time.Time `db:"step_deadline"`
+	CreatedAt     time.Time  `db:"created_at"`
+	UpdatedAt     time.Time  `db:"updated_at"`
+}
+
+// Step шаг согласования, настроенный для роли
+type Step struct {
+	Id              int64        `db:"id"`
+	RoleId          int64        `db:"role_id"`
+	Position        int          `db:"position"`
+	ApproverType    ApproverType `db:"approver_type"`
+	ApproverGroup   *string      `db:"approver_group"`
+	TimeoutSeconds  int64        `db:"timeout_seconds"`
+	EscalationGroup *string      `db:"escalation_group"`
+}
+
+// HistoryEntry запись о переходе заявки из одного состояния в другое
+type HistoryEntry struct {
+	Id         int64     `db:"id"`
+	RequestId  int64     `db:"request_id"`
+	FromStatus Status    `db:"from_status"`
+	ToStatus   Status    `db:"to_status"`
+	Step       int       `db:"step"`
+	ActorId    *int64    `db:"actor_id"`
+	Comment    string    `db:"comment"`
+	CreatedAt  time.Time `db:"created_at"`
+}
+
+type Repository struct {
+	db *sqlx.DB
+}
+
+func NewRepository(db *sqlx.DB) *Repository {
+	return &Repository{db: db}
+}
+
+func (r *Repository) FindById(id int64) (*Request, error) {
+	var request Request
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := r.db.GetContext(ctx, &request, "SELECT * FROM access_requests WHERE id = $1", id)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, database.ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &request, err
+}
+
+func (r *Repository) FindByEmployeeId(employeeId int64) ([]*Request, error) {
+	var requests []*Request
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := r.db.SelectContext(ctx, &requests,
+		"SELECT * FROM access_requests WHERE employee_id = $1 ORDER BY id", employeeId)
+
+	return requests, err
+}
+
+// FindOverdue заявки в статусе pending, у которых истёк срок текущего шага
+func (r *Repository) FindOverdue(now time.Time) ([]*Request, error) {
+	var requests []*Request
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := r.db.SelectContext(ctx, &requests,
+		"SELECT * FROM access_requests WHERE status = $1 AND step_deadline < $2 ORDER BY id",
+		StatusPending, now)
+
+	return requests, err
+}
+
+// FindApproved согласованные заявки, по которым роль ещё не выдана
+func (r *Repository) FindApproved() ([]*Request, error) {
+	var requests []*Request
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := r.db.SelectContext(ctx, &requests,
+		"SELECT * FROM access_requests WHERE status = $1 ORDER BY id", StatusApproved)
+
+	return requests, err
+}
+
+func (r *Repository) FindSteps(roleId int64) ([]*Step, error) {
+	var steps []*Step
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := r.db.SelectContext(ctx, &steps,
+		"SELECT * FROM approval_steps WHERE role_id = $1 ORDER BY position", roleId)
+
+	return steps, err
+}
+
+func (r *Repository) FindHistory(requestId int64) ([]*HistoryEntry, error) {
+	var entries []*HistoryEntry
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := r.db.SelectContext(ctx, &entries,
+		"SELECT * FROM access_request_history WHERE request_id = $1 ORDER BY id", requestId)
+
+	return entries, err
+}
+
+// FindApproverIds идентификаторы сотрудников, которые могут согласовать заявку от имени approver
+func (r *Repository) FindApproverIds(request *Request, approver Approver) ([]int64, error) {
+	var ids []int64
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var err error
+	switch approver.Type {
+	case ApproverRoleOwner:
+		err = r.db.SelectContext(ctx, &ids,
+			"SELECT owner_id FROM roles WHERE id = $1 AND owner_id IS NOT NULL", request.RoleId)
+	case ApproverManager:
+		err = r.db.SelectContext(ctx, &ids,
+			"SELECT manager_id FROM employees WHERE id = $1 AND manager_id IS NOT NULL", request.EmployeeId)
+	case ApproverGroup:
+		err = r.db.SelectContext(ctx, &ids,
+			"SELECT employee_id FROM approver_groups WHERE name = $1 ORDER BY employee_id", approver.Group)
+	default:
+		err = ErrUnknownApproverType
+	}
+
+	return ids, err
+}
+
+// HasOpenRequest есть ли у сотрудника незавершённая заявка на роль
+func (r *Repository) HasOpenRequest(employeeId int64, roleId int64) (bool, error) {
+	var exists bool
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := r.db.GetContext(ctx, &exists,
+		"SELECT EXISTS (SELECT 1 FROM access_requests WHERE employee_id = $1 AND role_id = $2 AND status = ANY($3))",
+		employeeId, roleId, pq.Array(openStatuses))
+
+	return exists, err
+}
+
+// Create сохранить новую заявку вместе с первой записью истории. Если такая же незавершённая
+// заявка успела появиться параллельно, уникальный индекс её не пропустит – возвращается ErrDuplicateRequest.
+func (r *Repository) Create(request *Request, entry *HistoryEntry) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	err = tx.QueryRowContext(ctx,
+		`INSERT INTO access_requests (employee_id, role_id, justification, status, current_step, step_deadline)
+		VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, created_at, updated_at`,
+		request.EmployeeId, request.RoleId, request.Justification, request.Status, request.CurrentStep,
+		request.StepDeadline,
+	).Scan(&request.Id, &request.CreatedAt, &request.UpdatedAt)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" && pqErr.Constraint == "access_requests_open_idx" {
+		return ErrDuplicateRequest
+	}
+	if err != nil {
+		return err
+	}
+
+	entry.RequestId = request.Id
+	if err = insertHistory(ctx, tx, entry); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Transition сохранить новое состояние заявки и запись истории в одной транзакции.
+// Если заявку успели изменить параллельно, возвращается ErrConcurrentUpdate.
+func (r *Repository) Transition(request *Request, entry *HistoryEntry) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	err = tx.QueryRowContext(ctx,
+		`UPDATE access_requests
+		SET status = $1, current_step = $2, step_deadline = $3, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $4 AND status = $5 AND current_step = $6
+		RETURNING updated_at`,
+		request.Status, request.CurrentStep, request.StepDeadline, request.Id, entry.FromStatus, entry.Step,
+	).Scan(&request.UpdatedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrConcurrentUpdate
+		default:
+			return err
+		}
+	}
+
+	entry.RequestId = request.Id
+	if err = insertHistory(ctx, tx, entry); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func insertHistory(ctx context.Context, tx *sqlx.Tx, entry *HistoryEntry) error {
+	return tx.QueryRowContext(ctx,
+		`INSERT INTO access_request_history (request_id, from_status, to_status, step, actor_id, comment)
+		VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, created_at`,
+		entry.RequestId, entry.FromStatus, entry.ToStatus, entry.Step, entry.ActorId, entry.Comment,
+	).Scan(&entry.Id, &entry.CreatedAt)
+}
@@ -0,0 +1,501 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"idm/inner/database"
+	"idm/inner/httpapi"
+	"idm/inner/sod"
+	"log"
+	"slices"
+	"time"
+)
+
+// Status состояние заявки на доступ
+type Status string
+
+const (
+	StatusPending   Status = "pending"
+	StatusEscalated Status = "escalated"
+	StatusApproved  Status = "approved"
+	StatusGranted   Status = "granted"
+	StatusRejected  Status = "rejected"
+	StatusCancelled Status = "cancelled"
+	// StatusFailed роль по согласованной заявке выдать нельзя, например из-за конфликта SoD
+	StatusFailed Status = "failed"
+)
+
+// openStatuses статусы, в которых заявка ещё ожидает решения или выдачи роли
+var openStatuses = []string{string(StatusPending), string(StatusEscalated), string(StatusApproved)}
+
+// transitions допустимые переходы конечного автомата заявки
+var transitions = map[Status][]Status{
+	"":              {StatusPending},
+	StatusPending:   {StatusPending, StatusEscalated, StatusApproved, StatusRejected, StatusCancelled},
+	StatusEscalated: {StatusPending, StatusApproved, StatusRejected, StatusCancelled},
+	StatusApproved:  {StatusGranted, StatusFailed},
+}
+
+// ApproverType кто согласует шаг заявки
+type ApproverType string
+
+const (
+	ApproverRoleOwner ApproverType = "role_owner"
+	ApproverManager   ApproverType = "manager"
+	ApproverGroup     ApproverType = "group"
+)
+
+// Approver согласующий: тип и, для групп, имя группы
+type Approver struct {
+	Type  ApproverType
+	Group string
+}
+
+var (
+	ErrNoApprovalSteps     = errors.New("no approval steps configured for role")
+	ErrDuplicateRequest    = errors.New("employee already has an open request for this role")
+	ErrInvalidTransition   = errors.New("invalid request status transition")
+	ErrNotApprover         = errors.New("employee is not an approver of the current step")
+	ErrNotRequester        = errors.New("only the requester can cancel the request")
+	ErrUnknownApproverType = errors.New("unknown approver type")
+	ErrConcurrentUpdate    = errors.New("request was modified concurrently")
+	ErrUnauthenticated     = httpapi.ErrUnauthenticated
+)
+
+type Repo interface {
+	FindById(id int64) (*Request, error)
+	FindByEmployeeId(employeeId int64) ([]*Request, error)
+	FindOverdue(now time.Time) ([]*Request, error)
+	FindApproved() ([]*Request, error)
+	FindSteps(roleId int64) ([]*Step, error)
+	FindHistory(requestId int64) ([]*HistoryEntry, error)
+	FindApproverIds(request *Request, approver Approver) ([]int64, error)
+	HasOpenRequest(employeeId int64, roleId int64) (bool, error)
+	Create(request *Request, entry *HistoryEntry) error
+	Transition(request *Request, entry *HistoryEntry) error
+}
+
+// Granter выдаёт роль сотруднику после финального согласования
+type Granter interface {
+	Assign(employeeId int64, roleId int64) error
+}
+
+// Service управляет жизненным циклом заявок на доступ
+type Service struct {
+	repo    Repo
+	granter Granter
+	now     func() time.Time
+}
+
+func NewService(repository Repo, granter Granter) *Service {
+	return &Service{repo: repository, granter: granter, now: time.Now}
+}
+
+func (s *Service) FindById(id int64) (RequestResponse, error) {
+	request, err := s.repo.FindById(id)
+	if err != nil {
+		return RequestResponse{}, fmt.Errorf("error finding access request with id %d: %w", id, err)
+	}
+
+	history, err := s.repo.FindHistory(id)
+	if err != nil {
+		return RequestResponse{}, fmt.Errorf("error finding history of access request with id %d: %w", id, err)
+	}
+
+	return *request.ToResponse(history), nil
+}
+
+func (s *Service) FindByEmployeeId(employeeId int64) ([]RequestResponse, error) {
+	requests, err := s.repo.FindByEmployeeId(employeeId)
+	if err != nil {
+		return nil, fmt.Errorf("error finding access requests of employee with id %d: %w", employeeId, err)
+	}
+
+	var responses []RequestResponse
+	for _, request := range requests {
+		responses = append(responses, *request.ToResponse(nil))
+	}
+
+	return responses, nil
+}
+
+// Submit создать заявку сотрудника на роль с обоснованием
+func (s *Service) Submit(employeeId int64, roleId int64, justification string) (RequestResponse, error) {
+	steps, err := s.repo.FindSteps(roleId)
+	if err != nil {
+		return RequestResponse{}, fmt.Errorf("error finding approval steps for role with id %d: %w", roleId, err)
+	}
+	if len(steps) == 0 {
+		return RequestResponse{}, fmt.Errorf("error submitting access request for role with id %d: %w", roleId, ErrNoApprovalSteps)
+	}
+
+	exists, err := s.repo.HasOpenRequest(employeeId, roleId)
+	if err != nil {
+		return RequestResponse{}, fmt.Errorf("error checking open access requests: %w", err)
+	}
+	if exists {
+		return RequestResponse{}, fmt.Errorf("error submitting access request for role with id %d: %w", roleId, ErrDuplicateRequest)
+	}
+
+	request := &Request{
+		EmployeeId:    employeeId,
+		RoleId:        roleId,
+		Justification: justification,
+		Status:        StatusPending,
+		CurrentStep:   steps[0].Position,
+		StepDeadline:  s.deadline(steps[0]),
+	}
+	entry := &HistoryEntry{
+		ToStatus: StatusPending,
+		Step:     request.CurrentStep,
+		ActorId:  &employeeId,
+		Comment:  justification,
+	}
+
+	err = s.repo.Create(request, entry)
+	if err != nil {
+		return RequestResponse{}, fmt.Errorf("error creating access request: %w", err)
+	}
+
+	return *request.ToResponse([]*HistoryEntry{entry}), nil
+}
+
+// Approve согласовать текущий шаг заявки. На последнем шаге роль выдаётся автоматически.
+func (s *Service) Approve(requestId int64, approverId int64, comment string) (RequestResponse, error) {
+	request, step, steps, err := s.loadForDecision(requestId, approverId)
+	if err != nil {
+		return RequestResponse{}, err
+	}
+
+	next := nextStep(steps, step)
+	if next != nil {
+		err = s.transition(request, StatusPending, &approverId, comment, func(r *Request) {
+			r.CurrentStep = next.Position
+			r.StepDeadline = s.deadline(next)
+		})
+		if err != nil {
+			return RequestResponse{}, err
+		}
+
+		return s.FindById(requestId)
+	}
+
+	err = s.transition(request, StatusApproved, &approverId, comment, func(r *Request) {
+		r.StepDeadline = nil
+	})
+	if err != nil {
+		return RequestResponse{}, err
+	}
+
+	err = s.grant(request)
+	if err != nil {
+		return RequestResponse{}, err
+	}
+
+	return s.FindById(requestId)
+}
+
+// Reject отклонить заявку на текущем шаге
+func (s *Service) Reject(requestId int64, approverId int64, comment string) (RequestResponse, error) {
+	request, _, _, err := s.loadForDecision(requestId, approverId)
+	if err != nil {
+		return RequestResponse{}, err
+	}
+
+	err = s.transition(request, StatusRejected, &approverId, comment, func(r *Request) {
+		r.StepDeadline = nil
+	})
+	if err != nil {
+		return RequestResponse{}, err
+	}
+
+	return s.FindById(requestId)
+}
+
+// Cancel отменить заявку. Отменить её может только сам заявитель.
+func (s *Service) Cancel(requestId int64, employeeId int64) (RequestResponse, error) {
+	request, err := s.repo.FindById(requestId)
+	if err != nil {
+		return RequestResponse{}, fmt.Errorf("error finding access request with id %d: %w", requestId, err)
+	}
+	if request.EmployeeId != employeeId {
+		return RequestResponse{}, fmt.Errorf("error cancelling access request with id %d: %w", requestId, ErrNotRequester)
+	}
+
+	err = s.transition(request, StatusCancelled, &employeeId, "", func(r *Request) {
+		r.StepDeadline = nil
+	})
+	if err != nil {
+		return RequestResponse{}, err
+	}
+
+	return s.FindById(requestId)
+}
+
+// RetryGrant повторить выдачу роли по согласованной заявке, если прошлая попытка завершилась ошибкой
+func (s *Service) RetryGrant(requestId int64) (RequestResponse, error) {
+	request, err := s.repo.FindById(requestId)
+	if err != nil {
+		return RequestResponse{}, fmt.Errorf("error finding access request with id %d: %w", requestId, err)
+	}
+	if request.Status != StatusApproved {
+		return RequestResponse{}, fmt.Errorf("error granting role for access request with id %d: %w", requestId, ErrInvalidTransition)
+	}
+
+	err = s.grant(request)
+	if err != nil {
+		return RequestResponse{}, err
+	}
+
+	return s.FindById(requestId)
+}
+
+// EscalateOverdue эскалировать заявки, по которым согласующие не приняли решение в срок.
+// Возвращает количество эскалированных заявок.
+func (s *Service) EscalateOverdue() (int, error) {
+	requests, err := s.repo.FindOverdue(s.now())
+	if err != nil {
+		return 0, fmt.Errorf("error finding overdue access requests: %w", err)
+	}
+
+	escalated := 0
+	for _, request := range requests {
+		err = s.transition(request, StatusEscalated, nil, "step timed out", func(r *Request) {
+			r.StepDeadline = nil
+		})
+		if errors.Is(err, ErrConcurrentUpdate) {
+			continue
+		}
+		if err != nil {
+			return escalated, err
+		}
+		escalated++
+	}
+
+	return escalated, nil
+}
+
+// RetryApproved повторить выдачу ролей по всем согласованным заявкам, где она ещё не удалась.
+// Возвращает количество заявок, по которым роль выдана.
+func (s *Service) RetryApproved() (int, error) {
+	requests, err := s.repo.FindApproved()
+	if err != nil {
+		return 0, fmt.Errorf("error finding approved access requests: %w", err)
+	}
+
+	granted := 0
+	for _, request := range requests {
+		err = s.grant(request)
+		if errors.Is(err, ErrConcurrentUpdate) {
+			continue
+		}
+		if err != nil {
+			log.Printf("error retrying grant: %v", err)
+			continue
+		}
+		granted++
+	}
+
+	return granted, nil
+}
+
+// Run каждые interval эскалировать просроченные заявки и повторять выдачу ролей по согласованным,
+// пока не отменён ctx
+func (s *Service) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := s.EscalateOverdue(); err != nil {
+			log.Printf("error escalating access requests: %v", err)
+		}
+		if _, err := s.RetryApproved(); err != nil {
+			log.Printf("error retrying access request grants: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// FindVisible заявка с историей, если сотрудник её подал или может принять по ней решение.
+// Остальным она не видна, как несуществующая.
+func (s *Service) FindVisible(requestId int64, employeeId int64) (RequestResponse, error) {
+	response, err := s.FindById(requestId)
+	if err != nil || response.EmployeeId == employeeId {
+		return response, err
+	}
+
+	approvers, err := s.Approvers(requestId)
+	if err != nil {
+		return RequestResponse{}, err
+	}
+	if !slices.Contains(approvers, employeeId) {
+		return RequestResponse{}, fmt.Errorf("error finding access request with id %d: %w", requestId, database.ErrRecordNotFound)
+	}
+
+	return response, nil
+}
+
+// Approvers сотрудники, которые сейчас могут принять решение по заявке
+func (s *Service) Approvers(requestId int64) ([]int64, error) {
+	request, err := s.repo.FindById(requestId)
+	if err != nil {
+		return nil, fmt.Errorf("error finding access request with id %d: %w", requestId, err)
+	}
+
+	steps, err := s.repo.FindSteps(request.RoleId)
+	if err != nil {
+		return nil, fmt.Errorf("error finding approval steps for role with id %d: %w", request.RoleId, err)
+	}
+
+	step := findStep(steps, request.CurrentStep)
+	if step == nil {
+		return nil, fmt.Errorf("error finding approval step %d of access request with id %d: %w",
+			request.CurrentStep, requestId, ErrNoApprovalSteps)
+	}
+
+	return s.approvers(request, step)
+}
+
+func (s *Service) approvers(request *Request, step *Step) ([]int64, error) {
+	candidates := []Approver{{Type: step.ApproverType, Group: deref(step.ApproverGroup)}}
+	if request.Status == StatusEscalated {
+		if step.EscalationGroup != nil {
+			candidates = append(candidates, Approver{Type: ApproverGroup, Group: *step.EscalationGroup})
+		} else {
+			candidates = append(candidates, Approver{Type: ApproverRoleOwner})
+		}
+	}
+
+	var ids []int64
+	for _, candidate := range candidates {
+		found, err := s.repo.FindApproverIds(request, candidate)
+		if err != nil {
+			return nil, fmt.Errorf("error finding approvers of access request with id %d: %w", request.Id, err)
+		}
+		for _, id := range found {
+			if id != request.EmployeeId && !slices.Contains(ids, id) {
+				ids = append(ids, id)
+			}
+		}
+	}
+
+	return ids, nil
+}
+
+func (s *Service) loadForDecision(requestId int64, approverId int64) (*Request, *Step, []*Step, error) {
+	request, err := s.repo.FindById(requestId)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("error finding access request with id %d: %w", requestId, err)
+	}
+	if request.Status != StatusPending && request.Status != StatusEscalated {
+		return nil, nil, nil, fmt.Errorf("error deciding on access request with id %d in status %s: %w",
+			requestId, request.Status, ErrInvalidTransition)
+	}
+
+	steps, err := s.repo.FindSteps(request.RoleId)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("error finding approval steps for role with id %d: %w", request.RoleId, err)
+	}
+
+	step := findStep(steps, request.CurrentStep)
+	if step == nil {
+		return nil, nil, nil, fmt.Errorf("error finding approval step %d of access request with id %d: %w",
+			request.CurrentStep, requestId, ErrNoApprovalSteps)
+	}
+
+	approvers, err := s.approvers(request, step)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if !slices.Contains(approvers, approverId) {
+		return nil, nil, nil, fmt.Errorf("error deciding on access request with id %d by employee %d: %w",
+			requestId, approverId, ErrNotApprover)
+	}
+
+	return request, step, steps, nil
+}
+
+// grant выдать роль по согласованной заявке. Если роль выдать нельзя в принципе, заявка
+// завершается статусом failed с причиной в истории, иначе остаётся согласованной до повтора.
+func (s *Service) grant(request *Request) error {
+	err := s.granter.Assign(request.EmployeeId, request.RoleId)
+	if errors.Is(err, sod.ErrViolation) || errors.Is(err, database.ErrRecordNotFound) {
+		if err := s.transition(request, StatusFailed, nil, err.Error(), func(*Request) {}); err != nil {
+			return err
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("error granting role for access request with id %d: %w", request.Id, err)
+	}
+
+	return s.transition(request, StatusGranted, nil, "role granted automatically", func(*Request) {})
+}
+
+func (s *Service) transition(request *Request, to Status, actorId *int64, comment string, apply func(*Request)) error {
+	if !slices.Contains(transitions[request.Status], to) {
+		return fmt.Errorf("error moving access request with id %d from %s to %s: %w",
+			request.Id, request.Status, to, ErrInvalidTransition)
+	}
+
+	entry := &HistoryEntry{
+		FromStatus: request.Status,
+		ToStatus:   to,
+		Step:       request.CurrentStep,
+		ActorId:    actorId,
+		Comment:    comment,
+	}
+
+	request.Status = to
+	apply(request)
+
+	err := s.repo.Transition(request, entry)
+	if err != nil {
+		return fmt.Errorf("error moving access request with id %d from %s to %s: %w",
+			request.Id, entry.FromStatus, to, err)
+	}
+
+	return nil
+}
+
+func (s *Service) deadline(step *Step) *time.Time {
+	if step.TimeoutSeconds <= 0 {
+		return nil
+	}
+	deadline := s.now().Add(time.Duration(step.TimeoutSeconds) * time.Second)
+
+	return &deadline
+}
+
+func findStep(steps []*Step, position int) *Step {
+	for _, step := range steps {
+		if step.Position == position {
+			return step
+		}
+	}
+
+	return nil
+}
+
+func nextStep(steps []*Step, current *Step) *Step {
+	for _, step := range steps {
+		if step.Position > current.Position {
+			return step
+		}
+	}
+
+	return nil
+}
+
+func deref(value *string) string {
+	if value == nil {
+		return ""
+	}
+
+	return *value
+}
@@ -0,0 +1,374 @@
+package workflow
+
+import (
+	"errors"
+	"fmt"
+	assertpackage "github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"idm/inner/httpapi"
+	"idm/inner/sod"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type MockRepo struct {
+	mock.Mock
+}
+
+func (m *MockRepo) FindById(id int64) (*Request, error) {
+	args := m.Called(id)
+	return args.Get(0).(*Request), args.Error(1)
+}
+
+func (m *MockRepo) FindByEmployeeId(employeeId int64) ([]*Request, error) {
+	args := m.Called(employeeId)
+	return args.Get(0).([]*Request), args.Error(1)
+}
+
+func (m *MockRepo) FindOverdue(now time.Time) ([]*Request, error) {
+	args := m.Called(now)
+	return args.Get(0).([]*Request), args.Error(1)
+}
+
+func (m *MockRepo) FindApproved() ([]*Request, error) {
+	args := m.Called()
+	return args.Get(0).([]*Request), args.Error(1)
+}
+
+func (m *MockRepo) FindSteps(roleId int64) ([]*Step, error) {
+	args := m.Called(roleId)
+	return args.Get(0).([]*Step), args.Error(1)
+}
+
+func (m *MockRepo) FindHistory(requestId int64) ([]*HistoryEntry, error) {
+	args := m.Called(requestId)
+	return args.Get(0).([]*HistoryEntry), args.Error(1)
+}
+
+func (m *MockRepo) FindApproverIds(request *Request, approver Approver) ([]int64, error) {
+	args := m.Called(request, approver)
+	return args.Get(0).([]int64), args.Error(1)
+}
+
+func (m *MockRepo) HasOpenRequest(employeeId int64, roleId int64) (bool, error) {
+	args := m.Called(employeeId, roleId)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRepo) Create(request *Request, entry *HistoryEntry) error {
+	args := m.Called(request, entry)
+	return args.Error(0)
+}
+
+func (m *MockRepo) Transition(request *Request, entry *HistoryEntry) error {
+	args := m.Called(request, entry)
+	return args.Error(0)
+}
+
+type MockGranter struct {
+	mock.Mock
+}
+
+func (m *MockGranter) Assign(employeeId int64, roleId int64) error {
+	args := m.Called(employeeId, roleId)
+	return args.Error(0)
+}
+
+func TestWorkflowService(t *testing.T) {
+	assert := assertpackage.New(t)
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	group := "security"
+
+	twoSteps := []*Step{
+		{Id: 1, RoleId: 10, Position: 1, ApproverType: ApproverManager, TimeoutSeconds: 3600},
+		{Id: 2, RoleId: 10, Position: 2, ApproverType: ApproverGroup, ApproverGroup: &group},
+	}
+
+	newService := func() (*Service, *MockRepo, *MockGranter) {
+		repo := &MockRepo{}
+		granter := &MockGranter{}
+		service := NewService(repo, granter)
+		service.now = func() time.Time { return now }
+
+		return service, repo, granter
+	}
+
+	t.Run("Submit should create a pending request on the first step", func(t *testing.T) {
+		service, repo, _ := newService()
+
+		repo.On("FindSteps", int64(10)).Return(twoSteps, nil)
+		repo.On("HasOpenRequest", int64(1), int64(10)).Return(false, nil)
+		repo.On("Create", mock.AnythingOfType("*workflow.Request"), mock.AnythingOfType("*workflow.HistoryEntry")).
+			Return(nil)
+
+		got, err := service.Submit(1, 10, "need access to payments")
+
+		assert.NoError(err)
+		assert.Equal(StatusPending, got.Status)
+		assert.Equal(1, got.CurrentStep)
+		assert.Equal(now.Add(time.Hour), *got.StepDeadline)
+		assert.Len(got.History, 1)
+		assert.Equal(StatusPending, got.History[0].ToStatus)
+	})
+
+	t.Run("Submit should fail when role has no approval steps", func(t *testing.T) {
+		service, repo, _ := newService()
+
+		repo.On("FindSteps", int64(10)).Return([]*Step{}, nil)
+
+		_, err := service.Submit(1, 10, "need access")
+
+		assert.ErrorIs(err, ErrNoApprovalSteps)
+		repo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Submit should fail when an open request exists", func(t *testing.T) {
+		service, repo, _ := newService()
+
+		repo.On("FindSteps", int64(10)).Return(twoSteps, nil)
+		repo.On("HasOpenRequest", int64(1), int64(10)).Return(true, nil)
+
+		_, err := service.Submit(1, 10, "need access")
+
+		assert.ErrorIs(err, ErrDuplicateRequest)
+	})
+
+	t.Run("Submit should fail when a concurrent request wins the unique index", func(t *testing.T) {
+		service, repo, _ := newService()
+
+		repo.On("FindSteps", int64(10)).Return(twoSteps, nil)
+		repo.On("HasOpenRequest", int64(1), int64(10)).Return(false, nil)
+		repo.On("Create", mock.AnythingOfType("*workflow.Request"), mock.AnythingOfType("*workflow.HistoryEntry")).
+			Return(ErrDuplicateRequest)
+
+		_, err := service.Submit(1, 10, "need access")
+
+		assert.ErrorIs(err, ErrDuplicateRequest)
+	})
+
+	t.Run("Approve should move request to the next step", func(t *testing.T) {
+		service, repo, granter := newService()
+		request := &Request{Id: 5, EmployeeId: 1, RoleId: 10, Status: StatusPending, CurrentStep: 1}
+
+		repo.On("FindById", int64(5)).Return(request, nil)
+		repo.On("FindSteps", int64(10)).Return(twoSteps, nil)
+		repo.On("FindApproverIds", request, Approver{Type: ApproverManager}).Return([]int64{2}, nil)
+		repo.On("Transition", request, mock.AnythingOfType("*workflow.HistoryEntry")).Return(nil)
+		repo.On("FindHistory", int64(5)).Return([]*HistoryEntry{}, nil)
+
+		got, err := service.Approve(5, 2, "ok")
+
+		assert.NoError(err)
+		assert.Equal(StatusPending, got.Status)
+		assert.Equal(2, got.CurrentStep)
+		assert.Nil(got.StepDeadline)
+		granter.AssertNotCalled(t, "Assign", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Approve on the last step should grant the role", func(t *testing.T) {
+		service, repo, granter := newService()
+		request := &Request{Id: 5, EmployeeId: 1, RoleId: 10, Status: StatusPending, CurrentStep: 2}
+
+		repo.On("FindById", int64(5)).Return(request, nil)
+		repo.On("FindSteps", int64(10)).Return(twoSteps, nil)
+		repo.On("FindApproverIds", request, Approver{Type: ApproverGroup, Group: group}).Return([]int64{3, 4}, nil)
+		repo.On("Transition", request, mock.AnythingOfType("*workflow.HistoryEntry")).Return(nil)
+		repo.On("FindHistory", int64(5)).Return([]*HistoryEntry{}, nil)
+		granter.On("Assign", int64(1), int64(10)).Return(nil)
+
+		got, err := service.Approve(5, 4, "approved")
+
+		assert.NoError(err)
+		assert.Equal(StatusGranted, got.Status)
+		assert.True(granter.AssertNumberOfCalls(t, "Assign", 1))
+		assert.True(repo.AssertNumberOfCalls(t, "Transition", 2))
+	})
+
+	t.Run("Approve should leave request approved when grant fails", func(t *testing.T) {
+		service, repo, granter := newService()
+		request := &Request{Id: 5, EmployeeId: 1, RoleId: 10, Status: StatusPending, CurrentStep: 2}
+
+		repo.On("FindById", int64(5)).Return(request, nil)
+		repo.On("FindSteps", int64(10)).Return(twoSteps, nil)
+		repo.On("FindApproverIds", request, Approver{Type: ApproverGroup, Group: group}).Return([]int64{3}, nil)
+		repo.On("Transition", request, mock.AnythingOfType("*workflow.HistoryEntry")).Return(nil)
+		granter.On("Assign", int64(1), int64(10)).Return(errors.New("database error"))
+
+		_, err := service.Approve(5, 3, "approved")
+
+		assert.Error(err)
+		assert.Equal(StatusApproved, request.Status)
+	})
+
+	t.Run("Approve should fail the request when the role cannot be granted", func(t *testing.T) {
+		service, repo, granter := newService()
+		request := &Request{Id: 5, EmployeeId: 1, RoleId: 10, Status: StatusPending, CurrentStep: 2}
+		var entries []*HistoryEntry
+
+		repo.On("FindById", int64(5)).Return(request, nil)
+		repo.On("FindSteps", int64(10)).Return(twoSteps, nil)
+		repo.On("FindApproverIds", request, Approver{Type: ApproverGroup, Group: group}).Return([]int64{3}, nil)
+		repo.On("Transition", request, mock.AnythingOfType("*workflow.HistoryEntry")).Run(func(args mock.Arguments) {
+			entries = append(entries, args.Get(1).(*HistoryEntry))
+		}).Return(nil)
+		granter.On("Assign", int64(1), int64(10)).Return(fmt.Errorf("error assigning role: %w", sod.ErrViolation))
+
+		_, err := service.Approve(5, 3, "approved")
+
+		assert.ErrorIs(err, sod.ErrViolation)
+		assert.Equal(StatusFailed, request.Status)
+		if assert.Len(entries, 2) {
+			assert.Equal(StatusApproved, entries[1].FromStatus)
+			assert.Contains(entries[1].Comment, sod.ErrViolation.Error())
+		}
+
+		_, err = service.RetryGrant(5)
+		assert.ErrorIs(err, ErrInvalidTransition)
+		assert.True(granter.AssertNumberOfCalls(t, "Assign", 1))
+	})
+
+	t.Run("Approve should reject employees who are not approvers", func(t *testing.T) {
+		service, repo, _ := newService()
+		request := &Request{Id: 5, EmployeeId: 1, RoleId: 10, Status: StatusPending, CurrentStep: 1}
+
+		repo.On("FindById", int64(5)).Return(request, nil)
+		repo.On("FindSteps", int64(10)).Return(twoSteps, nil)
+		repo.On("FindApproverIds", request, Approver{Type: ApproverManager}).Return([]int64{2}, nil)
+
+		_, err := service.Approve(5, 7, "ok")
+
+		assert.ErrorIs(err, ErrNotApprover)
+		repo.AssertNotCalled(t, "Transition", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Approve should not allow self approval", func(t *testing.T) {
+		service, repo, _ := newService()
+		request := &Request{Id: 5, EmployeeId: 1, RoleId: 10, Status: StatusPending, CurrentStep: 1}
+
+		repo.On("FindById", int64(5)).Return(request, nil)
+		repo.On("FindSteps", int64(10)).Return(twoSteps, nil)
+		repo.On("FindApproverIds", request, Approver{Type: ApproverManager}).Return([]int64{1}, nil)
+
+		_, err := service.Approve(5, 1, "ok")
+
+		assert.ErrorIs(err, ErrNotApprover)
+	})
+
+	t.Run("Reject should not be possible for a finished request", func(t *testing.T) {
+		service, repo, _ := newService()
+		request := &Request{Id: 5, EmployeeId: 1, RoleId: 10, Status: StatusGranted, CurrentStep: 2}
+
+		repo.On("FindById", int64(5)).Return(request, nil)
+
+		_, err := service.Reject(5, 2, "no")
+
+		assert.ErrorIs(err, ErrInvalidTransition)
+	})
+
+	t.Run("Cancel should only be allowed for the requester", func(t *testing.T) {
+		service, repo, _ := newService()
+		request := &Request{Id: 5, EmployeeId: 1, RoleId: 10, Status: StatusPending, CurrentStep: 1}
+
+		repo.On("FindById", int64(5)).Return(request, nil)
+
+		_, err := service.Cancel(5, 2)
+
+		assert.ErrorIs(err, ErrNotRequester)
+	})
+
+	t.Run("EscalateOverdue should escalate requests and let the escalation group approve", func(t *testing.T) {
+		service, repo, _ := newService()
+		escalation := "directors"
+		steps := []*Step{{Id: 1, RoleId: 10, Position: 1, ApproverType: ApproverManager, TimeoutSeconds: 60,
+			EscalationGroup: &escalation}}
+		request := &Request{Id: 5, EmployeeId: 1, RoleId: 10, Status: StatusPending, CurrentStep: 1}
+
+		repo.On("FindOverdue", now).Return([]*Request{request}, nil)
+		repo.On("Transition", request, mock.AnythingOfType("*workflow.HistoryEntry")).Return(nil)
+		repo.On("FindSteps", int64(10)).Return(steps, nil)
+		repo.On("FindApproverIds", request, Approver{Type: ApproverManager}).Return([]int64{2}, nil)
+		repo.On("FindApproverIds", request, Approver{Type: ApproverGroup, Group: escalation}).Return([]int64{9}, nil)
+
+		count, err := service.EscalateOverdue()
+
+		assert.NoError(err)
+		assert.Equal(1, count)
+		assert.Equal(StatusEscalated, request.Status)
+
+		repo.On("FindById", int64(5)).Return(request, nil)
+		approvers, err := service.Approvers(5)
+
+		assert.NoError(err)
+		assert.Equal([]int64{2, 9}, approvers)
+	})
+	t.Run("RetryApproved should grant roles of approved requests and skip failures", func(t *testing.T) {
+		service, repo, granter := newService()
+		first := &Request{Id: 5, EmployeeId: 1, RoleId: 10, Status: StatusApproved, CurrentStep: 2}
+		second := &Request{Id: 6, EmployeeId: 2, RoleId: 11, Status: StatusApproved, CurrentStep: 2}
+
+		repo.On("FindApproved").Return([]*Request{first, second}, nil)
+		granter.On("Assign", int64(1), int64(10)).Return(errors.New("conflicting role"))
+		granter.On("Assign", int64(2), int64(11)).Return(nil)
+		repo.On("Transition", second, mock.AnythingOfType("*workflow.HistoryEntry")).Return(nil)
+
+		count, err := service.RetryApproved()
+
+		assert.NoError(err)
+		assert.Equal(1, count)
+		assert.Equal(StatusApproved, first.Status)
+		assert.Equal(StatusGranted, second.Status)
+	})
+}
+
+func TestWorkflowHandler(t *testing.T) {
+	assert := assertpackage.New(t)
+
+	newHandler := func(employeeId int64) (*Handler, *MockRepo) {
+		repo := &MockRepo{}
+		handler := NewHandler(NewService(repo, &MockGranter{}), httpapi.AuthenticatorFunc[int64](func(r *http.Request) (int64, error) {
+			if employeeId == 0 {
+				return 0, ErrUnauthenticated
+			}
+			return employeeId, nil
+		}))
+
+		return handler, repo
+	}
+	do := func(handler http.Handler, method string, path string, body string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return recorder
+	}
+	steps := []*Step{{Id: 1, RoleId: 10, Position: 1, ApproverType: ApproverManager}}
+
+	t.Run("should require a signed in employee", func(t *testing.T) {
+		handler, _ := newHandler(0)
+
+		assert.Equal(http.StatusUnauthorized, do(handler, http.MethodGet, "/", "").Code)
+	})
+
+	t.Run("should submit a request on behalf of the current employee", func(t *testing.T) {
+		handler, repo := newHandler(1)
+		repo.On("FindSteps", int64(10)).Return(steps, nil)
+		repo.On("HasOpenRequest", int64(1), int64(10)).Return(true, nil)
+
+		recorder := do(handler, http.MethodPost, "/", `{"role_id": 10, "justification": "audit"}`)
+
+		assert.Equal(http.StatusConflict, recorder.Code)
+	})
+
+	t.Run("should hide requests from employees who neither filed nor approve them", func(t *testing.T) {
+		handler, repo := newHandler(3)
+		request := &Request{Id: 5, EmployeeId: 1, RoleId: 10, Status: StatusPending, CurrentStep: 1}
+		repo.On("FindById", int64(5)).Return(request, nil)
+		repo.On("FindHistory", int64(5)).Return([]*HistoryEntry{}, nil)
+		repo.On("FindSteps", int64(10)).Return(steps, nil)
+		repo.On("FindApproverIds", request, Approver{Type: ApproverManager}).Return([]int64{2}, nil)
+
+		assert.Equal(http.StatusNotFound, do(handler, http.MethodGet, "/5", "").Code)
+		assert.Equal(http.StatusForbidden, do(handler, http.MethodPost, "/5/approve", `{}`).Code)
+	})
+}
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS employee_roles;
\ No newline at end of file
@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS employee_roles (
+    employee_id BIGINT NOT NULL REFERENCES employees (id) ON DELETE CASCADE,
+    role_id BIGINT NOT NULL REFERENCES roles (id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (employee_id, role_id)
+);
\ No newline at end of file
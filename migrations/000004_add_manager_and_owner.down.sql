@@ -0,0 +1,2 @@
+ALTER TABLE roles DROP COLUMN IF EXISTS owner_id;
+ALTER TABLE employees DROP COLUMN IF EXISTS manager_id;
\ No newline at end of file
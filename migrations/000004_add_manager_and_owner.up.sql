@@ -0,0 +1,2 @@
+ALTER TABLE employees ADD COLUMN IF NOT EXISTS manager_id BIGINT REFERENCES employees (id) ON DELETE SET NULL;
+ALTER TABLE roles ADD COLUMN IF NOT EXISTS owner_id BIGINT REFERENCES employees (id) ON DELETE SET NULL;
\ No newline at end of file
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS access_request_history;
+DROP TABLE IF EXISTS access_requests;
+DROP TABLE IF EXISTS approval_steps;
+DROP TABLE IF EXISTS approver_groups;
\ No newline at end of file
@@ -0,0 +1,41 @@
+CREATE TABLE IF NOT EXISTS approver_groups (
+    name TEXT NOT NULL,
+    employee_id BIGINT NOT NULL REFERENCES employees (id) ON DELETE CASCADE,
+    PRIMARY KEY (name, employee_id)
+);
+
+CREATE TABLE IF NOT EXISTS approval_steps (
+    id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    role_id BIGINT NOT NULL REFERENCES roles (id) ON DELETE CASCADE,
+    position INT NOT NULL,
+    approver_type TEXT NOT NULL CHECK (approver_type IN ('role_owner', 'manager', 'group')),
+    approver_group TEXT,
+    timeout_seconds BIGINT NOT NULL DEFAULT 0,
+    escalation_group TEXT,
+    UNIQUE (role_id, position)
+);
+
+CREATE TABLE IF NOT EXISTS access_requests (
+    id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    employee_id BIGINT NOT NULL REFERENCES employees (id) ON DELETE CASCADE,
+    role_id BIGINT NOT NULL REFERENCES roles (id) ON DELETE CASCADE,
+    justification TEXT NOT NULL,
+    status TEXT NOT NULL,
+    current_step INT NOT NULL DEFAULT 1,
+    step_deadline TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS access_requests_status_idx ON access_requests (status, step_deadline);
+
+CREATE TABLE IF NOT EXISTS access_request_history (
+    id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    request_id BIGINT NOT NULL REFERENCES access_requests (id) ON DELETE CASCADE,
+    from_status TEXT NOT NULL,
+    to_status TEXT NOT NULL,
+    step INT NOT NULL,
+    actor_id BIGINT REFERENCES employees (id) ON DELETE SET NULL,
+    comment TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS sod_exceptions;
+DROP TABLE IF EXISTS sod_rule_roles;
+DROP TABLE IF EXISTS sod_rules;
\ No newline at end of file
@@ -0,0 +1,26 @@
+CREATE TABLE IF NOT EXISTS sod_rules (
+    id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE,
+    description TEXT NOT NULL DEFAULT '',
+    max_roles INT NOT NULL DEFAULT 1 CHECK (max_roles > 0),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS sod_rule_roles (
+    rule_id BIGINT NOT NULL REFERENCES sod_rules (id) ON DELETE CASCADE,
+    role_id BIGINT NOT NULL REFERENCES roles (id) ON DELETE CASCADE,
+    PRIMARY KEY (rule_id, role_id)
+);
+
+CREATE TABLE IF NOT EXISTS sod_exceptions (
+    id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    rule_id BIGINT NOT NULL REFERENCES sod_rules (id) ON DELETE CASCADE,
+    employee_id BIGINT NOT NULL REFERENCES employees (id) ON DELETE CASCADE,
+    reason TEXT NOT NULL,
+    approved_by BIGINT REFERENCES employees (id) ON DELETE SET NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS sod_exceptions_lookup_idx ON sod_exceptions (rule_id, employee_id, expires_at);
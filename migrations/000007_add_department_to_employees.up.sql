@@ -0,0 +1 @@
+ALTER TABLE employees ADD COLUMN IF NOT EXISTS department TEXT NOT NULL DEFAULT '';
\ No newline at end of file
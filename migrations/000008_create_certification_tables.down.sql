@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS certification_items;
+DROP TABLE IF EXISTS certification_campaigns;
\ No newline at end of file
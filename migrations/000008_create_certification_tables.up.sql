@@ -0,0 +1,30 @@
+CREATE TABLE IF NOT EXISTS certification_campaigns (
+    id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    name TEXT NOT NULL,
+    owner_id BIGINT NOT NULL REFERENCES employees (id),
+    role_ids BIGINT[] NOT NULL DEFAULT '{}',
+    departments TEXT[] NOT NULL DEFAULT '{}',
+    status TEXT NOT NULL,
+    deadline TIMESTAMPTZ NOT NULL,
+    reminder_interval_seconds BIGINT NOT NULL DEFAULT 86400,
+    closed_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS certification_items (
+    id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    campaign_id BIGINT NOT NULL REFERENCES certification_campaigns (id) ON DELETE CASCADE,
+    employee_id BIGINT NOT NULL REFERENCES employees (id) ON DELETE CASCADE,
+    role_id BIGINT NOT NULL REFERENCES roles (id) ON DELETE CASCADE,
+    reviewer_id BIGINT NOT NULL REFERENCES employees (id),
+    decision TEXT NOT NULL DEFAULT 'pending',
+    auto_revoked BOOLEAN NOT NULL DEFAULT FALSE,
+    comment TEXT NOT NULL DEFAULT '',
+    decided_at TIMESTAMPTZ,
+    reminders_sent INT NOT NULL DEFAULT 0,
+    last_reminded_at TIMESTAMPTZ,
+    UNIQUE (campaign_id, employee_id, role_id)
+);
+
+CREATE INDEX IF NOT EXISTS certification_items_reviewer_idx ON certification_items (reviewer_id, decision);
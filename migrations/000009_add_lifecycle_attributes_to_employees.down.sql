@@ -0,0 +1,3 @@
+ALTER TABLE employees DROP COLUMN IF EXISTS terminated_at;
+ALTER TABLE employees DROP COLUMN IF EXISTS status;
+ALTER TABLE employees DROP COLUMN IF EXISTS title;
\ No newline at end of file
@@ -0,0 +1,3 @@
+ALTER TABLE employees ADD COLUMN IF NOT EXISTS title TEXT NOT NULL DEFAULT '';
+ALTER TABLE employees ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT 'active';
+ALTER TABLE employees ADD COLUMN IF NOT EXISTS terminated_at TIMESTAMPTZ;
\ No newline at end of file
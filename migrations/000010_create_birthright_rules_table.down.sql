@@ -0,0 +1 @@
+DROP TABLE IF EXISTS birthright_rules;
\ No newline at end of file
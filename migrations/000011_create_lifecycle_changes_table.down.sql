@@ -0,0 +1 @@
+DROP TABLE IF EXISTS lifecycle_changes;
\ No newline at end of file
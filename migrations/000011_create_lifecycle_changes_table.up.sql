@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS lifecycle_changes (
+    id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    kind TEXT NOT NULL CHECK (kind IN ('hire', 'transfer', 'terminate', 'purge')),
+    employee_id BIGINT REFERENCES employees (id) ON DELETE SET NULL,
+    payload JSONB NOT NULL DEFAULT '{}',
+    status TEXT NOT NULL,
+    error TEXT NOT NULL DEFAULT '',
+    effective_at TIMESTAMPTZ NOT NULL,
+    applied_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS lifecycle_changes_due_idx ON lifecycle_changes (status, effective_at);
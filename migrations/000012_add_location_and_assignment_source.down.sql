@@ -0,0 +1,3 @@
+ALTER TABLE employee_roles DROP COLUMN IF EXISTS source;
+ALTER TABLE employees DROP COLUMN IF EXISTS employment_type;
+ALTER TABLE employees DROP COLUMN IF EXISTS location;
@@ -0,0 +1,3 @@
+ALTER TABLE employees ADD COLUMN IF NOT EXISTS location TEXT NOT NULL DEFAULT '';
+ALTER TABLE employees ADD COLUMN IF NOT EXISTS employment_type TEXT NOT NULL DEFAULT '';
+ALTER TABLE employee_roles ADD COLUMN IF NOT EXISTS source TEXT NOT NULL DEFAULT 'manual';
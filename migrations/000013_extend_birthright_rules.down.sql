@@ -0,0 +1,13 @@
+ALTER TABLE birthright_rules ADD COLUMN IF NOT EXISTS role_id BIGINT REFERENCES roles (id) ON DELETE CASCADE;
+
+UPDATE birthright_rules r SET role_id = (SELECT min(rr.role_id) FROM birthright_rule_roles rr WHERE rr.rule_id = r.id);
+DELETE FROM birthright_rules WHERE role_id IS NULL;
+
+ALTER TABLE birthright_rules ALTER COLUMN role_id SET NOT NULL;
+ALTER TABLE birthright_rules ADD CONSTRAINT birthright_rules_department_title_role_id_key UNIQUE (department, title, role_id);
+
+DROP TABLE IF EXISTS birthright_rule_roles;
+
+ALTER TABLE birthright_rules DROP COLUMN IF EXISTS employment_type;
+ALTER TABLE birthright_rules DROP COLUMN IF EXISTS location;
+ALTER TABLE birthright_rules DROP COLUMN IF EXISTS name;
@@ -0,0 +1,16 @@
+ALTER TABLE birthright_rules ADD COLUMN IF NOT EXISTS name TEXT NOT NULL DEFAULT '';
+ALTER TABLE birthright_rules ADD COLUMN IF NOT EXISTS location TEXT NOT NULL DEFAULT '';
+ALTER TABLE birthright_rules ADD COLUMN IF NOT EXISTS employment_type TEXT NOT NULL DEFAULT '';
+
+CREATE TABLE IF NOT EXISTS birthright_rule_roles (
+    rule_id BIGINT NOT NULL REFERENCES birthright_rules (id) ON DELETE CASCADE,
+    role_id BIGINT NOT NULL REFERENCES roles (id) ON DELETE CASCADE,
+    PRIMARY KEY (rule_id, role_id)
+);
+
+INSERT INTO birthright_rule_roles (rule_id, role_id) SELECT id, role_id FROM birthright_rules;
+
+UPDATE birthright_rules SET name = 'rule ' || id WHERE name = '';
+
+ALTER TABLE birthright_rules DROP CONSTRAINT IF EXISTS birthright_rules_department_title_role_id_key;
+ALTER TABLE birthright_rules DROP COLUMN IF EXISTS role_id;
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS employees_user_name_key;
+
+ALTER TABLE employees DROP COLUMN IF EXISTS email;
+ALTER TABLE employees DROP COLUMN IF EXISTS user_name;
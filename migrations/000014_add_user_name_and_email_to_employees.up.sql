@@ -0,0 +1,4 @@
+ALTER TABLE employees ADD COLUMN IF NOT EXISTS user_name TEXT NOT NULL DEFAULT '';
+ALTER TABLE employees ADD COLUMN IF NOT EXISTS email TEXT NOT NULL DEFAULT '';
+
+CREATE UNIQUE INDEX IF NOT EXISTS employees_user_name_key ON employees (lower(user_name)) WHERE user_name <> '';
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS oidc_revoked_tokens;
+DROP TABLE IF EXISTS oidc_refresh_tokens;
+DROP TABLE IF EXISTS oidc_codes;
+DROP TABLE IF EXISTS oidc_keys;
+DROP TABLE IF EXISTS oidc_clients;
\ No newline at end of file
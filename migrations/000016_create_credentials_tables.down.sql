@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS password_history;
+DROP TABLE IF EXISTS credentials;
\ No newline at end of file
@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS mfa_events;
+DROP TABLE IF EXISTS mfa_role_policy;
+DROP TABLE IF EXISTS mfa_webauthn_sessions;
+DROP TABLE IF EXISTS mfa_webauthn_credentials;
+DROP TABLE IF EXISTS mfa_recovery_codes;
+DROP TABLE IF EXISTS mfa_totp;
\ No newline at end of file
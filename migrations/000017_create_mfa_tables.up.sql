@@ -0,0 +1,50 @@
+CREATE TABLE IF NOT EXISTS mfa_totp (
+    employee_id BIGINT PRIMARY KEY REFERENCES employees (id) ON DELETE CASCADE,
+    secret TEXT NOT NULL,
+    last_used_step BIGINT NOT NULL DEFAULT 0,
+    confirmed_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS mfa_recovery_codes (
+    id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    employee_id BIGINT NOT NULL REFERENCES employees (id) ON DELETE CASCADE,
+    hash TEXT NOT NULL,
+    used_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS mfa_recovery_codes_employee_idx ON mfa_recovery_codes (employee_id);
+
+CREATE TABLE IF NOT EXISTS mfa_webauthn_credentials (
+    id TEXT PRIMARY KEY,
+    employee_id BIGINT NOT NULL REFERENCES employees (id) ON DELETE CASCADE,
+    name TEXT NOT NULL DEFAULT '',
+    data JSONB NOT NULL,
+    last_used_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS mfa_webauthn_credentials_employee_idx ON mfa_webauthn_credentials (employee_id);
+
+CREATE TABLE IF NOT EXISTS mfa_webauthn_sessions (
+    employee_id BIGINT NOT NULL REFERENCES employees (id) ON DELETE CASCADE,
+    kind TEXT NOT NULL CHECK (kind IN ('registration', 'login')),
+    data JSONB NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (employee_id, kind)
+);
+
+CREATE TABLE IF NOT EXISTS mfa_role_policy (
+    role_id BIGINT PRIMARY KEY REFERENCES roles (id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS mfa_events (
+    id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    employee_id BIGINT NOT NULL REFERENCES employees (id) ON DELETE CASCADE,
+    kind TEXT NOT NULL,
+    detail TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS mfa_events_employee_idx ON mfa_events (employee_id, created_at);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS ldap_sync_state;
+DROP TABLE IF EXISTS ldap_role_grants;
+DROP TABLE IF EXISTS ldap_links;
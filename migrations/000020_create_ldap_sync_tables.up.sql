@@ -0,0 +1,22 @@
+CREATE TABLE IF NOT EXISTS ldap_links (
+    employee_id BIGINT PRIMARY KEY REFERENCES employees (id) ON DELETE CASCADE,
+    source TEXT NOT NULL,
+    external_id TEXT NOT NULL,
+    dn TEXT NOT NULL,
+    removed_at TIMESTAMPTZ,
+    synced_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (source, external_id)
+);
+
+CREATE TABLE IF NOT EXISTS ldap_role_grants (
+    employee_id BIGINT NOT NULL REFERENCES employees (id) ON DELETE CASCADE,
+    role_id BIGINT NOT NULL REFERENCES roles (id) ON DELETE CASCADE,
+    source TEXT NOT NULL,
+    PRIMARY KEY (employee_id, role_id, source)
+);
+
+CREATE TABLE IF NOT EXISTS ldap_sync_state (
+    source TEXT PRIMARY KEY,
+    high_water_mark TEXT NOT NULL DEFAULT '',
+    last_run_at TIMESTAMPTZ
+);
@@ -0,0 +1,7 @@
+DROP TRIGGER IF EXISTS employee_roles_outbox ON employee_roles;
+DROP TRIGGER IF EXISTS roles_outbox ON roles;
+DROP TRIGGER IF EXISTS employees_outbox ON employees;
+DROP FUNCTION IF EXISTS outbox_employee_roles();
+DROP FUNCTION IF EXISTS outbox_roles();
+DROP FUNCTION IF EXISTS outbox_employees();
+DROP TABLE IF EXISTS outbox;
@@ -0,0 +1,90 @@
+CREATE TABLE IF NOT EXISTS outbox (
+    id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    event_type TEXT NOT NULL,
+    aggregate_type TEXT NOT NULL,
+    aggregate_id BIGINT NOT NULL,
+    payload JSONB NOT NULL,
+    occurred_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    attempts INT NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_error TEXT NOT NULL DEFAULT '',
+    delivered_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS outbox_pending_idx ON outbox (aggregate_type, aggregate_id, id) WHERE delivered_at IS NULL;
+CREATE INDEX IF NOT EXISTS outbox_delivered_idx ON outbox (delivered_at) WHERE delivered_at IS NOT NULL;
+
+-- События пишутся триггерами, поэтому попадают в outbox в той же транзакции, что и изменение,
+-- кто бы его ни сделал: сервисы, массовый импорт, синхронизация с LDAP или роли по умолчанию.
+CREATE OR REPLACE FUNCTION outbox_employees() RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        INSERT INTO outbox (event_type, aggregate_type, aggregate_id, payload)
+        VALUES ('EmployeeCreated', 'employee', NEW.id, jsonb_build_object('employee', to_jsonb(NEW)));
+    ELSIF TG_OP = 'UPDATE' THEN
+        IF to_jsonb(NEW) - 'updated_at' = to_jsonb(OLD) - 'updated_at' THEN
+            RETURN NULL;
+        END IF;
+        INSERT INTO outbox (event_type, aggregate_type, aggregate_id, payload)
+        VALUES (
+            CASE
+                WHEN NEW.status IS NOT DISTINCT FROM OLD.status THEN 'EmployeeUpdated'
+                WHEN NEW.status = 'active' THEN 'EmployeeActivated'
+                ELSE 'EmployeeDisabled'
+            END,
+            'employee', NEW.id, jsonb_build_object('employee', to_jsonb(NEW), 'previous', to_jsonb(OLD)));
+    ELSE
+        INSERT INTO outbox (event_type, aggregate_type, aggregate_id, payload)
+        VALUES ('EmployeeRemoved', 'employee', OLD.id, jsonb_build_object('employee', to_jsonb(OLD)));
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION outbox_roles() RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        INSERT INTO outbox (event_type, aggregate_type, aggregate_id, payload)
+        VALUES ('RoleCreated', 'role', NEW.id, jsonb_build_object('role', to_jsonb(NEW)));
+    ELSIF TG_OP = 'UPDATE' THEN
+        IF to_jsonb(NEW) - 'updated_at' = to_jsonb(OLD) - 'updated_at' THEN
+            RETURN NULL;
+        END IF;
+        INSERT INTO outbox (event_type, aggregate_type, aggregate_id, payload)
+        VALUES ('RoleUpdated', 'role', NEW.id, jsonb_build_object('role', to_jsonb(NEW), 'previous', to_jsonb(OLD)));
+    ELSE
+        INSERT INTO outbox (event_type, aggregate_type, aggregate_id, payload)
+        VALUES ('RoleRemoved', 'role', OLD.id, jsonb_build_object('role', to_jsonb(OLD)));
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Выдача и отзыв роли относятся к сотруднику, чтобы они упорядочивались вместе с его изменениями.
+-- Отзыв при удалении самого сотрудника не пишется: его заменяет EmployeeRemoved.
+CREATE OR REPLACE FUNCTION outbox_employee_roles() RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        INSERT INTO outbox (event_type, aggregate_type, aggregate_id, payload)
+        VALUES ('RoleAssigned', 'employee', NEW.employee_id,
+            jsonb_build_object('employee_id', NEW.employee_id, 'role_id', NEW.role_id, 'source', NEW.source));
+    ELSIF EXISTS (SELECT 1 FROM employees WHERE id = OLD.employee_id) THEN
+        INSERT INTO outbox (event_type, aggregate_type, aggregate_id, payload)
+        VALUES ('RoleRevoked', 'employee', OLD.employee_id,
+            jsonb_build_object('employee_id', OLD.employee_id, 'role_id', OLD.role_id, 'source', OLD.source));
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS employees_outbox ON employees;
+CREATE TRIGGER employees_outbox AFTER INSERT OR UPDATE OR DELETE ON employees
+    FOR EACH ROW EXECUTE FUNCTION outbox_employees();
+
+DROP TRIGGER IF EXISTS roles_outbox ON roles;
+CREATE TRIGGER roles_outbox AFTER INSERT OR UPDATE OR DELETE ON roles
+    FOR EACH ROW EXECUTE FUNCTION outbox_roles();
+
+DROP TRIGGER IF EXISTS employee_roles_outbox ON employee_roles;
+CREATE TRIGGER employee_roles_outbox AFTER INSERT OR DELETE ON employee_roles
+    FOR EACH ROW EXECUTE FUNCTION outbox_employee_roles();
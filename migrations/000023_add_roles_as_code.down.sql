@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS roles_state;
+ALTER TABLE roles DROP COLUMN IF EXISTS permissions;
+ALTER TABLE roles DROP COLUMN IF EXISTS description;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS employees_manager_idx;
+ALTER TABLE employees DROP COLUMN IF EXISTS department_id;
+DROP TABLE IF EXISTS departments;
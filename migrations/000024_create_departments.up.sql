@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS departments (
+    id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    name TEXT NOT NULL,
+    -- подразделение с дочерними удалить нельзя: сначала их нужно перенести или удалить
+    parent_id BIGINT REFERENCES departments (id) ON DELETE RESTRICT,
+    head_id BIGINT REFERENCES employees (id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- имена уникальны среди подразделений одного родителя
+CREATE UNIQUE INDEX IF NOT EXISTS departments_name_idx ON departments (COALESCE(parent_id, 0), lower(name));
+CREATE INDEX IF NOT EXISTS departments_parent_idx ON departments (parent_id);
+
+ALTER TABLE employees ADD COLUMN IF NOT EXISTS department_id BIGINT REFERENCES departments (id) ON DELETE SET NULL;
+CREATE INDEX IF NOT EXISTS employees_department_idx ON employees (department_id);
+-- рекурсивные запросы по линиям подчинения
+CREATE INDEX IF NOT EXISTS employees_manager_idx ON employees (manager_id);
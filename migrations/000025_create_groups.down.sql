@@ -0,0 +1,5 @@
+DELETE FROM employee_roles WHERE source = 'group';
+DROP TABLE IF EXISTS group_roles;
+DROP TABLE IF EXISTS group_subgroups;
+DROP TABLE IF EXISTS group_members;
+DROP TABLE IF EXISTS groups;
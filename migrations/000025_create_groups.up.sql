@@ -0,0 +1,36 @@
+CREATE TABLE IF NOT EXISTS groups (
+    id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    name TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    -- фильтр SCIM по атрибутам сотрудника для динамического членства; пустой – только явные участники
+    rule TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS groups_name_idx ON groups (lower(name));
+
+CREATE TABLE IF NOT EXISTS group_members (
+    group_id BIGINT NOT NULL REFERENCES groups (id) ON DELETE CASCADE,
+    employee_id BIGINT NOT NULL REFERENCES employees (id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (group_id, employee_id)
+);
+
+-- вложенные группы: участники subgroup_id входят в group_id; циклы не допускает сервис
+CREATE TABLE IF NOT EXISTS group_subgroups (
+    group_id BIGINT NOT NULL REFERENCES groups (id) ON DELETE CASCADE,
+    subgroup_id BIGINT NOT NULL REFERENCES groups (id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (group_id, subgroup_id),
+    CHECK (group_id <> subgroup_id)
+);
+
+CREATE INDEX IF NOT EXISTS group_subgroups_subgroup_idx ON group_subgroups (subgroup_id);
+
+-- роли группы выдаются всем её участникам в employee_roles с источником group
+CREATE TABLE IF NOT EXISTS group_roles (
+    group_id BIGINT NOT NULL REFERENCES groups (id) ON DELETE CASCADE,
+    role_id BIGINT NOT NULL REFERENCES roles (id) ON DELETE CASCADE,
+    PRIMARY KEY (group_id, role_id)
+);
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS access_requests_open_idx;
@@ -0,0 +1,23 @@
+-- Из незавершённых заявок одного сотрудника на одну роль остаётся самая ранняя;
+-- остальные отменяются с записью в истории
+WITH duplicates AS (
+    SELECT id, status, current_step FROM access_requests
+    WHERE status IN ('pending', 'escalated', 'approved')
+      AND EXISTS (
+        SELECT 1 FROM access_requests earlier
+        WHERE earlier.employee_id = access_requests.employee_id
+          AND earlier.role_id = access_requests.role_id
+          AND earlier.status IN ('pending', 'escalated', 'approved')
+          AND earlier.id < access_requests.id
+      )
+), cancelled AS (
+    UPDATE access_requests SET status = 'cancelled', step_deadline = NULL, updated_at = CURRENT_TIMESTAMP
+    FROM duplicates WHERE access_requests.id = duplicates.id
+    RETURNING access_requests.id
+)
+INSERT INTO access_request_history (request_id, from_status, to_status, step, comment)
+SELECT duplicates.id, duplicates.status, 'cancelled', duplicates.current_step, 'duplicate of an earlier open request'
+FROM duplicates JOIN cancelled ON cancelled.id = duplicates.id;
+
+CREATE UNIQUE INDEX IF NOT EXISTS access_requests_open_idx ON access_requests (employee_id, role_id)
+    WHERE status IN ('pending', 'escalated', 'approved');
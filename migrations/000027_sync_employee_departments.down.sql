@@ -0,0 +1,2 @@
+-- Прежние названия не сохранялись: откат не меняет данные
+SELECT 1;
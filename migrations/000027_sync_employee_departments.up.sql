@@ -0,0 +1,4 @@
+-- Название подразделения сотрудника хранится рядом с department_id и меняется тем же запросом
+UPDATE employees e SET department = d.name, updated_at = CURRENT_TIMESTAMP
+FROM departments d
+WHERE e.department_id = d.id AND e.department <> d.name;
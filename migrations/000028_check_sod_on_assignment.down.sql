@@ -0,0 +1,2 @@
+DROP TRIGGER IF EXISTS employee_roles_sod ON employee_roles;
+DROP FUNCTION IF EXISTS check_sod_employee_roles();
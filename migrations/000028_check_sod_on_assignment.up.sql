@@ -0,0 +1,35 @@
+-- Правила разделения полномочий проверяются триггером в той же транзакции, что и выдача роли,
+-- кто бы её ни выдавал. Проверка сервиса sod остаётся, чтобы отклонять выдачу до записи и
+-- объяснять причину, а триггер закрывает гонку между проверкой и записью: выдачи одному
+-- сотруднику выполняются по очереди под блокировкой, и каждая видит роли, выданные предыдущей.
+CREATE OR REPLACE FUNCTION check_sod_employee_roles() RETURNS TRIGGER AS $$
+DECLARE
+    violated TEXT;
+BEGIN
+    PERFORM pg_advisory_xact_lock(hashtext('idm_employee_roles:' || NEW.employee_id));
+
+    SELECT r.name INTO violated
+    FROM sod_rules r
+    JOIN sod_rule_roles rr ON rr.rule_id = r.id
+    JOIN employee_roles er ON er.role_id = rr.role_id AND er.employee_id = NEW.employee_id
+    WHERE r.id IN (SELECT rule_id FROM sod_rule_roles WHERE role_id = NEW.role_id)
+        AND NOT EXISTS (
+            SELECT 1 FROM sod_exceptions x
+            WHERE x.rule_id = r.id AND x.employee_id = NEW.employee_id AND x.expires_at > CURRENT_TIMESTAMP
+        )
+    GROUP BY r.id, r.name, r.max_roles
+    HAVING count(*) > r.max_roles
+    ORDER BY r.id
+    LIMIT 1;
+
+    IF violated IS NOT NULL THEN
+        RAISE EXCEPTION 'role % conflicts with rule "%" for employee %', NEW.role_id, violated, NEW.employee_id
+            USING ERRCODE = 'IDM01';
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS employee_roles_sod ON employee_roles;
+CREATE TRIGGER employee_roles_sod AFTER INSERT ON employee_roles
+    FOR EACH ROW EXECUTE FUNCTION check_sod_employee_roles();
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS oidc_refresh_tokens_family_idx;
+ALTER TABLE oidc_refresh_tokens DROP COLUMN IF EXISTS family;
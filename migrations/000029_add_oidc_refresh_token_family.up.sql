@@ -0,0 +1,7 @@
+-- Семейство токена обновления: все токены, полученные заменой токена одного входа.
+-- Повторное предъявление отозванного токена отзывает всё семейство.
+ALTER TABLE oidc_refresh_tokens ADD COLUMN IF NOT EXISTS family TEXT;
+UPDATE oidc_refresh_tokens SET family = hash WHERE family IS NULL;
+ALTER TABLE oidc_refresh_tokens ALTER COLUMN family SET NOT NULL;
+
+CREATE INDEX IF NOT EXISTS oidc_refresh_tokens_family_idx ON oidc_refresh_tokens (family);
@@ -0,0 +1,62 @@
+package bulkimport
+
+import (
+	assertpackage "github.com/stretchr/testify/assert"
+	"idm/inner/bulkimport"
+	"idm/inner/database"
+	"idm/inner/employee"
+	"testing"
+)
+
+func TestBulkImportRepository(t *testing.T) {
+	assert := assertpackage.New(t)
+	var db = database.ConnectDb()
+
+	var clearDb = func() {
+		db.MustExec("DELETE FROM employees")
+		db.MustExec("DELETE FROM departments")
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			clearDb()
+		}
+	}()
+
+	var employeeRepository = employee.NewRepository(db)
+	var importRepository = bulkimport.NewRepository(db)
+
+	t.Run("we take employees out of their department when the import renames it", func(t *testing.T) {
+		var departmentId int64
+		assert.Nil(db.Get(&departmentId, "INSERT INTO departments (name) VALUES ('Sales') RETURNING id"))
+		moved := &employee.Employee{Name: "John Doe", UserName: "jdoe"}
+		kept := &employee.Employee{Name: "Jane Doe", UserName: "jane"}
+		for _, empl := range []*employee.Employee{moved, kept} {
+			assert.Nil(employeeRepository.Create(empl))
+			assert.Nil(employeeRepository.Update(empl))
+		}
+		_, err := employeeRepository.SetDepartment([]int64{moved.Id, kept.Id}, &departmentId)
+		assert.Nil(err)
+
+		batch, err := importRepository.Begin(bulkimport.KindEmployees, "user_name")
+		assert.Nil(err)
+		assert.Nil(batch.Add(bulkimport.Row{Line: 1, Values: map[string]string{"user_name": "jdoe", "department": "Marketing"}}))
+		assert.Nil(batch.Add(bulkimport.Row{Line: 2, Values: map[string]string{"user_name": "jane", "title": "Lead"}}))
+		result, err := batch.Apply()
+		assert.Nil(err)
+		assert.ElementsMatch([]int64{moved.Id, kept.Id}, result.UpdatedIds)
+		assert.Nil(batch.Commit())
+
+		got, err := employeeRepository.FindById(moved.Id)
+		assert.Nil(err)
+		assert.Equal("Marketing", got.Department)
+		assert.Nil(got.DepartmentId)
+
+		got, err = employeeRepository.FindById(kept.Id)
+		assert.Nil(err)
+		assert.Equal("Sales", got.Department)
+		assert.Equal(&departmentId, got.DepartmentId)
+
+		clearDb()
+	})
+}
@@ -0,0 +1,79 @@
+package certification
+
+import (
+	assertpackage "github.com/stretchr/testify/assert"
+	"idm/inner/birthright"
+	"idm/inner/certification"
+	"idm/inner/database"
+	"idm/inner/employee"
+	"idm/inner/group"
+	"idm/inner/role"
+	"testing"
+	"time"
+)
+
+func TestCertificationRevoke(t *testing.T) {
+	assert := assertpackage.New(t)
+	var db = database.ConnectDb()
+
+	var clearDb = func() {
+		db.MustExec("DELETE FROM certification_campaigns")
+		db.MustExec("DELETE FROM groups")
+		db.MustExec("DELETE FROM birthright_rules")
+		db.MustExec("DELETE FROM roles")
+		db.MustExec("DELETE FROM employees")
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			clearDb()
+		}
+	}()
+
+	var employeeRepository = employee.NewRepository(db)
+	var roleRepository = role.NewRepository(db)
+
+	// источники связаны так же, как в cmd/main.go
+	roleService := role.NewService(roleRepository)
+	birthrightService := birthright.NewService(birthright.NewRepository(db))
+	groupService := group.NewService(group.NewRepository(db), roleService)
+	birthrightService.UseHook(groupService)
+	groupService.UseHook(birthrightService)
+	certificationRepository := certification.NewRepository(db)
+	certificationService := certification.NewService(certificationRepository, roleService)
+
+	t.Run("we keep a role revoked by certification even if birthright rules and groups grant it", func(t *testing.T) {
+		member := &employee.Employee{Name: "John Doe"}
+		owner := &employee.Employee{Name: "Jane Doe"}
+		assert.Nil(employeeRepository.Create(member))
+		assert.Nil(employeeRepository.Create(owner))
+		granted := &role.Role{Name: "Admin"}
+		assert.Nil(roleRepository.Create(granted))
+
+		_, err := birthrightService.Create(birthright.RuleRequest{Name: "everyone", RoleIds: []int64{granted.Id}})
+		assert.Nil(err)
+		_, err = birthrightService.Evaluate(member.Id)
+		assert.Nil(err)
+		admins, err := groupService.Create(group.Request{Name: "Admins"})
+		assert.Nil(err)
+		assert.Nil(groupService.AddMember(admins.Id, member.Id))
+		assert.Nil(groupService.GrantRole(admins.Id, granted.Id))
+
+		campaign, err := certificationService.Create(certification.CreateRequest{Name: "Q1", OwnerId: owner.Id,
+			RoleIds: []int64{granted.Id}, Deadline: time.Now().Add(24 * time.Hour)})
+		assert.Nil(err)
+		items, err := certificationRepository.FindItems(campaign.Id)
+		assert.Nil(err)
+		assert.Len(items, 1)
+
+		revoked, err := certificationService.Revoke(items[0].Id, owner.Id, "not needed")
+		assert.Nil(err)
+		assert.Equal(certification.DecisionRevoked, revoked.Decision)
+
+		roles, err := roleRepository.FindByEmployeeId(member.Id)
+		assert.Nil(err)
+		assert.Empty(roles)
+
+		clearDb()
+	})
+}
@@ -77,6 +77,29 @@ func TestEmployeeRepository(t *testing.T) {
 		clearDb()
 	})
 
+	t.Run("we can page employees after an id and count them", func(t *testing.T) {
+		emp1, _ := fixture.CreateEmployee("John Doe")
+		emp2, _ := fixture.CreateEmployee("Jane Doe")
+		emp3, _ := fixture.CreateEmployee("Jose Doe")
+
+		got, err := fixture.FindPage(0, 2)
+		assert.Nil(err)
+		assert.Len(got, 2)
+		assert.Equal(emp1.Id, got[0].Id)
+		assert.Equal(emp2.Id, got[1].Id)
+
+		got, err = fixture.FindPage(emp2.Id, 2)
+		assert.Nil(err)
+		assert.Len(got, 1)
+		assert.Equal(emp3.Id, got[0].Id)
+
+		count, err := fixture.Count()
+		assert.Nil(err)
+		assert.Equal(int64(3), count)
+
+		clearDb()
+	})
+
 	t.Run("we can find employees by ids", func(t *testing.T) {
 		emp1, _ := fixture.CreateEmployee("John Doe")
 		emp2, _ := fixture.CreateEmployee("Jane Doe")
@@ -161,4 +184,32 @@ func TestEmployeeRepository(t *testing.T) {
 
 		clearDb()
 	})
+
+	t.Run("we get ErrRecordNotFound when removing a missing employee", func(t *testing.T) {
+		emp, _ := fixture.CreateEmployee("John Doe")
+		assert.Nil(fixture.Remove(emp.Id))
+
+		err := fixture.Remove(emp.Id)
+		assert.ErrorIs(err, database.ErrRecordNotFound)
+
+		clearDb()
+	})
+
+	t.Run("we remove no employees by ids when one of them is missing", func(t *testing.T) {
+		emp1, _ := fixture.CreateEmployee("John Doe")
+		emp2, _ := fixture.CreateEmployee("Jane Doe")
+		assert.Nil(fixture.Remove(emp2.Id))
+
+		err := fixture.RemoveByIds([]int64{emp1.Id, emp2.Id})
+		assert.ErrorIs(err, database.ErrRecordNotFound)
+
+		got, err := fixture.FindById(emp1.Id)
+		assert.Nil(err)
+		assert.Equal(emp1.Id, got.Id)
+
+		err = fixture.RemoveByIds([]int64{emp1.Id, emp1.Id})
+		assert.Nil(err)
+
+		clearDb()
+	})
 }
@@ -24,6 +24,14 @@ func (f *Fixture) FindAll() ([]*employee.Employee, error) {
 	return f.employees.FindAll()
 }
 
+func (f *Fixture) FindPage(afterId int64, limit int) ([]*employee.Employee, error) {
+	return f.employees.FindPage(afterId, limit)
+}
+
+func (f *Fixture) Count() (int64, error) {
+	return f.employees.Count()
+}
+
 func (f *Fixture) CreateEmployee(name string) (*employee.Employee, error) {
 	empl := &employee.Employee{Name: name}
 	err := f.employees.Create(empl)
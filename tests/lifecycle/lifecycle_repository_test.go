@@ -0,0 +1,62 @@
+package lifecycle
+
+import (
+	"github.com/jmoiron/sqlx/types"
+	assertpackage "github.com/stretchr/testify/assert"
+	"idm/inner/database"
+	"idm/inner/employee"
+	"idm/inner/lifecycle"
+	"testing"
+	"time"
+)
+
+func TestLifecycleRepository(t *testing.T) {
+	assert := assertpackage.New(t)
+	var db = database.ConnectDb()
+
+	var clearDb = func() {
+		db.MustExec("DELETE FROM lifecycle_changes")
+		db.MustExec("DELETE FROM employees")
+		db.MustExec("DELETE FROM departments")
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			clearDb()
+		}
+	}()
+
+	var employeeRepository = employee.NewRepository(db)
+	var lifecycleRepository = lifecycle.NewRepository(db)
+
+	var transfer = func(empl *employee.Employee, department string) error {
+		empl.Department = department
+		change := &lifecycle.Change{Kind: lifecycle.KindTransfer, EmployeeId: &empl.Id,
+			Payload: types.JSONText("{}"), EffectiveAt: time.Now()}
+
+		return lifecycleRepository.Apply(change, &lifecycle.Effect{Update: empl})
+	}
+
+	t.Run("we keep the department of a transferred employee only while its name is the same", func(t *testing.T) {
+		var departmentId int64
+		assert.Nil(db.Get(&departmentId, "INSERT INTO departments (name) VALUES ('Sales') RETURNING id"))
+		empl := &employee.Employee{Name: "John Doe"}
+		assert.Nil(employeeRepository.Create(empl))
+		_, err := employeeRepository.SetDepartment([]int64{empl.Id}, &departmentId)
+		assert.Nil(err)
+
+		empl.Title = "Lead"
+		assert.Nil(transfer(empl, "Sales"))
+		assert.Equal(&departmentId, empl.DepartmentId)
+
+		assert.Nil(transfer(empl, "Marketing"))
+		assert.Nil(empl.DepartmentId)
+
+		got, err := employeeRepository.FindById(empl.Id)
+		assert.Nil(err)
+		assert.Equal("Marketing", got.Department)
+		assert.Nil(got.DepartmentId)
+
+		clearDb()
+	})
+}
@@ -14,6 +14,14 @@ func (f *Fixture) FindAll() ([]*role.Role, error) {
 	return f.roles.FindAll()
 }
 
+func (f *Fixture) FindPage(afterId int64, limit int) ([]*role.Role, error) {
+	return f.roles.FindPage(afterId, limit)
+}
+
+func (f *Fixture) Count() (int64, error) {
+	return f.roles.Count()
+}
+
 func (f *Fixture) FindById(id int64) (*role.Role, error) {
 	return f.roles.FindById(id)
 }
@@ -36,3 +44,15 @@ func (f *Fixture) Remove(id int64) error {
 func (f *Fixture) RemoveByIds(ids []int64) error {
 	return f.roles.RemoveByIds(ids)
 }
+
+func (f *Fixture) FindByEmployeeId(employeeId int64) ([]*role.Role, error) {
+	return f.roles.FindByEmployeeId(employeeId)
+}
+
+func (f *Fixture) Assign(employeeId int64, roleId int64) error {
+	return f.roles.Assign(employeeId, roleId)
+}
+
+func (f *Fixture) Revoke(employeeId int64, roleId int64) error {
+	return f.roles.Revoke(employeeId, roleId)
+}
@@ -3,6 +3,7 @@ package role
 import (
 	assertpackage "github.com/stretchr/testify/assert"
 	"idm/inner/database"
+	"idm/inner/employee"
 	"idm/inner/role"
 	"testing"
 )
@@ -78,6 +79,27 @@ func TestRoleRepository(t *testing.T) {
 		clearDb()
 	})
 
+	t.Run("we can page roles after an id and count them", func(t *testing.T) {
+		role1, _ := fixture.Create("Admin")
+		role2, _ := fixture.Create("Developer")
+
+		got, err := fixture.FindPage(0, 1)
+		assert.Nil(err)
+		assert.Len(got, 1)
+		assert.Equal(role1.Id, got[0].Id)
+
+		got, err = fixture.FindPage(role1.Id, 10)
+		assert.Nil(err)
+		assert.Len(got, 1)
+		assert.Equal(role2.Id, got[0].Id)
+
+		count, err := fixture.Count()
+		assert.Nil(err)
+		assert.Equal(int64(2), count)
+
+		clearDb()
+	})
+
 	t.Run("we can find roles by ids", func(t *testing.T) {
 		roleEntity, err := fixture.Create("Admin")
 		if err != nil {
@@ -125,4 +147,62 @@ func TestRoleRepository(t *testing.T) {
 
 		clearDb()
 	})
+
+	t.Run("we can assign and revoke a role", func(t *testing.T) {
+		roleEntity, err := fixture.Create("Admin")
+		if err != nil {
+			t.Logf("unexpected error while creating role: %v", err)
+		}
+		empl := &employee.Employee{Name: "John Doe"}
+		err = employee.NewRepository(db).Create(empl)
+		if err != nil {
+			t.Logf("unexpected error while creating employee: %v", err)
+		}
+
+		err = fixture.Assign(empl.Id, roleEntity.Id)
+		assert.Nil(err)
+		got, err := fixture.FindByEmployeeId(empl.Id)
+		assert.Nil(err)
+		assert.Len(got, 1)
+		assert.Equal(roleEntity.Id, got[0].Id)
+
+		err = fixture.Revoke(empl.Id, roleEntity.Id)
+		assert.Nil(err)
+		got, err = fixture.FindByEmployeeId(empl.Id)
+		assert.Nil(err)
+		assert.Empty(got)
+
+		clearDb()
+		db.MustExec("DELETE FROM employees")
+	})
+
+	t.Run("we get ErrRecordNotFound when removing a missing role", func(t *testing.T) {
+		roleEntity, _ := fixture.Create("Admin")
+		assert.Nil(fixture.Remove(roleEntity.Id))
+
+		err := fixture.Remove(roleEntity.Id)
+		assert.ErrorIs(err, database.ErrRecordNotFound)
+
+		clearDb()
+	})
+
+	t.Run("we remove no roles by ids when one of them is missing", func(t *testing.T) {
+		admin, _ := fixture.Create("Admin")
+		viewer, _ := fixture.Create("Viewer")
+		assert.Nil(fixture.Remove(viewer.Id))
+
+		err := fixture.RemoveByIds([]int64{admin.Id, viewer.Id})
+		assert.ErrorIs(err, database.ErrRecordNotFound)
+
+		got, err := fixture.FindById(admin.Id)
+		assert.Nil(err)
+		assert.Equal(admin.Id, got.Id)
+
+		err = fixture.RemoveByIds([]int64{admin.Id})
+		assert.Nil(err)
+		_, err = fixture.FindById(admin.Id)
+		assert.ErrorIs(err, database.ErrRecordNotFound)
+
+		clearDb()
+	})
 }